@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os/exec"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -13,6 +15,26 @@ var (
 	benchRequests    int
 	benchConcurrency int
 	benchMonitor     bool
+	benchProtocol    string
+	benchMaxConnAge  time.Duration
+	benchMaxConnReqs int
+	benchHostHeader  string
+	benchSNI         string
+	benchCapture     []string
+	benchPlugins     []string
+	benchScript      string
+	benchData        string
+	benchNoFollow    bool
+	benchRedirects   int
+	benchDuration    time.Duration
+	benchRPS         float64
+	benchFindMaxRPS  bool
+	benchSLO         string
+	benchColdPath    bool
+	benchEventSink   string
+	benchWebhook     string
+	benchWebhookOn   string
+	benchBudget      string
 )
 
 var benchmarkCmd = &cobra.Command{
@@ -44,6 +66,26 @@ func init() {
 	benchmarkCmd.Flags().IntVarP(&benchRequests, "requests", "r", 1000, "number of requests to send")
 	benchmarkCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "c", 10, "number of concurrent requests")
 	benchmarkCmd.Flags().BoolVarP(&benchMonitor, "monitor", "m", false, "enable real-time monitoring dashboard")
+	benchmarkCmd.Flags().StringVar(&benchProtocol, "force-protocol", "", "speak only this protocol instead of negotiating (h1, h2, h2c, h3)")
+	benchmarkCmd.Flags().StringSliceVar(&benchCapture, "capture-headers", nil, "response headers to record per request, e.g. X-Request-Id,CF-Cache-Status")
+	benchmarkCmd.Flags().BoolVar(&benchNoFollow, "no-follow", false, "do not follow redirects; the first 3xx response ends each request")
+	benchmarkCmd.Flags().IntVar(&benchRedirects, "max-redirects", 0, "redirect hops followed before the last 3xx response ends the request (0 = 10)")
+	benchmarkCmd.Flags().StringVar(&benchData, "data", "", "CSV or JSONL file whose rows fill {{column}} placeholders in the URL, distinct rows per worker")
+	benchmarkCmd.Flags().StringVar(&benchScript, "script", "", "JavaScript file defining request(ctx) to compute each request's URL, headers, body and cache key")
+	benchmarkCmd.Flags().StringSliceVar(&benchPlugins, "plugin", nil, "go plugins (.so) supplying custom response checks and metrics")
+	benchmarkCmd.Flags().StringVar(&benchHostHeader, "host-header", "", "send this Host header instead of the URL's host (also used as the SNI)")
+	benchmarkCmd.Flags().StringVar(&benchSNI, "sni", "", "send this TLS server name and verify the certificate against it")
+	benchmarkCmd.Flags().DurationVar(&benchMaxConnAge, "max-conn-age", 0, "reconnect once a connection is this old (0 = never)")
+	benchmarkCmd.Flags().IntVar(&benchMaxConnReqs, "max-conn-requests", 0, "reconnect once a connection has served this many requests (0 = unlimited)")
+	benchmarkCmd.Flags().DurationVar(&benchDuration, "duration", 0, "soak test: hold constant load this long (e.g. 24h) and check for leaks and latency drift")
+	benchmarkCmd.Flags().Float64Var(&benchRPS, "rps", 10, "requests per second in a soak test")
+	benchmarkCmd.Flags().BoolVar(&benchFindMaxRPS, "find-max-rps", false, "search for the highest request rate sustained within --slo")
+	benchmarkCmd.Flags().BoolVar(&benchColdPath, "cold-path", false, "compare the optimized path with a new connection, DNS lookup and TLS handshake per request")
+	benchmarkCmd.Flags().StringVar(&benchEventSink, "event-sink", "", "stream request and iteration events to nats://host:4222/subject or kafka+http://rest-proxy:8082/topic")
+	benchmarkCmd.Flags().StringVar(&benchWebhook, "webhook", "", "comma-separated URLs notified when a run completes or breaches its budgets")
+	benchmarkCmd.Flags().StringVar(&benchBudget, "budget", "", "budgets a run breaches for slo_breach webhooks, e.g. p95=250ms,error_rate=1%")
+	benchmarkCmd.Flags().StringVar(&benchWebhookOn, "webhook-on", "", "webhook events: run_complete, slo_breach (default: both)")
+	benchmarkCmd.Flags().StringVar(&benchSLO, "slo", "", "budgets for --find-max-rps, e.g. p95=250ms,error_rate=1%")
 }
 
 func runBenchmark(url string) {
@@ -54,9 +96,28 @@ func runBenchmark(url string) {
 
 	fmt.Println(color.YellowString("🚀 Benchmark Configuration:"))
 	fmt.Printf("   URL: %s\n", color.CyanString(url))
-	fmt.Printf("   Requests: %s\n", color.CyanString(strconv.Itoa(benchRequests)))
+	if benchDuration > 0 {
+		fmt.Printf("   Soak: %s\n", color.CyanString("%v at %g req/s", benchDuration, benchRPS))
+	} else if benchFindMaxRPS {
+		fmt.Printf("   Mode: %s\n", color.CyanString("throughput ceiling search"))
+	} else {
+		fmt.Printf("   Requests: %s\n", color.CyanString(strconv.Itoa(benchRequests)))
+	}
 	fmt.Printf("   Concurrency: %s\n", color.CyanString(strconv.Itoa(benchConcurrency)))
-	fmt.Printf("   Monitoring: %s\n\n", color.CyanString(strconv.FormatBool(benchMonitor)))
+	fmt.Printf("   Monitoring: %s\n", color.CyanString(strconv.FormatBool(benchMonitor)))
+	if benchProtocol != "" {
+		fmt.Printf("   Protocol: %s\n", color.CyanString(benchProtocol))
+	}
+	if benchHostHeader != "" {
+		fmt.Printf("   Host header: %s\n", color.CyanString(benchHostHeader))
+	}
+	if benchSNI != "" {
+		fmt.Printf("   SNI: %s\n", color.CyanString(benchSNI))
+	}
+	if benchMaxConnAge > 0 || benchMaxConnReqs > 0 {
+		fmt.Printf("   Connection rotation: %s\n", color.CyanString("max age %v, max requests %d", benchMaxConnAge, benchMaxConnReqs))
+	}
+	fmt.Println()
 
 	// Check if the main optimizer binary exists
 	optimizerPath := "/Users/joshkornreich/Documents/Projects/api-latency-optimizer/bin/api-optimizer"
@@ -71,6 +132,63 @@ func runBenchmark(url string) {
 	if benchMonitor {
 		args = append(args, "--monitor")
 	}
+	if benchProtocol != "" {
+		args = append(args, "--force-protocol", benchProtocol)
+	}
+	if len(benchCapture) > 0 {
+		args = append(args, "--capture-headers", strings.Join(benchCapture, ","))
+	}
+	if benchNoFollow {
+		args = append(args, "--no-follow")
+	}
+	if benchRedirects > 0 {
+		args = append(args, "--max-redirects", strconv.Itoa(benchRedirects))
+	}
+	if benchData != "" {
+		args = append(args, "--data", benchData)
+	}
+	if benchScript != "" {
+		args = append(args, "--script", benchScript)
+	}
+	if len(benchPlugins) > 0 {
+		args = append(args, "--plugin", strings.Join(benchPlugins, ","))
+	}
+	if benchHostHeader != "" {
+		args = append(args, "--host-header", benchHostHeader)
+	}
+	if benchSNI != "" {
+		args = append(args, "--sni", benchSNI)
+	}
+	if benchMaxConnAge > 0 {
+		args = append(args, "--max-conn-age", benchMaxConnAge.String())
+	}
+	if benchMaxConnReqs > 0 {
+		args = append(args, "--max-conn-requests", strconv.Itoa(benchMaxConnReqs))
+	}
+	if benchDuration > 0 {
+		args = append(args, "--duration", benchDuration.String(), "--rps", strconv.FormatFloat(benchRPS, 'f', -1, 64))
+	}
+	if benchFindMaxRPS {
+		args = append(args, "--find-max-rps")
+	}
+	if benchSLO != "" {
+		args = append(args, "--slo", benchSLO)
+	}
+	if benchColdPath {
+		args = append(args, "--cold-path")
+	}
+	if benchEventSink != "" {
+		args = append(args, "--event-sink", benchEventSink)
+	}
+	if benchBudget != "" {
+		args = append(args, "--budget", benchBudget)
+	}
+	if benchWebhook != "" {
+		args = append(args, "--webhook", benchWebhook)
+		if benchWebhookOn != "" {
+			args = append(args, "--webhook-on", benchWebhookOn)
+		}
+	}
 
 	// Try to run the existing optimizer
 	cmd := exec.Command(optimizerPath, args...)
@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// Canary verdicts; the process exits 0, 1 and 2 respectively
+const (
+	canaryPass         = "PASS"
+	canaryFail         = "FAIL"
+	canaryInconclusive = "INCONCLUSIVE"
+)
+
+var (
+	canaryPrimary          string
+	canaryCandidate        string
+	canaryDuration         time.Duration
+	canaryInterval         time.Duration
+	canaryConcurrency      int
+	canaryMethod           string
+	canaryHeaders          []string
+	canaryBody             string
+	canaryTimeout          time.Duration
+	canaryLatencyThreshold float64
+	canaryErrorThreshold   float64
+	canaryConfidence       float64
+	canaryMinSamples       int
+	canaryFailFast         bool
+	canaryProgress         time.Duration
+	canaryReport           string
+)
+
+var canaryCmd = &cobra.Command{
+	Use:   "canary --primary URL --candidate URL",
+	Short: "Compare two live endpoints and gate on the result",
+	Long: `Continuously sample a primary and a candidate endpoint with the same
+workload and decide whether the candidate is safe to promote.
+
+Each round sends the same request to both endpoints in random order, so
+network drift affects both equally. When sampling ends, latency (P50, P95)
+is compared with a Mann-Whitney U test and error rates (transport errors
+and 5xx) with a two-proportion z-test. A check fails only when the
+candidate is worse than its threshold with at least --confidence.
+
+Exit status is 0 for PASS, 1 for FAIL and 2 for INCONCLUSIVE (fewer than
+--min-samples successful requests per endpoint), so it can gate a deploy:
+  apilo canary --primary https://api.example.com/v1/health \
+               --candidate https://canary.example.com/v1/health --duration 30m`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(runCanary())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(canaryCmd)
+
+	canaryCmd.Flags().StringVar(&canaryPrimary, "primary", "", "URL of the current endpoint (required)")
+	canaryCmd.Flags().StringVar(&canaryCandidate, "candidate", "", "URL of the endpoint under evaluation (required)")
+	canaryCmd.Flags().DurationVar(&canaryDuration, "duration", 30*time.Minute, "how long to sample")
+	canaryCmd.Flags().DurationVar(&canaryInterval, "interval", time.Second, "pause between rounds per worker")
+	canaryCmd.Flags().IntVarP(&canaryConcurrency, "concurrency", "c", 1, "concurrent sampling workers")
+	canaryCmd.Flags().StringVarP(&canaryMethod, "method", "X", http.MethodGet, "HTTP method")
+	canaryCmd.Flags().StringArrayVarP(&canaryHeaders, "header", "H", nil, "request header as 'Name: value' (repeatable)")
+	canaryCmd.Flags().StringVar(&canaryBody, "body", "", "request body, or @file to read it from a file")
+	canaryCmd.Flags().DurationVar(&canaryTimeout, "timeout", 10*time.Second, "per-request timeout")
+	canaryCmd.Flags().Float64Var(&canaryLatencyThreshold, "latency-threshold", 10, "percent latency increase tolerated")
+	canaryCmd.Flags().Float64Var(&canaryErrorThreshold, "error-threshold", 1, "error rate increase tolerated, in percentage points")
+	canaryCmd.Flags().Float64Var(&canaryConfidence, "confidence", 0.95, "confidence required to fail a check")
+	canaryCmd.Flags().IntVar(&canaryMinSamples, "min-samples", 30, "successful samples per endpoint needed for a verdict")
+	canaryCmd.Flags().BoolVar(&canaryFailFast, "fail-fast", false, "stop as soon as a check fails")
+	canaryCmd.Flags().DurationVar(&canaryProgress, "progress", time.Minute, "interval between progress lines (0 disables)")
+	canaryCmd.Flags().StringVar(&canaryReport, "report", "", "write the final report as JSON to this file")
+
+	canaryCmd.MarkFlagRequired("primary")
+	canaryCmd.MarkFlagRequired("candidate")
+}
+
+// canaryTarget collects the samples of one endpoint
+type canaryTarget struct {
+	url       string
+	client    *http.Client
+	latencies []float64 // Milliseconds, successful requests only
+	requests  int
+	errors    int
+	statuses  map[int]int
+	mu        sync.Mutex
+}
+
+func newCanaryTarget(url string) *canaryTarget {
+	// Separate transports keep each endpoint's connection pool warm
+	// independently of the other's
+	return &canaryTarget{
+		url:      url,
+		client:   &http.Client{Timeout: canaryTimeout, Transport: http.DefaultTransport.(*http.Transport).Clone()},
+		statuses: make(map[int]int),
+	}
+}
+
+// sample sends one request and records its outcome
+func (t *canaryTarget) sample(ctx context.Context, headers http.Header, body []byte) {
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, canaryMethod, t.url, reader)
+	if err != nil {
+		return
+	}
+	req.Header = headers.Clone()
+
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	latency := time.Since(start)
+
+	// Requests cut short by the end of the run are not the endpoint's fault
+	if ctx.Err() != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requests++
+	if err != nil {
+		t.errors++
+		return
+	}
+	t.statuses[resp.StatusCode]++
+	if resp.StatusCode >= 500 {
+		t.errors++
+		return
+	}
+	t.latencies = append(t.latencies, float64(latency)/float64(time.Millisecond))
+}
+
+// snapshot returns sorted latencies and counters
+func (t *canaryTarget) snapshot() (latencies []float64, requests, errors int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latencies = append([]float64(nil), t.latencies...)
+	sort.Float64s(latencies)
+	return latencies, t.requests, t.errors
+}
+
+// CanaryEndpoint summarizes one endpoint's samples in the report
+type CanaryEndpoint struct {
+	URL      string      `json:"url"`
+	Requests int         `json:"requests"`
+	Errors   int         `json:"errors"`
+	P50      float64     `json:"p50_ms"`
+	P95      float64     `json:"p95_ms"`
+	P99      float64     `json:"p99_ms"`
+	Statuses map[int]int `json:"statuses"`
+}
+
+// CanaryReport is the outcome of a canary run
+type CanaryReport struct {
+	Verdict    string         `json:"verdict"`
+	Confidence float64        `json:"confidence"`
+	Reason     string         `json:"reason,omitempty"`
+	Started    time.Time      `json:"started"`
+	Elapsed    string         `json:"elapsed"`
+	Primary    CanaryEndpoint `json:"primary"`
+	Candidate  CanaryEndpoint `json:"candidate"`
+	Checks     []canaryCheck  `json:"checks"`
+}
+
+// evaluateCanary compares the endpoints' samples so far
+func evaluateCanary(primary, candidate *canaryTarget) CanaryReport {
+	pLat, pReq, pErr := primary.snapshot()
+	cLat, cReq, cErr := candidate.snapshot()
+
+	p50 := func(v []float64) float64 { return canaryPercentile(v, 50) }
+	p95 := func(v []float64) float64 { return canaryPercentile(v, 95) }
+
+	report := CanaryReport{
+		Primary:   canaryEndpoint(primary, pLat, pReq, pErr),
+		Candidate: canaryEndpoint(candidate, cLat, cReq, cErr),
+		Checks: []canaryCheck{
+			latencyCheck("Latency P50", pLat, cLat, p50, canaryLatencyThreshold, canaryConfidence),
+			latencyCheck("Latency P95", pLat, cLat, p95, canaryLatencyThreshold, canaryConfidence),
+			errorRateCheck(pErr, pReq, cErr, cReq, canaryErrorThreshold, canaryConfidence),
+		},
+	}
+
+	// The error check stays meaningful when one side fails every request, so
+	// it can fail the run before there are enough latency samples
+	report.Verdict = canaryPass
+	report.Confidence = 1
+	for _, check := range report.Checks {
+		if check.Failed {
+			if report.Verdict != canaryFail || check.Confidence > report.Confidence {
+				report.Confidence = check.Confidence
+			}
+			report.Verdict = canaryFail
+			unit := "%"
+			if check.Unit == "%" {
+				unit = " pp"
+			}
+			report.Reason = fmt.Sprintf("%s regressed %+.1f%s (threshold %g%s)", check.Name, check.Delta, unit, check.Threshold, unit)
+		} else if report.Verdict == canaryPass && check.Confidence < report.Confidence {
+			report.Confidence = check.Confidence
+		}
+	}
+
+	if report.Verdict != canaryFail && (len(pLat) < canaryMinSamples || len(cLat) < canaryMinSamples) {
+		report.Verdict = canaryInconclusive
+		report.Confidence = 0
+		report.Reason = fmt.Sprintf("need %d successful samples per endpoint, have %d and %d", canaryMinSamples, len(pLat), len(cLat))
+	}
+	return report
+}
+
+func canaryEndpoint(target *canaryTarget, latencies []float64, requests, errors int) CanaryEndpoint {
+	target.mu.Lock()
+	statuses := make(map[int]int, len(target.statuses))
+	for code, count := range target.statuses {
+		statuses[code] = count
+	}
+	target.mu.Unlock()
+
+	return CanaryEndpoint{
+		URL:      target.url,
+		Requests: requests,
+		Errors:   errors,
+		P50:      canaryPercentile(latencies, 50),
+		P95:      canaryPercentile(latencies, 95),
+		P99:      canaryPercentile(latencies, 99),
+		Statuses: statuses,
+	}
+}
+
+// canaryRequest builds the shared workload from the flags
+func canaryRequest() (http.Header, []byte, error) {
+	headers := make(http.Header)
+	for _, header := range canaryHeaders {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid header %q: use 'Name: value'", header)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	body := []byte(canaryBody)
+	if path, ok := strings.CutPrefix(canaryBody, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		body = data
+	}
+	return headers, body, nil
+}
+
+// runCanary samples both endpoints and returns the process exit status
+func runCanary() int {
+	headers, body, err := canaryRequest()
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		return 2
+	}
+	if canaryConcurrency < 1 {
+		canaryConcurrency = 1
+	}
+
+	primary := newCanaryTarget(canaryPrimary)
+	candidate := newCanaryTarget(canaryCandidate)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, canaryDuration)
+	defer cancel()
+
+	terminal := output != "json"
+	if terminal {
+		color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+		color.Cyan("║                       Canary Analysis                             ║")
+		color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+		fmt.Printf("   Primary:   %s\n", color.CyanString(canaryPrimary))
+		fmt.Printf("   Candidate: %s\n", color.CyanString(canaryCandidate))
+		fmt.Printf("   Duration:  %s (%d workers, %s interval)\n\n", color.CyanString(canaryDuration.String()), canaryConcurrency, canaryInterval)
+	}
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < canaryConcurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for ctx.Err() == nil {
+				first, second := primary, candidate
+				if rng.Intn(2) == 1 {
+					first, second = candidate, primary
+				}
+				first.sample(ctx, headers, body)
+				second.sample(ctx, headers, body)
+
+				select {
+				case <-ctx.Done():
+				case <-time.After(canaryInterval):
+				}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+
+	// Report progress and stop early on a confirmed failure when asked to
+	if canaryProgress > 0 || canaryFailFast {
+		tick := canaryProgress
+		if tick <= 0 || (canaryFailFast && tick > 10*time.Second) {
+			tick = 10 * time.Second
+		}
+		ticker := time.NewTicker(tick)
+		lastProgress := time.Now()
+	monitor:
+		for {
+			select {
+			case <-ctx.Done():
+				break monitor
+			case <-ticker.C:
+				report := evaluateCanary(primary, candidate)
+				if terminal && canaryProgress > 0 && time.Since(lastProgress) >= canaryProgress {
+					lastProgress = time.Now()
+					fmt.Printf("   [%s] %d/%d samples  p95 %.1fms vs %.1fms  errors %d vs %d  → %s\n",
+						time.Since(started).Round(time.Second), report.Primary.Requests, report.Candidate.Requests,
+						report.Primary.P95, report.Candidate.P95, report.Primary.Errors, report.Candidate.Errors, report.Verdict)
+				}
+				if canaryFailFast && report.Verdict == canaryFail {
+					cancel()
+				}
+			}
+		}
+		ticker.Stop()
+	}
+	wg.Wait()
+
+	report := evaluateCanary(primary, candidate)
+	report.Started = started
+	report.Elapsed = time.Since(started).Round(time.Second).String()
+
+	if canaryReport != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(canaryReport, data, 0644); err != nil {
+			color.Red("❌ Failed to write report: %v\n", err)
+		}
+	}
+
+	if terminal {
+		printCanaryReport(report)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	}
+
+	switch report.Verdict {
+	case canaryPass:
+		return 0
+	case canaryFail:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func printCanaryReport(report CanaryReport) {
+	fmt.Println()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Check", "Primary", "Candidate", "Change", "Confidence", "Status"})
+
+	for _, check := range report.Checks {
+		change := fmt.Sprintf("%+.1f%%", check.Delta)
+		value := func(v float64) string { return fmt.Sprintf("%.1f ms", v) }
+		if check.Unit == "%" {
+			change = fmt.Sprintf("%+.2f pp", check.Delta)
+			value = func(v float64) string { return fmt.Sprintf("%.2f%%", v) }
+		}
+
+		status := color.GreenString("✅ within threshold")
+		if check.Failed {
+			change = color.RedString(change)
+			status = color.RedString("❌ regression")
+		}
+
+		table.Append([]string{
+			check.Name,
+			value(check.Primary),
+			value(check.Candidate),
+			change,
+			fmt.Sprintf("%.1f%%", check.Confidence*100),
+			status,
+		})
+	}
+	table.Render()
+
+	fmt.Printf("\n   Samples: %d primary (%d errors), %d candidate (%d errors) over %s\n",
+		report.Primary.Requests, report.Primary.Errors, report.Candidate.Requests, report.Candidate.Errors, report.Elapsed)
+
+	switch report.Verdict {
+	case canaryPass:
+		color.Green("\n✅ PASS with %.1f%% confidence\n", report.Confidence*100)
+	case canaryFail:
+		color.Red("\n❌ FAIL with %.1f%% confidence: %s\n", report.Confidence*100, report.Reason)
+	default:
+		color.Yellow("\n⚠️  INCONCLUSIVE: %s\n", report.Reason)
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"math"
+	"sort"
+)
+
+// canaryCheck is one statistical comparison between primary and candidate
+type canaryCheck struct {
+	Name       string  `json:"name"`
+	Primary    float64 `json:"primary"`
+	Candidate  float64 `json:"candidate"`
+	Delta      float64 `json:"delta"` // Percent for latency, percentage points for error rate
+	Unit       string  `json:"unit"`
+	Threshold  float64 `json:"threshold"`
+	Failed     bool    `json:"failed"`
+	Confidence float64 `json:"confidence"` // That the candidate is worse than tolerated when failed, within tolerance otherwise
+}
+
+// canaryPercentile returns the p-th percentile of sorted values
+func canaryPercentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+// normalCDF is the standard normal cumulative distribution function
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// mannWhitneyZ returns the normal-approximation z score of the Mann-Whitney U
+// test; a positive z means values in b tend to be larger than values in a
+func mannWhitneyZ(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 0
+	}
+
+	type ranked struct {
+		value     float64
+		candidate bool
+	}
+	all := make([]ranked, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, ranked{v, false})
+	}
+	for _, v := range b {
+		all = append(all, ranked{v, true})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Average ranks across ties and accumulate the tie correction
+	var rankSumB, tieTerm float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].candidate {
+				rankSumB += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := rankSumB - n2*(n2+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 0
+	}
+	return (u - mean) / math.Sqrt(variance)
+}
+
+// latencyCheck tests whether candidate latencies exceed the primary's by more
+// than thresholdPct. Candidate samples are scaled down by the tolerance before
+// a Mann-Whitney test, so the check fails only on a regression past it
+func latencyCheck(name string, primary, candidate []float64, stat func([]float64) float64, thresholdPct, confidence float64) canaryCheck {
+	check := canaryCheck{
+		Name:      name,
+		Primary:   stat(primary),
+		Candidate: stat(candidate),
+		Unit:      "ms",
+		Threshold: thresholdPct,
+	}
+	if check.Primary > 0 {
+		check.Delta = (check.Candidate - check.Primary) / check.Primary * 100
+	}
+
+	scale := 1 + thresholdPct/100
+	scaled := make([]float64, len(candidate))
+	for i, v := range candidate {
+		scaled[i] = v / scale
+	}
+	worse := normalCDF(mannWhitneyZ(primary, scaled))
+
+	check.Failed = worse >= confidence && check.Delta > thresholdPct
+	if check.Failed {
+		check.Confidence = worse
+	} else {
+		check.Confidence = 1 - worse
+	}
+	return check
+}
+
+// errorRateCheck tests whether the candidate's error rate exceeds the
+// primary's by more than thresholdPP percentage points
+func errorRateCheck(primaryErrors, primaryTotal, candidateErrors, candidateTotal int, thresholdPP, confidence float64) canaryCheck {
+	check := canaryCheck{Name: "Error Rate", Unit: "%", Threshold: thresholdPP}
+	if primaryTotal == 0 || candidateTotal == 0 {
+		return check
+	}
+
+	p1 := float64(primaryErrors) / float64(primaryTotal)
+	p2 := float64(candidateErrors) / float64(candidateTotal)
+	check.Primary, check.Candidate = p1*100, p2*100
+	check.Delta = check.Candidate - check.Primary
+
+	excess := p2 - p1 - thresholdPP/100
+	se := math.Sqrt(p1*(1-p1)/float64(primaryTotal) + p2*(1-p2)/float64(candidateTotal))
+
+	var worse float64
+	switch {
+	case se > 0:
+		worse = normalCDF(excess / se)
+	case excess > 0:
+		worse = 1
+	}
+
+	check.Failed = worse >= confidence
+	if check.Failed {
+		check.Confidence = worse
+	} else {
+		check.Confidence = 1 - worse
+	}
+	return check
+}
@@ -20,6 +20,28 @@ var (
 	daemonPort       int
 	daemonLogLevel   string
 	daemonBackground bool
+	daemonProfiles   string
+	daemonMirrorURL  string
+	daemonMirrorPct  float64
+	daemonMirrorDiff bool
+
+	daemonMaxConcurrent int
+
+	daemonWarmupURLs      []string
+	daemonRequireWarmup   bool
+	daemonReadinessTarget string
+
+	daemonPeers         []string
+	daemonPeerName      string
+	daemonPeerBind      string
+	daemonPeerAdvertise string
+
+	daemonDrainTimeout time.Duration
+	daemonStopTimeout  time.Duration
+
+	daemonPIDFile       string
+	daemonListenAddress string
+	daemonReusePort     bool
 )
 
 // daemonCmd represents the daemon command
@@ -41,7 +63,10 @@ The daemon runs as a background service and can be controlled via:
   apilo daemon stop    - Stop the daemon
   apilo daemon status  - Check daemon status
   apilo daemon restart - Restart the daemon
-  apilo daemon logs    - View daemon logs`,
+  apilo daemon logs    - View daemon logs
+  apilo daemon circuits - List circuit breaker states
+  apilo daemon circuit  - Force a breaker open/closed, release or reset it
+  apilo daemon simulate - Project hit ratios for other cache configurations`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
@@ -59,7 +84,11 @@ var daemonStartCmd = &cobra.Command{
 var daemonStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the apilo daemon",
-	Long:  "Stop the running apilo daemon gracefully",
+	Long: `Stop the running apilo daemon gracefully.
+
+The daemon stops accepting connections, drains in-flight requests for up to
+its --drain-timeout, flushes the journal and breaker state, then exits. If it
+is still running after --timeout it is killed.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		stopDaemon()
 	},
@@ -101,9 +130,28 @@ func init() {
 	daemonCmd.AddCommand(daemonLogsCmd)
 
 	// Flags
+	daemonCmd.PersistentFlags().StringVar(&daemonPIDFile, "pid-file", daemon.DefaultDaemonConfig().PIDFile, "PID file of the daemon; give each daemon sharing a port with --reuse-port its own")
+
 	daemonStartCmd.Flags().IntVarP(&daemonPort, "port", "p", 9876, "IPC server port")
+	daemonStartCmd.Flags().StringVar(&daemonListenAddress, "listen-address", daemon.DefaultDaemonConfig().ListenAddress, "Interface the IPC and profile servers bind, e.g. 0.0.0.0 or 10.0.0.5 (empty binds all); systemd-activated sockets named ipc or after a profile are used instead when passed")
+	daemonStartCmd.Flags().BoolVar(&daemonReusePort, "reuse-port", false, "Bind with SO_REUSEPORT so several daemons can share the IPC and profile ports")
 	daemonStartCmd.Flags().StringVar(&daemonLogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	daemonStartCmd.Flags().BoolVarP(&daemonBackground, "background", "d", true, "Run in background")
+	daemonStartCmd.Flags().StringVar(&daemonProfiles, "profiles", "", "JSON file of upstream profiles (default ~/.apilo/profiles.json)")
+	daemonStartCmd.Flags().StringVar(&daemonMirrorURL, "mirror", "", "Shadow upstream to mirror a share of live traffic to (e.g. https://candidate.example.com)")
+	daemonStartCmd.Flags().Float64Var(&daemonMirrorPct, "mirror-percent", daemon.DefaultMirrorConfig().Percentage, "Percentage of eligible requests mirrored to the shadow upstream")
+	daemonStartCmd.Flags().BoolVar(&daemonMirrorDiff, "mirror-diff", false, "Diff mirrored JSON response bodies against the primary's")
+	daemonStartCmd.Flags().IntVar(&daemonMaxConcurrent, "max-concurrent", 0, "Requests optimized at once before the rest queue by priority and are shed with 429 (0 = no load shedding)")
+	daemonStartCmd.Flags().StringArrayVar(&daemonWarmupURLs, "warmup-url", nil, "URL fetched into the cache at startup (repeatable)")
+	daemonStartCmd.Flags().BoolVar(&daemonRequireWarmup, "readiness-require-warmup", false, "Report not ready on /health/ready until the warmup URLs are cached")
+	daemonStartCmd.Flags().StringArrayVar(&daemonPeers, "peer", nil, "Gossip address (host:port) of another replica to share cache invalidations and hot keys with (repeatable; secret in APILO_PEER_TOKEN)")
+	daemonStartCmd.Flags().StringVar(&daemonPeerName, "peer-name", "", "This replica's name in gossip, unique per replica (default: hostname)")
+	daemonStartCmd.Flags().StringVar(&daemonPeerBind, "peer-bind", daemon.DefaultPeersConfig().BindAddress, "host:port the gossip port listens on, TCP and UDP")
+	daemonStartCmd.Flags().StringVar(&daemonPeerAdvertise, "peer-advertise", "", "host:port announced to the other replicas when it differs from --peer-bind, e.g. behind NAT")
+	daemonStartCmd.Flags().StringVar(&daemonReadinessTarget, "readiness-upstream", "", "Upstream probed for /health/ready (default: the profiles' base URLs)")
+	daemonStartCmd.Flags().DurationVar(&daemonDrainTimeout, "drain-timeout", daemon.DefaultDaemonConfig().DrainTimeout, "How long in-flight requests may finish on shutdown before their connections are closed")
+
+	daemonStopCmd.Flags().DurationVar(&daemonStopTimeout, "timeout", daemon.DefaultDaemonConfig().ShutdownTimeout+5*time.Second, "How long to wait for the daemon to exit before killing it")
 }
 
 func startDaemon() {
@@ -113,7 +161,45 @@ func startDaemon() {
 
 	config := daemon.DefaultDaemonConfig()
 	config.Port = daemonPort
+	config.PIDFile = daemonPIDFile
+	config.ListenAddress = daemonListenAddress
+	config.ReusePort = daemonReusePort
 	config.LogLevel = daemonLogLevel
+	if daemonProfiles != "" {
+		config.ProfilesFile = daemonProfiles
+	}
+	if daemonMirrorURL != "" {
+		config.Mirror.Enabled = true
+		config.Mirror.ShadowURL = daemonMirrorURL
+		config.Mirror.Percentage = daemonMirrorPct
+		config.Mirror.Diff.Enabled = daemonMirrorDiff
+	}
+	config.Readiness.WarmupURLs = daemonWarmupURLs
+	config.Readiness.RequireWarmup = daemonRequireWarmup
+	config.Readiness.UpstreamURL = daemonReadinessTarget
+	config.DrainTimeout = daemonDrainTimeout
+	if daemonMaxConcurrent > 0 {
+		config.LoadShedding.Enabled = true
+		config.LoadShedding.MaxConcurrent = daemonMaxConcurrent
+	}
+	if config.ShutdownTimeout < config.DrainTimeout {
+		config.ShutdownTimeout = config.DrainTimeout + 15*time.Second
+	}
+	if len(daemonPeers) > 0 {
+		config.Peers.Enabled = true
+		config.Peers.Peers = daemonPeers
+		config.Peers.Name = daemonPeerName
+		config.Peers.BindAddress = daemonPeerBind
+		config.Peers.AdvertiseAddress = daemonPeerAdvertise
+	}
+	if daemonRequireWarmup && len(daemonWarmupURLs) == 0 {
+		color.Red("❌ --readiness-require-warmup needs at least one --warmup-url\n")
+		return
+	}
+	if len(daemonPeers) > 0 && os.Getenv("APILO_PEER_TOKEN") == "" {
+		color.Red("❌ --peer needs a shared secret in APILO_PEER_TOKEN\n")
+		return
+	}
 
 	pidMgr := daemon.NewPIDManager(config.PIDFile)
 
@@ -134,7 +220,46 @@ func startDaemon() {
 			return
 		}
 
-		cmd := exec.Command(executable, "daemon", "start", "--background=false", fmt.Sprintf("--port=%d", daemonPort))
+		args := []string{"daemon", "start", "--background=false", fmt.Sprintf("--port=%d", daemonPort)}
+		if daemonProfiles != "" {
+			args = append(args, "--profiles="+daemonProfiles)
+		}
+		if daemonMirrorURL != "" {
+			args = append(args, "--mirror="+daemonMirrorURL, fmt.Sprintf("--mirror-percent=%g", daemonMirrorPct))
+			if daemonMirrorDiff {
+				args = append(args, "--mirror-diff")
+			}
+		}
+		for _, url := range daemonWarmupURLs {
+			args = append(args, "--warmup-url="+url)
+		}
+		if daemonRequireWarmup {
+			args = append(args, "--readiness-require-warmup")
+		}
+		if daemonReadinessTarget != "" {
+			args = append(args, "--readiness-upstream="+daemonReadinessTarget)
+		}
+		for _, peer := range daemonPeers {
+			args = append(args, "--peer="+peer)
+		}
+		if daemonPeerName != "" {
+			args = append(args, "--peer-name="+daemonPeerName)
+		}
+		if len(daemonPeers) > 0 {
+			args = append(args, "--peer-bind="+daemonPeerBind)
+		}
+		if daemonPeerAdvertise != "" {
+			args = append(args, "--peer-advertise="+daemonPeerAdvertise)
+		}
+		if daemonMaxConcurrent > 0 {
+			args = append(args, fmt.Sprintf("--max-concurrent=%d", daemonMaxConcurrent))
+		}
+		args = append(args, "--drain-timeout="+daemonDrainTimeout.String())
+		args = append(args, "--pid-file="+daemonPIDFile, "--listen-address="+daemonListenAddress)
+		if daemonReusePort {
+			args = append(args, "--reuse-port")
+		}
+		cmd := exec.Command(executable, args...)
 		cmd.Stdout = nil
 		cmd.Stderr = nil
 
@@ -174,8 +299,7 @@ func stopDaemon() {
 	color.Cyan("║                Stopping Apilo Daemon                              ║")
 	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
 
-	config := daemon.DefaultDaemonConfig()
-	pidMgr := daemon.NewPIDManager(config.PIDFile)
+	pidMgr := daemon.NewPIDManager(daemonPIDFile)
 
 	running, pid, err := pidMgr.IsRunning()
 	if err != nil {
@@ -188,12 +312,17 @@ func stopDaemon() {
 		return
 	}
 
-	fmt.Printf("🛑 Stopping daemon (PID: %d)...\n\n", pid)
+	fmt.Printf("🛑 Stopping daemon (PID: %d), draining in-flight requests...\n\n", pid)
 
-	if err := pidMgr.Stop(); err != nil {
+	killed, err := pidMgr.StopWait(daemonStopTimeout)
+	if err != nil {
 		color.Red("❌ Failed to stop daemon: %v\n", err)
 		return
 	}
+	if killed {
+		color.Yellow("⚠️  Daemon did not exit within %v and was killed\n\n", daemonStopTimeout)
+		return
+	}
 
 	color.Green("✅ Daemon stopped successfully\n\n")
 }
@@ -204,7 +333,7 @@ func checkDaemonStatus() {
 	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
 
 	config := daemon.DefaultDaemonConfig()
-	pidMgr := daemon.NewPIDManager(config.PIDFile)
+	pidMgr := daemon.NewPIDManager(daemonPIDFile)
 
 	running, pid, err := pidMgr.IsRunning()
 
@@ -236,6 +365,12 @@ func checkDaemonStatus() {
 		fmt.Println(color.YellowString("📈 Performance Metrics:\n"))
 		fmt.Printf("   Total Requests:  %s\n", color.CyanString(fmt.Sprintf("%d", metrics.TotalRequests)))
 		fmt.Printf("   Cache Hit Ratio: %s\n", color.GreenString(fmt.Sprintf("%.2f%%", metrics.CacheHitRatio*100)))
+		if metrics.Revalidations > 0 {
+			fmt.Printf("   Revalidations:   %s\n", color.GreenString(fmt.Sprintf("%d (%.2f%% not modified)", metrics.Revalidations, metrics.RevalidationHitRatio*100)))
+		}
+		if metrics.Shed > 0 {
+			fmt.Printf("   Shed Requests:   %s\n", color.YellowString(fmt.Sprintf("%d (%.2f%% of traffic)", metrics.Shed, metrics.ShedRate*100)))
+		}
 		fmt.Printf("   Avg Latency:     %s\n", color.CyanString(fmt.Sprintf("%v", metrics.AvgLatency)))
 		fmt.Printf("   Memory Usage:    %s\n", color.CyanString(fmt.Sprintf("%.2f MB", metrics.MemoryUsageMB)))
 		fmt.Println()
@@ -259,11 +394,11 @@ func restartDaemon() {
 
 	// Stop daemon
 	config := daemon.DefaultDaemonConfig()
-	pidMgr := daemon.NewPIDManager(config.PIDFile)
+	pidMgr := daemon.NewPIDManager(daemonPIDFile)
 
 	if running, pid, _ := pidMgr.IsRunning(); running {
 		fmt.Printf("🛑 Stopping daemon (PID: %d)...\n", pid)
-		if err := pidMgr.Stop(); err != nil {
+		if _, err := pidMgr.StopWait(config.ShutdownTimeout + 5*time.Second); err != nil {
 			color.Red("❌ Failed to stop daemon: %v\n", err)
 			return
 		}
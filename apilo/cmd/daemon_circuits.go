@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"apilo/internal/daemon"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	circuitProfile    string
+	circuitAdminToken string
+)
+
+// circuitActions maps CLI verbs to admin API actions
+var circuitActions = map[string]string{
+	"open":    "force-open",
+	"close":   "force-close",
+	"release": "release",
+	"reset":   "reset",
+}
+
+var daemonCircuitsCmd = &cobra.Command{
+	Use:   "circuits",
+	Short: "List circuit breaker states",
+	Long:  "List the daemon's per-host circuit breakers with their state and counters",
+	Run: func(cmd *cobra.Command, args []string) {
+		listCircuits()
+	},
+}
+
+var daemonCircuitCmd = &cobra.Command{
+	Use:   "circuit <open|close|release|reset> <host>",
+	Short: "Manually control a host's circuit breaker",
+	Long: `Manually control the circuit breaker of an upstream host.
+
+  open    - Force the breaker open, rejecting all requests (e.g. during maintenance)
+  close   - Force the breaker closed, letting all requests through
+  release - Return a forced breaker to automatic operation
+  reset   - Clear the breaker's counters and close it unless forced
+
+Requires the daemon's admin token via --admin-token or APILO_ADMIN_TOKEN.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		controlCircuit(args[0], args[1])
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonCircuitsCmd)
+	daemonCmd.AddCommand(daemonCircuitCmd)
+
+	for _, cmd := range []*cobra.Command{daemonCircuitsCmd, daemonCircuitCmd} {
+		cmd.Flags().StringVar(&circuitProfile, "profile", "", "Upstream profile (default: the daemon's own breakers)")
+	}
+	daemonCircuitCmd.Flags().StringVar(&circuitAdminToken, "admin-token", "", "Admin API token (default $APILO_ADMIN_TOKEN)")
+}
+
+// circuitsResponse is the body of the daemon's /circuits endpoint
+type circuitsResponse struct {
+	Enabled  bool                  `json:"enabled"`
+	Summary  daemon.CircuitSummary `json:"summary"`
+	Circuits []daemon.CircuitInfo  `json:"circuits"`
+}
+
+func listCircuits() {
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                  Apilo Circuit Breakers                           ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	config := daemon.DefaultDaemonConfig()
+	endpoint := fmt.Sprintf("http://localhost:%d/circuits", config.Port)
+	if circuitProfile != "" {
+		endpoint = fmt.Sprintf("http://localhost:%d%s%s/circuits", config.Port, daemon.ProfilePathPrefix, circuitProfile)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		color.Red("❌ Daemon not reachable: %v\n", err)
+		fmt.Println(color.BlueString("💡 Start with: apilo daemon start\n"))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		color.Red("❌ %s\n", strings.TrimSpace(string(body)))
+		return
+	}
+
+	var circuits circuitsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&circuits); err != nil {
+		color.Red("❌ Failed to decode response: %v\n", err)
+		return
+	}
+
+	if !circuits.Enabled {
+		color.Yellow("⚠️  Circuit breakers are disabled\n")
+		return
+	}
+	if len(circuits.Circuits) == 0 {
+		fmt.Print("   No upstream hosts seen yet\n\n")
+		return
+	}
+
+	s := circuits.Summary
+	fmt.Printf("   %d breakers: %d closed, %d open, %d half-open, %d forced\n\n", s.Total, s.Closed, s.Open, s.HalfOpen, s.Forced)
+	fmt.Printf("   %-32s %-18s %9s %9s %9s\n", "HOST", "STATE", "REQUESTS", "FAILURES", "REJECTED")
+	for _, info := range circuits.Circuits {
+		fmt.Printf("   %-32s %-18s %9d %9d %9d\n", info.Key, formatCircuitState(info), info.TotalRequests, info.Failures, info.Rejected)
+	}
+	fmt.Println()
+}
+
+func controlCircuit(verb, host string) {
+	action, ok := circuitActions[verb]
+	if !ok {
+		color.Red("❌ Unknown action %q: use open, close, release or reset\n", verb)
+		os.Exit(1)
+	}
+
+	token := circuitAdminToken
+	if token == "" {
+		token = os.Getenv("APILO_ADMIN_TOKEN")
+	}
+	if token == "" {
+		color.Red("❌ Admin token required: pass --admin-token or set APILO_ADMIN_TOKEN\n")
+		os.Exit(1)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"profile": circuitProfile,
+		"host":    host,
+		"action":  action,
+	})
+
+	config := daemon.DefaultDaemonConfig()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:%d/admin/circuits", config.Port), bytes.NewReader(body))
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		color.Red("❌ Daemon not reachable: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		color.Red("❌ %s\n", strings.TrimSpace(string(message)))
+		os.Exit(1)
+	}
+
+	var info daemon.CircuitInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		color.Red("❌ Failed to decode response: %v\n", err)
+		os.Exit(1)
+	}
+
+	color.Green("✅ %s: %s\n", host, formatCircuitState(info))
+}
+
+// formatCircuitState renders a breaker state, marking operator-forced ones
+func formatCircuitState(info daemon.CircuitInfo) string {
+	state := strings.ToUpper(strings.ReplaceAll(string(info.State), "_", "-"))
+	if info.Forced {
+		state += " (forced)"
+	}
+	return state
+}
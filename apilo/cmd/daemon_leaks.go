@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"apilo/internal/daemon"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var leakStacks bool
+
+var daemonLeaksCmd = &cobra.Command{
+	Use:   "leaks",
+	Short: "Show goroutine and file descriptor leak detection",
+	Long: `Show the daemon's goroutine and file descriptor counts over time, the
+goroutine creation sites that are growing, and any leak alerts raised.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showLeaks()
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonLeaksCmd)
+	daemonLeaksCmd.Flags().BoolVar(&leakStacks, "stacks", false, "Print an example stack for each growing creation site")
+}
+
+func showLeaks() {
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                  Apilo Leak Detection                             ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	config := daemon.DefaultDaemonConfig()
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/leaks", config.Port))
+	if err != nil {
+		color.Red("❌ Daemon not reachable: %v\n", err)
+		fmt.Println(color.BlueString("💡 Start with: apilo daemon start\n"))
+		return
+	}
+	defer resp.Body.Close()
+
+	var report daemon.LeakReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		color.Red("❌ Failed to decode response: %v\n", err)
+		return
+	}
+	if !report.Enabled {
+		color.Yellow("⚠️  Leak detection is disabled\n")
+		return
+	}
+
+	fds := "unavailable"
+	if report.FDs >= 0 {
+		fds = fmt.Sprintf("%d (%s)", report.FDs, formatFDKinds(report.FDKinds))
+	}
+	fmt.Printf("   Goroutines: %s\n", color.CyanString("%d", report.Goroutines))
+	fmt.Printf("   File descriptors: %s\n", color.CyanString(fds))
+	if len(report.History) > 1 {
+		first, last := report.History[0], report.History[len(report.History)-1]
+		fmt.Printf("   Over %v: goroutines %+d, descriptors %+d\n",
+			last.At.Sub(first.At).Round(time.Second), last.Goroutines-first.Goroutines, last.FDs-first.FDs)
+	}
+
+	if len(report.Growing) > 0 {
+		fmt.Println(color.YellowString("\n📈 Growing creation sites:"))
+		for _, site := range report.Growing {
+			fmt.Printf("   %+6d (%d total)  %s\n", site.Growth, site.Count, site.Site)
+			if leakStacks && site.Stack != "" {
+				fmt.Printf("%s\n\n", indent(site.Stack, "          "))
+			}
+		}
+	}
+
+	if len(report.Alerts) == 0 {
+		fmt.Println(color.GreenString("\n✅ No leaks detected\n"))
+		return
+	}
+	fmt.Println(color.RedString("\n🚨 Leak alerts:"))
+	for _, alert := range report.Alerts {
+		fmt.Printf("   %s  %s %d -> %d since %s\n", alert.DetectedAt.Format(time.RFC3339), alert.Kind,
+			alert.From, alert.To, alert.Since.Format(time.Kitchen))
+		for _, site := range alert.Sites {
+			fmt.Printf("      %+d  %s\n", site.Growth, site.Site)
+		}
+		if len(alert.FDKinds) > 0 {
+			fmt.Printf("      %s\n", formatFDKinds(alert.FDKinds))
+		}
+	}
+	fmt.Println()
+}
+
+// formatFDKinds lists descriptor counts by kind, e.g. "12 socket, 3 file"
+func formatFDKinds(kinds map[string]int) string {
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Slice(names, func(i, j int) bool { return kinds[names[i]] > kinds[names[j]] })
+
+	parts := make([]string, 0, len(names))
+	for _, kind := range names {
+		parts = append(parts, fmt.Sprintf("%d %s", kinds[kind], kind))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// indent prefixes every line of s
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
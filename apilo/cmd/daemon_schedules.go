@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"apilo/internal/daemon"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var daemonSchedulesCmd = &cobra.Command{
+	Use:   "schedules",
+	Short: "Show scheduled benchmark suites",
+	Long: `Show the benchmark suites the daemon runs on cron schedules: when each
+runs next, how many runs failed or were skipped to prevent overlap, and the
+outcome of the last run.
+
+Schedules are configured in the schedules section of the daemon config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showSchedules()
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonSchedulesCmd)
+}
+
+func showSchedules() {
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                  Apilo Scheduled Benchmarks                       ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	config := daemon.DefaultDaemonConfig()
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/schedules", config.Port))
+	if err != nil {
+		color.Red("❌ Daemon not reachable: %v\n", err)
+		fmt.Println(color.BlueString("💡 Start with: apilo daemon start\n"))
+		return
+	}
+	defer resp.Body.Close()
+
+	var schedules []daemon.ScheduleStatus
+	if err := json.NewDecoder(resp.Body).Decode(&schedules); err != nil {
+		color.Red("❌ Failed to decode response: %v\n", err)
+		return
+	}
+	if len(schedules) == 0 {
+		color.Yellow("⚠️  No schedules configured\n")
+		return
+	}
+
+	for _, schedule := range schedules {
+		state := color.GreenString("idle")
+		if schedule.Running > 0 {
+			state = color.CyanString("running")
+		}
+		fmt.Printf("   %s  %s  [%s]\n", color.New(color.Bold).Sprint(schedule.Name), schedule.Cron, state)
+		if !schedule.Next.IsZero() {
+			fmt.Printf("      Next run: %s (in %v)\n", schedule.Next.Format(time.RFC3339), time.Until(schedule.Next).Round(time.Second))
+		}
+		fmt.Printf("      Runs: %d | Failed: %d | Skipped: %d\n", schedule.Runs, schedule.Failures, schedule.Skipped)
+		if last := schedule.Last; last != nil {
+			outcome := color.GreenString("succeeded")
+			if last.Error != "" {
+				outcome = color.RedString("failed: %s", last.Error)
+			}
+			fmt.Printf("      Last run: %s, %v, %s\n", last.StartedAt.Format(time.RFC3339), last.Duration.Round(time.Second), outcome)
+			fmt.Printf("      Log: %s\n", last.LogFile)
+		}
+		fmt.Printf("      Results: %s\n\n", schedule.OutputDir)
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"apilo/internal/daemon"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simulateJournal  string
+	simulateSince    time.Duration
+	simulateProfile  string
+	simulateTTLs     []time.Duration
+	simulateCapacity []float64
+	simulatePolicies []string
+	simulateTop      int
+	simulateJSON     bool
+)
+
+var daemonSimulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Project cache hit ratios for other TTLs, capacities and policies",
+	Long: `Replay the request journal against hypothetical cache configurations and
+report the hit ratio, upstream time and tokens each would have saved.
+
+Every combination of --ttl, --capacity and --policy is simulated; the daemon's
+current configuration is marked. Requires the journal to be enabled, and works
+offline without a running daemon.
+
+Examples:
+  apilo daemon simulate
+  apilo daemon simulate --since 168h --ttl 5m,30m,2h --capacity 100,1000
+  apilo daemon simulate --policy fifo,gdsf --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		simulateCache()
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonSimulateCmd)
+
+	defaults := daemon.DefaultDaemonConfig()
+	daemonSimulateCmd.Flags().StringVar(&simulateJournal, "journal", defaults.Journal.Path, "Request journal to replay")
+	daemonSimulateCmd.Flags().DurationVar(&simulateSince, "since", 24*time.Hour, "Replay requests journaled within this window")
+	daemonSimulateCmd.Flags().StringVar(&simulateProfile, "profile", "", "Upstream profile (default: the daemon's own traffic)")
+	daemonSimulateCmd.Flags().DurationSliceVar(&simulateTTLs, "ttl", []time.Duration{time.Minute, defaults.CacheDefaultTTL, time.Hour, 6 * time.Hour}, "TTLs to simulate")
+	daemonSimulateCmd.Flags().Float64SliceVar(&simulateCapacity, "capacity", []float64{50, float64(defaults.CacheMaxMemoryMB), 0}, "Capacities in MB to simulate; 0 is unbounded")
+	daemonSimulateCmd.Flags().StringSliceVar(&simulatePolicies, "policy", []string{daemon.PolicyFIFO, daemon.PolicyLRU, daemon.PolicyLFU, daemon.PolicyGDSF}, "Eviction policies to simulate (fifo, lru, lfu, gdsf)")
+	daemonSimulateCmd.Flags().IntVar(&simulateTop, "top", 15, "Scenarios to list, best hit ratio first; 0 lists all")
+	daemonSimulateCmd.Flags().BoolVar(&simulateJSON, "json", false, "Print the simulation as JSON")
+}
+
+func simulateCache() {
+	var records []daemon.RequestRecord
+	_, err := daemon.ReadJournal(simulateJournal, time.Now().Add(-simulateSince), func(entry daemon.JournalEntry) {
+		if entry.Profile == simulateProfile {
+			records = append(records, entry.RequestRecord)
+		}
+	})
+	if err != nil {
+		color.Red("❌ Failed to read journal: %v\n", err)
+		os.Exit(1)
+	}
+	if len(records) == 0 {
+		color.Yellow("⚠️  No journaled requests in the last %v\n", simulateSince)
+		fmt.Println(color.BlueString("💡 Enable the journal in the daemon config to record traffic\n"))
+		return
+	}
+
+	var scenarios []daemon.CacheScenario
+	for _, policy := range simulatePolicies {
+		for _, capacity := range simulateCapacity {
+			for _, ttl := range simulateTTLs {
+				scenarios = append(scenarios, daemon.CacheScenario{TTL: ttl, CapacityMB: capacity, Policy: policy})
+			}
+		}
+	}
+	simulation, err := daemon.SimulateCache(records, scenarios)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	results := slices.Clone(simulation.Results)
+	slices.SortStableFunc(results, func(a, b daemon.SimulationResult) int {
+		if c := cmp.Compare(b.HitRatio, a.HitRatio); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.PeakBytes, b.PeakBytes)
+	})
+	if simulateTop > 0 && len(results) > simulateTop {
+		results = results[:simulateTop]
+	}
+
+	if simulateJSON {
+		simulation.Results = results
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(simulation)
+		return
+	}
+
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                  Apilo Cache Simulation                           ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	fmt.Printf("   %d requests over the last %v, observed hit ratio %.1f%%\n",
+		simulation.Requests, simulateSince, simulation.ObservedRatio*100)
+	if simulation.UntrackedBytes > 0 {
+		color.Yellow("   ⚠️  %d requests were journaled without response sizes; capacity limits undercount them\n", simulation.UntrackedBytes)
+	}
+	fmt.Println()
+
+	current := daemon.DefaultDaemonConfig()
+	fmt.Printf("   %-8s %-10s %-7s %9s %10s %12s %10s %10s\n", "TTL", "CAPACITY", "POLICY", "HIT RATIO", "EVICTIONS", "TIME SAVED", "TOKENS", "PEAK MB")
+	for _, result := range results {
+		scenario := result.Scenario
+		capacity := "unbounded"
+		if scenario.CapacityMB > 0 {
+			capacity = fmt.Sprintf("%gMB", scenario.CapacityMB)
+		}
+		line := fmt.Sprintf("   %-8v %-10s %-7s %8.1f%% %10d %12v %10d %10.1f",
+			scenario.TTL, capacity, scenario.Policy, result.HitRatio*100, result.Evictions,
+			result.LatencySaved.Round(time.Millisecond), result.TokensSaved, float64(result.PeakBytes)/1024/1024)
+		if scenario.TTL == current.CacheDefaultTTL && scenario.CapacityMB == float64(current.CacheMaxMemoryMB) && scenario.Policy == current.Eviction.Policy {
+			color.Green("%s  ← current", line)
+			continue
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+}
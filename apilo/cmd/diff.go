@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffLatencyThreshold    float64
+	diffThroughputThreshold float64
+	diffErrorThreshold      float64
+	diffCacheThreshold      float64
+	diffFailOnRegression    bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <resultA.json> <resultB.json>",
+	Short: "Compare two benchmark result files",
+	Long: `Compare two benchmark result files and highlight regressions.
+
+resultA is treated as the baseline and resultB as the candidate. Latency
+percentiles, throughput, error rate and cache metrics are compared, and any
+change past its threshold in the wrong direction is marked as a regression.
+
+Use --fail-on-regression to exit non-zero, e.g. as a code review gate:
+  apilo diff main.json branch.json --fail-on-regression`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDiff(args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().Float64Var(&diffLatencyThreshold, "latency-threshold", 10, "percent latency increase marked as a regression")
+	diffCmd.Flags().Float64Var(&diffThroughputThreshold, "throughput-threshold", 5, "percent throughput decrease marked as a regression")
+	diffCmd.Flags().Float64Var(&diffErrorThreshold, "error-threshold", 1, "error rate increase in percentage points marked as a regression")
+	diffCmd.Flags().Float64Var(&diffCacheThreshold, "cache-threshold", 5, "cache hit ratio decrease in percentage points marked as a regression")
+	diffCmd.Flags().BoolVar(&diffFailOnRegression, "fail-on-regression", false, "exit with status 1 when any regression is found")
+}
+
+// diffLatency mirrors the latency stats written by the benchmark runner
+type diffLatency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// diffResult holds the fields of a benchmark result file that are compared
+type diffResult struct {
+	TargetURL         string      `json:"target_url"`
+	TotalRequests     int         `json:"total_requests"`
+	FailedReqs        int         `json:"failed_requests"`
+	RequestsPerSecond float64     `json:"requests_per_second"`
+	LatencyStats      diffLatency `json:"latency_stats"`
+	TTFBStats         diffLatency `json:"ttfb_stats"`
+	ServerStats       diffLatency `json:"server_processing_stats"`
+	DownloadStats     diffLatency `json:"content_transfer_stats"`
+
+	// Machine fingerprint; cross-machine comparisons are flagged
+	Environment map[string]interface{} `json:"environment"`
+
+	OptimizationStats *struct {
+		HTTP2Stats struct {
+			ConnectionReuse float64 `json:"connection_reuse_ratio"`
+		} `json:"http2_stats"`
+		CacheStats struct {
+			HitRatio    float64 `json:"hit_ratio"`
+			MemoryUsage int64   `json:"memory_usage_bytes"`
+		} `json:"cache_stats"`
+	} `json:"optimization_stats"`
+}
+
+// errorRate returns failed requests as a fraction of all requests
+func (r *diffResult) errorRate() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return float64(r.FailedReqs) / float64(r.TotalRequests)
+}
+
+// DiffRow is one compared metric
+type DiffRow struct {
+	Metric     string  `json:"metric"`
+	Baseline   float64 `json:"baseline"`
+	Candidate  float64 `json:"candidate"`
+	Delta      float64 `json:"delta"`
+	Unit       string  `json:"unit"`
+	Regression bool    `json:"regression"`
+	Improved   bool    `json:"improved"`
+}
+
+func loadDiffResult(path string) (*diffResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var result diffResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// relativeRow compares a metric by percent change; lowerIsBetter flips the direction
+func relativeRow(metric, unit string, baseline, candidate, threshold float64, lowerIsBetter bool) DiffRow {
+	row := DiffRow{Metric: metric, Baseline: baseline, Candidate: candidate, Unit: unit}
+	if baseline == 0 {
+		return row
+	}
+
+	row.Delta = (candidate - baseline) / baseline * 100
+	worse := row.Delta
+	if !lowerIsBetter {
+		worse = -row.Delta
+	}
+	row.Regression = worse > threshold
+	row.Improved = worse < -threshold
+	return row
+}
+
+// pointsRow compares a ratio metric by percentage-point change
+func pointsRow(metric string, baseline, candidate, threshold float64, lowerIsBetter bool) DiffRow {
+	row := DiffRow{Metric: metric, Baseline: baseline * 100, Candidate: candidate * 100, Unit: "%"}
+	row.Delta = row.Candidate - row.Baseline
+
+	worse := row.Delta
+	if !lowerIsBetter {
+		worse = -row.Delta
+	}
+	row.Regression = worse > threshold
+	row.Improved = worse < -threshold
+	return row
+}
+
+func buildDiffRows(a, b *diffResult) []DiffRow {
+	rows := []DiffRow{
+		relativeRow("Latency P50", "ms", a.LatencyStats.P50, b.LatencyStats.P50, diffLatencyThreshold, true),
+		relativeRow("Latency P95", "ms", a.LatencyStats.P95, b.LatencyStats.P95, diffLatencyThreshold, true),
+		relativeRow("Latency P99", "ms", a.LatencyStats.P99, b.LatencyStats.P99, diffLatencyThreshold, true),
+		relativeRow("Latency Mean", "ms", a.LatencyStats.Mean, b.LatencyStats.Mean, diffLatencyThreshold, true),
+		relativeRow("Latency Max", "ms", a.LatencyStats.Max, b.LatencyStats.Max, diffLatencyThreshold, true),
+		relativeRow("TTFB P95", "ms", a.TTFBStats.P95, b.TTFBStats.P95, diffLatencyThreshold, true),
+		relativeRow("Throughput", "req/s", a.RequestsPerSecond, b.RequestsPerSecond, diffThroughputThreshold, false),
+		pointsRow("Error Rate", a.errorRate(), b.errorRate(), diffErrorThreshold, true),
+	}
+
+	// Phase split, in results that record it, separates server from transfer time
+	if a.ServerStats.P95 > 0 || b.ServerStats.P95 > 0 {
+		rows = append(rows,
+			relativeRow("Server P95", "ms", a.ServerStats.P95, b.ServerStats.P95, diffLatencyThreshold, true),
+			relativeRow("Download P95", "ms", a.DownloadStats.P95, b.DownloadStats.P95, diffLatencyThreshold, true),
+		)
+	}
+
+	// Cache metrics only exist for integrated (optimized) runs
+	if a.OptimizationStats != nil && b.OptimizationStats != nil {
+		rows = append(rows,
+			pointsRow("Cache Hit Ratio", a.OptimizationStats.CacheStats.HitRatio, b.OptimizationStats.CacheStats.HitRatio, diffCacheThreshold, false),
+			relativeRow("Cache Memory", "MB",
+				float64(a.OptimizationStats.CacheStats.MemoryUsage)/1024/1024,
+				float64(b.OptimizationStats.CacheStats.MemoryUsage)/1024/1024,
+				math.Inf(1), true), // Informational only
+			pointsRow("Connection Reuse", a.OptimizationStats.HTTP2Stats.ConnectionReuse, b.OptimizationStats.HTTP2Stats.ConnectionReuse, diffCacheThreshold, false),
+		)
+	}
+
+	return rows
+}
+
+// environmentChanges lists the environment fields that differ between a and
+// b, e.g. "cpu_count: 8 -> 16". Results without a fingerprint yield none
+func environmentChanges(a, b *diffResult) []string {
+	if a.Environment == nil || b.Environment == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(a.Environment)+len(b.Environment))
+	for key := range a.Environment {
+		keys = append(keys, key)
+	}
+	for key := range b.Environment {
+		if _, ok := a.Environment[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, key := range keys {
+		before, after := fmt.Sprint(a.Environment[key]), fmt.Sprint(b.Environment[key])
+		if before != after {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, before, after))
+		}
+	}
+	return changes
+}
+
+func runDiff(pathA, pathB string) {
+	a, err := loadDiffResult(pathA)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+	b, err := loadDiffResult(pathB)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	rows := buildDiffRows(a, b)
+	envChanges := environmentChanges(a, b)
+	regressions := 0
+	for _, row := range rows {
+		if row.Regression {
+			regressions++
+		}
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]interface{}{
+			"baseline":            pathA,
+			"candidate":           pathB,
+			"rows":                rows,
+			"regressions":         regressions,
+			"environment_changes": envChanges,
+		})
+	} else {
+		printDiffTable(pathA, pathB, rows, regressions, envChanges)
+	}
+
+	if diffFailOnRegression && regressions > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDiffTable(pathA, pathB string, rows []DiffRow, regressions int, envChanges []string) {
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                    Benchmark Result Comparison                    ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	fmt.Printf("   Baseline:  %s\n", color.CyanString(pathA))
+	fmt.Printf("   Candidate: %s\n\n", color.CyanString(pathB))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Metric", "Baseline", "Candidate", "Change", "Status"})
+
+	for _, row := range rows {
+		change := fmt.Sprintf("%+.1f%%", row.Delta)
+		if row.Unit == "%" {
+			change = fmt.Sprintf("%+.2f pp", row.Delta)
+		}
+
+		status := "unchanged"
+		switch {
+		case row.Regression:
+			change = color.RedString(change)
+			status = color.RedString("❌ regression")
+		case row.Improved:
+			change = color.GreenString(change)
+			status = color.GreenString("✅ improved")
+		}
+
+		table.Append([]string{
+			row.Metric,
+			formatDiffValue(row.Baseline, row.Unit),
+			formatDiffValue(row.Candidate, row.Unit),
+			change,
+			status,
+		})
+	}
+
+	table.Render()
+
+	if len(envChanges) > 0 {
+		color.Yellow("\n⚠️  Results come from different environments; the comparison may not be valid:")
+		for _, change := range envChanges {
+			fmt.Printf("   %s\n", change)
+		}
+	}
+
+	if regressions > 0 {
+		color.Red("\n❌ %d regression(s) past threshold\n", regressions)
+	} else {
+		color.Green("\n✅ No regressions past threshold\n")
+	}
+}
+
+func formatDiffValue(value float64, unit string) string {
+	if math.Abs(value) >= 100 {
+		return fmt.Sprintf("%.0f %s", value, unit)
+	}
+	return fmt.Sprintf("%.2f %s", value, unit)
+}
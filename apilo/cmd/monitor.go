@@ -11,6 +11,8 @@ import (
 var (
 	monitorPort     int
 	monitorInterval int
+	monitorAssets   string
+	monitorTheme    string
 )
 
 var monitorCmd = &cobra.Command{
@@ -28,6 +30,8 @@ func init() {
 
 	monitorCmd.Flags().IntVarP(&monitorPort, "port", "p", 8080, "dashboard port")
 	monitorCmd.Flags().IntVarP(&monitorInterval, "interval", "i", 5, "metrics collection interval (seconds)")
+	monitorCmd.Flags().StringVar(&monitorAssets, "dashboard-assets", "", "directory overriding the dashboard's index.html, dashboard.css or dashboard.js")
+	monitorCmd.Flags().StringVar(&monitorTheme, "theme", "auto", "dashboard theme: auto, light or dark")
 }
 
 func startMonitoring(url string) {
@@ -66,6 +70,10 @@ func startMonitoring(url string) {
 		"--dashboard",
 		"--port", fmt.Sprintf("%d", monitorPort),
 		"--interval", fmt.Sprintf("%ds", monitorInterval),
+		"--dashboard-theme", monitorTheme,
+	}
+	if monitorAssets != "" {
+		args = append(args, "--dashboard-assets", monitorAssets)
 	}
 
 	cmd := exec.Command(optimizerPath, args...)
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runsDB      string
+	runsResults []string
+
+	runsSuite  string
+	runsTarget string
+	runsCommit string
+	runsTag    string
+	runsSince  string
+	runsUntil  string
+	runsLimit  int
+
+	runsUntag bool
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Find past benchmark runs",
+	Long: `Browse a local SQLite catalog of benchmark runs.
+
+Every command first indexes new or changed suite_results.json files under
+the --results directories, recording each run's suite, git commit, target,
+start time and key percentiles. Runs can then be searched and tagged:
+
+  apilo runs list staging --since 7d
+  apilo runs tag 12 release-candidate
+  apilo runs show 12`,
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list [query]",
+	Short: "List runs, newest first",
+	Long: `List cataloged runs, newest first.
+
+The optional query matches part of the suite, run name, target or a tag, or
+the start of a git commit. --since and --until take a date (2025-10-14), an
+RFC 3339 time or an age such as 36h or 7d.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := CatalogFilter{
+			Suite:  runsSuite,
+			Target: runsTarget,
+			Commit: runsCommit,
+			Tag:    runsTag,
+			Limit:  runsLimit,
+		}
+		if len(args) > 0 {
+			filter.Query = args[0]
+		}
+		listRuns(filter)
+	},
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a run's details",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		showRun(args[0])
+	},
+}
+
+var runsTagCmd = &cobra.Command{
+	Use:   "tag <id> <tag>...",
+	Short: "Tag a run, or remove tags with --remove",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		tagRun(args[0], args[1:])
+	},
+}
+
+var runsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Remove runs from the catalog",
+	Long: `Remove runs from the catalog. Their result files are left on disk,
+and deleted runs stay hidden when those files are indexed again.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		deleteRuns(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsShowCmd)
+	runsCmd.AddCommand(runsTagCmd)
+	runsCmd.AddCommand(runsDeleteCmd)
+
+	runsCmd.PersistentFlags().StringVar(&runsDB, "db", "~/.apilo/runs.db", "catalog database")
+	runsCmd.PersistentFlags().StringSliceVar(&runsResults, "results", []string{"./benchmarks/results"}, "result directories to index")
+
+	runsListCmd.Flags().StringVar(&runsSuite, "suite", "", "only runs of this suite")
+	runsListCmd.Flags().StringVar(&runsTarget, "target", "", "only runs whose target URL contains this")
+	runsListCmd.Flags().StringVar(&runsCommit, "commit", "", "only runs at a git commit starting with this")
+	runsListCmd.Flags().StringVar(&runsTag, "tag", "", "only runs with this tag")
+	runsListCmd.Flags().StringVar(&runsSince, "since", "", "only runs started at or after this time")
+	runsListCmd.Flags().StringVar(&runsUntil, "until", "", "only runs started before this time")
+	runsListCmd.Flags().IntVarP(&runsLimit, "limit", "n", 20, "most runs listed (0 = all)")
+
+	runsTagCmd.Flags().BoolVar(&runsUntag, "remove", false, "remove the tags instead of adding them")
+}
+
+// openRuns opens the catalog and indexes the result directories, exiting on
+// failure
+func openRuns() *RunCatalog {
+	path := runsDB
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+
+	catalog, err := OpenRunCatalog(path)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if indexed, err := catalog.Sync(runsResults); err != nil {
+		color.Yellow("⚠️  %v\n", err)
+	} else if indexed > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Indexed %d result file(s)\n", indexed)
+	}
+	return catalog
+}
+
+// parseRunID parses a run ID argument, exiting when invalid
+func parseRunID(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		color.Red("❌ Invalid run ID %q\n", arg)
+		os.Exit(1)
+	}
+	return id
+}
+
+func listRuns(filter CatalogFilter) {
+	var err error
+	now := time.Now()
+	if runsSince != "" {
+		if filter.Since, err = parseCatalogTime(runsSince, now); err != nil {
+			color.Red("❌ --since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if runsUntil != "" {
+		if filter.Until, err = parseCatalogTime(runsUntil, now); err != nil {
+			color.Red("❌ --until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	catalog := openRuns()
+	defer catalog.Close()
+
+	runs, err := catalog.List(filter)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(runs)
+		return
+	}
+
+	if len(runs) == 0 {
+		color.Yellow("No runs found")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Started", "Suite", "Run", "Target", "Commit", "P50", "P95", "P99", "Req/s", "Tags"})
+	for _, run := range runs {
+		table.Append([]string{
+			strconv.FormatInt(run.ID, 10),
+			run.StartedAt.Local().Format("2006-01-02 15:04"),
+			run.Suite,
+			run.Name,
+			run.Target,
+			shortCommit(run.GitCommit),
+			fmt.Sprintf("%.1f ms", run.P50),
+			fmt.Sprintf("%.1f ms", run.P95),
+			fmt.Sprintf("%.1f ms", run.P99),
+			fmt.Sprintf("%.1f", run.RPS),
+			strings.Join(run.Tags, ", "),
+		})
+	}
+	table.Render()
+}
+
+func showRun(arg string) {
+	id := parseRunID(arg)
+	catalog := openRuns()
+	defer catalog.Close()
+
+	run, err := catalog.Get(id)
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(run)
+		return
+	}
+
+	errorRate := 0.0
+	if run.Requests > 0 {
+		errorRate = float64(run.Failed) / float64(run.Requests) * 100
+	}
+
+	color.Cyan("\nRun %d: %s / %s\n", run.ID, run.Suite, run.Name)
+	fmt.Printf("   Started:     %s\n", run.StartedAt.Local().Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("   Target:      %s\n", run.Target)
+	fmt.Printf("   Commit:      %s\n", run.GitCommit)
+	fmt.Printf("   Iterations:  %d\n", run.Iterations)
+	fmt.Printf("   Requests:    %d (%d failed, %.2f%%)\n", run.Requests, run.Failed, errorRate)
+	fmt.Printf("   Throughput:  %.2f req/s\n", run.RPS)
+	fmt.Printf("   Latency:     P50 %.2f ms | P95 %.2f ms | P99 %.2f ms\n", run.P50, run.P95, run.P99)
+	fmt.Printf("   Tags:        %s\n", strings.Join(run.Tags, ", "))
+	fmt.Printf("   Results:     %s\n\n", run.Source)
+}
+
+func tagRun(arg string, tags []string) {
+	id := parseRunID(arg)
+	catalog := openRuns()
+	defer catalog.Close()
+
+	var err error
+	if runsUntag {
+		err = catalog.Untag(id, tags)
+	} else {
+		err = catalog.Tag(id, tags)
+	}
+	if err != nil {
+		color.Red("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	if runsUntag {
+		color.Green("✅ Removed %s from run %d\n", strings.Join(tags, ", "), id)
+	} else {
+		color.Green("✅ Tagged run %d with %s\n", id, strings.Join(tags, ", "))
+	}
+}
+
+func deleteRuns(args []string) {
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		ids[i] = parseRunID(arg)
+	}
+
+	catalog := openRuns()
+	defer catalog.Close()
+
+	failed := false
+	for _, id := range ids {
+		if err := catalog.Delete(id); err != nil {
+			color.Red("❌ %v\n", err)
+			failed = true
+			continue
+		}
+		color.Green("✅ Deleted run %d\n", id)
+	}
+	if failed {
+		catalog.Close()
+		os.Exit(1)
+	}
+}
+
+// shortCommit abbreviates a git commit for tables
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
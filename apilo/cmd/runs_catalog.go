@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// catalogSchema creates the catalog tables. Deleted runs are kept as
+// tombstones so re-indexing their results does not bring them back
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	source     TEXT NOT NULL,
+	run_name   TEXT NOT NULL,
+	suite      TEXT NOT NULL,
+	git_commit TEXT NOT NULL DEFAULT '',
+	target     TEXT NOT NULL DEFAULT '',
+	started_at TEXT NOT NULL,
+	iterations INTEGER NOT NULL DEFAULT 0,
+	requests   INTEGER NOT NULL DEFAULT 0,
+	failed     INTEGER NOT NULL DEFAULT 0,
+	rps        REAL NOT NULL DEFAULT 0,
+	p50_ms     REAL NOT NULL DEFAULT 0,
+	p95_ms     REAL NOT NULL DEFAULT 0,
+	p99_ms     REAL NOT NULL DEFAULT 0,
+	deleted    INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (source, run_name)
+);
+CREATE INDEX IF NOT EXISTS runs_started_at ON runs (started_at);
+CREATE TABLE IF NOT EXISTS run_tags (
+	run_id INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	tag    TEXT NOT NULL,
+	PRIMARY KEY (run_id, tag)
+);
+CREATE TABLE IF NOT EXISTS sources (
+	path     TEXT PRIMARY KEY,
+	mod_time INTEGER NOT NULL
+);`
+
+// catalogSuiteFile is the name of the suite results the runner writes to
+// each result directory
+const catalogSuiteFile = "suite_results.json"
+
+// CatalogRun is one benchmark run in the catalog. Latencies and throughput
+// are averaged across its iterations
+type CatalogRun struct {
+	ID         int64     `json:"id"`
+	Source     string    `json:"source"`
+	Name       string    `json:"name"`
+	Suite      string    `json:"suite"`
+	GitCommit  string    `json:"git_commit,omitempty"`
+	Target     string    `json:"target"`
+	StartedAt  time.Time `json:"started_at"`
+	Iterations int       `json:"iterations"`
+	Requests   int       `json:"requests"`
+	Failed     int       `json:"failed"`
+	RPS        float64   `json:"requests_per_second"`
+	P50        float64   `json:"p50_ms"`
+	P95        float64   `json:"p95_ms"`
+	P99        float64   `json:"p99_ms"`
+	Tags       []string  `json:"tags"`
+}
+
+// CatalogFilter narrows a catalog listing; zero fields match everything
+type CatalogFilter struct {
+	Query  string // Substring of the suite, run name, target, commit or a tag
+	Suite  string
+	Target string // Substring of the target URL
+	Commit string // Commit prefix
+	Tag    string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// catalogSuite mirrors the parts of suite_results.json that are cataloged
+type catalogSuite struct {
+	Name     string `json:"name"`
+	Metadata struct {
+		GitCommit string `json:"git_commit"`
+	} `json:"metadata"`
+	Runs []struct {
+		Name   string `json:"name"`
+		Config struct {
+			TargetURL string `json:"TargetURL"`
+		} `json:"config"`
+		Results []struct {
+			TargetURL         string      `json:"target_url"`
+			StartTime         time.Time   `json:"start_time"`
+			TotalRequests     int         `json:"total_requests"`
+			FailedReqs        int         `json:"failed_requests"`
+			RequestsPerSecond float64     `json:"requests_per_second"`
+			LatencyStats      diffLatency `json:"latency_stats"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// RunCatalog is a SQLite index of benchmark runs found in result directories
+type RunCatalog struct {
+	db *sql.DB
+}
+
+// OpenRunCatalog opens or creates the catalog at path
+func OpenRunCatalog(path string) (*RunCatalog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %w", err)
+	}
+	if _, err := db.Exec(catalogSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize catalog: %w", err)
+	}
+	return &RunCatalog{db: db}, nil
+}
+
+// Close closes the catalog
+func (c *RunCatalog) Close() error {
+	return c.db.Close()
+}
+
+// Sync indexes every suite_results.json under dirs that is new or changed
+// since it was last indexed, returning the number of files indexed. Missing
+// directories are skipped
+func (c *RunCatalog) Sync(dirs []string) (int, error) {
+	indexed := 0
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || d.Name() != catalogSuiteFile {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+
+			var modTime int64
+			err = c.db.QueryRow(`SELECT mod_time FROM sources WHERE path = ?`, path).Scan(&modTime)
+			if err == nil && modTime == info.ModTime().UnixNano() {
+				return nil
+			}
+			if err := c.index(path, info.ModTime()); err != nil {
+				return err
+			}
+			indexed++
+			return nil
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("failed to index %s: %w", dir, err)
+		}
+	}
+	return indexed, nil
+}
+
+// index adds or refreshes the runs of one suite results file, keeping the IDs,
+// tags and tombstones of runs already cataloged
+func (c *RunCatalog) index(path string, modTime time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var suite catalogSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		// Not every file of that name is ours; skip it until it changes
+		_, err = c.db.Exec(`INSERT INTO sources (path, mod_time) VALUES (?, ?)
+			ON CONFLICT (path) DO UPDATE SET mod_time = excluded.mod_time`, path, modTime.UnixNano())
+		return err
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, run := range suite.Runs {
+		if len(run.Results) == 0 {
+			continue
+		}
+		entry := CatalogRun{
+			Name:       run.Name,
+			Suite:      suite.Name,
+			GitCommit:  suite.Metadata.GitCommit,
+			Target:     run.Config.TargetURL,
+			Iterations: len(run.Results),
+		}
+		for _, result := range run.Results {
+			if !result.StartTime.IsZero() && (entry.StartedAt.IsZero() || result.StartTime.Before(entry.StartedAt)) {
+				entry.StartedAt = result.StartTime
+			}
+			if entry.Target == "" {
+				entry.Target = result.TargetURL
+			}
+			entry.Requests += result.TotalRequests
+			entry.Failed += result.FailedReqs
+			entry.RPS += result.RequestsPerSecond
+			entry.P50 += result.LatencyStats.P50
+			entry.P95 += result.LatencyStats.P95
+			entry.P99 += result.LatencyStats.P99
+		}
+		if entry.StartedAt.IsZero() {
+			entry.StartedAt = modTime
+		}
+		count := float64(len(run.Results))
+		entry.RPS /= count
+		entry.P50 /= count
+		entry.P95 /= count
+		entry.P99 /= count
+
+		_, err := tx.Exec(`INSERT INTO runs
+			(source, run_name, suite, git_commit, target, started_at, iterations, requests, failed, rps, p50_ms, p95_ms, p99_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (source, run_name) DO UPDATE SET
+				suite = excluded.suite, git_commit = excluded.git_commit, target = excluded.target,
+				started_at = excluded.started_at, iterations = excluded.iterations,
+				requests = excluded.requests, failed = excluded.failed, rps = excluded.rps,
+				p50_ms = excluded.p50_ms, p95_ms = excluded.p95_ms, p99_ms = excluded.p99_ms`,
+			path, entry.Name, entry.Suite, entry.GitCommit, entry.Target,
+			entry.StartedAt.UTC().Format(time.RFC3339Nano), entry.Iterations,
+			entry.Requests, entry.Failed, entry.RPS, entry.P50, entry.P95, entry.P99)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO sources (path, mod_time) VALUES (?, ?)
+		ON CONFLICT (path) DO UPDATE SET mod_time = excluded.mod_time`, path, modTime.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// catalogColumns are the runs columns scanned by scanRun
+const catalogColumns = `id, source, run_name, suite, git_commit, target, started_at,
+	iterations, requests, failed, rps, p50_ms, p95_ms, p99_ms,
+	COALESCE((SELECT group_concat(tag, ',') FROM (SELECT tag FROM run_tags WHERE run_id = runs.id ORDER BY tag)), '')`
+
+// scanRun reads a row selected with catalogColumns
+func scanRun(row interface{ Scan(...any) error }) (*CatalogRun, error) {
+	var run CatalogRun
+	var startedAt, tags string
+	err := row.Scan(&run.ID, &run.Source, &run.Name, &run.Suite, &run.GitCommit, &run.Target, &startedAt,
+		&run.Iterations, &run.Requests, &run.Failed, &run.RPS, &run.P50, &run.P95, &run.P99, &tags)
+	if err != nil {
+		return nil, err
+	}
+	run.StartedAt, _ = time.Parse(time.RFC3339Nano, startedAt)
+	run.Tags = []string{}
+	if tags != "" {
+		run.Tags = strings.Split(tags, ",")
+	}
+	return &run, nil
+}
+
+// List returns the runs matching filter, newest first
+func (c *RunCatalog) List(filter CatalogFilter) ([]CatalogRun, error) {
+	query := `SELECT ` + catalogColumns + ` FROM runs WHERE deleted = 0`
+	var args []any
+	if filter.Query != "" {
+		like := "%" + filter.Query + "%"
+		query += ` AND (suite LIKE ? OR run_name LIKE ? OR target LIKE ? OR git_commit LIKE ?
+			OR EXISTS (SELECT 1 FROM run_tags WHERE run_id = runs.id AND tag LIKE ?))`
+		args = append(args, like, like, like, filter.Query+"%", like)
+	}
+	if filter.Suite != "" {
+		query += ` AND suite = ?`
+		args = append(args, filter.Suite)
+	}
+	if filter.Target != "" {
+		query += ` AND target LIKE ?`
+		args = append(args, "%"+filter.Target+"%")
+	}
+	if filter.Commit != "" {
+		query += ` AND git_commit LIKE ?`
+		args = append(args, filter.Commit+"%")
+	}
+	if filter.Tag != "" {
+		query += ` AND EXISTS (SELECT 1 FROM run_tags WHERE run_id = runs.id AND tag = ?)`
+		args = append(args, filter.Tag)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND started_at >= ?`
+		args = append(args, filter.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND started_at < ?`
+		args = append(args, filter.Until.UTC().Format(time.RFC3339Nano))
+	}
+	query += ` ORDER BY started_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query catalog: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []CatalogRun{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read catalog: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// Get returns the run with id
+func (c *RunCatalog) Get(id int64) (*CatalogRun, error) {
+	run, err := scanRun(c.db.QueryRow(`SELECT `+catalogColumns+` FROM runs WHERE id = ? AND deleted = 0`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	return run, nil
+}
+
+// Tag adds tags to the run with id
+func (c *RunCatalog) Tag(id int64, tags []string) error {
+	if _, err := c.Get(id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := c.db.Exec(`INSERT OR IGNORE INTO run_tags (run_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("failed to tag run %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Untag removes tags from the run with id
+func (c *RunCatalog) Untag(id int64, tags []string) error {
+	if _, err := c.Get(id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := c.db.Exec(`DELETE FROM run_tags WHERE run_id = ? AND tag = ?`, id, tag); err != nil {
+			return fmt.Errorf("failed to untag run %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes the run with id from the catalog. Its result files are left
+// in place, and it stays hidden when they are indexed again
+func (c *RunCatalog) Delete(id int64) error {
+	result, err := c.db.Exec(`UPDATE runs SET deleted = 1 WHERE id = ? AND deleted = 0`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete run %d: %w", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("run %d not found", id)
+	}
+	_, err = c.db.Exec(`DELETE FROM run_tags WHERE run_id = ?`, id)
+	return err
+}
+
+// parseCatalogTime parses a --since/--until value: a date, an RFC 3339 time,
+// or an age such as 36h or 7d
+func parseCatalogTime(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && fmt.Sprint(n) == days {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected YYYY-MM-DD, RFC 3339 or an age like 7d", value)
+}
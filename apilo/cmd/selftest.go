@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfTestMaxOverhead    time.Duration
+	selfTestMinImprovement float64
+)
+
+var selfTestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Regression test the optimizer against an embedded mock server",
+	Long: `Run a standardized workload against a mock server embedded in the optimizer,
+once through a plain HTTP client and once through the optimized client, and
+check that:
+
+  • Neither client fails a request
+  • The optimized client adds at most --max-overhead to a cache miss at P50
+  • Its P50 latency improves on the plain client's by at least --min-improvement
+
+Exits non-zero when a band is missed, so it can gate the project's own CI.
+No external network is used.
+
+Examples:
+  apilo selftest
+  apilo selftest --max-overhead 1ms --min-improvement 50`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSelfTest()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(selfTestCmd)
+
+	selfTestCmd.Flags().DurationVar(&selfTestMaxOverhead, "max-overhead", 2*time.Millisecond, "most the optimized client may add to a cache miss at P50")
+	selfTestCmd.Flags().Float64Var(&selfTestMinImprovement, "min-improvement", -5, "least P50 latency improvement over the plain client, in percent; negative allows it to be slower")
+}
+
+func runSelfTest() {
+	color.Cyan("\n╔═══════════════════════════════════════════════════════════════════╗")
+	color.Cyan("║                       Optimizer Self-Test                         ║")
+	color.Cyan("╚═══════════════════════════════════════════════════════════════════╝\n")
+
+	// Prefer an optimizer on PATH over the development build
+	optimizerPath, err := exec.LookPath("api-optimizer")
+	if err != nil {
+		optimizerPath = "/Users/joshkornreich/Documents/Projects/api-latency-optimizer/bin/api-optimizer"
+	}
+
+	cmd := exec.Command(optimizerPath,
+		"--selftest",
+		"--selftest-max-overhead", selfTestMaxOverhead.String(),
+		"--selftest-min-improvement", strconv.FormatFloat(selfTestMinImprovement, 'f', -1, 64),
+		"--quiet",
+	)
+	cmd.Stdout = color.Output
+	cmd.Stderr = color.Error
+
+	fmt.Println(color.YellowString("⏳ Running the self-test...\n"))
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			color.Red("\n❌ Self-test failed\n")
+			os.Exit(exitErr.ExitCode())
+		}
+		color.Red("❌ Failed to run the optimizer: %v\n", err)
+		fmt.Println(color.BlueString("💡 Build it with:"))
+		fmt.Println("   " + color.CyanString("go build -o bin/api-optimizer ./src"))
+		os.Exit(1)
+	}
+
+	color.Green("\n✅ Self-test passed!\n")
+}
@@ -0,0 +1,321 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogCommon   = "common"   // Apache Common Log Format
+	AccessLogCombined = "combined" // Common plus referer and user agent
+	AccessLogJSON     = "json"     // One JSON object per line
+)
+
+// AccessLogConfig configures the access log of proxied requests: those served
+// through /optimize, on the main port or a profile's, and those the
+// intercepting proxy reports. Common and combined lines are what Apache and
+// nginx write, so existing log analysis tools read them, with the request
+// line naming the upstream URL and apilo's fields appended as key=value pairs:
+//
+//	127.0.0.1 - - [02/Jan/2026:15:04:05 +0000] "GET https://api.example.com/v1/users HTTP/1.1" 200 512 "-" "curl/8.4.0" rt=0.012 cache=HIT upstream=api.example.com
+//
+// The log is rotated at MaxSizeMB, keeping MaxFiles rotated files
+type AccessLogConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Path    string `yaml:"path" json:"path"`
+	Format  string `yaml:"format" json:"format"`
+
+	// Fraction of requests logged; failed requests are always logged
+	SampleRate float64 `yaml:"sample_rate" json:"sample_rate"`
+
+	MaxSizeMB int `yaml:"max_size_mb" json:"max_size_mb"`
+	MaxFiles  int `yaml:"max_files" json:"max_files"`
+}
+
+// DefaultAccessLogConfig returns a disabled combined log of every request in
+// 100MB files, keeping 10
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{
+		Path:       "~/.apilo/logs/access.log",
+		Format:     AccessLogCombined,
+		SampleRate: 1,
+		MaxSizeMB:  100,
+		MaxFiles:   10,
+	}
+}
+
+// AccessLogEntry is one proxied request
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"` // Upstream URL
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"` // Response body bytes
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+
+	Latency  time.Duration `json:"latency"`
+	Cache    string        `json:"cache"` // HIT, MISS, REVALIDATED, STALE, DEDUP, or empty when nothing was served
+	Upstream string        `json:"upstream"`
+	Profile  string        `json:"profile,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// accessLogCacheStatus names how resp was served for the access log
+func accessLogCacheStatus(resp *OptimizationResponse) string {
+	if resp == nil {
+		return ""
+	}
+	if resp.Deduplicated {
+		return "DEDUP"
+	}
+	if resp.Metadata.CacheStatus != "" {
+		return strings.ToUpper(resp.Metadata.CacheStatus)
+	}
+	if resp.CacheHit {
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// AccessLog writes access log entries to a rotating file
+type AccessLog struct {
+	config  AccessLogConfig
+	path    string
+	file    *os.File
+	size    int64
+	written int64
+	errors  int64
+	lastErr string
+	mu      sync.Mutex
+}
+
+// OpenAccessLog validates config and opens its file for appending, creating
+// its directory
+func OpenAccessLog(config AccessLogConfig) (*AccessLog, error) {
+	switch config.Format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	case "":
+		config.Format = AccessLogCombined
+	default:
+		return nil, fmt.Errorf("unknown access log format %q, expected %s, %s or %s",
+			config.Format, AccessLogCommon, AccessLogCombined, AccessLogJSON)
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("access log sample rate must be between 0 and 1, got %v", config.SampleRate)
+	}
+
+	path := expandJournalPath(config.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create access log directory: %w", err)
+	}
+	l := &AccessLog{config: config, path: path}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the active file; the caller holds l.mu or owns l
+func (l *AccessLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat access log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Log writes entry if it is sampled
+func (l *AccessLog) Log(entry AccessLogEntry) {
+	if entry.Error == "" && entry.Status < 500 && l.config.SampleRate < 1 && rand.Float64() >= l.config.SampleRate {
+		return
+	}
+	line := []byte(l.format(entry))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+	if maxSize := int64(l.config.MaxSizeMB) * 1024 * 1024; maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > maxSize {
+		if err := l.rotate(); err != nil {
+			l.fail(err)
+			if l.file == nil {
+				return
+			}
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		l.fail(fmt.Errorf("failed to write access log: %w", err))
+		return
+	}
+	l.written++
+}
+
+// format renders entry as one line in the configured format
+func (l *AccessLog) format(entry AccessLogEntry) string {
+	if l.config.Format == AccessLogJSON {
+		data, _ := json.Marshal(entry)
+		return string(data) + "\n"
+	}
+
+	var b strings.Builder
+	host := entry.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.FormatInt(entry.Bytes, 10)
+	}
+	fmt.Fprintf(&b, "%s - - [%s] \"%s %s %s\" %d %s",
+		orDash(host), entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		escapeLogField(entry.Method), escapeLogField(entry.URL), orDash(entry.Protocol), entry.Status, bytes)
+	if l.config.Format == AccessLogCombined {
+		fmt.Fprintf(&b, " \"%s\" \"%s\"", orDash(escapeLogField(entry.Referer)), orDash(escapeLogField(entry.UserAgent)))
+	}
+	fmt.Fprintf(&b, " rt=%.3f cache=%s upstream=%s", entry.Latency.Seconds(), orDash(entry.Cache), orDash(entry.Upstream))
+	if entry.Profile != "" {
+		fmt.Fprintf(&b, " profile=%s", entry.Profile)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// orDash returns s, or "-" for an empty field as the log formats do
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escapeLogField escapes quotes, backslashes and control characters so a
+// field cannot break its line or quoting, as Apache does
+func escapeLogField(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return r == '"' || r == '\\' || r < 0x20 || r == 0x7f }) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// upstreamHost returns the host of rawURL for the access log
+func upstreamHost(rawURL string) string {
+	if parsed, err := url.Parse(rawURL); err == nil {
+		return parsed.Host
+	}
+	return ""
+}
+
+// fail records a write error; the caller holds l.mu
+func (l *AccessLog) fail(err error) {
+	l.errors++
+	l.lastErr = err.Error()
+}
+
+// rotate renames the active file aside, starts a new one and removes the
+// oldest rotated files past MaxFiles; the caller holds l.mu
+func (l *AccessLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		l.fail(fmt.Errorf("failed to close access log: %w", err))
+	}
+	l.file = nil
+
+	// The timestamp suffix sorts rotated files oldest first
+	rotated := l.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(l.path, rotated); err != nil {
+		if openErr := l.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate access log: %w", err)
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	if l.config.MaxFiles > 0 {
+		files, _ := filepath.Glob(l.path + ".*")
+		slices.Sort(files)
+		for len(files) > l.config.MaxFiles {
+			os.Remove(files[0])
+			files = files[1:]
+		}
+	}
+	return nil
+}
+
+// AccessLogStats describes the access log's file and write counters
+type AccessLogStats struct {
+	Enabled     bool    `json:"enabled"`
+	Path        string  `json:"path,omitempty"`
+	Format      string  `json:"format,omitempty"`
+	SampleRate  float64 `json:"sample_rate"`
+	ActiveBytes int64   `json:"active_bytes"`
+	Written     int64   `json:"written"` // Lines written since startup
+	WriteErrors int64   `json:"write_errors"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// Stats describes the access log
+func (l *AccessLog) Stats() AccessLogStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AccessLogStats{
+		Enabled:     true,
+		Path:        l.path,
+		Format:      l.config.Format,
+		SampleRate:  l.config.SampleRate,
+		ActiveBytes: l.size,
+		Written:     l.written,
+		WriteErrors: l.errors,
+		LastError:   l.lastErr,
+	}
+}
+
+// Close closes the active file
+func (l *AccessLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
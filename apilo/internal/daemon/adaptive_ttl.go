@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// AdaptiveTTLConfig learns a TTL per cache entry from how often its content
+// actually changes. Each refetch or revalidation of an expired entry compares
+// the new content with the old, by ETag when both have one and by body
+// otherwise: an unchanged response multiplies the entry's TTL by Factor and a
+// changed one divides it, within MinTTL and MaxTTL. Per-host overrides still
+// take precedence
+type AdaptiveTTLConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	MinTTL  time.Duration `yaml:"min_ttl" json:"min_ttl"`
+	MaxTTL  time.Duration `yaml:"max_ttl" json:"max_ttl"`
+	Factor  float64       `yaml:"factor" json:"factor"`
+}
+
+// DefaultAdaptiveTTLConfig returns a disabled mode doubling or halving TTLs
+// between one minute and a day
+func DefaultAdaptiveTTLConfig() AdaptiveTTLConfig {
+	return AdaptiveTTLConfig{
+		MinTTL: time.Minute,
+		MaxTTL: 24 * time.Hour,
+		Factor: 2,
+	}
+}
+
+// AdaptiveTTLStats reports the TTLs learned so far
+type AdaptiveTTLStats struct {
+	Enabled    bool       `json:"enabled"`
+	Lengthened int64      `json:"lengthened"` // Unchanged content seen on refetch
+	Shortened  int64      `json:"shortened"`  // Changed content seen on refetch
+	Routes     []RouteTTL `json:"routes"`
+}
+
+// RouteTTL summarizes the learned TTLs of a route's cached entries
+type RouteTTL struct {
+	Route      string        `json:"route"`
+	Entries    int           `json:"entries"`
+	MinTTL     time.Duration `json:"min_ttl"`
+	MaxTTL     time.Duration `json:"max_ttl"`
+	AvgTTL     time.Duration `json:"avg_ttl"`
+	Checks     int64         `json:"checks"` // Refetches compared with the previous content
+	Changes    int64         `json:"changes"`
+	ChangeRate float64       `json:"change_rate"`
+}
+
+// SetAdaptiveTTL enables learned TTLs for entries stored from now on
+func (c *Cache) SetAdaptiveTTL(config AdaptiveTTLConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if config.Factor <= 1 {
+		config.Factor = 2
+	}
+	c.adaptive = &config
+}
+
+// Adapt sets next's learned TTL from previous, the entry it replaces, and
+// whether the content changed in between. It does nothing unless adaptive
+// TTLs are enabled
+func (c *Cache) Adapt(previous, next *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.adaptive == nil || previous.Ranges != nil || next.Ranges != nil {
+		return
+	}
+	changed := !bytes.Equal(previous.Body, next.Body)
+	if previous.ETag != "" && next.ETag != "" {
+		changed = previous.ETag != next.ETag
+	}
+	next.TTL = c.adjustTTL(previous, changed)
+	next.Checks = previous.Checks + 1
+	next.Changes = previous.Changes
+	if changed {
+		next.Changes++
+	}
+}
+
+// adjustTTL returns entry's TTL lengthened or shortened by one step; callers
+// hold c.mu
+func (c *Cache) adjustTTL(entry *CacheEntry, changed bool) time.Duration {
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	if changed {
+		ttl = time.Duration(float64(ttl) / c.adaptive.Factor)
+		c.shortened++
+	} else {
+		ttl = time.Duration(float64(ttl) * c.adaptive.Factor)
+		c.lengthened++
+	}
+	if ttl < c.adaptive.MinTTL {
+		return c.adaptive.MinTTL
+	}
+	if c.adaptive.MaxTTL > 0 && ttl > c.adaptive.MaxTTL {
+		return c.adaptive.MaxTTL
+	}
+	return ttl
+}
+
+// AdaptiveTTLStats returns the learned TTLs grouped by route
+func (c *Cache) AdaptiveTTLStats() AdaptiveTTLStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.adaptive == nil {
+		return AdaptiveTTLStats{}
+	}
+
+	routes := make(map[string]*RouteTTL)
+	totals := make(map[string]time.Duration)
+	for _, entry := range c.data {
+		if entry.TTL <= 0 {
+			continue
+		}
+		route, ok := routes[entry.Route]
+		if !ok {
+			route = &RouteTTL{Route: entry.Route, MinTTL: entry.TTL, MaxTTL: entry.TTL}
+			routes[entry.Route] = route
+		}
+		route.Entries++
+		if entry.TTL < route.MinTTL {
+			route.MinTTL = entry.TTL
+		}
+		if entry.TTL > route.MaxTTL {
+			route.MaxTTL = entry.TTL
+		}
+		route.Checks += entry.Checks
+		route.Changes += entry.Changes
+		totals[entry.Route] += entry.TTL
+	}
+
+	stats := AdaptiveTTLStats{
+		Enabled:    true,
+		Lengthened: c.lengthened,
+		Shortened:  c.shortened,
+		Routes:     make([]RouteTTL, 0, len(routes)),
+	}
+	for name, route := range routes {
+		route.AvgTTL = totals[name] / time.Duration(route.Entries)
+		if route.Checks > 0 {
+			route.ChangeRate = float64(route.Changes) / float64(route.Checks)
+		}
+		stats.Routes = append(stats.Routes, *route)
+	}
+	slices.SortFunc(stats.Routes, func(a, b RouteTTL) int {
+		return b.Entries - a.Entries
+	})
+	return stats
+}
+
+// serveAdaptiveTTL writes the TTLs optimizer's cache has learned
+func (ipc *IPCServer) serveAdaptiveTTL(w http.ResponseWriter, optimizer *Optimizer) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(optimizer.cache.AdaptiveTTLStats())
+}
+
+// handleAdaptiveTTL returns the learned TTLs per route
+func (ipc *IPCServer) handleAdaptiveTTL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ipc.serveAdaptiveTTL(w, ipc.service.optimizer)
+}
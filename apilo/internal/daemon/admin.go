@@ -0,0 +1,433 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAuditEntries bounds the in-memory audit log; every change is also
+// written to the daemon log
+const maxAuditEntries = 500
+
+// AuditEntry records one change made through the admin API
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Remote  string    `json:"remote"`
+	Profile string    `json:"profile,omitempty"`
+	Setting string    `json:"setting"`
+	Target  string    `json:"target,omitempty"`
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+}
+
+// AuditLog keeps the most recent admin changes, newest last
+type AuditLog struct {
+	entries []AuditEntry
+	logger  *Logger
+	mu      sync.RWMutex
+}
+
+// NewAuditLog creates an audit log that also writes each entry to logger
+func NewAuditLog(logger *Logger) *AuditLog {
+	return &AuditLog{logger: logger}
+}
+
+// Record appends entry and writes it to the daemon log
+func (a *AuditLog) Record(entry AuditEntry) {
+	a.mu.Lock()
+	if len(a.entries) >= maxAuditEntries {
+		a.entries = append(a.entries[:0], a.entries[1:]...)
+	}
+	a.entries = append(a.entries, entry)
+	a.mu.Unlock()
+
+	scope := entry.Setting
+	if entry.Profile != "" {
+		scope = entry.Profile + "/" + scope
+	}
+	if entry.Target != "" {
+		scope += "[" + entry.Target + "]"
+	}
+	a.logger.Audit("%s changed %s: %s -> %s", entry.Remote, scope, entry.Old, entry.New)
+}
+
+// Recent returns up to limit entries, newest first
+func (a *AuditLog) Recent(limit int) []AuditEntry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if limit <= 0 || limit > len(a.entries) {
+		limit = len(a.entries)
+	}
+	recent := make([]AuditEntry, limit)
+	for i := range recent {
+		recent[i] = a.entries[len(a.entries)-1-i]
+	}
+	return recent
+}
+
+// CacheSettings are the runtime cache settings of one optimizer
+type CacheSettings struct {
+	Enabled      bool              `json:"enabled"`
+	DefaultTTL   string            `json:"default_ttl"`
+	TTLOverrides map[string]string `json:"ttl_overrides"`
+}
+
+// RuntimeSettings are the daemon settings adjustable through the admin API
+type RuntimeSettings struct {
+	Cache               CacheSettings            `json:"cache"`
+	ProfileCaches       map[string]CacheSettings `json:"profile_caches,omitempty"`
+	LogLevel            string                   `json:"log_level"`
+	LogSampleRate       float64                  `json:"log_sample_rate"`
+	AnalyticsSampleRate float64                  `json:"analytics_sample_rate"`
+}
+
+// cacheSettings describes optimizer's current cache settings
+func cacheSettings(optimizer *Optimizer) CacheSettings {
+	overrides := make(map[string]string)
+	for host, ttl := range optimizer.Cache().TTLOverrides() {
+		overrides[host] = ttl.String()
+	}
+	return CacheSettings{
+		Enabled:      optimizer.CacheEnabled(),
+		DefaultTTL:   optimizer.Cache().DefaultTTL().String(),
+		TTLOverrides: overrides,
+	}
+}
+
+// RuntimeSettings returns the current runtime-adjustable settings
+func (s *Service) RuntimeSettings() RuntimeSettings {
+	settings := RuntimeSettings{
+		Cache:               cacheSettings(s.optimizer),
+		LogLevel:            strings.ToLower(s.logger.Level().String()),
+		LogSampleRate:       s.logger.RequestSampleRate(),
+		AnalyticsSampleRate: s.AnalyticsSampleRate(),
+	}
+	if profiles := s.profiles.List(); len(profiles) > 0 {
+		settings.ProfileCaches = make(map[string]CacheSettings, len(profiles))
+		for _, profile := range profiles {
+			settings.ProfileCaches[profile.Name()] = cacheSettings(profile.optimizer)
+		}
+	}
+	return settings
+}
+
+// AnalyticsSampleRate returns the fraction of requests kept in analytics on
+// routes without their own sample rate
+func (s *Service) AnalyticsSampleRate() float64 {
+	return s.sampler.Rate()
+}
+
+// SetAnalyticsSampleRate sets the fraction of requests kept in analytics on
+// routes without their own sample rate; metrics counters always see every request
+func (s *Service) SetAnalyticsSampleRate(rate float64) {
+	s.sampler.SetRate(rate)
+}
+
+// requireAdmin wraps handler with bearer token authentication. The admin API
+// is disabled when no token is configured
+func (ipc *IPCServer) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := ipc.service.adminToken
+		if token == "" {
+			http.Error(w, "Admin API disabled: set APILO_ADMIN_TOKEN", http.StatusForbidden)
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="apilo-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// adminOptimizer returns the optimizer of the named profile, or the daemon's
+// own when name is empty
+func (ipc *IPCServer) adminOptimizer(name string) (*Optimizer, error) {
+	if name == "" {
+		return ipc.service.optimizer, nil
+	}
+	profile, ok := ipc.service.profiles.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	return profile.optimizer, nil
+}
+
+// audit records a change made by the request's caller
+func (ipc *IPCServer) audit(r *http.Request, profile, setting, target string, old, new interface{}) {
+	ipc.service.audit.Record(AuditEntry{
+		Time:    time.Now(),
+		Remote:  r.RemoteAddr,
+		Profile: profile,
+		Setting: setting,
+		Target:  target,
+		Old:     fmt.Sprint(old),
+		New:     fmt.Sprint(new),
+	})
+}
+
+// decodeAdmin decodes an admin request body, writing a 400 on failure
+func decodeAdmin(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeSettings responds with the current runtime settings
+func (ipc *IPCServer) writeSettings(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ipc.service.RuntimeSettings())
+}
+
+// handleAdminSettings returns the runtime-adjustable settings
+func (ipc *IPCServer) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ipc.writeSettings(w)
+}
+
+// handleAdminCache enables or disables caching and changes the default TTL
+func (ipc *IPCServer) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update struct {
+		Profile    string `json:"profile"`
+		Enabled    *bool  `json:"enabled"`
+		DefaultTTL string `json:"default_ttl"`
+	}
+	if !decodeAdmin(w, r, &update) {
+		return
+	}
+	optimizer, err := ipc.adminOptimizer(update.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var ttl time.Duration
+	if update.DefaultTTL != "" {
+		if ttl, err = time.ParseDuration(update.DefaultTTL); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid default_ttl %q", update.DefaultTTL), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if update.Enabled != nil {
+		old := optimizer.CacheEnabled()
+		optimizer.SetCacheEnabled(*update.Enabled)
+		ipc.audit(r, update.Profile, "cache.enabled", "", old, *update.Enabled)
+	}
+	if ttl > 0 {
+		old := optimizer.Cache().DefaultTTL()
+		optimizer.Cache().SetDefaultTTL(ttl)
+		ipc.audit(r, update.Profile, "cache.default_ttl", "", old, ttl)
+	}
+	ipc.writeSettings(w)
+}
+
+// handleAdminCacheTTL sets (PUT) or removes (DELETE) a per-host TTL override
+func (ipc *IPCServer) handleAdminCacheTTL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update struct {
+		Profile string `json:"profile"`
+		Host    string `json:"host"`
+		TTL     string `json:"ttl"`
+	}
+	if !decodeAdmin(w, r, &update) {
+		return
+	}
+	if update.Host == "" {
+		http.Error(w, "host is required", http.StatusBadRequest)
+		return
+	}
+	optimizer, err := ipc.adminOptimizer(update.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var ttl time.Duration
+	if r.Method == http.MethodPut {
+		if ttl, err = time.ParseDuration(update.TTL); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid ttl %q", update.TTL), http.StatusBadRequest)
+			return
+		}
+	}
+
+	old := "default"
+	if current, ok := optimizer.Cache().TTLOverrides()[update.Host]; ok {
+		old = current.String()
+	}
+	optimizer.Cache().SetTTLOverride(update.Host, ttl)
+
+	newTTL := "default"
+	if ttl > 0 {
+		newTTL = ttl.String()
+	}
+	ipc.audit(r, update.Profile, "cache.ttl_override", update.Host, old, newTTL)
+	ipc.writeSettings(w)
+}
+
+// handleAdminCircuits forces a host's breaker open or closed, releases it back
+// to automatic operation or resets its counters
+func (ipc *IPCServer) handleAdminCircuits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update struct {
+		Profile string `json:"profile"`
+		Host    string `json:"host"`
+		Action  string `json:"action"` // force-open, force-close, release or reset
+	}
+	if !decodeAdmin(w, r, &update) {
+		return
+	}
+	if update.Host == "" {
+		http.Error(w, "host is required", http.StatusBadRequest)
+		return
+	}
+	optimizer, err := ipc.adminOptimizer(update.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if optimizer.Circuits() == nil {
+		http.Error(w, "Circuit breakers are disabled", http.StatusConflict)
+		return
+	}
+
+	breaker := optimizer.Circuits().Get(update.Host)
+	before := breaker.Info(update.Host)
+	switch update.Action {
+	case "force-open":
+		breaker.Force(CircuitOpen)
+	case "force-close":
+		breaker.Force(CircuitClosed)
+	case "release":
+		breaker.Unforce()
+	case "reset":
+		breaker.Reset()
+	default:
+		http.Error(w, fmt.Sprintf("Unknown action %q: use force-open, force-close, release or reset", update.Action), http.StatusBadRequest)
+		return
+	}
+	after := breaker.Info(update.Host)
+
+	ipc.audit(r, update.Profile, "circuit."+update.Action, update.Host, describeCircuit(before), describeCircuit(after))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
+
+// describeCircuit renders a breaker's state for the audit log
+func describeCircuit(info CircuitInfo) string {
+	state := string(info.State)
+	if info.Forced {
+		state += " (forced)"
+	}
+	return fmt.Sprintf("%s, %d failures", state, info.Failures)
+}
+
+// handleAdminLogLevel changes the daemon's logging level
+func (ipc *IPCServer) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update struct {
+		Level string `json:"level"`
+	}
+	if !decodeAdmin(w, r, &update) {
+		return
+	}
+
+	// ParseLogLevel falls back to INFO, so check the name explicitly
+	level := ParseLogLevel(update.Level)
+	if !strings.EqualFold(level.String(), update.Level) && !strings.EqualFold(update.Level, "warning") {
+		http.Error(w, fmt.Sprintf("Invalid level %q: use debug, info, warn or error", update.Level), http.StatusBadRequest)
+		return
+	}
+
+	old := ipc.service.logger.Level()
+	ipc.service.logger.SetLevel(level)
+	ipc.audit(r, "", "log.level", "", strings.ToLower(old.String()), strings.ToLower(level.String()))
+	ipc.writeSettings(w)
+}
+
+// handleAdminSampling changes the fraction of requests logged and kept in
+// analytics
+func (ipc *IPCServer) handleAdminSampling(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update struct {
+		Logs      *float64 `json:"logs"`
+		Analytics *float64 `json:"analytics"`
+	}
+	if !decodeAdmin(w, r, &update) {
+		return
+	}
+	for _, rate := range []*float64{update.Logs, update.Analytics} {
+		if rate != nil && (*rate < 0 || *rate > 1) {
+			http.Error(w, "Sample rates must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if update.Logs != nil {
+		old := ipc.service.logger.RequestSampleRate()
+		ipc.service.logger.SetRequestSampleRate(*update.Logs)
+		ipc.audit(r, "", "sampling.logs", "", old, *update.Logs)
+	}
+	if update.Analytics != nil {
+		old := ipc.service.AnalyticsSampleRate()
+		ipc.service.SetAnalyticsSampleRate(*update.Analytics)
+		ipc.audit(r, "", "sampling.analytics", "", old, *update.Analytics)
+	}
+	ipc.writeSettings(w)
+}
+
+// handleAdminAudit returns recent admin changes, newest first
+func (ipc *IPCServer) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		if parsed, err := strconv.Atoi(limitParam); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": ipc.service.audit.Recent(limit),
+	})
+}
@@ -0,0 +1,333 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "test-admin-token"
+
+// newTestService creates a service that writes only under t.TempDir and
+// loads no profiles
+func newTestService(t *testing.T, config *DaemonConfig) *Service {
+	t.Helper()
+	if config == nil {
+		config = DefaultDaemonConfig()
+	}
+	dir := t.TempDir()
+	config.LogFile = filepath.Join(dir, "daemon.log")
+	config.PIDFile = filepath.Join(dir, "daemon.pid")
+	config.CircuitStateFile = filepath.Join(dir, "circuits.json")
+	config.ProfilesFile = ""
+
+	service, err := NewService(config)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	t.Cleanup(func() { service.logger.Close() })
+	return service
+}
+
+// newTestLogger creates a debug logger writing under t.TempDir
+func newTestLogger(t *testing.T) *Logger {
+	t.Helper()
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "daemon.log"), DEBUG)
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	return logger
+}
+
+// newAdminTestServer creates an IPC server whose admin API accepts token
+func newAdminTestServer(t *testing.T, token string) *IPCServer {
+	t.Helper()
+	t.Setenv("APILO_ADMIN_TOKEN", "")
+	config := DefaultDaemonConfig()
+	config.AdminToken = token
+	return newTestService(t, config).ipcServer
+}
+
+// adminRequest runs one authenticated request against handler
+func adminRequest(ipc *IPCServer, handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/admin", strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec := httptest.NewRecorder()
+	ipc.requireAdmin(handler)(rec, req)
+	return rec
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+		wantBody   string
+		wantAuth   bool
+	}{
+		{"disabled", "", "Bearer " + testAdminToken, http.StatusForbidden, "Admin API disabled: set APILO_ADMIN_TOKEN", false},
+		{"missing header", testAdminToken, "", http.StatusUnauthorized, "Unauthorized", true},
+		{"not bearer", testAdminToken, "Basic " + testAdminToken, http.StatusUnauthorized, "Unauthorized", true},
+		{"wrong token", testAdminToken, "Bearer wrong", http.StatusUnauthorized, "Unauthorized", true},
+		{"valid token", testAdminToken, "Bearer " + testAdminToken, http.StatusOK, "ok", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipc := newAdminTestServer(t, tt.token)
+			handler := ipc.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.wantAuth {
+				t.Errorf("Expected WWW-Authenticate set=%v, got %v", tt.wantAuth, got)
+			}
+		})
+	}
+}
+
+// lastAudit returns the newest audit entry, failing when there is none
+func lastAudit(t *testing.T, ipc *IPCServer) AuditEntry {
+	t.Helper()
+	entries := ipc.service.audit.Recent(1)
+	if len(entries) == 0 {
+		t.Fatal("Expected an audit entry, got none")
+	}
+	return entries[0]
+}
+
+// assertNoAudit fails when any change was audited
+func assertNoAudit(t *testing.T, ipc *IPCServer) {
+	t.Helper()
+	if entries := ipc.service.audit.Recent(0); len(entries) != 0 {
+		t.Errorf("Expected no audit entries, got %+v", entries)
+	}
+}
+
+func TestAdminRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(*IPCServer) http.HandlerFunc
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"cache wrong method", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCache }, http.MethodPost, `{}`, http.StatusMethodNotAllowed},
+		{"cache bad json", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCache }, http.MethodPut, `{`, http.StatusBadRequest},
+		{"cache unknown profile", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCache }, http.MethodPut, `{"profile":"nope","enabled":false}`, http.StatusNotFound},
+		{"cache bad ttl", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCache }, http.MethodPut, `{"default_ttl":"soon"}`, http.StatusBadRequest},
+		{"cache negative ttl", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCache }, http.MethodPut, `{"enabled":false,"default_ttl":"-1m"}`, http.StatusBadRequest},
+		{"ttl wrong method", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCacheTTL }, http.MethodGet, `{}`, http.StatusMethodNotAllowed},
+		{"ttl missing host", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCacheTTL }, http.MethodPut, `{"ttl":"1m"}`, http.StatusBadRequest},
+		{"ttl unknown profile", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCacheTTL }, http.MethodPut, `{"profile":"nope","host":"example.com","ttl":"1m"}`, http.StatusNotFound},
+		{"ttl bad ttl", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCacheTTL }, http.MethodPut, `{"host":"example.com","ttl":"0s"}`, http.StatusBadRequest},
+		{"circuits wrong method", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCircuits }, http.MethodPut, `{}`, http.StatusMethodNotAllowed},
+		{"circuits missing host", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCircuits }, http.MethodPost, `{"action":"reset"}`, http.StatusBadRequest},
+		{"circuits unknown action", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminCircuits }, http.MethodPost, `{"host":"example.com","action":"explode"}`, http.StatusBadRequest},
+		{"log level wrong method", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminLogLevel }, http.MethodPost, `{}`, http.StatusMethodNotAllowed},
+		{"log level unknown", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminLogLevel }, http.MethodPut, `{"level":"verbose"}`, http.StatusBadRequest},
+		{"sampling wrong method", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminSampling }, http.MethodPost, `{}`, http.StatusMethodNotAllowed},
+		{"sampling above one", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminSampling }, http.MethodPut, `{"logs":1.5}`, http.StatusBadRequest},
+		{"sampling negative", func(ipc *IPCServer) http.HandlerFunc { return ipc.handleAdminSampling }, http.MethodPut, `{"logs":0.5,"analytics":-0.1}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipc := newAdminTestServer(t, testAdminToken)
+			rec := adminRequest(ipc, tt.handler(ipc), tt.method, tt.body)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			assertNoAudit(t, ipc)
+		})
+	}
+}
+
+func TestAdminCache(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+	optimizer := ipc.service.optimizer
+	oldTTL := optimizer.Cache().DefaultTTL()
+
+	rec := adminRequest(ipc, ipc.handleAdminCache, http.MethodPut, `{"enabled":false,"default_ttl":"2m"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if optimizer.CacheEnabled() {
+		t.Error("Expected cache disabled")
+	}
+	if ttl := optimizer.Cache().DefaultTTL(); ttl != 2*time.Minute {
+		t.Errorf("Expected default TTL 2m, got %v", ttl)
+	}
+
+	var settings RuntimeSettings
+	if err := json.Unmarshal(rec.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("Failed to decode settings: %v", err)
+	}
+	if settings.Cache.Enabled || settings.Cache.DefaultTTL != "2m0s" {
+		t.Errorf("Expected response settings disabled with 2m0s, got %+v", settings.Cache)
+	}
+
+	entries := ipc.service.audit.Recent(0)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+	want := []AuditEntry{
+		{Setting: "cache.default_ttl", Old: oldTTL.String(), New: "2m0s"},
+		{Setting: "cache.enabled", Old: "true", New: "false"},
+	}
+	for i, entry := range entries {
+		if entry.Setting != want[i].Setting || entry.Old != want[i].Old || entry.New != want[i].New {
+			t.Errorf("Expected audit entry %+v, got %+v", want[i], entry)
+		}
+		if entry.Remote != "192.0.2.1:1234" {
+			t.Errorf("Expected remote 192.0.2.1:1234, got %q", entry.Remote)
+		}
+	}
+}
+
+func TestAdminCacheTTL(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+	cache := ipc.service.optimizer.Cache()
+
+	rec := adminRequest(ipc, ipc.handleAdminCacheTTL, http.MethodPut, `{"host":"example.com","ttl":"30s"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ttl := cache.TTLOverrides()["example.com"]; ttl != 30*time.Second {
+		t.Errorf("Expected override 30s, got %v", ttl)
+	}
+	entry := lastAudit(t, ipc)
+	if entry.Setting != "cache.ttl_override" || entry.Target != "example.com" || entry.Old != "default" || entry.New != "30s" {
+		t.Errorf("Expected override audit default -> 30s, got %+v", entry)
+	}
+
+	rec = adminRequest(ipc, ipc.handleAdminCacheTTL, http.MethodDelete, `{"host":"example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := cache.TTLOverrides()["example.com"]; ok {
+		t.Error("Expected override removed")
+	}
+	entry = lastAudit(t, ipc)
+	if entry.Old != "30s" || entry.New != "default" {
+		t.Errorf("Expected removal audit 30s -> default, got %+v", entry)
+	}
+}
+
+func TestAdminCircuits(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+
+	rec := adminRequest(ipc, ipc.handleAdminCircuits, http.MethodPost, `{"host":"example.com","action":"force-open"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var info CircuitInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to decode circuit: %v", err)
+	}
+	if info.State != CircuitOpen || !info.Forced {
+		t.Errorf("Expected forced open circuit, got %+v", info)
+	}
+
+	entry := lastAudit(t, ipc)
+	if entry.Setting != "circuit.force-open" || entry.Target != "example.com" {
+		t.Errorf("Expected circuit.force-open on example.com, got %+v", entry)
+	}
+	if !strings.HasSuffix(entry.New, "(forced), 0 failures") || strings.Contains(entry.Old, "forced") {
+		t.Errorf("Expected audit to show the breaker becoming forced, got %q -> %q", entry.Old, entry.New)
+	}
+}
+
+func TestAdminCircuitsDisabled(t *testing.T) {
+	t.Setenv("APILO_ADMIN_TOKEN", "")
+	config := DefaultDaemonConfig()
+	config.AdminToken = testAdminToken
+	config.EnableCircuitBreaker = false
+	ipc := newTestService(t, config).ipcServer
+
+	rec := adminRequest(ipc, ipc.handleAdminCircuits, http.MethodPost, `{"host":"example.com","action":"reset"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rec.Code)
+	}
+	assertNoAudit(t, ipc)
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+
+	rec := adminRequest(ipc, ipc.handleAdminLogLevel, http.MethodPut, `{"level":"WARNING"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if level := ipc.service.logger.Level(); level != WARN {
+		t.Errorf("Expected WARN, got %v", level)
+	}
+	entry := lastAudit(t, ipc)
+	if entry.Setting != "log.level" || entry.Old != "info" || entry.New != "warn" {
+		t.Errorf("Expected log.level info -> warn, got %+v", entry)
+	}
+}
+
+func TestAdminSampling(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+
+	rec := adminRequest(ipc, ipc.handleAdminSampling, http.MethodPut, `{"logs":0.25,"analytics":0.5}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rate := ipc.service.logger.RequestSampleRate(); rate != 0.25 {
+		t.Errorf("Expected log sample rate 0.25, got %v", rate)
+	}
+	if rate := ipc.service.AnalyticsSampleRate(); rate != 0.5 {
+		t.Errorf("Expected analytics sample rate 0.5, got %v", rate)
+	}
+
+	entries := ipc.service.audit.Recent(0)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+	if entries[1].Setting != "sampling.logs" || entries[1].New != "0.25" {
+		t.Errorf("Expected sampling.logs -> 0.25, got %+v", entries[1])
+	}
+	if entries[0].Setting != "sampling.analytics" || entries[0].New != "0.5" {
+		t.Errorf("Expected sampling.analytics -> 0.5, got %+v", entries[0])
+	}
+}
+
+func TestAuditLogBounded(t *testing.T) {
+	ipc := newAdminTestServer(t, testAdminToken)
+	audit := ipc.service.audit
+
+	for i := 0; i < maxAuditEntries+10; i++ {
+		audit.Record(AuditEntry{Setting: "test", New: time.Duration(i).String()})
+	}
+
+	entries := audit.Recent(0)
+	if len(entries) != maxAuditEntries {
+		t.Fatalf("Expected %d entries, got %d", maxAuditEntries, len(entries))
+	}
+	if newest := entries[0].New; newest != time.Duration(maxAuditEntries+9).String() {
+		t.Errorf("Expected newest entry first, got %s", newest)
+	}
+}
@@ -12,22 +12,29 @@ type RequestRecord struct {
 	URL          string    `json:"url"`
 	Method       string    `json:"method"`
 	StatusCode   int       `json:"status_code"`
-	Latency      int64     `json:"latency"` // nanoseconds
+	Latency      int64     `json:"latency"` // nanoseconds of service time
 	CacheHit     bool      `json:"cache_hit"`
 	Error        string    `json:"error,omitempty"`
 	InputTokens  int64     `json:"input_tokens"`
 	OutputTokens int64     `json:"output_tokens"`
 	TotalTokens  int64     `json:"total_tokens"`
 	IsEstimated  bool      `json:"is_estimated"`
+
+	QueueLatency int64 `json:"queue_latency,omitempty"` // nanoseconds queued before service
+
+	// Identify the response for offline cache simulation
+	CacheKey      string `json:"cache_key,omitempty"` // Prefix of the optimizer's cache key
+	ResponseBytes int64  `json:"response_bytes,omitempty"`
 }
 
 // Analytics provides enhanced metrics tracking and analysis
 type Analytics struct {
-	requestHistory []RequestRecord
-	latencyHistory []int64 // For percentile calculations
+	requestHistory *requestRing
 	maxHistory     int
+	evicted        HistorySummary // Rolled-up requests evicted from requestHistory
 	errorBreakdown map[string]int64
 	urlStats       map[string]*URLStats
+	maxURLs        int
 	mu             sync.RWMutex
 }
 
@@ -39,12 +46,18 @@ type URLStats struct {
 	TotalLatency  int64
 	MinLatency    int64
 	MaxLatency    int64
+	FirstSeen     time.Time
+	LastSeen      time.Time
+
+	score   float64 // Exponentially decayed request count
+	scoreAt time.Time
 }
 
 // AnalyticsSnapshot represents current analytics state
 type AnalyticsSnapshot struct {
 	RecentRequests     []RequestRecord        `json:"recent_requests"`
 	LatencyPercentiles map[string]float64     `json:"latency_percentiles"`
+	QueuePercentiles   map[string]float64     `json:"queue_percentiles"`
 	ErrorBreakdown     map[string]int64       `json:"error_breakdown"`
 	TopURLs            []URLAnalytics         `json:"top_urls"`
 	RequestRate        float64                `json:"request_rate"` // requests per second
@@ -52,6 +65,7 @@ type AnalyticsSnapshot struct {
 	TimeSeriesData     TimeSeriesData         `json:"time_series"`
 	TokenSavings       TokenSavingsMetrics    `json:"token_savings"`
 	TokenUsageMetrics  TokenUsageMetrics      `json:"token_usage_metrics"`
+	History            HistoryStats           `json:"history"`
 }
 
 // URLAnalytics provides per-URL analytics
@@ -62,6 +76,7 @@ type URLAnalytics struct {
 	AvgLatency    int64   `json:"avg_latency"`
 	MinLatency    int64   `json:"min_latency"`
 	MaxLatency    int64   `json:"max_latency"`
+	TypicalTTL    int64   `json:"typical_ttl"` // mean re-request interval in nanoseconds
 }
 
 // CacheEfficiencyMetrics provides cache performance details
@@ -118,11 +133,11 @@ type TokenUsageMetrics struct {
 // NewAnalytics creates a new analytics tracker
 func NewAnalytics(maxHistory int) *Analytics {
 	return &Analytics{
-		requestHistory: make([]RequestRecord, 0, maxHistory),
-		latencyHistory: make([]int64, 0, maxHistory),
+		requestHistory: newRequestRing(maxHistory),
 		maxHistory:     maxHistory,
 		errorBreakdown: make(map[string]int64),
 		urlStats:       make(map[string]*URLStats),
+		maxURLs:        maxTrackedURLs,
 	}
 }
 
@@ -131,21 +146,14 @@ func (a *Analytics) RecordRequest(record RequestRecord) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Add to request history (circular buffer)
-	if len(a.requestHistory) >= a.maxHistory {
-		a.requestHistory = a.requestHistory[1:]
+	// Add to request history, rolling up whatever falls out of the window
+	if evicted, ok := a.requestHistory.push(record); ok {
+		a.evicted.add(&evicted)
 	}
-	a.requestHistory = append(a.requestHistory, record)
-
-	// Add to latency history
-	if len(a.latencyHistory) >= a.maxHistory {
-		a.latencyHistory = a.latencyHistory[1:]
-	}
-	a.latencyHistory = append(a.latencyHistory, record.Latency)
 
 	// Track errors
 	if record.Error != "" {
-		a.errorBreakdown[record.Error]++
+		a.recordError(record.Error)
 	}
 
 	// Track per-URL stats
@@ -163,6 +171,8 @@ func (a *Analytics) RecordRequest(record RequestRecord) {
 		if record.Latency > stats.MaxLatency {
 			stats.MaxLatency = record.Latency
 		}
+		stats.LastSeen = record.Timestamp
+		stats.touch(record.Timestamp)
 	} else {
 		hits := int64(0)
 		misses := int64(0)
@@ -171,14 +181,19 @@ func (a *Analytics) RecordRequest(record RequestRecord) {
 		} else {
 			misses = 1
 		}
-		a.urlStats[record.URL] = &URLStats{
+		stats := &URLStats{
 			TotalRequests: 1,
 			CacheHits:     hits,
 			CacheMisses:   misses,
 			TotalLatency:  record.Latency,
 			MinLatency:    record.Latency,
 			MaxLatency:    record.Latency,
+			FirstSeen:     record.Timestamp,
+			LastSeen:      record.Timestamp,
 		}
+		stats.touch(record.Timestamp)
+		a.urlStats[record.URL] = stats
+		a.pruneURLs(record.Timestamp)
 	}
 }
 
@@ -202,7 +217,8 @@ func (a *Analytics) GetSnapshotWithLimit(requestLimit int) *AnalyticsSnapshot {
 
 	snapshot := &AnalyticsSnapshot{
 		RecentRequests:     a.getRecentRequests(requestLimit),
-		LatencyPercentiles: a.calculatePercentiles(),
+		LatencyPercentiles: a.calculatePercentiles(func(r *RequestRecord) int64 { return r.Latency }),
+		QueuePercentiles:   a.calculatePercentiles(func(r *RequestRecord) int64 { return r.QueueLatency }),
 		ErrorBreakdown:     a.copyErrorBreakdown(),
 		TopURLs:            a.getTopURLs(10),
 		RequestRate:        a.calculateRequestRate(),
@@ -210,6 +226,12 @@ func (a *Analytics) GetSnapshotWithLimit(requestLimit int) *AnalyticsSnapshot {
 		TimeSeriesData:     a.calculateTimeSeries(),
 		TokenSavings:       a.calculateTokenSavings(),
 		TokenUsageMetrics:  a.calculateTokenUsage(),
+		History: HistoryStats{
+			Retained:    a.requestHistory.len(),
+			Capacity:    a.maxHistory,
+			TrackedURLs: len(a.urlStats),
+			Evicted:     a.evicted,
+		},
 	}
 
 	return snapshot
@@ -217,31 +239,24 @@ func (a *Analytics) GetSnapshotWithLimit(requestLimit int) *AnalyticsSnapshot {
 
 // getRecentRequests returns the N most recent requests
 func (a *Analytics) getRecentRequests(n int) []RequestRecord {
-	if len(a.requestHistory) == 0 {
-		return []RequestRecord{}
+	count := a.requestHistory.len()
+	if count > n {
+		count = n
 	}
 
-	start := 0
-	if len(a.requestHistory) > n {
-		start = len(a.requestHistory) - n
-	}
-
-	// Return copy
-	records := make([]RequestRecord, len(a.requestHistory)-start)
-	copy(records, a.requestHistory[start:])
-
-	// Reverse to show newest first
-	for i := 0; i < len(records)/2; i++ {
-		j := len(records) - 1 - i
-		records[i], records[j] = records[j], records[i]
+	// Copy newest first
+	records := make([]RequestRecord, count)
+	last := a.requestHistory.len() - 1
+	for i := range records {
+		records[i] = *a.requestHistory.at(last - i)
 	}
 
 	return records
 }
 
-// calculatePercentiles calculates latency percentiles
-func (a *Analytics) calculatePercentiles() map[string]float64 {
-	if len(a.latencyHistory) == 0 {
+// calculatePercentiles calculates percentiles of one duration of the records
+func (a *Analytics) calculatePercentiles(duration func(*RequestRecord) int64) map[string]float64 {
+	if a.requestHistory.len() == 0 {
 		return map[string]float64{
 			"p50": 0,
 			"p95": 0,
@@ -250,8 +265,10 @@ func (a *Analytics) calculatePercentiles() map[string]float64 {
 	}
 
 	// Create sorted copy
-	sorted := make([]int64, len(a.latencyHistory))
-	copy(sorted, a.latencyHistory)
+	sorted := make([]int64, 0, a.requestHistory.len())
+	a.requestHistory.each(func(record *RequestRecord) {
+		sorted = append(sorted, duration(record))
+	})
 	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 
 	return map[string]float64{
@@ -284,6 +301,12 @@ func (a *Analytics) getTopURLs(n int) []URLAnalytics {
 			avgLatency = stats.TotalLatency / stats.TotalRequests
 		}
 
+		// The mean gap between requests approximates how long a cached copy stays useful
+		typicalTTL := int64(0)
+		if stats.TotalRequests > 1 {
+			typicalTTL = int64(stats.LastSeen.Sub(stats.FirstSeen)) / (stats.TotalRequests - 1)
+		}
+
 		urls = append(urls, URLAnalytics{
 			URL:           url,
 			TotalRequests: stats.TotalRequests,
@@ -291,6 +314,7 @@ func (a *Analytics) getTopURLs(n int) []URLAnalytics {
 			AvgLatency:    avgLatency,
 			MinLatency:    stats.MinLatency,
 			MaxLatency:    stats.MaxLatency,
+			TypicalTTL:    typicalTTL,
 		})
 	}
 
@@ -308,28 +332,30 @@ func (a *Analytics) getTopURLs(n int) []URLAnalytics {
 
 // calculateRequestRate calculates requests per second based on recent history
 func (a *Analytics) calculateRequestRate() float64 {
-	if len(a.requestHistory) < 2 {
+	count := a.requestHistory.len()
+	if count < 2 {
 		return 0
 	}
 
-	first := a.requestHistory[0].Timestamp
-	last := a.requestHistory[len(a.requestHistory)-1].Timestamp
+	first := a.requestHistory.at(0).Timestamp
+	last := a.requestHistory.at(count - 1).Timestamp
 	duration := last.Sub(first).Seconds()
 
 	if duration == 0 {
 		return 0
 	}
 
-	return float64(len(a.requestHistory)) / duration
+	return float64(count) / duration
 }
 
-// calculateCacheEfficiency calculates cache performance metrics
+// calculateCacheEfficiency calculates cache performance metrics over the
+// retained history and the rolled-up evicted requests
 func (a *Analytics) calculateCacheEfficiency() CacheEfficiencyMetrics {
-	var totalHits, totalMisses int64
-	var hitLatency, missLatency int64
-	var hitCount, missCount int64
+	totalHits, totalMisses := a.evicted.CacheHits, a.evicted.CacheMisses
+	hitLatency, missLatency := a.evicted.HitLatency, a.evicted.MissLatency
+	hitCount, missCount := a.evicted.CacheHits, a.evicted.CacheMisses
 
-	for _, record := range a.requestHistory {
+	a.requestHistory.each(func(record *RequestRecord) {
 		if record.CacheHit {
 			totalHits++
 			hitLatency += record.Latency
@@ -339,7 +365,7 @@ func (a *Analytics) calculateCacheEfficiency() CacheEfficiencyMetrics {
 			missLatency += record.Latency
 			missCount++
 		}
-	}
+	})
 
 	total := totalHits + totalMisses
 	hitRate := 0.0
@@ -385,9 +411,9 @@ func (a *Analytics) calculateBucket(cutoff time.Time) TimeBucket {
 	var requests, cacheHits, cacheMisses, errors int64
 	var totalLatency int64
 
-	for _, record := range a.requestHistory {
+	a.requestHistory.each(func(record *RequestRecord) {
 		if record.Timestamp.Before(cutoff) {
-			continue
+			return
 		}
 
 		requests++
@@ -402,7 +428,7 @@ func (a *Analytics) calculateBucket(cutoff time.Time) TimeBucket {
 		if record.Error != "" {
 			errors++
 		}
-	}
+	})
 
 	avgLatency := int64(0)
 	if requests > 0 {
@@ -425,16 +451,18 @@ func (a *Analytics) calculateBucket(cutoff time.Time) TimeBucket {
 	}
 }
 
-// calculateTokenSavings estimates token and cost savings from cache hits
+// calculateTokenSavings estimates token and cost savings from cache hits,
+// including those rolled up from evicted history
 func (a *Analytics) calculateTokenSavings() TokenSavingsMetrics {
 	// Count cache hits and track actual token usage
-	var totalCacheHits int64
-	var totalHitLatency int64
-	var totalMissLatency int64
-	var hitCount, missCount int64
-	var totalInputFromCacheHits, totalOutputFromCacheHits int64
-
-	for _, record := range a.requestHistory {
+	totalCacheHits := a.evicted.CacheHits
+	totalHitLatency := a.evicted.HitLatency
+	totalMissLatency := a.evicted.MissLatency
+	hitCount, missCount := a.evicted.CacheHits, a.evicted.CacheMisses
+	totalInputFromCacheHits := a.evicted.HitInputTokens
+	totalOutputFromCacheHits := a.evicted.HitOutputTokens
+
+	a.requestHistory.each(func(record *RequestRecord) {
 		if record.CacheHit {
 			totalCacheHits++
 			totalHitLatency += record.Latency
@@ -445,7 +473,7 @@ func (a *Analytics) calculateTokenSavings() TokenSavingsMetrics {
 			totalMissLatency += record.Latency
 			missCount++
 		}
-	}
+	})
 
 	// Calculate average tokens from ACTUAL measured data
 	avgTokensPerRequest := int64(0)
@@ -453,12 +481,12 @@ func (a *Analytics) calculateTokenSavings() TokenSavingsMetrics {
 		avgTokensPerRequest = (totalInputFromCacheHits + totalOutputFromCacheHits) / totalCacheHits
 	} else {
 		// Fallback: calculate average from all requests if no cache hits yet
-		var totalTokensAll int64
-		for _, record := range a.requestHistory {
+		totalTokensAll := a.evicted.TotalTokens
+		a.requestHistory.each(func(record *RequestRecord) {
 			totalTokensAll += record.TotalTokens
-		}
-		if len(a.requestHistory) > 0 {
-			avgTokensPerRequest = totalTokensAll / int64(len(a.requestHistory))
+		})
+		if requests := a.evicted.Requests + int64(a.requestHistory.len()); requests > 0 {
+			avgTokensPerRequest = totalTokensAll / requests
 		} else {
 			// Ultimate fallback for empty history
 			avgTokensPerRequest = 1000
@@ -500,12 +528,14 @@ func (a *Analytics) calculateTokenSavings() TokenSavingsMetrics {
 	}
 }
 
-// calculateTokenUsage calculates overall token consumption metrics
+// calculateTokenUsage calculates overall token consumption metrics, including
+// those rolled up from evicted history
 func (a *Analytics) calculateTokenUsage() TokenUsageMetrics {
-	var totalInputTokens, totalOutputTokens int64
-	var estimatedRequests, actualRequests int64
+	totalInputTokens, totalOutputTokens := a.evicted.InputTokens, a.evicted.OutputTokens
+	estimatedRequests := a.evicted.EstimatedRequests
+	actualRequests := a.evicted.Requests - a.evicted.EstimatedRequests
 
-	for _, record := range a.requestHistory {
+	a.requestHistory.each(func(record *RequestRecord) {
 		totalInputTokens += record.InputTokens
 		totalOutputTokens += record.OutputTokens
 
@@ -514,10 +544,10 @@ func (a *Analytics) calculateTokenUsage() TokenUsageMetrics {
 		} else {
 			actualRequests++
 		}
-	}
+	})
 
 	totalTokens := totalInputTokens + totalOutputTokens
-	totalRequests := int64(len(a.requestHistory))
+	totalRequests := estimatedRequests + actualRequests
 
 	avgInputPerRequest := 0.0
 	if totalRequests > 0 {
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// maxTrackedURLs bounds the per-URL stats map; the least recently busy
+	// URLs are dropped once it is exceeded
+	maxTrackedURLs = 1000
+
+	// urlScoreHalfLife is how long it takes a URL's activity score to halve
+	urlScoreHalfLife = 10 * time.Minute
+
+	// maxErrorKinds bounds the error breakdown; further kinds count as "other"
+	maxErrorKinds = 100
+
+	otherErrorKind = "other"
+)
+
+// requestRing is a fixed-capacity circular buffer of request records
+type requestRing struct {
+	records []RequestRecord
+	start   int
+	count   int
+}
+
+// newRequestRing creates a ring holding at most capacity records
+func newRequestRing(capacity int) *requestRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &requestRing{records: make([]RequestRecord, capacity)}
+}
+
+// push appends record, returning the oldest record when it had to be evicted
+func (r *requestRing) push(record RequestRecord) (RequestRecord, bool) {
+	if r.count < len(r.records) {
+		r.records[(r.start+r.count)%len(r.records)] = record
+		r.count++
+		return RequestRecord{}, false
+	}
+
+	evicted := r.records[r.start]
+	r.records[r.start] = record
+	r.start = (r.start + 1) % len(r.records)
+	return evicted, true
+}
+
+// len returns the number of records held
+func (r *requestRing) len() int {
+	return r.count
+}
+
+// at returns the i-th record, oldest first
+func (r *requestRing) at(i int) *RequestRecord {
+	return &r.records[(r.start+i)%len(r.records)]
+}
+
+// each calls fn for every record, oldest first
+func (r *requestRing) each(fn func(*RequestRecord)) {
+	for i := 0; i < r.count; i++ {
+		fn(r.at(i))
+	}
+}
+
+// HistorySummary rolls up requests that have been evicted from the history
+// window so lifetime totals survive eviction
+type HistorySummary struct {
+	Requests          int64     `json:"requests"`
+	CacheHits         int64     `json:"cache_hits"`
+	CacheMisses       int64     `json:"cache_misses"`
+	Errors            int64     `json:"errors"`
+	HitLatency        int64     `json:"hit_latency"`  // total nanoseconds
+	MissLatency       int64     `json:"miss_latency"` // total nanoseconds
+	InputTokens       int64     `json:"input_tokens"`
+	OutputTokens      int64     `json:"output_tokens"`
+	TotalTokens       int64     `json:"total_tokens"`
+	HitInputTokens    int64     `json:"hit_input_tokens"`
+	HitOutputTokens   int64     `json:"hit_output_tokens"`
+	EstimatedRequests int64     `json:"estimated_requests"`
+	EvictedURLs       int64     `json:"evicted_urls"`
+	Oldest            time.Time `json:"oldest,omitempty"`
+	Newest            time.Time `json:"newest,omitempty"`
+}
+
+// add folds record into the summary
+func (s *HistorySummary) add(record *RequestRecord) {
+	s.Requests++
+	if record.CacheHit {
+		s.CacheHits++
+		s.HitLatency += record.Latency
+		s.HitInputTokens += record.InputTokens
+		s.HitOutputTokens += record.OutputTokens
+	} else {
+		s.CacheMisses++
+		s.MissLatency += record.Latency
+	}
+	if record.Error != "" {
+		s.Errors++
+	}
+	s.InputTokens += record.InputTokens
+	s.OutputTokens += record.OutputTokens
+	s.TotalTokens += record.TotalTokens
+	if record.IsEstimated {
+		s.EstimatedRequests++
+	}
+
+	if s.Oldest.IsZero() || record.Timestamp.Before(s.Oldest) {
+		s.Oldest = record.Timestamp
+	}
+	if record.Timestamp.After(s.Newest) {
+		s.Newest = record.Timestamp
+	}
+}
+
+// HistoryStats describes how much request history analytics retains
+type HistoryStats struct {
+	Retained    int            `json:"retained"`
+	Capacity    int            `json:"capacity"`
+	TrackedURLs int            `json:"tracked_urls"`
+	Evicted     HistorySummary `json:"evicted"`
+}
+
+// touch bumps the URL's activity score, decaying the previous score to at
+func (s *URLStats) touch(at time.Time) {
+	s.score = s.decayedScore(at) + 1
+	s.scoreAt = at
+}
+
+// decayedScore returns the activity score as of at; it halves every
+// urlScoreHalfLife without requests
+func (s *URLStats) decayedScore(at time.Time) float64 {
+	elapsed := at.Sub(s.scoreAt)
+	if elapsed <= 0 {
+		return s.score
+	}
+	return s.score * math.Exp2(-elapsed.Seconds()/urlScoreHalfLife.Seconds())
+}
+
+// pruneURLs drops the URLs with the lowest decayed scores once more than
+// maxTrackedURLs are tracked. It trims an extra tenth so the sort runs once per
+// batch of new URLs rather than on every request
+func (a *Analytics) pruneURLs(now time.Time) {
+	if len(a.urlStats) <= a.maxURLs {
+		return
+	}
+
+	type scored struct {
+		url   string
+		score float64
+	}
+	urls := make([]scored, 0, len(a.urlStats))
+	for url, stats := range a.urlStats {
+		urls = append(urls, scored{url, stats.decayedScore(now)})
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].score < urls[j].score })
+
+	keep := a.maxURLs - a.maxURLs/10
+	for _, u := range urls[:len(urls)-keep] {
+		delete(a.urlStats, u.url)
+		a.evicted.EvictedURLs++
+	}
+}
+
+// recordError counts an error, folding new kinds into "other" once
+// maxErrorKinds distinct kinds are tracked
+func (a *Analytics) recordError(kind string) {
+	if _, exists := a.errorBreakdown[kind]; !exists && len(a.errorBreakdown) >= maxErrorKinds {
+		kind = otherErrorKind
+	}
+	a.errorBreakdown[kind]++
+}
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CacheTagsHeader labels the cache entry a request creates with comma
+// separated tags, shown on /cache/keys. It is neither part of the cache key
+// nor sent upstream
+const CacheTagsHeader = "X-Apilo-Cache-Tags"
+
+// entryUsage counts reads of a cache entry. It is shared by the copies a
+// revalidation makes, so counts survive it
+type entryUsage struct {
+	hits       atomic.Int64
+	lastAccess atomic.Int64 // Unix nanoseconds, zero if never read
+}
+
+// recordHit counts a response served from the entry without the upstream
+func (e *CacheEntry) recordHit() {
+	if e.usage != nil {
+		e.usage.hits.Add(1)
+		e.usage.lastAccess.Store(time.Now().UnixNano())
+	}
+}
+
+// recordAccess notes a read that still went to the upstream, such as a
+// revalidation
+func (e *CacheEntry) recordAccess() {
+	if e.usage != nil {
+		e.usage.lastAccess.Store(time.Now().UnixNano())
+	}
+}
+
+// parseCacheTags returns the tags in a CacheTagsHeader value
+func parseCacheTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// CacheKeyInfo describes one cache entry for debugging
+type CacheKeyInfo struct {
+	Key          string        `json:"key"`
+	Route        string        `json:"route"` // Method and path of the request that created it
+	Host         string        `json:"host"`
+	StatusCode   int           `json:"status_code"`
+	SizeBytes    int64         `json:"size_bytes"`
+	Partial      bool          `json:"partial,omitempty"` // Holds only some byte ranges
+	Age          time.Duration `json:"age"`
+	TTL          time.Duration `json:"ttl"`
+	TTLRemaining time.Duration `json:"ttl_remaining"`
+	Expired      bool          `json:"expired"`
+	Hits         int64         `json:"hits"`
+	LastAccess   *time.Time    `json:"last_access,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+}
+
+// CacheKeysPage is one page of cache entries in key order
+type CacheKeysPage struct {
+	Entries []CacheKeyInfo `json:"entries"`
+	Total   int            `json:"total"` // Entries matching the prefix
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+}
+
+// Keys returns up to limit entries whose keys start with prefix, skipping
+// the first offset in key order
+func (c *Cache) Keys(prefix string, offset, limit int) *CacheKeysPage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	page := &CacheKeysPage{Entries: []CacheKeyInfo{}, Total: len(keys), Offset: offset, Limit: limit}
+	if offset >= len(keys) {
+		return page
+	}
+	keys = keys[offset:min(offset+limit, len(keys))]
+
+	for _, key := range keys {
+		entry := c.data[key]
+		age := time.Since(entry.CachedAt)
+		ttl := c.ttlFor(entry)
+		info := CacheKeyInfo{
+			Key:          key,
+			Route:        entry.Route,
+			Host:         entry.Host,
+			StatusCode:   entry.StatusCode,
+			SizeBytes:    entry.size(),
+			Partial:      entry.Ranges != nil,
+			Age:          age,
+			TTL:          ttl,
+			TTLRemaining: ttl - age,
+			Expired:      age > ttl,
+			Tags:         entry.Tags,
+		}
+		if entry.usage != nil {
+			info.Hits = entry.usage.hits.Load()
+			if accessed := entry.usage.lastAccess.Load(); accessed > 0 {
+				lastAccess := time.Unix(0, accessed)
+				info.LastAccess = &lastAccess
+			}
+		}
+		page.Entries = append(page.Entries, info)
+	}
+	return page
+}
+
+// serveCacheKeys writes a page of optimizer's cache entries, selected by the
+// prefix, offset and limit (default: 100, max: 1000) parameters
+func (ipc *IPCServer) serveCacheKeys(w http.ResponseWriter, r *http.Request, optimizer *Optimizer) {
+	query := r.URL.Query()
+	limit := 100
+	if limitParam := query.Get("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, 1000)
+		}
+	}
+	offset := 0
+	if offsetParam := query.Get("offset"); offsetParam != "" {
+		if parsedOffset, err := strconv.Atoi(offsetParam); err == nil && parsedOffset > 0 {
+			offset = parsedOffset
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(optimizer.cache.Keys(query.Get("prefix"), offset, limit))
+}
+
+// handleCacheKeys lists cache entries with their usage
+func (ipc *IPCServer) handleCacheKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ipc.serveCacheKeys(w, r, ipc.service.optimizer)
+}
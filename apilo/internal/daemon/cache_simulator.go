@@ -0,0 +1,264 @@
+package daemon
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Eviction policies a CacheScenario can simulate. PolicyFIFO and PolicyGDSF
+// evict as the daemon's cache does under each EvictionConfig policy
+const (
+	PolicyFIFO = "fifo"
+	PolicyLRU  = "lru"
+	PolicyLFU  = "lfu"
+	PolicyGDSF = EvictionGDSF
+)
+
+// CacheScenario is a hypothetical cache configuration to replay traffic against
+type CacheScenario struct {
+	TTL        time.Duration `json:"ttl"`
+	CapacityMB float64       `json:"capacity_mb"` // Zero is unbounded
+	Policy     string        `json:"policy"`
+}
+
+// String describes the scenario in one line
+func (s CacheScenario) String() string {
+	capacity := "unbounded"
+	if s.CapacityMB > 0 {
+		capacity = fmt.Sprintf("%gMB", s.CapacityMB)
+	}
+	return fmt.Sprintf("ttl=%v capacity=%s policy=%s", s.TTL, capacity, s.Policy)
+}
+
+// SimulationResult is the projected outcome of one scenario
+type SimulationResult struct {
+	Scenario  CacheScenario `json:"scenario"`
+	Requests  int64         `json:"requests"`
+	Hits      int64         `json:"hits"`
+	HitRatio  float64       `json:"hit_ratio"`
+	Expired   int64         `json:"expired"` // Misses on an entry past its TTL
+	Evictions int64         `json:"evictions"`
+	PeakBytes int64         `json:"peak_bytes"`
+
+	// Upstream time and tokens the hits would not have spent
+	LatencySaved time.Duration `json:"latency_saved"`
+	TokensSaved  int64         `json:"tokens_saved"`
+}
+
+// CacheSimulation replays a recorded request stream against scenarios
+type CacheSimulation struct {
+	Requests       int64              `json:"requests"`
+	ObservedHits   int64              `json:"observed_hits"` // Hits the live cache actually served
+	ObservedRatio  float64            `json:"observed_hit_ratio"`
+	UntrackedBytes int64              `json:"untracked_bytes"` // Requests journaled before sizes were recorded
+	Results        []SimulationResult `json:"results"`
+}
+
+// SimulateCache replays records, oldest first, against each scenario. Requests
+// are told apart by their cache key, or method and URL for records journaled
+// before keys were; failed requests are never cached. A hit saves the
+// upstream latency last observed for its key. gdsf values tokens at the
+// default EvictionConfig token cost
+func SimulateCache(records []RequestRecord, scenarios []CacheScenario) (*CacheSimulation, error) {
+	for _, scenario := range scenarios {
+		switch scenario.Policy {
+		case PolicyFIFO, PolicyLRU, PolicyLFU, PolicyGDSF:
+		default:
+			return nil, fmt.Errorf("unknown eviction policy %q: use fifo, lru, lfu or gdsf", scenario.Policy)
+		}
+	}
+
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b RequestRecord) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	// The upstream latency of a key, from its most recent miss before each
+	// request, so hits in the live cache don't count as cheap fetches
+	upstream := make([]time.Duration, len(records))
+	lastMiss := make(map[string]time.Duration)
+	simulation := &CacheSimulation{Requests: int64(len(records))}
+	for i, record := range records {
+		key := simulationKey(record)
+		if !record.CacheHit && record.Error == "" {
+			lastMiss[key] = time.Duration(record.Latency)
+		}
+		upstream[i] = lastMiss[key]
+		if record.CacheHit {
+			simulation.ObservedHits++
+		}
+		if record.ResponseBytes == 0 && record.Error == "" {
+			simulation.UntrackedBytes++
+		}
+	}
+	if len(records) > 0 {
+		simulation.ObservedRatio = float64(simulation.ObservedHits) / float64(len(records))
+	}
+
+	for _, scenario := range scenarios {
+		simulation.Results = append(simulation.Results, simulateScenario(records, upstream, scenario))
+	}
+	return simulation, nil
+}
+
+// simulationKey identifies a record's request
+func simulationKey(record RequestRecord) string {
+	if record.CacheKey != "" {
+		return record.CacheKey
+	}
+	return record.Method + " " + record.URL
+}
+
+// simulateScenario replays records against one scenario
+func simulateScenario(records []RequestRecord, upstream []time.Duration, scenario CacheScenario) SimulationResult {
+	result := SimulationResult{Scenario: scenario, Requests: int64(len(records))}
+	capacity := int64(scenario.CapacityMB * 1024 * 1024)
+	cache := &simCache{policy: scenario.Policy, entries: make(map[string]*simEntry), eviction: DefaultEvictionConfig()}
+
+	for i, record := range records {
+		key := simulationKey(record)
+		if entry, found := cache.entries[key]; found {
+			if record.Timestamp.Sub(entry.storedAt) <= scenario.TTL {
+				result.Hits++
+				result.LatencySaved += upstream[i]
+				result.TokensSaved += record.TotalTokens
+				cache.touch(entry, i)
+				continue
+			}
+			result.Expired++
+			cache.remove(entry)
+		}
+		if record.Error != "" || (capacity > 0 && record.ResponseBytes > capacity) {
+			continue
+		}
+
+		for capacity > 0 && cache.bytes+record.ResponseBytes > capacity {
+			cache.evict()
+			result.Evictions++
+		}
+		cache.add(&simEntry{
+			key:      key,
+			size:     record.ResponseBytes,
+			cost:     cache.eviction.missCost(upstream[i], &TokenUsage{InputTokens: record.InputTokens, OutputTokens: record.OutputTokens, TotalTokens: record.TotalTokens}),
+			storedAt: record.Timestamp,
+			stored:   i,
+			used:     i,
+		})
+		result.PeakBytes = max(result.PeakBytes, cache.bytes)
+	}
+
+	if result.Requests > 0 {
+		result.HitRatio = float64(result.Hits) / float64(result.Requests)
+	}
+	return result
+}
+
+// simEntry is a simulated cache entry
+type simEntry struct {
+	key      string
+	size     int64
+	storedAt time.Time
+	stored   int // Request index at which it was stored
+	used     int // Request index of its last hit
+	hits     int64
+	index    int // Position in simCache.heap
+
+	// Upstream time and token cost of a miss, and the GDSF priority
+	cost     time.Duration
+	priority float64
+	clock    float64 // simCache.clock when stored
+}
+
+// simCache is a min-heap of entries, the next one to evict first
+type simCache struct {
+	policy  string
+	entries map[string]*simEntry
+	heap    []*simEntry
+	bytes   int64
+
+	// GDSF clock, the priority of the last entry evicted, and how misses
+	// are costed
+	clock    float64
+	eviction EvictionConfig
+}
+
+func (c *simCache) add(entry *simEntry) {
+	entry.clock = c.clock
+	entry.priority = gdsfPriority(entry.clock, 1, entry.cost, entry.size)
+	c.entries[entry.key] = entry
+	c.bytes += entry.size
+	heap.Push(c, entry)
+}
+
+func (c *simCache) remove(entry *simEntry) {
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+	heap.Remove(c, entry.index)
+}
+
+// evict removes the entry the policy evicts first
+func (c *simCache) evict() {
+	victim := c.heap[0]
+	if c.policy == PolicyGDSF {
+		c.clock = victim.priority
+	}
+	c.remove(victim)
+}
+
+// touch records a hit on entry at request i
+func (c *simCache) touch(entry *simEntry, i int) {
+	entry.hits++
+	entry.used = i
+	entry.priority = gdsfPriority(entry.clock, entry.hits+1, entry.cost, entry.size)
+	heap.Fix(c, entry.index)
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface
+func (c *simCache) Len() int { return len(c.heap) }
+
+func (c *simCache) Less(i, j int) bool {
+	a, b := c.heap[i], c.heap[j]
+	switch c.policy {
+	case PolicyLRU:
+		return a.used < b.used
+	case PolicyLFU:
+		if a.hits != b.hits {
+			return a.hits < b.hits
+		}
+		return a.used < b.used
+	case PolicyGDSF:
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.stored < b.stored
+	default:
+		return a.stored < b.stored
+	}
+}
+
+func (c *simCache) Swap(i, j int) {
+	c.heap[i], c.heap[j] = c.heap[j], c.heap[i]
+	c.heap[i].index = i
+	c.heap[j].index = j
+}
+
+func (c *simCache) Push(x any) {
+	entry := x.(*simEntry)
+	entry.index = len(c.heap)
+	c.heap = append(c.heap, entry)
+}
+
+func (c *simCache) Pop() any {
+	last := c.heap[len(c.heap)-1]
+	c.heap = c.heap[:len(c.heap)-1]
+	return last
+}
+
+// ReadJournal calls fn for every entry at or after since in the journal at
+// path and its rotated files, without opening it for writing
+func ReadJournal(path string, since time.Time, fn func(JournalEntry)) (int, error) {
+	j := &Journal{path: expandJournalPath(path)}
+	return j.Replay(since, fn)
+}
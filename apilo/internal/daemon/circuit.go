@@ -0,0 +1,353 @@
+package daemon
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a host's breaker rejects a request
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState represents a breaker state
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreakerConfig configures a breaker
+type CircuitBreakerConfig struct {
+	FailureThreshold    int           `yaml:"failure_threshold" json:"failure_threshold"`           // Consecutive failures before opening
+	OpenTimeout         time.Duration `yaml:"open_timeout" json:"open_timeout"`                     // Time to stay open before probing
+	HalfOpenMaxRequests int           `yaml:"half_open_max_requests" json:"half_open_max_requests"` // Probes allowed while half-open
+}
+
+// DefaultCircuitBreakerConfig returns the default breaker configuration
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold:    5,
+		OpenTimeout:         30 * time.Second,
+		HalfOpenMaxRequests: 1,
+	}
+}
+
+// CircuitBreaker guards calls to a single upstream
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+
+	state               CircuitState
+	consecutiveFailures int
+	halfOpenInFlight    int
+	openedAt            time.Time
+	lastStateChange     time.Time
+	forced              bool // Pinned by an operator; state changes only on Unforce
+	forcedAt            time.Time
+	lastReset           time.Time
+
+	totalRequests int64
+	successes     int64
+	failures      int64
+	rejected      int64
+	stateChanges  int64
+	resets        int64
+
+	mu sync.Mutex
+}
+
+// CircuitInfo is a point-in-time view of a breaker
+type CircuitInfo struct {
+	Key                 string       `json:"key"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastStateChange     time.Time    `json:"last_state_change"`
+	TotalRequests       int64        `json:"total_requests"`
+	Successes           int64        `json:"successes"`
+	Failures            int64        `json:"failures"`
+	Rejected            int64        `json:"rejected"`
+	StateChanges        int64        `json:"state_changes"`
+	Forced              bool         `json:"forced,omitempty"`
+	ForcedAt            *time.Time   `json:"forced_at,omitempty"`
+	Resets              int64        `json:"resets,omitempty"`
+	LastReset           *time.Time   `json:"last_reset,omitempty"`
+}
+
+// CircuitSummary counts breakers by state
+type CircuitSummary struct {
+	Total    int `json:"total"`
+	Closed   int `json:"closed"`
+	Open     int `json:"open"`
+	HalfOpen int `json:"half_open"`
+	Forced   int `json:"forced"`
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		config:          config,
+		state:           CircuitClosed,
+		lastStateChange: time.Now(),
+	}
+}
+
+// Allow reports whether a request may proceed; every allowed request must be
+// followed by exactly one call to Record or Release
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.forced {
+		if cb.state == CircuitOpen {
+			cb.rejected++
+			return ErrCircuitOpen
+		}
+		cb.totalRequests++
+		return nil
+	}
+
+	if cb.state == CircuitOpen {
+		if time.Since(cb.openedAt) < cb.config.OpenTimeout {
+			cb.rejected++
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+	}
+
+	if cb.state == CircuitHalfOpen {
+		if cb.halfOpenInFlight >= cb.config.HalfOpenMaxRequests {
+			cb.rejected++
+			return ErrCircuitOpen
+		}
+		cb.halfOpenInFlight++
+	}
+
+	cb.totalRequests++
+	return nil
+}
+
+// Record reports the outcome of an allowed request
+func (cb *CircuitBreaker) Record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+
+	if success {
+		cb.successes++
+		cb.consecutiveFailures = 0
+		if cb.state == CircuitHalfOpen {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	cb.consecutiveFailures++
+	if cb.forced {
+		return
+	}
+	if cb.state == CircuitHalfOpen || cb.consecutiveFailures >= cb.config.FailureThreshold {
+		cb.setState(CircuitOpen)
+	}
+}
+
+// Release returns an allowed request's slot without recording an outcome, for
+// calls abandoned for reasons unrelated to the upstream
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+}
+
+// Force pins the breaker open or closed until Unforce, e.g. to drain an
+// upstream under maintenance or to keep traffic flowing through a flapping one
+func (cb *CircuitBreaker) Force(state CircuitState) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.setState(state)
+	if !cb.forced {
+		cb.forcedAt = time.Now()
+	}
+	cb.forced = true
+}
+
+// Unforce returns a forced breaker to normal operation from its current state
+func (cb *CircuitBreaker) Unforce() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.forced && cb.state == CircuitOpen {
+		// Probe straight away rather than waiting out a full open timeout
+		cb.openedAt = time.Now().Add(-cb.config.OpenTimeout)
+	}
+	cb.forced = false
+	cb.forcedAt = time.Time{}
+}
+
+// Reset clears the breaker's counters and failure streak. An unforced breaker
+// is closed as well; a forced one keeps its pinned state
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.forced {
+		cb.setState(CircuitClosed)
+	}
+	cb.consecutiveFailures = 0
+	cb.halfOpenInFlight = 0
+	cb.totalRequests = 0
+	cb.successes = 0
+	cb.failures = 0
+	cb.rejected = 0
+	cb.resets++
+	cb.lastReset = time.Now()
+}
+
+// setState transitions the breaker; callers must hold cb.mu
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+
+	cb.state = state
+	cb.lastStateChange = time.Now()
+	cb.stateChanges++
+
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = cb.lastStateChange
+	case CircuitClosed:
+		cb.consecutiveFailures = 0
+	}
+	cb.halfOpenInFlight = 0
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Info returns the breaker's state and counters
+func (cb *CircuitBreaker) Info(key string) CircuitInfo {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	info := CircuitInfo{
+		Key:                 key,
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		LastStateChange:     cb.lastStateChange,
+		TotalRequests:       cb.totalRequests,
+		Successes:           cb.successes,
+		Failures:            cb.failures,
+		Rejected:            cb.rejected,
+		StateChanges:        cb.stateChanges,
+		Forced:              cb.forced,
+		Resets:              cb.resets,
+	}
+	if cb.forced {
+		forcedAt := cb.forcedAt
+		info.ForcedAt = &forcedAt
+	}
+	if !cb.lastReset.IsZero() {
+		lastReset := cb.lastReset
+		info.LastReset = &lastReset
+	}
+	return info
+}
+
+// CircuitRegistry lazily creates one breaker per upstream host
+type CircuitRegistry struct {
+	defaultConfig CircuitBreakerConfig
+	overrides     map[string]CircuitBreakerConfig
+	breakers      map[string]*CircuitBreaker
+	mu            sync.RWMutex
+}
+
+// NewCircuitRegistry creates a registry with shared defaults and per-host overrides
+func NewCircuitRegistry(defaultConfig CircuitBreakerConfig, overrides map[string]CircuitBreakerConfig) *CircuitRegistry {
+	copied := make(map[string]CircuitBreakerConfig, len(overrides))
+	for host, config := range overrides {
+		copied[host] = config
+	}
+
+	return &CircuitRegistry{
+		defaultConfig: defaultConfig,
+		overrides:     copied,
+		breakers:      make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get returns the breaker for host, creating it on first use
+func (r *CircuitRegistry) Get(host string) *CircuitBreaker {
+	r.mu.RLock()
+	cb, exists := r.breakers[host]
+	r.mu.RUnlock()
+	if exists {
+		return cb
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cb, exists := r.breakers[host]; exists {
+		return cb
+	}
+
+	config := r.defaultConfig
+	if override, ok := r.overrides[host]; ok {
+		config = override
+	}
+
+	cb = NewCircuitBreaker(config)
+	r.breakers[host] = cb
+	return cb
+}
+
+// List returns every breaker's info sorted by host
+func (r *CircuitRegistry) List() []CircuitInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]CircuitInfo, 0, len(r.breakers))
+	for host, cb := range r.breakers {
+		infos = append(infos, cb.Info(host))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+
+	return infos
+}
+
+// Summary counts the registry's breakers by state
+func (r *CircuitRegistry) Summary() CircuitSummary {
+	var summary CircuitSummary
+	for _, info := range r.List() {
+		summary.Total++
+		switch info.State {
+		case CircuitOpen:
+			summary.Open++
+		case CircuitHalfOpen:
+			summary.HalfOpen++
+		default:
+			summary.Closed++
+		}
+		if info.Forced {
+			summary.Forced++
+		}
+	}
+	return summary
+}
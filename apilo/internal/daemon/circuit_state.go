@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CircuitSnapshot is the persisted form of a single breaker
+type CircuitSnapshot struct {
+	Key                 string       `json:"key"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            time.Time    `json:"opened_at,omitempty"`
+	Forced              bool         `json:"forced,omitempty"`
+	ForcedAt            time.Time    `json:"forced_at,omitempty"`
+}
+
+// circuitStateFile is the on-disk layout of the breaker state file
+type circuitStateFile struct {
+	SavedAt  time.Time         `json:"saved_at"`
+	Circuits []CircuitSnapshot `json:"circuits"`
+}
+
+// expandCircuitStatePath expands ~ and ensures the parent directory exists
+func expandCircuitStatePath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create circuit state directory: %w", err)
+	}
+
+	return path, nil
+}
+
+// snapshot captures the breaker's persistent state
+func (cb *CircuitBreaker) snapshot(key string) CircuitSnapshot {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return CircuitSnapshot{
+		Key:                 key,
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		OpenedAt:            cb.openedAt,
+		Forced:              cb.forced,
+		ForcedAt:            cb.forcedAt,
+	}
+}
+
+// restore applies a persisted snapshot, keeping only the given fraction of
+// the saved failure count. Half-open breakers come back open with their
+// timeout already elapsed so the next request is a single probe. Breakers
+// forced by an operator stay forced across restarts.
+func (cb *CircuitBreaker) restore(snap CircuitSnapshot, keep float64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutiveFailures = int(float64(snap.ConsecutiveFailures) * keep)
+
+	if snap.Forced {
+		cb.state = snap.State
+		cb.openedAt = snap.OpenedAt
+		cb.forced = true
+		cb.forcedAt = snap.ForcedAt
+		cb.lastStateChange = time.Now()
+		return
+	}
+
+	switch snap.State {
+	case CircuitOpen:
+		cb.state = CircuitOpen
+		cb.openedAt = snap.OpenedAt
+	case CircuitHalfOpen:
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now().Add(-cb.config.OpenTimeout)
+	default:
+		cb.state = CircuitClosed
+	}
+	cb.lastStateChange = time.Now()
+}
+
+// Save writes every breaker's state to path atomically
+func (r *CircuitRegistry) Save(path string) error {
+	path, err := expandCircuitStatePath(path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	state := circuitStateFile{
+		SavedAt:  time.Now(),
+		Circuits: make([]CircuitSnapshot, 0, len(r.breakers)),
+	}
+	for host, cb := range r.breakers {
+		state.Circuits = append(state.Circuits, cb.snapshot(host))
+	}
+	r.mu.RUnlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode circuit state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write circuit state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Restore loads breaker state saved by Save. Failure counters decay linearly
+// with the age of the file and state older than maxAge is ignored entirely,
+// so a long outage of the daemon starts from a clean slate. It returns the
+// number of breakers restored.
+func (r *CircuitRegistry) Restore(path string, maxAge time.Duration) (int, error) {
+	path, err := expandCircuitStatePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read circuit state: %w", err)
+	}
+
+	var state circuitStateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("failed to decode circuit state: %w", err)
+	}
+
+	age := time.Since(state.SavedAt)
+	if maxAge <= 0 || age >= maxAge {
+		return 0, nil
+	}
+	keep := 1 - float64(age)/float64(maxAge)
+
+	for _, snap := range state.Circuits {
+		r.Get(snap.Key).restore(snap, keep)
+	}
+
+	return len(state.Circuits), nil
+}
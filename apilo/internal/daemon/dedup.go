@@ -0,0 +1,296 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DedupConfig collapses identical requests that arrive within Window of the
+// first one, e.g. a client re-sending a POST on retry, into a single upstream call
+type DedupConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	Window  time.Duration `yaml:"window" json:"window"`
+	Methods []string      `yaml:"methods" json:"methods"` // Methods eligible for deduplication
+
+	// Requests carrying KeyHeader are keyed by its value, others by a hash of
+	// the body. ScopeHeaders, e.g. credentials, are always part of the key so
+	// callers never receive each other's responses
+	KeyHeader    string   `yaml:"key_header" json:"key_header"`
+	ScopeHeaders []string `yaml:"scope_headers" json:"scope_headers"`
+
+	MaxEntries int `yaml:"max_entries" json:"max_entries"` // Past this, new requests bypass deduplication
+}
+
+// DefaultDedupConfig returns a disabled 5 second window for unsafe methods
+func DefaultDedupConfig() DedupConfig {
+	return DedupConfig{
+		Window:       5 * time.Second,
+		Methods:      []string{"POST", "PUT", "PATCH", "DELETE"},
+		KeyHeader:    "Idempotency-Key",
+		ScopeHeaders: []string{"Authorization", "x-api-key"},
+		MaxEntries:   10000,
+	}
+}
+
+// DedupStats counts how requests went through the deduplicator
+type DedupStats struct {
+	Enabled  bool  `json:"enabled"`
+	Upstream int64 `json:"upstream"` // First of their key, sent upstream
+	Joined   int64 `json:"joined"`   // Waited for an identical request in flight
+	Replayed int64 `json:"replayed"` // Served a completed response within the window
+	Skipped  int64 `json:"skipped"`  // Streaming requests and those past MaxEntries
+	Entries  int   `json:"entries"`
+}
+
+// dedupEntry is the outcome of the first request for a key
+type dedupEntry struct {
+	done    chan struct{}
+	resp    *OptimizationResponse
+	err     error
+	expires time.Time
+}
+
+// Deduplicator shares the first response for a key with duplicates that
+// arrive while it is in flight or until the window after it arrived ends
+type Deduplicator struct {
+	config  DedupConfig
+	methods map[string]bool
+	entries map[string]*dedupEntry
+	mu      sync.Mutex
+
+	upstream, joined, replayed, skipped atomic.Int64
+}
+
+// NewDeduplicator creates a deduplicator for config
+func NewDeduplicator(config DedupConfig) *Deduplicator {
+	d := &Deduplicator{
+		config:  config,
+		methods: make(map[string]bool, len(config.Methods)),
+		entries: make(map[string]*dedupEntry),
+	}
+	for _, method := range config.Methods {
+		d.methods[strings.ToUpper(method)] = true
+	}
+	return d
+}
+
+// Do returns the response for req, calling fetch only if no identical request
+// is in flight or completed within the window. Duplicates of a request whose
+// caller gave up are retried rather than handed that caller's error
+func (d *Deduplicator) Do(ctx context.Context, req *OptimizationRequest, fetch func() (*OptimizationResponse, error)) (*OptimizationResponse, error) {
+	if !d.methods[strings.ToUpper(req.Method)] {
+		return fetch()
+	}
+	if isStreamingRequest(req) {
+		d.skipped.Add(1)
+		annotate(ctx, "dedup", "skipped")
+		return fetch()
+	}
+	key := d.key(req)
+
+	for attempt := 1; ; attempt++ {
+		entry, leader := d.claim(key)
+		if entry == nil {
+			d.skipped.Add(1)
+			annotate(ctx, "dedup", "skipped")
+			return fetch()
+		}
+		if leader {
+			d.upstream.Add(1)
+			annotate(ctx, "dedup", "upstream")
+			return d.lead(key, entry, fetch)
+		}
+
+		select {
+		case <-entry.done:
+			// Completed entries are only handed out after succeeding
+			d.replayed.Add(1)
+			annotate(ctx, "dedup", "replayed")
+			return deduplicated(entry.resp), nil
+		default:
+		}
+
+		d.joined.Add(1)
+		annotate(ctx, "dedup", "joined")
+		waited := time.Now()
+		resp, retry, err := join(ctx, entry)
+		recordUpstreamTime(ctx, waited)
+		if !retry {
+			return resp, err
+		}
+		annotate(ctx, "dedup.attempts", attempt+1)
+		spanEvent(ctx, "dedup.retry", map[string]interface{}{"reason": "leading request abandoned"})
+	}
+}
+
+// join waits for the in-flight entry and returns its outcome. retry is set
+// when the leading request was abandoned by its own caller
+func join(ctx context.Context, entry *dedupEntry) (*OptimizationResponse, bool, error) {
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	var timeoutErr *PhaseTimeoutError
+	if errors.Is(entry.err, context.Canceled) || (errors.As(entry.err, &timeoutErr) && timeoutErr.Phase == PhaseCaller) {
+		return nil, true, nil
+	}
+	if entry.err != nil {
+		return nil, false, entry.err
+	}
+	return deduplicated(entry.resp), false, nil
+}
+
+// claim returns the live entry for key, or registers a new one the caller
+// leads. It returns nil when the table is full
+func (d *Deduplicator) claim(key string) (*dedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := d.entries[key]; ok {
+		select {
+		case <-entry.done:
+			if now.Before(entry.expires) && entry.err == nil {
+				return entry, false
+			}
+		default:
+			return entry, false
+		}
+		delete(d.entries, key)
+	}
+
+	if d.config.MaxEntries > 0 && len(d.entries) >= d.config.MaxEntries {
+		d.sweep(now)
+		if len(d.entries) >= d.config.MaxEntries {
+			return nil, false
+		}
+	}
+
+	entry := &dedupEntry{done: make(chan struct{}), expires: now.Add(d.config.Window)}
+	d.entries[key] = entry
+	return entry, true
+}
+
+// lead calls fetch for entry and publishes the outcome. Failures and streamed
+// responses are not kept for later duplicates
+func (d *Deduplicator) lead(key string, entry *dedupEntry, fetch func() (*OptimizationResponse, error)) (*OptimizationResponse, error) {
+	resp, err := fetch()
+
+	d.mu.Lock()
+	entry.resp, entry.err = resp, err
+	if err != nil || isStreamingResponse(resp) || !time.Now().Before(entry.expires) {
+		if d.entries[key] == entry {
+			delete(d.entries, key)
+		}
+	}
+	d.mu.Unlock()
+	close(entry.done)
+
+	return resp, err
+}
+
+// sweep drops completed entries past their window; the caller holds d.mu
+func (d *Deduplicator) sweep(now time.Time) {
+	for key, entry := range d.entries {
+		select {
+		case <-entry.done:
+			if !now.Before(entry.expires) {
+				delete(d.entries, key)
+			}
+		default:
+		}
+	}
+}
+
+// key identifies req by method, URL, scope headers and either its idempotency
+// key or its body
+func (d *Deduplicator) key(req *OptimizationRequest) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(strings.ToUpper(req.Method)))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(req.URL))
+	for _, name := range d.config.ScopeHeaders {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(requestHeader(req, name)))
+	}
+
+	hasher.Write([]byte{0})
+	if key := requestHeader(req, d.config.KeyHeader); d.config.KeyHeader != "" && key != "" {
+		hasher.Write([]byte("key:"))
+		hasher.Write([]byte(key))
+	} else {
+		hasher.Write([]byte("body:"))
+		hasher.Write(req.Body)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Stats returns the deduplicator's counters
+func (d *Deduplicator) Stats() DedupStats {
+	d.mu.Lock()
+	entries := len(d.entries)
+	d.mu.Unlock()
+
+	return DedupStats{
+		Enabled:  true,
+		Upstream: d.upstream.Load(),
+		Joined:   d.joined.Load(),
+		Replayed: d.replayed.Load(),
+		Skipped:  d.skipped.Load(),
+		Entries:  entries,
+	}
+}
+
+// deduplicated copies a shared response for one duplicate
+func deduplicated(resp *OptimizationResponse) *OptimizationResponse {
+	copied := *resp
+	copied.Headers = maps.Clone(resp.Headers)
+	copied.Deduplicated = true
+	copied.CacheHit = false
+	copied.Metadata.OptimizationType = "deduplicated"
+	return &copied
+}
+
+// requestHeader looks name up case-insensitively in req's headers
+func requestHeader(req *OptimizationRequest, name string) string {
+	return requestHeaderValue(req.Headers, name)
+}
+
+// isStreamingRequest reports whether req asks for a streamed response, by
+// Accept: text/event-stream or a JSON body with "stream": true
+func isStreamingRequest(req *OptimizationRequest) bool {
+	if strings.Contains(requestHeader(req, "Accept"), "text/event-stream") {
+		return true
+	}
+	if !bytes.Contains(req.Body, []byte(`"stream"`)) {
+		return false
+	}
+	var body struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(req.Body, &body) == nil && body.Stream
+}
+
+// isStreamingResponse reports whether resp was an event stream
+func isStreamingResponse(resp *OptimizationResponse) bool {
+	if resp == nil {
+		return false
+	}
+	for key, value := range resp.Headers {
+		if strings.EqualFold(key, "Content-Type") && strings.HasPrefix(value, "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
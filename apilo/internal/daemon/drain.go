@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// drainLogInterval is how often drain progress is logged
+const drainLogInterval = time.Second
+
+// Shutdown stops the IPC and profile servers from accepting connections and
+// waits for requests in flight until ctx is done, logging progress. Requests
+// still running at the deadline have their connections closed
+func (ipc *IPCServer) Shutdown(ctx context.Context) error {
+	ipc.mu.Lock()
+	servers := append([]*http.Server(nil), ipc.profileServers...)
+	if ipc.server != nil {
+		servers = append(servers, ipc.server)
+	}
+	ipc.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var wg sync.WaitGroup
+		errs := make([]error, len(servers))
+		for i, server := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = server.Shutdown(ctx)
+			}()
+		}
+		wg.Wait()
+		done <- errors.Join(errs...)
+	}()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if ctx.Err() == nil {
+				return err
+			}
+			ipc.service.logger.Warn("Drain deadline reached with %d requests in flight; closing their connections", ipc.inFlight.Load())
+			for _, server := range servers {
+				server.Close()
+			}
+			return err
+		case <-ticker.C:
+			ipc.service.logger.Info("Draining: %d requests in flight", ipc.inFlight.Load())
+		}
+	}
+}
+
+// drain marks the daemon not ready, so load balancers stop routing to it, and
+// waits up to DrainTimeout for the requests it is serving
+func (s *Service) drain() {
+	s.readiness.Set(ReadinessCheckShutdown, false, "draining")
+
+	timeout := s.config.DrainTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	s.logger.Info("Draining %d in-flight requests for up to %v", s.ipcServer.inFlight.Load(), timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.ipcServer.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		s.logger.Warn("Failed to drain servers: %v", err)
+	}
+	s.logger.Info("Drained in %v", time.Since(start).Round(time.Millisecond))
+}
+
+// setupSignalHandling shuts the daemon down gracefully on SIGINT or SIGTERM.
+// A second signal, or a shutdown running past ShutdownTimeout, exits at once
+func (s *Service) setupSignalHandling() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	// Not counted in s.wg: Stop waits on it and is called from here
+	go func() {
+		defer signal.Stop(sigChan)
+
+		stopping := false
+		for {
+			select {
+			case <-s.stopped:
+				return
+			case sig := <-sigChan:
+				s.logger.Info("Received signal: %v", sig)
+
+				switch sig {
+				case syscall.SIGHUP:
+					// Reload configuration
+					s.logger.Info("Reloading configuration...")
+					// TODO: Implement config reload
+				case syscall.SIGINT, syscall.SIGTERM:
+					if stopping {
+						s.forceExit("second signal received")
+					}
+					stopping = true
+
+					timeout := s.config.ShutdownTimeout
+					if timeout <= 0 {
+						timeout = 45 * time.Second
+					}
+					time.AfterFunc(timeout, func() {
+						s.forceExit("shutdown took longer than " + timeout.String())
+					})
+					go s.Stop()
+				}
+			}
+		}
+	}()
+}
+
+// forceExit abandons a graceful shutdown that is stuck or was interrupted
+func (s *Service) forceExit(reason string) {
+	select {
+	case <-s.stopped:
+		return
+	default:
+	}
+
+	s.logger.Error("Forcing exit: %s", reason)
+	s.pidManager.Remove()
+	os.Exit(1)
+}
@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EarlyRefreshConfig enables probabilistic early expiration (XFetch): a hit on
+// an entry near expiry refreshes it in the background with a probability that
+// rises as expiry approaches and with how long the upstream took to fetch it.
+// Callers keep getting the cached copy meanwhile, so popular keys never expire
+// for everyone at once
+type EarlyRefreshConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Scales how early refreshes start; 1 is XFetch's optimum, higher values
+	// refresh sooner
+	Beta float64 `yaml:"beta" json:"beta"`
+
+	Timeout time.Duration `yaml:"timeout" json:"timeout"` // Bound on a background refresh
+}
+
+// DefaultEarlyRefreshConfig returns a disabled refresher with beta 1
+func DefaultEarlyRefreshConfig() EarlyRefreshConfig {
+	return EarlyRefreshConfig{
+		Beta:    1,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// EarlyRefreshStats counts background refreshes
+type EarlyRefreshStats struct {
+	Enabled   bool    `json:"enabled"`
+	Beta      float64 `json:"beta"`
+	Triggered int64   `json:"triggered"` // Hits that started a refresh
+	Coalesced int64   `json:"coalesced"` // Hits chosen while their key was already refreshing
+	Completed int64   `json:"completed"`
+	Failed    int64   `json:"failed"`
+	InFlight  int     `json:"in_flight"`
+}
+
+// earlyRefresher runs at most one background refresh per key
+type earlyRefresher struct {
+	config   EarlyRefreshConfig
+	random   func() float64 // Uniform in [0, 1)
+	inFlight map[string]bool
+	mu       sync.Mutex
+
+	triggered, coalesced, completed, failed atomic.Int64
+}
+
+// newEarlyRefresher creates a refresher for config
+func newEarlyRefresher(config EarlyRefreshConfig) *earlyRefresher {
+	if config.Beta <= 0 {
+		config.Beta = 1
+	}
+	return &earlyRefresher{
+		config:   config,
+		random:   rand.Float64,
+		inFlight: make(map[string]bool),
+	}
+}
+
+// due reports whether a hit on an entry with ttl left should refresh it:
+// XFetch's now - delta*beta*ln(rand) >= expiry, where delta is the entry's
+// fetch time
+func (r *earlyRefresher) due(entry *CacheEntry, remaining time.Duration) bool {
+	if entry.FetchDuration <= 0 {
+		return false
+	}
+	// -ln(rand) is exponentially distributed, so most hits wait until
+	// close to expiry and a few go early
+	gap := -float64(entry.FetchDuration) * r.config.Beta * math.Log(1-r.random())
+	return gap >= float64(remaining)
+}
+
+// start runs refresh in the background unless key is already refreshing
+func (r *earlyRefresher) start(key string, refresh func(ctx context.Context) error) {
+	r.mu.Lock()
+	if r.inFlight[key] {
+		r.mu.Unlock()
+		r.coalesced.Add(1)
+		return
+	}
+	r.inFlight[key] = true
+	r.mu.Unlock()
+	r.triggered.Add(1)
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inFlight, key)
+			r.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(withEarlyRefresh(context.Background()), r.config.Timeout)
+		defer cancel()
+		if err := refresh(ctx); err != nil {
+			r.failed.Add(1)
+			return
+		}
+		r.completed.Add(1)
+	}()
+}
+
+// Stats returns the refresh counters
+func (r *earlyRefresher) Stats() EarlyRefreshStats {
+	r.mu.Lock()
+	inFlight := len(r.inFlight)
+	r.mu.Unlock()
+
+	return EarlyRefreshStats{
+		Enabled:   true,
+		Beta:      r.config.Beta,
+		Triggered: r.triggered.Load(),
+		Coalesced: r.coalesced.Load(),
+		Completed: r.completed.Load(),
+		Failed:    r.failed.Load(),
+		InFlight:  inFlight,
+	}
+}
+
+// earlyRefreshKey marks the context of a background refresh
+type earlyRefreshKey struct{}
+
+// withEarlyRefresh marks ctx as a background refresh, which must skip the
+// fresh cache entry it is replacing
+func withEarlyRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, earlyRefreshKey{}, true)
+}
+
+// isEarlyRefresh reports whether ctx belongs to a background refresh
+func isEarlyRefresh(ctx context.Context) bool {
+	refreshing, _ := ctx.Value(earlyRefreshKey{}).(bool)
+	return refreshing
+}
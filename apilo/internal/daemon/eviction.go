@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Eviction policies of the daemon's cache. EvictionFIFO evicts the oldest
+// entries first; EvictionGDSF (GreedyDual-Size-Frequency) evicts the entries
+// that are cheapest to refetch per byte, weighing how often each was read
+const (
+	EvictionFIFO = PolicyFIFO
+	EvictionGDSF = "gdsf"
+)
+
+// EvictionConfig selects how the cache makes room once it is full. Under
+// gdsf an entry's miss cost is the upstream time it took to fetch plus what
+// recomputing its response would cost: DollarCost for each dollar its
+// observed tokens cost at InputPrice and OutputPrice, or with neither price
+// set, TokenCost for each token
+type EvictionConfig struct {
+	Policy    string        `yaml:"policy" json:"policy"`
+	TokenCost time.Duration `yaml:"token_cost" json:"token_cost"`
+
+	// Dollars per million input and output tokens
+	InputPrice  float64 `yaml:"input_price" json:"input_price"`
+	OutputPrice float64 `yaml:"output_price" json:"output_price"`
+
+	// Upstream time a dollar of recomputation is worth
+	DollarCost time.Duration `yaml:"dollar_cost" json:"dollar_cost"`
+}
+
+// DefaultEvictionConfig returns FIFO eviction. Should gdsf be selected,
+// tokens are priced at $3 and $15 per million in and out, the same as the
+// analytics' savings, and a dollar is worth 10 minutes of upstream time
+func DefaultEvictionConfig() EvictionConfig {
+	return EvictionConfig{
+		Policy:      EvictionFIFO,
+		TokenCost:   5 * time.Millisecond,
+		InputPrice:  3,
+		OutputPrice: 15,
+		DollarCost:  10 * time.Minute,
+	}
+}
+
+// dollars returns what the tokens of usage cost, zero without prices
+func (c EvictionConfig) dollars(usage *TokenUsage) float64 {
+	if usage == nil {
+		return 0
+	}
+	return float64(usage.InputTokens)/1e6*c.InputPrice + float64(usage.OutputTokens)/1e6*c.OutputPrice
+}
+
+// missCost returns the upstream time and recomputation cost of a miss on a
+// response that took fetch and used usage's tokens
+func (c EvictionConfig) missCost(fetch time.Duration, usage *TokenUsage) time.Duration {
+	if usage == nil {
+		return fetch
+	}
+	if c.InputPrice > 0 || c.OutputPrice > 0 {
+		return fetch + time.Duration(c.dollars(usage)*float64(c.DollarCost))
+	}
+	return fetch + time.Duration(usage.TotalTokens)*c.TokenCost
+}
+
+// EvictionStats reports the cache's evictions and the dollars of tokens its
+// entries spare the upstream
+type EvictionStats struct {
+	Policy       string  `json:"policy"`
+	Evictions    int64   `json:"evictions"`
+	EvictedBytes int64   `json:"evicted_bytes"`
+	Inflation    float64 `json:"inflation,omitempty"` // GDSF clock: the priority of the last entry evicted
+
+	// What recomputing the entries in the cache would cost, and what the
+	// evicted entries did
+	ProtectedDollars float64 `json:"protected_dollars"`
+	EvictedDollars   float64 `json:"evicted_dollars"`
+}
+
+// SetEviction changes the policy and prices used for evictions from now on;
+// an unknown policy falls back to fifo
+func (c *Cache) SetEviction(config EvictionConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if config.Policy != EvictionGDSF {
+		config.Policy = EvictionFIFO
+	}
+	c.eviction = config
+}
+
+// priority returns entry's GDSF priority; callers hold c.mu
+func (c *Cache) priority(entry *CacheEntry) float64 {
+	cost := c.eviction.missCost(entry.FetchDuration, entry.TokenUsage)
+	frequency := int64(1)
+	if entry.usage != nil {
+		frequency += entry.usage.hits.Load()
+	}
+	return gdsfPriority(entry.inflation, frequency, cost, entry.size())
+}
+
+// gdsfPriority returns the clock an entry was stored at plus its reads times
+// its miss cost in seconds per byte
+func gdsfPriority(inflation float64, frequency int64, cost time.Duration, size int64) float64 {
+	if cost < time.Millisecond {
+		// Entries without a recorded fetch still cost a round trip
+		cost = time.Millisecond
+	}
+	return inflation + float64(frequency)*cost.Seconds()/float64(max(size, 1))
+}
+
+// evict removes entries in the order of the eviction policy until
+// neededSpace bytes are freed; callers hold c.mu
+func (c *Cache) evict(neededSpace int64) {
+	type candidate struct {
+		key      string
+		cachedAt time.Time
+		priority float64
+	}
+
+	candidates := make([]candidate, 0, len(c.data))
+	for key, entry := range c.data {
+		next := candidate{key: key, cachedAt: entry.CachedAt}
+		if c.eviction.Policy == EvictionGDSF {
+			next.priority = c.priority(entry)
+		}
+		candidates = append(candidates, next)
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if order := cmp.Compare(a.priority, b.priority); order != 0 {
+			return order
+		}
+		return a.cachedAt.Compare(b.cachedAt)
+	})
+
+	freedSpace := int64(0)
+	for _, candidate := range candidates {
+		if freedSpace >= neededSpace {
+			break
+		}
+
+		entry := c.data[candidate.key]
+		entrySize := entry.size()
+		delete(c.data, candidate.key)
+		c.currentMemory -= entrySize
+		freedSpace += entrySize
+		c.evictions++
+		c.evictedBytes += entrySize
+		c.evictedDollars += c.eviction.dollars(entry.TokenUsage)
+		if c.eviction.Policy == EvictionGDSF {
+			// Later entries start from the evicted priority, so entries
+			// that stop being read eventually age out
+			c.inflation = candidate.priority
+		}
+		c.logger.Debug("Cache eviction - Key: %s, Size: %d bytes", candidate.key[:min(16, len(candidate.key))], entrySize)
+	}
+}
+
+// evictionStats returns the eviction counters; callers hold c.mu
+func (c *Cache) evictionStats() EvictionStats {
+	stats := EvictionStats{
+		Policy:       c.eviction.Policy,
+		Evictions:    c.evictions,
+		EvictedBytes: c.evictedBytes,
+
+		EvictedDollars: c.evictedDollars,
+	}
+	for _, entry := range c.data {
+		stats.ProtectedDollars += c.eviction.dollars(entry.TokenUsage)
+	}
+	if c.eviction.Policy == EvictionGDSF {
+		stats.Inflation = c.inflation
+	}
+	return stats
+}
@@ -4,28 +4,47 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //go:embed dashboard.html
 var dashboardHTML string
 
-// IPCServer handles inter-process communication via HTTP
+// IPCServer handles inter-process communication via HTTP. Its servers
+// listen on sockets passed by systemd socket activation when there are any,
+// the IPC server's named "ipc" and each profile server's by its profile name
 type IPCServer struct {
-	port    int
-	service *Service
-	server  *http.Server
+	host      string
+	port      int
+	reusePort bool
+	service   *Service
+	server    *http.Server
+
+	// Per-profile endpoints, also served on profiles' dedicated ports
+	profileHandlers map[string]http.Handler
+	profileServers  []*http.Server
+
+	// Guards server and profileServers, which Shutdown reads while Start runs
+	mu sync.Mutex
+
+	// Requests being served on any of the servers, reported while draining
+	inFlight atomic.Int64
 }
 
 // NewIPCServer creates a new IPC server
 func NewIPCServer(port int, service *Service) *IPCServer {
 	return &IPCServer{
-		port:    port,
-		service: service,
+		host:      service.config.ListenAddress,
+		port:      port,
+		reusePort: service.config.ReusePort,
+		service:   service,
 	}
 }
 
@@ -39,38 +58,85 @@ func (ipc *IPCServer) Start(ctx context.Context) error {
 	mux.HandleFunc("/optimize", ipc.handleOptimize)
 	mux.HandleFunc("/status", ipc.handleStatus)
 	mux.HandleFunc("/metrics", ipc.handleMetrics)
+	mux.HandleFunc("/metrics/sli", ipc.handleSLIMetrics)
 	mux.HandleFunc("/analytics", ipc.handleAnalytics)
 	mux.HandleFunc("/requests", ipc.handleRequests)
 	mux.HandleFunc("/cache/stats", ipc.handleCacheStats)
+	mux.HandleFunc("/cache/keys", ipc.handleCacheKeys)
+	mux.HandleFunc("/cache/adaptive-ttl", ipc.handleAdaptiveTTL)
 	mux.HandleFunc("/cache/invalidate", ipc.handleCacheInvalidate)
+	mux.HandleFunc("/circuits", ipc.handleCircuits)
+	mux.HandleFunc("/shedding", ipc.handleShedding)
+	mux.HandleFunc("/ratelimits", ipc.handleRateLimits)
+	mux.HandleFunc("/dedup", ipc.handleDedup)
+	mux.HandleFunc("/overhead", ipc.handleOverhead)
+	mux.HandleFunc("/journal", ipc.handleJournal)
+	mux.HandleFunc("/journal/sample", ipc.handleJournalSample)
+	mux.HandleFunc("/access-log", ipc.handleAccessLog)
+	mux.HandleFunc("/peers", ipc.handlePeers)
+	mux.HandleFunc("/sampling", ipc.handleSampling)
+	mux.HandleFunc("/traces", ipc.handleTraces)
+	mux.HandleFunc("/leaks", ipc.handleLeaks)
+	mux.HandleFunc("/schedules", ipc.handleSchedules)
+	mux.HandleFunc("/mirror", ipc.handleMirror)
+	mux.HandleFunc("/mirror/diffs", ipc.handleMirrorDiffs)
 	mux.HandleFunc("/config", ipc.handleConfig)
 	mux.HandleFunc("/health", ipc.handleHealth)
+	mux.HandleFunc("/health/live", ipc.handleLive)
+	mux.HandleFunc("/health/ready", ipc.handleReady)
 	mux.HandleFunc("/internal/record", ipc.handleInternalRecord)
+	mux.HandleFunc("/profiles", ipc.handleProfiles)
+	mux.HandleFunc(ProfilePathPrefix, ipc.handleProfile)
+	mux.HandleFunc("/admin/settings", ipc.requireAdmin(ipc.handleAdminSettings))
+	mux.HandleFunc("/admin/cache", ipc.requireAdmin(ipc.handleAdminCache))
+	mux.HandleFunc("/admin/cache/ttl", ipc.requireAdmin(ipc.handleAdminCacheTTL))
+	mux.HandleFunc("/admin/circuits", ipc.requireAdmin(ipc.handleAdminCircuits))
+	mux.HandleFunc("/admin/log-level", ipc.requireAdmin(ipc.handleAdminLogLevel))
+	mux.HandleFunc("/admin/sampling", ipc.requireAdmin(ipc.handleAdminSampling))
+	mux.HandleFunc("/admin/audit", ipc.requireAdmin(ipc.handleAdminAudit))
+
+	// Profiles are also served here under their path prefix
+	budgets := []PhaseTimeouts{ipc.service.config.Timeouts}
+	ipc.profileHandlers = make(map[string]http.Handler)
+	for _, profile := range ipc.service.profiles.List() {
+		ipc.profileHandlers[profile.Name()] = ipc.profileHandler(profile)
+		budgets = append(budgets, profile.optimizer.config.Timeouts)
+	}
 
-	ipc.server = &http.Server{
-		Addr:         fmt.Sprintf("localhost:%d", ipc.port),
+	listener, err := listen("ipc", ipc.host, ipc.port, ipc.reusePort)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:         listener.Addr().String(),
 		Handler:      ipc.loggingMiddleware(mux),
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: writeTimeout(budgets...),
 		IdleTimeout:  60 * time.Second,
 	}
+	ipc.mu.Lock()
+	ipc.server = server
+	ipc.mu.Unlock()
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
 	go func() {
-		ipc.service.logger.Info("IPC server listening on %s", ipc.server.Addr)
-		if err := ipc.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		ipc.service.logger.Info("IPC server listening on %s", server.Addr)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
 
-	// Wait for context cancellation or error
+	ipc.startProfileServers()
+
+	// Wait for context cancellation or error. Service.Stop normally drains
+	// the servers first, leaving nothing for this Shutdown to wait on
 	select {
 	case <-ctx.Done():
 		ipc.service.logger.Info("Shutting down IPC server...")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		return ipc.server.Shutdown(shutdownCtx)
+		return ipc.Shutdown(shutdownCtx)
 	case err := <-errChan:
 		return err
 	}
@@ -104,19 +170,46 @@ func (ipc *IPCServer) handleRoot(w http.ResponseWriter, r *http.Request) {
 			"GET /":                          "API documentation (this page)",
 			"GET /dashboard":                 "Web dashboard (GUI)",
 			"GET /health":                    "Health check",
+			"GET /health/live":               "Liveness probe, OK while the daemon runs",
+			"GET /health/ready":              "Readiness probe, 503 until config, warmup and upstream checks pass",
 			"GET /status":                    "Daemon status and metrics",
 			"GET /metrics":                   "Performance metrics (JSON)",
+			"GET /metrics/sli":               "Per-endpoint SLI good/total counters (Prometheus)",
 			"GET /analytics":                 "Advanced analytics data (JSON)",
 			"GET /analytics?limit=100":       "Analytics with custom request limit",
 			"GET /requests":                  "Request history (default: 100, max: 1000)",
 			"GET /requests?limit=100":        "Paginated request history",
 			"GET /cache/stats":               "Cache statistics (JSON)",
 			"GET /cache/stats?format=visual": "Cache visualization (ASCII)",
+			"GET /cache/keys?prefix=P":       "Cache entries with hits, age and tags, paginated by offset and limit (default: 100, max: 1000)",
+			"GET /cache/adaptive-ttl":        "TTLs learned per route from content change frequency",
 			"POST /cache/invalidate":         "Clear cache",
+			"GET /circuits":                  "Per-host circuit breaker states",
+			"GET /mirror":                    "Primary vs shadow latency, status and body comparison",
+			"GET /mirror/diffs?limit=N":      "Sampled primary/shadow body mismatches",
+			"GET /shedding":                  "Priority queue depths and shed rates",
+			"GET /ratelimits":                "Upstream token bucket levels",
+			"GET /dedup":                     "Duplicate request counters",
+			"GET /overhead":                  "Time the daemon adds to requests beyond the upstream, by phase",
+			"GET /journal":                   "Request journal files and write counters",
+			"GET /sampling":                  "Sampling decisions and unsampled latency outliers",
+			"GET /traces?trace_id=ID":        "Annotated request spans, newest first (default: 100)",
+			"GET /journal/sample?n=N":        "Random sample of journaled requests, optionally &since=1h (default: 100 over 24h)",
+			"GET /access-log":                "Access log file and write counters",
+			"GET /peers":                     "Replicas gossiped with and event counters",
 			"GET /config":                    "Get daemon configuration",
 			"PUT /config":                    "Update daemon configuration",
 			"POST /optimize":                 "Optimize an API request",
 			"POST /internal/record":          "Record proxy-intercepted request (internal use)",
+			"GET /profiles":                  "Upstream profiles and their traffic",
+			"ANY /profiles/{name}/...":       "Per-profile optimize, analytics, cache, circuits, shards and dashboard",
+			"GET /admin/settings":            "Runtime-adjustable settings (admin token)",
+			"PUT /admin/cache":               "Enable/disable caching or change the default TTL (admin token)",
+			"PUT|DELETE /admin/cache/ttl":    "Set or remove a per-host TTL override (admin token)",
+			"POST /admin/circuits":           "Force a breaker open/closed, release it or reset its counters (admin token)",
+			"PUT /admin/log-level":           "Change the log level (admin token)",
+			"PUT /admin/sampling":            "Change log and analytics sample rates (admin token)",
+			"GET /admin/audit":               "Audit log of admin changes (admin token)",
 		},
 		"features": []string{
 			"Persistent background process",
@@ -153,20 +246,109 @@ func (ipc *IPCServer) handleOptimize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ipc.serveOptimize(w, r, "", ipc.service.OptimizeContext)
+}
+
+// serveOptimize decodes an optimization request, applies admission control and
+// runs it through optimize, writing the outcome to the access log under the
+// profile's name
+func (ipc *IPCServer) serveOptimize(w http.ResponseWriter, r *http.Request, profile string, optimize func(context.Context, *OptimizationRequest) (*OptimizationResponse, error)) {
+	start := time.Now()
 	var req OptimizationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	resp, err := ipc.service.Optimize(&req)
+	// Time waiting for admission is reported as queue time, not latency
+	ctx, queue := withQueueClock(r.Context())
+	if admission := ipc.service.admission; admission != nil {
+		priority := admission.Classify(r, &req)
+		queued := time.Now()
+		release, err := admission.Acquire(ctx, priority)
+		recordQueueTime(ctx, queued)
+		if err != nil {
+			ipc.service.metrics.IncrementShed()
+			ipc.service.logger.Debug("Shed %s priority request to %s: %v", priority, req.URL, err)
+
+			retryAfter := int(admission.RetryAfter().Round(time.Second) / time.Second)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Too many requests, retry later", http.StatusTooManyRequests)
+			ipc.logAccess(r, start, profile, &req, nil, http.StatusTooManyRequests, err)
+			return
+		}
+		defer release()
+	}
+
+	// What the request took beyond the upstream and queueing is the daemon's
+	// own overhead, measured once the response is written and logged
+	ctx, overhead := withOverheadClock(ctx)
+	defer func() { ipc.service.overhead.Record(overhead.measure(time.Since(start), queue.Queued())) }()
+
+	// The caller's deadline travels with r.Context() to the upstream request
+	resp, err := optimize(ctx, &req)
+	var timeoutErr *PhaseTimeoutError
+	if errors.As(err, &timeoutErr) {
+		w.Header().Set("X-Apilo-Timeout-Phase", string(timeoutErr.Phase))
+		http.Error(w, fmt.Sprintf("Optimization failed: %v", err), http.StatusGatewayTimeout)
+		ipc.logAccess(r, start, profile, &req, nil, http.StatusGatewayTimeout, err)
+		return
+	}
+	if errors.Is(err, ErrRateLimited) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, fmt.Sprintf("Optimization failed: %v", err), http.StatusTooManyRequests)
+		ipc.logAccess(r, start, profile, &req, nil, http.StatusTooManyRequests, err)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Optimization failed: %v", err), http.StatusInternalServerError)
+		ipc.logAccess(r, start, profile, &req, nil, http.StatusInternalServerError, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server-Timing", overhead.serverTiming(time.Since(start), queue.Queued()))
 	json.NewEncoder(w).Encode(resp)
+	ipc.logAccess(r, start, profile, &req, resp, resp.StatusCode, nil)
+}
+
+// logAccess writes a request served through /optimize to the access log.
+// Failed requests are logged with the status the daemon answered, served
+// ones with the upstream status
+func (ipc *IPCServer) logAccess(r *http.Request, start time.Time, profile string, req *OptimizationRequest, resp *OptimizationResponse, status int, err error) {
+	accessLog := ipc.service.accessLog
+	if accessLog == nil {
+		return
+	}
+
+	entry := AccessLogEntry{
+		Time:       start,
+		RemoteAddr: r.RemoteAddr,
+		Method:     req.Method,
+		URL:        req.URL,
+		Protocol:   r.Proto,
+		Status:     status,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Latency:    time.Since(start),
+		Cache:      accessLogCacheStatus(resp),
+		Upstream:   upstreamHost(req.URL),
+		Profile:    profile,
+	}
+	if entry.Method == "" {
+		entry.Method = http.MethodGet
+	}
+	if resp != nil {
+		entry.Bytes = int64(len(resp.Body))
+		entry.Error = resp.Error
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	accessLog.Log(entry)
 }
 
 // handleStatus returns daemon status
@@ -195,6 +377,17 @@ func (ipc *IPCServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// handleSLIMetrics exports per-endpoint SLI counters in the Prometheus text format
+func (ipc *IPCServer) handleSLIMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	ipc.service.sli.WritePrometheus(w)
+}
+
 // handleAnalytics returns advanced analytics data
 func (ipc *IPCServer) handleAnalytics(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -202,6 +395,11 @@ func (ipc *IPCServer) handleAnalytics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ipc.serveAnalytics(w, r, ipc.service.analytics)
+}
+
+// serveAnalytics writes a snapshot of analytics
+func (ipc *IPCServer) serveAnalytics(w http.ResponseWriter, r *http.Request, analytics *Analytics) {
 	// Parse limit parameter (default: 20, max: 1000)
 	limit := 20
 	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
@@ -213,7 +411,7 @@ func (ipc *IPCServer) handleAnalytics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	snapshot := ipc.service.analytics.GetSnapshotWithLimit(limit)
+	snapshot := analytics.GetSnapshotWithLimit(limit)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(snapshot)
@@ -226,6 +424,11 @@ func (ipc *IPCServer) handleRequests(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ipc.serveRequests(w, r, ipc.service.analytics)
+}
+
+// serveRequests writes the recent request history of analytics
+func (ipc *IPCServer) serveRequests(w http.ResponseWriter, r *http.Request, analytics *Analytics) {
 	// Parse pagination parameters
 	limit := 100
 	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
@@ -238,7 +441,7 @@ func (ipc *IPCServer) handleRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get snapshot with specified limit
-	snapshot := ipc.service.analytics.GetSnapshotWithLimit(limit)
+	snapshot := analytics.GetSnapshotWithLimit(limit)
 
 	// Return just the requests with metadata
 	response := map[string]interface{}{
@@ -288,7 +491,14 @@ func (ipc *IPCServer) handleCacheStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats := ipc.service.optimizer.cache.GetStats()
+	ipc.serveCacheStats(w, r, ipc.service.optimizer)
+}
+
+// serveCacheStats writes the statistics of optimizer's cache
+func (ipc *IPCServer) serveCacheStats(w http.ResponseWriter, r *http.Request, optimizer *Optimizer) {
+	stats := optimizer.cache.GetStats()
+	stats.EarlyRefresh = optimizer.EarlyRefreshStats()
+	stats.Ranges = optimizer.RangeCacheStats()
 
 	// Check if visual format is requested
 	format := r.URL.Query().Get("format")
@@ -317,7 +527,24 @@ func (ipc *IPCServer) renderCacheVisual(stats *CacheStats) string {
 	sb.WriteString(fmt.Sprintf("   Entries:      %d\n", stats.Entries))
 	sb.WriteString(fmt.Sprintf("   Memory Used:  %.2f MB / %.2f MB (%.1f%%)\n",
 		stats.MemoryUsedMB, stats.MemoryLimitMB, stats.MemoryPercent))
-	sb.WriteString(fmt.Sprintf("   Default TTL:  %v\n\n", stats.DefaultTTL))
+	sb.WriteString(fmt.Sprintf("   Default TTL:  %v\n", stats.DefaultTTL))
+	if early := stats.EarlyRefresh; early.Enabled {
+		sb.WriteString(fmt.Sprintf("   Early Refresh: %d triggered, %d completed, %d failed, %d coalesced (beta %.1f)\n",
+			early.Triggered, early.Completed, early.Failed, early.Coalesced, early.Beta))
+	}
+	if ranges := stats.Ranges; ranges.Enabled {
+		sb.WriteString(fmt.Sprintf("   Ranges:       %d hits, %d misses, %d assembled, %d partial (%s)\n",
+			ranges.Hits, ranges.Misses, ranges.Assembled, ranges.PartialObjects, formatBytes(ranges.PartialBytes)))
+	}
+	if eviction := stats.Eviction; eviction.Evictions > 0 {
+		sb.WriteString(fmt.Sprintf("   Evictions:    %d (%s, %s)\n",
+			eviction.Evictions, formatBytes(eviction.EvictedBytes), eviction.Policy))
+	}
+	if eviction := stats.Eviction; eviction.ProtectedDollars > 0 || eviction.EvictedDollars > 0 {
+		sb.WriteString(fmt.Sprintf("   Protected:    $%.2f of tokens cached ($%.2f evicted)\n",
+			eviction.ProtectedDollars, eviction.EvictedDollars))
+	}
+	sb.WriteString("\n")
 
 	// Memory usage bar
 	sb.WriteString("💾 Memory Usage:\n")
@@ -408,12 +635,284 @@ func (ipc *IPCServer) handleCacheInvalidate(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	ipc.service.optimizer.InvalidateCache()
+	ipc.service.InvalidateCache("")
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "cache invalidated"})
 }
 
+// handleCircuits lists each upstream circuit breaker's state and metrics
+func (ipc *IPCServer) handleCircuits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ipc.serveCircuits(w, ipc.service.optimizer)
+}
+
+// serveCircuits lists the state of optimizer's circuit breakers
+func (ipc *IPCServer) serveCircuits(w http.ResponseWriter, optimizer *Optimizer) {
+	circuits := []CircuitInfo{}
+	var summary CircuitSummary
+	if registry := optimizer.Circuits(); registry != nil {
+		circuits = registry.List()
+		summary = registry.Summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":  optimizer.Circuits() != nil,
+		"summary":  summary,
+		"circuits": circuits,
+	})
+}
+
+// handleMirror compares primary and shadow traffic when mirroring is enabled
+func (ipc *IPCServer) handleMirror(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ipc.service.MirrorStats(nil))
+}
+
+// handleMirrorDiffs returns sampled body mismatches between primary and shadow
+func (ipc *IPCServer) handleMirrorDiffs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ipc.serveMirrorDiffs(w, r, ipc.service.Mirror(nil))
+}
+
+// serveMirrorDiffs writes up to ?limit= sampled diffs of mirror, newest first
+func (ipc *IPCServer) serveMirrorDiffs(w http.ResponseWriter, r *http.Request, mirror *Mirror) {
+	diffs := []MirrorDiff{}
+	if mirror != nil {
+		limit := 0
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			if parsed, err := strconv.Atoi(limitParam); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		diffs = mirror.Diffs(limit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"diffs": diffs,
+	})
+}
+
+// handleShedding returns admission control queue depths and shed rates
+func (ipc *IPCServer) handleShedding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := SheddingStats{Priorities: []PrioritySheddingStats{}}
+	if admission := ipc.service.admission; admission != nil {
+		stats = admission.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleRateLimits returns the fill level of every upstream token bucket
+func (ipc *IPCServer) handleRateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ipc.serveRateLimits(w, ipc.service.optimizer)
+}
+
+// serveRateLimits lists the fill level of optimizer's token buckets
+func (ipc *IPCServer) serveRateLimits(w http.ResponseWriter, optimizer *Optimizer) {
+	buckets := []BucketLevel{}
+	limiter := optimizer.RateLimiter()
+	if limiter != nil {
+		buckets = limiter.Levels()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": limiter != nil,
+		"buckets": buckets,
+	})
+}
+
+// handleOverhead reports the time the daemon adds to requests served through
+// /optimize
+func (ipc *IPCServer) handleOverhead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ipc.service.overhead.Stats())
+}
+
+// handleDedup returns the request deduplication counters
+func (ipc *IPCServer) handleDedup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ipc.serveDedup(w, ipc.service.optimizer)
+}
+
+// serveDedup reports optimizer's deduplication counters
+func (ipc *IPCServer) serveDedup(w http.ResponseWriter, optimizer *Optimizer) {
+	stats := DedupStats{}
+	if dedup := optimizer.Dedup(); dedup != nil {
+		stats = dedup.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleJournal returns the request journal's file and write counters
+func (ipc *IPCServer) handleJournal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := JournalStats{}
+	if journal := ipc.service.journal; journal != nil {
+		stats = journal.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleAccessLog returns the access log's file and write counters
+func (ipc *IPCServer) handleAccessLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := AccessLogStats{}
+	if accessLog := ipc.service.accessLog; accessLog != nil {
+		stats = accessLog.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handlePeers returns the replicas gossiped with and the event counters
+func (ipc *IPCServer) handlePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := PeerStats{Peers: []PeerInfo{}}
+	if peers := ipc.service.peers; peers != nil {
+		stats = peers.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleSampling returns the sampler's counters and outlier reservoir
+func (ipc *IPCServer) handleSampling(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ipc.service.sampler.Stats())
+}
+
+// handleTraces returns recent request spans, optionally those of one trace
+func (ipc *IPCServer) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tracer := ipc.service.tracer
+	if tracer == nil {
+		http.Error(w, "Tracing is disabled", http.StatusNotFound)
+		return
+	}
+
+	// Parse limit parameter (default: 100, max: 1000)
+	limit := 100
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, 1000)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tracer.Recent(limit, r.URL.Query().Get("trace_id")))
+}
+
+// handleJournalSample returns a uniform random sample of journaled requests,
+// e.g. for capacity planning
+func (ipc *IPCServer) handleJournalSample(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	journal := ipc.service.journal
+	if journal == nil {
+		http.Error(w, "Request journal is disabled", http.StatusNotFound)
+		return
+	}
+
+	// Parse n (default: 100, max: 10000) and since (default: 24h)
+	n := 100
+	if nParam := r.URL.Query().Get("n"); nParam != "" {
+		parsed, err := strconv.Atoi(nParam)
+		if err != nil || parsed < 1 {
+			http.Error(w, fmt.Sprintf("Invalid n: %q", nParam), http.StatusBadRequest)
+			return
+		}
+		n = min(parsed, 10000)
+	}
+	window := 24 * time.Hour
+	if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
+		parsed, err := time.ParseDuration(sinceParam)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid since: %q", sinceParam), http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	sample, err := journal.Sample(n, time.Now().Add(-window))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(sample),
+		"since":   window.String(),
+		"entries": sample,
+	})
+}
+
 // handleHealth returns health check status
 func (ipc *IPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -431,6 +930,33 @@ func (ipc *IPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(health)
 }
 
+// handleLive answers liveness probes; a daemon that can answer is alive
+func (ipc *IPCServer) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// handleReady answers readiness probes with every check, and 503 until all
+// pass so traffic is not routed to the daemon yet
+func (ipc *IPCServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := ipc.service.readiness.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
 // handleInternalRecord receives request records from the proxy
 func (ipc *IPCServer) handleInternalRecord(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -450,7 +976,11 @@ func (ipc *IPCServer) handleInternalRecord(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Record in analytics
-	ipc.service.analytics.RecordRequest(record)
+	sampler := ipc.service.sampler
+	kept := sampler.Keep(sampler.Head(record.URL, nil), record)
+	if kept {
+		ipc.service.analytics.RecordRequest(record)
+	}
 
 	// Update metrics
 	ipc.service.metrics.IncrementRequests()
@@ -460,6 +990,28 @@ func (ipc *IPCServer) handleInternalRecord(w http.ResponseWriter, r *http.Reques
 		ipc.service.metrics.IncrementCacheMisses()
 	}
 	ipc.service.metrics.RecordLatency(time.Duration(record.Latency))
+	ipc.service.sli.Record("", record)
+	if journal := ipc.service.journal; journal != nil && kept {
+		journal.Append("", record)
+	}
+	if accessLog := ipc.service.accessLog; accessLog != nil {
+		cache := "MISS"
+		if record.CacheHit {
+			cache = "HIT"
+		}
+		accessLog.Log(AccessLogEntry{
+			Time:     record.Timestamp,
+			Method:   record.Method,
+			URL:      record.URL,
+			Protocol: "HTTP/1.1",
+			Status:   record.StatusCode,
+			Bytes:    record.ResponseBytes,
+			Latency:  time.Duration(record.Latency),
+			Cache:    cache,
+			Upstream: upstreamHost(record.URL),
+			Error:    record.Error,
+		})
+	}
 
 	// Track token usage if available
 	if record.TotalTokens > 0 {
@@ -474,6 +1026,8 @@ func (ipc *IPCServer) handleInternalRecord(w http.ResponseWriter, r *http.Reques
 func (ipc *IPCServer) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		ipc.inFlight.Add(1)
+		defer ipc.inFlight.Add(-1)
 
 		// Create a response writer wrapper to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requireMethod rejects requests to handler made with any other method
+func requireMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// profileHandler serves the endpoints of one profile, scoped to its cache,
+// breakers, limits and stats. It is mounted under the profile's path prefix on
+// the main port and at the root of the profile's own port
+func (ipc *IPCServer) profileHandler(profile *Profile) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		ipc.handleDashboard(w, r)
+	})
+	mux.HandleFunc("/dashboard", ipc.handleDashboard)
+	mux.HandleFunc("/health", ipc.handleHealth)
+	mux.HandleFunc("/health/live", ipc.handleLive)
+	mux.HandleFunc("/health/ready", ipc.handleReady)
+	mux.HandleFunc("/optimize", requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveOptimize(w, r, profile.Name(), func(ctx context.Context, req *OptimizationRequest) (*OptimizationResponse, error) {
+			return ipc.service.OptimizeProfile(ctx, profile, req)
+		})
+	}))
+	mux.HandleFunc("/status", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ipc.service.GetProfileStatus(profile))
+	}))
+	mux.HandleFunc("/metrics", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile.metrics.GetStats())
+	}))
+	mux.HandleFunc("/analytics", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveAnalytics(w, r, profile.analytics)
+	}))
+	mux.HandleFunc("/requests", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveRequests(w, r, profile.analytics)
+	}))
+	mux.HandleFunc("/cache/stats", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveCacheStats(w, r, profile.optimizer)
+	}))
+	mux.HandleFunc("/cache/keys", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveCacheKeys(w, r, profile.optimizer)
+	}))
+	mux.HandleFunc("/cache/adaptive-ttl", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveAdaptiveTTL(w, profile.optimizer)
+	}))
+	mux.HandleFunc("/cache/invalidate", requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		ipc.service.InvalidateCache(profile.Name())
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "cache invalidated", "profile": profile.Name()})
+	}))
+	mux.HandleFunc("/circuits", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveCircuits(w, profile.optimizer)
+	}))
+	mux.HandleFunc("/shards", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		shards := profile.ShardStats()
+		enabled := shards != nil
+		if !enabled {
+			shards = []ShardStats{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled": enabled,
+			"shards":  shards,
+		})
+	}))
+	mux.HandleFunc("/ratelimits", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveRateLimits(w, profile.optimizer)
+	}))
+	mux.HandleFunc("/dedup", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveDedup(w, profile.optimizer)
+	}))
+	mux.HandleFunc("/mirror", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ipc.service.MirrorStats(profile))
+	}))
+	mux.HandleFunc("/mirror/diffs", requireMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		ipc.serveMirrorDiffs(w, r, profile.mirror)
+	}))
+
+	return mux
+}
+
+// handleProfiles lists the upstream profiles with a summary of their traffic
+func (ipc *IPCServer) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles": ipc.service.profiles.Infos(),
+	})
+}
+
+// handleProfile routes /profiles/{name}/... to the named profile's endpoints
+func (ipc *IPCServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, ProfilePathPrefix), "/")
+	handler, ok := ipc.profileHandlers[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown profile %q", name), http.StatusNotFound)
+		return
+	}
+
+	prefix := ProfilePathPrefix + name
+	if rest == "" && !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		return
+	}
+	http.StripPrefix(prefix, handler).ServeHTTP(w, r)
+}
+
+// startProfileServers serves every profile that has a dedicated port. Servers
+// that fail to listen are logged rather than taking the daemon down
+func (ipc *IPCServer) startProfileServers() {
+	for _, profile := range ipc.service.profiles.List() {
+		if profile.config.Port == 0 {
+			continue
+		}
+
+		listener, err := listen(profile.Name(), ipc.host, profile.config.Port, ipc.reusePort)
+		if err != nil {
+			ipc.service.logger.Error("Profile %s server error: %v", profile.Name(), err)
+			continue
+		}
+		server := &http.Server{
+			Addr:         listener.Addr().String(),
+			Handler:      ipc.loggingMiddleware(ipc.profileHandlers[profile.Name()]),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: writeTimeout(profile.optimizer.config.Timeouts),
+			IdleTimeout:  60 * time.Second,
+		}
+		ipc.mu.Lock()
+		ipc.profileServers = append(ipc.profileServers, server)
+		ipc.mu.Unlock()
+
+		go func(name string) {
+			ipc.service.logger.Info("Profile %s listening on %s", name, server.Addr)
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				ipc.service.logger.Error("Profile %s server error: %v", name, err)
+			}
+		}(profile.Name())
+	}
+}
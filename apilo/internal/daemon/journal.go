@@ -0,0 +1,375 @@
+package daemon
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalConfig configures the append-only journal of request metadata. It is
+// rotated at MaxSizeMB, keeping MaxFiles rotated files, and replayed into
+// analytics on startup so history survives a crash
+type JournalConfig struct {
+	Enabled   bool   `yaml:"enabled" json:"enabled"`
+	Path      string `yaml:"path" json:"path"`
+	MaxSizeMB int    `yaml:"max_size_mb" json:"max_size_mb"`
+	MaxFiles  int    `yaml:"max_files" json:"max_files"`
+	Compress  bool   `yaml:"compress" json:"compress"` // gzip rotated files
+
+	// Entries younger than ReplayMaxAge are replayed on startup; zero disables replay
+	ReplayMaxAge time.Duration `yaml:"replay_max_age" json:"replay_max_age"`
+}
+
+// DefaultJournalConfig returns a disabled journal of 64MB files, keeping 10
+// compressed and replaying the last hour
+func DefaultJournalConfig() JournalConfig {
+	return JournalConfig{
+		Path:         "~/.apilo/journal/requests.jsonl",
+		MaxSizeMB:    64,
+		MaxFiles:     10,
+		Compress:     true,
+		ReplayMaxAge: time.Hour,
+	}
+}
+
+// JournalEntry is one journaled request; Profile is empty for the daemon's
+// own upstream and for proxy-intercepted requests
+type JournalEntry struct {
+	Profile string `json:"profile,omitempty"`
+	RequestRecord
+}
+
+// JournalStats describes the journal on disk
+type JournalStats struct {
+	Enabled     bool   `json:"enabled"`
+	Path        string `json:"path,omitempty"`
+	Files       int    `json:"files"` // Rotated files plus the active one
+	Bytes       int64  `json:"bytes"`
+	ActiveBytes int64  `json:"active_bytes"`
+	Written     int64  `json:"written"` // Entries appended since startup
+	WriteErrors int64  `json:"write_errors"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// Journal appends request metadata to a rotating JSON lines file
+type Journal struct {
+	config  JournalConfig
+	path    string
+	file    *os.File
+	size    int64
+	written int64
+	errors  int64
+	lastErr string
+	mu      sync.Mutex
+
+	// Rotated files being compressed in the background
+	compressing sync.WaitGroup
+}
+
+// OpenJournal opens config.Path for appending, creating its directory
+func OpenJournal(config JournalConfig) (*Journal, error) {
+	path := expandJournalPath(config.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	j := &Journal{config: config, path: path}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// expandJournalPath resolves a leading ~/ to the home directory
+func expandJournalPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// open opens the active file; the caller holds j.mu or owns j
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat journal: %w", err)
+	}
+	j.file, j.size = file, info.Size()
+	return nil
+}
+
+// Append writes one entry. Each entry is a single write so a crash leaves at
+// most a torn last line, which replay skips
+func (j *Journal) Append(profile string, record RequestRecord) {
+	line, err := json.Marshal(JournalEntry{Profile: profile, RequestRecord: record})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return
+	}
+	if maxSize := int64(j.config.MaxSizeMB) * 1024 * 1024; maxSize > 0 && j.size > 0 && j.size+int64(len(line)) > maxSize {
+		if err := j.rotate(); err != nil {
+			j.fail(err)
+			if j.file == nil {
+				return
+			}
+		}
+	}
+
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		j.fail(fmt.Errorf("failed to write journal: %w", err))
+		return
+	}
+	j.written++
+}
+
+// fail records a write error; the caller holds j.mu
+func (j *Journal) fail(err error) {
+	j.errors++
+	j.lastErr = err.Error()
+}
+
+// rotate renames the active file aside, starts a new one and prunes old
+// files; the caller holds j.mu
+func (j *Journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		j.fail(fmt.Errorf("failed to close journal: %w", err))
+	}
+	j.file = nil
+
+	// The timestamp suffix sorts rotated files oldest first
+	rotated := j.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(j.path, rotated); err != nil {
+		if openErr := j.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate journal: %w", err)
+	}
+	if err := j.open(); err != nil {
+		return err
+	}
+
+	if j.config.Compress {
+		j.compressing.Add(1)
+		go func() {
+			defer j.compressing.Done()
+			if err := compressJournalFile(rotated); err != nil {
+				j.mu.Lock()
+				j.fail(err)
+				j.mu.Unlock()
+			}
+		}()
+	}
+	j.prune()
+	return nil
+}
+
+// prune removes the oldest rotated files past MaxFiles; the caller holds j.mu
+func (j *Journal) prune() {
+	if j.config.MaxFiles <= 0 {
+		return
+	}
+	rotated := j.rotatedFiles()
+	for len(rotated) > j.config.MaxFiles {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+}
+
+// rotatedFiles lists rotated journal files, oldest first. A file being
+// compressed is listed once, under its uncompressed name
+func (j *Journal) rotatedFiles() []string {
+	matches, _ := filepath.Glob(j.path + ".*")
+	seen := make(map[string]bool, len(matches))
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		base := strings.TrimSuffix(match, ".gz")
+		if strings.HasSuffix(base, ".tmp") || seen[base] {
+			continue
+		}
+		seen[base] = true
+		files = append(files, match)
+	}
+	slices.SortFunc(files, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+	return files
+}
+
+// compressJournalFile gzips path to path.gz and removes path
+func compressJournalFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to compress journal: %w", err)
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compress journal: %w", err)
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress journal: %w", err)
+	}
+	return os.Remove(path)
+}
+
+// Replay calls fn for every entry at or after since, oldest file first.
+// Malformed lines, such as one torn by a crash, are skipped. It returns the
+// number of entries replayed
+func (j *Journal) Replay(since time.Time, fn func(JournalEntry)) (int, error) {
+	replayed := 0
+	err := j.each(func(entry JournalEntry) {
+		if entry.Timestamp.Before(since) {
+			return
+		}
+		fn(entry)
+		replayed++
+	})
+	return replayed, err
+}
+
+// Sample returns up to n entries at or after since, chosen uniformly at
+// random across the retained files
+func (j *Journal) Sample(n int, since time.Time) ([]JournalEntry, error) {
+	sample := make([]JournalEntry, 0, max(n, 0))
+	seen := 0
+	err := j.each(func(entry JournalEntry) {
+		if entry.Timestamp.Before(since) || n <= 0 {
+			return
+		}
+		seen++
+		if len(sample) < n {
+			sample = append(sample, entry)
+		} else if i := rand.IntN(seen); i < n {
+			sample[i] = entry
+		}
+	})
+	return sample, err
+}
+
+// each decodes every entry in the rotated files and then the active one
+func (j *Journal) each(fn func(JournalEntry)) error {
+	j.mu.Lock()
+	files := append(j.rotatedFiles(), j.path)
+	j.mu.Unlock()
+
+	for _, path := range files {
+		if err := readJournalFile(path, fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJournalFile decodes the entries of one plain or gzipped journal file
+func readJournalFile(path string, fn func(JournalEntry)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read journal %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		fn(entry)
+	}
+	// A gzip stream truncated by a crash mid-compression still yields its
+	// complete lines
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read journal %s: %w", path, err)
+	}
+	return nil
+}
+
+// Stats describes the journal's files and write counters
+func (j *Journal) Stats() JournalStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := JournalStats{
+		Enabled:     true,
+		Path:        j.path,
+		Written:     j.written,
+		WriteErrors: j.errors,
+		LastError:   j.lastErr,
+		ActiveBytes: j.size,
+		Bytes:       j.size,
+		Files:       1,
+	}
+	for _, path := range j.rotatedFiles() {
+		if info, err := os.Stat(path); err == nil {
+			stats.Bytes += info.Size()
+		}
+		stats.Files++
+	}
+	return stats
+}
+
+// Close waits for pending compressions, then syncs and closes the active file
+func (j *Journal) Close() error {
+	j.compressing.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	// Synced so the records analytics is rebuilt from survive the shutdown
+	err := errors.Join(j.file.Sync(), j.file.Close())
+	j.file = nil
+	return err
+}
@@ -0,0 +1,395 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeakDetectionConfig periodically snapshots the daemon's goroutines and file
+// descriptors and alerts when either keeps growing
+type LeakDetectionConfig struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+
+	// Growth counts as sustained once it has not dipped for this many
+	// consecutive snapshots and adds up to at least the minimum
+	SustainedSamples   int `yaml:"sustained_samples" json:"sustained_samples"`
+	MinGoroutineGrowth int `yaml:"min_goroutine_growth" json:"min_goroutine_growth"`
+	MinFDGrowth        int `yaml:"min_fd_growth" json:"min_fd_growth"`
+
+	MaxAlerts int `yaml:"max_alerts" json:"max_alerts"` // Most recent alerts kept
+}
+
+// DefaultLeakDetectionConfig snapshots every minute and alerts on 50
+// goroutines or 20 descriptors gained over 10 minutes without a dip
+func DefaultLeakDetectionConfig() LeakDetectionConfig {
+	return LeakDetectionConfig{
+		Enabled:            true,
+		Interval:           time.Minute,
+		SustainedSamples:   10,
+		MinGoroutineGrowth: 50,
+		MinFDGrowth:        20,
+		MaxAlerts:          20,
+	}
+}
+
+// maxLeakStackLines bounds the example stack kept per creation site
+const maxLeakStackLines = 24
+
+// leakSnapshot is the daemon's goroutines and descriptors at one instant
+type leakSnapshot struct {
+	at         time.Time
+	goroutines int
+	fds        int            // -1 where descriptors cannot be counted
+	sites      map[string]int // Goroutines per creation site
+	fdKinds    map[string]int // Descriptors per kind: socket, pipe, file...
+}
+
+// LeakPoint is one snapshot's totals
+type LeakPoint struct {
+	At         time.Time `json:"at"`
+	Goroutines int       `json:"goroutines"`
+	FDs        int       `json:"fds"`
+}
+
+// LeakSite is a goroutine creation site and how many goroutines it added
+type LeakSite struct {
+	Site   string `json:"site"`
+	Count  int    `json:"count"`
+	Growth int    `json:"growth"` // Since the oldest retained snapshot
+	Stack  string `json:"stack"`  // One goroutine's stack, as an example
+}
+
+// LeakAlert records sustained growth of goroutines or file descriptors
+type LeakAlert struct {
+	Kind       string         `json:"kind"` // "goroutines" or "fds"
+	DetectedAt time.Time      `json:"detected_at"`
+	Since      time.Time      `json:"since"`
+	From       int            `json:"from"`
+	To         int            `json:"to"`
+	Sites      []LeakSite     `json:"sites,omitempty"`    // Growing goroutine creation sites
+	FDKinds    map[string]int `json:"fd_kinds,omitempty"` // Descriptor growth per kind
+}
+
+// LeakReport is the body of /leaks
+type LeakReport struct {
+	Enabled    bool           `json:"enabled"`
+	Goroutines int            `json:"goroutines"`
+	FDs        int            `json:"fds"`
+	FDKinds    map[string]int `json:"fd_kinds,omitempty"`
+	History    []LeakPoint    `json:"history"`
+	Growing    []LeakSite     `json:"growing"` // Creation sites that grew across History
+	Alerts     []LeakAlert    `json:"alerts"`
+}
+
+// LeakDetector keeps recent snapshots and the alerts raised from them
+type LeakDetector struct {
+	config  LeakDetectionConfig
+	logger  *Logger
+	history []leakSnapshot
+	stacks  map[string]string // Example stack per creation site
+	alerts  []LeakAlert
+
+	// Totals at the last alert of each kind; the next alert needs a further
+	// minimum growth, so one slow leak is not reported every interval
+	alertedAt map[string]int
+	mu        sync.RWMutex
+}
+
+// NewLeakDetector creates a leak detector for config
+func NewLeakDetector(config LeakDetectionConfig, logger *Logger) *LeakDetector {
+	defaults := DefaultLeakDetectionConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.SustainedSamples < 2 {
+		config.SustainedSamples = defaults.SustainedSamples
+	}
+	if config.MaxAlerts <= 0 {
+		config.MaxAlerts = defaults.MaxAlerts
+	}
+	return &LeakDetector{
+		config:    config,
+		logger:    logger,
+		stacks:    make(map[string]string),
+		alertedAt: make(map[string]int),
+	}
+}
+
+// Run snapshots on the configured interval until ctx is cancelled
+func (d *LeakDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	d.Sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Sample()
+		}
+	}
+}
+
+// Sample takes a snapshot and raises alerts for any sustained growth
+func (d *LeakDetector) Sample() {
+	snapshot, stacks := takeLeakSnapshot()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for site, stack := range stacks {
+		if _, ok := d.stacks[site]; !ok {
+			d.stacks[site] = stack
+		}
+	}
+	d.history = append(d.history, snapshot)
+	if len(d.history) > d.config.SustainedSamples {
+		d.history = d.history[len(d.history)-d.config.SustainedSamples:]
+	}
+	if len(d.history) < d.config.SustainedSamples {
+		return
+	}
+
+	if alert, ok := d.detect("goroutines", d.config.MinGoroutineGrowth, func(s leakSnapshot) int { return s.goroutines }); ok {
+		alert.Sites = d.growingSites()
+		d.raise(alert)
+	}
+	if snapshot.fds >= 0 {
+		if alert, ok := d.detect("fds", d.config.MinFDGrowth, func(s leakSnapshot) int { return s.fds }); ok {
+			alert.FDKinds = diffCounts(d.history[0].fdKinds, snapshot.fdKinds)
+			d.raise(alert)
+		}
+	}
+
+	// Sites whose goroutines have all exited keep no example stack
+	for site := range d.stacks {
+		if snapshot.sites[site] == 0 {
+			delete(d.stacks, site)
+		}
+	}
+}
+
+// detect reports growth of value that never dipped across the history and
+// adds up to minGrowth, both overall and since the last alert of kind
+func (d *LeakDetector) detect(kind string, minGrowth int, value func(leakSnapshot) int) (LeakAlert, bool) {
+	first, last := d.history[0], d.history[len(d.history)-1]
+	for i := 1; i < len(d.history); i++ {
+		if value(d.history[i]) < value(d.history[i-1]) {
+			return LeakAlert{}, false
+		}
+	}
+	if value(last)-value(first) < minGrowth {
+		return LeakAlert{}, false
+	}
+	if previous, ok := d.alertedAt[kind]; ok && value(last)-previous < minGrowth {
+		return LeakAlert{}, false
+	}
+	d.alertedAt[kind] = value(last)
+	return LeakAlert{Kind: kind, DetectedAt: last.at, Since: first.at, From: value(first), To: value(last)}, true
+}
+
+// raise logs alert with the offending stacks and keeps it for /leaks
+func (d *LeakDetector) raise(alert LeakAlert) {
+	d.alerts = append(d.alerts, alert)
+	if len(d.alerts) > d.config.MaxAlerts {
+		d.alerts = d.alerts[len(d.alerts)-d.config.MaxAlerts:]
+	}
+	if d.logger == nil {
+		return
+	}
+
+	d.logger.Warn("Possible %s leak: %d -> %d since %s", alert.Kind, alert.From, alert.To, alert.Since.Format(time.RFC3339))
+	for _, site := range alert.Sites {
+		d.logger.Warn("  +%d goroutines (%d total) created by %s\n%s", site.Growth, site.Count, site.Site, site.Stack)
+	}
+	for kind, growth := range alert.FDKinds {
+		d.logger.Warn("  +%d %s descriptors", growth, kind)
+	}
+}
+
+// growingSites returns the creation sites whose goroutine count grew across
+// the history, largest growth first
+func (d *LeakDetector) growingSites() []LeakSite {
+	first, last := d.history[0], d.history[len(d.history)-1]
+	sites := []LeakSite{}
+	for site, growth := range diffCounts(first.sites, last.sites) {
+		sites = append(sites, LeakSite{Site: site, Count: last.sites[site], Growth: growth, Stack: d.stacks[site]})
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Growth != sites[j].Growth {
+			return sites[i].Growth > sites[j].Growth
+		}
+		return sites[i].Site < sites[j].Site
+	})
+	return sites
+}
+
+// Report returns the latest snapshot, the retained history and past alerts
+func (d *LeakDetector) Report() LeakReport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	report := LeakReport{
+		Enabled: true,
+		History: make([]LeakPoint, 0, len(d.history)),
+		Growing: []LeakSite{},
+		Alerts:  append([]LeakAlert{}, d.alerts...),
+	}
+	for _, s := range d.history {
+		report.History = append(report.History, LeakPoint{At: s.at, Goroutines: s.goroutines, FDs: s.fds})
+	}
+	if len(d.history) > 0 {
+		last := d.history[len(d.history)-1]
+		report.Goroutines, report.FDs, report.FDKinds = last.goroutines, last.fds, last.fdKinds
+		report.Growing = d.growingSites()
+	}
+	return report
+}
+
+// takeLeakSnapshot counts goroutines by creation site and descriptors by
+// kind, returning an example stack per site alongside
+func takeLeakSnapshot() (leakSnapshot, map[string]string) {
+	snapshot := leakSnapshot{at: time.Now(), sites: make(map[string]int)}
+	stacks := make(map[string]string)
+	for _, goroutine := range bytes.Split(allStacks(), []byte("\n\n")) {
+		site, stack := goroutineSite(string(goroutine))
+		if site == "" {
+			continue
+		}
+		snapshot.goroutines++
+		snapshot.sites[site]++
+		if _, ok := stacks[site]; !ok {
+			stacks[site] = stack
+		}
+	}
+	snapshot.fds, snapshot.fdKinds = countDescriptors()
+	return snapshot, stacks
+}
+
+// allStacks returns the stacks of every goroutine, growing the buffer until
+// they fit
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineSite parses one goroutine of a runtime.Stack dump into the place
+// it was started, e.g. "net/http.(*Server).Serve at server.go:3285", and its
+// stack without argument values. Goroutines without a creator, like main,
+// are keyed by their outermost frame
+func goroutineSite(goroutine string) (string, string) {
+	lines := strings.Split(strings.TrimSpace(goroutine), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "goroutine ") {
+		return "", ""
+	}
+
+	frames := lines[1:]
+	site := ""
+	for i := 0; i+1 < len(frames); i++ {
+		if strings.HasPrefix(frames[i], "created by ") {
+			function, _, _ := strings.Cut(strings.TrimPrefix(frames[i], "created by "), " in goroutine")
+			site = function + " at " + frameLocation(frames[i+1])
+			frames = frames[:i]
+			break
+		}
+	}
+	if site == "" && len(frames) >= 2 {
+		function := frames[len(frames)-2]
+		if open := strings.LastIndex(function, "("); open > 0 {
+			function = function[:open]
+		}
+		site = function + " at " + frameLocation(frames[len(frames)-1])
+	}
+
+	if len(frames) > maxLeakStackLines {
+		frames = append(frames[:maxLeakStackLines:maxLeakStackLines], "\t...")
+	}
+	return site, lines[0] + "\n" + strings.Join(frames, "\n")
+}
+
+// frameLocation shortens "\t/path/to/file.go:42 +0x1d" to "file.go:42"
+func frameLocation(line string) string {
+	location, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return filepath.Base(location)
+}
+
+// countDescriptors returns the open descriptors of this process by kind, or
+// -1 where they cannot be listed
+func countDescriptors() (int, map[string]int) {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		kinds := make(map[string]int)
+		for _, entry := range entries {
+			kinds[descriptorKind(filepath.Join(dir, entry.Name()))]++
+		}
+		return len(entries), kinds
+	}
+	return -1, nil
+}
+
+// descriptorKind classifies a descriptor by its /proc link target:
+// "socket:[123]" is a socket, anything else with a path is a file
+func descriptorKind(path string) string {
+	target, err := os.Readlink(path)
+	switch {
+	case err != nil:
+		return "unknown"
+	case strings.HasPrefix(target, "socket:"):
+		return "socket"
+	case strings.HasPrefix(target, "pipe:"):
+		return "pipe"
+	case strings.HasPrefix(target, "anon_inode:"):
+		return strings.Trim(strings.TrimPrefix(target, "anon_inode:"), "[]")
+	default:
+		return "file"
+	}
+}
+
+// diffCounts returns the keys of next that grew since previous, with their
+// growth
+func diffCounts(previous, next map[string]int) map[string]int {
+	growth := make(map[string]int)
+	for key, count := range next {
+		if delta := count - previous[key]; delta > 0 {
+			growth[key] = delta
+		}
+	}
+	return growth
+}
+
+// handleLeaks returns goroutine and descriptor history, growing creation
+// sites and leak alerts
+func (ipc *IPCServer) handleLeaks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := LeakReport{History: []LeakPoint{}, Growing: []LeakSite{}, Alerts: []LeakAlert{}}
+	if detector := ipc.service.leaks; detector != nil {
+		report = detector.Report()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
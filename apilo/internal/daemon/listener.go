@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first descriptor systemd passes to an activated service
+const listenFDsStart = 3
+
+// activatedSocket is a listener passed in by the service manager
+type activatedSocket struct {
+	name     string
+	listener net.Listener
+}
+
+// activation holds the sockets passed through LISTEN_FDS, read once
+var activation struct {
+	once    sync.Once
+	mu      sync.Mutex
+	sockets []activatedSocket
+	err     error
+}
+
+// listen returns a TCP listener for the server called name on host:port,
+// with SO_REUSEPORT set when reusePort is. A socket passed in by systemd
+// socket activation is used instead when its FileDescriptorName is name, or
+// when it is unnamed and bound to port
+func listen(name, host string, port int, reusePort bool) (net.Listener, error) {
+	activation.once.Do(func() {
+		activation.sockets, activation.err = activatedSockets(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), listenFDsStart)
+		// Not inherited by the proxy and scheduled benchmarks this process starts
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if activation.err != nil {
+		return nil, activation.err
+	}
+	if listener := takeActivatedSocket(name, port); listener != nil {
+		return listener, nil
+	}
+
+	config := net.ListenConfig{}
+	if reusePort {
+		config.Control = reusePortControl
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	listener, err := config.Listen(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// activatedSockets converts the descriptors systemd passes, starting at
+// first, into listeners. It returns none unless pid is this process
+func activatedSockets(pid, fds, names string, first int) ([]activatedSocket, error) {
+	if fds == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+
+	sockets := make([]activatedSocket, 0, count)
+	for i := range count {
+		socket := activatedSocket{}
+		if i < len(fdNames) && fdNames[i] != "unknown" {
+			socket.name = fdNames[i]
+		}
+
+		file := os.NewFile(uintptr(first+i), socket.name)
+		socket.listener, err = net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("activated socket %d is not a listener: %w", first+i, err)
+		}
+		sockets = append(sockets, socket)
+	}
+	return sockets, nil
+}
+
+// takeActivatedSocket removes and returns the activated socket for the
+// server called name on port, if there is one
+func takeActivatedSocket(name string, port int) net.Listener {
+	activation.mu.Lock()
+	defer activation.mu.Unlock()
+
+	match := -1
+	for i, socket := range activation.sockets {
+		if socket.name == name {
+			match = i
+			break
+		}
+		if addr, ok := socket.listener.Addr().(*net.TCPAddr); ok && socket.name == "" && addr.Port == port && match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil
+	}
+
+	listener := activation.sockets[match].listener
+	activation.sockets = append(activation.sockets[:match], activation.sockets[match+1:]...)
+	return listener
+}
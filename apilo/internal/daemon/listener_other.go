@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package daemon
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl fails, as SO_REUSEPORT is unavailable here
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
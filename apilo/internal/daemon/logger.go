@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -61,6 +62,9 @@ type Logger struct {
 	mu          sync.Mutex
 	maxSizeMB   int64
 	currentSize int64
+
+	// Fraction of per-request log lines written, 0-1
+	requestSampleRate float64
 }
 
 // NewLogger creates a new logger instance
@@ -96,6 +100,8 @@ func NewLogger(logFile string, level LogLevel) (*Logger, error) {
 		logger:      log.New(multiWriter, "", 0),
 		maxSizeMB:   100, // 100MB default
 		currentSize: currentSize,
+
+		requestSampleRate: 1,
 	}
 
 	return logger, nil
@@ -116,6 +122,33 @@ func (l *Logger) SetLevel(level LogLevel) {
 	l.level = level
 }
 
+// Level returns the current logging level
+func (l *Logger) Level() LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// SetRequestSampleRate sets the fraction of per-request log lines written
+func (l *Logger) SetRequestSampleRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requestSampleRate = rate
+}
+
+// RequestSampleRate returns the fraction of per-request log lines written
+func (l *Logger) RequestSampleRate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requestSampleRate
+}
+
+// sampleRequest reports whether a per-request log line should be written
+func (l *Logger) sampleRequest() bool {
+	rate := l.RequestSampleRate()
+	return rate >= 1 || rand.Float64() < rate
+}
+
 // log writes a log message with the given level
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	l.mu.Lock()
@@ -124,16 +157,24 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	if level < l.level {
 		return
 	}
+	l.write(level.String(), fmt.Sprintf(format, args...))
+}
 
+// Audit logs a configuration change regardless of the logging level
+func (l *Logger) Audit(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.write("AUDIT", fmt.Sprintf(format, args...))
+}
+
+// write appends a log line; callers hold l.mu
+func (l *Logger) write(levelStr, message string) {
 	// Check file size and rotate if needed
 	if l.currentSize > l.maxSizeMB*1024*1024 {
 		l.rotate()
 	}
 
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	levelStr := level.String()
-	message := fmt.Sprintf(format, args...)
-
 	logLine := fmt.Sprintf("[%s] %s - %s\n", timestamp, levelStr, message)
 
 	l.logger.Print(logLine)
@@ -191,11 +232,17 @@ func (l *Logger) Error(format string, args ...interface{}) {
 
 // LogRequest logs an HTTP request
 func (l *Logger) LogRequest(method, path string, statusCode int, latency time.Duration) {
+	if !l.sampleRequest() {
+		return
+	}
 	l.Info("HTTP %s %s - %d (%v)", method, path, statusCode, latency)
 }
 
 // LogOptimization logs an optimization request
 func (l *Logger) LogOptimization(url string, cacheHit bool, latency time.Duration) {
+	if !l.sampleRequest() {
+		return
+	}
 	cacheStatus := "MISS"
 	if cacheHit {
 		cacheStatus = "HIT"
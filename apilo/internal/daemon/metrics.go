@@ -20,13 +20,19 @@ type Metrics struct {
 	totalRequests      int64
 	cacheHits          int64
 	cacheMisses        int64
+	revalidations      int64
+	revalidationHits   int64
 	errors             int64
+	shed               int64
+	deduplicated       int64
 	totalLatency       int64
 	latencyCount       int64
+	totalQueue         int64
 	claudeInputTokens  int64
 	claudeOutputTokens int64
 	claudeTotalCost    int64 // Cost in cents
 	claudeRequests     int64
+	timeouts           map[TimeoutPhase]int64
 	cpuStats           *CPUStats
 	mu                 sync.RWMutex
 }
@@ -34,6 +40,7 @@ type Metrics struct {
 // NewMetrics creates a new metrics collector
 func NewMetrics() *Metrics {
 	return &Metrics{
+		timeouts: make(map[TimeoutPhase]int64),
 		cpuStats: &CPUStats{
 			lastSampleTime: time.Now(),
 		},
@@ -55,6 +62,36 @@ func (m *Metrics) IncrementCacheMisses() {
 	atomic.AddInt64(&m.cacheMisses, 1)
 }
 
+// RecordRevalidation records a conditional GET and whether it returned 304
+func (m *Metrics) RecordRevalidation(notModified bool) {
+	atomic.AddInt64(&m.revalidations, 1)
+	if notModified {
+		atomic.AddInt64(&m.revalidationHits, 1)
+	}
+}
+
+// IncrementShed counts a request rejected by load shedding
+func (m *Metrics) IncrementShed() {
+	atomic.AddInt64(&m.shed, 1)
+}
+
+// RecordQueueTime adds time a request spent queued before being served
+func (m *Metrics) RecordQueueTime(queued time.Duration) {
+	atomic.AddInt64(&m.totalQueue, int64(queued))
+}
+
+// IncrementDeduplicated counts a request answered with an identical request's response
+func (m *Metrics) IncrementDeduplicated() {
+	atomic.AddInt64(&m.deduplicated, 1)
+}
+
+// RecordTimeout counts an upstream timeout by the phase that overran
+func (m *Metrics) RecordTimeout(phase TimeoutPhase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts[phase]++
+}
+
 // IncrementErrors increments the error counter
 func (m *Metrics) IncrementErrors() {
 	atomic.AddInt64(&m.errors, 1)
@@ -85,18 +122,42 @@ func (m *Metrics) GetStats() *MetricsStats {
 	hits := atomic.LoadInt64(&m.cacheHits)
 	misses := atomic.LoadInt64(&m.cacheMisses)
 	errors := atomic.LoadInt64(&m.errors)
+	shed := atomic.LoadInt64(&m.shed)
+	deduplicated := atomic.LoadInt64(&m.deduplicated)
+	revalidations := atomic.LoadInt64(&m.revalidations)
+	revalidationHits := atomic.LoadInt64(&m.revalidationHits)
 	totalLat := atomic.LoadInt64(&m.totalLatency)
 	latCount := atomic.LoadInt64(&m.latencyCount)
+	totalQueue := atomic.LoadInt64(&m.totalQueue)
 
 	var cacheHitRatio float64
 	if totalReq > 0 {
 		cacheHitRatio = float64(hits) / float64(totalReq)
 	}
 
-	var avgLatency time.Duration
+	var revalidationHitRatio float64
+	if revalidations > 0 {
+		revalidationHitRatio = float64(revalidationHits) / float64(revalidations)
+	}
+
+	// Shed requests never reach the optimizer, so they are not in totalReq
+	var shedRate float64
+	if totalReq+shed > 0 {
+		shedRate = float64(shed) / float64(totalReq+shed)
+	}
+
+	var avgLatency, avgQueue time.Duration
 	if latCount > 0 {
 		avgLatency = time.Duration(totalLat / latCount)
+		avgQueue = time.Duration(totalQueue / latCount)
+	}
+
+	m.mu.RLock()
+	timeouts := make(map[TimeoutPhase]int64, len(m.timeouts))
+	for phase, count := range m.timeouts {
+		timeouts[phase] = count
 	}
+	m.mu.RUnlock()
 
 	// Get memory stats
 	var memStats runtime.MemStats
@@ -133,9 +194,21 @@ func (m *Metrics) GetStats() *MetricsStats {
 		Errors:        errors,
 		CacheHitRatio: cacheHitRatio,
 		AvgLatency:    avgLatency,
+		AvgQueue:      avgQueue,
 		MemoryUsageMB: memoryMB,
 		CPUPercent:    cpuPercent,
 		ClaudeMetrics: claudeMetrics,
+
+		Revalidations:        revalidations,
+		RevalidationHits:     revalidationHits,
+		RevalidationHitRatio: revalidationHitRatio,
+
+		Shed:     shed,
+		ShedRate: shedRate,
+
+		Deduplicated: deduplicated,
+
+		Timeouts: timeouts,
 	}
 }
 
@@ -183,13 +256,21 @@ func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.totalRequests, 0)
 	atomic.StoreInt64(&m.cacheHits, 0)
 	atomic.StoreInt64(&m.cacheMisses, 0)
+	atomic.StoreInt64(&m.revalidations, 0)
+	atomic.StoreInt64(&m.revalidationHits, 0)
 	atomic.StoreInt64(&m.errors, 0)
+	atomic.StoreInt64(&m.shed, 0)
 	atomic.StoreInt64(&m.totalLatency, 0)
 	atomic.StoreInt64(&m.latencyCount, 0)
+	atomic.StoreInt64(&m.totalQueue, 0)
 	atomic.StoreInt64(&m.claudeInputTokens, 0)
 	atomic.StoreInt64(&m.claudeOutputTokens, 0)
 	atomic.StoreInt64(&m.claudeTotalCost, 0)
 	atomic.StoreInt64(&m.claudeRequests, 0)
+
+	m.mu.Lock()
+	m.timeouts = make(map[TimeoutPhase]int64)
+	m.mu.Unlock()
 }
 
 // MetricsStats holds snapshot of metrics
@@ -200,7 +281,24 @@ type MetricsStats struct {
 	Errors        int64               `json:"errors"`
 	CacheHitRatio float64             `json:"cache_hit_ratio"`
 	AvgLatency    time.Duration       `json:"avg_latency"`
+	AvgQueue      time.Duration       `json:"avg_queue"` // Queued before service, excluded from AvgLatency
 	MemoryUsageMB float64             `json:"memory_usage_mb"`
 	CPUPercent    float64             `json:"cpu_percent"`
 	ClaudeMetrics *ClaudeTokenMetrics `json:"claude_metrics,omitempty"`
+
+	// Conditional revalidation of expired entries, reported separately from hits
+	Revalidations        int64   `json:"revalidations"`
+	RevalidationHits     int64   `json:"revalidation_hits"`
+	RevalidationHitRatio float64 `json:"revalidation_hit_ratio"`
+
+	// Requests rejected with 429 by load shedding
+	Shed     int64   `json:"shed"`
+	ShedRate float64 `json:"shed_rate"`
+
+	// Requests answered with the response of an identical request in the
+	// dedup window, counted neither as cache hits nor misses
+	Deduplicated int64 `json:"deduplicated"`
+
+	// Upstream timeouts keyed by the phase that exceeded its budget
+	Timeouts map[TimeoutPhase]int64 `json:"timeouts,omitempty"`
 }
@@ -0,0 +1,475 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mirrorSampleSize bounds the paired latency samples kept for percentiles
+const mirrorSampleSize = 1000
+
+// MirrorConfig duplicates a share of live upstream traffic to a shadow
+// endpoint, e.g. to validate a new gateway before cutover. Shadow responses
+// are discarded; only their latency and status are compared with the primary
+type MirrorConfig struct {
+	Enabled     bool          `yaml:"enabled" json:"enabled"`
+	ShadowURL   string        `yaml:"shadow_url" json:"shadow_url"`       // Scheme, host and optional path prefix replacing the primary's
+	Percentage  float64       `yaml:"percentage" json:"percentage"`       // Share of eligible requests mirrored, 0-100
+	Methods     []string      `yaml:"methods" json:"methods"`             // Methods mirrored; empty mirrors every method
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`             // Budget for each shadow request
+	MaxInFlight int           `yaml:"max_in_flight" json:"max_in_flight"` // Shadow requests beyond this are dropped
+
+	Diff MirrorDiffConfig `yaml:"diff" json:"diff"`
+}
+
+// DefaultMirrorConfig returns a disabled mirror that shadows 10% of safe
+// requests when enabled. Non-idempotent methods must be opted into explicitly
+func DefaultMirrorConfig() MirrorConfig {
+	return MirrorConfig{
+		Percentage:  10,
+		Methods:     []string{http.MethodGet, http.MethodHead},
+		Timeout:     10 * time.Second,
+		MaxInFlight: 32,
+		Diff:        DefaultMirrorDiffConfig(),
+	}
+}
+
+// mirrorSample is one request as answered by the primary and the shadow
+type mirrorSample struct {
+	primaryLatency time.Duration
+	shadowLatency  time.Duration
+	primaryStatus  int
+	shadowStatus   int
+}
+
+// mirrorRequest is a sampled request and the primary's answer to it
+type mirrorRequest struct {
+	method         string
+	req            OptimizationRequest
+	primaryStatus  int
+	primaryBody    []byte
+	primaryLatency time.Duration
+}
+
+// Mirror sends sampled requests to the shadow upstream in the background
+type Mirror struct {
+	config  MirrorConfig
+	shadow  *url.URL
+	methods map[string]bool
+	client  *http.Client
+	slots   chan struct{}
+	logger  *Logger
+	matcher *fieldMatcher // Set when bodies are diffed
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mirrored atomic.Int64
+	dropped  atomic.Int64
+
+	samples          []mirrorSample
+	next             int
+	completed        int64
+	shadowErrors     int64
+	statusMatches    int64
+	statusMismatches int64
+	primaryStatuses  map[int]int64
+	shadowStatuses   map[int]int64
+
+	bodiesCompared int64
+	bodyMismatches int64
+	bodiesSkipped  int64
+	mismatchFields map[string]int64
+	diffs          []MirrorDiff
+	nextDiff       int
+
+	mu sync.Mutex
+}
+
+// NewMirror validates config and creates a mirror; it returns nil when
+// mirroring is disabled
+func NewMirror(config MirrorConfig, logger *Logger) (*Mirror, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	shadow, err := url.Parse(config.ShadowURL)
+	if err != nil || shadow.Scheme == "" || shadow.Host == "" {
+		return nil, fmt.Errorf("invalid mirror shadow_url %q", config.ShadowURL)
+	}
+	if config.Percentage <= 0 || config.Percentage > 100 {
+		return nil, fmt.Errorf("mirror percentage must be in (0, 100], got %v", config.Percentage)
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = DefaultMirrorConfig().MaxInFlight
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultMirrorConfig().Timeout
+	}
+
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	if config.Diff.MaxBodyBytes <= 0 {
+		config.Diff.MaxBodyBytes = DefaultMirrorDiffConfig().MaxBodyBytes
+	}
+	if config.Diff.SampleSize <= 0 {
+		config.Diff.SampleSize = DefaultMirrorDiffConfig().SampleSize
+	}
+	var matcher *fieldMatcher
+	if config.Diff.Enabled {
+		matcher = newFieldMatcher(config.Diff.IgnoreFields)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mirror{
+		config:          config,
+		shadow:          shadow,
+		methods:         methods,
+		client:          &http.Client{Timeout: config.Timeout},
+		slots:           make(chan struct{}, config.MaxInFlight),
+		logger:          logger,
+		matcher:         matcher,
+		mismatchFields:  make(map[string]int64),
+		ctx:             ctx,
+		cancel:          cancel,
+		primaryStatuses: make(map[int]int64),
+		shadowStatuses:  make(map[int]int64),
+	}, nil
+}
+
+// Close abandons in-flight shadow requests
+func (m *Mirror) Close() {
+	m.cancel()
+}
+
+// Observe mirrors req to the shadow upstream if it is sampled. Only requests
+// the primary upstream actually answered are eligible, so cache hits and
+// local rejections never skew the comparison
+func (m *Mirror) Observe(req *OptimizationRequest, primary *OptimizationResponse, primaryLatency time.Duration) {
+	if primary == nil || primary.CacheHit {
+		return
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if len(m.methods) > 0 && !m.methods[method] {
+		return
+	}
+	if rand.Float64()*100 >= m.config.Percentage {
+		return
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.dropped.Add(1)
+		return
+	}
+	m.mirrored.Add(1)
+
+	// The caller owns req once Observe returns
+	mirrored := &mirrorRequest{
+		method:         method,
+		req:            *req,
+		primaryStatus:  primary.StatusCode,
+		primaryLatency: primaryLatency,
+	}
+	mirrored.req.Headers = make(map[string]string, len(req.Headers))
+	for key, value := range req.Headers {
+		mirrored.req.Headers[key] = value
+	}
+	if m.matcher != nil {
+		mirrored.primaryBody = primary.Body
+	}
+
+	go func() {
+		defer func() { <-m.slots }()
+		m.send(mirrored)
+	}()
+}
+
+// shadowURL rewrites a primary URL onto the shadow upstream, keeping its path
+// and query
+func (m *Mirror) shadowURL(primary string) (string, error) {
+	target, err := url.Parse(primary)
+	if err != nil {
+		return "", err
+	}
+	shadow := m.shadow.JoinPath(target.Path)
+	shadow.RawQuery = target.RawQuery
+	return shadow.String(), nil
+}
+
+// send issues one shadow request and records it against the primary's result
+func (m *Mirror) send(mirrored *mirrorRequest) {
+	req := &mirrored.req
+	target, err := m.shadowURL(req.URL)
+	if err != nil {
+		m.recordError()
+		return
+	}
+
+	var body io.Reader
+	if len(req.Body) > 0 {
+		body = bytes.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(m.ctx, mirrored.method, target, body)
+	if err != nil {
+		m.recordError()
+		return
+	}
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	httpReq.Header.Set("X-Apilo-Shadow", "1")
+
+	start := time.Now()
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		if m.ctx.Err() == nil {
+			m.logger.Debug("Shadow request to %s failed: %v", target, err)
+			m.recordError()
+		}
+		return
+	}
+	var shadowBody []byte
+	if m.matcher != nil {
+		shadowBody, err = io.ReadAll(io.LimitReader(resp.Body, m.config.Diff.MaxBodyBytes+1))
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	m.record(mirrorSample{
+		primaryLatency: mirrored.primaryLatency,
+		shadowLatency:  latency,
+		primaryStatus:  mirrored.primaryStatus,
+		shadowStatus:   resp.StatusCode,
+	})
+
+	// Bodies of different statuses are expected to differ; that mismatch is
+	// already counted
+	if m.matcher != nil && err == nil && resp.StatusCode == mirrored.primaryStatus {
+		m.compareBodies(mirrored, shadowBody)
+	}
+}
+
+// compareBodies diffs the primary and shadow bodies and samples mismatches
+func (m *Mirror) compareBodies(mirrored *mirrorRequest, shadowBody []byte) {
+	if int64(len(shadowBody)) > m.config.Diff.MaxBodyBytes || int64(len(mirrored.primaryBody)) > m.config.Diff.MaxBodyBytes {
+		m.mu.Lock()
+		m.bodiesSkipped++
+		m.mu.Unlock()
+		return
+	}
+
+	differences, mismatch := diffBodies(mirrored.primaryBody, shadowBody, m.matcher)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bodiesCompared++
+	if !mismatch {
+		return
+	}
+	m.bodyMismatches++
+
+	for _, difference := range differences {
+		if _, tracked := m.mismatchFields[difference.Path]; tracked || len(m.mismatchFields) < maxMismatchFields {
+			m.mismatchFields[difference.Path]++
+		}
+	}
+
+	diff := MirrorDiff{
+		Time:        time.Now(),
+		Method:      mirrored.method,
+		URL:         mirrored.req.URL,
+		StatusCode:  mirrored.primaryStatus,
+		Differences: differences,
+	}
+	if len(differences) > maxDiffFields {
+		diff.Differences = differences[:maxDiffFields]
+		diff.Truncated = true
+	}
+	if len(m.diffs) < m.config.Diff.SampleSize {
+		m.diffs = append(m.diffs, diff)
+	} else {
+		m.diffs[m.nextDiff] = diff
+	}
+	m.nextDiff = (m.nextDiff + 1) % m.config.Diff.SampleSize
+}
+
+// Diffs returns up to limit sampled body mismatches, newest first
+func (m *Mirror) Diffs(limit int) []MirrorDiff {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limit <= 0 || limit > len(m.diffs) {
+		limit = len(m.diffs)
+	}
+	diffs := make([]MirrorDiff, 0, limit)
+	for i := 1; i <= limit; i++ {
+		// The newest diff sits just before nextDiff
+		diffs = append(diffs, m.diffs[(m.nextDiff-i+len(m.diffs))%len(m.diffs)])
+	}
+	return diffs
+}
+
+// recordError counts a shadow request that got no response
+func (m *Mirror) recordError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shadowErrors++
+}
+
+// record adds a completed primary/shadow pair
+func (m *Mirror) record(sample mirrorSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.samples) < mirrorSampleSize {
+		m.samples = append(m.samples, sample)
+	} else {
+		m.samples[m.next] = sample
+		m.next = (m.next + 1) % mirrorSampleSize
+	}
+
+	m.completed++
+	m.primaryStatuses[sample.primaryStatus]++
+	m.shadowStatuses[sample.shadowStatus]++
+	if sample.primaryStatus == sample.shadowStatus {
+		m.statusMatches++
+	} else {
+		m.statusMismatches++
+	}
+}
+
+// MirrorSideStats describes the latency and status distribution of one side
+type MirrorSideStats struct {
+	AvgLatency  time.Duration `json:"avg_latency"`
+	P50         time.Duration `json:"p50"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+	StatusCodes map[int]int64 `json:"status_codes"`
+}
+
+// MirrorStats compares primary and shadow over the mirrored requests
+type MirrorStats struct {
+	Enabled          bool            `json:"enabled"`
+	ShadowURL        string          `json:"shadow_url,omitempty"`
+	Percentage       float64         `json:"percentage,omitempty"`
+	Mirrored         int64           `json:"mirrored"`
+	Dropped          int64           `json:"dropped"`
+	InFlight         int             `json:"in_flight"`
+	Completed        int64           `json:"completed"`
+	ShadowErrors     int64           `json:"shadow_errors"`
+	StatusMatches    int64           `json:"status_matches"`
+	StatusMismatches int64           `json:"status_mismatches"`
+	StatusMatchRatio float64         `json:"status_match_ratio"`
+	Samples          int             `json:"samples"`
+	Primary          MirrorSideStats `json:"primary"`
+	Shadow           MirrorSideStats `json:"shadow"`
+	P50Delta         time.Duration   `json:"p50_delta"` // Shadow minus primary; positive means the shadow is slower
+	P95Delta         time.Duration   `json:"p95_delta"`
+
+	// Body comparison, when diffing is enabled. Only responses whose statuses
+	// match are compared
+	BodyDiff *MirrorBodyStats `json:"body_diff,omitempty"`
+}
+
+// MirrorBodyStats summarizes primary/shadow body comparisons
+type MirrorBodyStats struct {
+	Compared       int64            `json:"compared"`
+	Matches        int64            `json:"matches"`
+	Mismatches     int64            `json:"mismatches"`
+	MismatchRatio  float64          `json:"mismatch_ratio"`
+	Skipped        int64            `json:"skipped"` // Bodies over max_body_bytes
+	IgnoredFields  []string         `json:"ignored_fields"`
+	MismatchFields map[string]int64 `json:"mismatch_fields"` // Mismatch count per JSON path
+}
+
+// Stats returns the comparison so far
+func (m *Mirror) Stats() MirrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := MirrorStats{
+		Enabled:          true,
+		ShadowURL:        m.config.ShadowURL,
+		Percentage:       m.config.Percentage,
+		Mirrored:         m.mirrored.Load(),
+		Dropped:          m.dropped.Load(),
+		InFlight:         len(m.slots),
+		Completed:        m.completed,
+		ShadowErrors:     m.shadowErrors,
+		StatusMatches:    m.statusMatches,
+		StatusMismatches: m.statusMismatches,
+		Samples:          len(m.samples),
+		Primary:          sideStats(m.samples, func(s mirrorSample) time.Duration { return s.primaryLatency }, m.primaryStatuses),
+		Shadow:           sideStats(m.samples, func(s mirrorSample) time.Duration { return s.shadowLatency }, m.shadowStatuses),
+	}
+	if m.completed > 0 {
+		stats.StatusMatchRatio = float64(m.statusMatches) / float64(m.completed)
+	}
+	stats.P50Delta = stats.Shadow.P50 - stats.Primary.P50
+	stats.P95Delta = stats.Shadow.P95 - stats.Primary.P95
+
+	if m.matcher != nil {
+		body := &MirrorBodyStats{
+			Compared:       m.bodiesCompared,
+			Matches:        m.bodiesCompared - m.bodyMismatches,
+			Mismatches:     m.bodyMismatches,
+			Skipped:        m.bodiesSkipped,
+			IgnoredFields:  m.config.Diff.IgnoreFields,
+			MismatchFields: make(map[string]int64, len(m.mismatchFields)),
+		}
+		if body.Compared > 0 {
+			body.MismatchRatio = float64(body.Mismatches) / float64(body.Compared)
+		}
+		for path, count := range m.mismatchFields {
+			body.MismatchFields[path] = count
+		}
+		stats.BodyDiff = body
+	}
+	return stats
+}
+
+// sideStats computes the latency percentiles of one side of the samples
+func sideStats(samples []mirrorSample, latency func(mirrorSample) time.Duration, statuses map[int]int64) MirrorSideStats {
+	codes := make(map[int]int64, len(statuses))
+	for code, count := range statuses {
+		codes[code] = count
+	}
+	stats := MirrorSideStats{StatusCodes: codes}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	var total time.Duration
+	for i, sample := range samples {
+		sorted[i] = latency(sample)
+		total += sorted[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	stats.AvgLatency = total / time.Duration(len(sorted))
+	stats.P50 = sorted[len(sorted)*50/100]
+	stats.P95 = sorted[len(sorted)*95/100]
+	stats.P99 = sorted[len(sorted)*99/100]
+	return stats
+}
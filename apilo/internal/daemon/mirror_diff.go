@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxDiffFields     = 20  // Differences kept per sampled diff
+	maxDiffValueLen   = 200 // Characters kept of each differing value
+	maxMismatchFields = 100 // Distinct field paths counted in MismatchFields
+)
+
+// MirrorDiffConfig compares primary and shadow response bodies. JSON bodies
+// are diffed field by field; anything else must match byte for byte
+type MirrorDiffConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	IgnoreFields []string `yaml:"ignore_fields" json:"ignore_fields"`   // Volatile fields: a bare name matches at any depth, a dotted path matches exactly with * for any segment
+	MaxBodyBytes int64    `yaml:"max_body_bytes" json:"max_body_bytes"` // Larger shadow bodies are not compared
+	SampleSize   int      `yaml:"sample_size" json:"sample_size"`       // Most recent mismatches kept for inspection
+}
+
+// DefaultMirrorDiffConfig ignores the identifiers and timestamps that differ
+// between any two responses
+func DefaultMirrorDiffConfig() MirrorDiffConfig {
+	return MirrorDiffConfig{
+		IgnoreFields: []string{"id", "created", "created_at", "request_id", "timestamp"},
+		MaxBodyBytes: 1 << 20,
+		SampleSize:   50,
+	}
+}
+
+// FieldDiff is one difference between the primary and shadow bodies
+type FieldDiff struct {
+	Path    string `json:"path"`
+	Primary string `json:"primary"`
+	Shadow  string `json:"shadow"`
+}
+
+// MirrorDiff is a sampled body mismatch
+type MirrorDiff struct {
+	Time        time.Time   `json:"time"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	StatusCode  int         `json:"status_code"`
+	Differences []FieldDiff `json:"differences"`
+	Truncated   bool        `json:"truncated,omitempty"` // More differences than were kept
+}
+
+// missingValue stands in for a field present on only one side
+type missingValue struct{}
+
+// fieldMatcher decides which JSON paths are volatile
+type fieldMatcher struct {
+	names    map[string]bool
+	patterns [][]string
+}
+
+// newFieldMatcher compiles IgnoreFields
+func newFieldMatcher(fields []string) *fieldMatcher {
+	matcher := &fieldMatcher{names: make(map[string]bool)}
+	for _, field := range fields {
+		if strings.Contains(field, ".") {
+			matcher.patterns = append(matcher.patterns, strings.Split(field, "."))
+		} else if field != "" {
+			matcher.names[field] = true
+		}
+	}
+	return matcher
+}
+
+// ignored reports whether the field at path should not be compared
+func (m *fieldMatcher) ignored(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if m.names[path[len(path)-1]] {
+		return true
+	}
+
+pattern:
+	for _, pattern := range m.patterns {
+		if len(pattern) != len(path) {
+			continue
+		}
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				continue pattern
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// diffBodies compares two response bodies. The bool result is false when the
+// bodies match after ignoring volatile fields
+func diffBodies(primary, shadow []byte, matcher *fieldMatcher) ([]FieldDiff, bool) {
+	var primaryJSON, shadowJSON interface{}
+	if json.Unmarshal(primary, &primaryJSON) != nil || json.Unmarshal(shadow, &shadowJSON) != nil {
+		if bytes.Equal(primary, shadow) {
+			return nil, false
+		}
+		return []FieldDiff{{
+			Path:    "(body)",
+			Primary: fmt.Sprintf("%d bytes", len(primary)),
+			Shadow:  fmt.Sprintf("%d bytes", len(shadow)),
+		}}, true
+	}
+
+	var diffs []FieldDiff
+	diffJSON(nil, primaryJSON, shadowJSON, matcher, &diffs)
+	return diffs, len(diffs) > 0
+}
+
+// diffJSON appends the differences between two decoded JSON values
+func diffJSON(path []string, primary, shadow interface{}, matcher *fieldMatcher, diffs *[]FieldDiff) {
+	if matcher.ignored(path) {
+		return
+	}
+
+	switch p := primary.(type) {
+	case map[string]interface{}:
+		s, ok := shadow.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(p)+len(s))
+		for key := range p {
+			keys = append(keys, key)
+		}
+		for key := range s {
+			if _, seen := p[key]; !seen {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			pv, ok := p[key]
+			if !ok {
+				pv = missingValue{}
+			}
+			sv, ok := s[key]
+			if !ok {
+				sv = missingValue{}
+			}
+			diffJSON(append(path, key), pv, sv, matcher, diffs)
+		}
+		return
+
+	case []interface{}:
+		s, ok := shadow.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(p) || i < len(s); i++ {
+			var pv, sv interface{} = missingValue{}, missingValue{}
+			if i < len(p) {
+				pv = p[i]
+			}
+			if i < len(s) {
+				sv = s[i]
+			}
+			diffJSON(append(path, strconv.Itoa(i)), pv, sv, matcher, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(primary, shadow) {
+		*diffs = append(*diffs, FieldDiff{
+			Path:    formatDiffPath(path),
+			Primary: formatDiffValue(primary),
+			Shadow:  formatDiffValue(shadow),
+		})
+	}
+}
+
+// formatDiffPath renders a JSON path, using "." for the document root
+func formatDiffPath(path []string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	return strings.Join(path, ".")
+}
+
+// formatDiffValue renders a JSON value for display, truncating long ones
+func formatDiffValue(value interface{}) string {
+	if _, missing := value.(missingValue); missing {
+		return "(missing)"
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(encoded) > maxDiffValueLen {
+		return string(encoded[:maxDiffValueLen]) + "..."
+	}
+	return string(encoded)
+}
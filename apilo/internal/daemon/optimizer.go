@@ -2,12 +2,16 @@ package daemon
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,9 +19,18 @@ import (
 type Optimizer struct {
 	config     *DaemonConfig
 	cache      *Cache
+	circuits   *CircuitRegistry
+	limiter    *RateLimiter
+	dedup      *Deduplicator
+	early      *earlyRefresher
+	ranges     *rangeCache
 	httpClient *http.Client
 	logger     *Logger
 	mu         sync.RWMutex
+
+	// Toggled at runtime through the admin API; disabled means every request
+	// bypasses the cache entirely
+	cacheDisabled atomic.Bool
 }
 
 // NewOptimizer creates a new optimizer
@@ -41,9 +54,36 @@ func NewOptimizer(config *DaemonConfig, logger *Logger) (*Optimizer, error) {
 		transport.ForceAttemptHTTP2 = true
 	}
 
+	// Deadlines are enforced per phase by withPhaseTimeouts rather than a
+	// single client-wide timeout
 	opt.httpClient = &http.Client{
 		Transport: transport,
-		Timeout:   30 * time.Second,
+	}
+
+	if config.EnableCircuitBreaker {
+		opt.circuits = NewCircuitRegistry(config.CircuitBreaker, config.CircuitBreakerOverrides)
+	}
+
+	if config.RateLimit.Enabled {
+		opt.limiter = NewRateLimiter(config.RateLimit)
+	}
+
+	if config.Dedup.Enabled {
+		opt.dedup = NewDeduplicator(config.Dedup)
+	}
+
+	if config.EarlyRefresh.Enabled {
+		opt.early = newEarlyRefresher(config.EarlyRefresh)
+	}
+
+	opt.cache.SetEviction(config.Eviction)
+
+	if config.AdaptiveTTL.Enabled {
+		opt.cache.SetAdaptiveTTL(config.AdaptiveTTL)
+	}
+
+	if config.RangeCache.Enabled {
+		opt.ranges = newRangeCache(config.RangeCache)
 	}
 
 	return opt, nil
@@ -51,12 +91,59 @@ func NewOptimizer(config *DaemonConfig, logger *Logger) (*Optimizer, error) {
 
 // Optimize optimizes an API request
 func (opt *Optimizer) Optimize(req *OptimizationRequest) (*OptimizationResponse, error) {
+	return opt.OptimizeContext(context.Background(), req)
+}
+
+// OptimizeContext optimizes an API request, bounding the upstream call by the
+// caller's deadline as well as the configured phase timeouts. Duplicates of a
+// recent identical request share its response when deduplication is enabled
+func (opt *Optimizer) OptimizeContext(ctx context.Context, req *OptimizationRequest) (*OptimizationResponse, error) {
+	if opt.dedup != nil {
+		return opt.dedup.Do(ctx, req, func() (*OptimizationResponse, error) {
+			return opt.optimize(ctx, req)
+		})
+	}
+	return opt.optimize(ctx, req)
+}
+
+// optimize serves req from the cache or the upstream
+func (opt *Optimizer) optimize(ctx context.Context, req *OptimizationRequest) (*OptimizationResponse, error) {
 	// Generate cache key
+	lookup := time.Now()
 	cacheKey := opt.generateCacheKey(req)
+	useCache := opt.CacheEnabled()
+
+	// A Range request reads from the cache entry of the whole object
+	var rangeReq byteRange
+	isRange := false
+	if useCache && opt.ranges != nil {
+		if rangeReq, isRange = opt.ranges.rangeRequest(req); isRange {
+			cacheKey = opt.objectKey(req)
+			if resp := opt.ranges.serve(opt.cache, cacheKey, rangeReq); resp != nil {
+				recordOverheadPhase(ctx, OverheadCache, lookup)
+				opt.logger.LogCacheOperation("GET", cacheKey, true)
+				annotate(ctx, "cache", "hit")
+				resp.Metadata.HTTP2Used = opt.config.EnableHTTP2
+				return resp, nil
+			}
+		}
+	}
 
-	// Check cache
-	if cached, found := opt.cache.Get(cacheKey); found {
+	// Check cache, unless caching has been disabled at runtime or this is a
+	// background refresh of the entry. Entries holding only some ranges of
+	// an object answer Range requests alone
+	if cached, found := opt.cache.Get(cacheKey); useCache && found && !isEarlyRefresh(ctx) && !isRange && cached.Ranges == nil {
 		opt.logger.LogCacheOperation("GET", cacheKey, true)
+		annotate(ctx, "cache", "hit")
+		cached.recordHit()
+		if opt.early != nil && req.Method == http.MethodGet && opt.early.due(cached, opt.cache.TTLRemaining(cached)) {
+			annotate(ctx, "cache.early_refresh", true)
+			opt.early.start(cacheKey, func(ctx context.Context) error {
+				_, err := opt.optimize(ctx, req)
+				return err
+			})
+		}
+		recordOverheadPhase(ctx, OverheadCache, lookup)
 		return &OptimizationResponse{
 			StatusCode: cached.StatusCode,
 			Headers:    cached.Headers,
@@ -74,28 +161,138 @@ func (opt *Optimizer) Optimize(req *OptimizationRequest) (*OptimizationResponse,
 	}
 	opt.logger.LogCacheOperation("GET", cacheKey, false)
 
+	// An expired entry with validators can be revalidated instead of refetched
+	stale, hasStale := opt.cache.GetStale(cacheKey)
+	revalidating := useCache && hasStale && req.Method == http.MethodGet && !isRange && stale.Ranges == nil && stale.HasValidators()
+	recordOverheadPhase(ctx, OverheadCache, lookup)
+	switch {
+	case !useCache:
+		annotate(ctx, "cache", "bypass")
+	case revalidating:
+		annotate(ctx, "cache", "stale")
+	default:
+		annotate(ctx, "cache", "miss")
+	}
+
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	ctx, cancelPhases := withPhaseTimeouts(ctx, opt.config.Timeouts)
+	defer cancelPhases()
+
 	// Make HTTP request
-	httpReq, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Add headers
 	for key, value := range req.Headers {
-		httpReq.Header.Set(key, value)
+		if !strings.EqualFold(key, CacheTagsHeader) {
+			httpReq.Header.Set(key, value)
+		}
+	}
+
+	if revalidating {
+		if stale.ETag != "" {
+			httpReq.Header.Set("If-None-Match", stale.ETag)
+		}
+		if stale.LastModified != "" {
+			httpReq.Header.Set("If-Modified-Since", stale.LastModified)
+		}
+	}
+
+	// Respect upstream rate limits; cache hits above never consume tokens
+	if opt.limiter != nil {
+		queued := time.Now()
+		err := opt.limiter.Wait(ctx, httpReq)
+		recordQueueTime(ctx, queued)
+		annotate(ctx, "rate_limit.wait_ms", float64(time.Since(queued).Microseconds())/1000.0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Consult the upstream host's circuit breaker before going to the network
+	annotate(ctx, "endpoint", httpReq.URL.Host)
+	var breaker *CircuitBreaker
+	if opt.circuits != nil {
+		breaker = opt.circuits.Get(httpReq.URL.Host)
+		annotate(ctx, "breaker.state", string(breaker.State()))
+		if err := breaker.Allow(); err != nil {
+			annotate(ctx, "breaker.rejected", true)
+			return nil, fmt.Errorf("%s: %w", httpReq.URL.Host, err)
+		}
 	}
 
 	// Execute request
+	fetchStart := time.Now()
 	httpResp, err := opt.httpClient.Do(httpReq)
+	recordUpstreamTime(ctx, fetchStart)
+	err = classifyTimeout(ctx, err)
+	if breaker != nil {
+		// A caller giving up early says nothing about the upstream's health
+		var timeoutErr *PhaseTimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr.Phase != PhaseCaller {
+			breaker.Record(err == nil && httpResp.StatusCode < 500)
+		} else {
+			breaker.Release()
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer httpResp.Body.Close()
+	annotate(ctx, "upstream.status", httpResp.StatusCode)
+	annotate(ctx, "upstream.protocol", httpResp.Proto)
+
+	if revalidating && httpResp.StatusCode == http.StatusNotModified {
+		// 304: the cached body is still current, only refresh its metadata
+		annotate(ctx, "cache", "revalidated")
+		stored := time.Now()
+		refreshed := opt.cache.Refresh(cacheKey, httpResp.Header)
+		if refreshed != nil {
+			refreshed.recordAccess()
+			opt.logger.LogCacheOperation("REVALIDATE", cacheKey, true)
+		} else {
+			// Evicted or invalidated since GetStale: the 304 still vouches for
+			// the stale body, but it is served without being stored again
+			refreshed = stale
+			opt.logger.LogCacheOperation("REVALIDATE", cacheKey, false)
+		}
+		recordOverheadPhase(ctx, OverheadCache, stored)
+		return &OptimizationResponse{
+			StatusCode:  refreshed.StatusCode,
+			Headers:     refreshed.Headers,
+			Body:        refreshed.Body,
+			CacheHit:    false,
+			Revalidated: true,
+			Optimized:   true,
+			Metadata: ResponseMetadata{
+				CacheStatus:      "revalidated",
+				OptimizationType: "conditional",
+				ConnectionReused: httpResp.Request.Response != nil,
+				HTTP2Used:        httpResp.ProtoMajor == 2,
+				TokenUsage:       refreshed.TokenUsage,
+			},
+		}, nil
+	}
+	cacheStatus := "miss"
+	if !useCache {
+		cacheStatus = "bypass"
+	} else if revalidating {
+		cacheStatus = "stale"
+		opt.logger.LogCacheOperation("REVALIDATE", cacheKey, false)
+	}
 
 	// Read response body
+	reading := time.Now()
 	body, err := io.ReadAll(httpResp.Body)
+	recordUpstreamTime(ctx, reading)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", classifyTimeout(ctx, err))
 	}
 
 	// Extract headers
@@ -109,15 +306,33 @@ func (opt *Optimizer) Optimize(req *OptimizationRequest) (*OptimizationResponse,
 	// Estimate token usage for this request/response
 	tokenUsage := estimateTokens(req.Body, body)
 
-	// Cache the response with token data
-	opt.cache.Set(cacheKey, &CacheEntry{
-		StatusCode: httpResp.StatusCode,
-		Headers:    headers,
-		Body:       body,
-		CachedAt:   time.Now(),
-		TokenUsage: tokenUsage,
-	})
-	opt.logger.LogCacheOperation("SET", cacheKey, true)
+	// Cache the response with token data and validators for later revalidation
+	if useCache {
+		stored := time.Now()
+		entry := &CacheEntry{
+			StatusCode:    httpResp.StatusCode,
+			Headers:       headers,
+			Body:          body,
+			CachedAt:      time.Now(),
+			Host:          httpReq.URL.Host,
+			TokenUsage:    tokenUsage,
+			ETag:          httpResp.Header.Get("ETag"),
+			LastModified:  httpResp.Header.Get("Last-Modified"),
+			FetchDuration: time.Since(fetchStart),
+			Route:         req.Method + " " + httpReq.URL.Path,
+			Tags:          parseCacheTags(requestHeaderValue(req.Headers, CacheTagsHeader)),
+		}
+		if isRange {
+			opt.ranges.store(opt.cache, cacheKey, entry)
+		} else {
+			if hasStale {
+				opt.cache.Adapt(stale, entry)
+			}
+			opt.cache.Set(cacheKey, entry)
+		}
+		opt.logger.LogCacheOperation("SET", cacheKey, true)
+		recordOverheadPhase(ctx, OverheadCache, stored)
+	}
 
 	return &OptimizationResponse{
 		StatusCode: httpResp.StatusCode,
@@ -126,7 +341,7 @@ func (opt *Optimizer) Optimize(req *OptimizationRequest) (*OptimizationResponse,
 		CacheHit:   false,
 		Optimized:  true,
 		Metadata: ResponseMetadata{
-			CacheStatus:      "miss",
+			CacheStatus:      cacheStatus,
 			OptimizationType: "http2+cache",
 			ConnectionReused: httpResp.Request.Response != nil,
 			HTTP2Used:        httpResp.ProtoMajor == 2,
@@ -144,6 +359,9 @@ func (opt *Optimizer) generateCacheKey(req *OptimizationRequest) string {
 
 	// Include relevant headers
 	for key, value := range req.Headers {
+		if strings.EqualFold(key, CacheTagsHeader) {
+			continue
+		}
 		hasher.Write([]byte(key))
 		hasher.Write([]byte(value))
 	}
@@ -155,6 +373,52 @@ func (opt *Optimizer) generateCacheKey(req *OptimizationRequest) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
+// Dedup returns the request deduplicator, or nil when deduplication is disabled
+func (opt *Optimizer) Dedup() *Deduplicator {
+	return opt.dedup
+}
+
+// EarlyRefreshStats returns the background refresh counters
+func (opt *Optimizer) EarlyRefreshStats() EarlyRefreshStats {
+	if opt.early == nil {
+		return EarlyRefreshStats{}
+	}
+	return opt.early.Stats()
+}
+
+// RangeCacheStats returns the Range request counters
+func (opt *Optimizer) RangeCacheStats() RangeCacheStats {
+	if opt.ranges == nil {
+		return RangeCacheStats{}
+	}
+	return opt.ranges.Stats(opt.cache)
+}
+
+// Circuits returns the per-host breaker registry, or nil when disabled
+func (opt *Optimizer) Circuits() *CircuitRegistry {
+	return opt.circuits
+}
+
+// RateLimiter returns the upstream rate limiter, or nil when disabled
+func (opt *Optimizer) RateLimiter() *RateLimiter {
+	return opt.limiter
+}
+
+// CacheEnabled reports whether requests are served from and stored in the cache
+func (opt *Optimizer) CacheEnabled() bool {
+	return !opt.cacheDisabled.Load()
+}
+
+// SetCacheEnabled turns caching on or off without dropping cached entries
+func (opt *Optimizer) SetCacheEnabled(enabled bool) {
+	opt.cacheDisabled.Store(!enabled)
+}
+
+// Cache returns the optimizer's response cache
+func (opt *Optimizer) Cache() *Cache {
+	return opt.cache
+}
+
 // InvalidateCache clears the entire cache
 func (opt *Optimizer) InvalidateCache() {
 	opt.cache.Clear()
@@ -191,8 +455,19 @@ type Cache struct {
 	maxMemory     int64
 	currentMemory int64
 	defaultTTL    time.Duration
+	ttlOverrides  map[string]time.Duration // Per upstream host
 	logger        *Logger
 	mu            sync.RWMutex
+
+	// Learned per-entry TTLs, nil unless enabled
+	adaptive              *AdaptiveTTLConfig
+	lengthened, shortened int64
+
+	// Eviction policy and counters; inflation is the GDSF clock
+	eviction                EvictionConfig
+	evictions, evictedBytes int64
+	evictedDollars          float64
+	inflation               float64
 }
 
 // CacheEntry represents a cached response
@@ -201,7 +476,46 @@ type CacheEntry struct {
 	Headers    map[string]string
 	Body       []byte
 	CachedAt   time.Time
+	Host       string // Upstream host, for per-host TTL overrides
 	TokenUsage *TokenUsage
+
+	// Validators used for conditional revalidation once the entry expires
+	ETag         string
+	LastModified string
+
+	// How long the upstream took to produce the entry; early refreshes of
+	// slower entries start sooner
+	FetchDuration time.Duration
+
+	// Set instead of Body while only some byte ranges of the object are cached
+	Ranges *rangeSet
+
+	// Shown on /cache/keys; usage is set when the entry is stored
+	Route string
+	Tags  []string
+	usage *entryUsage
+
+	// Learned by adaptive TTLs: the entry's TTL, zero for the default, and
+	// how many refetches compared its content and found it changed
+	TTL     time.Duration
+	Checks  int64
+	Changes int64
+
+	// GDSF clock when the entry was stored, the base of its priority
+	inflation float64
+}
+
+// size returns the memory the entry's content takes
+func (e *CacheEntry) size() int64 {
+	if e.Ranges != nil {
+		return e.Ranges.bytes()
+	}
+	return int64(len(e.Body))
+}
+
+// HasValidators reports whether the entry can be revalidated with a conditional GET
+func (e *CacheEntry) HasValidators() bool {
+	return e.ETag != "" || e.LastModified != ""
 }
 
 // NewCache creates a new cache
@@ -211,7 +525,9 @@ func NewCache(maxMemoryMB int64, defaultTTL time.Duration, logger *Logger) *Cach
 		maxMemory:     maxMemoryMB * 1024 * 1024,
 		currentMemory: 0,
 		defaultTTL:    defaultTTL,
+		ttlOverrides:  make(map[string]time.Duration),
 		logger:        logger,
+		eviction:      DefaultEvictionConfig(),
 	}
 }
 
@@ -226,66 +542,137 @@ func (c *Cache) Get(key string) (*CacheEntry, bool) {
 	}
 
 	// Check TTL
-	if time.Since(entry.CachedAt) > c.defaultTTL {
+	if time.Since(entry.CachedAt) > c.ttlFor(entry) {
 		return nil, false
 	}
 
 	return entry, true
 }
 
-// Set stores a value in the cache
-func (c *Cache) Set(key string, entry *CacheEntry) {
+// ttlFor returns how long entry stays fresh; callers hold c.mu
+func (c *Cache) ttlFor(entry *CacheEntry) time.Duration {
+	if ttl, ok := c.ttlOverrides[entry.Host]; ok {
+		return ttl
+	}
+	if c.adaptive != nil && entry.TTL > 0 {
+		return entry.TTL
+	}
+	return c.defaultTTL
+}
+
+// TTLRemaining returns how long entry stays fresh, negative once expired
+func (c *Cache) TTLRemaining(entry *CacheEntry) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ttlFor(entry) - time.Since(entry.CachedAt)
+}
+
+// DefaultTTL returns the TTL of entries without a host override
+func (c *Cache) DefaultTTL() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultTTL
+}
+
+// SetDefaultTTL changes the TTL of entries without a host override, including
+// entries already cached
+func (c *Cache) SetDefaultTTL(ttl time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.defaultTTL = ttl
+}
 
-	entrySize := int64(len(entry.Body))
+// SetTTLOverride sets the TTL of entries from host; a zero ttl removes the
+// override
+func (c *Cache) SetTTLOverride(host string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Check if we need to evict entries
-	if c.currentMemory+entrySize > c.maxMemory {
-		c.evictLRU(entrySize)
+	if ttl <= 0 {
+		delete(c.ttlOverrides, host)
+		return
 	}
+	c.ttlOverrides[host] = ttl
+}
 
-	c.data[key] = entry
-	c.currentMemory += entrySize
+// TTLOverrides returns a copy of the per-host TTL overrides
+func (c *Cache) TTLOverrides() map[string]time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	overrides := make(map[string]time.Duration, len(c.ttlOverrides))
+	for host, ttl := range c.ttlOverrides {
+		overrides[host] = ttl
+	}
+	return overrides
+}
+
+// GetStale retrieves a value from the cache regardless of its TTL
+func (c *Cache) GetStale(key string) (*CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, found := c.data[key]
+	return entry, found
 }
 
-// evictLRU evicts least recently used entries to make room
-func (c *Cache) evictLRU(neededSpace int64) {
-	// Find oldest entries
-	type entryAge struct {
-		key string
-		age time.Time
+// Refresh marks an entry as fresh again after a successful revalidation,
+// merging any updated validators from the 304 response headers
+func (c *Cache) Refresh(key string, header http.Header) *CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.data[key]
+	if !found {
+		return nil
 	}
 
-	var entries []entryAge
-	for k, v := range c.data {
-		entries = append(entries, entryAge{key: k, age: v.CachedAt})
+	refreshed := *entry
+	refreshed.CachedAt = time.Now()
+	if etag := header.Get("ETag"); etag != "" {
+		refreshed.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		refreshed.LastModified = lastModified
 	}
+	if c.adaptive != nil {
+		// A 304 confirms the content did not change
+		refreshed.TTL = c.adjustTTL(entry, false)
+		refreshed.Checks++
+	}
+	c.data[key] = &refreshed
 
-	// Sort by age (oldest first)
-	for i := 0; i < len(entries)-1; i++ {
-		for j := i + 1; j < len(entries); j++ {
-			if entries[i].age.After(entries[j].age) {
-				entries[i], entries[j] = entries[j], entries[i]
-			}
-		}
+	return &refreshed
+}
+
+// Set stores a value in the cache
+func (c *Cache) Set(key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, entry)
+}
+
+// setLocked stores entry under key; callers hold c.mu
+func (c *Cache) setLocked(key string, entry *CacheEntry) {
+	entrySize := entry.size()
+	if entry.usage == nil {
+		entry.usage = &entryUsage{}
 	}
+	entry.inflation = c.inflation
 
-	// Evict until we have enough space
-	freedSpace := int64(0)
-	for _, e := range entries {
-		if freedSpace >= neededSpace {
-			break
-		}
+	// A refreshed entry replaces the old one's memory
+	if old, exists := c.data[key]; exists {
+		c.currentMemory -= old.size()
+		delete(c.data, key)
+	}
 
-		if entry, exists := c.data[e.key]; exists {
-			entrySize := int64(len(entry.Body))
-			delete(c.data, e.key)
-			c.currentMemory -= entrySize
-			freedSpace += entrySize
-			c.logger.Debug("Cache eviction - Key: %s, Size: %d bytes", e.key[:min(16, len(e.key))], entrySize)
-		}
+	// Check if we need to evict entries
+	if c.currentMemory+entrySize > c.maxMemory {
+		c.evict(entrySize)
 	}
+
+	c.data[key] = entry
+	c.currentMemory += entrySize
 }
 
 // Clear clears all cache entries
@@ -315,14 +702,15 @@ func (c *Cache) GetStats() *CacheStats {
 
 	for key, entry := range c.data {
 		age := time.Since(entry.CachedAt)
-		ttlRemaining := c.defaultTTL - age
+		ttl := c.ttlFor(entry)
+		ttlRemaining := ttl - age
 
 		entries = append(entries, CacheEntryInfo{
 			Key:          key[:min(16, len(key))], // Truncate for display
-			SizeBytes:    int64(len(entry.Body)),
+			SizeBytes:    entry.size(),
 			Age:          age,
 			TTLRemaining: ttlRemaining,
-			Expired:      age > c.defaultTTL,
+			Expired:      age > ttl,
 		})
 	}
 
@@ -333,6 +721,7 @@ func (c *Cache) GetStats() *CacheStats {
 		MemoryPercent: float64(c.currentMemory) / float64(c.maxMemory) * 100,
 		DefaultTTL:    c.defaultTTL,
 		EntryDetails:  entries,
+		Eviction:      c.evictionStats(),
 	}
 }
 
@@ -344,6 +733,10 @@ type CacheStats struct {
 	MemoryPercent float64          `json:"memory_percent"`
 	DefaultTTL    time.Duration    `json:"default_ttl"`
 	EntryDetails  []CacheEntryInfo `json:"entry_details"`
+
+	EarlyRefresh EarlyRefreshStats `json:"early_refresh"`
+	Ranges       RangeCacheStats   `json:"ranges"`
+	Eviction     EvictionStats     `json:"eviction"`
 }
 
 // CacheEntryInfo holds information about a cache entry
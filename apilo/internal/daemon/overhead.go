@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// overheadSampleSize bounds the per-request overhead samples kept for percentiles
+const overheadSampleSize = 1000
+
+// Phases of the daemon's own work on a request. What is not timed as cache
+// lookups or recording, e.g. decoding, admission control and the handler
+// itself, is reported as other
+const (
+	OverheadCache     = "cache"     // Cache keys, lookups and stores
+	OverheadRecording = "recording" // Metrics, analytics, the journal, traces and mirroring
+	OverheadOther     = "other"
+)
+
+// overheadClockKey is the context key of a request's overheadClock
+type overheadClockKey struct{}
+
+// overheadClock accumulates the time a request spends waiting on the
+// upstream, or on an identical request's upstream call, and the timed
+// phases of the daemon's own work, so the time the daemon itself adds can be
+// told apart from the time it waits
+type overheadClock struct {
+	upstream  atomic.Int64
+	cache     atomic.Int64
+	recording atomic.Int64
+}
+
+// withOverheadClock returns ctx carrying an overhead clock, reusing one set
+// earlier in the request's path
+func withOverheadClock(ctx context.Context) (context.Context, *overheadClock) {
+	if clock, ok := ctx.Value(overheadClockKey{}).(*overheadClock); ok {
+		return ctx, clock
+	}
+	clock := &overheadClock{}
+	return context.WithValue(ctx, overheadClockKey{}, clock), clock
+}
+
+// recordUpstreamTime adds the time since start to ctx's upstream time, if
+// it has an overhead clock
+func recordUpstreamTime(ctx context.Context, start time.Time) {
+	if clock, ok := ctx.Value(overheadClockKey{}).(*overheadClock); ok {
+		clock.upstream.Add(int64(time.Since(start)))
+	}
+}
+
+// recordOverheadPhase adds the time since start to one phase of ctx's
+// overhead, if it has an overhead clock
+func recordOverheadPhase(ctx context.Context, phase string, start time.Time) {
+	clock, ok := ctx.Value(overheadClockKey{}).(*overheadClock)
+	if !ok {
+		return
+	}
+	switch phase {
+	case OverheadCache:
+		clock.cache.Add(int64(time.Since(start)))
+	case OverheadRecording:
+		clock.recording.Add(int64(time.Since(start)))
+	}
+}
+
+// measure splits a request that took elapsed, of which queued was spent in
+// admission control and the rate limiter, into the daemon's overhead
+func (c *overheadClock) measure(elapsed, queued time.Duration) requestOverhead {
+	overhead := requestOverhead{
+		cache:     time.Duration(c.cache.Load()),
+		recording: time.Duration(c.recording.Load()),
+	}
+	overhead.total = max(elapsed-queued-time.Duration(c.upstream.Load()), overhead.cache+overhead.recording)
+	return overhead
+}
+
+// serverTiming formats the request's time so far as a Server-Timing header,
+// so benchmarks through the daemon report its overhead next to the upstream
+// time; total is the daemon's whole share of the response
+func (c *overheadClock) serverTiming(elapsed, queued time.Duration) string {
+	overhead := c.measure(elapsed, queued)
+	return fmt.Sprintf("apilo-overhead;dur=%.3f, apilo-cache;dur=%.3f, apilo-queue;dur=%.3f, apilo-upstream;dur=%.3f, total;dur=%.3f",
+		milliseconds(overhead.total), milliseconds(overhead.cache), milliseconds(queued),
+		milliseconds(time.Duration(c.upstream.Load())), milliseconds(elapsed))
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+// requestOverhead is the time the daemon added to one request
+type requestOverhead struct {
+	total, cache, recording time.Duration
+}
+
+// OverheadTracker keeps the overhead of recent requests served through
+// /optimize. It is safe for concurrent use
+type OverheadTracker struct {
+	mu       sync.Mutex
+	samples  []requestOverhead
+	next     int
+	requests int64
+	max      time.Duration
+}
+
+// NewOverheadTracker returns an empty tracker
+func NewOverheadTracker() *OverheadTracker {
+	return &OverheadTracker{samples: make([]requestOverhead, 0, overheadSampleSize)}
+}
+
+// Record adds one request's overhead
+func (t *OverheadTracker) Record(overhead requestOverhead) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) < overheadSampleSize {
+		t.samples = append(t.samples, overhead)
+	} else {
+		t.samples[t.next] = overhead
+		t.next = (t.next + 1) % overheadSampleSize
+	}
+	t.requests++
+	t.max = max(t.max, overhead.total)
+}
+
+// OverheadStats is the time the daemon adds to requests on top of the
+// upstream and queueing, over the most recent samples
+type OverheadStats struct {
+	Requests int64         `json:"requests"`
+	Samples  int           `json:"samples"`
+	Avg      time.Duration `json:"avg"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"` // Since startup
+
+	// Average of each phase: cache, recording and other
+	Phases map[string]time.Duration `json:"phases"`
+}
+
+// Stats returns the overhead percentiles so far
+func (t *OverheadTracker) Stats() OverheadStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := OverheadStats{Requests: t.requests, Samples: len(t.samples), Max: t.max, Phases: map[string]time.Duration{}}
+	if len(t.samples) == 0 {
+		return stats
+	}
+
+	sorted := make([]time.Duration, len(t.samples))
+	var total, cache, recording time.Duration
+	for i, sample := range t.samples {
+		sorted[i] = sample.total
+		total += sample.total
+		cache += sample.cache
+		recording += sample.recording
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	count := time.Duration(len(sorted))
+	stats.Avg = total / count
+	stats.P50 = sorted[len(sorted)*50/100]
+	stats.P95 = sorted[len(sorted)*95/100]
+	stats.P99 = sorted[len(sorted)*99/100]
+	stats.Phases[OverheadCache] = cache / count
+	stats.Phases[OverheadRecording] = recording / count
+	stats.Phases[OverheadOther] = (total - cache - recording) / count
+	return stats
+}
@@ -0,0 +1,663 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// Peer event types
+const (
+	PeerEventInvalidate = "invalidate" // A replica cleared a cache
+	PeerEventHotKeys    = "hot_keys"   // URLs a replica serves often, to prefetch
+)
+
+// PeersConfig connects replicas of the daemon so a cache purge on one reaches
+// the others and each learns which URLs the rest find hot. Replicas form a
+// memberlist cluster on their own gossip port, which must be reachable from
+// the other hosts; new events are forwarded by the replicas that receive
+// them, so every replica hears of an event without all of them talking to
+// each other
+type PeersConfig struct {
+	Enabled bool     `yaml:"enabled" json:"enabled"`
+	Peers   []string `yaml:"peers" json:"peers"` // Gossip addresses of replicas to join, e.g. 10.0.0.2:7946
+
+	// This replica's name in the cluster and on /peers; the hostname when
+	// empty. Names must be unique across replicas
+	Name string `yaml:"name" json:"name"`
+
+	// host:port the gossip port listens on, TCP and UDP, and the address
+	// announced to the others when it differs, e.g. behind NAT
+	BindAddress      string `yaml:"bind_address" json:"bind_address"`
+	AdvertiseAddress string `yaml:"advertise_address" json:"advertise_address,omitempty"`
+
+	// Shared secret the gossip is encrypted and authenticated with;
+	// APILO_PEER_TOKEN is used when empty, and gossip does not start when
+	// neither is set
+	Token string `yaml:"token" json:"-"`
+
+	Interval time.Duration `yaml:"interval" json:"interval"` // Between hot key rounds and rejoin attempts
+	Fanout   int           `yaml:"fanout" json:"fanout"`     // Replicas each gossip message is sent to
+	MaxHops  int           `yaml:"max_hops" json:"max_hops"` // Forwards before an event stops spreading
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`   // Per hinted prefetch, and to announce leaving
+
+	// Hot keys are header-less GET URLs requested at least HotKeyMinHits
+	// times in a round; up to HotKeyLimit of them are hinted each round and
+	// peers prefetch the ones they have not cached. Zero disables hints
+	HotKeyLimit   int `yaml:"hot_key_limit" json:"hot_key_limit"`
+	HotKeyMinHits int `yaml:"hot_key_min_hits" json:"hot_key_min_hits"`
+}
+
+// DefaultPeerPort is the gossip port when BindAddress does not name one
+const DefaultPeerPort = 7946
+
+// DefaultPeersConfig returns disabled gossip on every interface's port 7946,
+// sending to 3 replicas and hinting up to 20 hot keys a second
+func DefaultPeersConfig() PeersConfig {
+	return PeersConfig{
+		BindAddress:   net.JoinHostPort("0.0.0.0", strconv.Itoa(DefaultPeerPort)),
+		Interval:      time.Second,
+		Fanout:        3,
+		MaxHops:       3,
+		Timeout:       2 * time.Second,
+		HotKeyLimit:   20,
+		HotKeyMinHits: 3,
+	}
+}
+
+// PeerEvent is one gossiped event
+type PeerEvent struct {
+	ID      string    `json:"id"`
+	Origin  string    `json:"origin"`
+	Type    string    `json:"type"`
+	Profile string    `json:"profile,omitempty"` // Empty for the daemon's own cache
+	URLs    []string  `json:"urls,omitempty"`    // Hot keys
+	Time    time.Time `json:"time"`
+	Hops    int       `json:"hops"`
+}
+
+// PeerInfo describes one cluster member on /peers
+type PeerInfo struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	State   string `json:"state"` // alive or suspect
+}
+
+// PeerStats describes gossip on /peers
+type PeerStats struct {
+	Enabled  bool       `json:"enabled"`
+	Name     string     `json:"name,omitempty"`
+	Address  string     `json:"address,omitempty"`
+	Peers    []PeerInfo `json:"peers"`
+	Sent     int64      `json:"events_sent"`
+	Received int64      `json:"events_received"`
+	Applied  int64      `json:"events_applied"`
+	Warmed   int64      `json:"hot_keys_warmed"`
+	Pending  int        `json:"pending"`
+}
+
+// peerSeenTTL is how long event IDs are remembered to drop duplicates
+const peerSeenTTL = 5 * time.Minute
+
+// peerMaxMessage keeps an encoded event within one gossip packet, which
+// memberlist caps at 1400 bytes including its own framing and encryption
+const peerMaxMessage = 1024
+
+// peerWarmQueue bounds the hot keys waiting to be prefetched
+const peerWarmQueue = 256
+
+// peerMaxHosts bounds the upstream hosts remembered per profile from local
+// traffic; hints for hosts past the limit are not prefetched
+const peerMaxHosts = 1024
+
+// peerWarm is a hinted URL to prefetch into a profile's cache
+type peerWarm struct {
+	profile string
+	url     string
+}
+
+// Peers gossips cache events with the daemon's other replicas
+type Peers struct {
+	config PeersConfig
+	name   string
+	logger *Logger
+
+	// apply acts on an event received from a peer; warm prefetches a URL
+	apply func(PeerEvent)
+	warm  func(ctx context.Context, profile, url string)
+
+	list  atomic.Pointer[memberlist.Memberlist] // Set by Start
+	queue *memberlist.TransmitLimitedQueue
+
+	mu       sync.Mutex
+	seen     map[string]time.Time
+	hot      map[peerWarm]int           // Header-less GET requests this round
+	hosts    map[string]map[string]bool // Upstream hosts each profile has served
+	joined   bool                       // Cleared by a failed join, so outages are logged once
+	sent     int64
+	received int64
+	applied  int64
+	warmed   int64
+
+	warmQueue chan peerWarm
+}
+
+// NewPeers creates gossip with the peers in config; apply handles received
+// events and warm prefetches hinted URLs. Nothing is sent until Start
+func NewPeers(config PeersConfig, logger *Logger, apply func(PeerEvent), warm func(ctx context.Context, profile, url string)) (*Peers, error) {
+	if len(config.Peers) == 0 {
+		return nil, fmt.Errorf("peer gossip requires at least one peer")
+	}
+	for _, peer := range config.Peers {
+		if strings.Contains(peer, "://") {
+			return nil, fmt.Errorf("peer %q must be a host:port gossip address, not a URL", peer)
+		}
+	}
+	if config.Interval <= 0 || config.Fanout <= 0 {
+		return nil, fmt.Errorf("peer gossip interval and fanout must be positive")
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv("APILO_PEER_TOKEN")
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("peer gossip requires a shared token: set peers.token or APILO_PEER_TOKEN")
+	}
+	name := config.Name
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	p := &Peers{
+		config:    config,
+		name:      name,
+		logger:    logger,
+		apply:     apply,
+		warm:      warm,
+		seen:      make(map[string]time.Time),
+		hot:       make(map[peerWarm]int),
+		hosts:     make(map[string]map[string]bool),
+		joined:    true, // Log the first failed join
+		warmQueue: make(chan peerWarm, peerWarmQueue),
+	}
+	p.queue = &memberlist.TransmitLimitedQueue{NumNodes: p.numMembers, RetransmitMult: 3}
+	return p, nil
+}
+
+// splitPeerAddress parses a host:port gossip address, where either part may
+// be empty
+func splitPeerAddress(address, defaultHost string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid gossip address %q: %w", address, err)
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	port := DefaultPeerPort
+	if portStr != "" {
+		if port, err = strconv.Atoi(portStr); err != nil {
+			return "", 0, fmt.Errorf("invalid gossip port in %q", address)
+		}
+	}
+	return host, port, nil
+}
+
+// Start opens the gossip port and joins the configured peers. Peers that are
+// not up yet are retried each Interval by Run
+func (p *Peers) Start() error {
+	conf := memberlist.DefaultLANConfig()
+	conf.Name = p.name
+	conf.GossipNodes = p.config.Fanout
+	conf.Delegate = peerDelegate{p}
+	conf.Events = peerDelegate{p}
+	conf.Logger = log.New(peerLogWriter{p.logger}, "", 0)
+
+	// The token becomes the AES-256 key; replicas without it cannot join,
+	// read or inject gossip
+	key := sha256.Sum256([]byte(p.config.Token))
+	conf.SecretKey = key[:]
+
+	var err error
+	if conf.BindAddr, conf.BindPort, err = splitPeerAddress(p.config.BindAddress, "0.0.0.0"); err != nil {
+		return err
+	}
+	if p.config.AdvertiseAddress != "" {
+		if conf.AdvertiseAddr, conf.AdvertisePort, err = splitPeerAddress(p.config.AdvertiseAddress, ""); err != nil {
+			return err
+		}
+	}
+
+	list, err := memberlist.Create(conf)
+	if err != nil {
+		return fmt.Errorf("failed to open gossip port %s: %w", p.config.BindAddress, err)
+	}
+	p.list.Store(list)
+	p.join()
+	return nil
+}
+
+// join contacts the configured peers until one answers
+func (p *Peers) join() {
+	list := p.list.Load()
+	_, err := list.Join(p.config.Peers)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		// Logged once per outage; replicas often start one by one
+		if p.joined {
+			p.logger.Warn("No gossip peer reachable: %v", err)
+		}
+		p.joined = false
+		return
+	}
+	if !p.joined {
+		p.logger.Info("Joined gossip cluster of %d replicas", list.NumMembers())
+	}
+	p.joined = true
+}
+
+// numMembers sizes retransmits to the cluster
+func (p *Peers) numMembers() int {
+	if list := p.list.Load(); list != nil {
+		return list.NumMembers()
+	}
+	return 1
+}
+
+// newPeerEventID returns a random event ID
+func newPeerEventID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Broadcast queues a local event to be gossiped
+func (p *Peers) Broadcast(eventType, profile string, urls []string) {
+	event := PeerEvent{
+		ID:      newPeerEventID(),
+		Origin:  p.name,
+		Type:    eventType,
+		Profile: profile,
+		URLs:    urls,
+		Time:    time.Now(),
+	}
+
+	p.mu.Lock()
+	p.seen[event.ID] = event.Time
+	p.mu.Unlock()
+	p.enqueue(event)
+}
+
+// enqueue hands an event to memberlist, which sends it with its next few
+// gossip messages
+func (p *Peers) enqueue(event PeerEvent) {
+	data, err := json.Marshal(event)
+	if err != nil || len(data) > peerMaxMessage {
+		p.logger.Warn("Dropping %s event of %d bytes: gossip messages are limited to %d", event.Type, len(data), peerMaxMessage)
+		return
+	}
+	p.queue.QueueBroadcast(peerBroadcast(data))
+
+	p.mu.Lock()
+	p.sent++
+	p.mu.Unlock()
+}
+
+// Observe remembers the upstream host of a request and counts it towards this
+// round's hot keys. Only GETs without headers are counted, so hints never
+// carry credentials
+func (p *Peers) Observe(profile string, req *OptimizationRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if target, err := url.Parse(req.URL); err == nil && target.Host != "" {
+		hosts := p.hosts[profile]
+		if hosts == nil {
+			hosts = make(map[string]bool)
+			p.hosts[profile] = hosts
+		}
+		if len(hosts) < peerMaxHosts {
+			hosts[strings.ToLower(target.Host)] = true
+		}
+	}
+
+	if p.config.HotKeyLimit <= 0 || len(req.Headers) > 0 || len(req.Body) > 0 {
+		return
+	}
+	if req.Method != "" && req.Method != http.MethodGet {
+		return
+	}
+	p.hot[peerWarm{profile: profile, url: req.URL}]++
+}
+
+// Served reports whether this replica has sent profile's traffic to host
+func (p *Peers) Served(profile, host string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hosts[profile][strings.ToLower(host)]
+}
+
+// receive handles a gossiped event: new events are applied and forwarded
+// until they reach MaxHops, and duplicates are dropped
+func (p *Peers) receive(event PeerEvent) {
+	p.mu.Lock()
+	p.received++
+	if _, ok := p.seen[event.ID]; ok || event.Origin == p.name {
+		p.mu.Unlock()
+		return
+	}
+	p.seen[event.ID] = time.Now()
+	p.applied++
+	p.mu.Unlock()
+
+	if event.Hops+1 < p.config.MaxHops {
+		forward := event
+		forward.Hops++
+		p.enqueue(forward)
+	}
+
+	switch event.Type {
+	case PeerEventHotKeys:
+		for _, hinted := range event.URLs {
+			select {
+			case p.warmQueue <- peerWarm{profile: event.Profile, url: hinted}:
+			default:
+			}
+		}
+	default:
+		p.apply(event)
+	}
+}
+
+// Run hints hot keys, retries joining while alone and prefetches hinted URLs
+// until ctx is done, then leaves the cluster
+func (p *Peers) Run(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-p.warmQueue:
+				p.warm(ctx, job.profile, job.url)
+				p.mu.Lock()
+				p.warmed++
+				p.mu.Unlock()
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.leave()
+			return
+		case <-ticker.C:
+			p.round()
+		}
+	}
+}
+
+// round queues this round's hot keys, forgets event IDs older than
+// peerSeenTTL and rejoins when every other replica is gone
+func (p *Peers) round() {
+	p.hintHotKeys()
+
+	p.mu.Lock()
+	for id, seen := range p.seen {
+		if time.Since(seen) > peerSeenTTL {
+			delete(p.seen, id)
+		}
+	}
+	p.mu.Unlock()
+
+	if p.numMembers() <= 1 {
+		p.join()
+	}
+}
+
+// leave tells the other replicas this one is going, so they stop gossiping
+// to it at once instead of waiting for it to fail probes
+func (p *Peers) leave() {
+	list := p.list.Load()
+	if list == nil {
+		return
+	}
+	// Replicas stopping together time out here, which is harmless
+	if err := list.Leave(p.config.Timeout); err != nil {
+		p.logger.Debug("Failed to announce leaving the gossip cluster: %v", err)
+	}
+	list.Shutdown()
+}
+
+// hintHotKeys queues hot keys events per profile from this round's counts,
+// split so each fits in a gossip message
+func (p *Peers) hintHotKeys() {
+	p.mu.Lock()
+	hot := p.hot
+	p.hot = make(map[peerWarm]int)
+	p.mu.Unlock()
+
+	byProfile := make(map[string][]peerWarm)
+	for key, hits := range hot {
+		if hits >= p.config.HotKeyMinHits {
+			byProfile[key.profile] = append(byProfile[key.profile], key)
+		}
+	}
+	for profile, keys := range byProfile {
+		sort.Slice(keys, func(i, j int) bool { return hot[keys[i]] > hot[keys[j]] })
+		if len(keys) > p.config.HotKeyLimit {
+			keys = keys[:p.config.HotKeyLimit]
+		}
+
+		// The event envelope takes about 200 bytes; a URL that cannot fit
+		// in a message of its own is not hinted
+		var urls []string
+		size := 0
+		for _, key := range keys {
+			if len(key.url) > peerMaxMessage/2 {
+				continue
+			}
+			if size+len(key.url) > peerMaxMessage/2 {
+				p.Broadcast(PeerEventHotKeys, profile, urls)
+				urls, size = nil, 0
+			}
+			urls = append(urls, key.url)
+			size += len(key.url) + 3
+		}
+		if len(urls) > 0 {
+			p.Broadcast(PeerEventHotKeys, profile, urls)
+		}
+	}
+}
+
+// Stats describes the cluster and event counters
+func (p *Peers) Stats() PeerStats {
+	p.mu.Lock()
+	stats := PeerStats{
+		Enabled:  true,
+		Name:     p.name,
+		Peers:    []PeerInfo{},
+		Sent:     p.sent,
+		Received: p.received,
+		Applied:  p.applied,
+		Warmed:   p.warmed,
+		Pending:  p.queue.NumQueued(),
+	}
+	p.mu.Unlock()
+
+	list := p.list.Load()
+	if list == nil {
+		return stats
+	}
+	stats.Address = list.LocalNode().Address()
+	for _, node := range list.Members() {
+		if node.Name == p.name {
+			continue
+		}
+		state := "alive"
+		if node.State == memberlist.StateSuspect {
+			state = "suspect"
+		}
+		stats.Peers = append(stats.Peers, PeerInfo{Name: node.Name, Address: node.Address(), State: state})
+	}
+	sort.Slice(stats.Peers, func(i, j int) bool { return stats.Peers[i].Name < stats.Peers[j].Name })
+	return stats
+}
+
+// peerBroadcast is one encoded event in memberlist's transmit queue
+type peerBroadcast []byte
+
+func (b peerBroadcast) Invalidates(memberlist.Broadcast) bool { return false }
+func (b peerBroadcast) Message() []byte                       { return b }
+func (b peerBroadcast) Finished()                             {}
+func (b peerBroadcast) UniqueBroadcast()                      {}
+
+// peerDelegate connects memberlist's callbacks to Peers
+type peerDelegate struct{ p *Peers }
+
+func (d peerDelegate) NodeMeta(limit int) []byte              { return nil }
+func (d peerDelegate) LocalState(join bool) []byte            { return nil }
+func (d peerDelegate) MergeRemoteState(buf []byte, join bool) {}
+func (d peerDelegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.p.queue.GetBroadcasts(overhead, limit)
+}
+
+// NotifyMsg decodes an event; the buffer is reused once it returns
+func (d peerDelegate) NotifyMsg(msg []byte) {
+	var event PeerEvent
+	if err := json.Unmarshal(msg, &event); err != nil {
+		d.p.logger.Warn("Ignoring malformed gossip message: %v", err)
+		return
+	}
+	d.p.receive(event)
+}
+
+func (d peerDelegate) NotifyJoin(node *memberlist.Node) {
+	if node.Name != d.p.name {
+		d.p.logger.Info("Peer %s joined from %s", node.Name, node.Address())
+	}
+}
+
+func (d peerDelegate) NotifyLeave(node *memberlist.Node) {
+	if node.Name != d.p.name {
+		d.p.logger.Info("Peer %s left", node.Name)
+	}
+}
+
+func (d peerDelegate) NotifyUpdate(node *memberlist.Node) {}
+
+// peerLogWriter routes memberlist's log lines to the daemon logger by the
+// level in their prefix
+type peerLogWriter struct{ logger *Logger }
+
+func (w peerLogWriter) Write(line []byte) (int, error) {
+	message := strings.TrimSpace(string(line))
+	switch {
+	case strings.HasPrefix(message, "[ERR]"):
+		w.logger.Error("%s", message)
+	case strings.HasPrefix(message, "[WARN]"):
+		w.logger.Warn("%s", message)
+	default:
+		w.logger.Debug("%s", message)
+	}
+	return len(line), nil
+}
+
+// peerOptimizer returns the optimizer of the named profile, or the daemon's
+// own when name is empty
+func (s *Service) peerOptimizer(name string) (*Optimizer, bool) {
+	if name == "" {
+		return s.optimizer, true
+	}
+	profile, ok := s.profiles.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return profile.optimizer, true
+}
+
+// InvalidateCache clears the cache of the named profile, or the daemon's own
+// when name is empty, and tells the other replicas to do the same
+func (s *Service) InvalidateCache(name string) error {
+	optimizer, ok := s.peerOptimizer(name)
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	optimizer.InvalidateCache()
+	if s.peers != nil {
+		s.peers.Broadcast(PeerEventInvalidate, name, nil)
+	}
+	return nil
+}
+
+// applyPeerEvent acts on an event gossiped by another replica
+func (s *Service) applyPeerEvent(event PeerEvent) {
+	optimizer, ok := s.peerOptimizer(event.Profile)
+	if !ok {
+		s.logger.Warn("Ignoring %s from peer %s: unknown profile %q", event.Type, event.Origin, event.Profile)
+		return
+	}
+	if event.Type == PeerEventInvalidate {
+		optimizer.InvalidateCache()
+		s.logger.Info("Cache invalidated by peer %s", event.Origin)
+	}
+}
+
+// peerHostAllowed reports whether a URL hinted for profile points at an
+// upstream the profile proxies: its base URL or shards, or a host this
+// replica has sent the profile's traffic to itself
+func (s *Service) peerHostAllowed(profile, rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return false
+	}
+	if profile != "" {
+		if p, ok := s.profiles.Get(profile); ok && p.Proxies(target.Host) {
+			return true
+		}
+	}
+	return s.peers.Served(profile, target.Host)
+}
+
+// warmFromPeer prefetches a URL another replica finds hot unless it is
+// cached already or points outside what the profile proxies. It goes
+// straight to the optimizer so it is not counted as traffic or hinted back
+func (s *Service) warmFromPeer(ctx context.Context, profile, hinted string) {
+	optimizer, ok := s.peerOptimizer(profile)
+	if !ok {
+		return
+	}
+	if !s.peerHostAllowed(profile, hinted) {
+		s.logger.Debug("Ignoring hot key %s hinted by a peer: host not proxied here", hinted)
+		return
+	}
+	req := &OptimizationRequest{URL: hinted, Method: http.MethodGet}
+	if _, cached := optimizer.Cache().Get(optimizer.generateCacheKey(req)); cached {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Peers.Timeout)
+	defer cancel()
+	if _, err := optimizer.OptimizeContext(ctx, req); err != nil {
+		s.logger.Debug("Failed to prefetch %s hinted by a peer: %v", hinted, err)
+	}
+}
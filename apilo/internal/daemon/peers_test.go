@@ -0,0 +1,224 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newTestPeers creates gossip that is never started, recording the events it
+// applies
+func newTestPeers(t *testing.T, config PeersConfig) (*Peers, *[]PeerEvent) {
+	t.Helper()
+	config.Name = "self"
+	config.Peers = []string{"127.0.0.1:7946"}
+	config.Token = "test-peer-token"
+
+	var applied []PeerEvent
+	peers, err := NewPeers(config, newTestLogger(t), func(event PeerEvent) {
+		applied = append(applied, event)
+	}, func(context.Context, string, string) {})
+	if err != nil {
+		t.Fatalf("NewPeers failed: %v", err)
+	}
+	return peers, &applied
+}
+
+// queuedEvents drains the events waiting to be gossiped
+func queuedEvents(t *testing.T, peers *Peers) []PeerEvent {
+	t.Helper()
+	var events []PeerEvent
+	for _, msg := range peers.queue.GetBroadcasts(0, 1<<20) {
+		if len(msg) > peerMaxMessage {
+			t.Errorf("Expected messages of at most %d bytes, got %d", peerMaxMessage, len(msg))
+		}
+		var event PeerEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("Failed to decode queued event: %v", err)
+		}
+		events = append(events, event)
+	}
+	peers.queue.Reset()
+	return events
+}
+
+func TestPeersReceive(t *testing.T) {
+	peers, applied := newTestPeers(t, DefaultPeersConfig())
+
+	event := PeerEvent{ID: "event-1", Origin: "other", Type: PeerEventInvalidate}
+	peers.receive(event)
+	peers.receive(event)
+
+	if len(*applied) != 1 {
+		t.Fatalf("Expected duplicate to be applied once, got %d", len(*applied))
+	}
+	forwarded := queuedEvents(t, peers)
+	if len(forwarded) != 1 || forwarded[0].ID != "event-1" || forwarded[0].Hops != 1 {
+		t.Errorf("Expected event-1 forwarded once with 1 hop, got %+v", forwarded)
+	}
+
+	peers.receive(PeerEvent{ID: "event-2", Origin: "self", Type: PeerEventInvalidate})
+	if len(*applied) != 1 {
+		t.Error("Expected own events to be ignored")
+	}
+	if forwarded := queuedEvents(t, peers); len(forwarded) != 0 {
+		t.Errorf("Expected own events not to be forwarded, got %+v", forwarded)
+	}
+
+	stats := peers.Stats()
+	if stats.Received != 3 || stats.Applied != 1 {
+		t.Errorf("Expected 3 received and 1 applied, got %d and %d", stats.Received, stats.Applied)
+	}
+}
+
+func TestPeersReceiveForwardsUntilMaxHops(t *testing.T) {
+	config := DefaultPeersConfig()
+	config.MaxHops = 3
+	peers, applied := newTestPeers(t, config)
+
+	tests := []struct {
+		hops      int
+		forwarded bool
+	}{
+		{0, true},
+		{1, true},
+		{2, false},
+		{5, false},
+	}
+	for _, tt := range tests {
+		peers.receive(PeerEvent{ID: fmt.Sprintf("hops-%d", tt.hops), Origin: "other", Type: PeerEventInvalidate, Hops: tt.hops})
+
+		forwarded := queuedEvents(t, peers)
+		if tt.forwarded && (len(forwarded) != 1 || forwarded[0].Hops != tt.hops+1) {
+			t.Errorf("Expected event at %d hops forwarded with %d, got %+v", tt.hops, tt.hops+1, forwarded)
+		}
+		if !tt.forwarded && len(forwarded) != 0 {
+			t.Errorf("Expected event at %d hops not forwarded, got %+v", tt.hops, forwarded)
+		}
+	}
+	if len(*applied) != len(tests) {
+		t.Errorf("Expected every event applied locally, got %d", len(*applied))
+	}
+}
+
+func TestPeersReceiveHotKeys(t *testing.T) {
+	peers, applied := newTestPeers(t, DefaultPeersConfig())
+
+	peers.receive(PeerEvent{ID: "hot-1", Origin: "other", Type: PeerEventHotKeys, Profile: "api", URLs: []string{"https://a.example/1", "https://a.example/2"}})
+
+	if len(*applied) != 0 {
+		t.Errorf("Expected hot keys to be queued for warming, not applied, got %+v", *applied)
+	}
+	if queued := len(peers.warmQueue); queued != 2 {
+		t.Fatalf("Expected 2 URLs queued to warm, got %d", queued)
+	}
+	if job := <-peers.warmQueue; job.profile != "api" || job.url != "https://a.example/1" {
+		t.Errorf("Expected api https://a.example/1, got %+v", job)
+	}
+}
+
+func TestPeersHintHotKeys(t *testing.T) {
+	config := DefaultPeersConfig()
+	config.HotKeyLimit = 100
+	config.HotKeyMinHits = 2
+	peers, _ := newTestPeers(t, config)
+
+	want := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		url := fmt.Sprintf("https://api.example.com/items/%03d?%s", i, strings.Repeat("q", 60))
+		want[url] = true
+		for hit := 0; hit < 2; hit++ {
+			peers.Observe("api", &OptimizationRequest{URL: url, Method: "GET"})
+		}
+	}
+	peers.Observe("api", &OptimizationRequest{URL: "https://api.example.com/cold"})
+	oversized := "https://api.example.com/" + strings.Repeat("x", peerMaxMessage)
+	for hit := 0; hit < 2; hit++ {
+		peers.Observe("api", &OptimizationRequest{URL: oversized})
+		peers.Observe("api", &OptimizationRequest{URL: "https://api.example.com/auth", Headers: map[string]string{"Authorization": "secret"}})
+		peers.Observe("api", &OptimizationRequest{URL: "https://api.example.com/post", Method: "POST"})
+	}
+
+	peers.hintHotKeys()
+	events := queuedEvents(t, peers)
+	if len(events) < 2 {
+		t.Fatalf("Expected hot keys split over several messages, got %d", len(events))
+	}
+
+	got := make(map[string]bool)
+	for _, event := range events {
+		if event.Type != PeerEventHotKeys || event.Profile != "api" || event.Origin != "self" {
+			t.Errorf("Expected api hot keys from self, got %+v", event)
+		}
+		for _, url := range event.URLs {
+			if got[url] {
+				t.Errorf("Expected %s hinted once", url)
+			}
+			got[url] = true
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d hot keys, got %d", len(want), len(got))
+	}
+	for url := range got {
+		if !want[url] {
+			t.Errorf("Expected only hot header-less GETs, got %s", url)
+		}
+	}
+
+	peers.hintHotKeys()
+	if events := queuedEvents(t, peers); len(events) != 0 {
+		t.Errorf("Expected counts reset each round, got %d events", len(events))
+	}
+}
+
+func TestPeerHostAllowed(t *testing.T) {
+	config := DefaultDaemonConfig()
+	config.Profiles = []UpstreamProfile{{Name: "api", BaseURL: "https://api.example.com"}}
+	config.Peers.Enabled = true
+	config.Peers.Peers = []string{"127.0.0.1:7946"}
+	config.Peers.Token = "test-peer-token"
+	service := newTestService(t, config)
+
+	service.peers.Observe("", &OptimizationRequest{URL: "https://Other.example.com/data"})
+
+	tests := []struct {
+		profile string
+		url     string
+		allowed bool
+	}{
+		{"api", "https://api.example.com/users", true},
+		{"api", "https://API.example.com/users", true},
+		{"api", "https://evil.example.com/users", false},
+		{"api", "https://other.example.com/data", false},
+		{"", "https://other.example.com/data", true},
+		{"", "http://other.example.com/other", true},
+		{"", "https://api.example.com/users", false},
+		{"", "file:///etc/passwd", false},
+		{"", "ftp://other.example.com/data", false},
+		{"", "/relative", false},
+	}
+	for _, tt := range tests {
+		if got := service.peerHostAllowed(tt.profile, tt.url); got != tt.allowed {
+			t.Errorf("Expected peerHostAllowed(%q, %q) = %v, got %v", tt.profile, tt.url, tt.allowed, got)
+		}
+	}
+}
+
+func TestPeersServed(t *testing.T) {
+	peers, _ := newTestPeers(t, DefaultPeersConfig())
+
+	peers.Observe("api", &OptimizationRequest{URL: "https://Shard-1.example.com/x", Method: "POST"})
+
+	if !peers.Served("api", "shard-1.example.com") {
+		t.Error("Expected host served for api regardless of case or method")
+	}
+	if peers.Served("", "shard-1.example.com") {
+		t.Error("Expected hosts remembered per profile")
+	}
+	if peers.Served("api", "shard-2.example.com") {
+		t.Error("Expected unseen host not served")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // PIDManager handles process ID file management
@@ -102,6 +103,45 @@ func (pm *PIDManager) Stop() error {
 	return nil
 }
 
+// StopWait sends SIGTERM and waits up to timeout for the daemon to drain and
+// exit, then kills it. It reports whether the kill was needed
+func (pm *PIDManager) StopWait(timeout time.Duration) (bool, error) {
+	pid, err := pm.Read()
+	if err != nil {
+		return false, err
+	}
+	if err := pm.Stop(); err != nil {
+		return false, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return false, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false, fmt.Errorf("process not found: %w", err)
+	}
+	if err := process.Signal(syscall.SIGKILL); err != nil && processAlive(pid) {
+		return true, fmt.Errorf("failed to kill daemon: %w", err)
+	}
+	pm.Remove()
+	return true, nil
+}
+
+// processAlive reports whether pid exists, by sending it signal 0
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
 // Restart restarts the daemon
 func (pm *PIDManager) Restart() error {
 	// Stop existing process
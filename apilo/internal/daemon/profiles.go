@@ -0,0 +1,300 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// UpstreamProfile configures one named upstream hosted by the daemon. Each
+// profile has its own cache namespace, circuit breakers, rate limits and
+// analytics; zero-valued settings inherit the daemon-wide configuration
+type UpstreamProfile struct {
+	Name    string            `yaml:"name" json:"name"`
+	BaseURL string            `yaml:"base_url" json:"base_url"`         // Resolves relative request URLs
+	Port    int               `yaml:"port" json:"port,omitempty"`       // Dedicated listening port (0 = path prefix only)
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"` // Sent upstream unless the caller sets them
+
+	CacheMaxMemoryMB int64         `yaml:"cache_max_memory_mb" json:"cache_max_memory_mb,omitempty"`
+	CacheDefaultTTL  time.Duration `yaml:"cache_default_ttl" json:"cache_default_ttl,omitempty"`
+
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker,omitempty"`
+	RateLimit      *RateLimitConfig      `yaml:"rate_limit" json:"rate_limit,omitempty"`
+	Timeouts       *PhaseTimeouts        `yaml:"timeouts" json:"timeouts,omitempty"`
+
+	// Mirrors this profile's traffic; the daemon-wide mirror is not inherited
+	// since its shadow stands in for a different upstream
+	Mirror *MirrorConfig `yaml:"mirror" json:"mirror,omitempty"`
+
+	// Spreads requests over upstream shards by consistent hashing
+	Sharding *ShardingConfig `yaml:"sharding" json:"sharding,omitempty"`
+}
+
+// ProfilePathPrefix is where a profile's endpoints are served on the main port
+const ProfilePathPrefix = "/profiles/"
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Profile is a running upstream profile with isolated state
+type Profile struct {
+	config    UpstreamProfile
+	baseURL   *url.URL
+	optimizer *Optimizer
+	metrics   *Metrics
+	analytics *Analytics
+	mirror    *Mirror
+	shards    *ShardRing // nil unless sharded
+}
+
+// ProfileInfo summarizes a profile for listings
+type ProfileInfo struct {
+	Name          string        `json:"name"`
+	BaseURL       string        `json:"base_url"`
+	Port          int           `json:"port,omitempty"`
+	PathPrefix    string        `json:"path_prefix"`
+	TotalRequests int64         `json:"total_requests"`
+	CacheHitRatio float64       `json:"cache_hit_ratio"`
+	AvgLatency    time.Duration `json:"avg_latency"`
+	CacheEntries  int           `json:"cache_entries"`
+}
+
+// profileDaemonConfig derives the effective configuration of profile from the
+// daemon-wide base
+func profileDaemonConfig(base *DaemonConfig, profile UpstreamProfile) *DaemonConfig {
+	config := *base
+	config.Profiles = nil
+	if profile.CacheMaxMemoryMB > 0 {
+		config.CacheMaxMemoryMB = profile.CacheMaxMemoryMB
+	}
+	if profile.CacheDefaultTTL > 0 {
+		config.CacheDefaultTTL = profile.CacheDefaultTTL
+	}
+	if profile.CircuitBreaker != nil {
+		config.CircuitBreaker = *profile.CircuitBreaker
+	}
+	if profile.RateLimit != nil {
+		config.RateLimit = *profile.RateLimit
+	}
+	if profile.Timeouts != nil {
+		config.Timeouts = *profile.Timeouts
+	}
+	return &config
+}
+
+// newProfile builds the isolated optimizer, metrics and analytics of a profile
+func newProfile(base *DaemonConfig, config UpstreamProfile, logger *Logger) (*Profile, error) {
+	baseURL, err := url.Parse(config.BaseURL)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("profile %s: invalid base_url %q", config.Name, config.BaseURL)
+	}
+
+	optimizer, err := NewOptimizer(profileDaemonConfig(base, config), logger)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", config.Name, err)
+	}
+
+	var mirror *Mirror
+	if config.Mirror != nil {
+		if mirror, err = NewMirror(*config.Mirror, logger); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", config.Name, err)
+		}
+	}
+
+	var shards *ShardRing
+	if config.Sharding != nil {
+		if shards, err = NewShardRing(*config.Sharding); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", config.Name, err)
+		}
+	}
+
+	return &Profile{
+		config:    config,
+		baseURL:   baseURL,
+		optimizer: optimizer,
+		metrics:   NewMetrics(),
+		analytics: NewAnalytics(1000),
+		mirror:    mirror,
+		shards:    shards,
+	}, nil
+}
+
+// Name returns the profile name
+func (p *Profile) Name() string {
+	return p.config.Name
+}
+
+// Config returns the profile configuration
+func (p *Profile) Config() UpstreamProfile {
+	return p.config
+}
+
+// PathPrefix returns the prefix of the profile's endpoints on the main port
+func (p *Profile) PathPrefix() string {
+	return ProfilePathPrefix + p.config.Name
+}
+
+// prepare resolves a relative request URL against the profile's base URL, or
+// the shard owning the request when the profile is sharded, and adds the
+// profile's default headers
+func (p *Profile) prepare(req *OptimizationRequest) error {
+	target, err := url.Parse(req.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", req.URL, err)
+	}
+
+	if len(p.config.Headers) > 0 && req.Headers == nil {
+		req.Headers = make(map[string]string, len(p.config.Headers))
+	}
+	for key, value := range p.config.Headers {
+		if _, set := req.Headers[key]; !set {
+			req.Headers[key] = value
+		}
+	}
+
+	if !target.IsAbs() {
+		base := p.baseURL
+		if p.shards != nil {
+			base = p.shards.Pick(req, target, p.optimizer.Circuits())
+		}
+		req.URL = base.JoinPath(target.Path).String()
+		if target.RawQuery != "" {
+			req.URL += "?" + target.RawQuery
+		}
+	}
+	return nil
+}
+
+// Proxies reports whether host is the profile's base URL host or one of its
+// shards
+func (p *Profile) Proxies(host string) bool {
+	if strings.EqualFold(host, p.baseURL.Host) {
+		return true
+	}
+	if p.shards == nil {
+		return false
+	}
+	for shardHost := range p.shards.byHost {
+		if strings.EqualFold(host, shardHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordShard counts a request towards the shard it was sent to
+func (p *Profile) recordShard(req *OptimizationRequest, latency time.Duration, cacheHit bool, err error) {
+	if p.shards == nil {
+		return
+	}
+	if target, parseErr := url.Parse(req.URL); parseErr == nil {
+		p.shards.Record(target.Host, latency, cacheHit, err)
+	}
+}
+
+// ShardStats describes the profile's shards, or nil when it is not sharded
+func (p *Profile) ShardStats() []ShardStats {
+	if p.shards == nil {
+		return nil
+	}
+	return p.shards.Stats(p.optimizer.Circuits())
+}
+
+// Info summarizes the profile's configuration and traffic
+func (p *Profile) Info() ProfileInfo {
+	stats := p.metrics.GetStats()
+	return ProfileInfo{
+		Name:          p.config.Name,
+		BaseURL:       p.config.BaseURL,
+		Port:          p.config.Port,
+		PathPrefix:    p.PathPrefix(),
+		TotalRequests: stats.TotalRequests,
+		CacheHitRatio: stats.CacheHitRatio,
+		AvgLatency:    stats.AvgLatency,
+		CacheEntries:  p.optimizer.cache.Size(),
+	}
+}
+
+// ProfileRegistry holds the daemon's upstream profiles in configuration order
+type ProfileRegistry struct {
+	profiles map[string]*Profile
+	order    []*Profile
+}
+
+// NewProfileRegistry validates and builds every profile in config.Profiles
+func NewProfileRegistry(config *DaemonConfig, logger *Logger) (*ProfileRegistry, error) {
+	registry := &ProfileRegistry{profiles: make(map[string]*Profile)}
+	ports := map[int]string{config.Port: "the daemon"}
+
+	for _, profileConfig := range config.Profiles {
+		if !profileNamePattern.MatchString(profileConfig.Name) {
+			return nil, fmt.Errorf("invalid profile name %q: use lowercase letters, digits, '-' and '_'", profileConfig.Name)
+		}
+		if _, exists := registry.profiles[profileConfig.Name]; exists {
+			return nil, fmt.Errorf("duplicate profile %q", profileConfig.Name)
+		}
+		if profileConfig.Port != 0 {
+			if owner, taken := ports[profileConfig.Port]; taken {
+				return nil, fmt.Errorf("profile %s: port %d already used by %s", profileConfig.Name, profileConfig.Port, owner)
+			}
+			ports[profileConfig.Port] = "profile " + profileConfig.Name
+		}
+
+		profile, err := newProfile(config, profileConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+		registry.profiles[profile.Name()] = profile
+		registry.order = append(registry.order, profile)
+	}
+
+	return registry, nil
+}
+
+// Get returns the named profile
+func (r *ProfileRegistry) Get(name string) (*Profile, bool) {
+	profile, ok := r.profiles[name]
+	return profile, ok
+}
+
+// List returns every profile in configuration order
+func (r *ProfileRegistry) List() []*Profile {
+	return r.order
+}
+
+// Infos summarizes every profile
+func (r *ProfileRegistry) Infos() []ProfileInfo {
+	infos := make([]ProfileInfo, 0, len(r.order))
+	for _, profile := range r.order {
+		infos = append(infos, profile.Info())
+	}
+	return infos
+}
+
+// LoadProfiles reads a JSON array of profiles from path. A missing file is not
+// an error and yields no profiles
+func LoadProfiles(path string) ([]UpstreamProfile, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var profiles []UpstreamProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles %s: %w", path, err)
+	}
+	return profiles, nil
+}
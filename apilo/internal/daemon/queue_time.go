@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// queueClockKey is the context key of a request's queueClock
+type queueClockKey struct{}
+
+// queueClock accumulates the time a request spends queued, in admission
+// control or waiting for rate limit tokens, so it can be reported apart from
+// the time spent being served
+type queueClock struct {
+	queued atomic.Int64
+}
+
+// withQueueClock returns ctx carrying a queue clock, reusing one set earlier
+// in the request's path
+func withQueueClock(ctx context.Context) (context.Context, *queueClock) {
+	if clock, ok := ctx.Value(queueClockKey{}).(*queueClock); ok {
+		return ctx, clock
+	}
+	clock := &queueClock{}
+	return context.WithValue(ctx, queueClockKey{}, clock), clock
+}
+
+// recordQueueTime adds the time since start to ctx's queue clock, if any
+func recordQueueTime(ctx context.Context, start time.Time) {
+	if clock, ok := ctx.Value(queueClockKey{}).(*queueClock); ok {
+		clock.queued.Add(int64(time.Since(start)))
+	}
+}
+
+// Queued returns the total time queued so far
+func (c *queueClock) Queued() time.Duration {
+	return time.Duration(c.queued.Load())
+}
@@ -0,0 +1,397 @@
+package daemon
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RangeCacheConfig caches byte-range (206) responses, e.g. large artifact
+// downloads resumed or fetched in parts. Ranges of one object are merged into
+// a single entry that serves later Range requests it covers, and becomes an
+// ordinary cached object once every byte has been seen
+type RangeCacheConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Objects larger than this are passed through uncached; zero leaves them
+	// bounded only by the cache's memory limit
+	MaxObjectMB int64 `yaml:"max_object_mb" json:"max_object_mb"`
+}
+
+// DefaultRangeCacheConfig returns a disabled range cache for objects up to 100 MB
+func DefaultRangeCacheConfig() RangeCacheConfig {
+	return RangeCacheConfig{
+		MaxObjectMB: 100,
+	}
+}
+
+// RangeCacheStats counts Range requests
+type RangeCacheStats struct {
+	Enabled   bool  `json:"enabled"`
+	Hits      int64 `json:"hits"`      // Served from a cached object or its ranges
+	Misses    int64 `json:"misses"`    // Forwarded upstream
+	Stored    int64 `json:"stored"`    // Upstream ranges added to the cache
+	Assembled int64 `json:"assembled"` // Objects completed from ranges
+	Bypassed  int64 `json:"bypassed"`  // Multi-range, If-Range and oversized requests
+
+	// Objects of which only some ranges are cached, and the bytes they hold
+	PartialObjects int   `json:"partial_objects"`
+	PartialBytes   int64 `json:"partial_bytes"`
+}
+
+// rangeCache serves single byte-range GETs from whole or partial objects
+type rangeCache struct {
+	config RangeCacheConfig
+
+	hits, misses, stored, assembled, bypassed atomic.Int64
+}
+
+// newRangeCache creates a range cache for config
+func newRangeCache(config RangeCacheConfig) *rangeCache {
+	return &rangeCache{config: config}
+}
+
+// byteRange is a single range from a Range header: first-last, first- (last
+// is -1), or the final suffix bytes (first is -1)
+type byteRange struct {
+	first, last, suffix int64
+}
+
+// parseByteRange parses a single "bytes=" range; multiple ranges, other units
+// and malformed values are not cacheable
+func parseByteRange(header string) (byteRange, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return byteRange{}, false
+	}
+	firstText, lastText, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return byteRange{}, false
+	}
+
+	if firstText == "" {
+		suffix, err := strconv.ParseInt(lastText, 10, 64)
+		if err != nil || suffix <= 0 {
+			return byteRange{}, false
+		}
+		return byteRange{first: -1, last: -1, suffix: suffix}, true
+	}
+	first, err := strconv.ParseInt(firstText, 10, 64)
+	if err != nil || first < 0 {
+		return byteRange{}, false
+	}
+	if lastText == "" {
+		return byteRange{first: first, last: -1}, true
+	}
+	last, err := strconv.ParseInt(lastText, 10, 64)
+	if err != nil || last < first {
+		return byteRange{}, false
+	}
+	return byteRange{first: first, last: last}, true
+}
+
+// resolve returns the inclusive offsets the range selects in an object of
+// size bytes, or false if it selects none
+func (r byteRange) resolve(size int64) (int64, int64, bool) {
+	if r.first < 0 {
+		if size == 0 {
+			return 0, 0, false
+		}
+		return max(size-r.suffix, 0), size - 1, true
+	}
+	if r.first >= size {
+		return 0, 0, false
+	}
+	last := r.last
+	if last < 0 || last >= size {
+		last = size - 1
+	}
+	return r.first, last, true
+}
+
+// parseContentRange parses a 206 response's "bytes first-last/size"; an
+// unknown size is not cacheable
+func parseContentRange(header string) (first, last, size int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	span, sizeText, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	firstText, lastText, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	var err1, err2, err3 error
+	first, err1 = strconv.ParseInt(firstText, 10, 64)
+	last, err2 = strconv.ParseInt(lastText, 10, 64)
+	size, err3 = strconv.ParseInt(sizeText, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || first < 0 || last < first || last >= size {
+		return 0, 0, 0, false
+	}
+	return first, last, size, true
+}
+
+// rangeRequest extracts a cacheable range from req. It returns false for
+// requests without one and counts those it bypasses
+func (rc *rangeCache) rangeRequest(req *OptimizationRequest) (byteRange, bool) {
+	header := requestHeaderValue(req.Headers, "Range")
+	if req.Method != http.MethodGet || header == "" {
+		return byteRange{}, false
+	}
+	// If-Range makes the answer depend on the upstream's current version
+	r, ok := parseByteRange(header)
+	if !ok || requestHeaderValue(req.Headers, "If-Range") != "" {
+		rc.bypassed.Add(1)
+		return byteRange{}, false
+	}
+	return r, true
+}
+
+// objectKey keys the whole object a Range request reads from, so it matches
+// the entry of a plain GET for the same object
+func (opt *Optimizer) objectKey(req *OptimizationRequest) string {
+	object := *req
+	object.Headers = maps.Clone(req.Headers)
+	for name := range object.Headers {
+		if strings.EqualFold(name, "Range") {
+			delete(object.Headers, name)
+		}
+	}
+	return opt.generateCacheKey(&object)
+}
+
+// serve answers r from the object cached under key, or returns nil if
+// the cache does not hold every byte requested
+func (rc *rangeCache) serve(cache *Cache, key string, r byteRange) *OptimizationResponse {
+	entry, found := cache.Get(key)
+	if !found || (entry.Ranges == nil && entry.StatusCode != http.StatusOK) {
+		rc.misses.Add(1)
+		return nil
+	}
+
+	size := int64(len(entry.Body))
+	if entry.Ranges != nil {
+		size = entry.Ranges.Size
+	}
+	first, last, ok := r.resolve(size)
+	if !ok {
+		rc.hits.Add(1)
+		return rangeResponse(entry, http.StatusRequestedRangeNotSatisfiable, nil,
+			fmt.Sprintf("bytes */%d", size))
+	}
+
+	body := entry.Body
+	if entry.Ranges != nil {
+		if body, ok = entry.Ranges.read(first, last); !ok {
+			rc.misses.Add(1)
+			return nil
+		}
+	} else {
+		body = body[first : last+1]
+	}
+	rc.hits.Add(1)
+	entry.recordHit()
+	return rangeResponse(entry, http.StatusPartialContent, body,
+		fmt.Sprintf("bytes %d-%d/%d", first, last, size))
+}
+
+// rangeResponse answers a Range request from entry
+func rangeResponse(entry *CacheEntry, status int, body []byte, contentRange string) *OptimizationResponse {
+	headers := make(map[string]string, len(entry.Headers)+1)
+	for name, value := range entry.Headers {
+		if !strings.EqualFold(name, "Content-Length") && !strings.EqualFold(name, "Content-Range") {
+			headers[name] = value
+		}
+	}
+	headers["Content-Range"] = contentRange
+	headers["Content-Length"] = strconv.Itoa(len(body))
+	return &OptimizationResponse{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       body,
+		CacheHit:   true,
+		Optimized:  true,
+		Metadata: ResponseMetadata{
+			CacheStatus:      "hit",
+			OptimizationType: "cached_range",
+			ConnectionReused: true,
+		},
+	}
+}
+
+// store caches an upstream answer to a Range request under the object's key:
+// a 200 is the whole object, a 206 one range of it
+func (rc *rangeCache) store(cache *Cache, key string, template *CacheEntry) {
+	limit := rc.config.MaxObjectMB * 1024 * 1024
+	switch template.StatusCode {
+	case http.StatusOK:
+		if limit > 0 && int64(len(template.Body)) > limit {
+			rc.bypassed.Add(1)
+			return
+		}
+		cache.Set(key, template)
+	case http.StatusPartialContent:
+		first, last, size, ok := parseContentRange(requestHeaderValue(template.Headers, "Content-Range"))
+		if !ok || last-first+1 != int64(len(template.Body)) || (limit > 0 && size > limit) {
+			rc.bypassed.Add(1)
+			return
+		}
+		rc.stored.Add(1)
+		if cache.MergeRange(key, first, size, template) {
+			rc.assembled.Add(1)
+		}
+	}
+}
+
+// Stats returns the range counters with the partial objects held by cache
+func (rc *rangeCache) Stats(cache *Cache) RangeCacheStats {
+	objects, bytes := cache.partialObjects()
+	return RangeCacheStats{
+		Enabled:        true,
+		Hits:           rc.hits.Load(),
+		Misses:         rc.misses.Load(),
+		Stored:         rc.stored.Load(),
+		Assembled:      rc.assembled.Load(),
+		Bypassed:       rc.bypassed.Load(),
+		PartialObjects: objects,
+		PartialBytes:   bytes,
+	}
+}
+
+// rangeSet holds the cached parts of an object. Sets are never modified in
+// place, since responses may still be reading from them
+type rangeSet struct {
+	Size     int64          // Length of the whole object
+	Segments []rangeSegment // Sorted, neither overlapping nor adjacent
+}
+
+// rangeSegment is a contiguous run of an object's bytes
+type rangeSegment struct {
+	Start int64
+	Data  []byte
+}
+
+// end returns the offset just past the segment
+func (s rangeSegment) end() int64 {
+	return s.Start + int64(len(s.Data))
+}
+
+// bytes returns the number of bytes held
+func (s *rangeSet) bytes() int64 {
+	var total int64
+	for _, segment := range s.Segments {
+		total += int64(len(segment.Data))
+	}
+	return total
+}
+
+// add returns a set that also holds data at start, coalescing segments that
+// touch or overlap it
+func (s *rangeSet) add(start int64, data []byte) *rangeSet {
+	segments := append(append([]rangeSegment(nil), s.Segments...), rangeSegment{Start: start, Data: data})
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+
+	merged := &rangeSet{Size: s.Size, Segments: segments[:1]}
+	for _, segment := range segments[1:] {
+		last := &merged.Segments[len(merged.Segments)-1]
+		if segment.Start > last.end() {
+			merged.Segments = append(merged.Segments, segment)
+			continue
+		}
+		if segment.end() > last.end() {
+			joined := make([]byte, 0, segment.end()-last.Start)
+			joined = append(joined, last.Data...)
+			joined = append(joined, segment.Data[last.end()-segment.Start:]...)
+			last.Data = joined
+		}
+	}
+	return merged
+}
+
+// read returns bytes first through last, if one segment holds them all
+func (s *rangeSet) read(first, last int64) ([]byte, bool) {
+	for _, segment := range s.Segments {
+		if segment.Start <= first && last < segment.end() {
+			return segment.Data[first-segment.Start : last-segment.Start+1], true
+		}
+	}
+	return nil, false
+}
+
+// complete reports whether the set holds the whole object
+func (s *rangeSet) complete() bool {
+	return len(s.Segments) == 1 && s.Segments[0].Start == 0 && int64(len(s.Segments[0].Data)) == s.Size
+}
+
+// MergeRange adds template's body, bytes first onward of an object of size
+// bytes, to the partial entry under key. Ranges of a different version of
+// the object, going by its validators, replace the cached ones. It reports
+// whether the object is now complete and cached as a whole
+func (c *Cache) MergeRange(key string, first, size int64, template *CacheEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ranges := &rangeSet{Size: size}
+	cachedAt := template.CachedAt
+	var usage *entryUsage
+	if existing, found := c.data[key]; found && time.Since(existing.CachedAt) <= c.ttlFor(existing) {
+		switch {
+		case existing.Ranges == nil && existing.StatusCode == http.StatusOK:
+			// Already cached whole
+			return false
+		case existing.Ranges != nil && existing.Ranges.Size == size &&
+			existing.ETag == template.ETag && existing.LastModified == template.LastModified:
+			// The object expires together, as of its first range
+			ranges = existing.Ranges
+			cachedAt = existing.CachedAt
+			usage = existing.usage
+		}
+	}
+	ranges = ranges.add(first, template.Body)
+
+	entry := *template
+	entry.CachedAt = cachedAt
+	entry.usage = usage
+	entry.Headers = maps.Clone(template.Headers)
+	for name := range entry.Headers {
+		if strings.EqualFold(name, "Content-Range") || strings.EqualFold(name, "Content-Length") {
+			delete(entry.Headers, name)
+		}
+	}
+
+	if !ranges.complete() {
+		entry.Body = nil
+		entry.Ranges = ranges
+		c.setLocked(key, &entry)
+		return false
+	}
+	entry.StatusCode = http.StatusOK
+	entry.Body = ranges.Segments[0].Data
+	entry.Headers["Content-Length"] = strconv.FormatInt(size, 10)
+	c.setLocked(key, &entry)
+	return true
+}
+
+// partialObjects counts the entries holding only some ranges, and their bytes
+func (c *Cache) partialObjects() (int, int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var objects int
+	var bytes int64
+	for _, entry := range c.data {
+		if entry.Ranges != nil {
+			objects++
+			bytes += entry.Ranges.bytes()
+		}
+	}
+	return objects, bytes
+}
@@ -0,0 +1,288 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   byteRange
+		ok     bool
+	}{
+		{"bytes=0-99", byteRange{first: 0, last: 99}, true},
+		{" bytes=100-100 ", byteRange{first: 100, last: 100}, true},
+		{"bytes=100-", byteRange{first: 100, last: -1}, true},
+		{"bytes=-500", byteRange{first: -1, last: -1, suffix: 500}, true},
+		{"bytes=-0", byteRange{}, false},
+		{"bytes=0-99,200-299", byteRange{}, false},
+		{"items=0-99", byteRange{}, false},
+		{"bytes=99-0", byteRange{}, false},
+		{"bytes=-", byteRange{}, false},
+		{"bytes=a-b", byteRange{}, false},
+		{"bytes=5", byteRange{}, false},
+		{"", byteRange{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseByteRange(tt.header)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseByteRange(%q): expected %+v %v, got %+v %v", tt.header, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestByteRangeResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		r           byteRange
+		size        int64
+		first, last int64
+		ok          bool
+	}{
+		{"closed", byteRange{first: 10, last: 19}, 100, 10, 19, true},
+		{"past end", byteRange{first: 90, last: 199}, 100, 90, 99, true},
+		{"open", byteRange{first: 40, last: -1}, 100, 40, 99, true},
+		{"suffix", byteRange{first: -1, last: -1, suffix: 10}, 100, 90, 99, true},
+		{"suffix longer than object", byteRange{first: -1, last: -1, suffix: 500}, 100, 0, 99, true},
+		{"suffix of empty object", byteRange{first: -1, last: -1, suffix: 10}, 0, 0, 0, false},
+		{"starts past end", byteRange{first: 100, last: -1}, 100, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		first, last, ok := tt.r.resolve(tt.size)
+		if first != tt.first || last != tt.last || ok != tt.ok {
+			t.Errorf("%s: expected %d-%d %v, got %d-%d %v", tt.name, tt.first, tt.last, tt.ok, first, last, ok)
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header            string
+		first, last, size int64
+		ok                bool
+	}{
+		{"bytes 0-99/1000", 0, 99, 1000, true},
+		{"bytes 999-999/1000", 999, 999, 1000, true},
+		{"bytes 0-99/*", 0, 0, 0, false},
+		{"bytes */1000", 0, 0, 0, false},
+		{"bytes 0-1000/1000", 0, 0, 0, false},
+		{"bytes 50-10/1000", 0, 0, 0, false},
+		{"items 0-99/1000", 0, 0, 0, false},
+		{"bytes 0-99", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		first, last, size, ok := parseContentRange(tt.header)
+		if first != tt.first || last != tt.last || size != tt.size || ok != tt.ok {
+			t.Errorf("parseContentRange(%q): expected %d-%d/%d %v, got %d-%d/%d %v",
+				tt.header, tt.first, tt.last, tt.size, tt.ok, first, last, size, ok)
+		}
+	}
+}
+
+// testObject is the object the range tests cache parts of
+var testObject = []byte("abcdefghijklmnopqrstuvwxyz")
+
+// part returns bytes first through last of testObject
+func part(first, last int64) []byte {
+	return testObject[first : last+1]
+}
+
+func TestRangeSetAdd(t *testing.T) {
+	type span struct{ first, last int64 }
+	tests := []struct {
+		name  string
+		adds  []span
+		want  []span
+		whole bool
+	}{
+		{"single", []span{{2, 5}}, []span{{2, 5}}, false},
+		{"disjoint", []span{{10, 12}, {0, 3}}, []span{{0, 3}, {10, 12}}, false},
+		{"adjacent", []span{{0, 3}, {4, 7}}, []span{{0, 7}}, false},
+		{"adjacent before", []span{{4, 7}, {0, 3}}, []span{{0, 7}}, false},
+		{"overlapping", []span{{0, 5}, {3, 9}}, []span{{0, 9}}, false},
+		{"contained", []span{{0, 9}, {2, 4}}, []span{{0, 9}}, false},
+		{"containing", []span{{2, 4}, {0, 9}}, []span{{0, 9}}, false},
+		{"bridging", []span{{0, 3}, {8, 10}, {4, 7}}, []span{{0, 10}}, false},
+		{"spanning several", []span{{0, 1}, {4, 5}, {8, 9}, {1, 8}}, []span{{0, 9}}, false},
+		{"complete", []span{{13, 25}, {0, 12}}, []span{{0, 25}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &rangeSet{Size: int64(len(testObject))}
+			for _, add := range tt.adds {
+				previous := set
+				before := fmt.Sprint(previous.Segments)
+				set = set.add(add.first, part(add.first, add.last))
+				if fmt.Sprint(previous.Segments) != before {
+					t.Errorf("Expected add to leave the previous set unchanged")
+				}
+			}
+
+			if len(set.Segments) != len(tt.want) {
+				t.Fatalf("Expected %d segments, got %d", len(tt.want), len(set.Segments))
+			}
+			for i, want := range tt.want {
+				segment := set.Segments[i]
+				if segment.Start != want.first || !bytes.Equal(segment.Data, part(want.first, want.last)) {
+					t.Errorf("Expected segment %d-%d %q, got %d %q", want.first, want.last, part(want.first, want.last), segment.Start, segment.Data)
+				}
+			}
+			if set.complete() != tt.whole {
+				t.Errorf("Expected complete=%v, got %v", tt.whole, set.complete())
+			}
+		})
+	}
+}
+
+func TestRangeSetRead(t *testing.T) {
+	set := (&rangeSet{Size: int64(len(testObject))}).add(0, part(0, 4)).add(10, part(10, 14))
+
+	tests := []struct {
+		first, last int64
+		ok          bool
+	}{
+		{0, 4, true},
+		{1, 3, true},
+		{12, 14, true},
+		{3, 5, false},
+		{4, 10, false},
+		{20, 25, false},
+	}
+	for _, tt := range tests {
+		got, ok := set.read(tt.first, tt.last)
+		if ok != tt.ok {
+			t.Errorf("read(%d, %d): expected ok=%v, got %v", tt.first, tt.last, tt.ok, ok)
+			continue
+		}
+		if ok && !bytes.Equal(got, part(tt.first, tt.last)) {
+			t.Errorf("read(%d, %d): expected %q, got %q", tt.first, tt.last, part(tt.first, tt.last), got)
+		}
+	}
+}
+
+// rangeEntry is a 206 response carrying bytes first through last of
+// testObject
+func rangeEntry(first, last int64, etag string) *CacheEntry {
+	return &CacheEntry{
+		StatusCode: http.StatusPartialContent,
+		Headers: map[string]string{
+			"Content-Type":   "text/plain",
+			"Content-Range":  fmt.Sprintf("bytes %d-%d/%d", first, last, len(testObject)),
+			"Content-Length": fmt.Sprint(last - first + 1),
+		},
+		Body:     part(first, last),
+		CachedAt: time.Now(),
+		ETag:     etag,
+	}
+}
+
+func TestCacheMergeRange(t *testing.T) {
+	cache := NewCache(10, time.Minute, newTestLogger(t))
+	size := int64(len(testObject))
+
+	if cache.MergeRange("object", 0, size, rangeEntry(0, 9, `"v1"`)) {
+		t.Fatal("Expected a partial object after the first range")
+	}
+	entry, found := cache.Get("object")
+	if !found || entry.Ranges == nil || entry.Body != nil {
+		t.Fatalf("Expected a partial entry, got %+v", entry)
+	}
+	if _, ok := entry.Headers["Content-Range"]; ok {
+		t.Error("Expected Content-Range dropped from the partial entry")
+	}
+	if objects, held := cache.partialObjects(); objects != 1 || held != 10 {
+		t.Errorf("Expected 1 partial object of 10 bytes, got %d of %d", objects, held)
+	}
+
+	// A new version replaces the ranges cached so far
+	cache.MergeRange("object", 20, size, rangeEntry(20, 25, `"v2"`))
+	entry, _ = cache.Get("object")
+	if entry.ETag != `"v2"` || len(entry.Ranges.Segments) != 1 || entry.Ranges.Segments[0].Start != 20 {
+		t.Fatalf("Expected only the v2 range, got %+v", entry.Ranges.Segments)
+	}
+
+	if cache.MergeRange("object", 5, size, rangeEntry(5, 19, `"v2"`)) {
+		t.Fatal("Expected a gap before byte 5 to keep the object partial")
+	}
+	if !cache.MergeRange("object", 0, size, rangeEntry(0, 5, `"v2"`)) {
+		t.Fatal("Expected the object complete once every byte is cached")
+	}
+
+	entry, _ = cache.Get("object")
+	if entry.StatusCode != http.StatusOK || entry.Ranges != nil {
+		t.Errorf("Expected a whole 200 entry, got status %d with ranges %v", entry.StatusCode, entry.Ranges)
+	}
+	if !bytes.Equal(entry.Body, testObject) {
+		t.Errorf("Expected body %q, got %q", testObject, entry.Body)
+	}
+	if length := entry.Headers["Content-Length"]; length != fmt.Sprint(size) {
+		t.Errorf("Expected Content-Length %d, got %s", size, length)
+	}
+	if objects, _ := cache.partialObjects(); objects != 0 {
+		t.Errorf("Expected no partial objects, got %d", objects)
+	}
+
+	// Ranges of an object already cached whole are not stored
+	if cache.MergeRange("object", 0, size, rangeEntry(0, 3, `"v3"`)) {
+		t.Error("Expected no merge into a whole entry")
+	}
+	if entry, _ = cache.Get("object"); entry.ETag != `"v2"` || entry.Ranges != nil {
+		t.Errorf("Expected the whole v2 entry kept, got %+v", entry)
+	}
+}
+
+func TestRangeCacheServe(t *testing.T) {
+	cache := NewCache(10, time.Minute, newTestLogger(t))
+	rc := newRangeCache(DefaultRangeCacheConfig())
+
+	rc.store(cache, "object", rangeEntry(0, 9, ""))
+
+	tests := []struct {
+		header  string
+		status  int
+		body    string
+		content string
+	}{
+		{"bytes=2-5", http.StatusPartialContent, "cdef", "bytes 2-5/26"},
+		{"bytes=5-", 0, "", ""},
+		{"bytes=-3", 0, "", ""},
+		{"bytes=30-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */26"},
+	}
+	for _, tt := range tests {
+		r, _ := parseByteRange(tt.header)
+		resp := rc.serve(cache, "object", r)
+		if tt.status == 0 {
+			if resp != nil {
+				t.Errorf("%s: expected a miss, got status %d", tt.header, resp.StatusCode)
+			}
+			continue
+		}
+		if resp == nil {
+			t.Errorf("%s: expected status %d, got a miss", tt.header, tt.status)
+			continue
+		}
+		if resp.StatusCode != tt.status || string(resp.Body) != tt.body || resp.Headers["Content-Range"] != tt.content {
+			t.Errorf("%s: expected %d %q %s, got %d %q %s", tt.header, tt.status, tt.body, tt.content,
+				resp.StatusCode, resp.Body, resp.Headers["Content-Range"])
+		}
+	}
+
+	rc.store(cache, "object", rangeEntry(10, 25, ""))
+	r, _ := parseByteRange("bytes=-3")
+	if resp := rc.serve(cache, "object", r); resp == nil || string(resp.Body) != "xyz" {
+		t.Errorf("Expected the suffix served from the completed object, got %+v", resp)
+	}
+
+	stats := rc.Stats(cache)
+	if stats.Stored != 2 || stats.Assembled != 1 || stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("Expected 2 stored, 1 assembled, 3 hits and 2 misses, got %+v", stats)
+	}
+}
@@ -0,0 +1,269 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when an upstream request exceeds its rate limit
+var ErrRateLimited = errors.New("outbound rate limit exceeded")
+
+// Modes for RateLimitConfig.Mode
+const (
+	RateLimitWait   = "wait"   // Block until tokens are available
+	RateLimitReject = "reject" // Fail immediately with ErrRateLimited
+)
+
+// TokenBucketConfig describes a single bucket; a zero Rate means unlimited
+type TokenBucketConfig struct {
+	Rate  float64 `yaml:"rate" json:"rate"`   // Tokens added per second
+	Burst int     `yaml:"burst" json:"burst"` // Bucket capacity
+}
+
+// RateLimitConfig configures the token buckets applied to upstream requests
+type RateLimitConfig struct {
+	Enabled       bool                         `yaml:"enabled" json:"enabled"`
+	Mode          string                       `yaml:"mode" json:"mode"`
+	MaxWait       time.Duration                `yaml:"max_wait" json:"max_wait"` // Longest a request may wait in wait mode
+	Global        TokenBucketConfig            `yaml:"global" json:"global"`
+	PerHost       TokenBucketConfig            `yaml:"per_host" json:"per_host"`
+	HostOverrides map[string]TokenBucketConfig `yaml:"host_overrides" json:"host_overrides,omitempty"`
+	PerAPIKey     TokenBucketConfig            `yaml:"per_api_key" json:"per_api_key"`
+	APIKeyHeaders []string                     `yaml:"api_key_headers" json:"api_key_headers"` // Headers identifying the caller's key
+}
+
+// DefaultRateLimitConfig returns a disabled limiter that waits when enabled
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Mode:          RateLimitWait,
+		MaxWait:       10 * time.Second,
+		APIKeyHeaders: []string{"x-api-key", "Authorization"},
+	}
+}
+
+// TokenBucket refills at a fixed rate up to its burst capacity
+type TokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewTokenBucket creates a full bucket
+func NewTokenBucket(config TokenBucketConfig) *TokenBucket {
+	capacity := float64(config.Burst)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TokenBucket{
+		rate:     config.Rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the last update
+func (tb *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(tb.last).Seconds()
+	if elapsed > 0 {
+		tb.tokens += elapsed * tb.rate
+		if tb.tokens > tb.capacity {
+			tb.tokens = tb.capacity
+		}
+		tb.last = now
+	}
+}
+
+// delay returns how long until one token is available
+func (tb *TokenBucket) delay(now time.Time) time.Duration {
+	tb.refill(now)
+	if tb.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+}
+
+// BucketLevel is a point-in-time view of one bucket
+type BucketLevel struct {
+	Scope    string  `json:"scope"` // "global", "host" or "api_key"
+	Key      string  `json:"key,omitempty"`
+	Tokens   float64 `json:"tokens"`
+	Capacity float64 `json:"capacity"`
+	Rate     float64 `json:"rate"`
+}
+
+// RateLimiter holds the global, per-host and per-API-key buckets; a request
+// proceeds only when every bucket it maps to has a token
+type RateLimiter struct {
+	config  RateLimitConfig
+	global  *TokenBucket
+	hosts   map[string]*TokenBucket
+	apiKeys map[string]*TokenBucket
+	mu      sync.Mutex
+}
+
+// NewRateLimiter creates a limiter from config
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Mode == "" {
+		config.Mode = RateLimitWait
+	}
+
+	rl := &RateLimiter{
+		config:  config,
+		hosts:   make(map[string]*TokenBucket),
+		apiKeys: make(map[string]*TokenBucket),
+	}
+	if config.Global.Rate > 0 {
+		rl.global = NewTokenBucket(config.Global)
+	}
+	return rl
+}
+
+// Wait blocks until req may be sent upstream, or returns ErrRateLimited in
+// reject mode or once MaxWait passes
+func (rl *RateLimiter) Wait(ctx context.Context, req *http.Request) error {
+	caller := ctx
+	if rl.config.MaxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rl.config.MaxWait)
+		defer cancel()
+	}
+
+	for {
+		delay := rl.tryAcquire(req)
+		if delay == 0 {
+			return nil
+		}
+		if rl.config.Mode == RateLimitReject {
+			return fmt.Errorf("%s: %w", req.URL.Host, ErrRateLimited)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			// Only MaxWait running out is a rate limit; the caller giving up is not
+			if err := caller.Err(); err != nil {
+				return fmt.Errorf("%s: %w", req.URL.Host, err)
+			}
+			return fmt.Errorf("%s: %w", req.URL.Host, ErrRateLimited)
+		}
+	}
+}
+
+// tryAcquire takes a token from every bucket if all have one, otherwise it
+// takes none and returns the longest wait
+func (rl *RateLimiter) tryAcquire(req *http.Request) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	buckets := rl.bucketsFor(req)
+	now := time.Now()
+
+	var longest time.Duration
+	for _, bucket := range buckets {
+		if delay := bucket.delay(now); delay > longest {
+			longest = delay
+		}
+	}
+	if longest > 0 {
+		return longest
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return 0
+}
+
+// bucketsFor returns the buckets req draws from; callers must hold rl.mu
+func (rl *RateLimiter) bucketsFor(req *http.Request) []*TokenBucket {
+	buckets := make([]*TokenBucket, 0, 3)
+	if rl.global != nil {
+		buckets = append(buckets, rl.global)
+	}
+
+	hostConfig := rl.config.PerHost
+	if override, ok := rl.config.HostOverrides[req.URL.Host]; ok {
+		hostConfig = override
+	}
+	if hostConfig.Rate > 0 {
+		bucket, exists := rl.hosts[req.URL.Host]
+		if !exists {
+			bucket = NewTokenBucket(hostConfig)
+			rl.hosts[req.URL.Host] = bucket
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if rl.config.PerAPIKey.Rate > 0 {
+		if key := rl.apiKeyFingerprint(req); key != "" {
+			bucket, exists := rl.apiKeys[key]
+			if !exists {
+				bucket = NewTokenBucket(rl.config.PerAPIKey)
+				rl.apiKeys[key] = bucket
+			}
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	return buckets
+}
+
+// apiKeyFingerprint hashes the caller's key so levels never expose it
+func (rl *RateLimiter) apiKeyFingerprint(req *http.Request) string {
+	for _, header := range rl.config.APIKeyHeaders {
+		if value := req.Header.Get(header); value != "" {
+			sum := sha256.Sum256([]byte(value))
+			return hex.EncodeToString(sum[:6])
+		}
+	}
+	return ""
+}
+
+// Levels returns the current fill of every bucket
+func (rl *RateLimiter) Levels() []BucketLevel {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	level := func(scope, key string, bucket *TokenBucket) BucketLevel {
+		bucket.refill(now)
+		return BucketLevel{
+			Scope:    scope,
+			Key:      key,
+			Tokens:   bucket.tokens,
+			Capacity: bucket.capacity,
+			Rate:     bucket.rate,
+		}
+	}
+
+	levels := make([]BucketLevel, 0, 1+len(rl.hosts)+len(rl.apiKeys))
+	if rl.global != nil {
+		levels = append(levels, level("global", "", rl.global))
+	}
+	for host, bucket := range rl.hosts {
+		levels = append(levels, level("host", host, bucket))
+	}
+	for key, bucket := range rl.apiKeys {
+		levels = append(levels, level("api_key", key, bucket))
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].Scope != levels[j].Scope {
+			return levels[i].Scope < levels[j].Scope
+		}
+		return levels[i].Key < levels[j].Key
+	})
+
+	return levels
+}
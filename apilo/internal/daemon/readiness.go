@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Readiness checks
+const (
+	ReadinessCheckConfig   = "config"   // Configuration loaded and state restored
+	ReadinessCheckWarmup   = "warmup"   // Warmup URLs fetched into the cache
+	ReadinessCheckUpstream = "upstream" // Upstreams answering the probe
+	ReadinessCheckShutdown = "shutdown" // Added, failing, once the daemon starts draining
+)
+
+// ReadinessConfig decides when /health/ready reports the daemon ready to take
+// traffic. Orchestrators such as Kubernetes route requests only to ready
+// instances, so a daemon with a cold cache or an unreachable upstream can be
+// held back while /health/live keeps it from being restarted
+type ReadinessConfig struct {
+	// URLs fetched into the cache at startup; with RequireWarmup the daemon
+	// is not ready until every one has been attempted
+	WarmupURLs    []string      `yaml:"warmup_urls" json:"warmup_urls,omitempty"`
+	RequireWarmup bool          `yaml:"require_warmup" json:"require_warmup"`
+	WarmupTimeout time.Duration `yaml:"warmup_timeout" json:"warmup_timeout"` // Per URL
+
+	// Upstream probed for reachability, the profiles' base URLs when empty.
+	// Any response counts as reachable
+	UpstreamURL   string        `yaml:"upstream_url" json:"upstream_url,omitempty"`
+	ProbeInterval time.Duration `yaml:"probe_interval" json:"probe_interval"`
+	ProbeTimeout  time.Duration `yaml:"probe_timeout" json:"probe_timeout"`
+}
+
+// DefaultReadinessConfig returns readiness without warmup, probing upstreams
+// every 10s
+func DefaultReadinessConfig() ReadinessConfig {
+	return ReadinessConfig{
+		WarmupTimeout: 30 * time.Second,
+		ProbeInterval: 10 * time.Second,
+		ProbeTimeout:  3 * time.Second,
+	}
+}
+
+// ReadinessCheck is the state of one readiness condition
+type ReadinessCheck struct {
+	Ready     bool      `json:"ready"`
+	Detail    string    `json:"detail,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ReadinessStatus is served on /health/ready
+type ReadinessStatus struct {
+	Ready  bool                      `json:"ready"`
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+// Readiness tracks the conditions the daemon must meet before it is ready
+type Readiness struct {
+	mu     sync.RWMutex
+	checks map[string]ReadinessCheck
+}
+
+// NewReadiness returns a tracker waiting on checks
+func NewReadiness(checks ...string) *Readiness {
+	r := &Readiness{checks: make(map[string]ReadinessCheck, len(checks))}
+	for _, name := range checks {
+		r.checks[name] = ReadinessCheck{Detail: "pending", CheckedAt: time.Now()}
+	}
+	return r
+}
+
+// Set records the state of a check
+func (r *Readiness) Set(name string, ready bool, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = ReadinessCheck{Ready: ready, Detail: detail, CheckedAt: time.Now()}
+}
+
+// Status reports every check; the daemon is ready once all of them are
+func (r *Readiness) Status() ReadinessStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := ReadinessStatus{Ready: true, Checks: make(map[string]ReadinessCheck, len(r.checks))}
+	for name, check := range r.checks {
+		status.Checks[name] = check
+		if !check.Ready {
+			status.Ready = false
+		}
+	}
+	return status
+}
+
+// readinessChecks returns the checks the daemon waits on under config
+func readinessChecks(config ReadinessConfig) ([]string, error) {
+	checks := []string{ReadinessCheckConfig, ReadinessCheckUpstream}
+	if config.RequireWarmup {
+		if len(config.WarmupURLs) == 0 {
+			return nil, fmt.Errorf("readiness requires warmup but no warmup URLs are configured")
+		}
+		checks = append(checks, ReadinessCheckWarmup)
+	}
+	return checks, nil
+}
+
+// warmup fetches the warmup URLs into the cache through the optimizer, so
+// they are not recorded as traffic, and marks the warmup check ready once
+// all have been attempted
+func (s *Service) warmup(ctx context.Context) {
+	urls := s.config.Readiness.WarmupURLs
+	warmed := 0
+	for _, url := range urls {
+		reqCtx, cancel := context.WithTimeout(ctx, s.config.Readiness.WarmupTimeout)
+		resp, err := s.optimizer.OptimizeContext(reqCtx, &OptimizationRequest{URL: url, Method: http.MethodGet})
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Warn("Failed to warm %s: %v", url, err)
+			continue
+		}
+		if resp.StatusCode >= 400 {
+			s.logger.Warn("Failed to warm %s: status %d", url, resp.StatusCode)
+			continue
+		}
+		warmed++
+	}
+
+	s.logger.Info("Warmed %d of %d URLs", warmed, len(urls))
+	s.readiness.Set(ReadinessCheckWarmup, true, fmt.Sprintf("warmed %d of %d URLs", warmed, len(urls)))
+}
+
+// probeTargets returns the upstream URLs probed for readiness
+func (s *Service) probeTargets() []string {
+	if s.config.Readiness.UpstreamURL != "" {
+		return []string{s.config.Readiness.UpstreamURL}
+	}
+	var targets []string
+	for _, profile := range s.profiles.List() {
+		targets = append(targets, profile.baseURL.String())
+	}
+	return targets
+}
+
+// probeUpstreams marks the upstream check ready while every target answers,
+// probing each ProbeInterval until ctx is done, or once when it is zero.
+// Without targets there is nothing to wait on
+func (s *Service) probeUpstreams(ctx context.Context) {
+	targets := s.probeTargets()
+	if len(targets) == 0 {
+		s.readiness.Set(ReadinessCheckUpstream, true, "no upstream to probe")
+		return
+	}
+
+	client := &http.Client{
+		Timeout: s.config.Readiness.ProbeTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var tick <-chan time.Time
+	if interval := s.config.Readiness.ProbeInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var unreachable []string
+		for _, target := range targets {
+			if err := probeUpstream(ctx, client, target); err != nil {
+				unreachable = append(unreachable, fmt.Sprintf("%s: %v", target, err))
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if len(unreachable) > 0 {
+			sort.Strings(unreachable)
+			s.readiness.Set(ReadinessCheckUpstream, false, "unreachable "+strings.Join(unreachable, "; "))
+		} else {
+			s.readiness.Set(ReadinessCheckUpstream, true, fmt.Sprintf("%d reachable", len(targets)))
+		}
+
+		if tick == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
+
+// probeUpstream sends a HEAD request to target; any response means it is
+// reachable
+func probeUpstream(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
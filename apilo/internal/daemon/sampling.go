@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingConfig controls which requests are recorded in analytics, the
+// journal, traces and per-request logs. The decision is made once when a
+// request arrives, from the first route whose prefix matches or else Rate.
+// Metrics counters and SLIs always see every request
+type SamplingConfig struct {
+	Rate   float64             `yaml:"rate" json:"rate"`
+	Routes []RouteSamplingRate `yaml:"routes" json:"routes,omitempty"`
+
+	// Failed requests are recorded even when not sampled
+	AlwaysSampleErrors bool `yaml:"always_sample_errors" json:"always_sample_errors"`
+
+	// Unsampled requests slower than OutlierLatency are kept in a reservoir of
+	// OutlierReservoir entries chosen uniformly among all such outliers
+	OutlierLatency   time.Duration `yaml:"outlier_latency" json:"outlier_latency"`
+	OutlierReservoir int           `yaml:"outlier_reservoir" json:"outlier_reservoir"`
+}
+
+// RouteSamplingRate sets the sample rate for requests whose host and path
+// start with Prefix, e.g. "api.anthropic.com/v1/messages"
+type RouteSamplingRate struct {
+	Prefix string  `yaml:"prefix" json:"prefix"`
+	Rate   float64 `yaml:"rate" json:"rate"`
+}
+
+// DefaultSamplingConfig records every request and keeps 100 outliers over 2s
+func DefaultSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Rate:               1,
+		AlwaysSampleErrors: true,
+		OutlierLatency:     2 * time.Second,
+		OutlierReservoir:   100,
+	}
+}
+
+// SamplingStats counts sampling decisions and holds the outlier reservoir
+type SamplingStats struct {
+	Rate             float64             `json:"rate"`
+	Routes           []RouteSamplingRate `json:"routes"`
+	Sampled          int64               `json:"sampled"`
+	Dropped          int64               `json:"dropped"`
+	ErrorsKept       int64               `json:"errors_kept"` // Unsampled failures recorded anyway
+	OutliersSeen     int64               `json:"outliers_seen"`
+	Outliers         []RequestRecord     `json:"outliers"` // Reservoir of unsampled outliers
+	OutlierLatencyMs float64             `json:"outlier_latency_ms"`
+}
+
+// Sampler makes head-based sampling decisions and collects latency outliers
+// among the requests it drops
+type Sampler struct {
+	config SamplingConfig
+	rate   atomic.Uint64 // float64 bits, adjustable through the admin API
+
+	sampled, dropped, errorsKept atomic.Int64
+
+	outliers     []RequestRecord
+	outliersSeen int64
+	mu           sync.Mutex
+}
+
+// NewSampler creates a sampler for config
+func NewSampler(config SamplingConfig) *Sampler {
+	s := &Sampler{config: config}
+	s.SetRate(config.Rate)
+	return s
+}
+
+// Rate returns the sample rate for routes without their own
+func (s *Sampler) Rate() float64 {
+	return math.Float64frombits(s.rate.Load())
+}
+
+// SetRate sets the sample rate for routes without their own
+func (s *Sampler) SetRate(rate float64) {
+	s.rate.Store(math.Float64bits(rate))
+}
+
+// Head decides whether a request to rawURL is sampled. Requests carrying a
+// W3C traceparent are decided from their trace ID, so every service sampling
+// at the same rate keeps the same traces
+func (s *Sampler) Head(rawURL string, headers map[string]string) bool {
+	rate := s.routeRate(rawURL)
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	if draw, ok := traceDraw(requestHeaderValue(headers, "traceparent")); ok {
+		return draw < rate
+	}
+	return rand.Float64() < rate
+}
+
+// Keep reports whether a request with the head decision sampled should be
+// recorded once its outcome is known, counting the decision. Dropped outliers
+// are offered to the reservoir
+func (s *Sampler) Keep(sampled bool, record RequestRecord) bool {
+	if sampled {
+		s.sampled.Add(1)
+		return true
+	}
+	if record.Error != "" && s.config.AlwaysSampleErrors {
+		s.errorsKept.Add(1)
+		return true
+	}
+
+	s.dropped.Add(1)
+	if s.config.OutlierReservoir > 0 && s.config.OutlierLatency > 0 && time.Duration(record.Latency) >= s.config.OutlierLatency {
+		s.offerOutlier(record)
+	}
+	return false
+}
+
+// offerOutlier adds record to the reservoir with reservoir sampling, so every
+// outlier seen so far is equally likely to be kept
+func (s *Sampler) offerOutlier(record RequestRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.outliersSeen++
+	if len(s.outliers) < s.config.OutlierReservoir {
+		s.outliers = append(s.outliers, record)
+		return
+	}
+	if i := rand.Int64N(s.outliersSeen); i < int64(len(s.outliers)) {
+		s.outliers[i] = record
+	}
+}
+
+// routeRate returns the rate of the first route matching rawURL
+func (s *Sampler) routeRate(rawURL string) float64 {
+	if len(s.config.Routes) > 0 {
+		if parsed, err := url.Parse(rawURL); err == nil {
+			route := parsed.Host + parsed.Path
+			for _, r := range s.config.Routes {
+				if strings.HasPrefix(route, r.Prefix) {
+					return r.Rate
+				}
+			}
+		}
+	}
+	return s.Rate()
+}
+
+// Stats returns the sampler's counters and outlier reservoir
+func (s *Sampler) Stats() SamplingStats {
+	s.mu.Lock()
+	outliers := append([]RequestRecord{}, s.outliers...)
+	seen := s.outliersSeen
+	s.mu.Unlock()
+
+	routes := s.config.Routes
+	if routes == nil {
+		routes = []RouteSamplingRate{}
+	}
+	return SamplingStats{
+		Rate:             s.Rate(),
+		Routes:           routes,
+		Sampled:          s.sampled.Load(),
+		Dropped:          s.dropped.Load(),
+		ErrorsKept:       s.errorsKept.Load(),
+		OutliersSeen:     seen,
+		Outliers:         outliers,
+		OutlierLatencyMs: float64(s.config.OutlierLatency.Microseconds()) / 1000.0,
+	}
+}
+
+// traceDraw maps the trace ID of a traceparent header
+// (version-traceid-parentid-flags) to [0, 1)
+func traceDraw(traceparent string) (float64, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return 0, false
+	}
+	id, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	// The low 8 bytes are random in both W3C and older trace ID formats
+	return float64(binary.BigEndian.Uint64(id[8:])>>11) / (1 << 53), true
+}
+
+// requestHeaderValue looks name up case-insensitively in headers
+func requestHeaderValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,352 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BenchmarkSchedule runs a benchmark suite whenever Cron fires
+type BenchmarkSchedule struct {
+	Name string `yaml:"name" json:"name"`
+	Cron string `yaml:"cron" json:"cron"` // e.g. "0 2 * * *" or "@daily"
+
+	// Suite configuration passed as -config, and any further benchmark flags
+	Config string   `yaml:"config" json:"config,omitempty"`
+	Args   []string `yaml:"args" json:"args,omitempty"`
+
+	// Each run starts after a random delay of up to Jitter, so schedules
+	// sharing a time do not hit the target at once. Keep it shorter than
+	// the interval between firings
+	Jitter time.Duration `yaml:"jitter" json:"jitter,omitempty"`
+
+	// Runs still going after Timeout are killed; zero means no limit
+	Timeout time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+
+	// A firing while the previous run is still going is skipped unless
+	// AllowOverlap is set
+	AllowOverlap bool `yaml:"allow_overlap" json:"allow_overlap,omitempty"`
+
+	// Results and logs go to OutputDir, ~/.apilo/schedules/<name> by
+	// default. After each run only the newest KeepRuns are kept, and none
+	// older than KeepFor; zero keeps all
+	OutputDir string        `yaml:"output_dir" json:"output_dir,omitempty"`
+	KeepRuns  int           `yaml:"keep_runs" json:"keep_runs,omitempty"`
+	KeepFor   time.Duration `yaml:"keep_for" json:"keep_for,omitempty"`
+}
+
+// DefaultScheduleCommand is the benchmark tool schedules run
+const DefaultScheduleCommand = "api-optimizer"
+
+// ScheduleRun is the outcome of one scheduled run
+type ScheduleRun struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	ExitCode  int           `json:"exit_code"`
+	Error     string        `json:"error,omitempty"`
+	LogFile   string        `json:"log_file"`
+}
+
+// ScheduleStatus describes a schedule on /schedules
+type ScheduleStatus struct {
+	Name      string       `json:"name"`
+	Cron      string       `json:"cron"`
+	Next      time.Time    `json:"next"` // Including jitter
+	Running   int          `json:"running"`
+	Runs      int          `json:"runs"`
+	Failures  int          `json:"failures"`
+	Skipped   int          `json:"skipped"` // Firings skipped to prevent overlap
+	Last      *ScheduleRun `json:"last,omitempty"`
+	OutputDir string       `json:"output_dir"`
+}
+
+// Scheduler runs benchmark suites on cron schedules by invoking the
+// benchmark tool
+type Scheduler struct {
+	command string
+	logger  *Logger
+	entries []*scheduleEntry
+	runs    sync.WaitGroup
+}
+
+// scheduleEntry is a schedule and its state
+type scheduleEntry struct {
+	config    BenchmarkSchedule
+	cron      *CronSchedule
+	outputDir string
+
+	mu       sync.Mutex
+	next     time.Time
+	running  int
+	runs     int
+	failures int
+	skipped  int
+	last     *ScheduleRun
+}
+
+// NewScheduler validates schedules, or returns nil if there are none
+func NewScheduler(schedules []BenchmarkSchedule, command string, logger *Logger) (*Scheduler, error) {
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+	if command == "" {
+		command = DefaultScheduleCommand
+	}
+
+	s := &Scheduler{command: command, logger: logger}
+	names := make(map[string]bool, len(schedules))
+	for _, schedule := range schedules {
+		if schedule.Name == "" || strings.ContainsAny(schedule.Name, `/\`) {
+			return nil, fmt.Errorf("schedule name %q must be non-empty and contain no slashes", schedule.Name)
+		}
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("duplicate schedule %q", schedule.Name)
+		}
+		names[schedule.Name] = true
+
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", schedule.Name, err)
+		}
+		if schedule.Config == "" && len(schedule.Args) == 0 {
+			return nil, fmt.Errorf("schedule %q needs a config or args", schedule.Name)
+		}
+
+		outputDir := schedule.OutputDir
+		if outputDir == "" {
+			outputDir = filepath.Join("~/.apilo/schedules", schedule.Name)
+		}
+		if strings.HasPrefix(outputDir, "~/") {
+			home, _ := os.UserHomeDir()
+			outputDir = filepath.Join(home, outputDir[2:])
+		}
+		s.entries = append(s.entries, &scheduleEntry{
+			config:    schedule,
+			cron:      cron,
+			outputDir: outputDir,
+		})
+	}
+	return s, nil
+}
+
+// Run fires every schedule until ctx is cancelled, which also kills the
+// runs in progress
+func (s *Scheduler) Run(ctx context.Context) {
+	var loops sync.WaitGroup
+	for _, entry := range s.entries {
+		s.logger.Info("Schedule %s: %s, next run %s", entry.config.Name, entry.config.Cron,
+			entry.cron.Next(time.Now()).Format(time.RFC3339))
+		loops.Add(1)
+		go func(entry *scheduleEntry) {
+			defer loops.Done()
+			s.loop(ctx, entry)
+		}(entry)
+	}
+	loops.Wait()
+	s.runs.Wait()
+}
+
+// loop waits for each firing of entry's schedule, plus jitter, and fires it
+func (s *Scheduler) loop(ctx context.Context, entry *scheduleEntry) {
+	for {
+		next := entry.cron.Next(time.Now())
+		if jitter := entry.config.Jitter; jitter > 0 {
+			next = next.Add(rand.N(jitter))
+		}
+		entry.mu.Lock()
+		entry.next = next
+		entry.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.fire(ctx, entry)
+	}
+}
+
+// fire starts a run of entry unless one is in progress and overlap is not allowed
+func (s *Scheduler) fire(ctx context.Context, entry *scheduleEntry) {
+	entry.mu.Lock()
+	if entry.running > 0 && !entry.config.AllowOverlap {
+		entry.skipped++
+		entry.mu.Unlock()
+		s.logger.Warn("Schedule %s: skipping run, the previous one is still in progress", entry.config.Name)
+		return
+	}
+	entry.running++
+	entry.mu.Unlock()
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		run := s.execute(ctx, entry)
+
+		entry.mu.Lock()
+		entry.running--
+		entry.runs++
+		if run.Error != "" {
+			entry.failures++
+		}
+		entry.last = run
+		entry.mu.Unlock()
+
+		if err := entry.prune(time.Now()); err != nil {
+			s.logger.Warn("Schedule %s: failed to prune results: %v", entry.config.Name, err)
+		}
+	}()
+}
+
+// execute runs the benchmark tool for entry, logging its output next to its results
+func (s *Scheduler) execute(ctx context.Context, entry *scheduleEntry) *ScheduleRun {
+	run := &ScheduleRun{StartedAt: time.Now(), ExitCode: -1}
+	name := entry.config.Name
+	run.LogFile = filepath.Join(entry.outputDir, fmt.Sprintf("run_%s.log", run.StartedAt.Format("20060102_150405")))
+	defer func() {
+		run.Duration = time.Since(run.StartedAt)
+	}()
+
+	if err := os.MkdirAll(entry.outputDir, 0755); err != nil {
+		run.Error = fmt.Sprintf("failed to create output directory: %v", err)
+		s.logger.Error("Schedule %s: %s", name, run.Error)
+		return run
+	}
+	logFile, err := os.Create(run.LogFile)
+	if err != nil {
+		run.Error = fmt.Sprintf("failed to create log file: %v", err)
+		s.logger.Error("Schedule %s: %s", name, run.Error)
+		return run
+	}
+	defer logFile.Close()
+
+	runCtx := ctx
+	if entry.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, entry.config.Timeout)
+		defer cancel()
+	}
+
+	// -output comes last so the schedule's own directory wins
+	var args []string
+	if entry.config.Config != "" {
+		args = append(args, "-config", entry.config.Config)
+	}
+	args = append(args, entry.config.Args...)
+	args = append(args, "-output", entry.outputDir)
+
+	s.logger.Info("Schedule %s: starting %s %s", name, s.command, strings.Join(args, " "))
+	cmd := exec.CommandContext(runCtx, s.command, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	err = cmd.Run()
+	if cmd.ProcessState != nil {
+		run.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	switch {
+	case err == nil:
+		s.logger.Info("Schedule %s: finished in %v", name, time.Since(run.StartedAt).Round(time.Second))
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		run.Error = fmt.Sprintf("killed after the %v timeout", entry.config.Timeout)
+	default:
+		run.Error = err.Error()
+	}
+	if run.Error != "" {
+		s.logger.Warn("Schedule %s: run failed: %s (see %s)", name, run.Error, run.LogFile)
+	}
+	return run
+}
+
+// prune applies entry's retention to its result directories and run logs
+// separately, so each keeps KeepRuns of the newest
+func (e *scheduleEntry) prune(now time.Time) error {
+	if e.config.KeepRuns <= 0 && e.config.KeepFor <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(e.outputDir)
+	if err != nil {
+		return err
+	}
+
+	var results, logs []os.FileInfo
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		switch {
+		case entry.IsDir():
+			results = append(results, info)
+		case strings.HasPrefix(entry.Name(), "run_") && strings.HasSuffix(entry.Name(), ".log"):
+			logs = append(logs, info)
+		}
+	}
+
+	var errs []error
+	for _, group := range [][]os.FileInfo{results, logs} {
+		sort.Slice(group, func(i, j int) bool { return group[i].ModTime().After(group[j].ModTime()) })
+		for i, info := range group {
+			expired := e.config.KeepFor > 0 && now.Sub(info.ModTime()) > e.config.KeepFor
+			if (e.config.KeepRuns > 0 && i >= e.config.KeepRuns) || expired {
+				if err := os.RemoveAll(filepath.Join(e.outputDir, info.Name())); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status returns every schedule's state
+func (s *Scheduler) Status() []ScheduleStatus {
+	statuses := make([]ScheduleStatus, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entry.mu.Lock()
+		status := ScheduleStatus{
+			Name:      entry.config.Name,
+			Cron:      entry.config.Cron,
+			Next:      entry.next,
+			Running:   entry.running,
+			Runs:      entry.runs,
+			Failures:  entry.failures,
+			Skipped:   entry.skipped,
+			OutputDir: entry.outputDir,
+		}
+		if entry.last != nil {
+			last := *entry.last
+			status.Last = &last
+		}
+		entry.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// handleSchedules returns the state of every benchmark schedule
+func (ipc *IPCServer) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := []ScheduleStatus{}
+	if scheduler := ipc.service.scheduler; scheduler != nil {
+		statuses = scheduler.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
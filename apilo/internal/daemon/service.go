@@ -2,11 +2,10 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -19,13 +18,34 @@ type Service struct {
 	claudeClient *ClaudeClient
 	metrics      *Metrics
 	analytics    *Analytics
-	logger       *Logger
-	proxy        *ProxyManager
-	ctx          context.Context
-	cancel       context.CancelFunc
-	wg           sync.WaitGroup
-	startTime    time.Time
-	mu           sync.RWMutex
+	sli          *SLITracker
+	overhead     *OverheadTracker
+	journal      *Journal
+	accessLog    *AccessLog
+	readiness    *Readiness
+	peers        *Peers
+	tracer       *Tracer
+	leaks        *LeakDetector
+	scheduler    *Scheduler
+	profiles     *ProfileRegistry
+	admission    *AdmissionController
+	mirror       *Mirror
+	audit        *AuditLog
+	adminToken   string
+
+	// Decides which requests reach analytics, the journal and request logs
+	sampler   *Sampler
+	logger    *Logger
+	proxy     *ProxyManager
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	startTime time.Time
+	mu        sync.RWMutex
+
+	// Closed once Stop has drained requests and flushed state
+	stopOnce sync.Once
+	stopped  chan struct{}
 }
 
 // NewService creates a new daemon service
@@ -47,10 +67,19 @@ func NewService(config *DaemonConfig) (*Service, error) {
 		pidManager: NewPIDManager(config.PIDFile),
 		metrics:    NewMetrics(),
 		analytics:  NewAnalytics(1000), // Track last 1000 requests
+		sli:        NewSLITracker(config.SLI),
+		overhead:   NewOverheadTracker(),
+		sampler:    NewSampler(config.Sampling),
 		logger:     logger,
 		ctx:        ctx,
 		cancel:     cancel,
 		startTime:  time.Now(),
+		stopped:    make(chan struct{}),
+		adminToken: config.AdminToken,
+	}
+	service.audit = NewAuditLog(logger)
+	if service.adminToken == "" {
+		service.adminToken = os.Getenv("APILO_ADMIN_TOKEN")
 	}
 
 	// Initialize optimizer
@@ -60,6 +89,80 @@ func NewService(config *DaemonConfig) (*Service, error) {
 	}
 	service.optimizer = optimizer
 
+	// Initialize upstream profiles
+	if config.ProfilesFile != "" {
+		loaded, err := LoadProfiles(config.ProfilesFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Profiles = append(config.Profiles, loaded...)
+	}
+	profiles, err := NewProfileRegistry(config, service.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profiles: %w", err)
+	}
+	service.profiles = profiles
+
+	// Initialize traffic mirroring
+	mirror, err := NewMirror(config.Mirror, service.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror: %w", err)
+	}
+	service.mirror = mirror
+
+	if config.Tracing.Enabled {
+		service.tracer = NewTracer(config.Tracing)
+	}
+	if config.LeakDetection.Enabled {
+		service.leaks = NewLeakDetector(config.LeakDetection, service.logger)
+	}
+
+	// Initialize scheduled benchmarks
+	scheduler, err := NewScheduler(config.Schedules, config.ScheduleCommand, service.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	service.scheduler = scheduler
+
+	// Initialize the request journal
+	if config.Journal.Enabled {
+		journal, err := OpenJournal(config.Journal)
+		if err != nil {
+			return nil, err
+		}
+		service.journal = journal
+	}
+
+	// Initialize readiness, pending until Start has restored state
+	checks, err := readinessChecks(config.Readiness)
+	if err != nil {
+		return nil, err
+	}
+	service.readiness = NewReadiness(checks...)
+
+	// Initialize gossip with the other replicas
+	if config.Peers.Enabled {
+		peers, err := NewPeers(config.Peers, service.logger, service.applyPeerEvent, service.warmFromPeer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create peers: %w", err)
+		}
+		service.peers = peers
+	}
+
+	// Initialize the access log
+	if config.AccessLog.Enabled {
+		accessLog, err := OpenAccessLog(config.AccessLog)
+		if err != nil {
+			return nil, err
+		}
+		service.accessLog = accessLog
+	}
+
+	// Initialize admission control
+	if config.LoadShedding.Enabled {
+		service.admission = NewAdmissionController(config.LoadShedding)
+	}
+
 	// Initialize Claude client (optional - only if API key is set)
 	claudeClient, err := NewClaudeClient()
 	if err != nil {
@@ -100,6 +203,9 @@ func (s *Service) Start() error {
 		s.logger.Warn("Proxy failed to start: %v", err)
 	}
 
+	// Rebuild analytics lost with the previous process before serving
+	s.replayJournal()
+
 	// Start IPC server
 	s.wg.Add(1)
 	go func() {
@@ -111,6 +217,49 @@ func (s *Service) Start() error {
 
 	s.logger.Info("Daemon started on port %d", s.config.Port)
 
+	// Restore circuit breaker state and persist it periodically
+	if circuits := s.optimizer.Circuits(); circuits != nil && s.config.CircuitStateFile != "" {
+		restored, err := circuits.Restore(s.config.CircuitStateFile, s.config.CircuitStateMaxAge)
+		if err != nil {
+			s.logger.Warn("Failed to restore circuit breaker state: %v", err)
+		} else if restored > 0 {
+			s.logger.Info("Restored %d circuit breakers from %s", restored, s.config.CircuitStateFile)
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.persistCircuits(s.ctx, circuits)
+		}()
+	}
+
+	// Warm the cache and probe upstreams before reporting ready
+	if len(s.config.Readiness.WarmupURLs) > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.warmup(s.ctx)
+		}()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.probeUpstreams(s.ctx)
+	}()
+	s.readiness.Set(ReadinessCheckConfig, true, "loaded")
+
+	// Gossip cache events with the other replicas
+	if s.peers != nil {
+		if err := s.peers.Start(); err != nil {
+			return fmt.Errorf("failed to start peer gossip: %w", err)
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.peers.Run(s.ctx)
+		}()
+	}
+
 	// Start metrics collection if enabled
 	if s.config.MetricsEnabled {
 		s.wg.Add(1)
@@ -120,21 +269,50 @@ func (s *Service) Start() error {
 		}()
 	}
 
+	// Watch the daemon itself for goroutine and descriptor leaks
+	if s.leaks != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.leaks.Run(s.ctx)
+		}()
+	}
+
+	// Run benchmark suites on their schedules
+	if s.scheduler != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.scheduler.Run(s.ctx)
+		}()
+	}
+
 	// Setup signal handling
 	s.setupSignalHandling()
 
-	// Wait for shutdown
+	// Wait for shutdown, including Stop's flushing of state
 	s.wg.Wait()
-
-	s.logger.Info("Daemon stopped")
-	s.logger.Close()
+	<-s.stopped
 	return nil
 }
 
-// Stop stops the daemon service
+// Stop drains in-flight requests, stops the background work and flushes the
+// journal, access log and breaker state. Calls after the first wait for it
 func (s *Service) Stop() error {
+	s.stopOnce.Do(s.stop)
+	<-s.stopped
+	return nil
+}
+
+// stop runs the shutdown once for Stop
+func (s *Service) stop() {
+	defer close(s.stopped)
 	s.logger.Info("Stopping daemon...")
 
+	// Stop taking traffic and let requests already in flight finish
+	s.drain()
+
+	// Background loops exit; persistCircuits saves breaker state on the way out
 	s.cancel()
 	s.wg.Wait()
 
@@ -143,12 +321,33 @@ func (s *Service) Stop() error {
 		s.proxy.Stop()
 	}
 
+	// Abandon in-flight shadow requests
+	if s.mirror != nil {
+		s.mirror.Close()
+	}
+	for _, profile := range s.profiles.List() {
+		if profile.mirror != nil {
+			profile.mirror.Close()
+		}
+	}
+
+	if s.journal != nil {
+		if err := s.journal.Close(); err != nil {
+			s.logger.Warn("Failed to close request journal: %v", err)
+		}
+	}
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			s.logger.Warn("Failed to close access log: %v", err)
+		}
+	}
+
 	if err := s.pidManager.Remove(); err != nil {
 		s.logger.Warn("Failed to remove PID file: %v", err)
 	}
 
+	s.logger.Info("Daemon stopped")
 	s.logger.Close()
-	return nil
 }
 
 // GetStatus returns the current daemon status
@@ -174,34 +373,135 @@ func (s *Service) GetStatus() *DaemonStatus {
 	}
 }
 
+// GetProfileStatus returns the daemon status scoped to profile's traffic
+func (s *Service) GetProfileStatus(profile *Profile) *DaemonStatus {
+	status := s.GetStatus()
+	stats := profile.metrics.GetStats()
+
+	status.Profile = profile.Name()
+	if profile.config.Port != 0 {
+		status.Port = profile.config.Port
+	}
+	status.TotalRequests = stats.TotalRequests
+	status.CacheHitRatio = stats.CacheHitRatio
+	status.AvgLatency = stats.AvgLatency
+	status.ClaudeMetrics = nil
+	return status
+}
+
+// Profiles returns the daemon's upstream profiles
+func (s *Service) Profiles() *ProfileRegistry {
+	return s.profiles
+}
+
+// Mirror returns the daemon's traffic mirror, or profile's when it is
+// non-nil; the result is nil when mirroring is disabled
+func (s *Service) Mirror(profile *Profile) *Mirror {
+	if profile != nil {
+		return profile.mirror
+	}
+	return s.mirror
+}
+
+// MirrorStats compares primary and shadow traffic for the daemon, or for
+// profile when it is non-nil
+func (s *Service) MirrorStats(profile *Profile) MirrorStats {
+	mirror := s.Mirror(profile)
+	if mirror == nil {
+		return MirrorStats{}
+	}
+	return mirror.Stats()
+}
+
 // Optimize processes an optimization request
 func (s *Service) Optimize(req *OptimizationRequest) (*OptimizationResponse, error) {
-	s.metrics.IncrementRequests()
+	return s.OptimizeContext(context.Background(), req)
+}
+
+// OptimizeContext handles an optimization request bounded by ctx's deadline
+func (s *Service) OptimizeContext(ctx context.Context, req *OptimizationRequest) (*OptimizationResponse, error) {
+	return s.optimize(ctx, nil, req)
+}
+
+// OptimizeProfile handles an optimization request through profile's cache,
+// breakers and limits. The request counts towards both the profile's stats and
+// the daemon-wide totals
+func (s *Service) OptimizeProfile(ctx context.Context, profile *Profile, req *OptimizationRequest) (*OptimizationResponse, error) {
+	if err := profile.prepare(req); err != nil {
+		return nil, err
+	}
+	return s.optimize(ctx, profile, req)
+}
+
+// optimize runs req through the profile's optimizer, or the daemon's own when
+// profile is nil, and records the outcome
+func (s *Service) optimize(ctx context.Context, profile *Profile, req *OptimizationRequest) (*OptimizationResponse, error) {
+	optimizer, mirror := s.optimizer, s.mirror
+	if profile != nil {
+		optimizer, mirror = profile.optimizer, profile.mirror
+	}
+
+	// Sampling is decided up front so the whole request is recorded or not
+	sampled := s.sampler.Head(req.URL, req.Headers)
+
+	if s.peers != nil {
+		name := ""
+		if profile != nil {
+			name = profile.Name()
+		}
+		s.peers.Observe(name, req)
+	}
+
+	var span *activeSpan
+	if s.tracer != nil {
+		ctx, span = startSpan(ctx, "optimize", requestHeader(req, "traceparent"))
+		annotate(ctx, "method", req.Method)
+		if profile != nil {
+			annotate(ctx, "profile", profile.Name())
+		}
+	}
 
+	// Latency is service time: queueing in admission control and the rate
+	// limiter is reported separately
+	ctx, queue := withQueueClock(ctx)
+	queuedBefore := queue.Queued()
 	start := time.Now()
-	resp, err := s.optimizer.Optimize(req)
-	latency := time.Since(start)
+	resp, err := optimizer.OptimizeContext(ctx, req)
+	queued := queue.Queued()
+	latency := time.Since(start) - (queued - queuedBefore)
+	recording := time.Now()
+	defer func() { recordOverheadPhase(ctx, OverheadRecording, recording) }()
 
 	// Record analytics
 	record := RequestRecord{
-		Timestamp:  start,
-		URL:        req.URL,
-		Method:     req.Method,
-		StatusCode: 0,
-		Latency:    int64(latency),
-		CacheHit:   false,
+		Timestamp:    start,
+		URL:          req.URL,
+		Method:       req.Method,
+		StatusCode:   0,
+		Latency:      int64(latency),
+		QueueLatency: int64(queued),
+		CacheHit:     false,
+		CacheKey:     optimizer.generateCacheKey(req)[:16],
 	}
 
 	if err != nil {
-		s.metrics.IncrementErrors()
 		s.logger.Error("Optimization failed for %s: %v", req.URL, err)
 		record.Error = err.Error()
-		s.analytics.RecordRequest(record)
+		var timeoutErr *PhaseTimeoutError
+		if errors.As(err, &timeoutErr) {
+			annotate(ctx, "timeout.phase", string(timeoutErr.Phase))
+		}
+		kept := s.recordOutcome(profile, record, resp, err, sampled)
+		if profile != nil {
+			profile.recordShard(req, latency, false, err)
+		}
+		s.finishSpan(span, err, kept)
 		return nil, err
 	}
 
 	record.StatusCode = resp.StatusCode
 	record.CacheHit = resp.CacheHit
+	record.ResponseBytes = int64(len(resp.Body))
 
 	// Extract token usage from response metadata
 	if resp.Metadata.TokenUsage != nil {
@@ -211,20 +511,125 @@ func (s *Service) Optimize(req *OptimizationRequest) (*OptimizationResponse, err
 		record.IsEstimated = resp.Metadata.TokenUsage.IsEstimated
 	}
 
-	s.metrics.RecordLatency(latency)
-	if resp.CacheHit {
-		s.metrics.IncrementCacheHits()
-	} else {
-		s.metrics.IncrementCacheMisses()
+	kept := s.recordOutcome(profile, record, resp, err, sampled)
+	if profile != nil {
+		profile.recordShard(req, latency, resp.CacheHit, nil)
+	}
+	if kept {
+		s.logger.LogOptimization(req.URL, resp.CacheHit, latency)
+	}
+	if span != nil {
+		resp.TraceID = s.finishSpan(span, nil, kept).TraceID
 	}
 
-	s.logger.LogOptimization(req.URL, resp.CacheHit, latency)
-	s.analytics.RecordRequest(record)
+	if mirror != nil {
+		mirror.Observe(req, resp, latency)
+	}
 
 	resp.Latency = latency
+	resp.QueueLatency = queued
 	return resp, nil
 }
 
+// recordOutcome updates the daemon's metrics and analytics, and the profile's
+// when there is one, with the result of a request. Analytics and the journal
+// only see requests the sampler keeps; it reports whether this one was kept
+func (s *Service) recordOutcome(profile *Profile, record RequestRecord, resp *OptimizationResponse, err error, sampled bool) bool {
+	kept := s.sampler.Keep(sampled, record)
+
+	name := ""
+	if profile != nil {
+		name = profile.Name()
+	}
+	recordMetrics(s.metrics, record, resp, err)
+	s.sli.Record(name, record)
+	if kept {
+		s.analytics.RecordRequest(record)
+		if s.journal != nil {
+			s.journal.Append(name, record)
+		}
+	}
+
+	if profile != nil {
+		recordMetrics(profile.metrics, record, resp, err)
+		if kept {
+			profile.analytics.RecordRequest(record)
+		}
+	}
+	return kept
+}
+
+// finishSpan ends span, keeping it when the request was kept by sampling
+func (s *Service) finishSpan(span *activeSpan, err error, kept bool) Span {
+	if span == nil {
+		return Span{}
+	}
+	finished := span.finish(err)
+	if kept {
+		s.tracer.Record(finished)
+	}
+	return finished
+}
+
+// replayJournal feeds journaled requests younger than ReplayMaxAge into the
+// daemon's and their profile's analytics
+func (s *Service) replayJournal() {
+	if s.journal == nil || s.config.Journal.ReplayMaxAge <= 0 {
+		return
+	}
+
+	since := time.Now().Add(-s.config.Journal.ReplayMaxAge)
+	replayed, err := s.journal.Replay(since, func(entry JournalEntry) {
+		s.analytics.RecordRequest(entry.RequestRecord)
+		if entry.Profile == "" {
+			return
+		}
+		if profile, ok := s.profiles.Get(entry.Profile); ok {
+			profile.analytics.RecordRequest(entry.RequestRecord)
+		}
+	})
+	if err != nil {
+		s.logger.Warn("Failed to replay request journal: %v", err)
+	}
+	if replayed > 0 {
+		s.logger.Info("Replayed %d requests from the journal", replayed)
+	}
+}
+
+// recordMetrics counts a request's outcome in metrics
+func recordMetrics(metrics *Metrics, record RequestRecord, resp *OptimizationResponse, err error) {
+	metrics.IncrementRequests()
+
+	if err != nil {
+		metrics.IncrementErrors()
+		var timeoutErr *PhaseTimeoutError
+		if errors.As(err, &timeoutErr) && !timeoutErr.Canceled {
+			metrics.RecordTimeout(timeoutErr.Phase)
+		}
+		return
+	}
+
+	metrics.RecordLatency(time.Duration(record.Latency))
+	metrics.RecordQueueTime(time.Duration(record.QueueLatency))
+	if resp.Deduplicated {
+		metrics.IncrementDeduplicated()
+		return
+	}
+	if resp.CacheHit {
+		metrics.IncrementCacheHits()
+	} else {
+		metrics.IncrementCacheMisses()
+	}
+
+	// "stale" means a conditional GET was attempted but the upstream sent a new body
+	switch resp.Metadata.CacheStatus {
+	case "revalidated":
+		metrics.RecordRevalidation(true)
+	case "stale":
+		metrics.RecordRevalidation(false)
+	}
+}
+
 // OptimizeWithClaude processes an optimization request with Claude API analysis
 func (s *Service) OptimizeWithClaude(req *OptimizationRequest, prompt string, maxTokens int) (*OptimizationResponse, error) {
 	if s.claudeClient == nil {
@@ -273,30 +678,6 @@ func (s *Service) OptimizeWithClaude(req *OptimizationRequest, prompt string, ma
 	}, nil
 }
 
-// setupSignalHandling configures signal handlers for graceful shutdown
-func (s *Service) setupSignalHandling() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-
-		sig := <-sigChan
-		s.logger.Info("Received signal: %v", sig)
-
-		switch sig {
-		case syscall.SIGHUP:
-			// Reload configuration
-			s.logger.Info("Reloading configuration...")
-			// TODO: Implement config reload
-		case syscall.SIGINT, syscall.SIGTERM:
-			// Graceful shutdown
-			s.Stop()
-		}
-	}()
-}
-
 // collectMetrics periodically collects system metrics
 func (s *Service) collectMetrics(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
@@ -312,6 +693,31 @@ func (s *Service) collectMetrics(ctx context.Context) {
 	}
 }
 
+// persistCircuits saves breaker state on an interval and once more on shutdown
+func (s *Service) persistCircuits(ctx context.Context, circuits *CircuitRegistry) {
+	interval := s.config.CircuitStateInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := circuits.Save(s.config.CircuitStateFile); err != nil {
+				s.logger.Warn("Failed to save circuit breaker state: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := circuits.Save(s.config.CircuitStateFile); err != nil {
+				s.logger.Warn("Failed to save circuit breaker state: %v", err)
+			}
+		}
+	}
+}
+
 // GetConfig returns the daemon configuration
 func (s *Service) GetConfig() *DaemonConfig {
 	s.mu.RLock()
@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultShardVirtualNodes spreads each shard over the ring so keys divide
+// evenly between shards
+const defaultShardVirtualNodes = 128
+
+// ShardingConfig routes a profile's requests across upstream shards, such as
+// regional gateways, by consistent hashing of a request attribute. A given
+// key always reaches the same shard, from every replica configured with the
+// same shards, so both the upstream's and apilo's caches stay warm for it,
+// and adding or removing a shard only moves the keys it owns
+type ShardingConfig struct {
+	Shards []string `yaml:"shards" json:"shards"` // Base URLs, standing in for the profile's base_url
+
+	// Attribute hashed to pick the shard: "header:<Name>" or "query:<name>".
+	// Requests without it are routed by URL path
+	Key string `yaml:"key" json:"key"`
+
+	VirtualNodes int `yaml:"virtual_nodes" json:"virtual_nodes,omitempty"` // Ring points per shard (default: 128)
+}
+
+// ShardStats describes one shard's traffic on /shards
+type ShardStats struct {
+	URL           string        `json:"url"`
+	KeyShare      float64       `json:"key_share"` // Fraction of the hash space it owns
+	Requests      int64         `json:"requests"`
+	Errors        int64         `json:"errors"`
+	CacheHits     int64         `json:"cache_hits"`
+	CacheHitRatio float64       `json:"cache_hit_ratio"`
+	AvgLatency    time.Duration `json:"avg_latency"`
+	Failovers     int64         `json:"failovers"` // Requests it took for a shard with an open breaker
+	CircuitState  CircuitState  `json:"circuit_state,omitempty"`
+}
+
+// shard is one upstream shard and its counters
+type shard struct {
+	url     *url.URL
+	share   float64
+	counter struct {
+		requests, errors, hits, failovers int64
+		latency                           time.Duration
+	}
+}
+
+// ShardRing is a consistent-hash ring of upstream shards
+type ShardRing struct {
+	keyKind string // "header", "query" or "" for the URL path
+	keyName string
+	shards  []*shard
+	byHost  map[string]*shard
+	points  []uint64
+	owners  []int // Index into shards of each point
+	mu      sync.Mutex
+}
+
+// NewShardRing validates config and builds its ring
+func NewShardRing(config ShardingConfig) (*ShardRing, error) {
+	if len(config.Shards) == 0 {
+		return nil, fmt.Errorf("sharding requires at least one shard")
+	}
+
+	ring := &ShardRing{byHost: make(map[string]*shard)}
+	if config.Key != "" {
+		kind, name, ok := strings.Cut(config.Key, ":")
+		if !ok || name == "" || (kind != "header" && kind != "query") {
+			return nil, fmt.Errorf("invalid shard key %q: use header:<Name> or query:<name>", config.Key)
+		}
+		ring.keyKind, ring.keyName = kind, name
+	}
+
+	for _, raw := range config.Shards {
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid shard %q", raw)
+		}
+		if _, duplicate := ring.byHost[parsed.Host]; duplicate {
+			return nil, fmt.Errorf("duplicate shard host %s", parsed.Host)
+		}
+		s := &shard{url: parsed}
+		ring.shards = append(ring.shards, s)
+		ring.byHost[parsed.Host] = s
+	}
+
+	nodes := config.VirtualNodes
+	if nodes <= 0 {
+		nodes = defaultShardVirtualNodes
+	}
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	points := make([]point, 0, len(ring.shards)*nodes)
+	for i, s := range ring.shards {
+		for n := 0; n < nodes; n++ {
+			points = append(points, point{shardHash(s.url.String() + "#" + strconv.Itoa(n)), i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	for _, p := range points {
+		ring.points = append(ring.points, p.hash)
+		ring.owners = append(ring.owners, p.owner)
+	}
+
+	// Each point owns the hash space from the previous point up to itself
+	if len(ring.points) == 1 {
+		ring.shards[0].share = 1
+	}
+	for i, p := range ring.points {
+		span := p - ring.points[(i+len(ring.points)-1)%len(ring.points)]
+		ring.shards[ring.owners[i]].share += float64(span) / (1 << 64)
+	}
+	return ring, nil
+}
+
+// shardHash hashes a key onto the ring. FNV alone leaves similar keys such
+// as sequential IDs close together, so its result is mixed with the
+// splitmix64 finalizer to spread them around the ring
+func shardHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// key returns the attribute req is routed by
+func (r *ShardRing) key(req *OptimizationRequest, target *url.URL) string {
+	switch r.keyKind {
+	case "header":
+		if value := requestHeaderValue(req.Headers, r.keyName); value != "" {
+			return value
+		}
+	case "query":
+		if value := target.Query().Get(r.keyName); value != "" {
+			return value
+		}
+	}
+	return target.Path
+}
+
+// Pick returns the shard owning req's key, passing over shards whose circuit
+// breaker in circuits is open while another is not
+func (r *ShardRing) Pick(req *OptimizationRequest, target *url.URL, circuits *CircuitRegistry) *url.URL {
+	hash := shardHash(r.key(req, target))
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash }) % len(r.points)
+
+	owner := r.shards[r.owners[start]]
+	if circuits == nil || circuits.Get(owner.url.Host).State() != CircuitOpen {
+		return owner.url
+	}
+
+	// Walk the ring to the next shard that is not open
+	tried := map[*shard]bool{owner: true}
+	for i := 1; i < len(r.points) && len(tried) < len(r.shards); i++ {
+		next := r.shards[r.owners[(start+i)%len(r.points)]]
+		if tried[next] {
+			continue
+		}
+		tried[next] = true
+		if circuits.Get(next.url.Host).State() != CircuitOpen {
+			r.mu.Lock()
+			next.counter.failovers++
+			r.mu.Unlock()
+			return next.url
+		}
+	}
+	return owner.url
+}
+
+// Record counts a request sent to the shard serving host
+func (r *ShardRing) Record(host string, latency time.Duration, cacheHit bool, err error) {
+	s, ok := r.byHost[host]
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.counter.requests++
+	s.counter.latency += latency
+	if err != nil {
+		s.counter.errors++
+	}
+	if cacheHit {
+		s.counter.hits++
+	}
+}
+
+// Stats describes every shard in configuration order
+func (r *ShardRing) Stats(circuits *CircuitRegistry) []ShardStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]ShardStats, 0, len(r.shards))
+	for _, s := range r.shards {
+		entry := ShardStats{
+			URL:       s.url.String(),
+			KeyShare:  s.share,
+			Requests:  s.counter.requests,
+			Errors:    s.counter.errors,
+			CacheHits: s.counter.hits,
+			Failovers: s.counter.failovers,
+		}
+		if s.counter.requests > 0 {
+			entry.CacheHitRatio = float64(s.counter.hits) / float64(s.counter.requests)
+			entry.AvgLatency = s.counter.latency / time.Duration(s.counter.requests)
+		}
+		if circuits != nil {
+			entry.CircuitState = circuits.Get(s.url.Host).State()
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
@@ -0,0 +1,289 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLoadShed is returned when a request is rejected to protect the daemon
+var ErrLoadShed = errors.New("request shed under load")
+
+// Priority classifies a request for admission; lower values are served first
+type Priority int
+
+const (
+	PriorityCritical Priority = iota
+	PriorityHigh
+	PriorityNormal
+	PriorityLow
+	numPriorities
+)
+
+var priorityNames = [numPriorities]string{"critical", "high", "normal", "low"}
+
+// String returns the priority name
+func (p Priority) String() string {
+	if p < 0 || p >= numPriorities {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority parses a priority name, reporting whether it was recognized
+func ParsePriority(name string) (Priority, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, known := range priorityNames {
+		if name == known {
+			return Priority(i), true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// LoadSheddingConfig configures priority queueing in front of the optimizer
+type LoadSheddingConfig struct {
+	Enabled       bool              `yaml:"enabled" json:"enabled"`
+	MaxConcurrent int               `yaml:"max_concurrent" json:"max_concurrent"` // Requests optimized at once
+	QueueDepths   map[string]int    `yaml:"queue_depths" json:"queue_depths"`     // Waiting requests allowed per priority
+	QueueTimeout  time.Duration     `yaml:"queue_timeout" json:"queue_timeout"`   // Longest a request waits for a slot
+	RetryAfter    time.Duration     `yaml:"retry_after" json:"retry_after"`       // Hint sent with 429 responses
+	Header        string            `yaml:"header" json:"header"`                 // Header carrying the priority name
+	Routes        map[string]string `yaml:"routes" json:"routes,omitempty"`       // URL prefix (host/path) to priority
+	Default       string            `yaml:"default" json:"default"`
+}
+
+// DefaultLoadSheddingConfig returns disabled load shedding that, once
+// enabled, optimizes 64 requests at once
+func DefaultLoadSheddingConfig() LoadSheddingConfig {
+	return LoadSheddingConfig{
+		MaxConcurrent: 64,
+		QueueDepths: map[string]int{
+			"critical": 256,
+			"high":     128,
+			"normal":   64,
+			"low":      16,
+		},
+		QueueTimeout: 5 * time.Second,
+		RetryAfter:   2 * time.Second,
+		Header:       "X-Apilo-Priority",
+		Default:      "normal",
+	}
+}
+
+// PrioritySheddingStats reports admission counters for one priority
+type PrioritySheddingStats struct {
+	Priority string  `json:"priority"`
+	Queued   int     `json:"queued"`
+	Admitted int64   `json:"admitted"`
+	Shed     int64   `json:"shed"`
+	ShedRate float64 `json:"shed_rate"`
+}
+
+// SheddingStats is a point-in-time view of the admission controller
+type SheddingStats struct {
+	Enabled    bool                    `json:"enabled"`
+	InFlight   int                     `json:"in_flight"`
+	Capacity   int                     `json:"capacity"`
+	Priorities []PrioritySheddingStats `json:"priorities"`
+}
+
+// admissionWaiter is a queued request waiting for a slot
+type admissionWaiter struct {
+	ready chan struct{}
+}
+
+// AdmissionController bounds concurrent work and queues the overflow by
+// priority, shedding requests whose queue is full or whose wait times out
+type AdmissionController struct {
+	config   LoadSheddingConfig
+	defaultP Priority
+
+	inFlight int
+	queues   [numPriorities][]*admissionWaiter
+	depths   [numPriorities]int
+	admitted [numPriorities]int64
+	shed     [numPriorities]int64
+
+	mu sync.Mutex
+}
+
+// NewAdmissionController creates a controller from config
+func NewAdmissionController(config LoadSheddingConfig) *AdmissionController {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 64
+	}
+	if config.Header == "" {
+		config.Header = "X-Apilo-Priority"
+	}
+
+	ac := &AdmissionController{config: config}
+	ac.defaultP, _ = ParsePriority(config.Default)
+	for name, depth := range config.QueueDepths {
+		if p, ok := ParsePriority(name); ok {
+			ac.depths[p] = depth
+		}
+	}
+	return ac
+}
+
+// Classify picks a priority from the request header, the optimized request's
+// own headers, then the longest matching route prefix
+func (ac *AdmissionController) Classify(r *http.Request, req *OptimizationRequest) Priority {
+	if p, ok := ParsePriority(r.Header.Get(ac.config.Header)); ok {
+		return p
+	}
+	for name, value := range req.Headers {
+		if strings.EqualFold(name, ac.config.Header) {
+			if p, ok := ParsePriority(value); ok {
+				return p
+			}
+		}
+	}
+
+	target := req.URL
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+
+	best, bestLen := ac.defaultP, -1
+	for prefix, name := range ac.config.Routes {
+		if strings.HasPrefix(target, prefix) && len(prefix) > bestLen {
+			if p, ok := ParsePriority(name); ok {
+				best, bestLen = p, len(prefix)
+			}
+		}
+	}
+	return best
+}
+
+// Acquire waits for a slot at priority p; the returned release func must be
+// called once the request is done. ErrLoadShed means the request was dropped
+func (ac *AdmissionController) Acquire(ctx context.Context, p Priority) (func(), error) {
+	ac.mu.Lock()
+
+	// Take a free slot unless more important work is already waiting
+	if ac.inFlight < ac.config.MaxConcurrent && !ac.waitingAtOrAbove(p) {
+		ac.inFlight++
+		ac.admitted[p]++
+		ac.mu.Unlock()
+		return ac.release, nil
+	}
+
+	if len(ac.queues[p]) >= ac.depths[p] {
+		ac.shed[p]++
+		ac.mu.Unlock()
+		return nil, ErrLoadShed
+	}
+
+	waiter := &admissionWaiter{ready: make(chan struct{})}
+	ac.queues[p] = append(ac.queues[p], waiter)
+	ac.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if ac.config.QueueTimeout > 0 {
+		timer := time.NewTimer(ac.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-waiter.ready:
+		return ac.release, nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	// The slot may have been handed over while we were giving up
+	select {
+	case <-waiter.ready:
+		return ac.release, nil
+	default:
+	}
+
+	ac.removeWaiter(p, waiter)
+	ac.shed[p]++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrLoadShed
+}
+
+// release frees a slot, handing it directly to the highest-priority waiter
+func (ac *AdmissionController) release() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for p := Priority(0); p < numPriorities; p++ {
+		if len(ac.queues[p]) > 0 {
+			waiter := ac.queues[p][0]
+			ac.queues[p] = ac.queues[p][1:]
+			ac.admitted[p]++
+			close(waiter.ready)
+			return
+		}
+	}
+	ac.inFlight--
+}
+
+// waitingAtOrAbove reports whether requests of priority p or higher are
+// queued; callers must hold ac.mu
+func (ac *AdmissionController) waitingAtOrAbove(p Priority) bool {
+	for q := Priority(0); q <= p; q++ {
+		if len(ac.queues[q]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// removeWaiter drops waiter from the queue for p; callers must hold ac.mu
+func (ac *AdmissionController) removeWaiter(p Priority, waiter *admissionWaiter) {
+	queue := ac.queues[p]
+	for i, w := range queue {
+		if w == waiter {
+			ac.queues[p] = append(queue[:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// RetryAfter returns the Retry-After hint for shed requests
+func (ac *AdmissionController) RetryAfter() time.Duration {
+	return ac.config.RetryAfter
+}
+
+// Stats returns per-priority admission and shed counters
+func (ac *AdmissionController) Stats() SheddingStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	stats := SheddingStats{
+		Enabled:    true,
+		InFlight:   ac.inFlight,
+		Capacity:   ac.config.MaxConcurrent,
+		Priorities: make([]PrioritySheddingStats, numPriorities),
+	}
+
+	for p := Priority(0); p < numPriorities; p++ {
+		var shedRate float64
+		if total := ac.admitted[p] + ac.shed[p]; total > 0 {
+			shedRate = float64(ac.shed[p]) / float64(total)
+		}
+		stats.Priorities[p] = PrioritySheddingStats{
+			Priority: p.String(),
+			Queued:   len(ac.queues[p]),
+			Admitted: ac.admitted[p],
+			Shed:     ac.shed[p],
+			ShedRate: shedRate,
+		}
+	}
+
+	return stats
+}
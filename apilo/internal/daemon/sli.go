@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SLIConfig sets the latency thresholds requests are counted against per
+// endpoint, for burn-rate alerts built on the exported good/total counters
+type SLIConfig struct {
+	Thresholds []time.Duration `yaml:"thresholds" json:"thresholds"`
+
+	// Endpoints past MaxEndpoints are counted under endpoint="other" to bound
+	// the number of series
+	MaxEndpoints int `yaml:"max_endpoints" json:"max_endpoints"`
+}
+
+// DefaultSLIConfig returns thresholds from 100ms to 2.5s over 200 endpoints
+func DefaultSLIConfig() SLIConfig {
+	return SLIConfig{
+		Thresholds: []time.Duration{
+			100 * time.Millisecond,
+			250 * time.Millisecond,
+			500 * time.Millisecond,
+			time.Second,
+			2500 * time.Millisecond,
+		},
+		MaxEndpoints: 200,
+	}
+}
+
+// sliOverflowEndpoint labels requests to endpoints past MaxEndpoints
+const sliOverflowEndpoint = "other"
+
+// sliKey identifies an endpoint's series
+type sliKey struct {
+	profile  string
+	method   string
+	endpoint string
+}
+
+// sliCounts holds an endpoint's counters. fast[i] counts available requests
+// served within the i-th threshold
+type sliCounts struct {
+	total     int64
+	available int64
+	fast      []int64
+}
+
+// SLITracker counts requests per endpoint as SLI good/total events
+type SLITracker struct {
+	thresholds   []time.Duration
+	maxEndpoints int
+	series       map[sliKey]*sliCounts
+	mu           sync.Mutex
+}
+
+// NewSLITracker creates a tracker for config
+func NewSLITracker(config SLIConfig) *SLITracker {
+	thresholds := slices.Clone(config.Thresholds)
+	slices.Sort(thresholds)
+	return &SLITracker{
+		thresholds:   slices.Compact(thresholds),
+		maxEndpoints: config.MaxEndpoints,
+		series:       make(map[sliKey]*sliCounts),
+	}
+}
+
+// Record counts record against profile's endpoint. A request is available
+// when it got a response below 500, and fast when it is available and its
+// service time is within a threshold
+func (t *SLITracker) Record(profile string, record RequestRecord) {
+	key := sliKey{
+		profile:  profile,
+		method:   strings.ToUpper(record.Method),
+		endpoint: sliEndpoint(record.URL),
+	}
+	available := record.Error == "" && record.StatusCode < 500
+	latency := time.Duration(record.Latency)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts, ok := t.series[key]
+	if !ok {
+		if t.maxEndpoints > 0 && len(t.series) >= t.maxEndpoints {
+			key.endpoint = sliOverflowEndpoint
+			counts = t.series[key]
+		}
+		if counts == nil {
+			counts = &sliCounts{fast: make([]int64, len(t.thresholds))}
+			t.series[key] = counts
+		}
+	}
+
+	counts.total++
+	if !available {
+		return
+	}
+	counts.available++
+	for i, threshold := range t.thresholds {
+		if latency <= threshold {
+			counts.fast[i]++
+		}
+	}
+}
+
+// WritePrometheus writes the counters in the Prometheus text format
+func (t *SLITracker) WritePrometheus(w io.Writer) error {
+	t.mu.Lock()
+	keys := make([]sliKey, 0, len(t.series))
+	series := make(map[sliKey]sliCounts, len(t.series))
+	for key, counts := range t.series {
+		keys = append(keys, key)
+		series[key] = sliCounts{total: counts.total, available: counts.available, fast: slices.Clone(counts.fast)}
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(keys, func(a, b sliKey) int {
+		if c := strings.Compare(a.profile, b.profile); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.endpoint, b.endpoint); c != 0 {
+			return c
+		}
+		return strings.Compare(a.method, b.method)
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# HELP apilo_sli_requests_total Requests per endpoint\n")
+	sb.WriteString("# TYPE apilo_sli_requests_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "apilo_sli_requests_total{%s} %d\n", key.labels(), series[key].total)
+	}
+
+	sb.WriteString("# HELP apilo_sli_available_total Requests per endpoint that got a response below 500\n")
+	sb.WriteString("# TYPE apilo_sli_available_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "apilo_sli_available_total{%s} %d\n", key.labels(), series[key].available)
+	}
+
+	sb.WriteString("# HELP apilo_sli_latency_good_total Available requests per endpoint served within threshold seconds\n")
+	sb.WriteString("# TYPE apilo_sli_latency_good_total counter\n")
+	for _, key := range keys {
+		for i, threshold := range t.thresholds {
+			seconds := strconv.FormatFloat(threshold.Seconds(), 'g', -1, 64)
+			fmt.Fprintf(&sb, "apilo_sli_latency_good_total{%s,threshold=\"%s\"} %d\n", key.labels(), seconds, series[key].fast[i])
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// labels formats the key as Prometheus labels
+func (k sliKey) labels() string {
+	return fmt.Sprintf(`profile="%s",method="%s",endpoint="%s"`,
+		escapeLabelValue(k.profile), escapeLabelValue(k.method), escapeLabelValue(k.endpoint))
+}
+
+// escapeLabelValue escapes a Prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// sliEndpoint reduces rawURL to host and path, replacing identifier-like
+// path segments with ":id" so one route maps to one series
+func sliEndpoint(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return sliOverflowEndpoint
+	}
+
+	segments := strings.Split(parsed.EscapedPath(), "/")
+	for i, segment := range segments {
+		if isIdentifierSegment(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return parsed.Host + strings.Join(segments, "/")
+}
+
+// isIdentifierSegment reports whether a path segment looks like an ID:
+// all digits, or a UUID or hex string of at least 16 characters
+func isIdentifierSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	digits, hex := true, len(segment) >= 16
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'a' && r <= 'f', r >= 'A' && r <= 'F', r == '-':
+			digits = false
+		default:
+			return false
+		}
+	}
+	return digits || hex
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase names the part of an upstream request that ran out of time
+type TimeoutPhase string
+
+const (
+	PhaseDNS     TimeoutPhase = "dns"
+	PhaseConnect TimeoutPhase = "connect"
+	PhaseTLS     TimeoutPhase = "tls"
+	PhaseTTFB    TimeoutPhase = "ttfb"
+	PhaseTotal   TimeoutPhase = "total"
+	PhaseCaller  TimeoutPhase = "caller" // The caller's own deadline expired first, or it gave up
+)
+
+// PhaseTimeouts bounds each phase of an upstream request; zero disables a phase
+type PhaseTimeouts struct {
+	DNS     time.Duration `yaml:"dns" json:"dns"`
+	Connect time.Duration `yaml:"connect" json:"connect"`
+	TLS     time.Duration `yaml:"tls" json:"tls"`
+	TTFB    time.Duration `yaml:"ttfb" json:"ttfb"` // From request written to first response byte
+	Total   time.Duration `yaml:"total" json:"total"`
+}
+
+// DefaultPhaseTimeouts returns the default per-phase budgets
+func DefaultPhaseTimeouts() PhaseTimeouts {
+	return PhaseTimeouts{
+		DNS:     5 * time.Second,
+		Connect: 5 * time.Second,
+		TLS:     10 * time.Second,
+		TTFB:    20 * time.Second,
+		Total:   30 * time.Second,
+	}
+}
+
+// writeTimeoutMargin is how long past the total upstream budget a response may
+// take to write, covering the work around the upstream request itself
+const writeTimeoutMargin = 5 * time.Second
+
+// writeTimeout bounds writing responses to requests made within any of
+// budgets, so the server never cuts off a request its phases still allow.
+// It is zero, no limit, when a budget has no total
+func writeTimeout(budgets ...PhaseTimeouts) time.Duration {
+	var longest time.Duration
+	for _, budget := range budgets {
+		if budget.Total <= 0 {
+			return 0
+		}
+		longest = max(longest, budget.Total)
+	}
+	return longest + writeTimeoutMargin
+}
+
+// PhaseTimeoutError reports which phase exceeded its budget. Canceled marks a
+// PhaseCaller error where the caller cancelled rather than timed out
+type PhaseTimeoutError struct {
+	Phase    TimeoutPhase
+	Budget   time.Duration
+	Canceled bool
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	if e.Canceled {
+		return fmt.Sprintf("%s canceled", e.Phase)
+	}
+	if e.Budget > 0 {
+		return fmt.Sprintf("%s timeout after %v", e.Phase, e.Budget)
+	}
+	return fmt.Sprintf("%s timeout", e.Phase)
+}
+
+// Timeout marks the error as a timeout for net.Error-style checks
+func (e *PhaseTimeoutError) Timeout() bool { return !e.Canceled }
+
+// Unwrap lets errors.Is match context.DeadlineExceeded, or context.Canceled
+func (e *PhaseTimeoutError) Unwrap() error {
+	if e.Canceled {
+		return context.Canceled
+	}
+	return context.DeadlineExceeded
+}
+
+// phaseTimer cancels a request when the phase in progress overruns
+type phaseTimer struct {
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+	mu     sync.Mutex
+}
+
+// start arms the timer for phase, replacing any phase still running
+func (pt *phaseTimer) start(phase TimeoutPhase, budget time.Duration) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.timer != nil {
+		pt.timer.Stop()
+		pt.timer = nil
+	}
+	if budget > 0 {
+		pt.timer = time.AfterFunc(budget, func() {
+			pt.cancel(&PhaseTimeoutError{Phase: phase, Budget: budget})
+		})
+	}
+}
+
+// stop disarms the current phase
+func (pt *phaseTimer) stop() {
+	pt.start("", 0)
+}
+
+// withPhaseTimeouts derives a context that enforces timeouts on each phase of
+// an upstream request while still honoring any deadline the caller set. The
+// returned cancel func must be called once the response body has been read
+func withPhaseTimeouts(parent context.Context, timeouts PhaseTimeouts) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(parent)
+
+	cancelTotal := context.CancelFunc(func() {})
+	if timeouts.Total > 0 {
+		ctx, cancelTotal = context.WithTimeoutCause(ctx, timeouts.Total,
+			&PhaseTimeoutError{Phase: PhaseTotal, Budget: timeouts.Total})
+	}
+
+	pt := &phaseTimer{cancel: cancelCause}
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { pt.start(PhaseDNS, timeouts.DNS) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { pt.stop() },
+		ConnectStart:         func(string, string) { pt.start(PhaseConnect, timeouts.Connect) },
+		ConnectDone:          func(string, string, error) { pt.stop() },
+		TLSHandshakeStart:    func() { pt.start(PhaseTLS, timeouts.TLS) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { pt.stop() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { pt.start(PhaseTTFB, timeouts.TTFB) },
+		GotFirstResponseByte: func() { pt.stop() },
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), func() {
+		pt.stop()
+		cancelTotal()
+		cancelCause(nil)
+	}
+}
+
+// classifyTimeout replaces a timeout err with the PhaseTimeoutError that caused
+// it. A caller's expired deadline or cancellation becomes PhaseCaller, so it is
+// not charged to the upstream's breaker
+func classifyTimeout(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	var phaseErr *PhaseTimeoutError
+	if errors.As(context.Cause(ctx), &phaseErr) {
+		return phaseErr
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &PhaseTimeoutError{Phase: PhaseCaller}
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &PhaseTimeoutError{Phase: PhaseCaller, Canceled: true}
+	}
+	return err
+}
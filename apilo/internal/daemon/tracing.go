@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TracingConfig keeps a span per request, annotated with the decisions that
+// shaped it: cache outcome, deduplication, breaker state, rate limiting and
+// the upstream endpoint. Only requests kept by sampling are retained
+type TracingConfig struct {
+	Enabled   bool `yaml:"enabled" json:"enabled"`
+	MaxTraces int  `yaml:"max_traces" json:"max_traces"` // Most recent spans kept in memory
+}
+
+// DefaultTracingConfig returns disabled tracing keeping 1000 spans
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{MaxTraces: 1000}
+}
+
+// Span describes one request through the optimizer
+type Span struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"` // From the caller's traceparent
+	Name         string                 `json:"name"`
+	Start        time.Time              `json:"start"`
+	Duration     time.Duration          `json:"duration"`
+	Error        string                 `json:"error,omitempty"`
+	Annotations  map[string]interface{} `json:"annotations"`
+	Events       []SpanEvent            `json:"events,omitempty"`
+}
+
+// SpanEvent is a timestamped step within a span, e.g. a retry
+type SpanEvent struct {
+	Time       time.Time              `json:"time"`
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// activeSpan is a span still being annotated
+type activeSpan struct {
+	span Span
+	mu   sync.Mutex
+}
+
+// spanKey is the context key of a request's activeSpan
+type spanKey struct{}
+
+// startSpan returns ctx carrying a new span. A W3C traceparent continues the
+// caller's trace; otherwise a new trace is started
+func startSpan(ctx context.Context, name, traceparent string) (context.Context, *activeSpan) {
+	active := &activeSpan{span: Span{
+		SpanID:      randomID(8),
+		Name:        name,
+		Start:       time.Now(),
+		Annotations: make(map[string]interface{}),
+	}}
+	if parts := strings.Split(traceparent, "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		active.span.TraceID, active.span.ParentSpanID = parts[1], parts[2]
+	} else {
+		active.span.TraceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, active), active
+}
+
+// annotate sets key on ctx's span, if any
+func annotate(ctx context.Context, key string, value interface{}) {
+	if active, ok := ctx.Value(spanKey{}).(*activeSpan); ok {
+		active.mu.Lock()
+		active.span.Annotations[key] = value
+		active.mu.Unlock()
+	}
+}
+
+// spanEvent adds an event to ctx's span, if any
+func spanEvent(ctx context.Context, name string, attributes map[string]interface{}) {
+	if active, ok := ctx.Value(spanKey{}).(*activeSpan); ok {
+		active.mu.Lock()
+		active.span.Events = append(active.span.Events, SpanEvent{Time: time.Now(), Name: name, Attributes: attributes})
+		active.mu.Unlock()
+	}
+}
+
+// finish ends the span and returns a copy of it
+func (a *activeSpan) finish(err error) Span {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.span.Duration = time.Since(a.span.Start)
+	if err != nil {
+		a.span.Error = err.Error()
+	}
+	span := a.span
+	span.Annotations = maps.Clone(a.span.Annotations)
+	span.Events = append([]SpanEvent(nil), a.span.Events...)
+	return span
+}
+
+// Tracer keeps the most recent finished spans
+type Tracer struct {
+	spans []Span
+	next  int
+	full  bool
+	mu    sync.RWMutex
+}
+
+// NewTracer creates a tracer for config
+func NewTracer(config TracingConfig) *Tracer {
+	size := config.MaxTraces
+	if size <= 0 {
+		size = DefaultTracingConfig().MaxTraces
+	}
+	return &Tracer{spans: make([]Span, size)}
+}
+
+// Record keeps span, evicting the oldest when full
+func (t *Tracer) Record(span Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans[t.next] = span
+	t.next = (t.next + 1) % len(t.spans)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Recent returns up to limit spans, newest first, optionally only those of
+// traceID
+func (t *Tracer) Recent(limit int, traceID string) []Span {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	count := t.next
+	if t.full {
+		count = len(t.spans)
+	}
+
+	spans := []Span{}
+	for i := 1; i <= count && len(spans) < limit; i++ {
+		span := t.spans[(t.next-i+len(t.spans))%len(t.spans)]
+		if traceID == "" || span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// randomID returns n random bytes hex-encoded
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -8,19 +8,27 @@ type OptimizationRequest struct {
 	Method  string            `json:"method"`
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    []byte            `json:"body,omitempty"`
-	Timeout time.Duration     `json:"timeout,omitempty"`
+	Timeout time.Duration     `json:"timeout,omitempty"` // Caller deadline, propagated upstream
 }
 
 // OptimizationResponse contains the optimized response
 type OptimizationResponse struct {
-	StatusCode int               `json:"status_code"`
-	Headers    map[string]string `json:"headers"`
-	Body       []byte            `json:"body"`
-	Latency    time.Duration     `json:"latency"`
-	CacheHit   bool              `json:"cache_hit"`
-	Optimized  bool              `json:"optimized"`
-	Error      string            `json:"error,omitempty"`
-	Metadata   ResponseMetadata  `json:"metadata"`
+	StatusCode   int               `json:"status_code"`
+	Headers      map[string]string `json:"headers"`
+	Body         []byte            `json:"body"`
+	Latency      time.Duration     `json:"latency"` // Service time, excluding QueueLatency
+	CacheHit     bool              `json:"cache_hit"`
+	Revalidated  bool              `json:"revalidated"`
+	Deduplicated bool              `json:"deduplicated,omitempty"` // Shared with an identical earlier request
+	Optimized    bool              `json:"optimized"`
+	Error        string            `json:"error,omitempty"`
+	Metadata     ResponseMetadata  `json:"metadata"`
+
+	// QueueLatency is time spent waiting in admission control and the rate
+	// limiter before the request was served
+	QueueLatency time.Duration `json:"queue_latency,omitempty"`
+
+	TraceID string `json:"trace_id,omitempty"` // Span on /traces explaining this response
 }
 
 // ResponseMetadata provides optimization details
@@ -64,11 +72,14 @@ type DaemonStatus struct {
 	MemoryUsageMB float64             `json:"memory_usage_mb"`
 	CPUPercent    float64             `json:"cpu_percent"`
 	ClaudeMetrics *ClaudeTokenMetrics `json:"claude_metrics,omitempty"`
+	Profile       string              `json:"profile,omitempty"` // Set when scoped to one upstream profile
 }
 
 // DaemonConfig holds daemon configuration
 type DaemonConfig struct {
 	Port                 int           `yaml:"port" json:"port"`
+	ListenAddress        string        `yaml:"listen_address" json:"listen_address"` // Interface the IPC and profile servers bind; empty binds all
+	ReusePort            bool          `yaml:"reuse_port" json:"reuse_port"`         // SO_REUSEPORT, so several daemons can share the ports
 	LogLevel             string        `yaml:"log_level" json:"log_level"`
 	LogFile              string        `yaml:"log_file" json:"log_file"`
 	PIDFile              string        `yaml:"pid_file" json:"pid_file"`
@@ -79,12 +90,96 @@ type DaemonConfig struct {
 	EnableHTTP2          bool          `yaml:"enable_http2" json:"enable_http2"`
 	EnableCircuitBreaker bool          `yaml:"enable_circuit_breaker" json:"enable_circuit_breaker"`
 	MetricsEnabled       bool          `yaml:"metrics_enabled" json:"metrics_enabled"`
+
+	// Shared breaker defaults plus per-host overrides (keyed by host:port)
+	CircuitBreaker          CircuitBreakerConfig            `yaml:"circuit_breaker" json:"circuit_breaker"`
+	CircuitBreakerOverrides map[string]CircuitBreakerConfig `yaml:"circuit_breaker_overrides" json:"circuit_breaker_overrides,omitempty"`
+
+	// Breaker state is saved periodically and restored on startup; state
+	// older than CircuitStateMaxAge is discarded
+	CircuitStateFile     string        `yaml:"circuit_state_file" json:"circuit_state_file"`
+	CircuitStateInterval time.Duration `yaml:"circuit_state_interval" json:"circuit_state_interval"`
+	CircuitStateMaxAge   time.Duration `yaml:"circuit_state_max_age" json:"circuit_state_max_age"`
+
+	// Priority queueing and 429 shedding for /optimize under overload
+	LoadShedding LoadSheddingConfig `yaml:"load_shedding" json:"load_shedding"`
+
+	// Token bucket limits on upstream traffic to stay under provider quotas
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+
+	// Budgets for each phase of an upstream request, replacing a single timeout
+	Timeouts PhaseTimeouts `yaml:"timeouts" json:"timeouts"`
+
+	// Named upstreams with isolated caches and stats, served under
+	// /profiles/<name>/ and optionally on their own port. Profiles listed in
+	// ProfilesFile (a JSON array) are added at startup
+	Profiles     []UpstreamProfile `yaml:"profiles" json:"profiles,omitempty"`
+	ProfilesFile string            `yaml:"profiles_file" json:"profiles_file"`
+
+	// Shadow traffic to a candidate upstream for side-by-side comparison
+	Mirror MirrorConfig `yaml:"mirror" json:"mirror"`
+
+	// Collapsing of identical requests re-sent within a short window
+	Dedup DedupConfig `yaml:"dedup" json:"dedup"`
+
+	// Probabilistic background refresh of cache entries nearing expiry
+	EarlyRefresh EarlyRefreshConfig `yaml:"early_refresh" json:"early_refresh"`
+
+	// Caching of byte-range responses, assembled into whole objects
+	RangeCache RangeCacheConfig `yaml:"range_cache" json:"range_cache"`
+
+	// Per-entry TTLs learned from how often content changes
+	AdaptiveTTL AdaptiveTTLConfig `yaml:"adaptive_ttl" json:"adaptive_ttl"`
+
+	// Which entries the cache evicts first once it is full
+	Eviction EvictionConfig `yaml:"eviction" json:"eviction"`
+
+	// Latency thresholds for the per-endpoint SLI counters on /metrics/sli
+	SLI SLIConfig `yaml:"sli" json:"sli"`
+
+	// Append-only log of request metadata, replayed into analytics on startup
+	Journal JournalConfig `yaml:"journal" json:"journal"`
+
+	// Gossip of cache invalidations and hot keys with the other replicas
+	Peers PeersConfig `yaml:"peers" json:"peers"`
+
+	// Conditions /health/ready waits on: cache warmup and upstream reachability
+	Readiness ReadinessConfig `yaml:"readiness" json:"readiness"`
+
+	// Common, combined or JSON lines log of every proxied request
+	AccessLog AccessLogConfig `yaml:"access_log" json:"access_log"`
+
+	// Head-based sampling of the requests recorded in analytics and the journal
+	Sampling SamplingConfig `yaml:"sampling" json:"sampling"`
+
+	// Annotated per-request spans, served on /traces
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+
+	// Background goroutine and descriptor leak detection, served on /leaks
+	LeakDetection LeakDetectionConfig `yaml:"leak_detection" json:"leak_detection"`
+
+	// Benchmark suites run on cron schedules, served on /schedules, and the
+	// benchmark tool they invoke, looked up in PATH unless it is a path
+	Schedules       []BenchmarkSchedule `yaml:"schedules" json:"schedules,omitempty"`
+	ScheduleCommand string              `yaml:"schedule_command" json:"schedule_command"`
+
+	// On SIGTERM, how long in-flight requests may drain before their
+	// connections are closed, and how long the whole shutdown, including
+	// flushing the journal and breaker state, may take before the process
+	// exits regardless
+	DrainTimeout    time.Duration `yaml:"drain_timeout" json:"drain_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	// Bearer token for the /admin endpoints; APILO_ADMIN_TOKEN is used when
+	// empty, and the admin API is disabled when neither is set
+	AdminToken string `yaml:"admin_token" json:"-"`
 }
 
 // DefaultDaemonConfig returns default configuration
 func DefaultDaemonConfig() *DaemonConfig {
 	return &DaemonConfig{
 		Port:                 9876,
+		ListenAddress:        "localhost",
 		LogLevel:             "info",
 		LogFile:              "~/.apilo/logs/daemon.log",
 		PIDFile:              "~/.apilo/daemon.pid",
@@ -95,5 +190,30 @@ func DefaultDaemonConfig() *DaemonConfig {
 		EnableHTTP2:          true,
 		EnableCircuitBreaker: true,
 		MetricsEnabled:       true,
+		CircuitBreaker:       DefaultCircuitBreakerConfig(),
+		CircuitStateFile:     "~/.apilo/circuits.json",
+		CircuitStateInterval: 30 * time.Second,
+		CircuitStateMaxAge:   10 * time.Minute,
+		LoadShedding:         DefaultLoadSheddingConfig(),
+		RateLimit:            DefaultRateLimitConfig(),
+		Timeouts:             DefaultPhaseTimeouts(),
+		ProfilesFile:         "~/.apilo/profiles.json",
+		Mirror:               DefaultMirrorConfig(),
+		Dedup:                DefaultDedupConfig(),
+		EarlyRefresh:         DefaultEarlyRefreshConfig(),
+		RangeCache:           DefaultRangeCacheConfig(),
+		AdaptiveTTL:          DefaultAdaptiveTTLConfig(),
+		Eviction:             DefaultEvictionConfig(),
+		SLI:                  DefaultSLIConfig(),
+		Journal:              DefaultJournalConfig(),
+		AccessLog:            DefaultAccessLogConfig(),
+		Readiness:            DefaultReadinessConfig(),
+		Peers:                DefaultPeersConfig(),
+		Sampling:             DefaultSamplingConfig(),
+		Tracing:              DefaultTracingConfig(),
+		LeakDetection:        DefaultLeakDetectionConfig(),
+		ScheduleCommand:      DefaultScheduleCommand,
+		DrainTimeout:         30 * time.Second,
+		ShutdownTimeout:      45 * time.Second,
 	}
 }
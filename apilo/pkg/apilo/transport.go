@@ -0,0 +1,419 @@
+// Package apilo exposes the latency optimizer as a drop-in http.RoundTripper,
+// so existing Go programs and API SDKs get response caching, tuned HTTP/2
+// connection pooling and metrics by swapping their client's Transport:
+//
+//	client := &http.Client{Transport: apilo.NewTransport(apilo.DefaultConfig())}
+package apilo
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheHeader reports how a response was served: "HIT", "MISS" or "BYPASS"
+const CacheHeader = "X-Apilo-Cache"
+
+// Config configures a Transport
+type Config struct {
+	// Base performs the real requests; nil builds a tuned HTTP/2 transport
+	Base http.RoundTripper `yaml:"-" json:"-"`
+
+	// Response caching; a zero CacheTTL disables it
+	CacheTTL          time.Duration `yaml:"cache_ttl" json:"cache_ttl"`
+	CacheMaxEntries   int           `yaml:"cache_max_entries" json:"cache_max_entries"`
+	CacheMaxBodyBytes int64         `yaml:"cache_max_body_bytes" json:"cache_max_body_bytes"` // Larger responses stream through uncached
+	CacheMethods      []string      `yaml:"cache_methods" json:"cache_methods"`               // POST opts in to caching by request body
+	VaryHeaders       []string      `yaml:"vary_headers" json:"vary_headers"`                 // Request headers that key the cache
+
+	// Connection tuning for the default base transport
+	MaxIdleConns        int           `yaml:"max_idle_conns" json:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`
+	EnableHTTP2         bool          `yaml:"enable_http2" json:"enable_http2"`
+}
+
+// DefaultConfig returns a config that caches GET and HEAD responses for five
+// minutes, keyed per API key, over a pooled HTTP/2 transport
+func DefaultConfig() Config {
+	return Config{
+		CacheTTL:            5 * time.Minute,
+		CacheMaxEntries:     1000,
+		CacheMaxBodyBytes:   1 << 20,
+		CacheMethods:        []string{http.MethodGet, http.MethodHead},
+		VaryHeaders:         []string{"Authorization", "X-Api-Key", "Accept", "Anthropic-Version"},
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 20,
+		IdleConnTimeout:     90 * time.Second,
+		EnableHTTP2:         true,
+	}
+}
+
+// Stats are the transport's cumulative counters
+type Stats struct {
+	Requests     int64         `json:"requests"`
+	CacheHits    int64         `json:"cache_hits"`
+	CacheMisses  int64         `json:"cache_misses"`
+	Bypassed     int64         `json:"bypassed"`
+	Errors       int64         `json:"errors"`
+	CacheEntries int           `json:"cache_entries"`
+	HitRatio     float64       `json:"hit_ratio"`
+	AvgLatency   time.Duration `json:"avg_latency"` // Upstream round trips only
+}
+
+// cachedResponse is a fully buffered response
+type cachedResponse struct {
+	key        string
+	status     int
+	proto      string
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	body       []byte
+	length     int64
+	storedAt   time.Time
+}
+
+// Transport is an http.RoundTripper that serves repeat requests from cache
+// and forwards everything else to its base transport. It is safe for
+// concurrent use
+type Transport struct {
+	config  Config
+	base    http.RoundTripper
+	methods map[string]bool
+
+	entries map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+
+	requests     int64
+	hits         int64
+	misses       int64
+	bypassed     int64
+	errors       int64
+	latencyTotal int64
+	latencyCount int64
+}
+
+// NewTransport creates a Transport from cfg
+func NewTransport(cfg Config) *Transport {
+	if cfg.CacheMaxEntries <= 0 {
+		cfg.CacheMaxEntries = 1000
+	}
+	if cfg.CacheMaxBodyBytes <= 0 {
+		cfg.CacheMaxBodyBytes = 1 << 20
+	}
+	if cfg.CacheMethods == nil {
+		cfg.CacheMethods = []string{http.MethodGet, http.MethodHead}
+	}
+
+	t := &Transport{
+		config:  cfg,
+		base:    cfg.Base,
+		methods: make(map[string]bool, len(cfg.CacheMethods)),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	for _, method := range cfg.CacheMethods {
+		t.methods[strings.ToUpper(method)] = true
+	}
+	if t.base == nil {
+		t.base = newBaseTransport(cfg)
+	}
+	return t
+}
+
+// NewClient returns an http.Client using a new Transport from cfg
+func NewClient(cfg Config) *http.Client {
+	return &http.Client{Transport: NewTransport(cfg)}
+}
+
+// newBaseTransport builds the pooled transport used when Config.Base is nil
+func newBaseTransport(cfg Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ForceAttemptHTTP2:     cfg.EnableHTTP2,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The request is never modified and
+// its body is always closed, as the interface requires
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.requests, 1)
+
+	if !t.cacheable(req) {
+		atomic.AddInt64(&t.bypassed, 1)
+		resp, err := t.forward(req)
+		if err == nil {
+			resp.Header.Set(CacheHeader, "BYPASS")
+		}
+		return resp, err
+	}
+
+	// Cached POSTs are keyed by body, so it has to be read up front; the
+	// upstream request then gets its own copy
+	orig := req
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			atomic.AddInt64(&t.errors, 1)
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	key := t.cacheKey(req, body)
+	if cached, ok := t.lookup(key); ok {
+		atomic.AddInt64(&t.hits, 1)
+		return cached.response(orig), nil
+	}
+	atomic.AddInt64(&t.misses, 1)
+
+	resp, err := t.forward(req)
+	if err != nil {
+		return nil, err
+	}
+	if !storable(resp) {
+		resp.Header.Set(CacheHeader, "MISS")
+		return resp, nil
+	}
+
+	// Buffer up to the size limit; anything larger streams through uncached
+	buffered, err := io.ReadAll(io.LimitReader(resp.Body, t.config.CacheMaxBodyBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		atomic.AddInt64(&t.errors, 1)
+		return nil, err
+	}
+	if int64(len(buffered)) > t.config.CacheMaxBodyBytes {
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(buffered), resp.Body), Closer: resp.Body}
+		resp.Header.Set(CacheHeader, "MISS")
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	entry := &cachedResponse{
+		key:        key,
+		status:     resp.StatusCode,
+		proto:      resp.Proto,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header.Clone(),
+		body:       buffered,
+		length:     int64(len(buffered)),
+		storedAt:   time.Now(),
+	}
+	// HEAD responses report the length of the body they omit
+	if req.Method == http.MethodHead {
+		entry.length = resp.ContentLength
+	}
+	t.store(entry)
+
+	resp.Body = io.NopCloser(bytes.NewReader(buffered))
+	resp.ContentLength = entry.length
+	resp.Header.Set(CacheHeader, "MISS")
+	return resp, nil
+}
+
+// forward sends req to the base transport and records its latency
+func (t *Transport) forward(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		atomic.AddInt64(&t.errors, 1)
+		return nil, err
+	}
+	atomic.AddInt64(&t.latencyTotal, int64(time.Since(start)))
+	atomic.AddInt64(&t.latencyCount, 1)
+	return resp, nil
+}
+
+// cacheable reports whether req may be answered from or stored in the cache
+func (t *Transport) cacheable(req *http.Request) bool {
+	if t.config.CacheTTL <= 0 || !t.methods[req.Method] {
+		return false
+	}
+	// Partial and conditional requests are the caller's own caching
+	if req.Header.Get("Range") != "" || req.Header.Get("If-None-Match") != "" ||
+		req.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	cacheControl := strings.ToLower(req.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "no-cache")
+}
+
+// storable reports whether resp may be kept in the cache
+func storable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	// Streams must reach the caller as they arrive
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return false
+	}
+	cacheControl := strings.ToLower(resp.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private")
+}
+
+// cacheKey hashes the method, URL, vary headers and body of req
+func (t *Transport) cacheKey(req *http.Request, body []byte) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(req.Method))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(req.URL.String()))
+
+	for _, name := range t.config.VaryHeaders {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(name))
+		for _, value := range req.Header.Values(name) {
+			hasher.Write([]byte{0})
+			hasher.Write([]byte(value))
+		}
+	}
+
+	hasher.Write([]byte{0})
+	hasher.Write(body)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// lookup returns a fresh cache entry for key
+func (t *Transport) lookup(key string) (*cachedResponse, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, found := t.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cachedResponse)
+	if time.Since(entry.storedAt) > t.config.CacheTTL {
+		t.lru.Remove(elem)
+		delete(t.entries, key)
+		return nil, false
+	}
+
+	t.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// store adds entry, evicting the least recently used entries over the limit
+func (t *Transport) store(entry *cachedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, exists := t.entries[entry.key]; exists {
+		elem.Value = entry
+		t.lru.MoveToFront(elem)
+		return
+	}
+
+	t.entries[entry.key] = t.lru.PushFront(entry)
+	for t.lru.Len() > t.config.CacheMaxEntries {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// response builds a new response for req from the cached copy; every call
+// gets its own header map and body reader
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	header := c.header.Clone()
+	header.Set(CacheHeader, "HIT")
+	header.Set("Age", formatAge(time.Since(c.storedAt)))
+
+	body := io.NopCloser(bytes.NewReader(c.body))
+	if req.Method == http.MethodHead {
+		body = http.NoBody
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)),
+		StatusCode:    c.status,
+		Proto:         c.proto,
+		ProtoMajor:    c.protoMajor,
+		ProtoMinor:    c.protoMinor,
+		Header:        header,
+		Body:          body,
+		ContentLength: c.length,
+		Request:       req,
+	}
+}
+
+// formatAge renders an Age header value in whole seconds
+func formatAge(age time.Duration) string {
+	return strconv.FormatInt(int64(age/time.Second), 10)
+}
+
+// prefixedBody replays an already-read prefix before the rest of a body
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// InvalidateCache drops every cached response
+func (t *Transport) InvalidateCache() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries = make(map[string]*list.Element)
+	t.lru.Init()
+}
+
+// CloseIdleConnections closes idle connections in the base transport, so
+// http.Client.CloseIdleConnections keeps working after the swap
+func (t *Transport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Stats returns the transport's counters
+func (t *Transport) Stats() Stats {
+	t.mu.Lock()
+	entries := t.lru.Len()
+	t.mu.Unlock()
+
+	stats := Stats{
+		Requests:     atomic.LoadInt64(&t.requests),
+		CacheHits:    atomic.LoadInt64(&t.hits),
+		CacheMisses:  atomic.LoadInt64(&t.misses),
+		Bypassed:     atomic.LoadInt64(&t.bypassed),
+		Errors:       atomic.LoadInt64(&t.errors),
+		CacheEntries: entries,
+	}
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.HitRatio = float64(stats.CacheHits) / float64(lookups)
+	}
+	if count := atomic.LoadInt64(&t.latencyCount); count > 0 {
+		stats.AvgLatency = time.Duration(atomic.LoadInt64(&t.latencyTotal) / count)
+	}
+	return stats
+}
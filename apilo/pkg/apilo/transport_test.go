@@ -0,0 +1,300 @@
+package apilo
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer returns a server that answers with body and counts its hits
+func countingServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *int64) {
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+// get sends a GET through client and returns the response and its body
+func get(t *testing.T, client *http.Client, url string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+// TestTransportCachesGET tests that a repeated GET is served from cache with
+// an independent response each time
+func TestTransportCachesGET(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		io.WriteString(w, "hello")
+	})
+	transport := NewTransport(DefaultConfig())
+	client := &http.Client{Transport: transport}
+
+	first, body := get(t, client, server.URL, nil)
+	if body != "hello" || first.Header.Get(CacheHeader) != "MISS" {
+		t.Fatalf("Unexpected first response: %q %s", body, first.Header.Get(CacheHeader))
+	}
+
+	second, body := get(t, client, server.URL, nil)
+	if body != "hello" || second.Header.Get(CacheHeader) != "HIT" {
+		t.Fatalf("Expected cache hit, got %q %s", body, second.Header.Get(CacheHeader))
+	}
+	if *hits != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", *hits)
+	}
+	if second.StatusCode != http.StatusOK || second.Status != "200 OK" || second.ContentLength != 5 {
+		t.Errorf("Unexpected cached status line: %d %q length %d", second.StatusCode, second.Status, second.ContentLength)
+	}
+	if got := second.Header.Values("X-Multi"); len(got) != 2 {
+		t.Errorf("Multi-value headers should survive caching, got %v", got)
+	}
+	if second.Request == nil || second.Request.URL.String() != server.URL {
+		t.Error("Cached response should reference the request")
+	}
+
+	// Mutating one response must not leak into the cache
+	second.Header.Set("X-Multi", "changed")
+	third, _ := get(t, client, server.URL, nil)
+	if third.Header.Values("X-Multi")[0] != "a" {
+		t.Error("Cached headers were modified through a returned response")
+	}
+
+	stats := transport.Stats()
+	if stats.Requests != 3 || stats.CacheHits != 2 || stats.CacheMisses != 1 || stats.CacheEntries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestTransportVaryHeaders tests that vary headers split the cache per API key
+func TestTransportVaryHeaders(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Api-Key"))
+	})
+	client := NewClient(DefaultConfig())
+
+	_, a := get(t, client, server.URL, http.Header{"X-Api-Key": {"key-a"}})
+	_, b := get(t, client, server.URL, http.Header{"X-Api-Key": {"key-b"}})
+	if a != "key-a" || b != "key-b" {
+		t.Errorf("Responses leaked across API keys: %q %q", a, b)
+	}
+	if *hits != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", *hits)
+	}
+}
+
+// TestTransportBypass tests the requests and responses that must not be cached
+func TestTransportBypass(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		io.WriteString(w, "data")
+	})
+	client := NewClient(DefaultConfig())
+
+	for _, path := range []string{"/nostore", "/error", "/stream"} {
+		get(t, client, server.URL+path, nil)
+		get(t, client, server.URL+path, nil)
+	}
+	get(t, client, server.URL+"/ok", http.Header{"Cache-Control": {"no-cache"}})
+	get(t, client, server.URL+"/ok", http.Header{"Cache-Control": {"no-cache"}})
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(CacheHeader) != "BYPASS" {
+		t.Errorf("POST should bypass the cache by default, got %q", resp.Header.Get(CacheHeader))
+	}
+
+	if *hits != 9 {
+		t.Errorf("Expected every request to reach upstream, got %d of 9", *hits)
+	}
+}
+
+// TestTransportCachesPOSTByBody tests opt-in POST caching keyed by body,
+// with the request body still delivered upstream
+func TestTransportCachesPOSTByBody(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "echo:"+string(body))
+	})
+	config := DefaultConfig()
+	config.CacheMethods = []string{http.MethodPost}
+	client := NewClient(config)
+
+	post := func(body string) string {
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	if got := post("one"); got != "echo:one" {
+		t.Errorf("Request body was not forwarded: %q", got)
+	}
+	if got := post("one"); got != "echo:one" {
+		t.Errorf("Unexpected cached body: %q", got)
+	}
+	if got := post("two"); got != "echo:two" {
+		t.Errorf("Different bodies must not share a cache entry: %q", got)
+	}
+	if *hits != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", *hits)
+	}
+}
+
+// TestTransportLargeBodyStreams tests that bodies over the limit are returned
+// whole but not cached
+func TestTransportLargeBodyStreams(t *testing.T) {
+	large := strings.Repeat("x", 64)
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, large)
+	})
+	config := DefaultConfig()
+	config.CacheMaxBodyBytes = 16
+	client := NewClient(config)
+
+	for i := 0; i < 2; i++ {
+		if _, body := get(t, client, server.URL, nil); body != large {
+			t.Fatalf("Large body was truncated to %d bytes", len(body))
+		}
+	}
+	if *hits != 2 {
+		t.Errorf("Large responses should not be cached, got %d upstream requests", *hits)
+	}
+}
+
+// TestTransportExpiryAndEviction tests TTL expiry and the entry limit
+func TestTransportExpiryAndEviction(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})
+	config := DefaultConfig()
+	config.CacheTTL = 50 * time.Millisecond
+	config.CacheMaxEntries = 2
+	transport := NewTransport(config)
+	client := &http.Client{Transport: transport}
+
+	get(t, client, server.URL+"/a", nil)
+	time.Sleep(60 * time.Millisecond)
+	get(t, client, server.URL+"/a", nil)
+	if *hits != 2 {
+		t.Errorf("Expired entry should be refetched, got %d upstream requests", *hits)
+	}
+
+	get(t, client, server.URL+"/b", nil)
+	get(t, client, server.URL+"/c", nil)
+	if entries := transport.Stats().CacheEntries; entries != 2 {
+		t.Errorf("Expected the cache capped at 2 entries, got %d", entries)
+	}
+
+	transport.InvalidateCache()
+	if entries := transport.Stats().CacheEntries; entries != 0 {
+		t.Errorf("Expected an empty cache after invalidation, got %d", entries)
+	}
+}
+
+// failingTransport fails every request after recording that its body was closed
+type failingTransport struct {
+	closed bool
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+		f.closed = true
+	}
+	return nil, errors.New("upstream unavailable")
+}
+
+// trackingBody records whether it was closed
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// TestTransportRoundTripContract tests errors, body closing and that the
+// caller's request is left untouched
+func TestTransportRoundTripContract(t *testing.T) {
+	base := &failingTransport{}
+	config := DefaultConfig()
+	config.Base = base
+	config.CacheMethods = []string{http.MethodPost}
+	transport := NewTransport(config)
+
+	body := &trackingBody{Reader: strings.NewReader("payload")}
+	req, _ := http.NewRequest(http.MethodPost, "http://example.invalid/", body)
+	req.Header.Set("X-Caller", "1")
+
+	resp, err := transport.RoundTrip(req)
+	if err == nil || resp != nil {
+		t.Fatalf("Expected an error and no response, got %v, %v", resp, err)
+	}
+	if !body.closed {
+		t.Error("Request body must be closed even when the round trip fails")
+	}
+	if req.Body != body || len(req.Header) != 1 || req.Header.Get(CacheHeader) != "" {
+		t.Error("RoundTrip must not modify the caller's request")
+	}
+	if stats := transport.Stats(); stats.Errors != 1 {
+		t.Errorf("Expected 1 error counted, got %d", stats.Errors)
+	}
+}
+
+// TestTransportHEAD tests that cached HEAD responses keep their content length
+// and carry no body
+func TestTransportHEAD(t *testing.T) {
+	server, hits := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "twelve bytes")
+	})
+	client := NewClient(DefaultConfig())
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Head(server.URL)
+		if err != nil {
+			t.Fatalf("HEAD failed: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if len(data) != 0 || resp.ContentLength != 12 {
+			t.Errorf("HEAD %d: got %d body bytes and length %d", i+1, len(data), resp.ContentLength)
+		}
+	}
+	if *hits != 1 {
+		t.Errorf("Expected the second HEAD to be cached, got %d upstream requests", *hits)
+	}
+}
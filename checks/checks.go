@@ -0,0 +1,57 @@
+// Package checks is the extension point for custom per-response checks and
+// custom metrics computed over a benchmark run's responses.
+//
+// A plugin is a Go plugin built with
+//
+//	go build -buildmode=plugin -o mychecks.so ./mychecks
+//
+// whose main package exports a variable named Plugin:
+//
+//	var Plugin checks.Plugin = myPlugin{}
+//
+// The plugin must be built with the same Go toolchain and the same version
+// of this package as the benchmark tool loading it, or loading fails.
+package checks
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response is a completed response as checks and metrics see it. Body is
+// the full response body; checks must not modify it
+type Response struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Protocol   string
+	Header     http.Header
+	Body       []byte
+
+	// Time from sending the request to the last and first response byte
+	Latency         time.Duration
+	TimeToFirstByte time.Duration
+}
+
+// Check validates one response. A non-nil error fails the request with the
+// error as its reason. Check is called from every benchmark worker at once,
+// so it must be safe for concurrent use
+type Check interface {
+	Name() string
+	Check(resp *Response) error
+}
+
+// Metric computes one value over every response of a run, e.g. the share of
+// responses served from a fallback. Calls to a metric are serialized
+type Metric interface {
+	Name() string
+	Observe(resp *Response)
+	Value() float64
+}
+
+// Plugin supplies checks and metrics. Metrics is called once per benchmark
+// run and must return metrics with fresh state
+type Plugin interface {
+	Checks() []Check
+	Metrics() []Metric
+}
@@ -5,8 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -101,6 +103,8 @@ type ApplicationProfiler struct {
 	requestCount        int64
 	cacheHits           int64
 	cacheMisses         int64
+	connsAcquired       int64
+	connsReused         int64
 }
 
 // CachedEntry represents a cache entry with metadata
@@ -230,6 +234,17 @@ func (ap *ApplicationProfiler) executeProfiledRequest(url string) (time.Duration
 		return 0, false, err
 	}
 
+	// Count real pool reuse rather than estimating it
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddInt64(&ap.connsAcquired, 1)
+			if info.Reused {
+				atomic.AddInt64(&ap.connsReused, 1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
 	resp, err := ap.client.Do(req)
 	if err != nil {
 		return 0, false, err
@@ -437,10 +452,16 @@ func (ap *ApplicationProfiler) analyzeHTTPProfile() *HTTPProfile {
 	}
 	ap.timesMutex.Unlock()
 
+	// Share of requests served by an already-open pooled connection
+	poolUsage := float64(0)
+	if acquired := atomic.LoadInt64(&ap.connsAcquired); acquired > 0 {
+		poolUsage = float64(atomic.LoadInt64(&ap.connsReused)) / float64(acquired) * 100
+	}
+
 	return &HTTPProfile{
 		RequestsPerSecond:   rps,
 		AverageLatency:      avgLatency,
-		ConnectionPoolUsage: 82.4,
+		ConnectionPoolUsage: poolUsage,
 		HTTP2Usage:          95.2,
 		CompressionRatio:    67.8,
 		KeepAliveEfficiency: 76.9,
@@ -37,6 +37,26 @@ type BenchmarkSettings struct {
 	CustomHeaders map[string]string `yaml:"custom_headers,omitempty"`
 	Body          string            `yaml:"body,omitempty"`
 	Cache         *CacheConfig      `yaml:"cache,omitempty"`
+	Script        *ScriptConfig     `yaml:"script,omitempty"`
+	Data          *DataConfig       `yaml:"data,omitempty"`
+	Seed          int64             `yaml:"seed,omitempty"`
+}
+
+// DataConfig represents a CSV or JSONL file whose rows parameterize
+// requests; File is relative to the suite file
+type DataConfig struct {
+	File    string `yaml:"file"`
+	Shuffle bool   `yaml:"shuffle"`
+	Seed    int64  `yaml:"seed"`
+}
+
+// ScriptConfig represents a JavaScript request script; File is relative
+// to the suite file
+type ScriptConfig struct {
+	File    string            `yaml:"file"`
+	Source  string            `yaml:"source"`
+	Vars    map[string]string `yaml:"vars,omitempty"`
+	Timeout Duration          `yaml:"timeout"`
 }
 
 // CacheConfig represents cache configuration
@@ -68,6 +68,12 @@ type ProductionMonitoringConfig struct {
 	TraceSampleRate     float64       `yaml:"trace_sample_rate"`
 	TraceRetention      time.Duration `yaml:"trace_retention"`
 
+	// Tail-based sampling: spans are buffered for TraceDecisionWait and whole
+	// traces kept if slower than TraceSlowThreshold or containing an error
+	TraceDecisionWait   time.Duration `yaml:"trace_decision_wait"`
+	TraceSlowThreshold  time.Duration `yaml:"trace_slow_threshold"`
+	TraceMaxPending     int           `yaml:"trace_max_pending"`
+
 	// Logging
 	LogLevel            string        `yaml:"log_level"`
 	LogAggregation      bool          `yaml:"log_aggregation"`
@@ -345,6 +351,10 @@ type TraceCollector struct {
 	// Completed traces
 	completedTraces     []*Trace
 
+	// Finished spans awaiting a tail sampling decision, by trace ID
+	pendingTraces       map[string]*pendingTrace
+	tailStats           TailSamplingStats
+
 	// Configuration
 	config              *TracingConfig
 	sampleRate          float64
@@ -442,8 +452,11 @@ func NewProductionMonitor(config *ProductionMonitoringConfig) *ProductionMonitor
 
 	if config.TracingEnabled {
 		pm.traceCollector = NewTraceCollector(&TracingConfig{
-			SampleRate: config.TraceSampleRate,
-			Retention:  config.TraceRetention,
+			SampleRate:    config.TraceSampleRate,
+			Retention:     config.TraceRetention,
+			DecisionWait:  config.TraceDecisionWait,
+			SlowThreshold: config.TraceSlowThreshold,
+			MaxPending:    config.TraceMaxPending,
 		})
 	}
 
@@ -487,6 +500,11 @@ func (pm *ProductionMonitor) Start() error {
 	pm.wg.Add(1)
 	go pm.healthCheckLoop()
 
+	if pm.traceCollector != nil && pm.config.TraceDecisionWait > 0 {
+		pm.wg.Add(1)
+		go pm.traceSamplingLoop()
+	}
+
 	// Start HTTP server
 	pm.wg.Add(1)
 	go func() {
@@ -544,6 +562,7 @@ func (pm *ProductionMonitor) setupHTTPRoutes() {
 	if pm.config.TracingEnabled {
 		pm.mux.HandleFunc("/traces", pm.handleTraces)
 		pm.mux.HandleFunc("/traces/active", pm.handleActiveTraces)
+		pm.mux.HandleFunc("/traces/sampling", pm.handleTraceSampling)
 	}
 
 	// Debug endpoints
@@ -605,6 +624,23 @@ func (pm *ProductionMonitor) alertingLoop() {
 	}
 }
 
+func (pm *ProductionMonitor) traceSamplingLoop() {
+	defer pm.wg.Done()
+
+	// Decide traces whose root span never finished once their wait is over
+	ticker := time.NewTicker(pm.config.TraceDecisionWait)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			pm.traceCollector.FlushPending()
+		case <-pm.shutdownCtx.Done():
+			return
+		}
+	}
+}
+
 // Metrics collection
 func (pm *ProductionMonitor) collectMetrics() {
 	if pm.metricsCollector != nil {
@@ -772,6 +808,18 @@ func (pm *ProductionMonitor) handleActiveTraces(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(traces)
 }
 
+func (pm *ProductionMonitor) handleTraceSampling(w http.ResponseWriter, r *http.Request) {
+	if pm.traceCollector == nil {
+		http.Error(w, "Trace collector not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats := pm.traceCollector.GetTailSamplingStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
 func (pm *ProductionMonitor) handleDebugVars(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -854,6 +902,9 @@ func DefaultProductionMonitoringConfig() *ProductionMonitoringConfig {
 		TracingEnabled:       true,
 		TraceSampleRate:      0.1,
 		TraceRetention:       1 * time.Hour,
+		TraceDecisionWait:    5 * time.Second,
+		TraceSlowThreshold:   1 * time.Second,
+		TraceMaxPending:      10000,
 		LogLevel:             "INFO",
 		LogAggregation:       true,
 		LogRetention:         24 * time.Hour,
@@ -884,6 +935,12 @@ type AlertConfig struct {
 type TracingConfig struct {
 	SampleRate float64
 	Retention  time.Duration
+
+	// Tail-based sampling is enabled when DecisionWait is set; SampleRate
+	// then applies only to traces that are neither slow nor failed
+	DecisionWait  time.Duration
+	SlowThreshold time.Duration
+	MaxPending    int
 }
 
 type LogConfig struct {
@@ -957,6 +1014,7 @@ func NewTraceCollector(config *TracingConfig) *TraceCollector {
 		sampleRate:      config.SampleRate,
 		activeTraces:    make(map[string]*Trace),
 		completedTraces: make([]*Trace, 0),
+		pendingTraces:   make(map[string]*pendingTrace),
 	}
 }
 
@@ -0,0 +1,190 @@
+package extras
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"time"
+)
+
+// TailSamplingStats counts tail sampling decisions
+type TailSamplingStats struct {
+	Pending  int   `json:"pending"`
+	Kept     int64 `json:"kept"`
+	Slow     int64 `json:"slow"`     // Kept for exceeding the slow threshold
+	Failed   int64 `json:"failed"`   // Kept for containing an error or timeout
+	Sampled  int64 `json:"sampled"`  // Kept by the baseline sample rate
+	Dropped  int64 `json:"dropped"`  // Neither slow, failed nor sampled
+	Overflow int64 `json:"overflow"` // Decided early because too many traces were pending
+}
+
+// pendingTrace buffers the finished spans of one trace until it is decided
+type pendingTrace struct {
+	spans     []*Trace
+	firstSeen time.Time
+}
+
+// StartSpan begins a span of traceID, starting a new trace when traceID is
+// empty. The span stays active until FinishSpan
+func (tc *TraceCollector) StartSpan(traceID, parentSpanID, operation string) *Trace {
+	if traceID == "" {
+		traceID = newTraceID(16)
+	}
+	span := &Trace{
+		TraceID:       traceID,
+		SpanID:        newTraceID(8),
+		ParentSpanID:  parentSpanID,
+		OperationName: operation,
+		StartTime:     time.Now(),
+		Tags:          make(map[string]interface{}),
+	}
+
+	tc.mutex.Lock()
+	tc.activeTraces[span.SpanID] = span
+	tc.mutex.Unlock()
+	return span
+}
+
+// FinishSpan ends span with status. Without tail sampling the span is kept
+// with probability SampleRate; otherwise it waits with the rest of its trace
+// until the root span finishes or DecisionWait passes, see FlushPending
+func (tc *TraceCollector) FinishSpan(span *Trace, status TraceStatus) {
+	end := time.Now()
+
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	delete(tc.activeTraces, span.SpanID)
+	span.EndTime = &end
+	span.Duration = end.Sub(span.StartTime)
+	span.Status = status
+
+	if tc.config.DecisionWait <= 0 {
+		if mathrand.Float64() < tc.sampleRate {
+			tc.completedTraces = append(tc.completedTraces, span)
+		}
+		tc.pruneCompleted(end)
+		return
+	}
+
+	pending, ok := tc.pendingTraces[span.TraceID]
+	if !ok {
+		if tc.config.MaxPending > 0 && len(tc.pendingTraces) >= tc.config.MaxPending {
+			tc.decideOldest()
+		}
+		pending = &pendingTrace{firstSeen: end}
+		tc.pendingTraces[span.TraceID] = pending
+	}
+	pending.spans = append(pending.spans, span)
+	// Spans finishing after their root are decided on their own once the
+	// wait passes
+	if span.ParentSpanID == "" {
+		tc.decide(span.TraceID, pending)
+	}
+	tc.pruneCompleted(end)
+}
+
+// FlushPending decides every trace whose decision wait has passed without
+// its root span finishing
+func (tc *TraceCollector) FlushPending() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.flushPending(time.Now())
+}
+
+// flushPending decides traces ready at now; the caller holds tc.mutex
+func (tc *TraceCollector) flushPending(now time.Time) {
+	for traceID, pending := range tc.pendingTraces {
+		if now.Sub(pending.firstSeen) >= tc.config.DecisionWait {
+			tc.decide(traceID, pending)
+		}
+	}
+	tc.pruneCompleted(now)
+}
+
+// decideOldest decides the longest-waiting trace to make room for another;
+// the caller holds tc.mutex
+func (tc *TraceCollector) decideOldest() {
+	var oldestID string
+	var oldest *pendingTrace
+	for traceID, pending := range tc.pendingTraces {
+		if oldest == nil || pending.firstSeen.Before(oldest.firstSeen) {
+			oldestID, oldest = traceID, pending
+		}
+	}
+	if oldest != nil {
+		tc.tailStats.Overflow++
+		tc.decide(oldestID, oldest)
+	}
+}
+
+// decide keeps a trace's spans if it was slow, failed or sampled, and drops
+// it otherwise; the caller holds tc.mutex
+func (tc *TraceCollector) decide(traceID string, pending *pendingTrace) {
+	delete(tc.pendingTraces, traceID)
+
+	var start, end time.Time
+	failed := false
+	for _, span := range pending.spans {
+		if start.IsZero() || span.StartTime.Before(start) {
+			start = span.StartTime
+		}
+		if span.EndTime != nil && span.EndTime.After(end) {
+			end = *span.EndTime
+		}
+		if span.Status != TraceStatusOK {
+			failed = true
+		}
+	}
+
+	switch {
+	case failed:
+		tc.tailStats.Failed++
+	case tc.config.SlowThreshold > 0 && end.Sub(start) >= tc.config.SlowThreshold:
+		tc.tailStats.Slow++
+	case mathrand.Float64() < tc.sampleRate:
+		tc.tailStats.Sampled++
+	default:
+		tc.tailStats.Dropped++
+		return
+	}
+	tc.tailStats.Kept++
+	tc.completedTraces = append(tc.completedTraces, pending.spans...)
+}
+
+// pruneCompleted drops the oldest kept spans that ended longer than
+// Retention ago. Spans are kept in the order they were decided, so it stops
+// at the first one still retained; the caller holds tc.mutex
+func (tc *TraceCollector) pruneCompleted(now time.Time) {
+	if tc.config.Retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-tc.config.Retention)
+	expired := 0
+	for _, span := range tc.completedTraces {
+		if span.EndTime == nil || span.EndTime.After(cutoff) {
+			break
+		}
+		expired++
+	}
+	// Reslice rather than filter in place: GetRecentTraces hands out the slice
+	tc.completedTraces = tc.completedTraces[expired:]
+}
+
+// GetTailSamplingStats returns the tail sampling counters
+func (tc *TraceCollector) GetTailSamplingStats() TailSamplingStats {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+
+	stats := tc.tailStats
+	stats.Pending = len(tc.pendingTraces)
+	return stats
+}
+
+// newTraceID returns n random bytes hex-encoded
+func newTraceID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultSignificanceLevel is the alpha used when a run does not set one
+const DefaultSignificanceLevel = 0.05
+
+// A/B verdicts for TargetComparison.Verdict
+const (
+	VerdictFaster       = "faster"
+	VerdictSlower       = "slower"
+	VerdictNoDifference = "no_difference"
+)
+
+// TargetComparison is the A/B result for one candidate target against the baseline
+type TargetComparison struct {
+	Target           string  `json:"target"`
+	BaselineSamples  int     `json:"baseline_samples"`
+	CandidateSamples int     `json:"candidate_samples"`
+	BaselineMedian   float64 `json:"baseline_median_ms"`
+	CandidateMedian  float64 `json:"candidate_median_ms"`
+	MedianChange     float64 `json:"median_change_percent"`
+	BaselineP95      float64 `json:"baseline_p95_ms"`
+	CandidateP95     float64 `json:"candidate_p95_ms"`
+	BaselineRPS      float64 `json:"baseline_rps"`
+	CandidateRPS     float64 `json:"candidate_rps"`
+	BaselineErrors   float64 `json:"baseline_error_rate"`
+	CandidateErrors  float64 `json:"candidate_error_rate"`
+	UStatistic       float64 `json:"u_statistic"`
+	PValue           float64 `json:"p_value"`
+	Significant      bool    `json:"significant"`
+	Verdict          string  `json:"verdict"`
+
+	// Where the time went, to tell server from transfer differences
+	Phases []PhaseComparison `json:"phases,omitempty"`
+}
+
+// PhaseComparison compares one request phase between baseline and candidate
+type PhaseComparison struct {
+	Phase           string  `json:"phase"`
+	BaselineMedian  float64 `json:"baseline_median_ms"`
+	CandidateMedian float64 `json:"candidate_median_ms"`
+	BaselineP95     float64 `json:"baseline_p95_ms"`
+	CandidateP95    float64 `json:"candidate_p95_ms"`
+}
+
+// ABComparison compares every candidate target with the first (baseline) target
+type ABComparison struct {
+	Baseline   string             `json:"baseline"`
+	Alpha      float64            `json:"alpha"`
+	Candidates []TargetComparison `json:"candidates"`
+}
+
+// targetSamples accumulates per-request latencies for one target across iterations
+type targetSamples struct {
+	latencies []float64
+	phases    map[string][]float64
+	requests  int
+	failed    int
+	rps       float64
+	results   int
+
+	// Connection establishment: requests that opened a new connection, the
+	// DNS+connect+TLS time of each, and the totals the setup share is based on
+	dialed       int
+	setup        []float64
+	setupTotal   float64
+	latencyTotal float64
+}
+
+// add folds one iteration's result into the samples
+func (s *targetSamples) add(result *BenchmarkResult) {
+	for _, m := range result.RawMetrics {
+		if m.Error != "" {
+			continue
+		}
+		latency := float64(m.TotalLatency.Microseconds()) / 1000.0
+		s.latencies = append(s.latencies, latency)
+		s.latencyTotal += latency
+		if m.TCPConnection > 0 {
+			setup := float64((m.DNSLookup + m.TCPConnection + m.TLSHandshake).Microseconds()) / 1000.0
+			s.dialed++
+			s.setup = append(s.setup, setup)
+			s.setupTotal += setup
+		}
+	}
+	if s.phases == nil {
+		s.phases = make(map[string][]float64)
+	}
+	for phase, latencies := range phaseLatencies(result.RawMetrics) {
+		s.phases[phase] = append(s.phases[phase], latencies...)
+	}
+	s.requests += result.SuccessfulReqs + result.FailedReqs
+	s.failed += result.FailedReqs
+	s.rps += result.RequestsPerSecond
+	s.results++
+}
+
+// errorRate returns the fraction of failed requests
+func (s *targetSamples) errorRate() float64 {
+	if s.requests == 0 {
+		return 0
+	}
+	return float64(s.failed) / float64(s.requests)
+}
+
+// meanRPS returns throughput averaged over iterations
+func (s *targetSamples) meanRPS() float64 {
+	if s.results == 0 {
+		return 0
+	}
+	return s.rps / float64(s.results)
+}
+
+// executeABRun benchmarks every target in run.Targets with the same workload.
+// Iterations are interleaved and the target order rotates each round, so
+// drift in the network or upstream affects all targets equally. An A/B run
+// resumed from a checkpoint starts over so every target sees the same rounds
+func (r *BenchmarkRunner) executeABRun(ctx context.Context, runIndex int, run *BenchmarkRun) error {
+	var limiter *RateLimiter
+	if run.Config.RateLimit != nil {
+		limiter = NewRateLimiter(run.Config.RateLimit)
+	}
+	// One spend guard, so its budget caps every target together
+	var spend *SpendGuard
+	if run.Config.SpendGuard != nil {
+		spend = NewSpendGuard(run.Config.SpendGuard)
+	}
+	// One auth provider for every target, so tokens are reused rather than refetched
+	var auth AuthProvider
+	if run.Config.Auth != nil {
+		provider, err := NewAuthProvider(run.Config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to configure auth: %w", err)
+		}
+		auth = provider
+	}
+	newBenchmarker := func(target string, iteration int) *Benchmarker {
+		config := run.Config
+		config.Seed = IterationSeed(run.Config.Seed, iteration)
+		config.TargetURL = target
+		// Per-request samples are needed for the significance test
+		config.IncludeRawMetrics = true
+		benchmarker := NewBenchmarker(config)
+		if limiter != nil {
+			benchmarker.SetRateLimiter(limiter)
+		}
+		if auth != nil {
+			benchmarker.SetAuthProvider(auth)
+		}
+		if spend != nil {
+			benchmarker.SetSpendGuard(spend)
+		}
+		r.watchProgress(benchmarker, runIndex)
+		return benchmarker
+	}
+
+	fmt.Printf("A/B mode: %d targets, baseline %s\n", len(run.Targets), run.Targets[0])
+
+	if run.WarmupIterations > 0 {
+		fmt.Printf("Warmup: Running %d iterations per target...\n", run.WarmupIterations)
+		for i := 0; i < run.WarmupIterations; i++ {
+			for _, target := range run.Targets {
+				_, err := newBenchmarker(target, -(i + 1)).Run(ctx)
+				if ctx.Err() != nil {
+					return fmt.Errorf("warmup interrupted: %w", ctx.Err())
+				}
+				if err != nil {
+					fmt.Printf("Warmup iteration %d for %s failed: %v\n", i+1, target, err)
+				}
+			}
+		}
+		fmt.Printf("Warmup complete\n\n")
+	}
+
+	samples := make(map[string]*targetSamples, len(run.Targets))
+	run.TargetResults = make(map[string][]*BenchmarkResult, len(run.Targets))
+	for _, target := range run.Targets {
+		samples[target] = &targetSamples{}
+	}
+
+	err := r.executeInterleavedRounds(ctx, runIndex, run, run.Targets, run.TargetResults, samples, newBenchmarker)
+
+	// The baseline's results keep the regular summary and baseline comparison
+	// working; an interrupted run is still compared on the rounds it finished
+	run.Results = run.TargetResults[run.Targets[0]]
+	if len(run.Results) == 0 {
+		return err
+	}
+
+	alpha := run.SignificanceLevel
+	if alpha <= 0 {
+		alpha = DefaultSignificanceLevel
+	}
+	run.Comparison = compareTargets(run.Targets, samples, alpha)
+	printABComparison(run.Comparison)
+
+	return err
+}
+
+// executeInterleavedRounds runs one iteration per name each round, rotating
+// the order, and collects results under each name. It stops early if ctx is
+// cancelled
+func (r *BenchmarkRunner) executeInterleavedRounds(ctx context.Context, runIndex int, run *BenchmarkRun, names []string, results map[string][]*BenchmarkResult, samples map[string]*targetSamples, newBenchmarker func(string, int) *Benchmarker) error {
+	for i := 0; i < run.Iterations; i++ {
+		fmt.Printf("Round %d/%d...\n", i+1, run.Iterations)
+
+		for j := range names {
+			target := names[(i+j)%len(names)]
+
+			benchmarker := newBenchmarker(target, i+1)
+			r.streamRequests(benchmarker, run, i+1, target)
+			result, err := benchmarker.Run(ctx)
+			if err != nil {
+				return fmt.Errorf("round %d against %s failed: %w", i+1, target, err)
+			}
+			// Partial rounds would compare targets on unequal workloads
+			if result.Partial {
+				return fmt.Errorf("round %d interrupted: %w", i+1, interruptedBy(ctx, result))
+			}
+
+			samples[target].add(result)
+			if !run.Config.IncludeRawMetrics {
+				result.RawMetrics = nil
+			}
+			results[target] = append(results[target], result)
+			r.publishIteration(run, i+1, target, result)
+
+			fmt.Printf("  %s: Successful: %d | Failed: %d | RPS: %.2f | P95: %.2f ms\n",
+				target, result.SuccessfulReqs, result.FailedReqs,
+				result.RequestsPerSecond, result.LatencyStats.P95)
+		}
+
+		if err := r.writeCheckpoint(runIndex, nil, false); err != nil {
+			fmt.Printf("WARNING: Failed to write checkpoint: %v\n", err)
+		}
+
+		if i < run.Iterations-1 {
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return fmt.Errorf("interrupted after round %d: %w", i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareTargets tests each candidate's latencies against the baseline's
+func compareTargets(targets []string, samples map[string]*targetSamples, alpha float64) *ABComparison {
+	comparison := &ABComparison{Baseline: targets[0], Alpha: alpha}
+	baseline := samples[targets[0]]
+	baseStats := CalculateStats(baseline.latencies)
+
+	for _, target := range targets[1:] {
+		candidate := samples[target]
+		candStats := CalculateStats(candidate.latencies)
+
+		tc := TargetComparison{
+			Target:           target,
+			BaselineSamples:  len(baseline.latencies),
+			CandidateSamples: len(candidate.latencies),
+			BaselineMedian:   baseStats.P50,
+			CandidateMedian:  candStats.P50,
+			BaselineP95:      baseStats.P95,
+			CandidateP95:     candStats.P95,
+			BaselineRPS:      baseline.meanRPS(),
+			CandidateRPS:     candidate.meanRPS(),
+			BaselineErrors:   baseline.errorRate(),
+			CandidateErrors:  candidate.errorRate(),
+			Verdict:          VerdictNoDifference,
+		}
+		if baseStats.P50 > 0 {
+			tc.MedianChange = (candStats.P50 - baseStats.P50) / baseStats.P50 * 100
+		}
+
+		for _, phase := range LatencyPhases {
+			basePhase := CalculateStats(baseline.phases[phase])
+			candPhase := CalculateStats(candidate.phases[phase])
+			if basePhase.Samples == 0 && candPhase.Samples == 0 {
+				continue
+			}
+			tc.Phases = append(tc.Phases, PhaseComparison{
+				Phase:           phase,
+				BaselineMedian:  basePhase.P50,
+				CandidateMedian: candPhase.P50,
+				BaselineP95:     basePhase.P95,
+				CandidateP95:    candPhase.P95,
+			})
+		}
+
+		tc.UStatistic, tc.PValue = MannWhitneyU(baseline.latencies, candidate.latencies)
+		tc.Significant = tc.PValue < alpha
+		if tc.Significant {
+			if candStats.P50 < baseStats.P50 {
+				tc.Verdict = VerdictFaster
+			} else {
+				tc.Verdict = VerdictSlower
+			}
+		}
+
+		comparison.Candidates = append(comparison.Candidates, tc)
+	}
+
+	return comparison
+}
+
+// MannWhitneyU runs a two-sided Mann-Whitney U test on two latency samples,
+// returning U for the first sample and the p-value from the tie-corrected
+// normal approximation. Latency distributions are skewed, so a rank test is
+// used rather than comparing means
+func MannWhitneyU(a, b []float64) (float64, float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type sample struct {
+		value float64
+		first bool
+	}
+	combined := make([]sample, 0, len(a)+len(b))
+	for _, v := range a {
+		combined = append(combined, sample{v, true})
+	}
+	for _, v := range b {
+		combined = append(combined, sample{v, false})
+	}
+	sort.Slice(combined, func(i, j int) bool {
+		return combined[i].value < combined[j].value
+	})
+
+	// Tied values share the average of their ranks
+	var rankSum, tieTerm float64
+	for i := 0; i < len(combined); {
+		j := i
+		for j < len(combined) && combined[j].value == combined[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if combined[k].first {
+				rankSum += rank
+			}
+		}
+		ties := float64(j - i)
+		tieTerm += ties*ties*ties - ties
+		i = j
+	}
+
+	u := rankSum - n1*(n1+1)/2
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return u, 1
+	}
+
+	// Continuity correction toward the mean
+	diff := math.Abs(u-mean) - 0.5
+	if diff < 0 {
+		diff = 0
+	}
+	z := diff / math.Sqrt(variance)
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// printABComparison prints the A/B verdict for every candidate
+func printABComparison(comparison *ABComparison) {
+	fmt.Printf("\n--- A/B Comparison (baseline %s, alpha %.2f) ---\n", comparison.Baseline, comparison.Alpha)
+	for _, tc := range comparison.Candidates {
+		fmt.Printf("%s: median %.2f ms vs %.2f ms (%+.1f%%), p=%.4f -> %s\n",
+			tc.Target, tc.BaselineMedian, tc.CandidateMedian, tc.MedianChange, tc.PValue, tc.Verdict)
+	}
+}
+
+// abComparisonSection renders the A/B comparison as markdown
+func abComparisonSection(comparison *ABComparison) string {
+	section := "### A/B Comparison\n\n"
+	section += fmt.Sprintf("**Baseline:** %s (alpha %.2f, Mann-Whitney U)\n\n", comparison.Baseline, comparison.Alpha)
+	section += "| Target | Median (ms) | Change | P95 (ms) | RPS | Error Rate | p-value | Verdict |\n"
+	section += "|--------|-------------|--------|----------|-----|------------|---------|---------|\n"
+
+	for _, tc := range comparison.Candidates {
+		section += fmt.Sprintf("| %s | %.2f → %.2f | %+.1f%% | %.2f → %.2f | %.2f → %.2f | %.2f%% → %.2f%% | %.4f | %s |\n",
+			tc.Target,
+			tc.BaselineMedian, tc.CandidateMedian, tc.MedianChange,
+			tc.BaselineP95, tc.CandidateP95,
+			tc.BaselineRPS, tc.CandidateRPS,
+			tc.BaselineErrors*100, tc.CandidateErrors*100,
+			tc.PValue, tc.Verdict)
+	}
+	section += "\n"
+
+	for _, tc := range comparison.Candidates {
+		if len(tc.Phases) == 0 {
+			continue
+		}
+		section += fmt.Sprintf("**Phase medians, %s:**\n\n", tc.Target)
+		section += "| Phase | Baseline (ms) | Candidate (ms) | P95 (ms) |\n"
+		section += "|-------|---------------|----------------|----------|\n"
+		for _, pc := range tc.Phases {
+			section += fmt.Sprintf("| %s | %.2f | %.2f | %.2f → %.2f |\n",
+				PhaseLabel(pc.Phase), pc.BaselineMedian, pc.CandidateMedian, pc.BaselineP95, pc.CandidateP95)
+		}
+		section += "\n"
+	}
+
+	return section
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMannWhitneyU tests the rank test on identical and clearly shifted samples
+func TestMannWhitneyU(t *testing.T) {
+	a := []float64{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+
+	if _, p := MannWhitneyU(a, a); p < 0.9 {
+		t.Errorf("Identical samples should not differ, got p=%.4f", p)
+	}
+
+	shifted := make([]float64, len(a))
+	for i, v := range a {
+		shifted[i] = v + 20
+	}
+	u, p := MannWhitneyU(a, shifted)
+	if u != 0 {
+		t.Errorf("Expected U=0 when every baseline value is smaller, got %.1f", u)
+	}
+	if p > 0.001 {
+		t.Errorf("Expected a significant difference, got p=%.4f", p)
+	}
+
+	if _, p := MannWhitneyU(nil, a); p != 1 {
+		t.Errorf("Empty samples should give p=1, got %.4f", p)
+	}
+}
+
+// TestABRun tests that an A/B run benchmarks every target and flags the slower one
+func TestABRun(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	suite := &BenchmarkSuite{Name: "ab_test", OutputDir: t.TempDir()}
+	runner := NewBenchmarkRunner(suite)
+	run := &BenchmarkRun{
+		Name:       "ab",
+		Config:     BenchmarkConfig{TotalRequests: 20, Concurrency: 4, KeepAlive: true},
+		Iterations: 2,
+		Targets:    []string{fast.URL, slow.URL},
+	}
+
+	if err := runner.executeRun(context.Background(), 0, run); err != nil {
+		t.Fatalf("A/B run failed: %v", err)
+	}
+
+	if len(run.TargetResults[fast.URL]) != 2 || len(run.TargetResults[slow.URL]) != 2 {
+		t.Fatalf("Expected 2 results per target, got %d and %d",
+			len(run.TargetResults[fast.URL]), len(run.TargetResults[slow.URL]))
+	}
+	if run.TargetResults[fast.URL][0].RawMetrics != nil {
+		t.Error("Raw metrics should be dropped when not requested")
+	}
+
+	if run.Comparison == nil || len(run.Comparison.Candidates) != 1 {
+		t.Fatalf("Expected one candidate comparison, got %+v", run.Comparison)
+	}
+	tc := run.Comparison.Candidates[0]
+	if tc.CandidateSamples != 40 || !tc.Significant || tc.Verdict != VerdictSlower {
+		t.Errorf("Expected a significant slowdown over 40 samples, got %+v", tc)
+	}
+}
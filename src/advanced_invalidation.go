@@ -56,6 +56,7 @@ type AdvancedInvalidationManager struct {
 	versionManager  *VersionManager
 	config          *InvalidationConfig
 	metrics         *InvalidationMetrics
+	metricsMu       sync.RWMutex // Guards metrics, so GetMetrics copies no lock
 	mu              sync.RWMutex
 }
 
@@ -81,7 +82,6 @@ type InvalidationMetrics struct {
 	VersionInvalidations    int64
 	PatternInvalidations    int64
 	InvalidationLatency     time.Duration
-	mu                      sync.RWMutex
 }
 
 // TaggedCacheIndex maintains tag-to-key mappings for efficient invalidation
@@ -241,18 +241,18 @@ func (aim *AdvancedInvalidationManager) ShouldInvalidate(entry *CacheElement, me
 
 	start := time.Now()
 	defer func() {
-		aim.metrics.mu.Lock()
+		aim.metricsMu.Lock()
 		aim.metrics.InvalidationLatency = time.Since(start)
-		aim.metrics.mu.Unlock()
+		aim.metricsMu.Unlock()
 	}()
 
 	// Execute strategies in priority order
 	for _, strategy := range aim.strategies {
 		if strategy.ShouldInvalidate(entry, metadata) {
-			aim.metrics.mu.Lock()
+			aim.metricsMu.Lock()
 			aim.metrics.StrategyExecutions[strategy.GetName()]++
 			aim.metrics.TotalInvalidations++
-			aim.metrics.mu.Unlock()
+			aim.metricsMu.Unlock()
 			return true
 		}
 	}
@@ -274,9 +274,9 @@ func (aim *AdvancedInvalidationManager) InvalidateByTag(tags []string, cache Inv
 		return aim.batchInvalidate(keys, cache)
 	}
 
-	aim.metrics.mu.Lock()
+	aim.metricsMu.Lock()
 	aim.metrics.TagInvalidations++
-	aim.metrics.mu.Unlock()
+	aim.metricsMu.Unlock()
 
 	return nil
 }
@@ -302,9 +302,9 @@ func (aim *AdvancedInvalidationManager) InvalidateByPattern(pattern string, cach
 		return aim.batchInvalidate(keys, cache)
 	}
 
-	aim.metrics.mu.Lock()
+	aim.metricsMu.Lock()
 	aim.metrics.PatternInvalidations++
-	aim.metrics.mu.Unlock()
+	aim.metricsMu.Unlock()
 
 	return nil
 }
@@ -323,9 +323,9 @@ func (aim *AdvancedInvalidationManager) InvalidateByDependency(sourceKey string,
 		return aim.batchInvalidate(dependentKeys, cache)
 	}
 
-	aim.metrics.mu.Lock()
+	aim.metricsMu.Lock()
 	aim.metrics.DependencyInvalidations++
-	aim.metrics.mu.Unlock()
+	aim.metricsMu.Unlock()
 
 	return nil
 }
@@ -344,9 +344,9 @@ func (aim *AdvancedInvalidationManager) InvalidateByVersion(newVersion string, c
 		return aim.batchInvalidate(outdatedKeys, cache)
 	}
 
-	aim.metrics.mu.Lock()
+	aim.metricsMu.Lock()
 	aim.metrics.VersionInvalidations++
-	aim.metrics.mu.Unlock()
+	aim.metricsMu.Unlock()
 
 	return nil
 }
@@ -369,9 +369,9 @@ func (aim *AdvancedInvalidationManager) batchInvalidate(keys []string, cache Inv
 			cache.Delete(key)
 		}
 
-		aim.metrics.mu.Lock()
+		aim.metricsMu.Lock()
 		aim.metrics.BatchInvalidations++
-		aim.metrics.mu.Unlock()
+		aim.metricsMu.Unlock()
 
 		// Small delay between batches to avoid overwhelming the system
 		if i+batchSize < len(keys) {
@@ -711,8 +711,8 @@ func NewInvalidationMetrics() *InvalidationMetrics {
 
 // GetMetrics returns current invalidation metrics
 func (aim *AdvancedInvalidationManager) GetMetrics() InvalidationMetrics {
-	aim.metrics.mu.RLock()
-	defer aim.metrics.mu.RUnlock()
+	aim.metricsMu.RLock()
+	defer aim.metricsMu.RUnlock()
 
 	// Return copy of metrics
 	metrics := InvalidationMetrics{
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// countingCache is an InvalidatableCache that counts deletes
+type countingCache struct {
+	mu      sync.Mutex
+	deletes int
+}
+
+func (c *countingCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deletes++
+}
+
+func (c *countingCache) GetKeysMatchingPattern(pattern *regexp.Regexp) []string {
+	return []string{"users/1"}
+}
+
+// TestInvalidationMetricsConcurrent tests that invalidations and GetMetrics
+// can run from many goroutines without losing counts; run with -race
+func TestInvalidationMetricsConcurrent(t *testing.T) {
+	aim := NewAdvancedInvalidationManager(DefaultInvalidationConfig())
+	cache := &countingCache{}
+
+	const workers, calls = 16, 100
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				if err := aim.InvalidateByPattern("^users/", cache); err != nil {
+					t.Errorf("Failed to invalidate: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				aim.GetMetrics()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if metrics := aim.GetMetrics(); metrics.BatchInvalidations != workers*calls {
+		t.Errorf("Expected %d batches, got %d", workers*calls, metrics.BatchInvalidations)
+	}
+	if cache.deletes != workers*calls {
+		t.Errorf("Expected %d deletes, got %d", workers*calls, cache.deletes)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 )
@@ -19,13 +21,15 @@ const (
 type AlertType string
 
 const (
-	AlertTypeLatency       AlertType = "latency"
-	AlertTypeTTFB          AlertType = "ttfb"
-	AlertTypeCacheHitRatio AlertType = "cache_hit_ratio"
-	AlertTypeCacheMemory   AlertType = "cache_memory"
-	AlertTypeErrorRate     AlertType = "error_rate"
-	AlertTypeThroughput    AlertType = "throughput"
-	AlertTypeCustom        AlertType = "custom"
+	AlertTypeLatency         AlertType = "latency"
+	AlertTypeTTFB            AlertType = "ttfb"
+	AlertTypeCacheHitRatio   AlertType = "cache_hit_ratio"
+	AlertTypeCacheMemory     AlertType = "cache_memory"
+	AlertTypeErrorRate       AlertType = "error_rate"
+	AlertTypeThroughput      AlertType = "throughput"
+	AlertTypeOutlierEjection AlertType = "outlier_ejection"
+	AlertTypeAnomaly         AlertType = "anomaly" // |z-score| of Metric's latest trend sample
+	AlertTypeCustom          AlertType = "custom"
 )
 
 // AlertRule defines a monitoring rule
@@ -38,6 +42,12 @@ type AlertRule struct {
 	Severity    AlertSeverity `json:"severity"`
 	Cooldown    time.Duration `json:"cooldown"`
 	Enabled     bool          `json:"enabled"`
+
+	// Trend metric scored by anomaly rules, e.g. TrendMetricLatencyP95
+	Metric string `json:"metric,omitempty"`
+
+	// Window of snapshots anomaly rules analyze; zero means one hour
+	Window time.Duration `json:"window,omitempty"`
 }
 
 // Alert represents an active or historical alert
@@ -58,6 +68,7 @@ type AlertManager struct {
 	activeAlerts map[string]*Alert
 	alertHistory []Alert
 	cacheMetrics *CacheMetrics
+	collector    *MetricsCollector
 
 	// Callbacks
 	onAlert   func(alert *Alert)
@@ -101,6 +112,14 @@ func (am *AlertManager) AttachCacheMetrics(metrics *CacheMetrics) {
 	am.cacheMetrics = metrics
 }
 
+// AttachCollector attaches the metrics collector whose trend analysis feeds
+// anomaly rules
+func (am *AlertManager) AttachCollector(collector *MetricsCollector) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.collector = collector
+}
+
 // SetOnAlert sets a callback for when alerts are triggered
 func (am *AlertManager) SetOnAlert(callback func(alert *Alert)) {
 	am.mu.Lock()
@@ -117,6 +136,12 @@ func (am *AlertManager) SetOnResolve(callback func(alert *Alert)) {
 
 // Start starts the alert checking loop
 func (am *AlertManager) Start(checkInterval time.Duration) chan struct{} {
+	return am.StartContext(context.Background(), checkInterval)
+}
+
+// StartContext starts the alert checking loop, which ends when the returned
+// channel is closed or ctx is cancelled
+func (am *AlertManager) StartContext(ctx context.Context, checkInterval time.Duration) chan struct{} {
 	stopChan := make(chan struct{})
 
 	go func() {
@@ -129,6 +154,8 @@ func (am *AlertManager) Start(checkInterval time.Duration) chan struct{} {
 				am.CheckAlerts()
 			case <-stopChan:
 				return
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
@@ -141,10 +168,13 @@ func (am *AlertManager) CheckAlerts() {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	if am.cacheMetrics == nil {
+	if am.cacheMetrics == nil && am.collector == nil {
 		return
 	}
 
+	// Trend analysis is shared by every anomaly rule with the same window
+	trends := make(map[time.Duration]map[string]*MetricTrend)
+
 	for _, rule := range am.rules {
 		if !rule.Enabled {
 			continue
@@ -158,7 +188,13 @@ func (am *AlertManager) CheckAlerts() {
 		}
 
 		// Evaluate rule
-		value, shouldAlert := am.evaluateRule(rule)
+		var value float64
+		var shouldAlert bool
+		if rule.Type == AlertTypeAnomaly {
+			value, shouldAlert = am.evaluateAnomalyRule(rule, trends)
+		} else {
+			value, shouldAlert = am.evaluateRule(rule)
+		}
 		if shouldAlert {
 			am.triggerAlert(rule, value)
 		} else {
@@ -169,6 +205,10 @@ func (am *AlertManager) CheckAlerts() {
 
 // evaluateRule evaluates a single rule against current metrics
 func (am *AlertManager) evaluateRule(rule AlertRule) (float64, bool) {
+	if am.cacheMetrics == nil {
+		return 0, false
+	}
+
 	var value float64
 
 	switch rule.Type {
@@ -202,6 +242,32 @@ func (am *AlertManager) evaluateRule(rule AlertRule) (float64, bool) {
 	return value, am.compare(value, rule.Threshold, rule.Comparator)
 }
 
+// evaluateAnomalyRule scores the rule's metric against its trend baseline,
+// caching trend analysis per window in trends
+func (am *AlertManager) evaluateAnomalyRule(rule AlertRule, trends map[time.Duration]map[string]*MetricTrend) (float64, bool) {
+	if am.collector == nil {
+		return 0, false
+	}
+
+	window := rule.Window
+	if window == 0 {
+		window = time.Hour
+	}
+	analysis, ok := trends[window]
+	if !ok {
+		analysis = am.collector.AnalyzeTrends(window)
+		trends[window] = analysis
+	}
+
+	trend, ok := analysis[rule.Metric]
+	if !ok {
+		return 0, false
+	}
+
+	value := math.Abs(trend.ZScore)
+	return value, am.compare(value, rule.Threshold, rule.Comparator)
+}
+
 // compare compares a value against a threshold using the specified comparator
 func (am *AlertManager) compare(value, threshold float64, comparator string) bool {
 	switch comparator {
@@ -256,6 +322,27 @@ func (am *AlertManager) triggerAlert(rule AlertRule, value float64) {
 	fmt.Printf("\n[%s ALERT] %s\n", alert.Severity, alert.Message)
 }
 
+// RaiseAlert triggers an event-driven alert that is not derived from cache metrics
+func (am *AlertManager) RaiseAlert(rule AlertRule, value float64) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if lastTrigger, exists := am.lastTriggered[rule.Name]; exists && time.Since(lastTrigger) < rule.Cooldown {
+		return
+	}
+
+	// Re-raise so the latest event replaces a stale active alert
+	am.resolveAlert(rule.Name)
+	am.triggerAlert(rule, value)
+}
+
+// ResolveAlert resolves an active alert by rule name
+func (am *AlertManager) ResolveAlert(ruleName string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.resolveAlert(ruleName)
+}
+
 // resolveAlert resolves an active alert
 func (am *AlertManager) resolveAlert(ruleName string) {
 	alert, exists := am.activeAlerts[ruleName]
@@ -481,6 +568,11 @@ func (am *AlertManager) formatAlertMessage(rule AlertRule, value float64) string
 	case AlertTypeThroughput:
 		unit = "req/s"
 		formattedValue = fmt.Sprintf("%.2f", value)
+	case AlertTypeOutlierEjection:
+		return fmt.Sprintf("%s: endpoint %d", rule.Description, int(value))
+	case AlertTypeAnomaly:
+		return fmt.Sprintf("%s: %s is %.1f standard deviations from its baseline (threshold: %.1f)",
+			rule.Description, rule.Metric, value, rule.Threshold)
 	default:
 		unit = ""
 		formattedValue = fmt.Sprintf("%.2f", value)
@@ -563,6 +655,28 @@ func DefaultAlertRules() []AlertRule {
 			Cooldown:    5 * time.Minute,
 			Enabled:     true,
 		},
+		{
+			Name:        "latency_anomaly",
+			Description: "P95 latency deviates from its baseline",
+			Type:        AlertTypeAnomaly,
+			Metric:      TrendMetricLatencyP95,
+			Threshold:   3.0, // z-score
+			Comparator:  "gt",
+			Severity:    AlertSeverityWarning,
+			Cooldown:    10 * time.Minute,
+			Enabled:     true,
+		},
+		{
+			Name:        "error_rate_anomaly",
+			Description: "Error rate deviates from its baseline",
+			Type:        AlertTypeAnomaly,
+			Metric:      TrendMetricErrorRate,
+			Threshold:   3.0, // z-score
+			Comparator:  "gt",
+			Severity:    AlertSeverityWarning,
+			Cooldown:    10 * time.Minute,
+			Enabled:     true,
+		},
 		{
 			Name:        "low_throughput",
 			Description: "Throughput below expected rate",
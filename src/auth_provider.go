@@ -0,0 +1,498 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Auth provider types for AuthConfig.Type; see also AuthAWSSigV4
+const (
+	AuthOAuth2ClientCredentials = "oauth2_client_credentials"
+	AuthJWT                     = "jwt"
+)
+
+// AuthConfig configures how outgoing requests obtain and refresh credentials.
+// Secrets may reference environment variables as $NAME or ${NAME}
+type AuthConfig struct {
+	Type string `yaml:"type"`
+
+	// Header receiving the token, as "<Scheme> <token>" or just the token
+	// when Scheme is empty
+	Header string `yaml:"header"`
+	Scheme string `yaml:"scheme"`
+
+	// OAuth2 token endpoint. Required for client credentials; for JWT it
+	// exchanges the signed assertion for an access token (RFC 7523) instead
+	// of sending the JWT itself
+	TokenURL     string   `yaml:"token_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	ClientAuth   string   `yaml:"client_auth"` // "basic" (default) or "body"
+	Scopes       []string `yaml:"scopes"`
+	Audience     string   `yaml:"audience"`
+
+	// JWT signing
+	Algorithm      string                 `yaml:"algorithm"`   // HS256 or RS256
+	SigningKey     string                 `yaml:"signing_key"` // HS256 secret
+	PrivateKeyFile string                 `yaml:"private_key_file"`
+	KeyID          string                 `yaml:"key_id"`
+	Issuer         string                 `yaml:"issuer"`
+	Subject        string                 `yaml:"subject"`
+	Claims         map[string]interface{} `yaml:"claims"`
+
+	// AWS SigV4 signing; region and service are inferred from AWS hostnames
+	// when empty, so one config can sign for several targets
+	Region          string `yaml:"region"`
+	Service         string `yaml:"service"`
+	Profile         string `yaml:"profile"`          // Shared credentials profile; skips environment credentials
+	UnsignedPayload bool   `yaml:"unsigned_payload"` // Sign bodies as UNSIGNED-PAYLOAD instead of hashing them
+
+	// Token lifecycle
+	TokenLifetime time.Duration `yaml:"token_lifetime"` // JWT lifetime, and the assumed lifetime of tokens without expires_in
+	RefreshBefore time.Duration `yaml:"refresh_before"` // Refresh in the background this long before expiry
+	RetryAfter    time.Duration `yaml:"retry_after"`    // After a failed fetch, requests fail fast for this long
+	FetchTimeout  time.Duration `yaml:"fetch_timeout"`
+}
+
+// DefaultAuthConfig returns bearer-token defaults refreshed a minute early
+func DefaultAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		Header:        "Authorization",
+		Scheme:        "Bearer",
+		ClientAuth:    "basic",
+		Algorithm:     "HS256",
+		TokenLifetime: time.Hour,
+		RefreshBefore: time.Minute,
+		RetryAfter:    time.Second,
+		FetchTimeout:  10 * time.Second,
+	}
+}
+
+// AuthStats counts token lifecycle events
+type AuthStats struct {
+	Type          string `json:"type"`
+	Fetches       int64  `json:"fetches"`
+	FetchFailures int64  `json:"fetch_failures"`
+	Invalidations int64  `json:"invalidations"` // Tokens rejected upstream and dropped
+	BlockedWaits  int64  `json:"blocked_waits"` // Requests that waited for a token
+}
+
+// AuthProvider injects credentials into outgoing requests
+type AuthProvider interface {
+	// Authorize sets the credential header on req and returns the token used
+	Authorize(ctx context.Context, req *http.Request) (string, error)
+	// Invalidate drops token after the upstream rejected it, so the next
+	// request fetches a new one. Stale tokens are ignored, so concurrent
+	// workers rejected with the same token trigger a single refresh
+	Invalidate(token string)
+	Stats() AuthStats
+}
+
+// tokenFetcher obtains a new token and its expiry
+type tokenFetcher func(ctx context.Context) (string, time.Time, error)
+
+// tokenRefresh is a fetch in progress that waiters share
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// TokenAuth caches a token and refreshes it with at most one fetch in flight.
+// Requests keep using the current token while a background refresh runs in
+// the RefreshBefore window, and only block once it has expired
+type TokenAuth struct {
+	config AuthConfig
+	fetch  tokenFetcher
+
+	token      string
+	expiry     time.Time
+	refreshing *tokenRefresh
+	lastErr    error
+	failedAt   time.Time
+	stats      AuthStats
+	mu         sync.Mutex
+}
+
+// NewAuthProvider builds the provider described by config
+func NewAuthProvider(config *AuthConfig) (AuthProvider, error) {
+	cfg := *DefaultAuthConfig()
+	mergeAuthConfig(&cfg, config)
+
+	var fetch tokenFetcher
+	switch cfg.Type {
+	case AuthOAuth2ClientCredentials:
+		if cfg.TokenURL == "" || cfg.ClientID == "" {
+			return nil, errors.New("oauth2 client credentials require token_url and client_id")
+		}
+		fetch = clientCredentialsFetcher(&cfg)
+	case AuthJWT:
+		signer, err := newJWTSigner(&cfg)
+		if err != nil {
+			return nil, err
+		}
+		fetch = jwtFetcher(&cfg, signer)
+	case AuthAWSSigV4:
+		return NewSigV4Auth(&cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown auth type %q: use %s, %s or %s", cfg.Type, AuthOAuth2ClientCredentials, AuthJWT, AuthAWSSigV4)
+	}
+
+	return &TokenAuth{
+		config: cfg,
+		fetch:  fetch,
+		stats:  AuthStats{Type: cfg.Type},
+	}, nil
+}
+
+// mergeAuthConfig overlays the set fields of config onto defaults and expands
+// environment references in secrets
+func mergeAuthConfig(defaults, config *AuthConfig) {
+	merged := *config
+	if merged.Header == "" {
+		merged.Header = defaults.Header
+	}
+	if merged.Scheme == "" && config.Header == "" {
+		merged.Scheme = defaults.Scheme
+	}
+	if merged.ClientAuth == "" {
+		merged.ClientAuth = defaults.ClientAuth
+	}
+	if merged.Algorithm == "" {
+		merged.Algorithm = defaults.Algorithm
+	}
+	if merged.TokenLifetime <= 0 {
+		merged.TokenLifetime = defaults.TokenLifetime
+	}
+	if merged.RefreshBefore <= 0 {
+		merged.RefreshBefore = defaults.RefreshBefore
+	}
+	if merged.RetryAfter <= 0 {
+		merged.RetryAfter = defaults.RetryAfter
+	}
+	if merged.FetchTimeout <= 0 {
+		merged.FetchTimeout = defaults.FetchTimeout
+	}
+
+	merged.ClientID = os.ExpandEnv(merged.ClientID)
+	merged.ClientSecret = os.ExpandEnv(merged.ClientSecret)
+	merged.SigningKey = os.ExpandEnv(merged.SigningKey)
+	*defaults = merged
+}
+
+// Authorize sets the credential header on req
+func (a *TokenAuth) Authorize(ctx context.Context, req *http.Request) (string, error) {
+	token, err := a.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	value := token
+	if a.config.Scheme != "" {
+		value = a.config.Scheme + " " + token
+	}
+	req.Header.Set(a.config.Header, value)
+	return token, nil
+}
+
+// Token returns a valid token, fetching one if needed
+func (a *TokenAuth) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	now := time.Now()
+
+	if a.token != "" && now.Before(a.expiry) {
+		if !now.Before(a.expiry.Add(-a.config.RefreshBefore)) && a.refreshing == nil {
+			a.startRefresh()
+		}
+		token := a.token
+		a.mu.Unlock()
+		return token, nil
+	}
+
+	// Fail fast rather than hammering a token endpoint that is down
+	if a.refreshing == nil && a.lastErr != nil && now.Sub(a.failedAt) < a.config.RetryAfter {
+		err := a.lastErr
+		a.mu.Unlock()
+		return "", err
+	}
+
+	refresh := a.refreshing
+	if refresh == nil {
+		refresh = a.startRefresh()
+	}
+	a.stats.BlockedWaits++
+	a.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if refresh.err != nil {
+		return "", refresh.err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token, nil
+}
+
+// startRefresh fetches a token in the background; callers must hold a.mu
+func (a *TokenAuth) startRefresh() *tokenRefresh {
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	a.refreshing = refresh
+	a.stats.Fetches++
+
+	go func() {
+		// Detached from any one request so a cancelled waiter cannot fail
+		// the fetch for the others
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.FetchTimeout)
+		token, expiry, err := a.fetch(ctx)
+		cancel()
+
+		a.mu.Lock()
+		if err != nil {
+			refresh.err = fmt.Errorf("auth token fetch failed: %w", err)
+			a.lastErr = refresh.err
+			a.failedAt = time.Now()
+			a.stats.FetchFailures++
+		} else {
+			a.token, a.expiry = token, expiry
+			a.lastErr = nil
+		}
+		a.refreshing = nil
+		a.mu.Unlock()
+		close(refresh.done)
+	}()
+	return refresh
+}
+
+// Invalidate drops token if it is still the current one
+func (a *TokenAuth) Invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if token != "" && token == a.token {
+		a.token = ""
+		a.expiry = time.Time{}
+		a.stats.Invalidations++
+	}
+}
+
+// Stats returns the provider's counters
+func (a *TokenAuth) Stats() AuthStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// tokenResponse is an OAuth2 token endpoint response
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// requestToken posts form to the token endpoint
+func requestToken(ctx context.Context, config *AuthConfig, form url.Values, clientAuth bool) (string, time.Time, error) {
+	if clientAuth && config.ClientAuth == "body" {
+		form.Set("client_id", config.ClientID)
+		form.Set("client_secret", config.ClientSecret)
+	}
+	if len(config.Scopes) > 0 {
+		form.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	if config.Audience != "" {
+		form.Set("audience", config.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientAuth && config.ClientAuth != "body" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	requested := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("token endpoint returned %d: invalid response: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		if token.Error != "" {
+			return "", time.Time{}, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, token.Error, token.Description)
+		}
+		return "", time.Time{}, fmt.Errorf("token endpoint returned %d without an access token", resp.StatusCode)
+	}
+
+	// Expiry counts from the request so clock time spent in flight is not
+	// credited to the token
+	lifetime := config.TokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	return token.AccessToken, requested.Add(lifetime), nil
+}
+
+// clientCredentialsFetcher implements the OAuth2 client credentials grant
+func clientCredentialsFetcher(config *AuthConfig) tokenFetcher {
+	return func(ctx context.Context) (string, time.Time, error) {
+		return requestToken(ctx, config, url.Values{"grant_type": {"client_credentials"}}, true)
+	}
+}
+
+// jwtSigner signs a JWT signing input
+type jwtSigner func(input []byte) ([]byte, error)
+
+// newJWTSigner loads the key for config.Algorithm
+func newJWTSigner(config *AuthConfig) (jwtSigner, error) {
+	switch config.Algorithm {
+	case "HS256":
+		if config.SigningKey == "" {
+			return nil, errors.New("HS256 JWT requires signing_key")
+		}
+		key := []byte(config.SigningKey)
+		return func(input []byte) ([]byte, error) {
+			mac := hmac.New(sha256.New, key)
+			mac.Write(input)
+			return mac.Sum(nil), nil
+		}, nil
+
+	case "RS256":
+		if config.PrivateKeyFile == "" {
+			return nil, errors.New("RS256 JWT requires private_key_file")
+		}
+		data, err := os.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		key, err := parseRSAPrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return func(input []byte) ([]byte, error) {
+			digest := sha256.Sum256(input)
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q: use HS256 or RS256", config.Algorithm)
+	}
+}
+
+// parseRSAPrivateKey decodes a PKCS#1 or PKCS#8 PEM private key
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// signJWT builds and signs a JWT with the configured claims
+func signJWT(config *AuthConfig, sign jwtSigner, now time.Time) (string, time.Time, error) {
+	expiry := now.Add(config.TokenLifetime)
+
+	header := map[string]string{"alg": config.Algorithm, "typ": "JWT"}
+	if config.KeyID != "" {
+		header["kid"] = config.KeyID
+	}
+
+	jti := make([]byte, 16)
+	rand.Read(jti)
+	claims := map[string]interface{}{
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
+		"jti": hex.EncodeToString(jti),
+	}
+	for key, value := range config.Claims {
+		claims[key] = value
+	}
+	if config.Issuer != "" {
+		claims["iss"] = config.Issuer
+	}
+	if config.Subject != "" {
+		claims["sub"] = config.Subject
+	}
+	if config.Audience != "" {
+		claims["aud"] = config.Audience
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+
+	encoding := base64.RawURLEncoding
+	input := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+	signature, err := sign([]byte(input))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return input + "." + encoding.EncodeToString(signature), expiry, nil
+}
+
+// jwtFetcher signs a fresh JWT, exchanging it for an access token when a
+// token endpoint is configured
+func jwtFetcher(config *AuthConfig, sign jwtSigner) tokenFetcher {
+	return func(ctx context.Context) (string, time.Time, error) {
+		assertion, expiry, err := signJWT(config, sign, time.Now())
+		if err != nil || config.TokenURL == "" {
+			return assertion, expiry, err
+		}
+
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		return requestToken(ctx, config, form, config.ClientID != "")
+	}
+}
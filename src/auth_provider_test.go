@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer serves client credentials tokens numbered by issue order
+func newTokenServer(t *testing.T, expiresIn int, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	var issued atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request"}`)
+			return
+		}
+		time.Sleep(delay)
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+// TestClientCredentialsNoStampede tests that concurrent workers share one fetch
+func TestClientCredentialsNoStampede(t *testing.T) {
+	server, issued := newTokenServer(t, 3600, 50*time.Millisecond)
+	auth, err := NewAuthProvider(&AuthConfig{
+		Type:         AuthOAuth2ClientCredentials,
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProvider failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+			if _, err := auth.Authorize(context.Background(), req); err != nil {
+				t.Errorf("Authorize failed: %v", err)
+				return
+			}
+			if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+				t.Errorf("Expected Bearer token-1, got %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := issued.Load(); n != 1 {
+		t.Errorf("Expected a single token fetch, got %d", n)
+	}
+	if stats := auth.Stats(); stats.Fetches != 1 || stats.FetchFailures != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestTokenRefreshAndInvalidate tests early refresh and dropping rejected tokens
+func TestTokenRefreshAndInvalidate(t *testing.T) {
+	server, issued := newTokenServer(t, 3600, 0)
+	config := &AuthConfig{
+		Type:          AuthOAuth2ClientCredentials,
+		TokenURL:      server.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		Scopes:        []string{"read", "write"},
+		RefreshBefore: 2 * time.Hour, // Every token is already due for refresh
+	}
+	provider, err := NewAuthProvider(config)
+	if err != nil {
+		t.Fatalf("NewAuthProvider failed: %v", err)
+	}
+	auth := provider.(*TokenAuth)
+	ctx := context.Background()
+
+	first, err := auth.Token(ctx)
+	if err != nil || first != "token-1" {
+		t.Fatalf("Expected token-1, got %q (%v)", first, err)
+	}
+
+	// A valid token inside the refresh window is still served while the
+	// background refresh runs
+	if token, _ := auth.Token(ctx); token != "token-1" {
+		t.Errorf("Expected the current token during refresh, got %q", token)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for issued.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if issued.Load() < 2 {
+		t.Fatal("Expected a background refresh inside the refresh window")
+	}
+
+	// Invalidating a stale token leaves the current one alone
+	auth.config.RefreshBefore = 0
+	time.Sleep(20 * time.Millisecond)
+	current, _ := auth.Token(ctx)
+	auth.Invalidate("token-1")
+	if token, _ := auth.Token(ctx); token != current {
+		t.Errorf("Stale invalidation replaced %q with %q", current, token)
+	}
+
+	auth.Invalidate(current)
+	if token, _ := auth.Token(ctx); token == current {
+		t.Errorf("Expected a new token after invalidating %q", current)
+	}
+	if stats := auth.Stats(); stats.Invalidations != 1 {
+		t.Errorf("Expected 1 invalidation, got %d", stats.Invalidations)
+	}
+}
+
+// TestTokenFetchFailureBackoff tests that failed fetches fail fast until RetryAfter
+func TestTokenFetchFailureBackoff(t *testing.T) {
+	server, issued := newTokenServer(t, 3600, 0)
+	auth, err := NewAuthProvider(&AuthConfig{
+		Type:         AuthOAuth2ClientCredentials,
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+		RetryAfter:   time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProvider failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	if _, err := auth.Authorize(context.Background(), req); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("Expected invalid_client error, got %v", err)
+	}
+	if _, err := auth.Authorize(context.Background(), req); err == nil {
+		t.Fatal("Expected the cached failure within RetryAfter")
+	}
+	if stats := auth.Stats(); stats.Fetches != 1 || stats.FetchFailures != 1 || issued.Load() != 0 {
+		t.Errorf("Expected one failed fetch, got %+v", stats)
+	}
+}
+
+// TestJWTSigning tests that HS256 tokens carry the configured claims and a valid signature
+func TestJWTSigning(t *testing.T) {
+	t.Setenv("APILO_TEST_JWT_KEY", "shh")
+	auth, err := NewAuthProvider(&AuthConfig{
+		Type:       AuthJWT,
+		SigningKey: "${APILO_TEST_JWT_KEY}",
+		KeyID:      "k1",
+		Issuer:     "apilo",
+		Audience:   "api.example.com",
+		Claims:     map[string]interface{}{"role": "bench"},
+		Header:     "X-Api-Token",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProvider failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	token, err := auth.Authorize(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if req.Header.Get("X-Api-Token") != token {
+		t.Errorf("Expected the bare token in a custom header, got %q", req.Header.Get("X-Api-Token"))
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a three-part JWT, got %q", token)
+	}
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) != parts[2] {
+		t.Error("JWT signature does not verify")
+	}
+
+	var header map[string]string
+	var claims map[string]interface{}
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(headerJSON, &header)
+	json.Unmarshal(claimsJSON, &claims)
+
+	if header["alg"] != "HS256" || header["kid"] != "k1" {
+		t.Errorf("Unexpected header: %v", header)
+	}
+	if claims["iss"] != "apilo" || claims["aud"] != "api.example.com" || claims["role"] != "bench" {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || int64(exp) <= time.Now().Unix() {
+		t.Errorf("Expected a future exp, got %v", claims["exp"])
+	}
+}
+
+// TestBenchmarkerAuth tests that the benchmarker injects tokens and refreshes after a 401
+func TestBenchmarkerAuth(t *testing.T) {
+	tokenServer, issued := newTokenServer(t, 3600, 0)
+
+	var rejected atomic.Bool
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first token is revoked upstream
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			rejected.Store(true)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{
+		TargetURL:         api.URL,
+		TotalRequests:     10,
+		Concurrency:       1,
+		IncludeRawMetrics: true,
+		Auth: &AuthConfig{
+			Type:         AuthOAuth2ClientCredentials,
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Scopes:       []string{"read", "write"},
+		},
+	})
+	result, err := benchmarker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	unauthorized := 0
+	for _, m := range result.RawMetrics {
+		if m.StatusCode == http.StatusUnauthorized {
+			unauthorized++
+		}
+	}
+	if !rejected.Load() || unauthorized != 1 {
+		t.Errorf("Expected one rejected request, got %d", unauthorized)
+	}
+	if issued.Load() != 2 {
+		t.Errorf("Expected a refetch after the 401, got %d fetches", issued.Load())
+	}
+	if result.Auth == nil || result.Auth.Invalidations != 1 {
+		t.Errorf("Expected auth stats with 1 invalidation, got %+v", result.Auth)
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthAWSSigV4 signs requests with AWS Signature Version 4
+const AuthAWSSigV4 = "aws_sigv4"
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	defaultIMDSEndpoint  = "http://169.254.169.254"
+	maxSignedBodyBytes   = 64 << 20 // Streamed bodies larger than this must use unsigned payloads
+	credentialRefreshLag = 5 * time.Minute
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body
+var emptyPayloadHash = sha256Hex(nil)
+
+// AWSCredentials are the keys requests are signed with
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Source          string
+	Expires         time.Time // Zero for static credentials
+}
+
+// expired reports whether the credentials are due for refresh at now
+func (c *AWSCredentials) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires.Add(-credentialRefreshLag))
+}
+
+// SigV4Auth signs requests with credentials from the default AWS chain:
+// environment variables, then the shared credentials file, then the EC2
+// instance metadata service
+type SigV4Auth struct {
+	config AuthConfig
+
+	creds *AWSCredentials
+	stats AuthStats
+	mu    sync.Mutex // Held while loading credentials, so workers wait for one load
+
+	now func() time.Time
+}
+
+// NewSigV4Auth creates a signer. Region and service are inferred per request
+// from AWS hostnames when the config leaves them empty
+func NewSigV4Auth(config *AuthConfig) *SigV4Auth {
+	return &SigV4Auth{
+		config: *config,
+		stats:  AuthStats{Type: AuthAWSSigV4},
+		now:    time.Now,
+	}
+}
+
+// Authorize signs req and returns the access key ID used
+func (s *SigV4Auth) Authorize(ctx context.Context, req *http.Request) (string, error) {
+	creds, err := s.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	region, service := s.config.Region, s.config.Service
+	if region == "" || service == "" {
+		inferredRegion, inferredService := inferAWSScope(req.URL.Hostname())
+		if region == "" {
+			region = inferredRegion
+		}
+		if service == "" {
+			service = inferredService
+		}
+	}
+	if region == "" || service == "" {
+		return "", fmt.Errorf("cannot infer AWS region and service from %s: set region and service", req.URL.Hostname())
+	}
+
+	payloadHash, err := s.payloadHash(req, service)
+	if err != nil {
+		return "", err
+	}
+
+	signSigV4(req, creds, region, service, payloadHash, s.now())
+	return creds.AccessKeyID, nil
+}
+
+// Invalidate reloads credentials before the next request when the rejected
+// key is still the cached one, e.g. after instance role credentials rotated
+func (s *SigV4Auth) Invalidate(accessKeyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.creds != nil && accessKeyID != "" && s.creds.AccessKeyID == accessKeyID {
+		s.creds = nil
+		s.stats.Invalidations++
+	}
+}
+
+// Stats returns the signer's counters; Fetches counts credential loads
+func (s *SigV4Auth) Stats() AuthStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// credentials returns cached credentials, loading them from the chain when
+// missing or about to expire
+func (s *SigV4Auth) credentials(ctx context.Context) (*AWSCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.creds != nil && !s.creds.expired(s.now()) {
+		return s.creds, nil
+	}
+
+	s.stats.Fetches++
+	creds, err := loadAWSCredentials(ctx, s.config.Profile)
+	if err != nil {
+		s.stats.FetchFailures++
+		// Keep signing with credentials that have not actually expired yet
+		if s.creds != nil && s.now().Before(s.creds.Expires) {
+			return s.creds, nil
+		}
+		return nil, fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	s.creds = creds
+	return creds, nil
+}
+
+// payloadHash returns the hex SHA-256 of the request body without consuming
+// it. Replayable bodies are hashed from a copy; a one-shot stream is buffered
+// and replaced, unless unsigned payloads are allowed
+func (s *SigV4Auth) payloadHash(req *http.Request, service string) (string, error) {
+	if s.config.UnsignedPayload {
+		return sigV4UnsignedPayload, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", fmt.Errorf("failed to read body for signing: %w", err)
+		}
+		defer body.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", fmt.Errorf("failed to hash body for signing: %w", err)
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxSignedBodyBytes+1))
+	req.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read body for signing: %w", err)
+	}
+	if len(data) > maxSignedBodyBytes {
+		return "", fmt.Errorf("streamed %s body exceeds %d bytes: enable unsigned_payload", service, maxSignedBodyBytes)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	return sha256Hex(data), nil
+}
+
+// signSigV4 adds the SigV4 date, token and Authorization headers to req
+func signSigV4(req *http.Request, creds *AWSCredentials, region, service, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	date := amzDate[:8]
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	// S3 requires the payload hash header; other services accept it, but the
+	// SDKs only send it there
+	if service == "s3" || payloadHash == sigV4UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if name == "authorization" || name == "user-agent" || name == "expect" {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req.URL, service),
+		sigV4CanonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sigV4CanonicalURI encodes each path segment; every service except S3
+// expects the already-escaped path to be encoded a second time
+func sigV4CanonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4CanonicalQuery sorts and strictly encodes the query parameters
+func sigV4CanonicalQuery(u *url.URL) string {
+	query := u.Query()
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent-encodes everything except RFC 3986 unreserved characters
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// inferAWSScope derives the region and service from an AWS endpoint, e.g.
+// abc.execute-api.us-east-1.amazonaws.com or xyz.lambda-url.eu-west-1.on.aws
+func inferAWSScope(host string) (region, service string) {
+	labels := strings.Split(strings.ToLower(host), ".")
+	n := len(labels)
+
+	switch {
+	case n >= 4 && labels[n-2] == "on" && labels[n-1] == "aws" && labels[n-4] == "lambda-url":
+		return labels[n-3], "lambda"
+	case n >= 3 && labels[n-2] == "amazonaws" && labels[n-1] == "com":
+		if n >= 4 && labels[n-4] == "execute-api" {
+			return labels[n-3], "execute-api"
+		}
+		if n >= 4 && strings.Contains(labels[n-3], "-") {
+			return labels[n-3], labels[n-4]
+		}
+		// Global endpoints such as iam.amazonaws.com sign for us-east-1
+		return "us-east-1", labels[n-3]
+	}
+	return "", ""
+}
+
+// loadAWSCredentials walks the credential chain. An explicit profile skips
+// the environment variables
+func loadAWSCredentials(ctx context.Context, profile string) (*AWSCredentials, error) {
+	var failures []string
+
+	if profile == "" {
+		if creds := envAWSCredentials(); creds != nil {
+			return creds, nil
+		}
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	explicit := profile != ""
+	if profile == "" {
+		profile = "default"
+	}
+
+	creds, err := sharedAWSCredentials(profile)
+	if err == nil {
+		return creds, nil
+	}
+	failures = append(failures, err.Error())
+	if explicit {
+		return nil, err
+	}
+
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, fmt.Errorf("no credentials found: %s", strings.Join(failures, "; "))
+	}
+	creds, err = imdsAWSCredentials(ctx)
+	if err != nil {
+		failures = append(failures, err.Error())
+		return nil, fmt.Errorf("no credentials found: %s", strings.Join(failures, "; "))
+	}
+	return creds, nil
+}
+
+// envAWSCredentials reads AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+func envAWSCredentials() *AWSCredentials {
+	id := os.Getenv("AWS_ACCESS_KEY_ID")
+	secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return nil
+	}
+	return &AWSCredentials{
+		AccessKeyID:     id,
+		SecretAccessKey: secret,
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Source:          "environment",
+	}
+}
+
+// sharedAWSCredentials reads profile from the shared credentials file
+func sharedAWSCredentials(profile string) (*AWSCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("shared credentials: %w", err)
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("shared credentials: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("shared credentials: %w", err)
+	}
+
+	if values["aws_access_key_id"] == "" || values["aws_secret_access_key"] == "" {
+		return nil, fmt.Errorf("shared credentials: profile %q not found in %s", profile, path)
+	}
+	return &AWSCredentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+		Source:          "profile " + profile,
+	}, nil
+}
+
+// imdsCredentialsResponse is the instance metadata role credentials document
+type imdsCredentialsResponse struct {
+	Code            string    `json:"Code"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// imdsAWSCredentials fetches instance role credentials over IMDSv2
+func imdsAWSCredentials(ctx context.Context) (*AWSCredentials, error) {
+	endpoint := strings.TrimSuffix(os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata: %w", err)
+	}
+	tokenReq.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+	token, err := imdsGet(tokenReq)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata token: %w", err)
+	}
+
+	get := func(path string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token", token)
+		return imdsGet(req)
+	}
+
+	const credentialsPath = "/latest/meta-data/iam/security-credentials/"
+	roles, err := get(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata role: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("instance metadata: no instance role attached")
+	}
+
+	document, err := get(credentialsPath + role)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata credentials: %w", err)
+	}
+	var resp imdsCredentialsResponse
+	if err := json.Unmarshal([]byte(document), &resp); err != nil {
+		return nil, fmt.Errorf("instance metadata credentials: %w", err)
+	}
+	if resp.Code != "Success" || resp.AccessKeyID == "" {
+		return nil, fmt.Errorf("instance metadata credentials: %s", resp.Code)
+	}
+
+	return &AWSCredentials{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.Token,
+		Source:          "instance role " + role,
+		Expires:         resp.Expiration,
+	}, nil
+}
+
+// imdsGet performs a metadata request and returns the body
+func imdsGet(req *http.Request) (string, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return string(body), nil
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sigV4TestCreds are the credentials of the AWS SigV4 test suite
+var sigV4TestCreds = &AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// TestSigV4TestSuite tests signatures against the AWS SigV4 test suite
+func TestSigV4TestSuite(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			signSigV4(req, sigV4TestCreds, "us-east-1", "service", emptyPayloadHash, now)
+
+			expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != expected {
+				t.Errorf("Authorization mismatch:\n got %s\nwant %s", got, expected)
+			}
+			if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+				t.Errorf("Unexpected X-Amz-Date %q", req.Header.Get("X-Amz-Date"))
+			}
+		})
+	}
+}
+
+// TestInferAWSScope tests region and service inference from endpoints
+func TestInferAWSScope(t *testing.T) {
+	tests := []struct {
+		host, region, service string
+	}{
+		{"abc123.execute-api.us-east-1.amazonaws.com", "us-east-1", "execute-api"},
+		{"xyz.lambda-url.eu-west-1.on.aws", "eu-west-1", "lambda"},
+		{"lambda.ap-south-1.amazonaws.com", "ap-south-1", "lambda"},
+		{"iam.amazonaws.com", "us-east-1", "iam"},
+		{"api.example.com", "", ""},
+	}
+	for _, tt := range tests {
+		region, service := inferAWSScope(tt.host)
+		if region != tt.region || service != tt.service {
+			t.Errorf("%s: expected %s/%s, got %s/%s", tt.host, tt.region, tt.service, region, service)
+		}
+	}
+}
+
+// TestSigV4StreamedBody tests that one-shot bodies are hashed without being consumed
+func TestSigV4StreamedBody(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	auth := NewSigV4Auth(&AuthConfig{Type: AuthAWSSigV4})
+	body := `{"hello":"world"}`
+	req, _ := http.NewRequest("POST", "https://abc.execute-api.us-west-2.amazonaws.com/prod/items", io.NopCloser(strings.NewReader(body)))
+
+	key, err := auth.Authorize(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if key != "AKIDEXAMPLE" {
+		t.Errorf("Expected the access key ID, got %q", key)
+	}
+
+	data, _ := io.ReadAll(req.Body)
+	if string(data) != body || req.ContentLength != int64(len(body)) {
+		t.Errorf("Body not preserved after signing: %q (%d)", data, req.ContentLength)
+	}
+	authorization := req.Header.Get("Authorization")
+	if !strings.Contains(authorization, "/us-west-2/execute-api/aws4_request") ||
+		!strings.Contains(authorization, "x-amz-security-token") {
+		t.Errorf("Unexpected Authorization %q", authorization)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Error("Expected the session token header")
+	}
+
+	// Unsigned payloads leave the stream untouched
+	unsigned := NewSigV4Auth(&AuthConfig{Type: AuthAWSSigV4, Region: "us-east-1", Service: "s3", UnsignedPayload: true})
+	req, _ = http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", io.NopCloser(strings.NewReader(body)))
+	if _, err := unsigned.Authorize(context.Background(), req); err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") != sigV4UnsignedPayload || req.GetBody != nil {
+		t.Errorf("Expected an unsigned, unbuffered payload")
+	}
+}
+
+// TestAWSCredentialChain tests the profile file and instance metadata sources
+func TestAWSCredentialChain(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = s1\n\n[bench]\naws_access_key_id=AKIDBENCH\naws_secret_access_key=s2\naws_session_token=tok\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	creds, err := loadAWSCredentials(context.Background(), "")
+	if err != nil || creds.AccessKeyID != "AKIDDEFAULT" {
+		t.Fatalf("Expected the default profile, got %+v (%v)", creds, err)
+	}
+	creds, err = loadAWSCredentials(context.Background(), "bench")
+	if err != nil || creds.AccessKeyID != "AKIDBENCH" || creds.SessionToken != "tok" {
+		t.Fatalf("Expected the bench profile, got %+v (%v)", creds, err)
+	}
+	if _, err := loadAWSCredentials(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error for an explicit missing profile")
+	}
+
+	// Without a credentials file, fall through to IMDSv2
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			fmt.Fprint(w, "imds-token")
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprint(w, "bench-role")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/bench-role":
+			fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"ASIAROLE","SecretAccessKey":"s3","Token":"role-token","Expiration":%q}`, expires.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer imds.Close()
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", imds.URL)
+
+	creds, err = loadAWSCredentials(context.Background(), "")
+	if err != nil {
+		t.Fatalf("IMDS credentials failed: %v", err)
+	}
+	if creds.AccessKeyID != "ASIAROLE" || creds.SessionToken != "role-token" || !creds.Expires.Equal(expires) {
+		t.Errorf("Unexpected IMDS credentials: %+v", creds)
+	}
+	if creds.expired(time.Now()) || !creds.expired(expires.Add(-time.Minute)) {
+		t.Error("Expected refresh only within the lag before expiry")
+	}
+}
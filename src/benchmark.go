@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -13,7 +14,10 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"api-latency-optimizer/checks"
 )
 
 // LatencyMetrics captures detailed timing information for a single request
@@ -25,17 +29,64 @@ type LatencyMetrics struct {
 	ServerProcessing time.Duration `json:"server_processing"`
 	ContentTransfer  time.Duration `json:"content_transfer"`
 
-	// Total times
+	// Total times. TotalLatency is service time: it starts once the request
+	// is sent, after QueueTime spent waiting for rate limit tokens
 	TotalLatency    time.Duration `json:"total_latency"`
 	TimeToFirstByte time.Duration `json:"time_to_first_byte"`
+	QueueTime       time.Duration `json:"queue_time,omitempty"`
 
 	// Response metadata
 	StatusCode   int       `json:"status_code"`
 	ResponseSize int64     `json:"response_size_bytes"`
 	Timestamp    time.Time `json:"timestamp"`
 
-	// Error tracking
-	Error string `json:"error,omitempty"`
+	// Token counts of a generated LLM request body, if any
+	PromptTokens int `json:"prompt_tokens,omitempty"`
+	MaxTokens    int `json:"max_tokens,omitempty"`
+
+	// 103 Early Hints: time to the first hint, its lead over the final
+	// response headers, and how many hinted links the response confirmed
+	EarlyHint          time.Duration `json:"early_hint,omitempty"`
+	EarlyHintLead      time.Duration `json:"early_hint_lead,omitempty"`
+	EarlyHintLinks     int           `json:"early_hint_links,omitempty"`
+	EarlyHintConfirmed int           `json:"early_hint_confirmed,omitempty"`
+
+	// Redirects followed before the final response, and their total time
+	Redirects    []RedirectHop `json:"redirects,omitempty"`
+	RedirectTime time.Duration `json:"redirect_time,omitempty"`
+
+	// Retry-After of a throttling response, and with a throttle policy the
+	// time the request was held back and how many throttled responses it
+	// got before this one; neither is part of TotalLatency
+	RetryAfter   time.Duration `json:"retry_after,omitempty"`
+	ThrottleTime time.Duration `json:"throttle_time,omitempty"`
+	Throttled    int           `json:"throttled,omitempty"`
+
+	// With a spend guard, the request's cost in dollars, or whether it went
+	// to the mock once the budget ran out
+	Cost   float64 `json:"cost,omitempty"`
+	Mocked bool    `json:"mocked,omitempty"`
+
+	// Cache key a request script assigned the request, if any
+	CacheKey string `json:"cache_key,omitempty"`
+
+	// The concurrent worker that sent the request, from 0
+	Worker int `json:"worker"`
+
+	// Protocol and host of the response, and whether the request offered
+	// HTTP/2 so that an HTTP/1.1 response is a fallback
+	Protocol     string `json:"protocol,omitempty"`
+	Host         string `json:"host,omitempty"`
+	HTTP2Offered bool   `json:"http2_offered,omitempty"`
+
+	// Values of the configured CaptureHeaders, and the upstream's own
+	// timings from Server-Timing
+	Headers      map[string]string    `json:"headers,omitempty"`
+	ServerTiming []ServerTimingMetric `json:"server_timing,omitempty"`
+
+	// Error tracking; ErrorType is a ClassifyRequestError class
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"error_type,omitempty"`
 }
 
 // BenchmarkResult contains aggregated statistics from multiple requests
@@ -49,13 +100,19 @@ type BenchmarkResult struct {
 	EndTime       time.Time     `json:"end_time"`
 
 	// Success/failure tracking
-	SuccessfulReqs int `json:"successful_requests"`
-	FailedReqs     int `json:"failed_requests"`
+	SuccessfulReqs int            `json:"successful_requests"`
+	FailedReqs     int            `json:"failed_requests"`
+	ErrorTypes     map[string]int `json:"error_types,omitempty"` // Failed requests by error class
 
 	// Throughput metrics
 	RequestsPerSecond float64 `json:"requests_per_second"`
 	BytesPerSecond    float64 `json:"bytes_per_second"`
 
+	// Bandwidth: every response body received, and the rate of bodies from
+	// error-free, non-4xx/5xx responses
+	TotalBytes  int64   `json:"total_bytes"`
+	GoodputMBps float64 `json:"goodput_mbps"`
+
 	// Latency statistics (all in milliseconds for readability)
 	Latency         LatencyStats `json:"latency"` // Alias for LatencyStats
 	LatencyStats    LatencyStats `json:"latency_stats"`
@@ -63,12 +120,103 @@ type BenchmarkResult struct {
 	ConnectionStats LatencyStats `json:"connection_stats"`
 	TLSStats        LatencyStats `json:"tls_stats"`
 
+	// Time requests waited for the rate limiter before being sent, kept
+	// apart from the on-wire service time in LatencyStats
+	QueueStats LatencyStats `json:"queue_stats"`
+
+	// Remaining request phases; see LatencyPhases
+	DNSStats      LatencyStats `json:"dns_stats"`
+	ServerStats   LatencyStats `json:"server_processing_stats"`
+	DownloadStats LatencyStats `json:"content_transfer_stats"`
+
+	// Payload characteristics of successful responses
+	ResponseSizeStats     SizeStats    `json:"response_size_stats"`
+	ResponseSizeHistogram []SizeBucket `json:"response_size_histogram,omitempty"`
+
 	// Throughput alias
 	Throughput ThroughputStats `json:"throughput"`
 
 	// Success rate
 	SuccessRate float64 `json:"success_rate"`
 
+	// Set when the result covers only some requests: an interim aggregate
+	// of a run in progress, or a run cancelled before every request completed
+	Partial      bool `json:"partial,omitempty"`
+	CanceledReqs int  `json:"canceled_requests,omitempty"`
+
+	// Token counts sent when the run used a generated workload
+	Workload *WorkloadStats `json:"workload,omitempty"`
+
+	// Cache keys assigned when the run used a request script
+	CacheKeys *CacheKeyStats `json:"cache_keys,omitempty"`
+
+	// Redirect chains followed, and requests ending on a redirect
+	Redirects *RedirectStats `json:"redirects,omitempty"`
+
+	// Seed of the run's randomness, if it was seeded
+	Seed int64 `json:"seed,omitempty"`
+
+	// Spending of the run's iterations so far when it had a spend guard
+	Spend *SpendStats `json:"spend,omitempty"`
+
+	// Throttling when the run honored Retry-After
+	Throttle *ThrottleStats `json:"throttle,omitempty"`
+
+	// Rows sent when the run used a data file
+	Data *DataFeedStats `json:"data,omitempty"`
+
+	// Set when the run skipped TLS certificate verification
+	TLSInsecure bool `json:"tls_insecure,omitempty"`
+
+	// Token lifecycle counters when the run used an auth provider
+	Auth *AuthStats `json:"auth,omitempty"`
+
+	// Set when the server sent 103 Early Hints
+	EarlyHints *EarlyHintsStats `json:"early_hints,omitempty"`
+
+	// Protocols responses arrived over, per host, and HTTP/2 fallbacks
+	Protocols *ProtocolStats `json:"protocols,omitempty"`
+
+	// Connections retired when ConnectionRotation was set, and the latency
+	// reconnecting added
+	Rotation *ConnectionRotationStats `json:"rotation,omitempty"`
+
+	// Values of the captured response headers, and Server-Timing metrics by name
+	CapturedHeaders []CapturedHeaderStats `json:"captured_headers,omitempty"`
+	ServerTiming    []ServerTimingStats   `json:"server_timing,omitempty"`
+
+	// Time to first byte split into upstream-reported and network time
+	Upstream *UpstreamAttribution `json:"upstream,omitempty"`
+
+	// Requests by send time and latency
+	Heatmap *LatencyHeatmap `json:"latency_heatmap,omitempty"`
+
+	// Whether the configured concurrency can reach the target rate
+	LittlesLaw *ConcurrencyAnalysis `json:"littles_law,omitempty"`
+
+	// Plugin check outcomes and custom metric values when Plugins were set
+	Checks        []CheckStats   `json:"checks,omitempty"`
+	CustomMetrics []CustomMetric `json:"custom_metrics,omitempty"`
+
+	// Code and tool version the result was measured with
+	Metadata *RunMetadata `json:"metadata,omitempty"`
+
+	// Machine the result was measured on
+	Environment *EnvironmentFingerprint `json:"environment,omitempty"`
+
+	// Latency spread across workers; nil with fewer than two workers
+	WorkerFairness *WorkerFairness `json:"worker_fairness,omitempty"`
+
+	// The load generator's own resource use when Guardrails were set
+	Guardrails *GuardrailStats `json:"guardrails,omitempty"`
+
+	// The load generator's GC and scheduler during the measurement, with
+	// tuning recommendations
+	Runtime *RuntimeProfile `json:"runtime,omitempty"`
+
+	// Unmeasured DNS and connection setup when PrimeConnections was set
+	Prime *PrimeStats `json:"prime,omitempty"`
+
 	// Raw data for detailed analysis
 	RawMetrics []LatencyMetrics `json:"raw_metrics,omitempty"`
 }
@@ -103,10 +251,33 @@ type BenchmarkResult struct {
 
 // Benchmarker orchestrates the benchmarking process
 type Benchmarker struct {
-	config     BenchmarkConfig
-	client     *http.Client
-	metrics    []LatencyMetrics
-	metricsMux sync.Mutex
+	config      BenchmarkConfig
+	client      *http.Client
+	limiter     *RateLimiter
+	workload    *WorkloadGenerator
+	script      *RequestScript
+	data        *DataFeed
+	auth        AuthProvider
+	plugins     []checks.Plugin
+	checks      *responseChecks    // Per Run, set when plugins supply checks or metrics
+	requestURL  string             // TargetURL, or the http:// URL sent over a Unix socket
+	connections *ConnectionTracker // Set when ConnectionRotation is
+	throttle    *throttleGate      // Set when Throttle is
+	spend       *SpendGuard        // Set when SpendGuard is
+	cancelRun   context.CancelFunc // Set during Run; stops it
+	configErr   error
+	metrics     []LatencyMetrics
+	metricsMux  sync.Mutex
+
+	// Workers with an ID at or above this stop; guardrails lower it
+	activeWorkers atomic.Int64
+
+	// Optional interim reporting while Run is in progress
+	progressInterval time.Duration
+	progressHandler  func(*BenchmarkResult)
+
+	// Optional callback for every recorded request
+	requestHandler func(LatencyMetrics)
 }
 
 // NewBenchmarker creates a new benchmarker with the given configuration
@@ -117,6 +288,9 @@ func NewBenchmarker(config BenchmarkConfig) *Benchmarker {
 	}
 	if config.Method == "" {
 		config.Method = "GET"
+		if config.Workload != nil {
+			config.Method = "POST"
+		}
 	}
 	if config.Concurrency <= 0 {
 		config.Concurrency = 1
@@ -128,27 +302,174 @@ func NewBenchmarker(config BenchmarkConfig) *Benchmarker {
 	// Normalize URL: add https:// if no scheme is provided
 	config.TargetURL = normalizeURL(config.TargetURL)
 
+	// unix://SOCKET[:PATH] targets are requested over the socket
+	requestURL := config.TargetURL
+	if socket, socketURL, ok := ParseUnixTarget(config.TargetURL); ok {
+		config.UnixSocket = socket
+		requestURL = socketURL
+	}
+
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = config.Concurrency
+	}
+	if config.IdleConnTimeout <= 0 {
+		config.IdleConnTimeout = 90 * time.Second
+	}
+
 	// Create optimized HTTP client
 	transport := &http.Transport{
-		MaxIdleConns:        config.Concurrency,
-		MaxIdleConnsPerHost: config.Concurrency,
-		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        max(config.Concurrency, config.MaxIdleConnsPerHost),
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
 		DisableKeepAlives:   !config.KeepAlive,
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: false,
 		},
 	}
+	if config.UnixSocket != "" {
+		transport.DialContext = dialUnixSocket(config.UnixSocket)
+	}
 
 	client := &http.Client{
 		Transport: transport,
 		Timeout:   config.Timeout,
 	}
 
-	return &Benchmarker{
-		config:  config,
-		client:  client,
-		metrics: make([]LatencyMetrics, 0, config.TotalRequests),
+	b := &Benchmarker{
+		config:     config,
+		client:     client,
+		requestURL: requestURL,
+		metrics:    make([]LatencyMetrics, 0, config.TotalRequests),
+	}
+	client.CheckRedirect = b.checkRedirect
+	if err := config.Redirects.Validate(); err != nil {
+		b.configErr = fmt.Errorf("invalid redirect policy: %w", err)
+	}
+	// An invalid TLS or h2c config is reported by Run
+	if config.TLS != nil {
+		tlsConfig, err := NewClientTLSConfig(config.TLS)
+		if err != nil {
+			b.configErr = fmt.Errorf("invalid TLS config: %w", err)
+		} else {
+			transport.TLSClientConfig = tlsConfig
+		}
+	}
+	if err := checkHostHeader(config.CustomHeaders); err != nil {
+		b.configErr = err
+	}
+	if override := config.HostOverride; override != nil {
+		if err := override.Validate(config.TargetURL, config.TLS); err != nil {
+			b.configErr = fmt.Errorf("invalid host override: %w", err)
+		} else if name := override.ServerName(); name != "" {
+			transport.TLSClientConfig.ServerName = name
+		}
+	}
+	if config.ColdPath {
+		applyColdPath(transport)
+	}
+	h2c, err := applyForcedProtocol(transport, config.ForceProtocol, config.H2C)
+	if err != nil {
+		b.configErr = err
+		h2c = ""
+	}
+	wrap := func(t *http.Transport) http.RoundTripper { return t }
+	if config.ConnectionRotation.enabled() {
+		b.connections = NewConnectionTracker()
+		b.connections.SetRotation(config.ConnectionRotation)
+		wrap = b.connections.Wrap
+	}
+	roundTripper, err := newH2CRoundTripper(transport, h2c, wrap)
+	if err != nil {
+		b.configErr = err
+	} else if config.TLS != nil {
+		client.Transport = WithClientCertHost(roundTripper)
+	} else {
+		client.Transport = roundTripper
+	}
+	if config.Script != nil && config.Workload != nil {
+		b.configErr = fmt.Errorf("a request script cannot be combined with a workload")
+	}
+	if guard := config.Guardrails; guard != nil && guard.Action != "" && guard.Action != GuardrailThrottle && guard.Action != GuardrailAbort {
+		b.configErr = fmt.Errorf("unknown guardrail action %q", guard.Action)
+	}
+	if config.SpendGuard != nil {
+		if err := config.SpendGuard.Validate(); err != nil {
+			b.configErr = fmt.Errorf("invalid spend guard: %w", err)
+		}
+		b.spend = NewSpendGuard(config.SpendGuard)
+	}
+	if config.Throttle != nil {
+		if err := config.Throttle.Validate(); err != nil {
+			b.configErr = fmt.Errorf("invalid throttle policy: %w", err)
+		}
+		b.throttle = newThrottleGate(config.Throttle)
 	}
+	if config.RateLimit != nil {
+		b.limiter = NewRateLimiter(config.RateLimit)
+	} else if config.TargetRPS > 0 {
+		limits := DefaultRateLimiterConfig()
+		limits.Global = TokenBucketConfig{Rate: config.TargetRPS, Burst: 1}
+		b.limiter = NewRateLimiter(limits)
+	}
+
+	return b
+}
+
+// SetRateLimiter shares a limiter across benchmarkers, e.g. every run in a
+// suite hitting the same provider
+func (b *Benchmarker) SetRateLimiter(limiter *RateLimiter) {
+	b.limiter = limiter
+}
+
+// SetSpendGuard shares a spend guard across benchmarkers, so its budget caps
+// every iteration of a run together
+func (b *Benchmarker) SetSpendGuard(guard *SpendGuard) {
+	b.spend = guard
+}
+
+// RateLimiter returns the outbound limiter, if any
+func (b *Benchmarker) RateLimiter() *RateLimiter {
+	return b.limiter
+}
+
+// SetWorkload shares a request body generator across benchmarkers. Without
+// one, Run loads the generator described by the config's Workload
+func (b *Benchmarker) SetWorkload(workload *WorkloadGenerator) {
+	b.workload = workload
+}
+
+// SetRequestScript shares a request script, and its state, across
+// benchmarkers. Without one, Run compiles the script described by the
+// config's Script
+func (b *Benchmarker) SetRequestScript(script *RequestScript) {
+	b.script = script
+}
+
+// SetAuthProvider shares credentials across benchmarkers so every run reuses
+// one token. Without one, Run creates the provider described by the config's Auth
+func (b *Benchmarker) SetAuthProvider(auth AuthProvider) {
+	b.auth = auth
+}
+
+// SetPlugins sets the plugins supplying response checks and metrics, e.g.
+// ones compiled into the caller. Without them, Run loads the config's Plugins
+func (b *Benchmarker) SetPlugins(plugins []checks.Plugin) {
+	b.plugins = plugins
+}
+
+// SetProgressHandler has Run report an interim aggregate of the requests
+// completed so far every interval. The handler runs on its own goroutine and
+// is never called after Run returns
+func (b *Benchmarker) SetProgressHandler(interval time.Duration, handler func(*BenchmarkResult)) {
+	b.progressInterval = interval
+	b.progressHandler = handler
+}
+
+// SetRequestHandler has Run pass every recorded request to handler. It is
+// called on the worker goroutines, so it must be safe for concurrent use and
+// must not block
+func (b *Benchmarker) SetRequestHandler(handler func(LatencyMetrics)) {
+	b.requestHandler = handler
 }
 
 // normalizeURL ensures the URL has a valid scheme (http:// or https://)
@@ -156,7 +477,7 @@ func normalizeURL(url string) string {
 	url = strings.TrimSpace(url)
 
 	// Check if URL already has a scheme
-	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "unix://") {
 		return url
 	}
 
@@ -164,10 +485,30 @@ func normalizeURL(url string) string {
 	return "https://" + url
 }
 
-// Run executes the benchmark and returns aggregated results
+// Run executes the benchmark and returns aggregated results. If ctx is
+// cancelled, in-flight requests are aborted and the results gathered so far are
+// returned with Partial set; the same happens when a guardrail aborts
 func (b *Benchmarker) Run(ctx context.Context) (*BenchmarkResult, error) {
+	if err := b.prepare(); err != nil {
+		return nil, err
+	}
+
+	// Setup done before startTime is excluded from the measurement
+	var prime *PrimeStats
+	if b.config.PrimeConnections && !b.config.ColdPath {
+		prime = b.primeConnections(ctx)
+	}
+
+	runtimeBefore := takeRuntimeSnapshot()
 	startTime := time.Now()
 
+	// Guardrails may abort the measurement without cancelling the caller
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	b.activeWorkers.Store(int64(b.config.Concurrency))
+	b.cancelRun = cancel
+	stopGuardrails := b.startGuardrails(startTime, cancel)
+
 	// Create work queue
 	requestQueue := make(chan int, b.config.TotalRequests)
 	for i := 0; i < b.config.TotalRequests; i++ {
@@ -181,56 +522,295 @@ func (b *Benchmarker) Run(ctx context.Context) (*BenchmarkResult, error) {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			b.worker(ctx, requestQueue)
+			b.worker(runCtx, workerID, requestQueue)
 		}(i)
 	}
 
 	// Wait for all requests to complete
+	stopProgress := b.startProgress(startTime)
 	wg.Wait()
+	stopProgress()
 	endTime := time.Now()
+	runtimeAfter := takeRuntimeSnapshot()
+	guardrails := stopGuardrails()
 
 	// Calculate statistics
-	result := b.calculateResults(startTime, endTime)
+	result := b.calculateResults(b.metrics, startTime, endTime)
+
+	if runCtx.Err() != nil {
+		result.Partial = true
+		result.CanceledReqs = b.config.TotalRequests - result.SuccessfulReqs - result.FailedReqs
+	}
+	if b.config.TLS != nil && b.config.TLS.InsecureSkipVerify {
+		result.TLSInsecure = true
+	}
+	if b.auth != nil {
+		stats := b.auth.Stats()
+		result.Auth = &stats
+	}
+	result.Prime = prime
+	result.Guardrails = guardrails
+	result.Seed = b.config.Seed
+	if b.spend != nil {
+		result.Spend = b.spend.Stats()
+	}
+	result.Runtime = profileRuntime(runtimeBefore, runtimeAfter, result.LatencyStats.P50)
 
 	return result, nil
 }
 
-// worker processes requests from the queue
-func (b *Benchmarker) worker(ctx context.Context, queue <-chan int) {
-	for requestID := range queue {
-		select {
-		case <-ctx.Done():
+// prepare reports an invalid config and loads what it refers to, the
+// workload, script, data file, auth provider and plugins, without sending
+// requests
+func (b *Benchmarker) prepare() error {
+	if b.configErr != nil {
+		return b.configErr
+	}
+	if b.workload == nil && b.config.Workload != nil {
+		config := *b.config.Workload
+		if config.Seed == 0 {
+			config.Seed = b.config.Seed
+		}
+		workload, err := NewWorkloadGenerator(&config)
+		if err != nil {
+			return fmt.Errorf("failed to load workload: %w", err)
+		}
+		b.workload = workload
+	}
+	if b.script == nil && b.config.Script != nil {
+		config := *b.config.Script
+		if config.Seed == 0 {
+			config.Seed = b.config.Seed
+		}
+		script, err := NewRequestScript(&config)
+		if err != nil {
+			return fmt.Errorf("failed to load request script: %w", err)
+		}
+		b.script = script
+	}
+	if b.data == nil && b.config.Data != nil {
+		config := *b.config.Data
+		if config.Seed == 0 {
+			config.Seed = b.config.Seed
+		}
+		data, err := LoadDataFeed(&config, b.config.Concurrency)
+		if err != nil {
+			return err
+		}
+		templates := []string{b.config.TargetURL, string(b.config.Body)}
+		for _, value := range b.config.CustomHeaders {
+			templates = append(templates, value)
+		}
+		if err := data.Validate(templates...); err != nil {
+			return err
+		}
+		b.data = data
+	}
+	if b.auth == nil && b.config.Auth != nil {
+		auth, err := NewAuthProvider(b.config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to configure auth: %w", err)
+		}
+		b.auth = auth
+	}
+	if b.plugins == nil && len(b.config.Plugins) > 0 {
+		plugins, err := LoadPlugins(b.config.Plugins)
+		if err != nil {
+			return err
+		}
+		b.plugins = plugins
+	}
+	checks, err := newResponseChecks(b.plugins)
+	if err != nil {
+		return fmt.Errorf("invalid plugins: %w", err)
+	}
+	b.checks = checks
+	return nil
+}
+
+// startProgress reports interim results until the returned func is called,
+// which waits for any report in flight to finish
+func (b *Benchmarker) startProgress(startTime time.Time) func() {
+	if b.progressHandler == nil || b.progressInterval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(b.progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				b.progressHandler(b.interimResults(startTime))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// interimResults aggregates the requests completed so far
+func (b *Benchmarker) interimResults(startTime time.Time) *BenchmarkResult {
+	b.metricsMux.Lock()
+	metrics := make([]LatencyMetrics, len(b.metrics))
+	copy(metrics, b.metrics)
+	b.metricsMux.Unlock()
+
+	result := b.calculateResults(metrics, startTime, time.Now())
+	result.Partial = true
+	result.RawMetrics = nil
+	return result
+}
+
+// worker processes requests from the queue until it drains, ctx is cancelled
+// or guardrails throttle it
+func (b *Benchmarker) worker(ctx context.Context, workerID int, queue <-chan int) {
+	for {
+		// Checked before taking a request, so a throttled worker drops none
+		if ctx.Err() != nil || int64(workerID) >= b.activeWorkers.Load() {
 			return
-		default:
-			metric := b.measureRequest(ctx, requestID)
-			b.metricsMux.Lock()
-			b.metrics = append(b.metrics, metric)
-			b.metricsMux.Unlock()
+		}
+		requestID, ok := <-queue
+		if !ok {
+			return
+		}
+
+		metric := b.throttledRequest(ctx, workerID, requestID)
+
+		// A request cut short by cancellation says nothing about the target
+		if metric.Error != "" && ctx.Err() != nil {
+			return
+		}
+
+		b.metricsMux.Lock()
+		b.metrics = append(b.metrics, metric)
+		b.metricsMux.Unlock()
+
+		if b.requestHandler != nil {
+			b.requestHandler(metric)
 		}
 	}
 }
 
 // measureRequest performs a single request and captures all timing metrics
-func (b *Benchmarker) measureRequest(ctx context.Context, requestID int) LatencyMetrics {
+func (b *Benchmarker) measureRequest(ctx context.Context, workerID, requestID int) LatencyMetrics {
 	metric := LatencyMetrics{
 		Timestamp: time.Now(),
+		Worker:    workerID,
+	}
+
+	// The worker's next data row fills the configured templates
+	method, requestURL := b.config.Method, b.requestURL
+	configBody := b.config.Body
+	var row map[string]string
+	if b.data != nil {
+		row = b.data.Next(workerID)
+		requestURL = expandURL(requestURL, row)
+		if len(configBody) > 0 {
+			configBody = []byte(expandPlaceholders(string(configBody), row, nil))
+		}
 	}
 
 	// Timing markers
 	var dnsStart, connectStart, tlsStart, reqStart, firstByteTime time.Time
 	var dnsDone, connectDone, tlsDone time.Time
+	var hints earlyHints
+
+	// Generated bodies take precedence over the configured one
+	var body io.Reader
+	var payload []byte
+	if b.workload != nil {
+		sample := b.workload.Sample(requestID)
+		payload = sample.Body
+		metric.PromptTokens = sample.PromptTokens
+		metric.MaxTokens = sample.MaxTokens
+	} else if len(configBody) > 0 {
+		payload = configBody
+	}
 
-	// Create request with tracing
-	req, err := http.NewRequestWithContext(ctx, b.config.Method, b.config.TargetURL, nil)
+	// A script may replace the method, URL, headers and body
+	var scripted *ScriptedRequest
+	if b.script != nil {
+		var err error
+		scripted, err = b.script.Next(requestID, method, requestURL, row)
+		if err != nil {
+			metric.Error = fmt.Sprintf("script failed: %v", err)
+			metric.ErrorType = ErrorTypeScript
+			return metric
+		}
+		method = scripted.Method
+		if scripted.URL != "" {
+			requestURL = scripted.URL
+		}
+		if scripted.HasBody {
+			payload = scripted.Body
+		}
+		metric.CacheKey = scripted.CacheKey
+	}
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	// The spend guard holds back a request whose worst-case cost could
+	// exceed the budget; until its usage is known the reservation counts
+	if b.spend != nil {
+		prompt, maxTokens := metric.PromptTokens, metric.MaxTokens
+		if b.workload == nil || (scripted != nil && scripted.HasBody) {
+			prompt, maxTokens = requestTokens(payload)
+		}
+		reserved, ok := b.spend.Reserve(prompt, maxTokens)
+		switch {
+		case ok:
+			metric.Cost = reserved
+			defer func() { b.spend.Settle(reserved, metric.Cost) }()
+		case b.spend.config.Action == SpendMock:
+			mocked, err := b.spend.Mock(requestURL)
+			if err != nil {
+				metric.Error = fmt.Sprintf("mock URL failed: %v", err)
+				metric.ErrorType = ErrorTypeOther
+				return metric
+			}
+			requestURL = mocked
+			metric.Mocked = true
+		default:
+			metric.Error = ErrSpendLimit.Error()
+			metric.ErrorType = ErrorTypeSpendLimit
+			if b.cancelRun != nil {
+				b.cancelRun()
+			}
+			return metric
+		}
+	}
+
+	// Create request with tracing, and with the hops of any redirects
+	redirects := &redirectTrace{}
+	req, err := http.NewRequestWithContext(withRedirectTrace(ctx, redirects), method, requestURL, body)
 	if err != nil {
 		metric.Error = fmt.Sprintf("request creation failed: %v", err)
+		metric.ErrorType = ErrorTypeOther
 		return metric
 	}
+	if b.workload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	// Add custom headers
 	for key, value := range b.config.CustomHeaders {
-		req.Header.Set(key, value)
+		req.Header.Set(key, expandPlaceholders(value, row, nil))
+	}
+	if scripted != nil {
+		scripted.applyHeaders(req)
 	}
+	b.config.HostOverride.apply(req)
 
 	// Create trace to capture timing events
 	trace := &httptrace.ClientTrace{
@@ -251,6 +831,7 @@ func (b *Benchmarker) measureRequest(ctx context.Context, requestID int) Latency
 			connectDone = time.Now()
 			if err != nil {
 				metric.Error = fmt.Sprintf("connection failed: %v", err)
+				metric.ErrorType = ClassifyRequestError(err)
 			}
 		},
 		TLSHandshakeStart: func() {
@@ -260,37 +841,89 @@ func (b *Benchmarker) measureRequest(ctx context.Context, requestID int) Latency
 			tlsDone = time.Now()
 			if err != nil {
 				metric.Error = fmt.Sprintf("TLS handshake failed: %v", err)
+				metric.ErrorType = ClassifyRequestError(err)
+				if metric.ErrorType != ErrorTypeTLSCertificate && metric.ErrorType != ErrorTypeTLSPin {
+					metric.ErrorType = ErrorTypeTLSHandshake
+				}
 			}
 		},
 		GotFirstResponseByte: func() {
 			firstByteTime = time.Now()
 		},
+		Got1xxResponse: hints.got1xx,
 	}
 
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 
+	// Wait for rate limit tokens before the clock starts
+	if b.limiter != nil {
+		queued := time.Now()
+		err := b.limiter.Wait(ctx, req)
+		metric.QueueTime = time.Since(queued)
+		if err != nil {
+			metric.Error = fmt.Sprintf("rate limited: %v", err)
+			metric.ErrorType = ClassifyRequestError(err)
+			return metric
+		}
+	}
+
+	// Credentials are fetched outside the timed section too
+	var token string
+	if b.auth != nil {
+		token, err = b.auth.Authorize(ctx, req)
+		if err != nil {
+			metric.Error = fmt.Sprintf("auth failed: %v", err)
+			metric.ErrorType = ErrorTypeAuth
+			return metric
+		}
+	}
+
 	// Execute request
 	reqStart = time.Now()
+	redirects.hopStart = reqStart
 	resp, err := b.client.Do(req)
+	headersDone := time.Now()
 	if err != nil {
 		metric.Error = fmt.Sprintf("request failed: %v", err)
+		// The trace already classified handshake failures more precisely
+		if metric.ErrorType == "" {
+			metric.ErrorType = ClassifyRequestError(err)
+		}
 		metric.TotalLatency = time.Since(reqStart)
 		return metric
 	}
 	defer resp.Body.Close()
 
+	// A rejected token is dropped so later requests fetch a fresh one
+	if resp.StatusCode == http.StatusUnauthorized && b.auth != nil {
+		b.auth.Invalidate(token)
+	}
+
 	// Read response body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	responseComplete := time.Now()
+	if metric.Cost > 0 {
+		metric.Cost = b.spend.responseCost(metric.Cost, resp.StatusCode, bodyBytes)
+	}
 
 	if err != nil {
 		metric.Error = fmt.Sprintf("response read failed: %v", err)
+		metric.ErrorType = ClassifyRequestError(err)
 	}
 
 	// Calculate timing metrics
+	b.recordProtocol(&metric, resp)
+	b.captureResponseHeaders(&metric, resp)
 	metric.StatusCode = resp.StatusCode
 	metric.ResponseSize = int64(len(bodyBytes))
+	b.recordRedirects(&metric, redirects, resp)
 	metric.TotalLatency = responseComplete.Sub(reqStart)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		metric.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), responseComplete)
+	}
+	if b.throttle != nil {
+		b.throttle.observe(resp)
+	}
 
 	if !dnsStart.IsZero() && !dnsDone.IsZero() {
 		metric.DNSLookup = dnsDone.Sub(dnsStart)
@@ -304,17 +937,28 @@ func (b *Benchmarker) measureRequest(ctx context.Context, requestID int) Latency
 		metric.TLSHandshake = tlsDone.Sub(tlsStart)
 	}
 
+	// The first byte of an early hint is not the response's; time the
+	// server from the final headers instead
+	hints.record(&metric, reqStart, headersDone, resp)
+	if metric.EarlyHint > 0 {
+		firstByteTime = headersDone
+	}
+
 	if !firstByteTime.IsZero() {
 		metric.TimeToFirstByte = firstByteTime.Sub(reqStart)
 		metric.ServerProcessing = firstByteTime.Sub(reqStart) - metric.DNSLookup - metric.TCPConnection - metric.TLSHandshake
 		metric.ContentTransfer = responseComplete.Sub(firstByteTime)
 	}
 
+	if b.checks != nil && metric.Error == "" {
+		b.checks.apply(&metric, req, resp, bodyBytes)
+	}
+
 	return metric
 }
 
 // calculateResults aggregates metrics into statistical summary
-func (b *Benchmarker) calculateResults(startTime, endTime time.Time) *BenchmarkResult {
+func (b *Benchmarker) calculateResults(metrics []LatencyMetrics, startTime, endTime time.Time) *BenchmarkResult {
 	result := &BenchmarkResult{
 		TargetURL:     b.config.TargetURL,
 		TotalRequests: b.config.TotalRequests,
@@ -322,37 +966,48 @@ func (b *Benchmarker) calculateResults(startTime, endTime time.Time) *BenchmarkR
 		StartTime:     startTime,
 		EndTime:       endTime,
 		Duration:      endTime.Sub(startTime),
+		Metadata:      CurrentRunMetadata(),
+	}
+	// A Unix socket target is not reached over a network interface
+	if b.config.UnixSocket == "" {
+		result.Environment = CurrentEnvironment(b.config.TargetURL)
+	} else {
+		result.Environment = CurrentEnvironment("")
 	}
 
 	// Separate successful and failed requests
 	var totalLatencies []float64
 	var ttfbLatencies []float64
-	var connectionLatencies []float64
-	var tlsLatencies []float64
-	var totalBytes int64
+	var queueTimes []float64
+	var responseSizes []int64
+	var totalBytes, goodBytes int64
+
+	for _, m := range metrics {
+		result.TotalBytes += m.ResponseSize
 
-	for _, m := range b.metrics {
 		if m.Error != "" {
 			result.FailedReqs++
+			if result.ErrorTypes == nil {
+				result.ErrorTypes = make(map[string]int)
+			}
+			result.ErrorTypes[m.ErrorType]++
 			continue
 		}
 
 		result.SuccessfulReqs++
 		totalBytes += m.ResponseSize
+		responseSizes = append(responseSizes, m.ResponseSize)
+		if m.StatusCode < 400 {
+			goodBytes += m.ResponseSize
+		}
 
 		totalLatencies = append(totalLatencies, float64(m.TotalLatency.Microseconds())/1000.0)
+		queueTimes = append(queueTimes, float64(m.QueueTime.Microseconds())/1000.0)
 
 		if m.TimeToFirstByte > 0 {
 			ttfbLatencies = append(ttfbLatencies, float64(m.TimeToFirstByte.Microseconds())/1000.0)
 		}
 
-		if m.TCPConnection > 0 {
-			connectionLatencies = append(connectionLatencies, float64(m.TCPConnection.Microseconds())/1000.0)
-		}
-
-		if m.TLSHandshake > 0 {
-			tlsLatencies = append(tlsLatencies, float64(m.TLSHandshake.Microseconds())/1000.0)
-		}
 	}
 
 	// Calculate throughput
@@ -360,17 +1015,56 @@ func (b *Benchmarker) calculateResults(startTime, endTime time.Time) *BenchmarkR
 	if durationSecs > 0 {
 		result.RequestsPerSecond = float64(result.SuccessfulReqs) / durationSecs
 		result.BytesPerSecond = float64(totalBytes) / durationSecs
+		result.GoodputMBps = float64(goodBytes) / durationSecs / (1 << 20)
+	}
+
+	result.ResponseSizeStats = CalculateSizeStats(responseSizes)
+	if len(responseSizes) > 0 {
+		result.ResponseSizeHistogram = SizeHistogram(responseSizes)
 	}
 
 	// Calculate statistics for each metric
 	result.LatencyStats = CalculateStats(totalLatencies)
 	result.TTFBStats = CalculateStats(ttfbLatencies)
-	result.ConnectionStats = CalculateStats(connectionLatencies)
-	result.TLSStats = CalculateStats(tlsLatencies)
+	if b.limiter != nil {
+		result.QueueStats = CalculateStats(queueTimes)
+	}
+
+	phases := phaseLatencies(metrics)
+	result.DNSStats = CalculateStats(phases[PhaseDNS])
+	result.ConnectionStats = CalculateStats(phases[PhaseConnect])
+	result.TLSStats = CalculateStats(phases[PhaseTLS])
+	result.ServerStats = CalculateStats(phases[PhaseServer])
+	result.DownloadStats = CalculateStats(phases[PhaseDownload])
+
+	result.Workload = calculateWorkloadStats(metrics)
+	result.CacheKeys = calculateCacheKeyStats(metrics)
+	result.Redirects = calculateRedirectStats(metrics)
+	result.Throttle = b.calculateThrottleStats(metrics, result)
+	if b.data != nil {
+		result.Data = b.data.Stats()
+	}
+	result.EarlyHints = calculateEarlyHintsStats(metrics)
+	result.Protocols = calculateProtocolStats(metrics)
+	if result.Protocols != nil {
+		result.Protocols.Forced = b.config.ForceProtocol
+	}
+	result.CapturedHeaders = calculateCapturedHeaderStats(b.config.CaptureHeaders, metrics)
+	result.ServerTiming = calculateServerTimingStats(metrics)
+	result.Upstream = calculateUpstreamAttribution(metrics)
+	result.Heatmap = BuildLatencyHeatmap(metrics)
+	result.LittlesLaw = calculateConcurrencyAnalysis(targetRate(b.config), b.config.Concurrency, result)
+	if b.connections != nil {
+		result.Rotation = b.connections.RotationStats()
+	}
+	result.WorkerFairness = calculateWorkerFairness(metrics)
+	if b.checks != nil {
+		result.Checks, result.CustomMetrics = b.checks.results()
+	}
 
 	// Include raw metrics if requested
 	if b.config.IncludeRawMetrics {
-		result.RawMetrics = b.metrics
+		result.RawMetrics = metrics
 	}
 
 	return result
@@ -457,9 +1151,23 @@ func (r *BenchmarkResult) PrintSummary() {
 	fmt.Printf("Total Requests: %d\n", r.TotalRequests)
 	fmt.Printf("Successful: %d | Failed: %d\n", r.SuccessfulReqs, r.FailedReqs)
 	fmt.Printf("Concurrency: %d\n", r.Concurrency)
+	if r.Seed != 0 {
+		fmt.Printf("Seed: %d\n", r.Seed)
+	}
+	if r.Metadata != nil {
+		fmt.Printf("Code: %s\n", r.Metadata)
+	}
+	if r.Environment != nil {
+		fmt.Printf("Environment: %s\n", r.Environment)
+	}
 	fmt.Printf("\n--- Throughput ---\n")
 	fmt.Printf("Requests/sec: %.2f\n", r.RequestsPerSecond)
 	fmt.Printf("Bytes/sec: %.2f (%.2f KB/s)\n", r.BytesPerSecond, r.BytesPerSecond/1024)
+	fmt.Printf("Goodput: %.3f MB/s\n", r.GoodputMBps)
+	fmt.Printf("Total Transferred: %s\n", formatBytes(r.TotalBytes))
+
+	fmt.Printf("\n--- Response Size ---\n")
+	printSizeStats(r.ResponseSizeStats, r.ResponseSizeHistogram)
 
 	fmt.Printf("\n--- Total Latency Statistics ---\n")
 	printLatencyStats(r.LatencyStats)
@@ -467,11 +1175,102 @@ func (r *BenchmarkResult) PrintSummary() {
 	fmt.Printf("\n--- Time to First Byte (TTFB) ---\n")
 	printLatencyStats(r.TTFBStats)
 
-	fmt.Printf("\n--- TCP Connection Time ---\n")
-	printLatencyStats(r.ConnectionStats)
+	if r.QueueStats.Samples > 0 {
+		fmt.Printf("\n--- Queue Time (rate limiter, excluded from latency) ---\n")
+		printLatencyStats(r.QueueStats)
+	}
+
+	for _, phase := range LatencyPhases {
+		fmt.Printf("\n--- %s Time ---\n", PhaseLabel(phase))
+		printLatencyStats(r.PhaseStats(phase))
+	}
 
-	fmt.Printf("\n--- TLS Handshake Time ---\n")
-	printLatencyStats(r.TLSStats)
+	if hints := r.EarlyHints; hints != nil {
+		fmt.Printf("\n--- 103 Early Hints ---\n")
+		fmt.Printf("Requests: %d (%.1f%%)\n", hints.Requests, hints.Rate*100)
+		fmt.Printf("Links: %d hinted, %d confirmed by the response\n", hints.Links, hints.ConfirmedLinks)
+		fmt.Printf("Hint P50: %.2f ms | Lead P50: %.2f ms\n", hints.HintTime.P50, hints.LeadTime.P50)
+	}
+
+	if r.Protocols != nil {
+		printProtocolStats(r.Protocols)
+	}
+	if r.Rotation != nil {
+		printRotationStats(r.Rotation)
+	}
+	if r.Redirects != nil {
+		printRedirectStats(r.Redirects)
+	}
+	if r.Throttle != nil {
+		printThrottleStats(r.Throttle)
+	}
+	if r.Spend != nil {
+		printSpendStats(r.Spend)
+	}
+	printResponseHeaderStats(r)
+	if data := r.Data; data != nil {
+		fmt.Printf("\n--- Data File ---\n")
+		fmt.Printf("%s: %d of %d rows used\n", data.File, data.RowsUsed, data.Rows)
+	}
+	if keys := r.CacheKeys; keys != nil {
+		fmt.Printf("\n--- Cache Keys ---\n")
+		fmt.Printf("Requests: %d | Distinct keys: %d | Reuse: %.1f%%\n", keys.Requests, keys.Distinct, keys.Reuse*100)
+	}
+	printCheckStats(r)
+	if r.LittlesLaw != nil {
+		printConcurrencyAnalysis(r.LittlesLaw)
+	}
+
+	if fairness := r.WorkerFairness; fairness != nil {
+		fmt.Printf("\n--- Worker Fairness ---\n")
+		fmt.Printf("Fairness index: %.3f across %d workers (GOMAXPROCS %d)\n",
+			fairness.Index, len(fairness.Workers), fairness.GOMAXPROCS)
+		fmt.Printf("Slowest: worker %d at %.2fx the median worker's P50\n", fairness.SlowestWorker, fairness.Skew)
+		if fairness.Skewed {
+			fmt.Printf("WARNING: latency is skewed across workers; the client machine may be the bottleneck\n")
+		}
+	}
+
+	if guard := r.Guardrails; guard != nil {
+		fmt.Printf("\n--- Load Generator ---\n")
+		fmt.Printf("Peak CPU: %.0f%% of %.1f cores | Peak memory: %s\n", guard.PeakCPU*100, guard.Cores, formatBytes(guard.PeakMemory))
+		for _, reduction := range guard.Reductions {
+			fmt.Printf("Concurrency reduced from %d to %d after %v\n", reduction.From, reduction.To, reduction.At.Round(time.Millisecond))
+		}
+		if guard.Aborted {
+			fmt.Printf("ABORTED: %s\n", guard.Reason)
+		} else if guard.Saturated {
+			fmt.Printf("WARNING: the load generator saturated its CPU; latencies may be inflated\n")
+		}
+	}
+
+	if profile := r.Runtime; profile != nil {
+		fmt.Printf("\n--- Load Generator Runtime ---\n")
+		fmt.Printf("%s\n", formatRuntimeProfile(profile))
+		for _, recommendation := range profile.Recommendations {
+			fmt.Printf("Recommend %s=%s: %s\n", recommendation.Setting, recommendation.Value, recommendation.Reason)
+		}
+	}
+}
+
+func printSizeStats(stats SizeStats, histogram []SizeBucket) {
+	if stats.Samples == 0 {
+		fmt.Printf("No data available\n")
+		return
+	}
+
+	fmt.Printf("Min: %s\n", formatBytes(stats.Min))
+	fmt.Printf("P50: %s\n", formatBytes(int64(stats.P50)))
+	fmt.Printf("P90: %s\n", formatBytes(int64(stats.P90)))
+	fmt.Printf("P95: %s\n", formatBytes(int64(stats.P95)))
+	fmt.Printf("P99: %s\n", formatBytes(int64(stats.P99)))
+	fmt.Printf("Max: %s\n", formatBytes(stats.Max))
+	fmt.Printf("Mean: %s\n", formatBytes(int64(stats.Mean)))
+	for _, bucket := range histogram {
+		if bucket.Count > 0 {
+			fmt.Printf("  %-20s %d\n", bucket.Label(), bucket.Count)
+		}
+	}
 }
 
 func printLatencyStats(stats LatencyStats) {
@@ -3,6 +3,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -94,6 +96,7 @@ type IntegratedBenchmarkEngine struct {
 	optimizer       *IntegratedOptimizer
 	optimizedClient *OptimizedClient
 	baselineClient  *http.Client
+	script          *RequestScript // Shapes optimized requests when configured
 
 	// Configuration
 	config *IntegratedBenchmarkConfig
@@ -130,11 +133,30 @@ func NewIntegratedBenchmarkEngine(config *IntegratedBenchmarkConfig) (*Integrate
 		Timeout: 30 * time.Second,
 	}
 
+	if config.BenchmarkConfig != nil && config.Script != nil {
+		scriptConfig := *config.Script
+		if scriptConfig.Seed == 0 {
+			scriptConfig.Seed = config.Seed
+		}
+		script, err := NewRequestScript(&scriptConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load request script: %w", err)
+		}
+		engine.script = script
+	}
+
 	return engine, nil
 }
 
 // RunIntegratedBenchmark executes a comprehensive benchmark with all optimizations
 func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmark(url string) (*IntegratedBenchmarkResult, error) {
+	return ibe.RunIntegratedBenchmarkContext(context.Background(), url)
+}
+
+// RunIntegratedBenchmarkContext runs the integrated benchmark until it
+// completes or ctx is cancelled; on cancellation only the partial optimized
+// result is returned, without stats or comparison
+func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmarkContext(ctx context.Context, url string) (*IntegratedBenchmarkResult, error) {
 	ibe.mu.Lock()
 	if ibe.running {
 		ibe.mu.Unlock()
@@ -162,7 +184,7 @@ func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmark(url string) (*Integ
 	}
 
 	// Run optimized benchmark
-	result, err := ibe.runOptimizedBenchmark(runConfig)
+	result, err := ibe.runOptimizedBenchmark(ctx, runConfig)
 	if err != nil {
 		return nil, fmt.Errorf("optimized benchmark failed: %w", err)
 	}
@@ -172,6 +194,11 @@ func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmark(url string) (*Integ
 		BenchmarkResult: result,
 	}
 
+	if ctx.Err() != nil {
+		log.Printf("Integrated benchmark interrupted, returning partial results")
+		return integratedResult, nil
+	}
+
 	// Collect optimization statistics
 	if ibe.config.UseOptimizations && ibe.optimizedClient != nil {
 		optStats, err := ibe.collectOptimizationStats()
@@ -184,7 +211,7 @@ func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmark(url string) (*Integ
 
 	// Run comparison if enabled
 	if ibe.config.ComparisonMode {
-		comparison, err := ibe.runComparison(runConfig)
+		comparison, err := ibe.runComparison(ctx, runConfig)
 		if err != nil {
 			log.Printf("Warning: comparison benchmark failed: %v", err)
 		} else {
@@ -202,7 +229,7 @@ func (ibe *IntegratedBenchmarkEngine) RunIntegratedBenchmark(url string) (*Integ
 }
 
 // runOptimizedBenchmark executes benchmark using the optimized client
-func (ibe *IntegratedBenchmarkEngine) runOptimizedBenchmark(config *BenchmarkRunConfig) (*BenchmarkResult, error) {
+func (ibe *IntegratedBenchmarkEngine) runOptimizedBenchmark(ctx context.Context, config *BenchmarkRunConfig) (*BenchmarkResult, error) {
 	if !ibe.config.UseOptimizations || ibe.optimizedClient == nil {
 		// Fall back to standard benchmark
 		return ibe.Run(config)
@@ -222,11 +249,16 @@ func (ibe *IntegratedBenchmarkEngine) runOptimizedBenchmark(config *BenchmarkRun
 	}
 
 	// Execute benchmark with optimized client
-	return ibe.runBenchmarkWithClient(config, ibe.optimizedClient)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return ibe.runBenchmarkWithClient(ctx, config, ibe.optimizedClient)
 }
 
-// runBenchmarkWithClient executes benchmark using a specific client implementation
-func (ibe *IntegratedBenchmarkEngine) runBenchmarkWithClient(config *BenchmarkRunConfig, client interface{}) (*BenchmarkResult, error) {
+// runBenchmarkWithClient executes benchmark using a specific client
+// implementation. Cancelling ctx stops queued requests from starting and
+// aborts those in flight; requests lost that way are not counted as errors
+func (ibe *IntegratedBenchmarkEngine) runBenchmarkWithClient(ctx context.Context, config *BenchmarkRunConfig, client interface{}) (*BenchmarkResult, error) {
 	startTime := time.Now()
 	results := make(chan *LatencyMetrics, config.TotalRequests)
 	errors := make(chan error, config.TotalRequests)
@@ -242,13 +274,21 @@ func (ibe *IntegratedBenchmarkEngine) runBenchmarkWithClient(config *BenchmarkRu
 			defer wg.Done()
 
 			// Acquire semaphore
-			semaphore <- struct{}{}
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-semaphore }()
 
+			if ctx.Err() != nil {
+				return
+			}
+
 			// Execute request based on client type
 			switch c := client.(type) {
 			case *OptimizedClient:
-				ibe.executeOptimizedRequest(c, config.URL, requestID, results, errors)
+				ibe.executeOptimizedRequest(ctx, c, config.URL, requestID, results, errors)
 			case *http.Client:
 				ibe.executeStandardRequest(c, config.URL, requestID, results, errors)
 			default:
@@ -277,39 +317,71 @@ func (ibe *IntegratedBenchmarkEngine) runBenchmarkWithClient(config *BenchmarkRu
 	}
 
 	// Generate benchmark result
-	return ibe.generateBenchmarkResult(config, metrics, errorCount, startTime, time.Now())
+	result, err := ibe.generateBenchmarkResult(config, metrics, errorCount, startTime, time.Now())
+	if ctx.Err() != nil && result != nil {
+		result.Partial = true
+		result.CanceledReqs = config.TotalRequests - len(metrics) - errorCount
+	}
+	return result, err
 }
 
 // executeOptimizedRequest performs a request using the optimized client
-func (ibe *IntegratedBenchmarkEngine) executeOptimizedRequest(client *OptimizedClient, url string, requestID int, results chan<- *LatencyMetrics, errors chan<- error) {
+func (ibe *IntegratedBenchmarkEngine) executeOptimizedRequest(ctx context.Context, client *OptimizedClient, url string, requestID int, results chan<- *LatencyMetrics, errors chan<- error) {
 	start := time.Now()
 
+	// A script may replace the method, URL, headers and body
+	method, target := http.MethodGet, url
+	var body io.Reader
+	var scripted *ScriptedRequest
+	if ibe.script != nil {
+		var err error
+		if scripted, err = ibe.script.Next(requestID, method, target, nil); err != nil {
+			errors <- fmt.Errorf("request %d: script failed: %w", requestID, err)
+			return
+		}
+		method = scripted.Method
+		if scripted.URL != "" {
+			target = scripted.URL
+		}
+		if scripted.HasBody {
+			body = bytes.NewReader(scripted.Body)
+		}
+	}
+
 	// Create HTTP request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
 	if err != nil {
 		errors <- fmt.Errorf("request %d: failed to create request: %w", requestID, err)
 		return
 	}
 
-	// Create optimized request
+	// Create optimized request; a scripted cache key names its cache entry
 	optimizedReq := &OptimizedRequest{
 		Request:       req,
 		UseCache:      ibe.config.EnableCaching,
 		EnableMetrics: ibe.config.EnableMonitoring,
 	}
+	if scripted != nil {
+		scripted.applyHeaders(req)
+		optimizedReq.CacheKey = scripted.CacheKey
+	}
 
 	// Execute request
 	resp, err := client.Do(optimizedReq)
 	if err != nil {
-		errors <- fmt.Errorf("request %d: %w", requestID, err)
+		if ctx.Err() == nil {
+			errors <- fmt.Errorf("request %d: %w", requestID, err)
+		}
 		return
 	}
 	defer resp.Response.Body.Close()
 
 	// Read response body
-	body, err := io.ReadAll(resp.Response.Body)
+	respBody, err := io.ReadAll(resp.Response.Body)
 	if err != nil {
-		errors <- fmt.Errorf("request %d: failed to read body: %w", requestID, err)
+		if ctx.Err() == nil {
+			errors <- fmt.Errorf("request %d: failed to read body: %w", requestID, err)
+		}
 		return
 	}
 
@@ -322,9 +394,12 @@ func (ibe *IntegratedBenchmarkEngine) executeOptimizedRequest(client *OptimizedC
 		TimeToFirstByte:  resp.TTFBLatency,
 		TotalLatency:     resp.TotalLatency,
 		StatusCode:       resp.Response.StatusCode,
-		ResponseSize:     int64(len(body)),
+		ResponseSize:     int64(len(respBody)),
 		Timestamp:        start,
 	}
+	if scripted != nil {
+		metrics.CacheKey = scripted.CacheKey
+	}
 
 	results <- metrics
 }
@@ -342,14 +417,14 @@ func (ibe *IntegratedBenchmarkEngine) executeStandardRequest(client *http.Client
 }
 
 // runComparison executes both optimized and baseline benchmarks for comparison
-func (ibe *IntegratedBenchmarkEngine) runComparison(config *BenchmarkRunConfig) (*ComparisonResult, error) {
+func (ibe *IntegratedBenchmarkEngine) runComparison(ctx context.Context, config *BenchmarkRunConfig) (*ComparisonResult, error) {
 	log.Println("Running comparison benchmark (optimized vs baseline)...")
 
 	// Run optimized benchmark
 	optimizedConfig := *config
 	optimizedConfig.UseOptimizations = true
 
-	optimizedResult, err := ibe.runBenchmarkWithClient(&optimizedConfig, ibe.optimizedClient)
+	optimizedResult, err := ibe.runBenchmarkWithClient(ctx, &optimizedConfig, ibe.optimizedClient)
 	if err != nil {
 		return nil, fmt.Errorf("optimized benchmark failed: %w", err)
 	}
@@ -358,7 +433,7 @@ func (ibe *IntegratedBenchmarkEngine) runComparison(config *BenchmarkRunConfig)
 	baselineConfig := *config
 	baselineConfig.UseOptimizations = false
 
-	baselineResult, err := ibe.runBenchmarkWithClient(&baselineConfig, ibe.baselineClient)
+	baselineResult, err := ibe.runBenchmarkWithClient(ctx, &baselineConfig, ibe.baselineClient)
 	if err != nil {
 		return nil, fmt.Errorf("baseline benchmark failed: %w", err)
 	}
@@ -12,6 +12,7 @@ import (
 // CacheEntry represents a single cached item with metadata
 type CacheEntry struct {
 	Key          string            // Cache key (typically URL + params hash)
+	Check        uint64            // Second hash of the request behind Key, to detect key collisions
 	Value        []byte            // Cached response body
 	StatusCode   int               // HTTP status code
 	Headers      map[string]string // Important response headers
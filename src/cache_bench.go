@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheBenchConfig sizes the cache microbenchmarks
+type CacheBenchConfig struct {
+	Entries    int   // Distinct keys, all of which fit in each cache
+	ValueSize  int   // Bytes per cached value
+	Operations int   // Operations per throughput measurement
+	Goroutines []int // Concurrency levels for contention scaling
+}
+
+// DefaultCacheBenchConfig returns 10000 1KB entries and 200000 operations,
+// scaled from 1 to 64 goroutines
+func DefaultCacheBenchConfig() CacheBenchConfig {
+	return CacheBenchConfig{
+		Entries:    10000,
+		ValueSize:  1024,
+		Operations: 200000,
+		Goroutines: []int{1, 4, 16, 64},
+	}
+}
+
+// CacheScalingPoint is mixed read/write throughput at one concurrency level
+type CacheScalingPoint struct {
+	Goroutines int     `json:"goroutines"`
+	OpsPerSec  float64 `json:"ops_per_sec"`
+	Speedup    float64 `json:"speedup"` // Relative to the first level
+}
+
+// CacheBenchResult is the outcome of the microbenchmarks for one cache
+type CacheBenchResult struct {
+	Variant      string              `json:"variant"`
+	SetOpsPerSec float64             `json:"set_ops_per_sec"`
+	GetOpsPerSec float64             `json:"get_ops_per_sec"`
+	Scaling      []CacheScalingPoint `json:"scaling"`
+
+	// Latency of a Set into a full cache, which must evict to make room
+	EvictionP50 time.Duration `json:"eviction_p50"`
+	EvictionP99 time.Duration `json:"eviction_p99"`
+
+	// Memory the cache accounts for against the heap growth it caused;
+	// accuracy is their ratio, below 1 when the cache undercounts
+	ReportedBytes  int64   `json:"reported_bytes"`
+	MeasuredBytes  int64   `json:"measured_bytes"`
+	MemoryAccuracy float64 `json:"memory_accuracy"`
+}
+
+// benchCache is what the microbenchmarks need from a cache implementation
+type benchCache interface {
+	get(key string) bool
+	set(key string, value []byte)
+	memoryUsage() int64
+}
+
+// cacheBenchVariant creates one cache implementation holding capacity
+// entries of valueSize bytes
+type cacheBenchVariant struct {
+	name     string
+	newCache func(capacity, valueSize int) benchCache
+}
+
+// cacheBenchVariants are the implementations compared by RunCacheBench
+var cacheBenchVariants = []cacheBenchVariant{
+	{"lru", func(capacity, valueSize int) benchCache {
+		return lruBenchCache{NewLRUCache(capacity, benchMemoryMB(capacity, valueSize))}
+	}},
+	{"sharded", func(capacity, valueSize int) benchCache {
+		return shardedBenchCache{NewShardedCache(16, capacity, benchMemoryMB(capacity, valueSize))}
+	}},
+	{"memory_bounded", func(capacity, valueSize int) benchCache {
+		// Bounded by memory alone, so size it for capacity entries as it
+		// estimates them, without the background loops
+		config := DefaultMemoryBoundedConfig()
+		config.EnableGCOptimization = false
+		config.EnableMemoryTracker = false
+		cache := NewMemoryBoundedCache(config)
+		cache.maxMemoryBytes = int64(capacity) * int64(valueSize+256)
+		return memoryBoundedBenchCache{cache}
+	}},
+}
+
+// benchMemoryMB returns a memory limit in MB that never binds before the
+// entry capacity does
+func benchMemoryMB(capacity, valueSize int) int64 {
+	return int64(capacity)*int64(valueSize+256)/(1024*1024) + 1
+}
+
+type lruBenchCache struct{ *LRUCache }
+
+func (c lruBenchCache) get(key string) bool {
+	_, found := c.Get(key)
+	return found
+}
+
+func (c lruBenchCache) set(key string, value []byte) {
+	c.Put(key, benchEntry(key, value))
+}
+
+func (c lruBenchCache) memoryUsage() int64 { return c.MemoryUsage() }
+
+type shardedBenchCache struct{ *ShardedCache }
+
+func (c shardedBenchCache) get(key string) bool {
+	_, found := c.Get(key)
+	return found
+}
+
+func (c shardedBenchCache) set(key string, value []byte) {
+	c.Put(key, benchEntry(key, value))
+}
+
+func (c shardedBenchCache) memoryUsage() int64 { return c.MemoryUsage() }
+
+type memoryBoundedBenchCache struct{ *MemoryBoundedCache }
+
+func (c memoryBoundedBenchCache) get(key string) bool {
+	_, found := c.Get(key)
+	return found
+}
+
+func (c memoryBoundedBenchCache) set(key string, value []byte) {
+	c.Set(key, value, time.Hour)
+}
+
+func (c memoryBoundedBenchCache) memoryUsage() int64 {
+	return c.GetMemoryStats().CurrentMemoryBytes
+}
+
+// benchEntry wraps value in an entry sized the way responses are cached
+func benchEntry(key string, value []byte) *CacheEntry {
+	now := time.Now()
+	return &CacheEntry{
+		Key:       key,
+		Value:     value,
+		Size:      int64(len(value)),
+		CreatedAt: now,
+		TTL:       time.Hour,
+		ExpiresAt: now.Add(time.Hour),
+	}
+}
+
+// RunCacheBench measures each cache implementation: single-goroutine Set
+// and Get throughput, mixed 90% read throughput as goroutines are added,
+// the latency of sets that evict, and how closely reported memory usage
+// matches heap growth
+func RunCacheBench(config CacheBenchConfig) []CacheBenchResult {
+	if config.Entries < 2 {
+		config.Entries = 2
+	}
+	config.Operations = max(config.Operations, config.Entries)
+
+	keys := make([]string, 2*config.Entries)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%08d", i)
+	}
+	value := make([]byte, config.ValueSize)
+
+	results := make([]CacheBenchResult, 0, len(cacheBenchVariants))
+	for _, variant := range cacheBenchVariants {
+		result := CacheBenchResult{Variant: variant.name}
+
+		cache := variant.newCache(config.Entries, config.ValueSize)
+		result.SetOpsPerSec = benchThroughput(config.Operations, 1, func(i int) {
+			cache.set(keys[i%config.Entries], value)
+		})
+		result.GetOpsPerSec = benchThroughput(config.Operations, 1, func(i int) {
+			cache.get(keys[i%config.Entries])
+		})
+
+		for _, goroutines := range config.Goroutines {
+			point := CacheScalingPoint{
+				Goroutines: goroutines,
+				OpsPerSec: benchThroughput(config.Operations, goroutines, func(i int) {
+					if i%10 == 0 {
+						cache.set(keys[i%config.Entries], value)
+					} else {
+						cache.get(keys[i%config.Entries])
+					}
+				}),
+			}
+			if len(result.Scaling) > 0 && result.Scaling[0].OpsPerSec > 0 {
+				point.Speedup = point.OpsPerSec / result.Scaling[0].OpsPerSec
+			} else {
+				point.Speedup = 1
+			}
+			result.Scaling = append(result.Scaling, point)
+		}
+
+		result.EvictionP50, result.EvictionP99 = benchEviction(variant, config, keys, value)
+		result.ReportedBytes, result.MeasuredBytes = benchMemory(variant, config, keys)
+		if result.MeasuredBytes > 0 {
+			result.MemoryAccuracy = float64(result.ReportedBytes) / float64(result.MeasuredBytes)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// benchThroughput runs op for indexes 0 to operations-1 split across
+// goroutines and returns operations per second
+func benchThroughput(operations, goroutines int, op func(i int)) float64 {
+	goroutines = max(goroutines, 1)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < operations; i += goroutines {
+				op(i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	return float64(operations) / time.Since(start).Seconds()
+}
+
+// benchEviction fills a cache holding half the entries, then times sets of
+// keys it has not seen, each of which evicts
+func benchEviction(variant cacheBenchVariant, config CacheBenchConfig, keys []string, value []byte) (p50, p99 time.Duration) {
+	capacity := config.Entries / 2
+	cache := variant.newCache(capacity, config.ValueSize)
+	for _, key := range keys[:capacity] {
+		cache.set(key, value)
+	}
+
+	latencies := make([]float64, 0, len(keys)-capacity)
+	for _, key := range keys[capacity:] {
+		start := time.Now()
+		cache.set(key, value)
+		latencies = append(latencies, float64(time.Since(start)))
+	}
+	sort.Float64s(latencies)
+	return time.Duration(percentile(latencies, 50)), time.Duration(percentile(latencies, 99))
+}
+
+// benchMemory fills a fresh cache with freshly allocated values and returns
+// the memory it reports and the live heap growth after garbage collection
+func benchMemory(variant cacheBenchVariant, config CacheBenchConfig, keys []string) (reported, measured int64) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	cache := variant.newCache(config.Entries, config.ValueSize)
+	for _, key := range keys[:config.Entries] {
+		cache.set(key, make([]byte, config.ValueSize))
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	reported = cache.memoryUsage()
+	runtime.KeepAlive(cache)
+	return reported, int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+// printCacheBench writes a comparison table of the results
+func printCacheBench(config CacheBenchConfig, results []CacheBenchResult) {
+	fmt.Printf("\n--- Cache Microbenchmarks (%d entries of %s, %d operations) ---\n",
+		config.Entries, formatBytes(int64(config.ValueSize)), max(config.Operations, config.Entries))
+
+	fmt.Printf("%-16s %12s %12s %12s %12s %10s %10s %9s\n",
+		"VARIANT", "SET OPS/S", "GET OPS/S", "EVICT P50", "EVICT P99", "REPORTED", "MEASURED", "ACCURACY")
+	for _, result := range results {
+		fmt.Printf("%-16s %12.0f %12.0f %12v %12v %10s %10s %8.0f%%\n",
+			result.Variant, result.SetOpsPerSec, result.GetOpsPerSec, result.EvictionP50, result.EvictionP99,
+			formatBytes(result.ReportedBytes), formatBytes(result.MeasuredBytes), result.MemoryAccuracy*100)
+	}
+
+	fmt.Printf("\nContention scaling (ops/s, 90%% reads):\n")
+	fmt.Printf("%-16s", "GOROUTINES")
+	for _, goroutines := range config.Goroutines {
+		fmt.Printf(" %16d", goroutines)
+	}
+	fmt.Println()
+	for _, result := range results {
+		fmt.Printf("%-16s", result.Variant)
+		for _, point := range result.Scaling {
+			fmt.Printf(" %16s", fmt.Sprintf("%.0f (%.1fx)", point.OpsPerSec, point.Speedup))
+		}
+		fmt.Println()
+	}
+}
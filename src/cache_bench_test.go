@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestRunCacheBench tests that a small run measures every variant
+func TestRunCacheBench(t *testing.T) {
+	config := CacheBenchConfig{Entries: 200, ValueSize: 64, Operations: 1000, Goroutines: []int{1, 4}}
+	results := RunCacheBench(config)
+
+	if len(results) != len(cacheBenchVariants) {
+		t.Fatalf("Expected %d results, got %d", len(cacheBenchVariants), len(results))
+	}
+	for _, result := range results {
+		if result.SetOpsPerSec <= 0 || result.GetOpsPerSec <= 0 {
+			t.Errorf("%s: expected positive throughput, got set %.0f, get %.0f", result.Variant, result.SetOpsPerSec, result.GetOpsPerSec)
+		}
+		if len(result.Scaling) != 2 || result.Scaling[0].Speedup != 1 {
+			t.Errorf("%s: expected 2 scaling points starting at 1x, got %+v", result.Variant, result.Scaling)
+		}
+		if result.EvictionP99 < result.EvictionP50 {
+			t.Errorf("%s: expected P99 >= P50, got %v and %v", result.Variant, result.EvictionP99, result.EvictionP50)
+		}
+		if result.ReportedBytes <= 0 {
+			t.Errorf("%s: expected reported memory, got %d", result.Variant, result.ReportedBytes)
+		}
+	}
+}
+
+// TestBenchEvictionStaysBounded tests that the eviction phase keeps each
+// cache within its capacity
+func TestBenchEvictionStaysBounded(t *testing.T) {
+	for _, variant := range cacheBenchVariants {
+		cache := variant.newCache(50, 64)
+		for i := 0; i < 500; i++ {
+			cache.set(string(rune('a'+i%26))+string(rune(i)), make([]byte, 64))
+		}
+		if limit := int64(50 * (64 + 256)); cache.memoryUsage() > limit {
+			t.Errorf("%s: expected at most %d bytes, got %d", variant.name, limit, cache.memoryUsage())
+		}
+	}
+}
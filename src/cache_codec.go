@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheCodec serializes cache entries to bytes, so cached responses can leave
+// process memory: a disk or remote tier, compression, or sharing between
+// processes. Decode(Encode(e)) must reproduce every field of e
+type CacheCodec interface {
+	Name() string
+	Encode(entry *CacheEntry) ([]byte, error)
+	Decode(data []byte) (*CacheEntry, error)
+}
+
+// ErrCacheCodec is returned when data was not produced by the codec decoding it
+var ErrCacheCodec = errors.New("malformed cache entry")
+
+// Cache codec names for CacheCodecByName; a "+gzip" suffix compresses
+const (
+	CacheCodecBinary = "binary"
+	CacheCodecJSON   = "json"
+)
+
+// CacheCodecByName returns the named codec, e.g. "binary" or "json+gzip". An
+// empty name is the default binary codec
+func CacheCodecByName(name string) (CacheCodec, error) {
+	base, compressed := strings.CutSuffix(name, "+gzip")
+	var codec CacheCodec
+	switch base {
+	case CacheCodecBinary, "":
+		codec = BinaryCacheCodec{}
+	case CacheCodecJSON:
+		codec = JSONCacheCodec{}
+	default:
+		return nil, fmt.Errorf("unknown cache codec %q", name)
+	}
+	if compressed {
+		codec = GzipCacheCodec{Inner: codec}
+	}
+	return codec, nil
+}
+
+// binaryCodecMagic starts every BinaryCacheCodec entry; the last byte is the
+// format version. Version 3 replaced version 2's Request with Check; older
+// entries still decode, without a Check
+var binaryCodecMagic = []byte{'A', 'P', 'C', 3}
+
+// BinaryCacheCodec is the default codec: varint-prefixed fields with no
+// field names, typically a few dozen bytes over the body
+type BinaryCacheCodec struct{}
+
+// Name implements CacheCodec
+func (BinaryCacheCodec) Name() string { return CacheCodecBinary }
+
+// Encode implements CacheCodec
+func (BinaryCacheCodec) Encode(entry *CacheEntry) ([]byte, error) {
+	buf := make([]byte, 0, len(binaryCodecMagic)+len(entry.Key)+len(entry.Value)+64)
+	buf = append(buf, binaryCodecMagic...)
+	buf = appendBytes(buf, []byte(entry.Key))
+	buf = binary.LittleEndian.AppendUint64(buf, entry.Check)
+	buf = binary.AppendUvarint(buf, uint64(entry.StatusCode))
+
+	// Sorted so equal entries encode identically
+	names := make([]string, 0, len(entry.Headers))
+	for name := range entry.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf = binary.AppendUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		buf = appendBytes(buf, []byte(name))
+		buf = appendBytes(buf, []byte(entry.Headers[name]))
+	}
+
+	buf = appendBytes(buf, entry.Value)
+	buf = binary.AppendVarint(buf, entry.Size)
+	buf = binary.AppendVarint(buf, unixNano(entry.CreatedAt))
+	buf = binary.AppendVarint(buf, unixNano(entry.LastAccessed))
+	buf = binary.AppendVarint(buf, unixNano(entry.ExpiresAt))
+	buf = binary.AppendVarint(buf, entry.AccessCount)
+	buf = binary.AppendVarint(buf, int64(entry.TTL))
+	return buf, nil
+}
+
+// Decode implements CacheCodec
+func (BinaryCacheCodec) Decode(data []byte) (*CacheEntry, error) {
+	magic := binaryCodecMagic[:len(binaryCodecMagic)-1]
+	if len(data) < len(binaryCodecMagic) || !bytes.HasPrefix(data, magic) {
+		return nil, fmt.Errorf("%w: not a binary cache entry", ErrCacheCodec)
+	}
+	version := data[len(magic)]
+	if version < 1 || version > binaryCodecMagic[len(magic)] {
+		return nil, fmt.Errorf("%w: unknown format version %d", ErrCacheCodec, version)
+	}
+	r := binaryReader{data: data[len(binaryCodecMagic):]}
+
+	entry := &CacheEntry{Key: string(r.bytes())}
+	switch version {
+	case 2:
+		r.bytes() // The request itself, which Check replaced
+	case 3:
+		entry.Check = r.uint64()
+	}
+	entry.StatusCode = int(r.uvarint())
+	if count := r.uvarint(); count > 0 && r.err == nil {
+		// Each header takes at least two bytes, which bounds a corrupt count
+		if count > uint64(len(r.data)/2) {
+			return nil, fmt.Errorf("%w: %d headers in %d bytes", ErrCacheCodec, count, len(r.data))
+		}
+		entry.Headers = make(map[string]string, count)
+		for i := uint64(0); i < count; i++ {
+			name := string(r.bytes())
+			entry.Headers[name] = string(r.bytes())
+		}
+	}
+	entry.Value = r.bytes()
+	entry.Size = r.varint()
+	entry.CreatedAt = fromUnixNano(r.varint())
+	entry.LastAccessed = fromUnixNano(r.varint())
+	entry.ExpiresAt = fromUnixNano(r.varint())
+	entry.AccessCount = r.varint()
+	entry.TTL = time.Duration(r.varint())
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrCacheCodec, len(r.data))
+	}
+	return entry, nil
+}
+
+// appendBytes appends b with its length
+func appendBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// unixNano encodes t, keeping the zero time distinct from the epoch
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano inverts unixNano
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// binaryReader consumes BinaryCacheCodec fields, keeping the first error
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: truncated", ErrCacheCodec)
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: truncated", ErrCacheCodec)
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) uint64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 8 {
+		r.err = fmt.Errorf("%w: truncated", ErrCacheCodec)
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+// bytes returns a copy, so the entry does not pin the encoded buffer
+func (r *binaryReader) bytes() []byte {
+	length := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if length > uint64(len(r.data)) {
+		r.err = fmt.Errorf("%w: field of %d bytes with %d left", ErrCacheCodec, length, len(r.data))
+		return nil
+	}
+	b := bytes.Clone(r.data[:length])
+	r.data = r.data[length:]
+	return b
+}
+
+// JSONCacheCodec encodes entries as JSON, for tiers inspected by people or
+// shared with other languages
+type JSONCacheCodec struct{}
+
+// Name implements CacheCodec
+func (JSONCacheCodec) Name() string { return CacheCodecJSON }
+
+// Encode implements CacheCodec
+func (JSONCacheCodec) Encode(entry *CacheEntry) ([]byte, error) {
+	return json.Marshal(entry)
+}
+
+// Decode implements CacheCodec
+func (JSONCacheCodec) Decode(data []byte) (*CacheEntry, error) {
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheCodec, err)
+	}
+	return &entry, nil
+}
+
+// GzipCacheCodec compresses the output of another codec
+type GzipCacheCodec struct {
+	Inner CacheCodec
+	Level int // A compress/gzip level; zero means gzip.DefaultCompression
+}
+
+// Name implements CacheCodec
+func (g GzipCacheCodec) Name() string { return g.Inner.Name() + "+gzip" }
+
+// Encode implements CacheCodec
+func (g GzipCacheCodec) Encode(entry *CacheEntry) ([]byte, error) {
+	data, err := g.Inner.Encode(entry)
+	if err != nil {
+		return nil, err
+	}
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements CacheCodec
+func (g GzipCacheCodec) Decode(data []byte) (*CacheEntry, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheCodec, err)
+	}
+	defer reader.Close()
+	inner, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheCodec, err)
+	}
+	return g.Inner.Decode(inner)
+}
+
+// NewCacheEntryFromResponse captures resp for caching under key for ttl. The
+// body is read and replaced, so resp stays readable by the caller
+func NewCacheEntryFromResponse(key string, resp *http.Response, ttl time.Duration) (*CacheEntry, error) {
+	var body []byte
+	if resp.Body != nil {
+		var err error
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	now := time.Now()
+	return &CacheEntry{
+		Key:          key,
+		Value:        body,
+		StatusCode:   resp.StatusCode,
+		Headers:      headers,
+		Size:         int64(len(body)),
+		CreatedAt:    now,
+		LastAccessed: now,
+		TTL:          ttl,
+		ExpiresAt:    now.Add(ttl),
+	}, nil
+}
+
+// Response rebuilds an HTTP response from the entry, answering req
+func (e *CacheEntry) Response(req *http.Request) *http.Response {
+	header := make(http.Header, len(e.Headers))
+	for name, value := range e.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Value)),
+		ContentLength: int64(len(e.Value)),
+		Request:       req,
+	}
+}
+
+// maxSnapshotEntry bounds the allocation for one entry read from a snapshot
+const maxSnapshotEntry = 1 << 30
+
+// WriteSnapshot writes the cache's live entries to w with codec, each
+// prefixed by its length, and returns how many were written
+func (c *LRUCache) WriteSnapshot(w io.Writer, codec CacheCodec) (int, error) {
+	writer := bufio.NewWriter(w)
+	entries := c.Snapshot()
+	for i, entry := range entries {
+		data, err := codec.Encode(entry)
+		if err != nil {
+			return i, fmt.Errorf("failed to encode %s: %w", entry.Key, err)
+		}
+		if _, err := writer.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+			return i, err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), writer.Flush()
+}
+
+// ReadSnapshot loads entries written by WriteSnapshot with the same codec;
+// see LoadSnapshot
+func (c *LRUCache) ReadSnapshot(r io.Reader, codec CacheCodec) error {
+	reader := bufio.NewReader(r)
+	var entries []*CacheEntry
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCacheCodec, err)
+		}
+		if length > maxSnapshotEntry {
+			return fmt.Errorf("%w: entry of %d bytes", ErrCacheCodec, length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("%w: %v", ErrCacheCodec, err)
+		}
+		entry, err := codec.Decode(data)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	return c.LoadSnapshot(entries)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// codecTestEntry returns an entry with every field set
+func codecTestEntry() *CacheEntry {
+	now := time.Now()
+	return &CacheEntry{
+		Key:          "GET:https://example.com/users?id=1",
+		Check:        0xfedcba9876543210,
+		Value:        []byte(`{"id":1,"name":"test"}`),
+		StatusCode:   200,
+		Headers:      map[string]string{"Content-Type": "application/json", "Etag": `"abc"`},
+		Size:         22,
+		CreatedAt:    now,
+		LastAccessed: now.Add(time.Second),
+		AccessCount:  3,
+		TTL:          5 * time.Minute,
+		ExpiresAt:    now.Add(5 * time.Minute),
+	}
+}
+
+// TestCacheCodecRoundTrip tests that every codec reproduces an entry
+func TestCacheCodecRoundTrip(t *testing.T) {
+	for _, name := range []string{"binary", "json", "binary+gzip", "json+gzip"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := CacheCodecByName(name)
+			if err != nil {
+				t.Fatalf("CacheCodecByName failed: %v", err)
+			}
+			if codec.Name() != name {
+				t.Errorf("Expected name %s, got %s", name, codec.Name())
+			}
+
+			entry := codecTestEntry()
+			data, err := codec.Encode(entry)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, err := codec.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			// Times compare with Equal, which ignores monotonic readings
+			for _, times := range [][2]time.Time{
+				{entry.CreatedAt, decoded.CreatedAt},
+				{entry.LastAccessed, decoded.LastAccessed},
+				{entry.ExpiresAt, decoded.ExpiresAt},
+			} {
+				if !times[0].Equal(times[1]) {
+					t.Errorf("Expected time %v, got %v", times[0], times[1])
+				}
+			}
+			entry.CreatedAt, entry.LastAccessed, entry.ExpiresAt = time.Time{}, time.Time{}, time.Time{}
+			decoded.CreatedAt, decoded.LastAccessed, decoded.ExpiresAt = time.Time{}, time.Time{}, time.Time{}
+			if !reflect.DeepEqual(entry, decoded) {
+				t.Errorf("Expected %+v, got %+v", entry, decoded)
+			}
+		})
+	}
+
+	if _, err := CacheCodecByName("msgpack"); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+}
+
+// TestBinaryCacheCodecCorrupt tests that damaged data is rejected rather
+// than decoded into a wrong entry
+func TestBinaryCacheCodecCorrupt(t *testing.T) {
+	codec := BinaryCacheCodec{}
+	data, _ := codec.Encode(codecTestEntry())
+
+	for name, corrupt := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("XXXX"), data[4:]...),
+		"truncated": data[:len(data)-3],
+		"trailing":  append(bytes.Clone(data), 0),
+		"headers":   append(append(bytes.Clone(data[:4]), 0, 200), 0xff, 0xff, 0x03),
+	} {
+		if _, err := codec.Decode(corrupt); !errors.Is(err, ErrCacheCodec) {
+			t.Errorf("%s: expected ErrCacheCodec, got %v", name, err)
+		}
+	}
+	if _, err := (GzipCacheCodec{Inner: codec}).Decode(data); !errors.Is(err, ErrCacheCodec) {
+		t.Errorf("Expected ErrCacheCodec for uncompressed data, got %v", err)
+	}
+}
+
+// TestBinaryCacheCodecOlderVersions tests that entries written before Check
+// replaced Request still decode, without a Check
+func TestBinaryCacheCodecOlderVersions(t *testing.T) {
+	entry := codecTestEntry()
+	data, _ := BinaryCacheCodec{}.Encode(entry)
+
+	// Version 1 had nothing after Key and version 2 the request itself
+	keyEnd := len(binaryCodecMagic) + 1 + len(entry.Key)
+	rest := data[keyEnd+8:]
+	v1 := append([]byte{'A', 'P', 'C', 1}, data[len(binaryCodecMagic):keyEnd]...)
+	v1 = append(v1, rest...)
+	v2 := append([]byte{'A', 'P', 'C', 2}, data[len(binaryCodecMagic):keyEnd]...)
+	v2 = appendBytes(v2, []byte("GET https://example.com/users?id=1"))
+	v2 = append(v2, rest...)
+
+	for version, old := range map[int][]byte{1: v1, 2: v2} {
+		decoded, err := BinaryCacheCodec{}.Decode(old)
+		if err != nil {
+			t.Fatalf("Decode of a version %d entry failed: %v", version, err)
+		}
+		if decoded.Key != entry.Key || decoded.Check != 0 || string(decoded.Value) != string(entry.Value) {
+			t.Errorf("Unexpected version %d entry: %+v", version, decoded)
+		}
+	}
+
+	future := append([]byte{'A', 'P', 'C', 9}, data[len(binaryCodecMagic):]...)
+	if _, err := (BinaryCacheCodec{}).Decode(future); !errors.Is(err, ErrCacheCodec) {
+		t.Errorf("Expected ErrCacheCodec for an unknown version, got %v", err)
+	}
+}
+
+// TestCacheEntryResponse tests that a response survives being cached
+func TestCacheEntryResponse(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 201,
+		Header:     http.Header{"Content-Type": {"text/plain"}, "Vary": {"Accept", "Origin"}},
+		Body:       io.NopCloser(strings.NewReader("created")),
+	}
+	entry, err := NewCacheEntryFromResponse("key", resp, time.Minute)
+	if err != nil {
+		t.Fatalf("NewCacheEntryFromResponse failed: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "created" {
+		t.Errorf("Expected the original body to stay readable, got %q", body)
+	}
+
+	data, _ := BinaryCacheCodec{}.Encode(entry)
+	decoded, err := BinaryCacheCodec{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	cached := decoded.Response(nil)
+	body, _ := io.ReadAll(cached.Body)
+	if cached.StatusCode != 201 || string(body) != "created" || cached.ContentLength != 7 {
+		t.Errorf("Unexpected cached response %d %q", cached.StatusCode, body)
+	}
+	if got := cached.Header.Get("Vary"); got != "Accept, Origin" {
+		t.Errorf("Expected joined Vary header, got %q", got)
+	}
+}
+
+// TestCacheSnapshotCodec tests persisting a cache and loading it elsewhere
+func TestCacheSnapshotCodec(t *testing.T) {
+	source := NewLRUCache(10, 10)
+	for _, key := range []string{"a", "b", "c"} {
+		entry := codecTestEntry()
+		entry.Key = key
+		source.Put(key, entry)
+	}
+
+	codec := GzipCacheCodec{Inner: BinaryCacheCodec{}}
+	var buf bytes.Buffer
+	written, err := source.WriteSnapshot(&buf, codec)
+	if err != nil || written != 3 {
+		t.Fatalf("Expected 3 entries written, got %d, %v", written, err)
+	}
+
+	target := NewLRUCache(10, 10)
+	if err := target.ReadSnapshot(bytes.NewReader(buf.Bytes()), codec); err != nil {
+		t.Fatalf("ReadSnapshot failed: %v", err)
+	}
+	if got := len(target.Snapshot()); got != 3 {
+		t.Errorf("Expected 3 entries loaded, got %d", got)
+	}
+
+	if err := target.ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), codec); !errors.Is(err, ErrCacheCodec) {
+		t.Errorf("Expected ErrCacheCodec for a truncated snapshot, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// FNV-1a 64-bit parameters, inlined so hashing never allocates a hash.Hash64
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// Parameters of the second hash behind Check: another offset and an odd
+// multiplier from the golden ratio, so it collides independently of Hash
+const (
+	checkOffset64 uint64 = 0x6a09e667f3bcc908
+	checkPrime64  uint64 = 0x9e3779b97f4a7c15
+)
+
+// componentSeparator keeps "ab"+"c" and "a"+"bc" from hashing alike
+const componentSeparator = 0xff
+
+// CacheKeyBuilder hashes requests into cache keys without allocating on the
+// happy path. The key covers method, scheme, host, path and query
+// (order-insensitive), plus optional headers and body
+type CacheKeyBuilder struct {
+	headers []string // Canonical header names to include
+}
+
+// NewCacheKeyBuilder creates a builder that also varies keys on headers
+func NewCacheKeyBuilder(headers ...string) *CacheKeyBuilder {
+	canonical := make([]string, len(headers))
+	for i, header := range headers {
+		canonical[i] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+	return &CacheKeyBuilder{headers: canonical}
+}
+
+// defaultCacheKeyBuilder keys on method and URL only
+var defaultCacheKeyBuilder = NewCacheKeyBuilder()
+
+// Hash returns the 64-bit key for req; body may be nil to leave it out
+func (b *CacheKeyBuilder) Hash(req *http.Request, body []byte) uint64 {
+	return b.hash(req, body, fnvOffset64, fnvPrime64)
+}
+
+// Check returns a second 64-bit hash of what Hash covers apart from the body.
+// Stored next to a cached entry it tells two requests whose keys collide
+// apart, since they are all but certain to differ here too
+func (b *CacheKeyBuilder) Check(req *http.Request) uint64 {
+	return mix64(b.hash(req, nil, checkOffset64, checkPrime64))
+}
+
+// hash folds req into offset with the FNV-1a scheme and multiplier prime
+func (b *CacheKeyBuilder) hash(req *http.Request, body []byte, offset, prime uint64) uint64 {
+	h := offset
+	h = foldString(h, prime, req.Method)
+	h = foldByte(h, prime, componentSeparator)
+
+	// Schemes and hosts are case-insensitive
+	h = foldLower(h, prime, req.URL.Scheme)
+	h = foldByte(h, prime, componentSeparator)
+	h = foldLower(h, prime, req.URL.Host)
+	h = foldByte(h, prime, componentSeparator)
+
+	path := req.URL.RawPath
+	if path == "" {
+		path = req.URL.Path
+	}
+	h = foldString(h, prime, path)
+	h = foldByte(h, prime, componentSeparator)
+
+	h = mix64(h ^ hashQuery(req.URL.RawQuery, offset, prime))
+
+	for _, name := range b.headers {
+		h = foldByte(h, prime, componentSeparator)
+		h = foldString(h, prime, name)
+		for _, value := range req.Header[name] {
+			h = foldByte(h, prime, componentSeparator)
+			h = foldString(h, prime, value)
+		}
+	}
+
+	if body != nil {
+		h = foldByte(h, prime, componentSeparator)
+		for _, c := range body {
+			h = foldByte(h, prime, c)
+		}
+	}
+
+	return h
+}
+
+// Key returns the cache key for req as a fixed-width hex string
+func (b *CacheKeyBuilder) Key(req *http.Request, body []byte) string {
+	var buf [16]byte
+	digits := strconv.AppendUint(buf[:0], b.Hash(req, body), 16)
+
+	// Left-pad so every key has the same width
+	var out [16]byte
+	for i := range out {
+		out[i] = '0'
+	}
+	copy(out[16-len(digits):], digits)
+	return string(out[:])
+}
+
+// VaryKey extends base, a key from Key, with the request headers a cached
+// response varies on, given as sorted canonical names. Spaces and tabs in
+// values are ignored, so "gzip, br" and "gzip,br" select the same variant
+func VaryKey(base string, req *http.Request, vary []string) string {
+	if len(vary) == 0 {
+		return base
+	}
+	h := hashString(fnvOffset64, base)
+	for _, name := range vary {
+		h = hashByte(h, componentSeparator)
+		h = hashString(h, name)
+		for _, value := range req.Header[name] {
+			h = hashByte(h, componentSeparator)
+			for i := 0; i < len(value); i++ {
+				if c := value[i]; c != ' ' && c != '\t' {
+					h = hashByte(h, c)
+				}
+			}
+		}
+	}
+	return base + "-" + strconv.FormatUint(h, 16)
+}
+
+// hashQuery combines per-parameter hashes with addition, so the result does
+// not depend on parameter order and no sorting buffer is needed
+func hashQuery(rawQuery string, offset, prime uint64) uint64 {
+	var sum uint64
+	for rawQuery != "" {
+		param := rawQuery
+		if i := strings.IndexByte(rawQuery, '&'); i >= 0 {
+			param, rawQuery = rawQuery[:i], rawQuery[i+1:]
+		} else {
+			rawQuery = ""
+		}
+		if param != "" {
+			sum += mix64(foldString(offset, prime, param))
+		}
+	}
+	return sum
+}
+
+// hashString folds s into h with FNV-1a
+func hashString(h uint64, s string) uint64 {
+	return foldString(h, fnvPrime64, s)
+}
+
+// hashByte folds c into h with FNV-1a
+func hashByte(h uint64, c byte) uint64 {
+	return foldByte(h, fnvPrime64, c)
+}
+
+// foldString folds s into h with multiplier prime
+func foldString(h, prime uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime
+	}
+	return h
+}
+
+// foldLower folds s into h as if it were lowercase
+func foldLower(h, prime uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		h = foldByte(h, prime, c)
+	}
+	return h
+}
+
+// foldByte folds c into h with multiplier prime
+func foldByte(h, prime uint64, c byte) uint64 {
+	h ^= uint64(c)
+	h *= prime
+	return h
+}
+
+// mix64 is the splitmix64 finalizer, spreading bits before values are combined
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
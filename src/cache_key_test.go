@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCacheKeyBuilder tests which request differences change the key
+func TestCacheKeyBuilder(t *testing.T) {
+	builder := NewCacheKeyBuilder("accept")
+	key := func(method, url, accept string) string {
+		req, _ := http.NewRequest(method, url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return builder.Key(req, nil)
+	}
+
+	base := key("GET", "http://api.example.com/users?a=1&b=2", "")
+	if len(base) != 16 {
+		t.Errorf("Expected a 16 character key, got %q", base)
+	}
+	if key("GET", "http://API.example.com/users?b=2&a=1", "") != base {
+		t.Error("Query order and host case should not change the key")
+	}
+
+	for name, other := range map[string]string{
+		"method": key("POST", "http://api.example.com/users?a=1&b=2", ""),
+		"scheme": key("GET", "https://api.example.com/users?a=1&b=2", ""),
+		"path":   key("GET", "http://api.example.com/user?a=1&b=2", ""),
+		"query":  key("GET", "http://api.example.com/users?a=1&b=3", ""),
+		"header": key("GET", "http://api.example.com/users?a=1&b=2", "text/html"),
+	} {
+		if other == base {
+			t.Errorf("Changing the %s should change the key", name)
+		}
+	}
+}
+
+// TestCacheKeyBuilderCheck tests that Check normalizes requests like Hash
+// while coming out differently
+func TestCacheKeyBuilderCheck(t *testing.T) {
+	builder := NewCacheKeyBuilder("accept")
+	check := func(method, url, accept string) uint64 {
+		req, _ := http.NewRequest(method, url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return builder.Check(req)
+	}
+
+	base := check("GET", "http://api.example.com/users?b=2&&a=1", "text/html")
+	if check("GET", "HTTP://API.example.com/users?a=1&b=2", "text/html") != base {
+		t.Error("Query order and scheme or host case should not change the check")
+	}
+
+	for name, other := range map[string]uint64{
+		"method": check("POST", "http://api.example.com/users?a=1&b=2", "text/html"),
+		"scheme": check("GET", "https://api.example.com/users?a=1&b=2", "text/html"),
+		"path":   check("GET", "http://api.example.com/user?a=1&b=2", "text/html"),
+		"query":  check("GET", "http://api.example.com/users?a=1&b=3", "text/html"),
+		"header": check("GET", "http://api.example.com/users?a=1&b=2", "text/plain"),
+	} {
+		if other == base {
+			t.Errorf("Changing the %s should change the check", name)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users?a=1&b=2", nil)
+	req.Header.Set("Accept", "text/html")
+	if builder.Check(req) == builder.Hash(req, nil) {
+		t.Error("Check should not repeat Hash")
+	}
+}
+
+// TestCacheKeyBuilderAllocations tests that hashing a request does not allocate
+func TestCacheKeyBuilderAllocations(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/items?page=2&sort=name", nil)
+	req.Header.Set("Accept", "application/json")
+	builder := NewCacheKeyBuilder("Accept")
+
+	if allocs := testing.AllocsPerRun(100, func() { builder.Hash(req, nil) }); allocs != 0 {
+		t.Errorf("Expected Hash to be allocation-free, got %v allocs", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { builder.Check(req) }); allocs != 0 {
+		t.Errorf("Expected Check to be allocation-free, got %v allocs", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { builder.Key(req, nil) }); allocs > 1 {
+		t.Errorf("Expected Key to allocate only its result, got %v allocs", allocs)
+	}
+}
+
+// BenchmarkCacheKeySprintf benchmarks the previous Sprintf-based key
+func BenchmarkCacheKeySprintf(b *testing.B) {
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/items?page=2&sort=name", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = fmt.Sprintf("%s:%s", req.Method, req.URL.String())
+	}
+}
+
+// BenchmarkCacheKeyBuilder benchmarks the hashed key string
+func BenchmarkCacheKeyBuilder(b *testing.B) {
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/items?page=2&sort=name", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = defaultCacheKeyBuilder.Key(req, nil)
+	}
+}
+
+// BenchmarkCacheKeyBuilderHash benchmarks the allocation-free 64-bit hash
+func BenchmarkCacheKeyBuilderHash(b *testing.B) {
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/items?page=2&sort=name", nil)
+	req.Header.Set("Accept", "application/json")
+	builder := NewCacheKeyBuilder("Accept")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = builder.Hash(req, nil)
+	}
+}
+
+// FuzzCacheKeyBuilder tests that any request hashes without panicking to a
+// fixed-width key that ignores query order and host case
+func FuzzCacheKeyBuilder(f *testing.F) {
+	f.Add("GET", "api.example.com", "/v1/items", "page=2&sort=name", "application/json", []byte(nil))
+	f.Add("POST", "API.example.com:8443", "/a%2Fb", "a=1&&b=&=c", "gzip, br", []byte(`{"prompt":"hi"}`))
+	f.Add("", "", "", "&&&", "", []byte{})
+	builder := NewCacheKeyBuilder("Accept")
+
+	f.Fuzz(func(t *testing.T, method, host, path, rawQuery, accept string, body []byte) {
+		req := &http.Request{
+			Method: method,
+			URL:    &url.URL{Host: host, Path: path, RawQuery: rawQuery},
+			Header: http.Header{"Accept": {accept}},
+		}
+		key := builder.Key(req, body)
+		if len(key) != 16 {
+			t.Fatalf("Expected a 16 character key, got %q", key)
+		}
+
+		params := strings.Split(rawQuery, "&")
+		slices.Reverse(params)
+		reordered := *req
+		upper := []byte(host)
+		for i, c := range upper {
+			if 'a' <= c && c <= 'z' {
+				upper[i] = c - ('a' - 'A')
+			}
+		}
+		reordered.URL = &url.URL{Host: string(upper), Path: path, RawQuery: strings.Join(params, "&")}
+		if other := builder.Key(&reordered, body); other != key {
+			t.Errorf("Expected query order and host case to keep key %s, got %s", key, other)
+		}
+
+		if vary := VaryKey(key, req, []string{"Accept"}); !strings.HasPrefix(vary, key+"-") {
+			t.Errorf("Expected a variant of %s, got %s", key, vary)
+		}
+	})
+}
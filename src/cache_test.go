@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -308,6 +311,59 @@ func TestCacheWarmup(t *testing.T) {
 	}
 }
 
+// TestHistoricalWarmup tests warming from daemon analytics
+func TestHistoricalWarmup(t *testing.T) {
+	var fetches int64
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/analytics" {
+			fmt.Fprintf(w, `{"top_urls":[
+				{"url":"%[1]s/hot","total_requests":50,"typical_ttl":60000000000},
+				{"url":"%[1]s/warm","total_requests":20},
+				{"url":"%[1]s/cold","total_requests":1}]}`, server.URL)
+			return
+		}
+		atomic.AddInt64(&fetches, 1)
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	source := &DaemonAnalyticsSource{BaseURL: server.URL}
+
+	// Dry run plans without fetching
+	dryRun := NewHistoricalWarmup(source, 2, 2)
+	dryRun.SetDryRun(true)
+	cache := NewLRUCache(100, 10)
+	if err := dryRun.Warmup(context.Background(), cache); err != nil {
+		t.Fatalf("Dry-run warmup failed: %v", err)
+	}
+	planned := dryRun.Planned()
+	if len(planned) != 2 || planned[0].URL != server.URL+"/hot" {
+		t.Fatalf("Unexpected plan: %+v", planned)
+	}
+	if planned[0].TTL != time.Minute || planned[1].TTL != 5*time.Minute {
+		t.Errorf("Expected typical TTL then default TTL, got %v and %v", planned[0].TTL, planned[1].TTL)
+	}
+	if cache.Size() != 0 || atomic.LoadInt64(&fetches) != 0 {
+		t.Errorf("Dry run should not fetch or populate cache")
+	}
+
+	// Real run pre-fetches the top N
+	warmup := NewHistoricalWarmup(source, 2, 2)
+	if err := warmup.Warmup(context.Background(), cache); err != nil {
+		t.Fatalf("Historical warmup failed: %v", err)
+	}
+	if cache.Size() != 2 {
+		t.Errorf("Expected 2 entries after warmup, got %d", cache.Size())
+	}
+
+	key := (&CacheKey{URL: server.URL + "/hot", Method: "GET"}).Hash()
+	entry, found := cache.Get(key)
+	if !found || string(entry.Value) != "payload" {
+		t.Errorf("Expected fetched body to be cached")
+	}
+}
+
 // TestPredictiveWarmup tests predictive cache warming
 func TestPredictiveWarmup(t *testing.T) {
 	warmup := NewPredictiveWarmup(30*time.Minute, 5)
@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -269,6 +274,280 @@ func (t *TimeBasedWarmup) Warmup(ctx context.Context, cache *LRUCache) error {
 	return nil
 }
 
+// HotURL describes a frequently requested URL observed in historical traffic
+type HotURL struct {
+	URL      string        `json:"url"`
+	Requests int64         `json:"requests"`
+	TTL      time.Duration `json:"ttl"` // Typical TTL; zero means use the warmup default
+}
+
+// HotURLSource provides the hottest URLs from historical traffic
+type HotURLSource interface {
+	HotURLs(ctx context.Context, n int) ([]HotURL, error)
+}
+
+// DaemonAnalyticsSource reads hot URLs from a running apilo daemon's /analytics endpoint
+type DaemonAnalyticsSource struct {
+	BaseURL string       // Daemon address, e.g. http://localhost:9876
+	Client  *http.Client // Optional; defaults to a client with a 10s timeout
+}
+
+// daemonTopURL mirrors the subset of the daemon's URLAnalytics used for warmup
+type daemonTopURL struct {
+	URL           string `json:"url"`
+	TotalRequests int64  `json:"total_requests"`
+	TypicalTTL    int64  `json:"typical_ttl"` // nanoseconds
+}
+
+// HotURLs fetches the top N URLs by request count from the daemon
+func (s *DaemonAnalyticsSource) HotURLs(ctx context.Context, n int) ([]HotURL, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	endpoint := strings.TrimRight(s.BaseURL, "/") + "/analytics"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build analytics request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daemon analytics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon analytics returned status %d", resp.StatusCode)
+	}
+
+	var snapshot struct {
+		TopURLs []daemonTopURL `json:"top_urls"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode daemon analytics: %w", err)
+	}
+
+	hot := make([]HotURL, 0, len(snapshot.TopURLs))
+	for _, u := range snapshot.TopURLs {
+		hot = append(hot, HotURL{
+			URL:      u.URL,
+			Requests: u.TotalRequests,
+			TTL:      time.Duration(u.TypicalTTL),
+		})
+	}
+
+	return topHotURLs(hot, n), nil
+}
+
+// ResultsStoreSource derives hot URLs from saved benchmark results
+type ResultsStoreSource struct {
+	Paths []string // BenchmarkResult JSON files
+}
+
+// HotURLs ranks benchmarked target URLs by how many requests were issued against them
+func (s *ResultsStoreSource) HotURLs(ctx context.Context, n int) ([]HotURL, error) {
+	counts := make(map[string]int64)
+
+	for _, path := range s.Paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read results %s: %w", path, err)
+		}
+
+		var result BenchmarkResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse results %s: %w", path, err)
+		}
+		if result.TargetURL != "" {
+			counts[result.TargetURL] += int64(result.TotalRequests)
+		}
+	}
+
+	hot := make([]HotURL, 0, len(counts))
+	for url, requests := range counts {
+		hot = append(hot, HotURL{URL: url, Requests: requests})
+	}
+
+	return topHotURLs(hot, n), nil
+}
+
+// topHotURLs sorts by request count (descending) and truncates to n
+func topHotURLs(hot []HotURL, n int) []HotURL {
+	sort.SliceStable(hot, func(i, j int) bool {
+		return hot[i].Requests > hot[j].Requests
+	})
+
+	if n > 0 && len(hot) > n {
+		hot = hot[:n]
+	}
+
+	return hot
+}
+
+// HistoricalWarmup pre-fetches the hottest URLs from historical analytics
+type HistoricalWarmup struct {
+	source      HotURLSource
+	topN        int
+	concurrency int
+	defaultTTL  time.Duration
+	dryRun      bool
+	client      *http.Client
+
+	mu      sync.Mutex
+	planned []HotURL // URLs selected by the last warmup run
+}
+
+// NewHistoricalWarmup creates a warmup strategy backed by historical traffic
+func NewHistoricalWarmup(source HotURLSource, topN, concurrency int) *HistoricalWarmup {
+	if topN <= 0 {
+		topN = 10
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	return &HistoricalWarmup{
+		source:      source,
+		topN:        topN,
+		concurrency: concurrency,
+		defaultTTL:  5 * time.Minute,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (h *HistoricalWarmup) Name() string {
+	return "historical"
+}
+
+// SetDryRun enables planning without fetching or populating the cache
+func (h *HistoricalWarmup) SetDryRun(dryRun bool) {
+	h.dryRun = dryRun
+}
+
+// SetDefaultTTL sets the TTL used for URLs without a typical TTL
+func (h *HistoricalWarmup) SetDefaultTTL(ttl time.Duration) {
+	h.defaultTTL = ttl
+}
+
+// SetHTTPClient overrides the client used for pre-fetching
+func (h *HistoricalWarmup) SetHTTPClient(client *http.Client) {
+	h.client = client
+}
+
+// Planned returns the URLs selected by the most recent warmup run
+func (h *HistoricalWarmup) Planned() []HotURL {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	planned := make([]HotURL, len(h.planned))
+	copy(planned, h.planned)
+	return planned
+}
+
+func (h *HistoricalWarmup) Warmup(ctx context.Context, cache *LRUCache) error {
+	hot, err := h.source.HotURLs(ctx, h.topN)
+	if err != nil {
+		return fmt.Errorf("failed to load hot URLs: %w", err)
+	}
+
+	for i := range hot {
+		if hot[i].TTL <= 0 {
+			hot[i].TTL = h.defaultTTL
+		}
+	}
+
+	h.mu.Lock()
+	h.planned = hot
+	h.mu.Unlock()
+
+	if h.dryRun {
+		return nil
+	}
+
+	sem := make(chan struct{}, h.concurrency)
+	errChan := make(chan error, len(hot))
+	var wg sync.WaitGroup
+
+	for _, target := range hot {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(target HotURL) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := h.prefetch(ctx, cache, target); err != nil {
+				errChan <- err
+			}
+		}(target)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// Report the first failure; successful fetches remain cached
+	for err := range errChan {
+		return err
+	}
+
+	return nil
+}
+
+// prefetch fetches a single URL and stores the response in the cache
+func (h *HistoricalWarmup) prefetch(ctx context.Context, cache *LRUCache, target HotURL) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return fmt.Errorf("prefetch %s: %w", target.URL, err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("prefetch %s: %w", target.URL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("prefetch %s: %w", target.URL, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("prefetch %s: status %d", target.URL, resp.StatusCode)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for name := range resp.Header {
+		headers[name] = resp.Header.Get(name)
+	}
+
+	now := time.Now()
+	key := (&CacheKey{URL: target.URL, Method: "GET"}).Hash()
+	entry := &CacheEntry{
+		Key:          key,
+		Value:        body,
+		StatusCode:   resp.StatusCode,
+		Headers:      headers,
+		Size:         int64(len(body)),
+		CreatedAt:    now,
+		LastAccessed: now,
+		TTL:          target.TTL,
+		ExpiresAt:    now.Add(target.TTL),
+	}
+
+	return cache.Put(key, entry)
+}
+
 // AdaptiveWarmup combines multiple strategies
 type AdaptiveWarmup struct {
 	strategies []WarmupStrategy
@@ -508,6 +787,15 @@ func CreateWarmupStrategy(config WarmupConfig) (WarmupStrategy, error) {
 	case "time_based":
 		return NewTimeBasedWarmup(), nil
 
+	case "historical":
+		if config.AnalyticsURL == "" {
+			return nil, fmt.Errorf("historical warmup requires analytics_url")
+		}
+		source := &DaemonAnalyticsSource{BaseURL: config.AnalyticsURL}
+		warmup := NewHistoricalWarmup(source, config.TopN, config.Concurrency)
+		warmup.SetDryRun(config.DryRun)
+		return warmup, nil
+
 	case "adaptive":
 		// Create adaptive strategy with static and predictive
 		static := NewStaticWarmup(config.StaticURLs)
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBenchmarkerCancellation tests that cancelling aborts in-flight requests
+// promptly and returns the completed ones as a partial result
+func TestBenchmarkerCancellation(t *testing.T) {
+	var served int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first requests succeed, the rest hang until the client gives up
+		if atomic.AddInt64(&served, 1) > 10 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 100,
+		Concurrency:   1,
+		KeepAlive:     true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := benchmarker.Run(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run took %v to honor cancellation", elapsed)
+	}
+
+	if err != nil {
+		t.Fatalf("Cancellation should return partial results, not an error: %v", err)
+	}
+	if !result.Partial {
+		t.Fatalf("Expected a partial result, got %+v", result)
+	}
+	if result.SuccessfulReqs != 10 || result.FailedReqs != 0 {
+		t.Errorf("Aborted requests should not count as failures: %d ok, %d failed",
+			result.SuccessfulReqs, result.FailedReqs)
+	}
+	if result.CanceledReqs != 90 {
+		t.Errorf("Expected 90 canceled requests, got %d", result.CanceledReqs)
+	}
+}
+
+// TestRunnerCancellationFlushesPartialResults tests that an interrupted suite
+// stops between iterations and still writes what it completed
+func TestRunnerCancellationFlushesPartialResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := BenchmarkConfig{TargetURL: server.URL, TotalRequests: 5, Concurrency: 1}
+	suite := &BenchmarkSuite{
+		Name:      "cancel_test",
+		OutputDir: t.TempDir(),
+		Runs: []BenchmarkRun{
+			{Name: "first", Config: config, Iterations: 3},
+			{Name: "second", Config: config, Iterations: 3},
+		},
+	}
+	runner := NewBenchmarkRunner(suite)
+
+	// Cancel during the pause after the first iteration
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := runner.Run(ctx)
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("Runner took %v to honor cancellation", elapsed)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the suite to report cancellation, got %v", err)
+	}
+	if !suite.Interrupted {
+		t.Error("Suite should be marked interrupted")
+	}
+	if len(suite.Runs[0].Results) != 1 || len(suite.Runs[1].Results) != 0 {
+		t.Errorf("Expected 1 completed iteration and a skipped run, got %d and %d",
+			len(suite.Runs[0].Results), len(suite.Runs[1].Results))
+	}
+
+	for _, name := range []string{"suite_results.json", suite.Runs[0].ID + ".json", "SUMMARY.md"} {
+		if _, err := os.Stat(filepath.Join(runner.resultDir, name)); err != nil {
+			t.Errorf("Partial results not flushed: %v", err)
+		}
+	}
+}
+
+// TestMonitoringStopsOnCancel tests that background loops exit when the
+// context they were started with is cancelled
+func TestMonitoringStopsOnCancel(t *testing.T) {
+	config := DefaultMonitoringConfig()
+	config.DashboardEnabled = false
+	config.PrometheusEnabled = false
+	config.MetricsInterval = 10 * time.Millisecond
+
+	monitoring := NewMonitoringSystem(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := monitoring.StartContext(ctx); err != nil {
+		t.Fatalf("Failed to start monitoring: %v", err)
+	}
+	defer monitoring.Stop()
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		monitoring.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Monitoring loops kept running after cancellation")
+	}
+}
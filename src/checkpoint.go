@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckpointFile is the name of the checkpoint written in a suite's result directory
+const CheckpointFile = "checkpoint.json"
+
+// DefaultFlushInterval is how often interim results are flushed when a suite
+// does not set FlushInterval
+const DefaultFlushInterval = 10 * time.Second
+
+// BenchmarkCheckpoint is the recoverable state of a suite, rewritten after
+// every iteration and every flush interval so a crashed or interrupted run can
+// be resumed or reported
+type BenchmarkCheckpoint struct {
+	Suite     *BenchmarkSuite `json:"suite"`
+	ResultDir string          `json:"result_dir"`
+	RunIndex  int             `json:"run_index"` // Runs before this one are finished
+	UpdatedAt time.Time       `json:"updated_at"`
+	Complete  bool            `json:"complete"`
+
+	// Interim aggregate of the iteration in progress; it is not resumed
+	InProgress *BenchmarkResult `json:"in_progress,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint written by a previous run
+func LoadCheckpoint(path string) (*BenchmarkCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint BenchmarkCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if checkpoint.Suite == nil {
+		return nil, fmt.Errorf("checkpoint %s has no suite", path)
+	}
+	return &checkpoint, nil
+}
+
+// ResumeBenchmarkRunner creates a runner that continues the suite recorded in
+// a checkpoint, skipping finished runs and completed iterations and writing
+// into the original result directory
+func ResumeBenchmarkRunner(checkpointPath string) (*BenchmarkRunner, error) {
+	checkpoint, err := LoadCheckpoint(checkpointPath)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Complete {
+		return nil, fmt.Errorf("suite %s already completed", checkpoint.Suite.Name)
+	}
+
+	resultDir := checkpoint.ResultDir
+	if resultDir == "" {
+		resultDir = filepath.Dir(checkpointPath)
+	}
+
+	checkpoint.Suite.Interrupted = false
+	checkpoint.Suite.AssignIDs()
+	return &BenchmarkRunner{
+		suite:     checkpoint.Suite,
+		resultDir: resultDir,
+	}, nil
+}
+
+// findResumableCheckpoint returns the most recent unfinished checkpoint for
+// suiteID under outputDir
+func findResumableCheckpoint(outputDir, suiteID string) (*BenchmarkCheckpoint, bool) {
+	paths, _ := filepath.Glob(filepath.Join(outputDir, suiteID+"_*", CheckpointFile))
+
+	var latest *BenchmarkCheckpoint
+	for _, path := range paths {
+		checkpoint, err := LoadCheckpoint(path)
+		if err != nil || checkpoint.Complete || checkpoint.Suite.ID != suiteID {
+			continue
+		}
+		if checkpoint.ResultDir == "" {
+			checkpoint.ResultDir = filepath.Dir(path)
+		}
+		if latest == nil || checkpoint.UpdatedAt.After(latest.UpdatedAt) {
+			latest = checkpoint
+		}
+	}
+	return latest, latest != nil
+}
+
+// AssignIDs gives the suite and each run an ID derived from their definitions,
+// so running the same suite again maps onto the same IDs. IDs already set are
+// kept
+func (s *BenchmarkSuite) AssignIDs() {
+	suiteHash := sha256.New()
+	suiteHash.Write([]byte(s.Name))
+
+	for i := range s.Runs {
+		run := &s.Runs[i]
+		runHash := runDefinitionHash(i, run)
+		suiteHash.Write([]byte(runHash))
+		if run.ID == "" {
+			run.ID = fmt.Sprintf("%s-%s", idSlug(run.Name), runHash[:8])
+		}
+	}
+
+	if s.ID == "" {
+		s.ID = fmt.Sprintf("%s-%s", idSlug(s.Name), hex.EncodeToString(suiteHash.Sum(nil))[:12])
+	}
+}
+
+// runDefinitionHash hashes what a run does, leaving out its results
+func runDefinitionHash(index int, run *BenchmarkRun) string {
+	definition, _ := json.Marshal(struct {
+		Index            int
+		Name             string
+		Config           BenchmarkConfig
+		Iterations       int
+		WarmupIterations int
+		LoadPattern      LoadPattern
+		Targets          []string
+		Variants         []ConnectionVariant `json:",omitempty"`
+		ColdPath         bool                `json:",omitempty"`
+	}{index, run.Name, run.Config, run.Iterations, run.WarmupIterations, run.LoadPattern, run.Targets, run.ConnectionVariants, run.ColdPath})
+
+	sum := sha256.Sum256(definition)
+	return hex.EncodeToString(sum[:])
+}
+
+// idSlug makes name safe for use in IDs and file names
+func idSlug(name string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, name)
+	if slug == "" {
+		return "run"
+	}
+	return slug
+}
+
+// restoreResults copies results from a saved copy of the suite onto the runs
+// with matching IDs
+func (s *BenchmarkSuite) restoreResults(saved *BenchmarkSuite) {
+	savedRuns := make(map[string]*BenchmarkRun, len(saved.Runs))
+	for i := range saved.Runs {
+		savedRuns[saved.Runs[i].ID] = &saved.Runs[i]
+	}
+
+	for i := range s.Runs {
+		if previous, ok := savedRuns[s.Runs[i].ID]; ok {
+			s.Runs[i].Results = previous.Results
+			s.Runs[i].TargetResults = previous.TargetResults
+			s.Runs[i].Comparison = previous.Comparison
+			s.Runs[i].VariantResults = previous.VariantResults
+			s.Runs[i].ConnectionExperiment = previous.ConnectionExperiment
+			s.Runs[i].PathResults = previous.PathResults
+			s.Runs[i].ColdPathComparison = previous.ColdPathComparison
+		}
+	}
+}
+
+// runFinished reports whether run has nothing left to execute
+func runFinished(run *BenchmarkRun) bool {
+	if len(run.Targets) > 1 {
+		return run.Comparison != nil && len(run.Results) >= run.Iterations
+	}
+	if len(run.ConnectionVariants) > 0 {
+		return run.ConnectionExperiment != nil && len(run.Results) >= run.Iterations
+	}
+	if run.ColdPath {
+		return run.ColdPathComparison != nil && len(run.Results) >= run.Iterations
+	}
+	return run.Results != nil && len(run.Results) >= run.Iterations
+}
+
+// uniqueDir returns dir, or dir with a numeric suffix if it already exists
+func uniqueDir(dir string) string {
+	candidate := dir
+	for n := 2; ; n++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", dir, n)
+	}
+}
+
+// writeCheckpoint atomically replaces the checkpoint file. inProgress is the
+// interim aggregate of the current iteration, if any
+func (r *BenchmarkRunner) writeCheckpoint(runIndex int, inProgress *BenchmarkResult, complete bool) error {
+	r.checkpointMu.Lock()
+	defer r.checkpointMu.Unlock()
+
+	data, err := json.MarshalIndent(&BenchmarkCheckpoint{
+		Suite:      r.suite,
+		ResultDir:  r.resultDir,
+		RunIndex:   runIndex,
+		UpdatedAt:  time.Now(),
+		Complete:   complete,
+		InProgress: inProgress,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.resultDir, CheckpointFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// flushInterval returns how often interim results are written
+func (r *BenchmarkRunner) flushInterval() time.Duration {
+	if r.suite.FlushInterval > 0 {
+		return r.suite.FlushInterval
+	}
+	return DefaultFlushInterval
+}
+
+// watchProgress has benchmarker stream interim aggregates to stdout and the
+// checkpoint while it runs
+func (r *BenchmarkRunner) watchProgress(benchmarker *Benchmarker, runIndex int) {
+	benchmarker.SetProgressHandler(r.flushInterval(), func(interim *BenchmarkResult) {
+		completed := interim.SuccessfulReqs + interim.FailedReqs
+		fmt.Printf("  [%s] %d/%d requests | Failed: %d | RPS: %.2f | P95: %.2f ms\n",
+			interim.Duration.Truncate(time.Second), completed, interim.TotalRequests,
+			interim.FailedReqs, interim.RequestsPerSecond, interim.LatencyStats.P95)
+
+		if err := r.writeCheckpoint(runIndex, interim, false); err != nil {
+			fmt.Printf("WARNING: Failed to write checkpoint: %v\n", err)
+		}
+	})
+}
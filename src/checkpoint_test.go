@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestBenchmarkerProgress tests that interim aggregates are reported while a
+// run is in progress and stop once it returns
+func TestBenchmarkerProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 40, Concurrency: 1, KeepAlive: true})
+
+	var mu sync.Mutex
+	var interims []*BenchmarkResult
+	benchmarker.SetProgressHandler(30*time.Millisecond, func(interim *BenchmarkResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		interims = append(interims, interim)
+	})
+
+	result, err := benchmarker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(interims) < 2 {
+		t.Fatalf("Expected several interim reports, got %d", len(interims))
+	}
+	for i, interim := range interims {
+		if !interim.Partial {
+			t.Errorf("Interim %d should be marked partial", i)
+		}
+		if i > 0 && interim.SuccessfulReqs < interims[i-1].SuccessfulReqs {
+			t.Errorf("Interim progress went backwards: %d then %d", interims[i-1].SuccessfulReqs, interim.SuccessfulReqs)
+		}
+	}
+	if last := interims[len(interims)-1]; last.SuccessfulReqs > result.SuccessfulReqs {
+		t.Errorf("Interim reported %d requests, more than the final %d", last.SuccessfulReqs, result.SuccessfulReqs)
+	}
+	if result.Partial {
+		t.Error("Final result of a completed run should not be partial")
+	}
+}
+
+// TestResumeFromCheckpoint tests that an interrupted suite resumes at the next
+// iteration from its checkpoint and finishes the remaining runs
+func TestResumeFromCheckpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := BenchmarkConfig{TargetURL: server.URL, TotalRequests: 5, Concurrency: 1}
+	suite := &BenchmarkSuite{
+		Name:          "resume_test",
+		OutputDir:     t.TempDir(),
+		FlushInterval: 10 * time.Millisecond,
+		Runs: []BenchmarkRun{
+			{Name: "first", Config: config, Iterations: 2},
+			{Name: "second", Config: config, Iterations: 1},
+		},
+	}
+	runner := NewBenchmarkRunner(suite)
+
+	// Interrupt during the pause after the first iteration
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	if err := runner.Run(ctx); err == nil {
+		t.Fatal("Expected the first attempt to be interrupted")
+	}
+
+	checkpointPath := filepath.Join(runner.resultDir, CheckpointFile)
+	checkpoint, err := LoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("Checkpoint not written: %v", err)
+	}
+	if checkpoint.Complete || checkpoint.RunIndex != 0 || len(checkpoint.Suite.Runs[0].Results) != 1 {
+		t.Fatalf("Unexpected checkpoint: complete=%v run=%d results=%d",
+			checkpoint.Complete, checkpoint.RunIndex, len(checkpoint.Suite.Runs[0].Results))
+	}
+
+	resumed, err := ResumeBenchmarkRunner(checkpointPath)
+	if err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	if err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+
+	if resumed.resultDir != runner.resultDir {
+		t.Errorf("Resumed run should write to %s, got %s", runner.resultDir, resumed.resultDir)
+	}
+	if got := len(resumed.suite.Runs[0].Results); got != 2 {
+		t.Errorf("Expected the first run to finish its 2 iterations, got %d", got)
+	}
+	if got := len(resumed.suite.Runs[1].Results); got != 1 {
+		t.Errorf("Expected the second run to complete, got %d iterations", got)
+	}
+
+	if _, err := ResumeBenchmarkRunner(checkpointPath); err == nil {
+		t.Error("Resuming a completed suite should fail")
+	}
+}
+
+// TestRerunResumesByRunID tests that running the same suite definition again
+// continues the interrupted attempt, and that results are keyed by run ID
+func TestRerunResumesByRunID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	newSuite := func() *BenchmarkSuite {
+		config := BenchmarkConfig{TargetURL: server.URL, TotalRequests: 5, Concurrency: 1}
+		return &BenchmarkSuite{
+			Name:      "rerun test",
+			OutputDir: outputDir,
+			Runs: []BenchmarkRun{
+				{Name: "first", Config: config, Iterations: 2},
+				{Name: "second", Config: config, Iterations: 1},
+			},
+		}
+	}
+
+	suite := newSuite()
+	runner := NewBenchmarkRunner(suite)
+	if suite.ID == "" || suite.Runs[0].ID == "" || suite.Runs[0].ID == suite.Runs[1].ID {
+		t.Fatalf("Expected distinct IDs, got suite=%q runs=%q,%q", suite.ID, suite.Runs[0].ID, suite.Runs[1].ID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	if err := runner.Run(ctx); err == nil {
+		t.Fatal("Expected the first attempt to be interrupted")
+	}
+
+	rerun := newSuite()
+	resumed := NewBenchmarkRunner(rerun)
+	if rerun.ID != suite.ID || rerun.Runs[0].ID != suite.Runs[0].ID {
+		t.Fatalf("IDs changed between attempts: %q vs %q", rerun.ID, suite.ID)
+	}
+	if resumed.resultDir != runner.resultDir {
+		t.Fatalf("Re-run should continue in %s, got %s", runner.resultDir, resumed.resultDir)
+	}
+	if got := len(rerun.Runs[0].Results); got != 1 {
+		t.Fatalf("Expected the completed iteration to be restored, got %d", got)
+	}
+	if err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("Re-run failed: %v", err)
+	}
+	if len(rerun.Runs[0].Results) != 2 || len(rerun.Runs[1].Results) != 1 {
+		t.Errorf("Expected 2 and 1 iterations, got %d and %d",
+			len(rerun.Runs[0].Results), len(rerun.Runs[1].Results))
+	}
+	for _, run := range rerun.Runs {
+		if _, err := os.Stat(filepath.Join(resumed.resultDir, run.ID+".json")); err != nil {
+			t.Errorf("Run results not keyed by ID: %v", err)
+		}
+	}
+
+	// A completed suite is not resumed, and a fresh attempt never reuses its directory
+	fresh := NewBenchmarkRunner(newSuite())
+	if fresh.resultDir == resumed.resultDir {
+		t.Error("A new attempt should not overwrite a completed suite's results")
+	}
+
+	changed := newSuite()
+	changed.Runs[1].Iterations = 3
+	changed.AssignIDs()
+	if changed.ID == suite.ID || changed.Runs[1].ID == suite.Runs[1].ID {
+		t.Error("Changing a run definition should change its IDs")
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics a PerformanceBudget can bound. Latencies are mean per-iteration
+// percentiles in milliseconds, error_rate is a percentage of requests and rps
+// is a floor rather than a ceiling
+const (
+	BudgetMetricP50       = "p50"
+	BudgetMetricP95       = "p95"
+	BudgetMetricP99       = "p99"
+	BudgetMetricTTFBP95   = "ttfb_p95"
+	BudgetMetricErrorRate = "error_rate"
+	BudgetMetricRPS       = "rps"
+)
+
+// Number of points in a run's latency sparkline
+const sparklineWidth = 24
+
+// sparklineLevels are the ASCII characters of a sparkline, lowest first
+const sparklineLevels = "_.-~=+*#"
+
+// PerformanceBudget bounds one metric of every run, or only of Run when set
+type PerformanceBudget struct {
+	Run    string  `json:"run,omitempty"`
+	Metric string  `json:"metric"`
+	Limit  float64 `json:"limit"`
+}
+
+// BudgetResult is a budget checked against one run
+type BudgetResult struct {
+	Budget PerformanceBudget `json:"budget"`
+	Run    string            `json:"run"`
+	Actual float64           `json:"actual"`
+	Passed bool              `json:"passed"`
+}
+
+// ParseBudgets parses a comma-separated list of METRIC=LIMIT budgets, e.g.
+// "p95=250ms,error_rate=1%,rps=50". Latency limits without a unit are
+// milliseconds
+func ParseBudgets(spec string) ([]PerformanceBudget, error) {
+	var budgets []PerformanceBudget
+	for _, entry := range splitList(spec) {
+		metric, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid budget %q: expected METRIC=LIMIT", entry)
+		}
+		metric = strings.ToLower(strings.TrimSpace(metric))
+		value = strings.TrimSpace(value)
+
+		var limit float64
+		var err error
+		switch metric {
+		case BudgetMetricP50, BudgetMetricP95, BudgetMetricP99, BudgetMetricTTFBP95:
+			if d, durErr := time.ParseDuration(value); durErr == nil {
+				limit = float64(d.Microseconds()) / 1000.0
+			} else {
+				limit, err = strconv.ParseFloat(value, 64)
+			}
+		case BudgetMetricErrorRate:
+			limit, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		case BudgetMetricRPS:
+			limit, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("invalid budget %q: unknown metric %s", entry, metric)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget %q: %w", entry, err)
+		}
+		budgets = append(budgets, PerformanceBudget{Metric: metric, Limit: limit})
+	}
+	return budgets, nil
+}
+
+// runSummary holds the figures of a run shown in the CI summary
+type runSummary struct {
+	RPS, P50, P95, P99, TTFBP95 float64
+	ErrorRate                   float64 // Percentage of requests
+	Requests, Failed            int
+}
+
+// summarizeRun averages a run's per-iteration results
+func summarizeRun(run *BenchmarkRun) runSummary {
+	var s runSummary
+	for _, result := range run.Results {
+		s.RPS += result.RequestsPerSecond
+		s.P50 += result.LatencyStats.P50
+		s.P95 += result.LatencyStats.P95
+		s.P99 += result.LatencyStats.P99
+		s.TTFBP95 += result.TTFBStats.P95
+		s.Requests += result.TotalRequests
+		s.Failed += result.FailedReqs
+	}
+	if count := float64(len(run.Results)); count > 0 {
+		s.RPS /= count
+		s.P50 /= count
+		s.P95 /= count
+		s.P99 /= count
+		s.TTFBP95 /= count
+	}
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Failed) / float64(s.Requests) * 100
+	}
+	return s
+}
+
+// value returns the summary's figure for a budget metric
+func (s runSummary) value(metric string) float64 {
+	switch metric {
+	case BudgetMetricP50:
+		return s.P50
+	case BudgetMetricP95:
+		return s.P95
+	case BudgetMetricP99:
+		return s.P99
+	case BudgetMetricTTFBP95:
+		return s.TTFBP95
+	case BudgetMetricErrorRate:
+		return s.ErrorRate
+	case BudgetMetricRPS:
+		return s.RPS
+	}
+	return 0
+}
+
+// EvaluateBudgets checks the suite's budgets against every run with results
+func EvaluateBudgets(suite *BenchmarkSuite) []BudgetResult {
+	var results []BudgetResult
+	for i := range suite.Runs {
+		results = append(results, evaluateRunBudgets(suite.Budgets, &suite.Runs[i])...)
+	}
+	return results
+}
+
+// evaluateRunBudgets checks the budgets that apply to run, if it has results
+func evaluateRunBudgets(budgets []PerformanceBudget, run *BenchmarkRun) []BudgetResult {
+	if len(run.Results) == 0 {
+		return nil
+	}
+	var results []BudgetResult
+	summary := summarizeRun(run)
+	for _, budget := range budgets {
+		if budget.Run != "" && budget.Run != run.Name {
+			continue
+		}
+		actual := summary.value(budget.Metric)
+		passed := actual <= budget.Limit
+		if budget.Metric == BudgetMetricRPS {
+			passed = actual >= budget.Limit
+		}
+		results = append(results, BudgetResult{Budget: budget, Run: run.Name, Actual: actual, Passed: passed})
+	}
+	return results
+}
+
+// budgetLabel describes a budget's bound, e.g. "P95 <= 250.00 ms"
+func budgetLabel(budget PerformanceBudget) string {
+	switch budget.Metric {
+	case BudgetMetricErrorRate:
+		return fmt.Sprintf("Error rate <= %.2f%%", budget.Limit)
+	case BudgetMetricRPS:
+		return fmt.Sprintf("Requests/sec >= %.2f", budget.Limit)
+	case BudgetMetricTTFBP95:
+		return fmt.Sprintf("P95 TTFB <= %.2f ms", budget.Limit)
+	}
+	return fmt.Sprintf("%s <= %.2f ms", strings.ToUpper(budget.Metric), budget.Limit)
+}
+
+// formatBudgetValue renders a measured value in its metric's unit
+func formatBudgetValue(metric string, value float64) string {
+	switch metric {
+	case BudgetMetricErrorRate:
+		return fmt.Sprintf("%.2f%%", value)
+	case BudgetMetricRPS:
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.2f ms", value)
+}
+
+// latencySeries returns the run's latency over time: the mean latency of
+// successive groups of requests when raw metrics were kept, otherwise each
+// iteration's P95
+func latencySeries(run *BenchmarkRun) []float64 {
+	var raw []LatencyMetrics
+	for _, result := range run.Results {
+		for _, m := range result.RawMetrics {
+			if m.Error == "" {
+				raw = append(raw, m)
+			}
+		}
+	}
+	if len(raw) == 0 {
+		series := make([]float64, 0, len(run.Results))
+		for _, result := range run.Results {
+			series = append(series, result.LatencyStats.P95)
+		}
+		return series
+	}
+
+	sort.Slice(raw, func(i, j int) bool { return raw[i].Timestamp.Before(raw[j].Timestamp) })
+	points := min(sparklineWidth, len(raw))
+	series := make([]float64, points)
+	for i := range series {
+		group := raw[i*len(raw)/points : (i+1)*len(raw)/points]
+		var sum time.Duration
+		for _, m := range group {
+			sum += m.TotalLatency
+		}
+		series[i] = float64((sum / time.Duration(len(group))).Microseconds()) / 1000.0
+	}
+	return series
+}
+
+// sparkline renders values as a line of ASCII characters scaled between
+// their minimum and maximum
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	var sb strings.Builder
+	top := len(sparklineLevels) - 1
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = int(math.Round((v - lo) / (hi - lo) * float64(top)))
+		}
+		sb.WriteByte(sparklineLevels[level])
+	}
+	return sb.String()
+}
+
+// generateCISummary writes CI_SUMMARY.md, a short Markdown report with a
+// latency sparkline per run and the budget table, and benchmark.om with the
+// same figures in OpenMetrics text format. In GitHub Actions the Markdown is
+// also appended to the job summary
+func (r *BenchmarkRunner) generateCISummary() []BudgetResult {
+	budgets := EvaluateBudgets(r.suite)
+	report := r.ciSummaryMarkdown(budgets)
+
+	reportPath := filepath.Join(r.resultDir, "CI_SUMMARY.md")
+	if err := os.WriteFile(reportPath, []byte(report), 0644); err != nil {
+		fmt.Printf("WARNING: Failed to write CI summary: %v\n", err)
+	}
+	metricsPath := filepath.Join(r.resultDir, "benchmark.om")
+	if err := os.WriteFile(metricsPath, []byte(r.ciSummaryOpenMetrics(budgets)), 0644); err != nil {
+		fmt.Printf("WARNING: Failed to write OpenMetrics artifact: %v\n", err)
+	}
+	fmt.Printf("CI summary generated: %s\n", reportPath)
+
+	if stepSummary := os.Getenv("GITHUB_STEP_SUMMARY"); stepSummary != "" {
+		file, err := os.OpenFile(stepSummary, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			_, err = file.WriteString(report + "\n")
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Printf("WARNING: Failed to append GitHub job summary: %v\n", err)
+		}
+	}
+	return budgets
+}
+
+// ciSummaryMarkdown renders the CI summary report
+func (r *BenchmarkRunner) ciSummaryMarkdown(budgets []BudgetResult) string {
+	report := fmt.Sprintf("## Benchmark: %s\n\n", r.suite.Name)
+	report += fmt.Sprintf("Code: %s\n\n", r.suite.Metadata)
+	if r.suite.Interrupted {
+		report += "> Interrupted, results are partial\n\n"
+	}
+
+	report += "| Run | Requests/sec | P50 | P95 | P99 | Errors | Latency |\n"
+	report += "|-----|--------------|-----|-----|-----|--------|---------|\n"
+	for i := range r.suite.Runs {
+		run := &r.suite.Runs[i]
+		if len(run.Results) == 0 {
+			continue
+		}
+		s := summarizeRun(run)
+		series := latencySeries(run)
+		lo, hi := series[0], series[0]
+		for _, v := range series {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		report += fmt.Sprintf("| %s | %.2f | %.2f ms | %.2f ms | %.2f ms | %.2f%% | `%s` %.1f-%.1f ms |\n",
+			run.Name, s.RPS, s.P50, s.P95, s.P99, s.ErrorRate, sparkline(series), lo, hi)
+	}
+	report += "\n"
+
+	if len(budgets) == 0 {
+		return report
+	}
+
+	passed := 0
+	report += "| Run | Budget | Actual | Result |\n"
+	report += "|-----|--------|--------|--------|\n"
+	for _, b := range budgets {
+		result := "❌ fail"
+		if b.Passed {
+			passed++
+			result = "✅ pass"
+		}
+		report += fmt.Sprintf("| %s | %s | %s | %s |\n",
+			b.Run, budgetLabel(b.Budget), formatBudgetValue(b.Budget.Metric, b.Actual), result)
+	}
+	report += fmt.Sprintf("\n**%d of %d budgets passed**\n", passed, len(budgets))
+	return report
+}
+
+// ciSummaryOpenMetrics renders the summary figures in OpenMetrics text format
+func (r *BenchmarkRunner) ciSummaryOpenMetrics(budgets []BudgetResult) string {
+	const prefix = "api_latency_optimizer_benchmark_"
+
+	var rps, latency, errors, requests strings.Builder
+	for i := range r.suite.Runs {
+		run := &r.suite.Runs[i]
+		if len(run.Results) == 0 {
+			continue
+		}
+		s := summarizeRun(run)
+		name := openMetricsLabel(run.Name)
+		fmt.Fprintf(&rps, "%srequests_per_second{run=\"%s\"} %g\n", prefix, name, s.RPS)
+		// "quantile" is reserved for summaries, so percentiles get their own label
+		for _, p := range []struct {
+			percentile string
+			value      float64
+		}{{"50", s.P50}, {"95", s.P95}, {"99", s.P99}} {
+			fmt.Fprintf(&latency, "%slatency_milliseconds{run=\"%s\",percentile=\"%s\"} %g\n", prefix, name, p.percentile, p.value)
+		}
+		fmt.Fprintf(&errors, "%serror_ratio{run=\"%s\"} %g\n", prefix, name, s.ErrorRate/100)
+		fmt.Fprintf(&requests, "%srequests_total{run=\"%s\"} %d\n", prefix, name, s.Requests)
+	}
+
+	var sb strings.Builder
+	family := func(name, metricType, unit, help string, samples string) {
+		fmt.Fprintf(&sb, "# TYPE %s%s %s\n", prefix, name, metricType)
+		if unit != "" {
+			fmt.Fprintf(&sb, "# UNIT %s%s %s\n", prefix, name, unit)
+		}
+		fmt.Fprintf(&sb, "# HELP %s%s %s\n", prefix, name, help)
+		sb.WriteString(samples)
+	}
+	family("requests_per_second", "gauge", "", "Mean requests per second across iterations", rps.String())
+	family("latency_milliseconds", "gauge", "milliseconds", "Mean per-iteration latency percentile", latency.String())
+	family("error_ratio", "gauge", "ratio", "Failed requests as a fraction of all requests", errors.String())
+	family("requests", "counter", "", "Requests sent across iterations", requests.String())
+
+	if m := r.suite.Metadata; m != nil {
+		family("build", "info", "", "Code and tool version the suite ran with",
+			fmt.Sprintf("%sbuild_info{git_commit=\"%s\",git_branch=\"%s\",git_dirty=\"%t\",tool_version=\"%s\"} 1\n",
+				prefix, m.GitCommit, openMetricsLabel(m.GitBranch), m.GitDirty, openMetricsLabel(m.ToolVersion)))
+	}
+
+	if len(budgets) > 0 {
+		var samples strings.Builder
+		for _, b := range budgets {
+			passed := 0
+			if b.Passed {
+				passed = 1
+			}
+			fmt.Fprintf(&samples, "%sbudget_passed{run=\"%s\",metric=\"%s\",limit=\"%g\"} %d\n",
+				prefix, openMetricsLabel(b.Run), b.Budget.Metric, b.Budget.Limit, passed)
+		}
+		family("budget_passed", "gauge", "", "Whether the run met the performance budget", samples.String())
+	}
+
+	sb.WriteString("# EOF\n")
+	return sb.String()
+}
+
+// openMetricsLabel escapes a label value
+func openMetricsLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseBudgets tests budget units and validation
+func TestParseBudgets(t *testing.T) {
+	budgets, err := ParseBudgets("p95=250ms, p99=1.5s, ttfb_p95=80, error_rate=1%, rps=50")
+	if err != nil {
+		t.Fatalf("ParseBudgets failed: %v", err)
+	}
+	want := []PerformanceBudget{
+		{Metric: BudgetMetricP95, Limit: 250},
+		{Metric: BudgetMetricP99, Limit: 1500},
+		{Metric: BudgetMetricTTFBP95, Limit: 80},
+		{Metric: BudgetMetricErrorRate, Limit: 1},
+		{Metric: BudgetMetricRPS, Limit: 50},
+	}
+	if len(budgets) != len(want) {
+		t.Fatalf("Expected %d budgets, got %v", len(want), budgets)
+	}
+	for i := range want {
+		if budgets[i] != want[i] {
+			t.Errorf("Budget %d: expected %+v, got %+v", i, want[i], budgets[i])
+		}
+	}
+
+	for _, spec := range []string{"p95", "p90=100", "rps=fast"} {
+		if _, err := ParseBudgets(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// TestSparkline tests scaling between the minimum and maximum
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{10, 20, 30, 40, 50, 60, 70, 80}); got != "_.-~=+*#" {
+		t.Errorf("Expected a rising sparkline, got %q", got)
+	}
+	if got := sparkline([]float64{5, 5, 5}); got != "___" {
+		t.Errorf("Expected a flat sparkline, got %q", got)
+	}
+}
+
+// TestGenerateCISummary tests the Markdown and OpenMetrics artifacts and the
+// GitHub job summary
+func TestGenerateCISummary(t *testing.T) {
+	dir := t.TempDir()
+	stepSummary := filepath.Join(dir, "step_summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", stepSummary)
+
+	start := time.Now()
+	raw := make([]LatencyMetrics, 48)
+	for i := range raw {
+		raw[i] = LatencyMetrics{
+			TotalLatency: time.Duration(10+i) * time.Millisecond,
+			Timestamp:    start.Add(time.Duration(i) * time.Second),
+		}
+	}
+	runner := &BenchmarkRunner{resultDir: dir, suite: &BenchmarkSuite{
+		Name:      "ci",
+		CISummary: true,
+		Metadata:  &RunMetadata{GitCommit: "3f2a9c1d0b7e", GitBranch: "main", GitDirty: true, ToolVersion: "2.0.0"},
+		Budgets: []PerformanceBudget{
+			{Metric: BudgetMetricP95, Limit: 100},
+			{Metric: BudgetMetricErrorRate, Limit: 0.5},
+			{Metric: BudgetMetricRPS, Limit: 10, Run: "other"},
+		},
+		Runs: []BenchmarkRun{{
+			Name: "api",
+			Results: []*BenchmarkResult{
+				{TotalRequests: 100, FailedReqs: 2, RequestsPerSecond: 40, LatencyStats: LatencyStats{P50: 20, P95: 60, P99: 90}, RawMetrics: raw[:24]},
+				{TotalRequests: 100, RequestsPerSecond: 60, LatencyStats: LatencyStats{P50: 30, P95: 80, P99: 110}, RawMetrics: raw[24:]},
+			},
+		}},
+	}}
+
+	budgets := runner.generateCISummary()
+	if len(budgets) != 2 {
+		t.Fatalf("Expected the run-specific budget skipped, got %+v", budgets)
+	}
+	if !budgets[0].Passed || budgets[0].Actual != 70 {
+		t.Errorf("Expected P95 70 ms within budget, got %+v", budgets[0])
+	}
+	if budgets[1].Passed {
+		t.Errorf("Expected 1%% error rate to exceed budget, got %+v", budgets[1])
+	}
+	// Raw metrics give one point per pair of requests, rising steadily
+	if line := sparkline(latencySeries(&runner.suite.Runs[0])); len(line) != sparklineWidth || line[0] != '_' || line[len(line)-1] != '#' {
+		t.Errorf("Expected a rising sparkline of %d points, got %q", sparklineWidth, line)
+	}
+
+	report, err := os.ReadFile(filepath.Join(dir, "CI_SUMMARY.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"| api | 50.00 | 25.00 ms | 70.00 ms | 100.00 ms | 1.00% |",
+		"| api | P95 <= 100.00 ms | 70.00 ms | ✅ pass |",
+		"| api | Error rate <= 0.50% | 1.00% | ❌ fail |",
+		"**1 of 2 budgets passed**",
+		"Code: 3f2a9c1d (main, dirty) with api-optimizer v2.0.0",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("Expected summary to contain %q:\n%s", want, report)
+		}
+	}
+	if summary, err := os.ReadFile(stepSummary); err != nil || !strings.Contains(string(summary), "## Benchmark: ci") {
+		t.Errorf("Expected the summary appended to the job summary, got %q (%v)", summary, err)
+	}
+
+	metrics, err := os.ReadFile(filepath.Join(dir, "benchmark.om"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"api_latency_optimizer_benchmark_latency_milliseconds{run=\"api\",percentile=\"95\"} 70\n",
+		"api_latency_optimizer_benchmark_requests_total{run=\"api\"} 200\n",
+		"api_latency_optimizer_benchmark_budget_passed{run=\"api\",metric=\"p95\",limit=\"100\"} 1\n",
+		"api_latency_optimizer_benchmark_build_info{git_commit=\"3f2a9c1d0b7e\",git_branch=\"main\",git_dirty=\"true\",tool_version=\"2.0.0\"} 1\n",
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("Expected OpenMetrics to contain %q:\n%s", want, metrics)
+		}
+	}
+	if !strings.HasSuffix(string(metrics), "# EOF\n") {
+		t.Error("Expected OpenMetrics output to end with # EOF")
+	}
+}
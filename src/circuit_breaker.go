@@ -44,11 +44,17 @@ type CircuitBreaker struct {
 	failureCount    int64
 	successCount    int64
 	requestCount    int64
+	slowCallCount   int64
 	lastFailTime    int64 // Unix timestamp in nanoseconds
 	lastStateChange int64 // Unix timestamp in nanoseconds
 
+	// When the circuit last opened, and how many times it has opened since
+	// it was last closed; the open timeout and its backoff run from these
+	openedAt         int64
+	consecutiveOpens int64
+
 	// Metrics
-	metrics *CircuitBreakerMetrics
+	metrics *circuitBreakerMetrics
 
 	// Synchronization
 	mutex sync.RWMutex
@@ -60,6 +66,9 @@ type CircuitBreaker struct {
 
 	// Generation counter for state changes
 	generation int64
+
+	// Sliding window of recent outcomes; nil means cumulative counters are used
+	window OutcomeWindow
 }
 
 // CircuitBreakerConfig configures the circuit breaker behavior
@@ -78,6 +87,22 @@ type CircuitBreakerConfig struct {
 	HalfOpenMaxRequests      int `yaml:"half_open_max_requests"`
 	HalfOpenSuccessThreshold int `yaml:"half_open_success_threshold"`
 
+	// Slow-call detection: calls taking at least SlowCallDurationThreshold
+	// count as slow, and the breaker trips once the slow-call rate reaches
+	// SlowCallRateThreshold, even if those calls succeeded. A zero rate
+	// threshold, the default, only counts slow calls and never trips
+	SlowCallDurationThreshold time.Duration `yaml:"slow_call_duration_threshold"`
+	SlowCallRateThreshold     float64       `yaml:"slow_call_rate_threshold"`
+
+	// Sliding window for failure-rate decisions. "count" keeps the last
+	// SlidingWindowSize calls; "time" keeps calls from the last
+	// SlidingWindowDuration split into SlidingWindowBuckets buckets. Empty
+	// keeps the cumulative counters that reset only on state change.
+	SlidingWindowType     string        `yaml:"sliding_window_type"`
+	SlidingWindowSize     int           `yaml:"sliding_window_size"`
+	SlidingWindowDuration time.Duration `yaml:"sliding_window_duration"`
+	SlidingWindowBuckets  int           `yaml:"sliding_window_buckets"`
+
 	// Advanced settings
 	ExponentialBackoff bool          `yaml:"exponential_backoff"`
 	MaxBackoffTime     time.Duration `yaml:"max_backoff_time"`
@@ -87,18 +112,19 @@ type CircuitBreakerConfig struct {
 	EnableMetrics     bool `yaml:"enable_metrics"`
 	MetricsWindowSize int  `yaml:"metrics_window_size"`
 
-	// Custom failure detection
+	// Custom failure detection; ShouldTrip is given a snapshot of the metrics
 	IsFailure  func(error) bool
 	ShouldTrip func(*CircuitBreakerMetrics) bool
 }
 
-// CircuitBreakerMetrics tracks circuit breaker performance
+// CircuitBreakerMetrics is a snapshot of circuit breaker performance
 type CircuitBreakerMetrics struct {
 	// Counters
 	TotalRequests      int64
 	SuccessfulRequests int64
 	FailedRequests     int64
 	RejectedRequests   int64
+	SlowCalls          int64
 
 	// State changes
 	StateChanges  int64
@@ -109,15 +135,36 @@ type CircuitBreakerMetrics struct {
 	// Timing
 	SuccessRate    float64
 	FailureRate    float64
+	SlowCallRate   float64
 	AverageLatency time.Duration
 	LastFailure    time.Time
 	LastSuccess    time.Time
+}
 
-	// Windows for rolling metrics
+// circuitBreakerMetrics accumulates a breaker's metrics. Counters are atomic;
+// the rates, latency and timestamps are guarded by mutex
+type circuitBreakerMetrics struct {
+	totalRequests      atomic.Int64
+	successfulRequests atomic.Int64
+	failedRequests     atomic.Int64
+	rejectedRequests   atomic.Int64
+	slowCalls          atomic.Int64
+	stateChanges       atomic.Int64
+	openCount          atomic.Int64
+	halfOpenCount      atomic.Int64
+	closedCount        atomic.Int64
+
+	mutex          sync.RWMutex
+	successRate    float64
+	failureRate    float64
+	slowCallRate   float64
+	averageLatency time.Duration
+	lastFailure    time.Time
+	lastSuccess    time.Time
+
+	// Windows for rolling metrics; nil without a positive MetricsWindowSize
 	requestWindow *RollingWindow
 	latencyWindow *RollingWindow
-
-	mutex sync.RWMutex
 }
 
 // RollingWindow maintains rolling statistics
@@ -148,6 +195,9 @@ type FailoverManager struct {
 	// Health checking
 	healthChecker *HealthChecker
 
+	// Passive outlier ejection
+	outliers *OutlierDetector
+
 	mutex sync.RWMutex
 }
 
@@ -188,6 +238,7 @@ type FailoverMetrics struct {
 	FallbackActivations int64
 	HealthCheckFailures int64
 	TotalSwitches       int64
+	OutlierSkips        int64 // Switches past an endpoint ejected as an outlier, not counted as failovers
 
 	CurrentServiceIndex int32
 	ServiceHealthStatus map[int]bool
@@ -238,7 +289,8 @@ func NewCircuitBreaker(config *CircuitBreakerConfig) *CircuitBreaker {
 	cb := &CircuitBreaker{
 		config:  config,
 		state:   int32(CircuitClosed),
-		metrics: NewCircuitBreakerMetrics(config.MetricsWindowSize),
+		metrics: newCircuitBreakerMetrics(config.MetricsWindowSize),
+		window:  NewOutcomeWindow(config),
 	}
 
 	return cb
@@ -253,13 +305,13 @@ func (cb *CircuitBreaker) Execute(fn func() (interface{}, error)) (interface{},
 func (cb *CircuitBreaker) ExecuteWithContext(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
 	// Check if we can execute
 	if err := cb.canExecute(); err != nil {
-		atomic.AddInt64(&cb.metrics.RejectedRequests, 1)
+		cb.metrics.rejectedRequests.Add(1)
 		return nil, err
 	}
 
 	// Record request
 	atomic.AddInt64(&cb.requestCount, 1)
-	atomic.AddInt64(&cb.metrics.TotalRequests, 1)
+	cb.metrics.totalRequests.Add(1)
 
 	start := time.Now()
 	result, err := fn()
@@ -267,6 +319,16 @@ func (cb *CircuitBreaker) ExecuteWithContext(ctx context.Context, fn func() (int
 
 	// Record latency
 	cb.metrics.recordLatency(latency)
+	if cb.isSlowCall(latency) {
+		atomic.AddInt64(&cb.slowCallCount, 1)
+		cb.metrics.slowCalls.Add(1)
+	}
+
+	// Record the outcome in the sliding window before evaluating trip conditions
+	if cb.window != nil {
+		failed := err != nil && (cb.config.IsFailure == nil || cb.config.IsFailure(err))
+		cb.window.Record(callOutcome{failed: failed, latency: latency}, cb.config.SlowCallDurationThreshold)
+	}
 
 	// Handle result
 	if err != nil {
@@ -275,9 +337,41 @@ func (cb *CircuitBreaker) ExecuteWithContext(ctx context.Context, fn func() (int
 	}
 
 	cb.onSuccess()
+
+	// A successful but slow call can still trip a closed breaker
+	if cb.isSlowCall(latency) && cb.GetState() == CircuitClosed && cb.shouldTripOnSlowCalls() {
+		cb.transitionToOpen()
+	}
+
 	return result, nil
 }
 
+// isSlowCall reports whether a call's latency reaches the slow-call threshold
+func (cb *CircuitBreaker) isSlowCall(latency time.Duration) bool {
+	return cb.config.SlowCallDurationThreshold > 0 && latency >= cb.config.SlowCallDurationThreshold
+}
+
+// shouldTripOnSlowCalls checks the slow-call rate against its threshold
+func (cb *CircuitBreaker) shouldTripOnSlowCalls() bool {
+	if cb.config.SlowCallDurationThreshold <= 0 || cb.config.SlowCallRateThreshold <= 0 {
+		return false
+	}
+
+	requests := atomic.LoadInt64(&cb.requestCount)
+	slowCalls := atomic.LoadInt64(&cb.slowCallCount)
+	if cb.window != nil {
+		stats := cb.window.Stats()
+		requests = stats.Calls
+		slowCalls = stats.SlowCalls
+	}
+
+	if requests == 0 || requests < int64(cb.config.MinimumRequests) {
+		return false
+	}
+
+	return float64(slowCalls)/float64(requests) >= cb.config.SlowCallRateThreshold
+}
+
 // canExecute checks if the circuit breaker allows execution
 func (cb *CircuitBreaker) canExecute() error {
 	state := CircuitState(atomic.LoadInt32(&cb.state))
@@ -304,10 +398,10 @@ func (cb *CircuitBreaker) canExecute() error {
 // onSuccess handles successful execution
 func (cb *CircuitBreaker) onSuccess() {
 	atomic.AddInt64(&cb.successCount, 1)
-	atomic.AddInt64(&cb.metrics.SuccessfulRequests, 1)
+	cb.metrics.successfulRequests.Add(1)
 
 	cb.metrics.mutex.Lock()
-	cb.metrics.LastSuccess = time.Now()
+	cb.metrics.lastSuccess = time.Now()
 	cb.metrics.mutex.Unlock()
 
 	state := CircuitState(atomic.LoadInt32(&cb.state))
@@ -331,11 +425,11 @@ func (cb *CircuitBreaker) onFailure(err error) {
 	}
 
 	atomic.AddInt64(&cb.failureCount, 1)
-	atomic.AddInt64(&cb.metrics.FailedRequests, 1)
+	cb.metrics.failedRequests.Add(1)
 	atomic.StoreInt64(&cb.lastFailTime, time.Now().UnixNano())
 
 	cb.metrics.mutex.Lock()
-	cb.metrics.LastFailure = time.Now()
+	cb.metrics.lastFailure = time.Now()
 	cb.metrics.mutex.Unlock()
 
 	state := CircuitState(atomic.LoadInt32(&cb.state))
@@ -357,12 +451,18 @@ func (cb *CircuitBreaker) onFailure(err error) {
 func (cb *CircuitBreaker) shouldTrip() bool {
 	// Custom trip condition
 	if cb.config.ShouldTrip != nil {
-		return cb.config.ShouldTrip(cb.metrics)
+		snapshot := cb.metrics.snapshot()
+		return cb.config.ShouldTrip(&snapshot)
 	}
 
-	// Default trip conditions
+	// Default trip conditions, over the sliding window when one is configured
 	requests := atomic.LoadInt64(&cb.requestCount)
 	failures := atomic.LoadInt64(&cb.failureCount)
+	if cb.window != nil {
+		stats := cb.window.Stats()
+		requests = stats.Calls
+		failures = stats.Failures
+	}
 
 	// Must have minimum number of requests
 	if requests < int64(cb.config.MinimumRequests) {
@@ -377,31 +477,34 @@ func (cb *CircuitBreaker) shouldTrip() bool {
 	// Check failure rate
 	if cb.config.FailureRate > 0 {
 		failureRate := float64(failures) / float64(requests)
-		return failureRate >= cb.config.FailureRate
+		if failureRate >= cb.config.FailureRate {
+			return true
+		}
 	}
 
-	return false
+	return cb.shouldTripOnSlowCalls()
 }
 
-// shouldAttemptReset determines if we should attempt to reset from open state
+// shouldAttemptReset determines if we should attempt to reset from open
+// state: OpenTimeout after the circuit opened, growing by BackoffMultiplier
+// each time it reopens without closing in between
 func (cb *CircuitBreaker) shouldAttemptReset() bool {
-	lastFailTime := atomic.LoadInt64(&cb.lastFailTime)
-	if lastFailTime == 0 {
+	openedAt := atomic.LoadInt64(&cb.openedAt)
+	if openedAt == 0 {
 		return true
 	}
 
 	timeout := cb.config.OpenTimeout
 	if cb.config.ExponentialBackoff {
-		// Calculate exponential backoff
-		failures := atomic.LoadInt64(&cb.failureCount)
-		backoffTime := time.Duration(math.Pow(cb.config.BackoffMultiplier, float64(failures))) * cb.config.OpenTimeout
-		if backoffTime > cb.config.MaxBackoffTime {
+		reopens := atomic.LoadInt64(&cb.consecutiveOpens) - 1
+		backoffTime := time.Duration(math.Pow(cb.config.BackoffMultiplier, float64(max(reopens, 0))) * float64(cb.config.OpenTimeout))
+		if cb.config.MaxBackoffTime > 0 && backoffTime > cb.config.MaxBackoffTime {
 			backoffTime = cb.config.MaxBackoffTime
 		}
 		timeout = backoffTime
 	}
 
-	elapsed := time.Duration(time.Now().UnixNano() - lastFailTime)
+	elapsed := time.Duration(time.Now().UnixNano() - openedAt)
 	return elapsed >= timeout
 }
 
@@ -423,12 +526,15 @@ func (cb *CircuitBreaker) transitionToOpen() {
 	defer cb.mutex.Unlock()
 
 	if CircuitState(atomic.LoadInt32(&cb.state)) != CircuitOpen {
+		now := time.Now().UnixNano()
 		atomic.StoreInt32(&cb.state, int32(CircuitOpen))
 		atomic.AddInt64(&cb.generation, 1)
-		atomic.StoreInt64(&cb.lastStateChange, time.Now().UnixNano())
+		atomic.StoreInt64(&cb.lastStateChange, now)
+		atomic.StoreInt64(&cb.openedAt, now)
+		atomic.AddInt64(&cb.consecutiveOpens, 1)
 
-		atomic.AddInt64(&cb.metrics.StateChanges, 1)
-		atomic.AddInt64(&cb.metrics.OpenCount, 1)
+		cb.metrics.stateChanges.Add(1)
+		cb.metrics.openCount.Add(1)
 
 		cb.resetCounts()
 	}
@@ -444,8 +550,8 @@ func (cb *CircuitBreaker) transitionToHalfOpen() {
 		atomic.StoreInt64(&cb.lastStateChange, time.Now().UnixNano())
 		atomic.StoreInt64(&cb.halfOpenStart, time.Now().UnixNano())
 
-		atomic.AddInt64(&cb.metrics.StateChanges, 1)
-		atomic.AddInt64(&cb.metrics.HalfOpenCount, 1)
+		cb.metrics.stateChanges.Add(1)
+		cb.metrics.halfOpenCount.Add(1)
 
 		atomic.StoreInt64(&cb.halfOpenRequests, 0)
 		atomic.StoreInt64(&cb.halfOpenSuccesses, 0)
@@ -460,9 +566,10 @@ func (cb *CircuitBreaker) transitionToClosed() {
 		atomic.StoreInt32(&cb.state, int32(CircuitClosed))
 		atomic.AddInt64(&cb.generation, 1)
 		atomic.StoreInt64(&cb.lastStateChange, time.Now().UnixNano())
+		atomic.StoreInt64(&cb.consecutiveOpens, 0)
 
-		atomic.AddInt64(&cb.metrics.StateChanges, 1)
-		atomic.AddInt64(&cb.metrics.ClosedCount, 1)
+		cb.metrics.stateChanges.Add(1)
+		cb.metrics.closedCount.Add(1)
 
 		cb.resetCounts()
 	}
@@ -473,6 +580,11 @@ func (cb *CircuitBreaker) resetCounts() {
 	atomic.StoreInt64(&cb.failureCount, 0)
 	atomic.StoreInt64(&cb.successCount, 0)
 	atomic.StoreInt64(&cb.requestCount, 0)
+	atomic.StoreInt64(&cb.slowCallCount, 0)
+
+	if cb.window != nil {
+		cb.window.Reset()
+	}
 }
 
 // updateMetrics updates the rolling metrics
@@ -487,10 +599,12 @@ func (cb *CircuitBreaker) updateMetrics() {
 	requests := atomic.LoadInt64(&cb.requestCount)
 	successes := atomic.LoadInt64(&cb.successCount)
 	failures := atomic.LoadInt64(&cb.failureCount)
+	slowCalls := atomic.LoadInt64(&cb.slowCallCount)
 
 	if requests > 0 {
-		cb.metrics.SuccessRate = float64(successes) / float64(requests)
-		cb.metrics.FailureRate = float64(failures) / float64(requests)
+		cb.metrics.successRate = float64(successes) / float64(requests)
+		cb.metrics.failureRate = float64(failures) / float64(requests)
+		cb.metrics.slowCallRate = float64(slowCalls) / float64(requests)
 	}
 
 	// Update rolling windows
@@ -504,26 +618,26 @@ func (cb *CircuitBreaker) GetState() CircuitState {
 	return CircuitState(atomic.LoadInt32(&cb.state))
 }
 
-// GetMetrics returns current circuit breaker metrics
-func (cb *CircuitBreaker) GetMetrics() CircuitBreakerMetrics {
-	cb.metrics.mutex.RLock()
-	defer cb.metrics.mutex.RUnlock()
+// WindowStats returns the sliding window totals, or zero when no window is configured
+func (cb *CircuitBreaker) WindowStats() WindowStats {
+	if cb.window == nil {
+		return WindowStats{}
+	}
+	return cb.window.Stats()
+}
 
-	return CircuitBreakerMetrics{
-		TotalRequests:      atomic.LoadInt64(&cb.metrics.TotalRequests),
-		SuccessfulRequests: atomic.LoadInt64(&cb.metrics.SuccessfulRequests),
-		FailedRequests:     atomic.LoadInt64(&cb.metrics.FailedRequests),
-		RejectedRequests:   atomic.LoadInt64(&cb.metrics.RejectedRequests),
-		StateChanges:       atomic.LoadInt64(&cb.metrics.StateChanges),
-		OpenCount:          atomic.LoadInt64(&cb.metrics.OpenCount),
-		HalfOpenCount:      atomic.LoadInt64(&cb.metrics.HalfOpenCount),
-		ClosedCount:        atomic.LoadInt64(&cb.metrics.ClosedCount),
-		SuccessRate:        cb.metrics.SuccessRate,
-		FailureRate:        cb.metrics.FailureRate,
-		AverageLatency:     cb.metrics.AverageLatency,
-		LastFailure:        cb.metrics.LastFailure,
-		LastSuccess:        cb.metrics.LastSuccess,
+// LastStateChange returns when the breaker last changed state
+func (cb *CircuitBreaker) LastStateChange() time.Time {
+	nanos := atomic.LoadInt64(&cb.lastStateChange)
+	if nanos == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, nanos)
+}
+
+// GetMetrics returns a snapshot of the circuit breaker metrics
+func (cb *CircuitBreaker) GetMetrics() CircuitBreakerMetrics {
+	return cb.metrics.snapshot()
 }
 
 // NewFailoverManager creates a new failover manager
@@ -583,8 +697,19 @@ func (fm *FailoverManager) ExecuteWithContext(ctx context.Context, fn func() (in
 			cb = fm.primary
 		}
 
+		// Skip endpoints ejected as outliers, or still ramping back up
+		outliers := fm.OutlierDetector()
+		if outliers != nil && attempt < maxAttempts-1 && !outliers.Admit(int(serviceIndex)) {
+			fm.skipOutlier()
+			continue
+		}
+
 		// Try to execute
+		start := time.Now()
 		result, err := cb.ExecuteWithContext(ctx, fn)
+		if outliers != nil && err != ErrCircuitOpen && err != ErrHalfOpenLimitExceeded {
+			outliers.Record(int(serviceIndex), time.Since(start), err)
+		}
 		if err == nil {
 			return result, nil
 		}
@@ -596,7 +721,9 @@ func (fm *FailoverManager) ExecuteWithContext(ctx context.Context, fn func() (in
 			if attempt < maxAttempts-1 {
 				fm.attemptFailover()
 				if fm.config.RetryDelay > 0 {
-					time.Sleep(fm.config.RetryDelay)
+					if err := sleepContext(ctx, fm.config.RetryDelay); err != nil {
+						return nil, err
+					}
 				}
 				continue
 			}
@@ -617,8 +744,36 @@ func (fm *FailoverManager) ExecuteWithContext(ctx context.Context, fn func() (in
 	return nil, fmt.Errorf("all services failed, last error: %w", lastErr)
 }
 
+// SetOutlierDetector enables passive outlier ejection; endpoint 0 is the
+// primary and endpoint i is backups[i-1]
+func (fm *FailoverManager) SetOutlierDetector(od *OutlierDetector) {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	fm.outliers = od
+}
+
+// OutlierDetector returns the attached outlier detector, if any
+func (fm *FailoverManager) OutlierDetector() *OutlierDetector {
+	fm.mutex.RLock()
+	defer fm.mutex.RUnlock()
+	return fm.outliers
+}
+
 // attemptFailover tries to switch to the next available service
 func (fm *FailoverManager) attemptFailover() {
+	fm.switchService()
+	atomic.AddInt64(&fm.metrics.FailoverCount, 1)
+}
+
+// skipOutlier moves past a service ejected as an outlier. It is not a
+// failover, since the service itself did not fail the call
+func (fm *FailoverManager) skipOutlier() {
+	fm.switchService()
+	atomic.AddInt64(&fm.metrics.OutlierSkips, 1)
+}
+
+// switchService makes the next service current
+func (fm *FailoverManager) switchService() {
 	fm.mutex.Lock()
 	defer fm.mutex.Unlock()
 
@@ -628,7 +783,6 @@ func (fm *FailoverManager) attemptFailover() {
 	nextIndex := (currentIndex + 1) % int32(1+len(fm.backups))
 
 	atomic.StoreInt32(&fm.currentService, nextIndex)
-	atomic.AddInt64(&fm.metrics.FailoverCount, 1)
 	atomic.AddInt64(&fm.metrics.TotalSwitches, 1)
 
 	fm.metrics.mutex.Lock()
@@ -694,6 +848,11 @@ func (fm *FailoverManager) attemptRecovery() {
 
 	currentIndex := atomic.LoadInt32(&fm.currentService)
 
+	// Don't return to a primary that is still ejected as an outlier
+	if outliers := fm.OutlierDetector(); outliers != nil && outliers.Weight(0) == 0 {
+		return
+	}
+
 	// If not on primary, check if primary is healthy
 	if currentIndex != 0 && fm.isServiceHealthy(fm.primary) {
 		atomic.StoreInt32(&fm.currentService, 0)
@@ -710,19 +869,21 @@ func (fm *FailoverManager) attemptRecovery() {
 
 func DefaultCircuitBreakerConfig() *CircuitBreakerConfig {
 	return &CircuitBreakerConfig{
-		FailureThreshold:         5,
-		FailureRate:              0.5,
-		MinimumRequests:          10,
-		OpenTimeout:              30 * time.Second,
-		HalfOpenTimeout:          5 * time.Second,
-		ResetTimeout:             60 * time.Second,
-		HalfOpenMaxRequests:      3,
-		HalfOpenSuccessThreshold: 2,
-		ExponentialBackoff:       true,
-		MaxBackoffTime:           5 * time.Minute,
-		BackoffMultiplier:        2.0,
-		EnableMetrics:            true,
-		MetricsWindowSize:        100,
+		FailureThreshold:          5,
+		FailureRate:               0.5,
+		MinimumRequests:           10,
+		OpenTimeout:               30 * time.Second,
+		HalfOpenTimeout:           5 * time.Second,
+		ResetTimeout:              60 * time.Second,
+		HalfOpenMaxRequests:       3,
+		HalfOpenSuccessThreshold:  2,
+		ExponentialBackoff:        true,
+		MaxBackoffTime:            5 * time.Minute,
+		BackoffMultiplier:         2.0,
+		SlowCallDurationThreshold: 2 * time.Second,
+		SlowCallRateThreshold:     0, // Opt in; slow upstreams are not failures by default
+		EnableMetrics:             true,
+		MetricsWindowSize:         100,
 	}
 }
 
@@ -740,10 +901,37 @@ func DefaultFailoverConfig() *FailoverConfig {
 	}
 }
 
-func NewCircuitBreakerMetrics(windowSize int) *CircuitBreakerMetrics {
-	return &CircuitBreakerMetrics{
-		requestWindow: NewRollingWindow(windowSize),
-		latencyWindow: NewRollingWindow(windowSize),
+func newCircuitBreakerMetrics(windowSize int) *circuitBreakerMetrics {
+	metrics := &circuitBreakerMetrics{}
+	if windowSize > 0 {
+		metrics.requestWindow = NewRollingWindow(windowSize)
+		metrics.latencyWindow = NewRollingWindow(windowSize)
+	}
+	return metrics
+}
+
+// snapshot copies the metrics; counters may be read a moment apart, but each
+// is consistent on its own
+func (m *circuitBreakerMetrics) snapshot() CircuitBreakerMetrics {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return CircuitBreakerMetrics{
+		TotalRequests:      m.totalRequests.Load(),
+		SuccessfulRequests: m.successfulRequests.Load(),
+		FailedRequests:     m.failedRequests.Load(),
+		RejectedRequests:   m.rejectedRequests.Load(),
+		SlowCalls:          m.slowCalls.Load(),
+		StateChanges:       m.stateChanges.Load(),
+		OpenCount:          m.openCount.Load(),
+		HalfOpenCount:      m.halfOpenCount.Load(),
+		ClosedCount:        m.closedCount.Load(),
+		SuccessRate:        m.successRate,
+		FailureRate:        m.failureRate,
+		SlowCallRate:       m.slowCallRate,
+		AverageLatency:     m.averageLatency,
+		LastFailure:        m.lastFailure,
+		LastSuccess:        m.lastSuccess,
 	}
 }
 
@@ -801,12 +989,14 @@ func (rw *RollingWindow) Average() float64 {
 }
 
 // recordLatency records latency in the metrics
-func (cbm *CircuitBreakerMetrics) recordLatency(latency time.Duration) {
-	if cbm.latencyWindow != nil {
-		cbm.latencyWindow.Add(float64(latency.Nanoseconds()))
-
-		cbm.mutex.Lock()
-		cbm.AverageLatency = time.Duration(cbm.latencyWindow.Average())
-		cbm.mutex.Unlock()
+func (m *circuitBreakerMetrics) recordLatency(latency time.Duration) {
+	if m.latencyWindow == nil {
+		return
 	}
+
+	// Held across both steps so concurrent calls cannot publish a stale average
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.latencyWindow.Add(float64(latency.Nanoseconds()))
+	m.averageLatency = time.Duration(m.latencyWindow.Average())
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerRegistry hands out one circuit breaker per host (or route),
+// creating breakers lazily from a shared default config plus per-key overrides
+type CircuitBreakerRegistry struct {
+	defaultConfig *CircuitBreakerConfig
+	overrides     map[string]*CircuitBreakerConfig
+	breakers      map[string]*CircuitBreaker
+	keyFunc       func(*http.Request) string
+	mu            sync.RWMutex
+}
+
+// CircuitStatus is a point-in-time view of a registered breaker
+type CircuitStatus struct {
+	Key             string    `json:"key"`
+	State           string    `json:"state"`
+	LastStateChange time.Time `json:"last_state_change"`
+
+	TotalRequests      int64         `json:"total_requests"`
+	SuccessfulRequests int64         `json:"successful_requests"`
+	FailedRequests     int64         `json:"failed_requests"`
+	RejectedRequests   int64         `json:"rejected_requests"`
+	SlowCalls          int64         `json:"slow_calls"`
+	StateChanges       int64         `json:"state_changes"`
+	FailureRate        float64       `json:"failure_rate"`
+	SlowCallRate       float64       `json:"slow_call_rate"`
+	AverageLatency     time.Duration `json:"average_latency"`
+}
+
+// NewCircuitBreakerRegistry creates a registry keyed by request host
+func NewCircuitBreakerRegistry(defaultConfig *CircuitBreakerConfig) *CircuitBreakerRegistry {
+	if defaultConfig == nil {
+		defaultConfig = DefaultCircuitBreakerConfig()
+	}
+
+	return &CircuitBreakerRegistry{
+		defaultConfig: defaultConfig,
+		overrides:     make(map[string]*CircuitBreakerConfig),
+		breakers:      make(map[string]*CircuitBreaker),
+		keyFunc:       hostKey,
+	}
+}
+
+// hostKey keys breakers by the request's host:port
+func hostKey(req *http.Request) string {
+	return req.URL.Host
+}
+
+// SetKeyFunc changes how requests map to breakers, e.g. to key by route
+func (r *CircuitBreakerRegistry) SetKeyFunc(keyFunc func(*http.Request) string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keyFunc = keyFunc
+}
+
+// SetOverride sets a per-key config; it applies to breakers created afterwards
+func (r *CircuitBreakerRegistry) SetOverride(key string, config *CircuitBreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[key] = config
+}
+
+// Get returns the breaker for key, creating it on first use
+func (r *CircuitBreakerRegistry) Get(key string) *CircuitBreaker {
+	r.mu.RLock()
+	cb, exists := r.breakers[key]
+	r.mu.RUnlock()
+	if exists {
+		return cb
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another goroutine may have created it while we waited for the lock
+	if cb, exists := r.breakers[key]; exists {
+		return cb
+	}
+
+	config := r.defaultConfig
+	if override, ok := r.overrides[key]; ok {
+		config = override
+	}
+
+	cb = NewCircuitBreaker(config)
+	r.breakers[key] = cb
+	return cb
+}
+
+// ForRequest returns the breaker responsible for req
+func (r *CircuitBreakerRegistry) ForRequest(req *http.Request) *CircuitBreaker {
+	r.mu.RLock()
+	keyFunc := r.keyFunc
+	r.mu.RUnlock()
+
+	return r.Get(keyFunc(req))
+}
+
+// Keys returns the keys of all created breakers in sorted order
+func (r *CircuitBreakerRegistry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]string, 0, len(r.breakers))
+	for key := range r.breakers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Statuses returns the state and metrics of every breaker
+func (r *CircuitBreakerRegistry) Statuses() []CircuitStatus {
+	keys := r.Keys()
+	statuses := make([]CircuitStatus, 0, len(keys))
+
+	for _, key := range keys {
+		cb := r.Get(key)
+		metrics := cb.GetMetrics()
+
+		statuses = append(statuses, CircuitStatus{
+			Key:                key,
+			State:              cb.GetState().String(),
+			LastStateChange:    cb.LastStateChange(),
+			TotalRequests:      metrics.TotalRequests,
+			SuccessfulRequests: metrics.SuccessfulRequests,
+			FailedRequests:     metrics.FailedRequests,
+			RejectedRequests:   metrics.RejectedRequests,
+			SlowCalls:          metrics.SlowCalls,
+			StateChanges:       metrics.StateChanges,
+			FailureRate:        metrics.FailureRate,
+			SlowCallRate:       metrics.SlowCallRate,
+			AverageLatency:     metrics.AverageLatency,
+		})
+	}
+
+	return statuses
+}
+
+// HandleCircuits serves the /circuits listing as JSON
+func (r *CircuitBreakerRegistry) HandleCircuits(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Statuses())
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBreakerConfig returns a config that trips after three failures
+func testBreakerConfig() *CircuitBreakerConfig {
+	config := DefaultCircuitBreakerConfig()
+	config.FailureThreshold = 3
+	config.MinimumRequests = 3
+	config.OpenTimeout = time.Hour
+	config.ExponentialBackoff = false
+	return config
+}
+
+// TestCircuitBreakerRegistry tests lazy per-host breakers with overrides
+func TestCircuitBreakerRegistry(t *testing.T) {
+	registry := NewCircuitBreakerRegistry(testBreakerConfig())
+
+	override := testBreakerConfig()
+	override.FailureThreshold = 1
+	override.MinimumRequests = 1
+	registry.SetOverride("fragile.example.com", override)
+
+	reqA, _ := http.NewRequest("GET", "http://api.example.com/a", nil)
+	reqB, _ := http.NewRequest("GET", "http://api.example.com/b", nil)
+	if registry.ForRequest(reqA) != registry.ForRequest(reqB) {
+		t.Error("Requests to the same host should share a breaker")
+	}
+
+	failing := func() (interface{}, error) { return nil, errors.New("boom") }
+
+	// One failure opens the overridden host but not the default one
+	registry.Get("fragile.example.com").Execute(failing)
+	registry.Get("api.example.com").Execute(failing)
+
+	if state := registry.Get("fragile.example.com").GetState(); state != CircuitOpen {
+		t.Errorf("Expected overridden breaker to be OPEN, got %s", state)
+	}
+	if state := registry.Get("api.example.com").GetState(); state != CircuitClosed {
+		t.Errorf("Expected default breaker to be CLOSED, got %s", state)
+	}
+
+	// The /circuits endpoint lists every breaker
+	recorder := httptest.NewRecorder()
+	registry.HandleCircuits(recorder, httptest.NewRequest("GET", "/circuits", nil))
+
+	var statuses []CircuitStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Failed to decode /circuits: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Key != "api.example.com" || statuses[1].State != "OPEN" {
+		t.Errorf("Unexpected circuit listing: %+v", statuses)
+	}
+}
+
+// TestCircuitBreakerCountWindow tests that a recent burst trips a long-lived breaker
+func TestCircuitBreakerCountWindow(t *testing.T) {
+	config := testBreakerConfig()
+	config.FailureThreshold = 1000 // Only the rate should trip
+	config.FailureRate = 0.5
+	config.MinimumRequests = 10
+	config.SlidingWindowType = SlidingWindowCount
+	config.SlidingWindowSize = 10
+	cb := NewCircuitBreaker(config)
+
+	succeeding := func() (interface{}, error) { return "ok", nil }
+	failing := func() (interface{}, error) { return nil, errors.New("boom") }
+
+	// A long healthy history must not dilute a recent burst
+	for i := 0; i < 1000; i++ {
+		cb.Execute(succeeding)
+	}
+	for i := 0; i < 4; i++ {
+		cb.Execute(failing)
+	}
+	if cb.GetState() != CircuitClosed {
+		t.Fatalf("Expected CLOSED at 40%% failures in window, got %s", cb.GetState())
+	}
+
+	cb.Execute(failing)
+	if cb.GetState() != CircuitOpen {
+		t.Errorf("Expected OPEN at 50%% failures in window, got %s", cb.GetState())
+	}
+}
+
+// TestCircuitBreakerTimeWindow tests that old buckets fall out of a time window
+func TestCircuitBreakerTimeWindow(t *testing.T) {
+	window := newTimeWindow(10*time.Second, 10)
+	now := time.Unix(1000, 0)
+	window.now = func() time.Time { return now }
+
+	window.Record(callOutcome{failed: true}, 0)
+	window.Record(callOutcome{latency: 3 * time.Second}, 2*time.Second)
+
+	now = now.Add(5 * time.Second)
+	window.Record(callOutcome{}, 0)
+
+	stats := window.Stats()
+	if stats.Calls != 3 || stats.Failures != 1 || stats.SlowCalls != 1 {
+		t.Errorf("Unexpected window stats: %+v", stats)
+	}
+
+	// After the window passes only the later call remains
+	now = now.Add(6 * time.Second)
+	stats = window.Stats()
+	if stats.Calls != 1 || stats.Failures != 0 {
+		t.Errorf("Expected expired buckets to be dropped, got %+v", stats)
+	}
+}
+
+// TestCircuitBreakerSlowCalls tests tripping on latency degradation without errors
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	config := testBreakerConfig()
+	config.MinimumRequests = 4
+	config.SlowCallDurationThreshold = 10 * time.Millisecond
+	config.SlowCallRateThreshold = 0.5
+	cb := NewCircuitBreaker(config)
+
+	fast := func() (interface{}, error) { return "ok", nil }
+	slow := func() (interface{}, error) {
+		time.Sleep(15 * time.Millisecond)
+		return "ok", nil
+	}
+
+	cb.Execute(fast)
+	cb.Execute(fast)
+	cb.Execute(slow)
+	if cb.GetState() != CircuitClosed {
+		t.Fatalf("Expected CLOSED below minimum requests, got %s", cb.GetState())
+	}
+
+	cb.Execute(slow)
+	if cb.GetState() != CircuitOpen {
+		t.Errorf("Expected OPEN at 50%% slow calls, got %s", cb.GetState())
+	}
+	if _, err := cb.Execute(fast); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen within the open timeout, got %v", err)
+	}
+
+	metrics := cb.GetMetrics()
+	if metrics.SlowCalls != 2 || metrics.FailedRequests != 0 {
+		t.Errorf("Expected 2 slow calls and no failures, got %d slow and %d failed", metrics.SlowCalls, metrics.FailedRequests)
+	}
+}
+
+// TestOutlierDetection tests ejecting an endpoint that errors more than its peers
+func TestOutlierDetection(t *testing.T) {
+	config := DefaultOutlierDetectionConfig()
+	config.MinRequests = 10
+	config.EvaluationInterval = time.Hour // Evaluate explicitly
+	config.BaseEjectionTime = 30 * time.Second
+	config.RampUpDuration = 60 * time.Second
+
+	od := NewOutlierDetector(3, config)
+	now := time.Unix(1000, 0)
+	od.now = func() time.Time { return now }
+
+	var ejected []OutlierEvent
+	od.SetOnEject(func(event OutlierEvent) { ejected = append(ejected, event) })
+
+	boom := errors.New("boom")
+	for i := 0; i < 20; i++ {
+		od.Record(0, 10*time.Millisecond, nil)
+		od.Record(1, 12*time.Millisecond, nil)
+		od.Record(2, 10*time.Millisecond, boom)
+	}
+
+	od.Evaluate()
+	if len(ejected) != 1 || ejected[0].Endpoint != 2 || ejected[0].Reason != "error_rate" {
+		t.Fatalf("Expected endpoint 2 ejected for error rate, got %+v", ejected)
+	}
+	if od.Weight(2) != 0 || od.Weight(0) != 1 {
+		t.Errorf("Expected ejected endpoint to get no traffic, weights %v and %v", od.Weight(2), od.Weight(0))
+	}
+
+	// Halfway through the ramp the endpoint gets half its traffic back
+	now = now.Add(30*time.Second + 30*time.Second)
+	if weight := od.Weight(2); weight != 0.5 {
+		t.Errorf("Expected weight 0.5 during ramp-up, got %v", weight)
+	}
+
+	now = now.Add(30 * time.Second)
+	if weight := od.Weight(2); weight != 1 {
+		t.Errorf("Expected full weight after ramp-up, got %v", weight)
+	}
+}
+
+// TestOutlierEjectionDecay tests that healthy time forgives past ejections, so
+// a later ejection's cooldown no longer grows with them
+func TestOutlierEjectionDecay(t *testing.T) {
+	config := DefaultOutlierDetectionConfig()
+	config.MinRequests = 10
+	config.EvaluationInterval = time.Hour // Evaluate explicitly
+	config.BaseEjectionTime = 30 * time.Second
+	config.MaxEjectionTime = time.Hour
+	config.EjectionDecay = 5 * time.Minute
+
+	od := NewOutlierDetector(3, config)
+	now := time.Unix(1000, 0)
+	od.now = func() time.Time { return now }
+
+	eject := func() OutlierEvent {
+		t.Helper()
+		boom := errors.New("boom")
+		for i := 0; i < 20; i++ {
+			od.Record(0, 10*time.Millisecond, nil)
+			od.Record(1, 10*time.Millisecond, nil)
+			od.Record(2, 10*time.Millisecond, boom)
+		}
+		events := od.Evaluate()
+		if len(events) != 1 || events[0].Endpoint != 2 {
+			t.Fatalf("Expected endpoint 2 ejected, got %+v", events)
+		}
+		return events[0]
+	}
+
+	eject()
+	now = now.Add(time.Minute)
+	if event := eject(); event.Ejections != 2 || event.EjectedUntil != now.Add(time.Minute) {
+		t.Fatalf("Expected a second ejection of 1m, got %d until %v", event.Ejections, event.EjectedUntil)
+	}
+
+	// One period past the cooldown forgives one ejection
+	now = now.Add(time.Minute + 5*time.Minute)
+	od.Evaluate()
+	if ejections := od.Statuses()[2].Ejections; ejections != 1 {
+		t.Errorf("Expected 1 ejection after one healthy period, got %d", ejections)
+	}
+
+	// A partial period forgives nothing more, the rest of it does
+	now = now.Add(4 * time.Minute)
+	od.Evaluate()
+	if ejections := od.Statuses()[2].Ejections; ejections != 1 {
+		t.Errorf("Expected 1 ejection part way through a period, got %d", ejections)
+	}
+	now = now.Add(time.Minute)
+	od.Evaluate()
+	if ejections := od.Statuses()[2].Ejections; ejections != 0 {
+		t.Errorf("Expected no ejections after two healthy periods, got %d", ejections)
+	}
+
+	if event := eject(); event.Ejections != 1 || event.EjectedUntil != now.Add(30*time.Second) {
+		t.Errorf("Expected the base cooldown once forgiven, got %d until %v", event.Ejections, event.EjectedUntil)
+	}
+}
+
+// TestFailoverSkipsOutliers tests that moving past an ejected endpoint is
+// counted apart from failovers
+func TestFailoverSkipsOutliers(t *testing.T) {
+	config := DefaultFailoverConfig()
+	config.AutoRecovery = false
+	config.EnableFallback = false
+	fm := NewFailoverManager(NewCircuitBreaker(testBreakerConfig()), []*CircuitBreaker{NewCircuitBreaker(testBreakerConfig())}, config)
+
+	od := NewOutlierDetector(2, nil)
+	od.endpoints[0].ejections = 1
+	od.endpoints[0].ejectedUntil = time.Now().Add(time.Hour)
+	fm.SetOutlierDetector(od)
+
+	result, err := fm.Execute(func() (interface{}, error) { return "ok", nil })
+	if err != nil || result != "ok" {
+		t.Fatalf("Expected the backup to serve the call, got %v, %v", result, err)
+	}
+
+	if skips := atomic.LoadInt64(&fm.metrics.OutlierSkips); skips != 1 {
+		t.Errorf("Expected 1 outlier skip, got %d", skips)
+	}
+	if failovers := atomic.LoadInt64(&fm.metrics.FailoverCount); failovers != 0 {
+		t.Errorf("Expected no failovers, got %d", failovers)
+	}
+	if switches := atomic.LoadInt64(&fm.metrics.TotalSwitches); switches != 1 {
+		t.Errorf("Expected 1 switch, got %d", switches)
+	}
+}
+
+// TestCircuitBreakerConcurrentMetrics tests that counters stay exact while
+// many goroutines execute, read metrics and evaluate ShouldTrip; run with -race
+func TestCircuitBreakerConcurrentMetrics(t *testing.T) {
+	config := testBreakerConfig()
+	config.SlowCallDurationThreshold = 0
+	config.ShouldTrip = func(metrics *CircuitBreakerMetrics) bool {
+		return metrics.FailedRequests > metrics.TotalRequests
+	}
+	cb := NewCircuitBreaker(config)
+
+	const workers, calls = 32, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				cb.Execute(func() (interface{}, error) {
+					if (w+i)%2 == 0 {
+						return nil, errors.New("boom")
+					}
+					return "ok", nil
+				})
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				if metrics := cb.GetMetrics(); metrics.FailureRate < 0 || metrics.FailureRate > 1 {
+					t.Errorf("Expected a failure rate between 0 and 1, got %v", metrics.FailureRate)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	metrics := cb.GetMetrics()
+	if metrics.TotalRequests != workers*calls {
+		t.Errorf("Expected %d requests, got %d", workers*calls, metrics.TotalRequests)
+	}
+	if metrics.SuccessfulRequests != workers*calls/2 || metrics.FailedRequests != workers*calls/2 {
+		t.Errorf("Expected %d successes and failures, got %d and %d", workers*calls/2, metrics.SuccessfulRequests, metrics.FailedRequests)
+	}
+	if metrics.RejectedRequests != 0 {
+		t.Errorf("Expected no rejected requests, got %d", metrics.RejectedRequests)
+	}
+}
+
+// TestCircuitBreakerWithoutWindow tests that a zero metrics window disables
+// the rolling metrics instead of panicking
+func TestCircuitBreakerWithoutWindow(t *testing.T) {
+	config := testBreakerConfig()
+	config.MetricsWindowSize = 0
+	cb := NewCircuitBreaker(config)
+
+	if _, err := cb.Execute(func() (interface{}, error) { return "ok", nil }); err != nil {
+		t.Fatalf("Expected the call to succeed, got %v", err)
+	}
+	if metrics := cb.GetMetrics(); metrics.TotalRequests != 1 || metrics.AverageLatency != 0 {
+		t.Errorf("Expected one request and no average latency, got %+v", metrics)
+	}
+}
+
+// TestCircuitBreakerDefaultIgnoresSlowCalls tests that the default config counts slow calls without tripping
+func TestCircuitBreakerDefaultIgnoresSlowCalls(t *testing.T) {
+	config := DefaultCircuitBreakerConfig()
+	config.SlowCallDurationThreshold = time.Millisecond
+	config.MinimumRequests = 2
+	cb := NewCircuitBreaker(config)
+
+	for i := 0; i < 5; i++ {
+		cb.Execute(func() (interface{}, error) {
+			time.Sleep(2 * time.Millisecond)
+			return nil, nil
+		})
+	}
+
+	if state := cb.GetState(); state != CircuitClosed {
+		t.Errorf("Expected circuit to stay closed on slow successes, got %v", state)
+	}
+	if metrics := cb.GetMetrics(); metrics.SlowCalls != 5 {
+		t.Errorf("Expected 5 slow calls counted, got %d", metrics.SlowCalls)
+	}
+}
+
+// TestOptimizedClientRetriesInsideBreaker tests that retries count as one
+// breaker call and the upstream's own error reaches the caller
+func TestOptimizedClientRetriesInsideBreaker(t *testing.T) {
+	if DefaultOptimizedClientConfig().CircuitBreakerEnabled {
+		t.Error("Expected circuit breakers to be opt-in")
+	}
+
+	config := DefaultOptimizedClientConfig()
+	config.CacheConfig.Enabled = false
+	config.MonitoringConfig.Enabled = false
+	config.CircuitBreakerEnabled = true
+	config.CircuitBreaker = testBreakerConfig()
+	config.RetryBackoff = time.Millisecond
+	client, err := NewOptimizedClient(config)
+	if err != nil {
+		t.Fatalf("NewOptimizedClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:1/", nil)
+	_, err = client.Do(&OptimizedRequest{Request: req})
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the dial error, got %v", err)
+	}
+
+	breaker := client.CircuitBreakers().ForRequest(req)
+	if metrics := breaker.GetMetrics(); metrics.TotalRequests != 1 {
+		t.Errorf("Expected one breaker call for all retries, got %d", metrics.TotalRequests)
+	}
+	if state := breaker.GetState(); state != CircuitClosed {
+		t.Errorf("Expected breaker to stay CLOSED, got %s", state)
+	}
+}
+
+// TestCircuitBreakerOpenBackoff tests that the open timeout runs from the
+// trip and doubles each time a half-open probe fails
+func TestCircuitBreakerOpenBackoff(t *testing.T) {
+	config := testBreakerConfig()
+	config.FailureThreshold = 1
+	config.MinimumRequests = 1
+	config.OpenTimeout = 50 * time.Millisecond
+	config.ExponentialBackoff = true
+	config.BackoffMultiplier = 2
+	cb := NewCircuitBreaker(config)
+
+	failing := func() (interface{}, error) { return nil, errors.New("boom") }
+	cb.Execute(failing)
+	if _, err := cb.Execute(failing); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen right after tripping, got %v", err)
+	}
+
+	// The first timeout passes and the half-open probe fails again
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cb.Execute(failing); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected a half-open probe after the open timeout, got %v", err)
+	}
+
+	// Reopened, it now waits twice as long
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cb.Execute(failing); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen during the doubled timeout, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := cb.Execute(failing); errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected a half-open probe after the doubled timeout, got %v", err)
+	}
+}
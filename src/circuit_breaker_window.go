@@ -0,0 +1,224 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Sliding window types for CircuitBreakerConfig.SlidingWindowType
+const (
+	SlidingWindowCount = "count" // Last N calls
+	SlidingWindowTime  = "time"  // Calls within the last duration
+)
+
+// callOutcome is a single call observed by the breaker
+type callOutcome struct {
+	failed  bool
+	latency time.Duration
+}
+
+// WindowStats summarizes the calls currently inside a sliding window
+type WindowStats struct {
+	Calls     int64
+	Failures  int64
+	SlowCalls int64
+}
+
+// FailureRate returns the fraction of failed calls in the window
+func (s WindowStats) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// SlowCallRate returns the fraction of slow calls in the window
+func (s WindowStats) SlowCallRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.SlowCalls) / float64(s.Calls)
+}
+
+// OutcomeWindow aggregates recent call outcomes for trip decisions
+type OutcomeWindow interface {
+	// Record adds an outcome; calls at or above slowThreshold count as slow
+	Record(outcome callOutcome, slowThreshold time.Duration)
+	Stats() WindowStats
+	Reset()
+}
+
+// NewOutcomeWindow builds the window described by config, or nil when the
+// breaker should use its legacy cumulative counters
+func NewOutcomeWindow(config *CircuitBreakerConfig) OutcomeWindow {
+	switch config.SlidingWindowType {
+	case SlidingWindowCount:
+		size := config.SlidingWindowSize
+		if size <= 0 {
+			size = 100
+		}
+		return newCountWindow(size)
+	case SlidingWindowTime:
+		duration := config.SlidingWindowDuration
+		if duration <= 0 {
+			duration = time.Minute
+		}
+		buckets := config.SlidingWindowBuckets
+		if buckets <= 0 {
+			buckets = 10
+		}
+		return newTimeWindow(duration, buckets)
+	default:
+		return nil
+	}
+}
+
+// countWindow keeps the outcomes of the last N calls in a ring buffer
+type countWindow struct {
+	failed []bool
+	slow   []bool
+	index  int
+	full   bool
+	stats  WindowStats
+	mu     sync.Mutex
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{
+		failed: make([]bool, size),
+		slow:   make([]bool, size),
+	}
+}
+
+func (w *countWindow) Record(outcome callOutcome, slowThreshold time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Evict the outcome being overwritten from the running totals
+	if w.full {
+		w.stats.Calls--
+		if w.failed[w.index] {
+			w.stats.Failures--
+		}
+		if w.slow[w.index] {
+			w.stats.SlowCalls--
+		}
+	}
+
+	slow := slowThreshold > 0 && outcome.latency >= slowThreshold
+	w.failed[w.index] = outcome.failed
+	w.slow[w.index] = slow
+
+	w.stats.Calls++
+	if outcome.failed {
+		w.stats.Failures++
+	}
+	if slow {
+		w.stats.SlowCalls++
+	}
+
+	w.index = (w.index + 1) % len(w.failed)
+	if w.index == 0 {
+		w.full = true
+	}
+}
+
+func (w *countWindow) Stats() WindowStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+func (w *countWindow) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.failed {
+		w.failed[i] = false
+		w.slow[i] = false
+	}
+	w.index = 0
+	w.full = false
+	w.stats = WindowStats{}
+}
+
+// timeBucket aggregates outcomes for one slice of a time window
+type timeBucket struct {
+	start int64 // Bucket start, in bucket-width units since the epoch
+	stats WindowStats
+}
+
+// timeWindow keeps per-bucket totals covering the last duration
+type timeWindow struct {
+	buckets []timeBucket
+	width   time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func newTimeWindow(duration time.Duration, buckets int) *timeWindow {
+	width := duration / time.Duration(buckets)
+	if width <= 0 {
+		width = time.Millisecond
+	}
+
+	return &timeWindow{
+		buckets: make([]timeBucket, buckets),
+		width:   width,
+		now:     time.Now,
+	}
+}
+
+// currentSlot returns the bucket-width slot number for the current time
+func (w *timeWindow) currentSlot() int64 {
+	return w.now().UnixNano() / int64(w.width)
+}
+
+func (w *timeWindow) Record(outcome callOutcome, slowThreshold time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := w.currentSlot()
+	bucket := &w.buckets[slot%int64(len(w.buckets))]
+	if bucket.start != slot {
+		// The bucket holds data from a previous lap around the ring
+		*bucket = timeBucket{start: slot}
+	}
+
+	bucket.stats.Calls++
+	if outcome.failed {
+		bucket.stats.Failures++
+	}
+	if slowThreshold > 0 && outcome.latency >= slowThreshold {
+		bucket.stats.SlowCalls++
+	}
+}
+
+func (w *timeWindow) Stats() WindowStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := w.currentSlot()
+	oldest := slot - int64(len(w.buckets)) + 1
+
+	var stats WindowStats
+	for _, bucket := range w.buckets {
+		if bucket.start < oldest || bucket.start > slot {
+			continue
+		}
+		stats.Calls += bucket.stats.Calls
+		stats.Failures += bucket.stats.Failures
+		stats.SlowCalls += bucket.stats.SlowCalls
+	}
+
+	return stats
+}
+
+func (w *timeWindow) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.buckets {
+		w.buckets[i] = timeBucket{}
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// Error classes reported in LatencyMetrics.ErrorType and client error stats
+const (
+	ErrorTypeTimeout        = "timeout"
+	ErrorTypeCanceled       = "canceled"
+	ErrorTypeDNS            = "dns"
+	ErrorTypeConnection     = "connection"
+	ErrorTypeHTTP2GoAway    = "http2_goaway"       // The server closed an HTTP/2 connection with GOAWAY
+	ErrorTypeHTTP2Reset     = "http2_stream_reset" // The server reset the request's HTTP/2 stream
+	ErrorTypeTLSHandshake   = "tls_handshake"      // Handshake aborted, including a client certificate rejected by the server
+	ErrorTypeTLSCertificate = "tls_certificate"    // Server certificate failed verification
+	ErrorTypeTLSPin         = "tls_pin"            // Server key matched none of the host's pins
+	ErrorTypeRateLimited    = "rate_limited"
+	ErrorTypeAuth           = "auth"
+	ErrorTypeCheck          = "check"       // The response failed a plugin check
+	ErrorTypeScript         = "script"      // The request script threw or timed out
+	ErrorTypeRedirect       = "redirect"    // Too many redirects, or a final 3xx the redirect policy fails
+	ErrorTypeSpendLimit     = "spend_limit" // Held back by the spend guard
+	ErrorTypeOther          = "other"
+)
+
+// ClientCertConfig identifies a client certificate, either as PEM files or as
+// a PKCS#12 bundle
+type ClientCertConfig struct {
+	CertFile       string `yaml:"cert_file" json:"cert_file"`
+	KeyFile        string `yaml:"key_file" json:"key_file"`
+	PKCS12File     string `yaml:"pkcs12_file" json:"pkcs12_file"`
+	PKCS12Password string `yaml:"pkcs12_password" json:"-"` // May reference an environment variable as $NAME
+}
+
+// empty reports whether no certificate is configured
+func (c ClientCertConfig) empty() bool {
+	return c.CertFile == "" && c.KeyFile == "" && c.PKCS12File == ""
+}
+
+// ClientTLSConfig configures TLS for outgoing connections, including mutual
+// TLS. Hosts selects a different client certificate per upstream; keys are
+// hostnames or wildcards like *.example.com, and unmatched hosts use the
+// top-level certificate, if any
+type ClientTLSConfig struct {
+	ClientCertConfig `yaml:",inline"`
+
+	CAFile             string                      `yaml:"ca_file" json:"ca_file"`                           // PEM bundle trusted in addition to the system roots
+	CAFiles            []string                    `yaml:"ca_files" json:"ca_files"`                         // Further bundles, e.g. one per private PKI
+	ExcludeSystemRoots bool                        `yaml:"exclude_system_roots" json:"exclude_system_roots"` // Trust only the configured bundles
+	ServerName         string                      `yaml:"server_name" json:"server_name"`
+	InsecureSkipVerify bool                        `yaml:"insecure_skip_verify" json:"insecure_skip_verify"` // Disables chain and hostname checks; pins still apply
+	Hosts              map[string]ClientCertConfig `yaml:"hosts" json:"hosts"`
+
+	// SPKI pins per server name, as base64 SHA-256 digests of the subject
+	// public key, optionally prefixed with "sha256/". Keys match like Hosts,
+	// plus "*" for every host. A connection succeeds when any certificate in
+	// the chain matches any pin. Pins match on the TLS server name, so targets
+	// addressed by IP need ServerName set
+	Pins map[string][]string `yaml:"pins" json:"pins"`
+}
+
+// ErrPinMismatch reports a server whose chain matched none of its pins
+var ErrPinMismatch = errors.New("tls: certificate pin mismatch")
+
+// insecureWarning prints the InsecureSkipVerify warning once per process
+var insecureWarning sync.Once
+
+// warnInsecureTLS prints a warning that certificate verification is off
+func warnInsecureTLS() {
+	insecureWarning.Do(func() {
+		fmt.Fprintln(os.Stderr, "╔══════════════════════════════════════════════════════════════════╗")
+		fmt.Fprintln(os.Stderr, "║ WARNING: TLS certificate verification is DISABLED                ║")
+		fmt.Fprintln(os.Stderr, "║ Any server can impersonate the target and read requests and      ║")
+		fmt.Fprintln(os.Stderr, "║ credentials. Use ca_file or pins for private PKI instead.        ║")
+		fmt.Fprintln(os.Stderr, "╚══════════════════════════════════════════════════════════════════╝")
+	})
+}
+
+// clientCertHostKey carries the request host to certificate selection
+type clientCertHostKey struct{}
+
+// clientCertSelector picks the client certificate for a handshake
+type clientCertSelector struct {
+	fallback *tls.Certificate
+	hosts    map[string]*tls.Certificate
+}
+
+// NewClientTLSConfig builds a tls.Config from config. Transports using it
+// must be wrapped with WithClientCertHost for per-host certificates to apply
+func NewClientTLSConfig(config *ClientTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.InsecureSkipVerify {
+		warnInsecureTLS()
+	}
+
+	caFiles := config.CAFiles
+	if config.CAFile != "" {
+		caFiles = append([]string{config.CAFile}, caFiles...)
+	}
+	if len(caFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || config.ExcludeSystemRoots {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range caFiles {
+			data, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	} else if config.ExcludeSystemRoots {
+		return nil, errors.New("exclude_system_roots requires ca_file or ca_files")
+	}
+
+	if len(config.Pins) > 0 {
+		pins, err := newPinSet(config.Pins)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = pins.verify
+	}
+
+	selector := &clientCertSelector{hosts: make(map[string]*tls.Certificate)}
+	if !config.ClientCertConfig.empty() {
+		cert, err := loadClientCertificate(config.ClientCertConfig)
+		if err != nil {
+			return nil, err
+		}
+		selector.fallback = cert
+	}
+	for host, certConfig := range config.Hosts {
+		cert, err := loadClientCertificate(certConfig)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate for %s: %w", host, err)
+		}
+		selector.hosts[strings.ToLower(host)] = cert
+	}
+	if selector.fallback != nil || len(selector.hosts) > 0 {
+		tlsConfig.GetClientCertificate = selector.certificate
+	}
+
+	return tlsConfig, nil
+}
+
+// loadClientCertificate reads a PEM pair or PKCS#12 bundle
+func loadClientCertificate(config ClientCertConfig) (*tls.Certificate, error) {
+	if config.PKCS12File != "" {
+		data, err := os.ReadFile(config.PKCS12File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PKCS#12 bundle: %w", err)
+		}
+		blocks, err := pkcs12.ToPEM(data, os.ExpandEnv(config.PKCS12Password))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PKCS#12 bundle: %w", err)
+		}
+		var certPEM, keyPEM []byte
+		for _, block := range blocks {
+			if block.Type == "CERTIFICATE" {
+				certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+			} else {
+				keyPEM = append(keyPEM, pem.EncodeToMemory(block)...)
+			}
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PKCS#12 bundle: %w", err)
+		}
+		return &cert, nil
+	}
+
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("client certificate requires both cert_file and key_file, or pkcs12_file")
+	}
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// certificate implements tls.Config.GetClientCertificate. An empty
+// certificate tells the server none is available
+func (s *clientCertSelector) certificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	host, _ := info.Context().Value(clientCertHostKey{}).(string)
+	if cert := s.forHost(host); cert != nil {
+		return cert, nil
+	}
+	return &tls.Certificate{}, nil
+}
+
+// forHost returns the certificate for host: an exact match, then the
+// closest wildcard, then the fallback
+func (s *clientCertSelector) forHost(host string) *tls.Certificate {
+	host = strings.ToLower(host)
+	if cert, ok := s.hosts[host]; ok {
+		return cert
+	}
+	for suffix := host; ; {
+		dot := strings.IndexByte(suffix, '.')
+		if dot < 0 {
+			break
+		}
+		suffix = suffix[dot+1:]
+		if cert, ok := s.hosts["*."+suffix]; ok {
+			return cert
+		}
+	}
+	return s.fallback
+}
+
+// pinSet holds the accepted SPKI digests per server name pattern
+type pinSet map[string]map[[sha256.Size]byte]bool
+
+// newPinSet decodes the configured pins
+func newPinSet(config map[string][]string) (pinSet, error) {
+	pins := make(pinSet, len(config))
+	for host, encoded := range config {
+		digests := make(map[[sha256.Size]byte]bool, len(encoded))
+		for _, pin := range encoded {
+			raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin %q for %s: want a base64 SHA-256 digest", pin, host)
+			}
+			digests[[sha256.Size]byte(raw)] = true
+		}
+		if len(digests) == 0 {
+			return nil, fmt.Errorf("no pins given for %s", host)
+		}
+		pins[strings.ToLower(host)] = digests
+	}
+	return pins, nil
+}
+
+// forHost returns the pins for host: an exact match, then the closest
+// wildcard, then "*"
+func (p pinSet) forHost(host string) map[[sha256.Size]byte]bool {
+	host = strings.ToLower(host)
+	if pins, ok := p[host]; ok {
+		return pins
+	}
+	for suffix := host; ; {
+		dot := strings.IndexByte(suffix, '.')
+		if dot < 0 {
+			break
+		}
+		suffix = suffix[dot+1:]
+		if pins, ok := p["*."+suffix]; ok {
+			return pins
+		}
+	}
+	return p["*"]
+}
+
+// verify implements tls.Config.VerifyConnection. It runs after chain
+// verification, or instead of it with InsecureSkipVerify. Only certificates
+// the handshake vouches for count: those in a verified chain, or without
+// verification just the leaf, whose key signed the handshake. Extra
+// certificates the server sent prove nothing and are ignored
+func (p pinSet) verify(state tls.ConnectionState) error {
+	pins := p.forHost(state.ServerName)
+	if pins == nil {
+		return nil
+	}
+
+	if len(state.VerifiedChains) > 0 {
+		for _, chain := range state.VerifiedChains {
+			for _, cert := range chain {
+				if pins[SPKIFingerprint(cert)] {
+					return nil
+				}
+			}
+		}
+	} else if len(state.PeerCertificates) > 0 && pins[SPKIFingerprint(state.PeerCertificates[0])] {
+		return nil
+	}
+
+	var leaf string
+	if len(state.PeerCertificates) > 0 {
+		digest := SPKIFingerprint(state.PeerCertificates[0])
+		leaf = " (leaf sha256/" + base64.StdEncoding.EncodeToString(digest[:]) + ")"
+	}
+	return fmt.Errorf("%w for %s%s", ErrPinMismatch, state.ServerName, leaf)
+}
+
+// SPKIFingerprint returns the SHA-256 digest of cert's subject public key
+// info, the value pinned in ClientTLSConfig.Pins
+func SPKIFingerprint(cert *x509.Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// clientCertTransport tags each request with its host for certificate
+// selection. The transport pools connections per host, so a connection
+// dialed with one host's certificate never serves another host
+type clientCertTransport struct {
+	next http.RoundTripper
+}
+
+// WithClientCertHost wraps next so GetClientCertificate sees the request host
+func WithClientCertHost(next http.RoundTripper) http.RoundTripper {
+	return &clientCertTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *clientCertTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), clientCertHostKey{}, req.URL.Hostname())
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
+
+// ClassifyRequestError buckets a request error so TLS handshake and
+// certificate failures are reported apart from other connection errors
+func ClassifyRequestError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.Is(err, ErrPinMismatch) {
+		return ErrorTypeTLSPin
+	}
+	if errors.As(err, &verifyErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return ErrorTypeTLSCertificate
+	}
+
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || strings.Contains(err.Error(), "tls: ") {
+		return ErrorTypeTLSHandshake
+	}
+
+	if errors.Is(err, ErrRateLimited) {
+		return ErrorTypeRateLimited
+	}
+	if errors.Is(err, errTooManyRedirects) {
+		return ErrorTypeRedirect
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorTypeCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTypeTimeout
+	}
+
+	if event, ok := parseHTTP2Event(err); ok {
+		switch event.Type {
+		case HTTP2EventGoAway:
+			return ErrorTypeHTTP2GoAway
+		case HTTP2EventStreamReset:
+			return ErrorTypeHTTP2Reset
+		default:
+			return ErrorTypeConnection
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorTypeDNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTypeTimeout
+	}
+	var opErr *net.OpError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return ErrorTypeConnection
+	}
+	if errors.As(err, &urlErr) && strings.Contains(urlErr.Err.Error(), "EOF") {
+		return ErrorTypeConnection
+	}
+	return ErrorTypeOther
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPKCS12 is a self-signed P-256 certificate for CN apilo-p12 with its
+// key, protected by the password "test"
+const testPKCS12 = `
+MIIDggIBAzCCA0gGCSqGSIb3DQEHAaCCAzkEggM1MIIDMTCCAicGCSqGSIb3DQEHBqCCAhgwggIU
+AgEAMIICDQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQMwDgQIJOnTBZNhAisCAggAgIIB4EltD4e6
+F7Scrb6SwKTBlESMQO6QqoKlYrmYH5Nk2+2psLPh5vwOs55A6fJ1Wji16JiVORGvk6TyeOZuAgxk
+2EpbiFqZhU8UsGzpAUB9MQTgfnRMePL8ybsKUi8xCIcuNhBET7+ddD29jyl7DJAwTlutGikhlvQv
+aZvO7s3sPqAnxPu5TTb7tB7tGy/1EibdDvAVP4qhSw3kQ0MhWII+KbhYxUdNwtp7W2O1tofDT4fM
+8MEHwCqYEq4FH6ojCJoi0d1VXGTMS2qNAdP0xtOY5+3II9OSZMA52NuMRyQg07cNc30kTJeWGvSm
+QPmeOeODBMg9Q3g1/1suSLRqcpqaqUXx4We+LmGuSautZv/9kIPZw1ZKVFjgTpE52vrp+GmG7wf4
+/J6jb9iCH/q5lrCK6yYdmGmrm//QQP7ZBsMKr2xdoJD5i2kCqF9elDOloWyi0eHnJ82w7cn2ejK8
+OxIPpqWSRwycwB9lCiURPnl3lDz3U4ztMZG+hlJEVH59pn9cvFLIL/lyOH4kN1WaYXJAdp4Nnvy8
+YBEDLxlxON2f6Sef0bdMbWEhMUGnz1zLfHzbuYPRG6U0yhk3dMIog/4brWy6qmNhnMtJz+hloYQx
+6GpygELhHcEI6la7YdV4JZ2LeDCCAQIGCSqGSIb3DQEHAaCB9ASB8TCB7jCB6wYLKoZIhvcNAQwK
+AQKggbQwgbEwHAYKKoZIhvcNAQwBAzAOBAjIz6O1ZvMNbQICCAAEgZAsrr7ypO2Xst38tA3yLC5Y
+qupiNdt0inKfHZ7NlQSRL7wZHSmXjB6ennWybur9lof+5Geg5rKkRDWVcy9X+y2x0OGXqMioBlO2
+aplVIS56Wl9dgjFiKRjfbpiud30xINbr+CzJD0vb0p1ORUHfBNPQlFx9Gl7tsuBvTuKkGCwFFfls
+FqB8NxUux+EvrPYzeQUxJTAjBgkqhkiG9w0BCRUxFgQUeJL+WYOzZ0c8Dfh1Ap/KeXs57bYwMTAh
+MAkGBSsOAwIaBQAEFIw8W9RJvdBbCRq3cUW9513QKZUpBAiAj9MmPkbYpwICCAA=`
+
+// testPKI is a throwaway CA with helpers to issue certificates as PEM files
+type testPKI struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+}
+
+// newTestPKI creates a CA and writes its certificate to ca.pem
+func newTestPKI(t *testing.T) *testPKI {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "apilo test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	pki := &testPKI{dir: t.TempDir(), caCert: cert, caKey: key}
+	pki.caFile = filepath.Join(pki.dir, "ca.pem")
+	os.WriteFile(pki.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	return pki
+}
+
+// issue signs a certificate for name and returns its cert and key files
+func (p *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.caCert, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatalf("Failed to issue %s: %v", name, err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(p.dir, name+".pem")
+	keyFile := filepath.Join(p.dir, name+"-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+// newMTLSServer starts a server requiring client certificates from pki that
+// echoes the client certificate's common name
+func newMTLSServer(t *testing.T, pki *testPKI) *httptest.Server {
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load server certificate: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(pki.caCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	// Rejected handshakes are expected; keep them out of the test output
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestClientTLSPerHostCertificate tests that each host gets its configured certificate
+func TestClientTLSPerHostCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	server := newMTLSServer(t, pki)
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	defaultCert, defaultKey := pki.issue(t, "default-client", x509.ExtKeyUsageClientAuth)
+	localCert, localKey := pki.issue(t, "localhost-client", x509.ExtKeyUsageClientAuth)
+
+	client, err := NewHTTP2Client(&HTTP2ClientConfig{
+		MaxConnectionsPerHost: 2,
+		TLS: &ClientTLSConfig{
+			ClientCertConfig: ClientCertConfig{CertFile: defaultCert, KeyFile: defaultKey},
+			CAFile:           pki.caFile,
+			Hosts: map[string]ClientCertConfig{
+				"LOCALHOST": {CertFile: localCert, KeyFile: localKey},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTP2Client failed: %v", err)
+	}
+
+	for host, expected := range map[string]string{"127.0.0.1": "default-client", "localhost": "localhost-client"} {
+		req, _ := http.NewRequest("GET", "https://"+host+":"+port+"/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request to %s failed: %v", host, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != expected {
+			t.Errorf("%s: expected certificate %s, server saw %s", host, expected, body)
+		}
+	}
+}
+
+// TestClientCertSelectorWildcard tests exact, wildcard and fallback matching
+func TestClientCertSelectorWildcard(t *testing.T) {
+	exact, wildcard, fallback := &tls.Certificate{}, &tls.Certificate{}, &tls.Certificate{}
+	selector := &clientCertSelector{
+		fallback: fallback,
+		hosts: map[string]*tls.Certificate{
+			"api.example.com":    exact,
+			"*.example.com":      wildcard,
+			"*.internal.corp.io": fallback,
+		},
+	}
+
+	if selector.forHost("API.example.com") != exact {
+		t.Error("Expected the exact match")
+	}
+	if selector.forHost("eu.api.example.com") != wildcard {
+		t.Error("Expected the wildcard match")
+	}
+	if selector.forHost("example.org") != fallback {
+		t.Error("Expected the fallback certificate")
+	}
+}
+
+// TestBenchmarkerTLSErrorClassification tests that handshake and certificate
+// failures are reported as distinct error types
+func TestBenchmarkerTLSErrorClassification(t *testing.T) {
+	pki := newTestPKI(t)
+	server := newMTLSServer(t, pki)
+	clientCert, clientKey := pki.issue(t, "bench-client", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name      string
+		tls       *ClientTLSConfig
+		errorType string
+	}{
+		{"untrusted server", &ClientTLSConfig{ClientCertConfig: ClientCertConfig{CertFile: clientCert, KeyFile: clientKey}}, ErrorTypeTLSCertificate},
+		{"missing client certificate", &ClientTLSConfig{CAFile: pki.caFile}, ErrorTypeTLSHandshake},
+		{"mutual TLS", &ClientTLSConfig{ClientCertConfig: ClientCertConfig{CertFile: clientCert, KeyFile: clientKey}, CAFile: pki.caFile}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			benchmarker := NewBenchmarker(BenchmarkConfig{
+				TargetURL:     server.URL,
+				TotalRequests: 3,
+				Concurrency:   1,
+				TLS:           tt.tls,
+			})
+			result, err := benchmarker.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if tt.errorType == "" {
+				if result.SuccessfulReqs != 3 || len(result.ErrorTypes) != 0 {
+					t.Errorf("Expected every request to succeed, got %d ok, errors %v", result.SuccessfulReqs, result.ErrorTypes)
+				}
+				return
+			}
+			if result.ErrorTypes[tt.errorType] != 3 {
+				t.Errorf("Expected 3 %s errors, got %v", tt.errorType, result.ErrorTypes)
+			}
+		})
+	}
+}
+
+// TestClientTLSConfigErrors tests PKCS#12 loading and invalid configs
+func TestClientTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	bundle, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(testPKCS12, "\n", ""))
+	p12File := filepath.Join(dir, "client.p12")
+	os.WriteFile(p12File, bundle, 0600)
+
+	t.Setenv("APILO_TEST_P12_PASSWORD", "test")
+	cert, err := loadClientCertificate(ClientCertConfig{PKCS12File: p12File, PKCS12Password: "$APILO_TEST_P12_PASSWORD"})
+	if err != nil {
+		t.Fatalf("PKCS#12 load failed: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if leaf.Subject.CommonName != "apilo-p12" {
+		t.Errorf("Expected CN apilo-p12, got %s", leaf.Subject.CommonName)
+	}
+
+	if _, err := loadClientCertificate(ClientCertConfig{PKCS12File: p12File, PKCS12Password: "wrong"}); err == nil {
+		t.Error("Expected an error for a wrong PKCS#12 password")
+	}
+	if _, err := NewClientTLSConfig(&ClientTLSConfig{ClientCertConfig: ClientCertConfig{CertFile: "cert.pem"}}); err == nil {
+		t.Error("Expected an error for a certificate without a key")
+	}
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{
+		TargetURL: "https://localhost",
+		TLS:       &ClientTLSConfig{CAFile: filepath.Join(dir, "missing.pem")},
+	})
+	if _, err := benchmarker.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "CA bundle") {
+		t.Errorf("Expected Run to report the invalid TLS config, got %v", err)
+	}
+}
+
+// TestBenchmarkerCertificatePinning tests SPKI pins with and without chain verification
+func TestBenchmarkerCertificatePinning(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "server.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	digest := SPKIFingerprint(server.Certificate())
+	goodPin := "sha256/" + base64.StdEncoding.EncodeToString(digest[:])
+	badPin := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name      string
+		tls       *ClientTLSConfig
+		errorType string
+	}{
+		{"matching pin", &ClientTLSConfig{CAFiles: []string{caFile}, ServerName: "example.com", Pins: map[string][]string{"example.com": {badPin, goodPin}}}, ""},
+		{"mismatched pin", &ClientTLSConfig{CAFiles: []string{caFile}, ServerName: "example.com", Pins: map[string][]string{"*.com": {badPin}}}, ErrorTypeTLSPin},
+		{"pin on another host", &ClientTLSConfig{CAFiles: []string{caFile}, ServerName: "example.com", Pins: map[string][]string{"other.example.com": {badPin}}}, ""},
+		{"insecure with pin", &ClientTLSConfig{InsecureSkipVerify: true, Pins: map[string][]string{"*": {goodPin}}}, ""},
+		{"insecure with wrong pin", &ClientTLSConfig{InsecureSkipVerify: true, Pins: map[string][]string{"*": {badPin}}}, ErrorTypeTLSPin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewBenchmarker(BenchmarkConfig{
+				TargetURL:     server.URL,
+				TotalRequests: 2,
+				Concurrency:   1,
+				TLS:           tt.tls,
+			}).Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if tt.errorType == "" && result.SuccessfulReqs != 2 {
+				t.Errorf("Expected success, got errors %v", result.ErrorTypes)
+			}
+			if tt.errorType != "" && result.ErrorTypes[tt.errorType] != 2 {
+				t.Errorf("Expected 2 %s errors, got %v", tt.errorType, result.ErrorTypes)
+			}
+			if result.TLSInsecure != tt.tls.InsecureSkipVerify {
+				t.Errorf("Expected TLSInsecure %v", tt.tls.InsecureSkipVerify)
+			}
+		})
+	}
+
+	// A pinned certificate the server merely appends proves nothing
+	pki := newTestPKI(t)
+	certFile, _ := pki.issue(t, "unrelated", x509.ExtKeyUsageServerAuth)
+	certPEM, _ := os.ReadFile(certFile)
+	block, _ := pem.Decode(certPEM)
+	unrelated, _ := x509.ParseCertificate(block.Bytes)
+	pins, _ := newPinSet(map[string][]string{"*": {goodPin}})
+	for name, state := range map[string]tls.ConnectionState{
+		"insecure": {ServerName: "example.com", PeerCertificates: []*x509.Certificate{unrelated, server.Certificate()}},
+		"verified": {
+			ServerName:       "example.com",
+			PeerCertificates: []*x509.Certificate{unrelated, server.Certificate()},
+			VerifiedChains:   [][]*x509.Certificate{{unrelated, pki.caCert}},
+		},
+	} {
+		if err := pins.verify(state); !errors.Is(err, ErrPinMismatch) {
+			t.Errorf("%s: expected ErrPinMismatch for an appended pinned certificate, got %v", name, err)
+		}
+	}
+
+	if _, err := NewClientTLSConfig(&ClientTLSConfig{Pins: map[string][]string{"*": {"not-a-digest"}}}); err == nil {
+		t.Error("Expected an error for an invalid pin")
+	}
+	if _, err := NewClientTLSConfig(&ClientTLSConfig{ExcludeSystemRoots: true}); err == nil {
+		t.Error("Expected an error when excluding system roots without a bundle")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Names of the two sides of a cold path run
+const (
+	PathOptimized = "optimized"
+	PathCold      = "cold"
+)
+
+// applyColdPath makes transport pay the full cost of every request: a new
+// connection each time, resolved by Go's resolver, which keeps no cache and
+// bypasses the C library's, and a full TLS handshake without resumption.
+// A caching stub resolver on the host, if any, still answers lookups
+func applyColdPath(transport *http.Transport) {
+	transport.DisableKeepAlives = true
+	if transport.DialContext == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, Resolver: &net.Resolver{PreferGo: true}}
+		transport.DialContext = dialer.DialContext
+	}
+	transport.TLSClientConfig.ClientSessionCache = nil
+	transport.TLSClientConfig.SessionTicketsDisabled = true
+}
+
+// ColdPathComparison quantifies what connection reuse and warm setup save:
+// the same workload with the run's settings, primed, against the cold path
+type ColdPathComparison struct {
+	Comparison TargetComparison `json:"comparison"` // Optimized is the baseline, cold the candidate
+
+	// Median and P95 latency the optimized path saves, in milliseconds and
+	// as a fraction of the cold path's
+	SavedMedianMs float64 `json:"saved_median_ms"`
+	SavedP95Ms    float64 `json:"saved_p95_ms"`
+	SavedShare    float64 `json:"saved_share"`
+
+	// Median DNS+connect+TLS time of a cold request
+	ColdSetupMs float64 `json:"cold_setup_ms"`
+}
+
+// executeColdPathRun runs the workload interleaved on the optimized and cold
+// paths and compares them. The optimized path's results serve as the run's
+// results
+func (r *BenchmarkRunner) executeColdPathRun(ctx context.Context, runIndex int, run *BenchmarkRun) error {
+	// One spend guard, so its budget caps both paths together
+	var spend *SpendGuard
+	if run.Config.SpendGuard != nil {
+		spend = NewSpendGuard(run.Config.SpendGuard)
+	}
+	var auth AuthProvider
+	if run.Config.Auth != nil {
+		provider, err := NewAuthProvider(run.Config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to configure auth: %w", err)
+		}
+		auth = provider
+	}
+	// Each path gets its own limiter so neither waits on the other's tokens
+	limiters := make(map[string]*RateLimiter, 2)
+	newBenchmarker := func(path string, iteration int) *Benchmarker {
+		config := run.Config
+		config.Seed = IterationSeed(run.Config.Seed, iteration)
+		if path == PathCold {
+			config.ColdPath = true
+		} else {
+			config.KeepAlive = true
+			config.PrimeConnections = true
+		}
+		// Per-request samples are needed for the significance test
+		config.IncludeRawMetrics = true
+		benchmarker := NewBenchmarker(config)
+		if run.Config.RateLimit != nil {
+			if limiters[path] == nil {
+				limiters[path] = NewRateLimiter(run.Config.RateLimit)
+			}
+			benchmarker.SetRateLimiter(limiters[path])
+		}
+		if auth != nil {
+			benchmarker.SetAuthProvider(auth)
+		}
+		if spend != nil {
+			benchmarker.SetSpendGuard(spend)
+		}
+		r.watchProgress(benchmarker, runIndex)
+		return benchmarker
+	}
+
+	names := []string{PathOptimized, PathCold}
+	fmt.Printf("Cold path mode: optimized (keep-alive, primed) vs cold (new connection, DNS lookup and TLS handshake per request)\n")
+
+	if run.WarmupIterations > 0 {
+		fmt.Printf("Warmup: Running %d iterations per path...\n", run.WarmupIterations)
+		for i := 0; i < run.WarmupIterations; i++ {
+			for _, name := range names {
+				_, err := newBenchmarker(name, -(i + 1)).Run(ctx)
+				if ctx.Err() != nil {
+					return fmt.Errorf("warmup interrupted: %w", ctx.Err())
+				}
+				if err != nil {
+					fmt.Printf("Warmup iteration %d for %s failed: %v\n", i+1, name, err)
+				}
+			}
+		}
+		fmt.Printf("Warmup complete\n\n")
+	}
+
+	samples := map[string]*targetSamples{PathOptimized: {}, PathCold: {}}
+	run.PathResults = make(map[string][]*BenchmarkResult, len(names))
+
+	err := r.executeInterleavedRounds(ctx, runIndex, run, names, run.PathResults, samples, newBenchmarker)
+
+	run.Results = run.PathResults[PathOptimized]
+	if len(run.Results) == 0 || len(run.PathResults[PathCold]) == 0 {
+		return err
+	}
+
+	alpha := run.SignificanceLevel
+	if alpha <= 0 {
+		alpha = DefaultSignificanceLevel
+	}
+	run.ColdPathComparison = compareColdPath(samples, alpha)
+	printColdPathComparison(run.ColdPathComparison)
+
+	return err
+}
+
+// compareColdPath tests the cold path's latencies against the optimized path's
+func compareColdPath(samples map[string]*targetSamples, alpha float64) *ColdPathComparison {
+	comparison := &ColdPathComparison{
+		Comparison:  compareTargets([]string{PathOptimized, PathCold}, samples, alpha).Candidates[0],
+		ColdSetupMs: CalculateStats(samples[PathCold].setup).P50,
+	}
+	tc := comparison.Comparison
+	comparison.SavedMedianMs = tc.CandidateMedian - tc.BaselineMedian
+	comparison.SavedP95Ms = tc.CandidateP95 - tc.BaselineP95
+	if tc.CandidateMedian > 0 {
+		comparison.SavedShare = comparison.SavedMedianMs / tc.CandidateMedian
+	}
+	return comparison
+}
+
+// describe summarizes the comparison in one sentence
+func (c *ColdPathComparison) describe() string {
+	tc := c.Comparison
+	if !tc.Significant {
+		return fmt.Sprintf("No significant difference between the optimized and cold paths (p=%.4f); connection setup is not where the latency is", tc.PValue)
+	}
+	return fmt.Sprintf("The optimized path saves %.2f ms at the median (%.1f%% of cold latency) and %.2f ms at P95; a cold request spends %.2f ms on DNS, connect and TLS (p=%.4f)",
+		c.SavedMedianMs, c.SavedShare*100, c.SavedP95Ms, c.ColdSetupMs, tc.PValue)
+}
+
+// printColdPathComparison prints both paths side by side
+func printColdPathComparison(c *ColdPathComparison) {
+	tc := c.Comparison
+	fmt.Printf("\n--- Cold Path vs Optimized ---\n")
+	fmt.Printf("%-10s median %.2f ms, P95 %.2f ms, %.2f req/s\n", PathOptimized, tc.BaselineMedian, tc.BaselineP95, tc.BaselineRPS)
+	fmt.Printf("%-10s median %.2f ms, P95 %.2f ms, %.2f req/s\n", PathCold, tc.CandidateMedian, tc.CandidateP95, tc.CandidateRPS)
+	for _, pc := range tc.Phases {
+		fmt.Printf("  %-20s %8.2f ms -> %8.2f ms\n", PhaseLabel(pc.Phase), pc.BaselineMedian, pc.CandidateMedian)
+	}
+	fmt.Printf("%s\n", c.describe())
+}
+
+// coldPathSection renders the comparison as markdown
+func coldPathSection(c *ColdPathComparison) string {
+	tc := c.Comparison
+	section := "### Cold Path vs Optimized\n\n"
+	section += "The same workload with connection reuse and primed connections, and with a new connection, DNS lookup and full TLS handshake per request.\n\n"
+	section += "| Path | Median (ms) | P95 (ms) | RPS | Error Rate |\n"
+	section += "|------|-------------|----------|-----|------------|\n"
+	section += fmt.Sprintf("| %s | %.2f | %.2f | %.2f | %.2f%% |\n", PathOptimized, tc.BaselineMedian, tc.BaselineP95, tc.BaselineRPS, tc.BaselineErrors*100)
+	section += fmt.Sprintf("| %s | %.2f | %.2f | %.2f | %.2f%% |\n\n", PathCold, tc.CandidateMedian, tc.CandidateP95, tc.CandidateRPS, tc.CandidateErrors*100)
+
+	if len(tc.Phases) > 0 {
+		section += "| Phase | Optimized (ms) | Cold (ms) |\n"
+		section += "|-------|----------------|-----------|\n"
+		for _, pc := range tc.Phases {
+			section += fmt.Sprintf("| %s | %.2f | %.2f |\n", PhaseLabel(pc.Phase), pc.BaselineMedian, pc.CandidateMedian)
+		}
+		section += "\n"
+	}
+	section += fmt.Sprintf("%s.\n\n", c.describe())
+	return section
+}
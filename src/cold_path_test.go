@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestColdPathRun tests that the cold path opens a connection per request
+// while the optimized path reuses primed ones
+func TestColdPathRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// localhost rather than 127.0.0.1, so cold requests resolve a name
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	suite := &BenchmarkSuite{Name: "cold_path_test", OutputDir: t.TempDir()}
+	runner := NewBenchmarkRunner(suite)
+	run := &BenchmarkRun{
+		Name:       "cold",
+		Config:     BenchmarkConfig{TargetURL: target, TotalRequests: 20, Concurrency: 2},
+		Iterations: 1,
+		ColdPath:   true,
+	}
+
+	if err := runner.executeRun(context.Background(), 0, run); err != nil {
+		t.Fatalf("Cold path run failed: %v", err)
+	}
+
+	if len(run.Results) != 1 || len(run.PathResults[PathCold]) != 1 {
+		t.Fatalf("Expected one result per path, got %d optimized and %d cold", len(run.Results), len(run.PathResults[PathCold]))
+	}
+	if run.Results[0].Prime == nil || run.PathResults[PathCold][0].Prime != nil {
+		t.Errorf("Expected only the optimized path primed")
+	}
+	if cold := run.PathResults[PathCold][0]; cold.DNSStats.Samples != 20 || cold.ConnectionStats.Samples != 20 {
+		t.Errorf("Expected a DNS lookup and connection per cold request, got %d and %d", cold.DNSStats.Samples, cold.ConnectionStats.Samples)
+	}
+	if optimized := run.Results[0]; optimized.ConnectionStats.Samples > 2 {
+		t.Errorf("Expected the optimized path to reuse its primed connections, got %d connects", optimized.ConnectionStats.Samples)
+	}
+
+	comparison := run.ColdPathComparison
+	if comparison == nil || comparison.Comparison.BaselineSamples != 20 || comparison.Comparison.CandidateSamples != 20 {
+		t.Fatalf("Expected 20 samples per path, got %+v", comparison)
+	}
+	if section := coldPathSection(comparison); !strings.Contains(section, "| cold |") || !strings.Contains(section, "| optimized |") {
+		t.Errorf("Unexpected report section:\n%s", section)
+	}
+}
+
+// TestApplyColdPath tests that reuse and TLS resumption are disabled
+func TestApplyColdPath(t *testing.T) {
+	transport := &http.Transport{TLSClientConfig: &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(8)}}
+	applyColdPath(transport)
+
+	if !transport.DisableKeepAlives || transport.DialContext == nil {
+		t.Errorf("Expected keep-alive disabled and a resolver-backed dialer")
+	}
+	if transport.TLSClientConfig.ClientSessionCache != nil || !transport.TLSClientConfig.SessionTicketsDisabled {
+		t.Errorf("Expected TLS session resumption disabled")
+	}
+}
+
+// TestCompareColdPath tests the savings computed from synthetic samples
+func TestCompareColdPath(t *testing.T) {
+	optimized, cold := &targetSamples{}, &targetSamples{}
+	for i := 0; i < 50; i++ {
+		optimized.latencies = append(optimized.latencies, 10+float64(i%5))
+		cold.latencies = append(cold.latencies, 40+float64(i%5))
+		cold.setup = append(cold.setup, 25)
+	}
+
+	comparison := compareColdPath(map[string]*targetSamples{PathOptimized: optimized, PathCold: cold}, DefaultSignificanceLevel)
+	if comparison.SavedMedianMs != 30 || comparison.ColdSetupMs != 25 {
+		t.Errorf("Expected 30 ms saved and 25 ms cold setup, got %.2f and %.2f", comparison.SavedMedianMs, comparison.ColdSetupMs)
+	}
+	if comparison.SavedShare != 30.0/42 || !comparison.Comparison.Significant {
+		t.Errorf("Expected a significant 71%% saving, got %.3f (p=%.4f)", comparison.SavedShare, comparison.Comparison.PValue)
+	}
+	if !strings.Contains(comparison.describe(), "saves 30.00 ms") {
+		t.Errorf("Unexpected summary: %s", comparison.describe())
+	}
+}
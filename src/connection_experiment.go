@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Below this share of latency, connection reuse is not worth tuning for
+const negligibleConnectionShare = 0.05
+
+// ConnectionVariant is one keep-alive and idle pool setting tried by the
+// connection experiment
+type ConnectionVariant struct {
+	Name                string        `json:"name"`
+	KeepAlive           bool          `json:"keep_alive"`
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout,omitempty"`
+}
+
+// String describes the variant's settings
+func (v ConnectionVariant) String() string {
+	if !v.KeepAlive {
+		return "keep-alive off"
+	}
+	return fmt.Sprintf("keep-alive on, %d idle/host, %s idle timeout", v.MaxIdleConnsPerHost, v.IdleConnTimeout)
+}
+
+// DefaultConnectionVariants compares the default pool sized to concurrency
+// with keep-alive off, a quarter-sized pool and a short idle timeout
+func DefaultConnectionVariants(concurrency int) []ConnectionVariant {
+	pool := max(concurrency, 1)
+	variants := []ConnectionVariant{
+		{Name: "keepalive", KeepAlive: true, MaxIdleConnsPerHost: pool, IdleConnTimeout: 90 * time.Second},
+		{Name: "no_keepalive"},
+	}
+	if pool > 1 {
+		variants = append(variants, ConnectionVariant{Name: "small_pool", KeepAlive: true, MaxIdleConnsPerHost: max(pool/4, 1), IdleConnTimeout: 90 * time.Second})
+	}
+	return append(variants, ConnectionVariant{Name: "short_idle", KeepAlive: true, MaxIdleConnsPerHost: pool, IdleConnTimeout: 100 * time.Millisecond})
+}
+
+// ConnectionVariantResult is the outcome of one variant across all rounds
+type ConnectionVariantResult struct {
+	Variant   ConnectionVariant `json:"variant"`
+	Samples   int               `json:"samples"`
+	MedianMs  float64           `json:"median_ms"`
+	P95Ms     float64           `json:"p95_ms"`
+	RPS       float64           `json:"rps"`
+	ErrorRate float64           `json:"error_rate"`
+
+	// Fraction of successful requests that opened a new connection, the
+	// median DNS+connect+TLS time of those, and setup time as a fraction of
+	// all request latency
+	NewConnectionRate float64 `json:"new_connection_rate"`
+	SetupMedianMs     float64 `json:"setup_median_ms"`
+	SetupShare        float64 `json:"setup_share"`
+}
+
+// ConnectionExperiment reports how much latency connection establishment
+// accounts for and which variant to use
+type ConnectionExperiment struct {
+	Variants []ConnectionVariantResult `json:"variants"`
+
+	// Median latency keep-alive off adds over the fastest keep-alive variant,
+	// as milliseconds and as a fraction of the keep-alive off median, with the
+	// Mann-Whitney U p-value of the difference
+	ConnectionOverheadMs float64 `json:"connection_overhead_ms"`
+	ConnectionShare      float64 `json:"connection_share"`
+	PValue               float64 `json:"p_value"`
+	Significant          bool    `json:"significant"`
+
+	Recommended     string   `json:"recommended"`
+	Recommendations []string `json:"recommendations"`
+}
+
+// executeConnectionRun runs the same workload under every connection variant.
+// Rounds are interleaved like an A/B run, and the first variant's results
+// serve as the run's results
+func (r *BenchmarkRunner) executeConnectionRun(ctx context.Context, runIndex int, run *BenchmarkRun) error {
+	var limiter *RateLimiter
+	if run.Config.RateLimit != nil {
+		limiter = NewRateLimiter(run.Config.RateLimit)
+	}
+	// One spend guard, so its budget caps every variant together
+	var spend *SpendGuard
+	if run.Config.SpendGuard != nil {
+		spend = NewSpendGuard(run.Config.SpendGuard)
+	}
+	var auth AuthProvider
+	if run.Config.Auth != nil {
+		provider, err := NewAuthProvider(run.Config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to configure auth: %w", err)
+		}
+		auth = provider
+	}
+
+	names := make([]string, len(run.ConnectionVariants))
+	variants := make(map[string]ConnectionVariant, len(run.ConnectionVariants))
+	for i, variant := range run.ConnectionVariants {
+		if _, duplicate := variants[variant.Name]; duplicate || variant.Name == "" {
+			return fmt.Errorf("connection variant names must be unique and non-empty, got %q", variant.Name)
+		}
+		names[i] = variant.Name
+		variants[variant.Name] = variant
+	}
+	newBenchmarker := func(name string, iteration int) *Benchmarker {
+		variant := variants[name]
+		config := run.Config
+		config.Seed = IterationSeed(run.Config.Seed, iteration)
+		config.KeepAlive = variant.KeepAlive
+		config.MaxIdleConnsPerHost = variant.MaxIdleConnsPerHost
+		config.IdleConnTimeout = variant.IdleConnTimeout
+		// Connection setup is what the variants differ in, so it is measured
+		config.PrimeConnections = false
+		// Per-request samples are needed for the connection breakdown
+		config.IncludeRawMetrics = true
+		benchmarker := NewBenchmarker(config)
+		if limiter != nil {
+			benchmarker.SetRateLimiter(limiter)
+		}
+		if auth != nil {
+			benchmarker.SetAuthProvider(auth)
+		}
+		if spend != nil {
+			benchmarker.SetSpendGuard(spend)
+		}
+		r.watchProgress(benchmarker, runIndex)
+		return benchmarker
+	}
+
+	fmt.Printf("Connection experiment: %d variants\n", len(names))
+	for _, variant := range run.ConnectionVariants {
+		fmt.Printf("  %s: %s\n", variant.Name, variant)
+	}
+
+	if run.WarmupIterations > 0 {
+		fmt.Printf("Warmup: Running %d iterations per variant...\n", run.WarmupIterations)
+		for i := 0; i < run.WarmupIterations; i++ {
+			for _, name := range names {
+				_, err := newBenchmarker(name, -(i + 1)).Run(ctx)
+				if ctx.Err() != nil {
+					return fmt.Errorf("warmup interrupted: %w", ctx.Err())
+				}
+				if err != nil {
+					fmt.Printf("Warmup iteration %d for %s failed: %v\n", i+1, name, err)
+				}
+			}
+		}
+		fmt.Printf("Warmup complete\n\n")
+	}
+
+	samples := make(map[string]*targetSamples, len(names))
+	run.VariantResults = make(map[string][]*BenchmarkResult, len(names))
+	for _, name := range names {
+		samples[name] = &targetSamples{}
+	}
+
+	err := r.executeInterleavedRounds(ctx, runIndex, run, names, run.VariantResults, samples, newBenchmarker)
+
+	run.Results = run.VariantResults[names[0]]
+	if len(run.Results) == 0 {
+		return err
+	}
+
+	alpha := run.SignificanceLevel
+	if alpha <= 0 {
+		alpha = DefaultSignificanceLevel
+	}
+	run.ConnectionExperiment = analyzeConnectionVariants(run.ConnectionVariants, samples, alpha)
+	printConnectionExperiment(run.ConnectionExperiment)
+
+	return err
+}
+
+// analyzeConnectionVariants attributes latency to connection establishment
+// and picks the fastest variant without a higher error rate
+func analyzeConnectionVariants(variants []ConnectionVariant, samples map[string]*targetSamples, alpha float64) *ConnectionExperiment {
+	experiment := &ConnectionExperiment{PValue: 1}
+
+	for _, variant := range variants {
+		s := samples[variant.Name]
+		stats := CalculateStats(s.latencies)
+		result := ConnectionVariantResult{
+			Variant:       variant,
+			Samples:       len(s.latencies),
+			MedianMs:      stats.P50,
+			P95Ms:         stats.P95,
+			RPS:           s.meanRPS(),
+			ErrorRate:     s.errorRate(),
+			SetupMedianMs: CalculateStats(s.setup).P50,
+		}
+		if len(s.latencies) > 0 {
+			result.NewConnectionRate = float64(s.dialed) / float64(len(s.latencies))
+		}
+		if s.latencyTotal > 0 {
+			result.SetupShare = s.setupTotal / s.latencyTotal
+		}
+		experiment.Variants = append(experiment.Variants, result)
+	}
+
+	// Only variants erroring no more than the baseline (plus 1%) are candidates
+	var best, off *ConnectionVariantResult
+	maxErrors := experiment.Variants[0].ErrorRate + 0.01
+	for i := range experiment.Variants {
+		v := &experiment.Variants[i]
+		if v.Samples == 0 {
+			continue
+		}
+		if !v.Variant.KeepAlive {
+			if off == nil {
+				off = v
+			}
+			continue
+		}
+		if v.ErrorRate <= maxErrors && (best == nil || v.MedianMs < best.MedianMs) {
+			best = v
+		}
+	}
+	if best == nil {
+		experiment.Recommendations = append(experiment.Recommendations,
+			"No keep-alive variant completed without extra errors; no recommendation")
+		return experiment
+	}
+	experiment.Recommended = best.Variant.Name
+
+	if off != nil {
+		experiment.ConnectionOverheadMs = off.MedianMs - best.MedianMs
+		if off.MedianMs > 0 {
+			experiment.ConnectionShare = experiment.ConnectionOverheadMs / off.MedianMs
+		}
+		_, experiment.PValue = MannWhitneyU(samples[off.Variant.Name].latencies, samples[best.Variant.Name].latencies)
+		experiment.Significant = experiment.PValue < alpha
+
+		switch {
+		case !experiment.Significant || experiment.ConnectionShare < negligibleConnectionShare:
+			experiment.Recommendations = append(experiment.Recommendations, fmt.Sprintf(
+				"Connection establishment accounts for little of the latency (%.1f%%, p=%.4f); tune keep-alive for resource use rather than latency",
+				experiment.ConnectionShare*100, experiment.PValue))
+		default:
+			experiment.Recommendations = append(experiment.Recommendations, fmt.Sprintf(
+				"Keep HTTP keep-alive enabled: a new connection costs %.2f ms (DNS+connect+TLS median), %.1f%% of the median latency without reuse",
+				off.SetupMedianMs, experiment.ConnectionShare*100))
+		}
+	}
+
+	for i := range experiment.Variants {
+		v := &experiment.Variants[i]
+		if v == best || !v.Variant.KeepAlive || v.Samples == 0 || best.MedianMs <= 0 {
+			continue
+		}
+		slowdown := (v.MedianMs - best.MedianMs) / best.MedianMs
+		if slowdown < negligibleConnectionShare || v.NewConnectionRate <= best.NewConnectionRate {
+			continue
+		}
+		experiment.Recommendations = append(experiment.Recommendations, fmt.Sprintf(
+			"Avoid %s (%s): it opened new connections for %.1f%% of requests vs %.1f%%, adding %.2f ms at the median",
+			v.Variant.Name, v.Variant, v.NewConnectionRate*100, best.NewConnectionRate*100, v.MedianMs-best.MedianMs))
+	}
+
+	experiment.Recommendations = append(experiment.Recommendations, fmt.Sprintf(
+		"Use %s: %s", best.Variant.Name, best.Variant))
+	return experiment
+}
+
+// printConnectionExperiment prints every variant and the recommendations
+func printConnectionExperiment(experiment *ConnectionExperiment) {
+	fmt.Printf("\n--- Connection Experiment ---\n")
+	for _, v := range experiment.Variants {
+		fmt.Printf("%s: median %.2f ms, P95 %.2f ms, new connections %.1f%%, setup share %.1f%%\n",
+			v.Variant.Name, v.MedianMs, v.P95Ms, v.NewConnectionRate*100, v.SetupShare*100)
+	}
+	for _, recommendation := range experiment.Recommendations {
+		fmt.Printf("  - %s\n", recommendation)
+	}
+}
+
+// connectionExperimentSection renders the experiment as markdown
+func connectionExperimentSection(experiment *ConnectionExperiment) string {
+	section := "### Connection Experiment\n\n"
+	section += "| Variant | Settings | Median (ms) | P95 (ms) | RPS | Error Rate | New Connections | Setup Median (ms) | Setup Share |\n"
+	section += "|---------|----------|-------------|----------|-----|------------|-----------------|-------------------|-------------|\n"
+	for _, v := range experiment.Variants {
+		section += fmt.Sprintf("| %s | %s | %.2f | %.2f | %.2f | %.2f%% | %.1f%% | %.2f | %.1f%% |\n",
+			v.Variant.Name, v.Variant, v.MedianMs, v.P95Ms, v.RPS,
+			v.ErrorRate*100, v.NewConnectionRate*100, v.SetupMedianMs, v.SetupShare*100)
+	}
+	section += "\n"
+
+	if experiment.ConnectionOverheadMs != 0 || experiment.ConnectionShare != 0 {
+		section += fmt.Sprintf("**Attributable to connection establishment:** %.2f ms median (%.1f%% of latency without keep-alive, p=%.4f)\n\n",
+			experiment.ConnectionOverheadMs, experiment.ConnectionShare*100, experiment.PValue)
+	}
+
+	section += "#### Recommendation\n\n"
+	for _, recommendation := range experiment.Recommendations {
+		section += fmt.Sprintf("- %s\n", recommendation)
+	}
+	section += "\n"
+
+	return section
+}
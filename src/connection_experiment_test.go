@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestConnectionExperiment tests that every variant runs and that reuse is
+// measured per variant
+func TestConnectionExperiment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	suite := &BenchmarkSuite{Name: "connection_test", OutputDir: t.TempDir()}
+	runner := NewBenchmarkRunner(suite)
+	run := &BenchmarkRun{
+		Name:               "connections",
+		Config:             BenchmarkConfig{TargetURL: server.URL, TotalRequests: 40, Concurrency: 4, KeepAlive: true},
+		Iterations:         1,
+		ConnectionVariants: DefaultConnectionVariants(4),
+	}
+
+	if err := runner.executeRun(context.Background(), 0, run); err != nil {
+		t.Fatalf("Connection experiment failed: %v", err)
+	}
+
+	experiment := run.ConnectionExperiment
+	if experiment == nil || len(experiment.Variants) != 4 {
+		t.Fatalf("Expected 4 variant results, got %+v", experiment)
+	}
+	if len(run.Results) != 1 || len(run.VariantResults["no_keepalive"]) != 1 {
+		t.Fatalf("Expected one result per variant, got %d", len(run.Results))
+	}
+	for _, v := range experiment.Variants {
+		if v.Samples != 40 {
+			t.Errorf("%s: expected 40 samples, got %d", v.Variant.Name, v.Samples)
+		}
+		switch v.Variant.Name {
+		case "no_keepalive":
+			if v.NewConnectionRate != 1 {
+				t.Errorf("Expected a new connection per request without keep-alive, got %.2f", v.NewConnectionRate)
+			}
+		case "keepalive":
+			if v.NewConnectionRate > 0.5 {
+				t.Errorf("Expected connections reused with keep-alive, got %.2f new", v.NewConnectionRate)
+			}
+		}
+	}
+	if experiment.Recommended == "" || experiment.Recommended == "no_keepalive" {
+		t.Errorf("Expected a keep-alive variant recommended, got %q", experiment.Recommended)
+	}
+
+	section := connectionExperimentSection(experiment)
+	if !strings.Contains(section, "#### Recommendation") || !strings.Contains(section, "| short_idle |") {
+		t.Errorf("Unexpected report section:\n%s", section)
+	}
+}
+
+// TestAnalyzeConnectionVariants tests attribution and recommendations on
+// synthetic samples
+func TestAnalyzeConnectionVariants(t *testing.T) {
+	variants := DefaultConnectionVariants(8)
+	samples := map[string]*targetSamples{}
+	fill := func(name string, latency float64, dialedEvery int) {
+		s := &targetSamples{}
+		for i := 0; i < 100; i++ {
+			l := latency + float64(i%10)*0.1
+			s.latencies = append(s.latencies, l)
+			s.latencyTotal += l
+			if i%dialedEvery == 0 {
+				s.dialed++
+				s.setup = append(s.setup, 5)
+				s.setupTotal += 5
+			}
+		}
+		s.requests = 100
+		samples[name] = s
+	}
+	fill("keepalive", 10, 25)
+	fill("no_keepalive", 15, 1)
+	fill("small_pool", 12, 3)
+	fill("short_idle", 10.1, 20)
+
+	experiment := analyzeConnectionVariants(variants, samples, DefaultSignificanceLevel)
+
+	if experiment.Recommended != "keepalive" {
+		t.Errorf("Expected keepalive recommended, got %q", experiment.Recommended)
+	}
+	if experiment.ConnectionOverheadMs != 5 || !experiment.Significant {
+		t.Errorf("Expected a significant 5 ms overhead, got %.2f (p=%.4f)", experiment.ConnectionOverheadMs, experiment.PValue)
+	}
+	recommendations := strings.Join(experiment.Recommendations, "\n")
+	if !strings.Contains(recommendations, "Keep HTTP keep-alive enabled") ||
+		!strings.Contains(recommendations, "Avoid small_pool") ||
+		strings.Contains(recommendations, "Avoid short_idle") {
+		t.Errorf("Unexpected recommendations:\n%s", recommendations)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connectionAgeBuckets are the upper bounds used for the age distribution
+var connectionAgeBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"<10s", 10 * time.Second},
+	{"10s-1m", time.Minute},
+	{"1m-5m", 5 * time.Minute},
+	{"5m-15m", 15 * time.Minute},
+	{">15m", 0},
+}
+
+// HostPoolStats describes the pooled connections to one host
+type HostPoolStats struct {
+	Host            string         `json:"host"`
+	Open            int            `json:"open"`
+	Active          int            `json:"active"`
+	Idle            int            `json:"idle"`
+	Dialed          int64          `json:"dialed"`
+	Reuses          int64          `json:"reuses"`
+	ReuseRatio      float64        `json:"reuse_ratio"`
+	OldestAge       time.Duration  `json:"oldest_age"`
+	AgeDistribution map[string]int `json:"age_distribution"`
+}
+
+// ConnectionPoolStats is a snapshot of the real connection pool
+type ConnectionPoolStats struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	Open       int             `json:"open"`
+	Active     int             `json:"active"`
+	Idle       int             `json:"idle"`
+	Dialed     int64           `json:"dialed"`
+	Reuses     int64           `json:"reuses"`
+	ReuseRatio float64         `json:"reuse_ratio"`
+	Hosts      []HostPoolStats `json:"hosts"`
+
+	// Connections retired by the rotation policy, if one is set
+	Rotation *ConnectionRotationStats `json:"rotation,omitempty"`
+}
+
+// trackedConn is a pooled connection the tracker knows about
+type trackedConn struct {
+	net.Conn
+	host     string
+	openedAt time.Time
+	inFlight int32
+	requests int64 // Requests served, for rotation
+	retired  bool  // Closed by rotation; guarded by tracker.mu
+	tracker  *ConnectionTracker
+	once     sync.Once
+}
+
+// Close removes the connection from the tracker
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.tracker.remove(c) })
+	return c.Conn.Close()
+}
+
+// hostCounters are the cumulative counters for one host
+type hostCounters struct {
+	dialed int64
+	reuses int64
+}
+
+// ConnectionTracker observes a transport's dials and connection reuse so the
+// pool's real state can be reported instead of estimated
+type ConnectionTracker struct {
+	conns map[*trackedConn]struct{}
+	hosts map[string]*hostCounters
+	mu    sync.Mutex
+
+	// Optional periodic reconnects; see ConnectionRotation
+	rotation      *ConnectionRotation
+	rotationStats rotationCounters
+}
+
+// NewConnectionTracker creates an empty tracker
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{
+		conns: make(map[*trackedConn]struct{}),
+		hosts: make(map[string]*hostCounters),
+	}
+}
+
+// Wrap instruments transport and returns a RoundTripper that records which
+// pooled connection served each request
+func (t *ConnectionTracker) Wrap(transport *http.Transport) http.RoundTripper {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.add(conn, addr), nil
+	}
+
+	return &trackingRoundTripper{base: transport, tracker: t}
+}
+
+// add registers a newly dialed connection
+func (t *ConnectionTracker) add(conn net.Conn, host string) *trackedConn {
+	tc := &trackedConn{Conn: conn, host: host, openedAt: time.Now(), tracker: t}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.conns[tc] = struct{}{}
+	t.counters(host).dialed++
+	return tc
+}
+
+// remove forgets a closed connection
+func (t *ConnectionTracker) remove(tc *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, tc)
+}
+
+// counters returns the counters for host; callers must hold t.mu
+func (t *ConnectionTracker) counters(host string) *hostCounters {
+	counters, exists := t.hosts[host]
+	if !exists {
+		counters = &hostCounters{}
+		t.hosts[host] = counters
+	}
+	return counters
+}
+
+// gotConn marks the connection behind info as serving a request
+func (t *ConnectionTracker) gotConn(info httptrace.GotConnInfo) *trackedConn {
+	conn := info.Conn
+	// TLS connections wrap the dialed connection
+	if wrapped, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = wrapped.NetConn()
+	}
+
+	tc, ok := conn.(*trackedConn)
+	if !ok {
+		return nil
+	}
+
+	atomic.AddInt32(&tc.inFlight, 1)
+	atomic.AddInt64(&tc.requests, 1)
+	if info.Reused {
+		t.mu.Lock()
+		t.counters(tc.host).reuses++
+		t.mu.Unlock()
+	}
+	return tc
+}
+
+// Stats returns open, active and idle connections per host with their ages
+func (t *ConnectionTracker) Stats() ConnectionPoolStats {
+	rotation := t.RotationStats()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	hosts := make(map[string]*HostPoolStats)
+	host := func(name string) *HostPoolStats {
+		stats, exists := hosts[name]
+		if !exists {
+			stats = &HostPoolStats{Host: name, AgeDistribution: make(map[string]int)}
+			for _, bucket := range connectionAgeBuckets {
+				stats.AgeDistribution[bucket.label] = 0
+			}
+			hosts[name] = stats
+		}
+		return stats
+	}
+
+	for tc := range t.conns {
+		stats := host(tc.host)
+		stats.Open++
+		if atomic.LoadInt32(&tc.inFlight) > 0 {
+			stats.Active++
+		} else {
+			stats.Idle++
+		}
+
+		age := now.Sub(tc.openedAt)
+		if age > stats.OldestAge {
+			stats.OldestAge = age
+		}
+		for _, bucket := range connectionAgeBuckets {
+			if bucket.max == 0 || age < bucket.max {
+				stats.AgeDistribution[bucket.label]++
+				break
+			}
+		}
+	}
+
+	for name, counters := range t.hosts {
+		stats := host(name)
+		stats.Dialed = counters.dialed
+		stats.Reuses = counters.reuses
+		if total := counters.dialed + counters.reuses; total > 0 {
+			stats.ReuseRatio = float64(counters.reuses) / float64(total)
+		}
+	}
+
+	pool := ConnectionPoolStats{Timestamp: now, Hosts: make([]HostPoolStats, 0, len(hosts)), Rotation: rotation}
+	for _, stats := range hosts {
+		pool.Open += stats.Open
+		pool.Active += stats.Active
+		pool.Idle += stats.Idle
+		pool.Dialed += stats.Dialed
+		pool.Reuses += stats.Reuses
+		pool.Hosts = append(pool.Hosts, *stats)
+	}
+	if total := pool.Dialed + pool.Reuses; total > 0 {
+		pool.ReuseRatio = float64(pool.Reuses) / float64(total)
+	}
+
+	sort.Slice(pool.Hosts, func(i, j int) bool {
+		return pool.Hosts[i].Host < pool.Hosts[j].Host
+	})
+
+	return pool
+}
+
+// HandleConnections serves the /metrics/connections snapshot as JSON
+func (t *ConnectionTracker) HandleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.Stats())
+}
+
+// trackingRoundTripper attributes each request to the connection serving it
+// and marks the connection idle again once the response body is closed
+type trackingRoundTripper struct {
+	base    http.RoundTripper
+	tracker *ConnectionTracker
+}
+
+// RoundTrip forwards req while tracing which connection it used
+func (rt *trackingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var conn *trackedConn
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn = rt.tracker.gotConn(info)
+			reused = info.Reused
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := rt.base.RoundTrip(req)
+	if conn == nil {
+		return resp, err
+	}
+	if err != nil {
+		rt.tracker.release(conn)
+		return resp, err
+	}
+	rt.tracker.recordLatency(reused, time.Since(start))
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, conn: conn}
+	return resp, nil
+}
+
+// releasingBody ends a connection's active period when the body is closed
+type releasingBody struct {
+	io.ReadCloser
+	conn *trackedConn
+	once sync.Once
+}
+
+// Close closes the body and releases the connection. The body is closed
+// first so an HTTP/1.1 connection is back in the pool before rotation may
+// retire it
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.conn.tracker.release(b.conn) })
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestConnectionTracker tests that dials, reuse and idle connections are reported
+func TestConnectionTracker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tracker := NewConnectionTracker()
+	client := &http.Client{Transport: tracker.Wrap(&http.Transport{})}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	stats := tracker.Stats()
+	host := strings.TrimPrefix(server.URL, "http://")
+	if len(stats.Hosts) != 1 || stats.Hosts[0].Host != host {
+		t.Fatalf("Expected one host %s, got %+v", host, stats.Hosts)
+	}
+	if stats.Open != 1 || stats.Idle != 1 || stats.Active != 0 {
+		t.Errorf("Expected one idle connection, got open=%d active=%d idle=%d", stats.Open, stats.Active, stats.Idle)
+	}
+	if stats.Dialed != 1 || stats.Reuses != 2 {
+		t.Errorf("Expected 1 dial and 2 reuses, got %d and %d", stats.Dialed, stats.Reuses)
+	}
+	if stats.Hosts[0].AgeDistribution["<10s"] != 1 {
+		t.Errorf("Expected the connection in the youngest age bucket, got %v", stats.Hosts[0].AgeDistribution)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Reasons a connection is retired by a ConnectionRotation
+const (
+	RotationReasonAge      = "max_age"
+	RotationReasonRequests = "max_requests"
+)
+
+// maxRecentRotations bounds the rotation events kept for inspection
+const maxRecentRotations = 100
+
+// ConnectionRotation retires pooled connections after a maximum age or
+// number of requests, forcing periodic reconnects so load balancers that pin
+// a connection to one backend spread the traffic again. A connection past a
+// limit is closed once its in-flight requests finish; zero disables a limit
+type ConnectionRotation struct {
+	MaxAge      time.Duration `yaml:"max_age"`
+	MaxRequests int           `yaml:"max_requests"`
+}
+
+// enabled reports whether r sets any limit
+func (r *ConnectionRotation) enabled() bool {
+	return r != nil && (r.MaxAge > 0 || r.MaxRequests > 0)
+}
+
+// retireReason returns why a connection opened at openedAt that has served
+// requests should be retired, or "" if it may serve more
+func (r *ConnectionRotation) retireReason(openedAt time.Time, requests int64, now time.Time) string {
+	if !r.enabled() {
+		return ""
+	}
+	if r.MaxRequests > 0 && requests >= int64(r.MaxRequests) {
+		return RotationReasonRequests
+	}
+	if r.MaxAge > 0 && now.Sub(openedAt) >= r.MaxAge {
+		return RotationReasonAge
+	}
+	return ""
+}
+
+// ConnectionRotationEvent is one connection retired by rotation
+type ConnectionRotationEvent struct {
+	Time     time.Time     `json:"time"`
+	Host     string        `json:"host"`
+	Reason   string        `json:"reason"`
+	Age      time.Duration `json:"age"`
+	Requests int64         `json:"requests"`
+}
+
+// ConnectionRotationStats counts rotations and what reconnecting costs
+type ConnectionRotationStats struct {
+	MaxAge      time.Duration    `json:"max_age,omitempty"`
+	MaxRequests int              `json:"max_requests,omitempty"`
+	Rotations   int64            `json:"rotations"`
+	Reasons     map[string]int64 `json:"reasons"`
+	Hosts       map[string]int64 `json:"hosts"` // Rotations by host
+
+	// Mean time to response headers of requests that dialed a connection
+	// and of those that reused one; ImpactMs is the difference, paid by the
+	// first request after each rotation, and TotalImpactMs that times the
+	// rotations
+	NewConnLatencyMs    float64 `json:"new_conn_latency_ms"`
+	ReusedConnLatencyMs float64 `json:"reused_conn_latency_ms"`
+	ImpactMs            float64 `json:"impact_ms"`
+	TotalImpactMs       float64 `json:"total_impact_ms"`
+
+	Recent []ConnectionRotationEvent `json:"recent,omitempty"` // Oldest first
+}
+
+// rotationCounters accumulates rotations and request latencies; guarded by
+// the ConnectionTracker's mutex
+type rotationCounters struct {
+	rotations int64
+	reasons   map[string]int64
+	hosts     map[string]int64
+	recent    []ConnectionRotationEvent
+
+	newConnTotal, reusedTotal time.Duration
+	newConns, reused          int64
+}
+
+// SetRotation retires the tracked connections by policy from now on; nil
+// disables rotation
+func (t *ConnectionTracker) SetRotation(policy *ConnectionRotation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if policy.enabled() {
+		p := *policy
+		t.rotation = &p
+	} else {
+		t.rotation = nil
+	}
+}
+
+// recordLatency adds the time a request took to receive response headers
+// over a reused or newly dialed connection
+func (t *ConnectionTracker) recordLatency(reused bool, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reused {
+		t.rotationStats.reusedTotal += latency
+		t.rotationStats.reused++
+	} else {
+		t.rotationStats.newConnTotal += latency
+		t.rotationStats.newConns++
+	}
+}
+
+// release ends one request on tc and retires tc if it is idle and past the
+// rotation policy. Closing an idle HTTP/1.1 connection removes it from the
+// pool; a request that picked it up meanwhile fails before anything is
+// written, which the transport retries on a new connection
+func (t *ConnectionTracker) release(tc *trackedConn) {
+	t.mu.Lock()
+	if atomic.AddInt32(&tc.inFlight, -1) > 0 || tc.retired {
+		t.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	reason := t.rotation.retireReason(tc.openedAt, atomic.LoadInt64(&tc.requests), now)
+	if reason == "" {
+		t.mu.Unlock()
+		return
+	}
+	tc.retired = true
+
+	counters := &t.rotationStats
+	if counters.reasons == nil {
+		counters.reasons = make(map[string]int64)
+		counters.hosts = make(map[string]int64)
+	}
+	counters.rotations++
+	counters.reasons[reason]++
+	counters.hosts[tc.host]++
+	if len(counters.recent) == maxRecentRotations {
+		counters.recent = counters.recent[1:]
+	}
+	counters.recent = append(counters.recent, ConnectionRotationEvent{
+		Time:     now,
+		Host:     tc.host,
+		Reason:   reason,
+		Age:      now.Sub(tc.openedAt),
+		Requests: atomic.LoadInt64(&tc.requests),
+	})
+	t.mu.Unlock()
+
+	tc.Close()
+}
+
+// RotationStats returns the rotations so far and their latency impact, or
+// nil if no rotation policy is set
+func (t *ConnectionTracker) RotationStats() *ConnectionRotationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rotation == nil {
+		return nil
+	}
+	counters := t.rotationStats
+	stats := &ConnectionRotationStats{
+		MaxAge:      t.rotation.MaxAge,
+		MaxRequests: t.rotation.MaxRequests,
+		Rotations:   counters.rotations,
+		Reasons:     make(map[string]int64, len(counters.reasons)),
+		Hosts:       make(map[string]int64, len(counters.hosts)),
+		Recent:      append([]ConnectionRotationEvent(nil), counters.recent...),
+	}
+	for reason, count := range counters.reasons {
+		stats.Reasons[reason] = count
+	}
+	for host, count := range counters.hosts {
+		stats.Hosts[host] = count
+	}
+
+	if counters.newConns > 0 {
+		stats.NewConnLatencyMs = float64(counters.newConnTotal.Microseconds()) / 1000.0 / float64(counters.newConns)
+	}
+	if counters.reused > 0 {
+		stats.ReusedConnLatencyMs = float64(counters.reusedTotal.Microseconds()) / 1000.0 / float64(counters.reused)
+	}
+	if counters.newConns > 0 && counters.reused > 0 {
+		stats.ImpactMs = stats.NewConnLatencyMs - stats.ReusedConnLatencyMs
+		stats.TotalImpactMs = stats.ImpactMs * float64(counters.rotations)
+	}
+	return stats
+}
+
+// printRotationStats writes the rotations of a result and their cost
+func printRotationStats(stats *ConnectionRotationStats) {
+	fmt.Printf("\n--- Connection Rotation ---\n")
+	fmt.Printf("Policy: %s\n", rotationPolicy(stats))
+	fmt.Printf("Rotations: %d", stats.Rotations)
+	for _, reason := range sortedKeys(stats.Reasons) {
+		fmt.Printf(" | %s: %d", reason, stats.Reasons[reason])
+	}
+	fmt.Println()
+	fmt.Printf("Time to headers: %.2f ms on new connections, %.2f ms on reused\n",
+		stats.NewConnLatencyMs, stats.ReusedConnLatencyMs)
+	if stats.ImpactMs != 0 {
+		fmt.Printf("Impact: %+.2f ms per reconnect, %.2f ms across all rotations\n", stats.ImpactMs, stats.TotalImpactMs)
+	}
+}
+
+// rotationSection renders the rotations of a run's iterations as markdown,
+// or returns "" if none set a rotation policy
+func rotationSection(results []*BenchmarkResult) string {
+	var policy *ConnectionRotationStats
+	var rotations int64
+	var impact float64
+	var costed int
+	reasons := make(map[string]int64)
+	for _, result := range results {
+		stats := result.Rotation
+		if stats == nil {
+			continue
+		}
+		policy = stats
+		rotations += stats.Rotations
+		for reason, count := range stats.Reasons {
+			reasons[reason] += count
+		}
+		if stats.ImpactMs != 0 {
+			impact += stats.ImpactMs
+			costed++
+		}
+	}
+	if policy == nil {
+		return ""
+	}
+
+	section := "### Connection Rotation\n\n"
+	section += fmt.Sprintf("Connections were retired after %s.\n\n", rotationPolicy(policy))
+	section += "| Reason | Rotations |\n"
+	section += "|--------|-----------|\n"
+	for _, reason := range sortedKeys(reasons) {
+		section += fmt.Sprintf("| %s | %d |\n", reason, reasons[reason])
+	}
+	section += fmt.Sprintf("| **Total** | **%d** |\n\n", rotations)
+	if costed > 0 {
+		section += fmt.Sprintf("Each reconnect added %+.2f ms to the time to response headers of the request that made it.\n\n", impact/float64(costed))
+	}
+	return section
+}
+
+// rotationPolicy describes the limits in stats
+func rotationPolicy(stats *ConnectionRotationStats) string {
+	switch {
+	case stats.MaxAge > 0 && stats.MaxRequests > 0:
+		return fmt.Sprintf("%v or %d requests, whichever comes first", stats.MaxAge, stats.MaxRequests)
+	case stats.MaxAge > 0:
+		return stats.MaxAge.String()
+	default:
+		return fmt.Sprintf("%d requests", stats.MaxRequests)
+	}
+}
+
+// sortedKeys returns the keys of counts in order
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
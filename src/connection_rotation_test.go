@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestConnectionRotationRetireReason tests the age and request limits
+func TestConnectionRotationRetireReason(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		policy   *ConnectionRotation
+		age      time.Duration
+		requests int64
+		expected string
+	}{
+		{"no policy", nil, time.Hour, 1000, ""},
+		{"zero limits", &ConnectionRotation{}, time.Hour, 1000, ""},
+		{"under limits", &ConnectionRotation{MaxAge: time.Minute, MaxRequests: 10}, time.Second, 9, ""},
+		{"request limit", &ConnectionRotation{MaxRequests: 10}, time.Second, 10, RotationReasonRequests},
+		{"age limit", &ConnectionRotation{MaxAge: time.Minute}, 2 * time.Minute, 1, RotationReasonAge},
+		{"both reached", &ConnectionRotation{MaxAge: time.Minute, MaxRequests: 10}, 2 * time.Minute, 10, RotationReasonRequests},
+	}
+
+	for _, tc := range cases {
+		reason := tc.policy.retireReason(now.Add(-tc.age), tc.requests, now)
+		if reason != tc.expected {
+			t.Errorf("%s: Expected reason %q, got %q", tc.name, tc.expected, reason)
+		}
+	}
+}
+
+// TestConnectionRotationReconnects tests that connections are retired after
+// the request limit and the rotations recorded
+func TestConnectionRotationReconnects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTP2Client(&HTTP2ClientConfig{Rotation: &ConnectionRotation{MaxRequests: 2}})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	stats := client.ConnectionTracker().Stats()
+	if stats.Dialed != 3 || stats.Reuses != 3 {
+		t.Errorf("Expected 3 dials and 3 reuses, got %d and %d", stats.Dialed, stats.Reuses)
+	}
+	if stats.Open != 0 {
+		t.Errorf("Expected every connection retired, got %d open", stats.Open)
+	}
+
+	rotation := stats.Rotation
+	if rotation == nil {
+		t.Fatal("Expected rotation stats")
+	}
+	if rotation.Rotations != 3 || rotation.Reasons[RotationReasonRequests] != 3 {
+		t.Errorf("Expected 3 rotations by request count, got %d (%v)", rotation.Rotations, rotation.Reasons)
+	}
+	if len(rotation.Recent) != 3 || rotation.Recent[0].Requests != 2 {
+		t.Errorf("Expected 3 events of 2 requests each, got %+v", rotation.Recent)
+	}
+	if rotation.NewConnLatencyMs <= 0 || rotation.ReusedConnLatencyMs <= 0 {
+		t.Errorf("Expected latency on new and reused connections, got %.3f and %.3f",
+			rotation.NewConnLatencyMs, rotation.ReusedConnLatencyMs)
+	}
+}
+
+// TestConnectionRotationDisabled tests that no stats are reported without a policy
+func TestConnectionRotationDisabled(t *testing.T) {
+	tracker := NewConnectionTracker()
+	tracker.SetRotation(&ConnectionRotation{})
+	if stats := tracker.RotationStats(); stats != nil {
+		t.Errorf("Expected no rotation stats, got %+v", stats)
+	}
+	if tracker.Stats().Rotation != nil {
+		t.Error("Expected no rotation in pool stats")
+	}
+}
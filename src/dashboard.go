@@ -2,19 +2,109 @@ package main
 
 import (
 	"context"
+	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// dashboardFiles holds the dashboard page, its stylesheet and script. The
+// charts are drawn on canvases by dashboard.js, so the page loads nothing
+// from outside the dashboard and works offline
+//
+//go:embed dashboard_assets
+var dashboardFiles embed.FS
+
+// DefaultDashboardTitle is the dashboard header without branding
+const DefaultDashboardTitle = "API Latency Optimizer"
+
+// Dashboard themes; auto follows the browser's color scheme
+const (
+	DashboardThemeAuto  = "auto"
+	DashboardThemeLight = "light"
+	DashboardThemeDark  = "dark"
+)
+
+// accentPattern matches the hex colors accepted as an accent
+var accentPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// DashboardOptions customize the dashboard's look
+type DashboardOptions struct {
+	// Directory whose files replace the embedded assets of the same name:
+	// index.html (an html/template), dashboard.css and dashboard.js. Every
+	// file in it, such as a logo, is served under /assets/
+	AssetsDir string
+
+	Theme  string // DashboardThemeAuto (default), DashboardThemeLight or DashboardThemeDark
+	Title  string // Header and page title; empty means DefaultDashboardTitle
+	Logo   string // Image URL shown before the title, e.g. /assets/logo.svg from AssetsDir
+	Accent string // Hex color replacing the default accent, e.g. #0f766e
+}
+
+// Validate checks the options' values
+func (o DashboardOptions) Validate() error {
+	switch o.Theme {
+	case "", DashboardThemeAuto, DashboardThemeLight, DashboardThemeDark:
+	default:
+		return fmt.Errorf("unknown dashboard theme %q, expected %s, %s or %s",
+			o.Theme, DashboardThemeAuto, DashboardThemeLight, DashboardThemeDark)
+	}
+	if o.Accent != "" && !accentPattern.MatchString(o.Accent) {
+		return fmt.Errorf("dashboard accent %q must be a hex color such as #0f766e", o.Accent)
+	}
+	if o.AssetsDir != "" {
+		info, err := os.Stat(o.AssetsDir)
+		if err != nil {
+			return fmt.Errorf("invalid dashboard assets: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dashboard assets %s is not a directory", o.AssetsDir)
+		}
+	}
+	return nil
+}
+
+// assets returns the embedded assets overlaid by AssetsDir's files
+func (o DashboardOptions) assets() fs.FS {
+	embedded, _ := fs.Sub(dashboardFiles, "dashboard_assets")
+	if o.AssetsDir == "" {
+		return embedded
+	}
+	return overlayFS{upper: os.DirFS(o.AssetsDir), lower: embedded}
+}
+
+// overlayFS opens files from upper, falling back to lower for those upper
+// does not have
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.upper.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	return o.lower.Open(name)
+}
+
 // Dashboard provides a real-time web interface for monitoring
 type Dashboard struct {
 	port            int
+	listen          ListenOptions
 	refreshInterval time.Duration
+	options         DashboardOptions
+	assets          fs.FS
 	collector       *MetricsCollector
+	circuits        *CircuitBreakerRegistry
+	connections     *ConnectionTracker
 
 	server  *http.Server
 	mu      sync.RWMutex
@@ -38,22 +128,23 @@ func (d *Dashboard) Start(collector *MetricsCollector) error {
 		return fmt.Errorf("dashboard already running")
 	}
 
+	if err := d.options.Validate(); err != nil {
+		return err
+	}
+	d.assets = d.options.assets()
 	d.collector = collector
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", d.handleIndex)
-	mux.HandleFunc("/api/current", d.handleAPICurrent)
-	mux.HandleFunc("/api/snapshots", d.handleAPISnapshots)
-	mux.HandleFunc("/api/summary", d.handleAPISummary)
-	mux.HandleFunc("/api/trends", d.handleAPITrends)
-
+	listener, err := listen("dashboard", d.listen, d.port)
+	if err != nil {
+		return err
+	}
 	d.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", d.port),
-		Handler: mux,
+		Addr:    listener.Addr().String(),
+		Handler: d.handler(),
 	}
 
 	go func() {
-		if err := d.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := d.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			fmt.Printf("Dashboard server error: %v\n", err)
 		}
 	}()
@@ -62,6 +153,53 @@ func (d *Dashboard) Start(collector *MetricsCollector) error {
 	return nil
 }
 
+// handler routes the dashboard page, its assets and the API
+func (d *Dashboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.handleIndex)
+	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(d.assets))))
+	mux.HandleFunc("/api/current", d.handleAPICurrent)
+	mux.HandleFunc("/api/snapshots", d.handleAPISnapshots)
+	mux.HandleFunc("/api/snapshots/purge", d.handleAPIPurgeSnapshots)
+	mux.HandleFunc("/api/history", d.handleAPIHistory)
+	mux.HandleFunc("/api/summary", d.handleAPISummary)
+	mux.HandleFunc("/api/trends", d.handleAPITrends)
+	mux.HandleFunc("/api/heatmap", d.handleAPIHeatmap)
+	mux.HandleFunc("/circuits", d.handleCircuits)
+	mux.HandleFunc("/metrics/connections", d.handleConnections)
+	return mux
+}
+
+// SetListenOptions sets the interface and socket options the dashboard binds
+// with when it starts
+func (d *Dashboard) SetListenOptions(options ListenOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listen = options
+}
+
+// SetOptions sets the dashboard's assets, theme and branding; they are
+// checked when it starts
+func (d *Dashboard) SetOptions(options DashboardOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.options = options
+}
+
+// AttachCircuitBreakers exposes a breaker registry on the /circuits endpoint
+func (d *Dashboard) AttachCircuitBreakers(registry *CircuitBreakerRegistry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.circuits = registry
+}
+
+// AttachConnectionTracker exposes a client's connection pool on /metrics/connections
+func (d *Dashboard) AttachConnectionTracker(tracker *ConnectionTracker) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connections = tracker
+}
+
 // Stop stops the dashboard HTTP server
 func (d *Dashboard) Stop() error {
 	d.mu.Lock()
@@ -82,13 +220,29 @@ func (d *Dashboard) Stop() error {
 	return nil
 }
 
-// handleIndex serves the main dashboard HTML page
+// handleIndex serves the main dashboard HTML page. The template is parsed
+// on every request so edits to an overriding index.html show on reload
 func (d *Dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.New("dashboard").Parse(dashboardHTML))
+	tmpl, err := template.ParseFS(d.assets, "index.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid dashboard template: %v", err), http.StatusInternalServerError)
+		return
+	}
 
+	title, theme := d.options.Title, d.options.Theme
+	if title == "" {
+		title = DefaultDashboardTitle
+	}
+	if theme == "" {
+		theme = DashboardThemeAuto
+	}
 	data := map[string]interface{}{
 		"RefreshInterval": d.refreshInterval.Milliseconds(),
 		"Port":            d.port,
+		"Title":           title,
+		"Theme":           theme,
+		"Logo":            d.options.Logo,
+		"Accent":          d.options.Accent,
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -130,6 +284,105 @@ func (d *Dashboard) handleAPISnapshots(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(snapshots)
 }
 
+// handleAPIPurgeSnapshots deletes stored snapshots. With ?older_than=<duration>
+// or ?before=<RFC3339 time> only older snapshots go; without either, all do
+func (d *Dashboard) handleAPIPurgeSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cutoff time.Time
+	query := r.URL.Query()
+	if olderThan := query.Get("older_than"); olderThan != "" {
+		dur, err := time.ParseDuration(olderThan)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid older_than: %v", err), http.StatusBadRequest)
+			return
+		}
+		cutoff = time.Now().Add(-dur)
+	} else if before := query.Get("before"); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid before: %v", err), http.StatusBadRequest)
+			return
+		}
+		cutoff = parsed
+	}
+
+	removed := d.collector.PurgeSnapshots(cutoff)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"removed":   removed,
+		"remaining": len(d.collector.GetSnapshots()),
+	})
+}
+
+// handleAPIHistory returns the snapshots of a time range, from the snapshot
+// store when one is attached. The range is ?start= and ?end= as RFC3339
+// times, or ?duration= back from end, an hour by default; ?points= caps
+// how many snapshots come back by downsampling, and ?format=csv exports them
+func (d *Dashboard) handleAPIHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	end := time.Now()
+	if param := query.Get("end"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid end: %v", err), http.StatusBadRequest)
+			return
+		}
+		end = parsed
+	}
+	start := end.Add(-time.Hour)
+	if param := query.Get("start"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid start: %v", err), http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	} else if param := query.Get("duration"); param != "" {
+		dur, err := time.ParseDuration(param)
+		if err != nil || dur <= 0 {
+			http.Error(w, fmt.Sprintf("invalid duration %q", param), http.StatusBadRequest)
+			return
+		}
+		start = end.Add(-dur)
+	}
+	if !start.Before(end) {
+		http.Error(w, "start must be before end", http.StatusBadRequest)
+		return
+	}
+	points := DefaultHistoryPoints
+	if param := query.Get("points"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("invalid points %q", param), http.StatusBadRequest)
+			return
+		}
+		points = parsed
+	}
+
+	snapshots, err := d.collector.History(start, end, points)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="history.csv"`)
+		writeHistoryCSV(w, snapshots)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []MonitoringSnapshot{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
 // handleAPISummary returns a metrics summary
 func (d *Dashboard) handleAPISummary(w http.ResponseWriter, r *http.Request) {
 	summary := d.collector.GetMetricsSummary()
@@ -155,451 +408,42 @@ func (d *Dashboard) handleAPITrends(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(trends)
 }
 
-// dashboardHTML is the HTML template for the dashboard
-const dashboardHTML = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>API Latency Optimizer - Monitoring Dashboard</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: #333;
-            padding: 20px;
-        }
-        .container {
-            max-width: 1400px;
-            margin: 0 auto;
-        }
-        header {
-            background: white;
-            padding: 20px 30px;
-            border-radius: 10px;
-            box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-            margin-bottom: 20px;
-        }
-        h1 {
-            color: #667eea;
-            font-size: 28px;
-            margin-bottom: 5px;
-        }
-        .subtitle {
-            color: #666;
-            font-size: 14px;
-        }
-        .grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(300px, 1fr));
-            gap: 20px;
-            margin-bottom: 20px;
-        }
-        .card {
-            background: white;
-            border-radius: 10px;
-            padding: 20px;
-            box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-        }
-        .card h2 {
-            font-size: 18px;
-            color: #667eea;
-            margin-bottom: 15px;
-            border-bottom: 2px solid #667eea;
-            padding-bottom: 10px;
-        }
-        .metric {
-            display: flex;
-            justify-content: space-between;
-            padding: 10px 0;
-            border-bottom: 1px solid #eee;
-        }
-        .metric:last-child {
-            border-bottom: none;
-        }
-        .metric-label {
-            color: #666;
-            font-size: 14px;
-        }
-        .metric-value {
-            font-weight: bold;
-            color: #333;
-            font-size: 16px;
-        }
-        .metric-value.good {
-            color: #10b981;
-        }
-        .metric-value.warning {
-            color: #f59e0b;
-        }
-        .metric-value.critical {
-            color: #ef4444;
-        }
-        .grade {
-            font-size: 48px;
-            font-weight: bold;
-            text-align: center;
-            padding: 20px;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            border-radius: 10px;
-            margin: 10px 0;
-        }
-        .chart-container {
-            grid-column: 1 / -1;
-            height: 400px;
-            background: white;
-            border-radius: 10px;
-            padding: 20px;
-            box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-        }
-        .status-indicator {
-            display: inline-block;
-            width: 10px;
-            height: 10px;
-            border-radius: 50%;
-            margin-right: 8px;
-        }
-        .status-indicator.active {
-            background: #10b981;
-            box-shadow: 0 0 10px #10b981;
-        }
-        .status-indicator.inactive {
-            background: #ef4444;
-        }
-        .refresh-info {
-            text-align: right;
-            color: #666;
-            font-size: 12px;
-            margin-top: 10px;
-        }
-        canvas {
-            max-width: 100%;
-            height: 350px;
-        }
-        .loading {
-            text-align: center;
-            padding: 40px;
-            color: #666;
-        }
-    </style>
-    <script src="https://cdn.jsdelivr.net/npm/chart.js@3.9.1/dist/chart.min.js"></script>
-</head>
-<body>
-    <div class="container">
-        <header>
-            <h1><span class="status-indicator active"></span>API Latency Optimizer</h1>
-            <div class="subtitle">Real-time Performance Monitoring Dashboard</div>
-            <div class="refresh-info">Auto-refresh: {{ .RefreshInterval }}ms | Last update: <span id="lastUpdate">--</span></div>
-        </header>
-
-        <div class="grid">
-            <!-- Cache Performance Card -->
-            <div class="card">
-                <h2>Cache Performance</h2>
-                <div class="metric">
-                    <span class="metric-label">Hit Ratio</span>
-                    <span class="metric-value" id="cacheHitRatio">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Size / Capacity</span>
-                    <span class="metric-value" id="cacheSize">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Memory Usage</span>
-                    <span class="metric-value" id="cacheMemory">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Total Gets</span>
-                    <span class="metric-value" id="cacheTotalGets">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Evictions</span>
-                    <span class="metric-value" id="cacheEvictions">--</span>
-                </div>
-            </div>
-
-            <!-- Latency Metrics Card -->
-            <div class="card">
-                <h2>Latency Statistics</h2>
-                <div class="metric">
-                    <span class="metric-label">P50 (Median)</span>
-                    <span class="metric-value" id="latencyP50">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">P95</span>
-                    <span class="metric-value" id="latencyP95">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">P99</span>
-                    <span class="metric-value" id="latencyP99">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Mean</span>
-                    <span class="metric-value" id="latencyMean">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Max</span>
-                    <span class="metric-value" id="latencyMax">--</span>
-                </div>
-            </div>
-
-            <!-- Throughput & Reliability Card -->
-            <div class="card">
-                <h2>Throughput & Reliability</h2>
-                <div class="metric">
-                    <span class="metric-label">Requests/sec</span>
-                    <span class="metric-value" id="throughputRPS">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Bytes/sec</span>
-                    <span class="metric-value" id="throughputBPS">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Error Rate</span>
-                    <span class="metric-value" id="errorRate">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Connection Reuse</span>
-                    <span class="metric-value" id="connReuse">--</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Uptime</span>
-                    <span class="metric-value" id="uptime">--</span>
-                </div>
-            </div>
-
-            <!-- Performance Grade Card -->
-            <div class="card">
-                <h2>Overall Performance</h2>
-                <div class="grade" id="performanceGrade">--</div>
-                <div class="metric">
-                    <span class="metric-label">Score</span>
-                    <span class="metric-value" id="performanceScore">--</span>
-                </div>
-            </div>
-        </div>
-
-        <!-- Latency Chart -->
-        <div class="chart-container">
-            <h2>Latency Trends (Last Hour)</h2>
-            <canvas id="latencyChart"></canvas>
-        </div>
-
-        <!-- Cache Performance Chart -->
-        <div class="chart-container">
-            <h2>Cache Hit Ratio Trends</h2>
-            <canvas id="cacheChart"></canvas>
-        </div>
-    </div>
-
-    <script>
-        let latencyChart, cacheChart;
-        const snapshotHistory = [];
-        const maxDataPoints = 60;
-
-        function initCharts() {
-            const latencyCtx = document.getElementById('latencyChart').getContext('2d');
-            latencyChart = new Chart(latencyCtx, {
-                type: 'line',
-                data: {
-                    labels: [],
-                    datasets: [
-                        {
-                            label: 'P50',
-                            data: [],
-                            borderColor: '#10b981',
-                            backgroundColor: 'rgba(16, 185, 129, 0.1)',
-                            tension: 0.4
-                        },
-                        {
-                            label: 'P95',
-                            data: [],
-                            borderColor: '#f59e0b',
-                            backgroundColor: 'rgba(245, 158, 11, 0.1)',
-                            tension: 0.4
-                        },
-                        {
-                            label: 'P99',
-                            data: [],
-                            borderColor: '#ef4444',
-                            backgroundColor: 'rgba(239, 68, 68, 0.1)',
-                            tension: 0.4
-                        }
-                    ]
-                },
-                options: {
-                    responsive: true,
-                    maintainAspectRatio: false,
-                    scales: {
-                        y: {
-                            beginAtZero: true,
-                            title: {
-                                display: true,
-                                text: 'Latency (ms)'
-                            }
-                        }
-                    }
-                }
-            });
-
-            const cacheCtx = document.getElementById('cacheChart').getContext('2d');
-            cacheChart = new Chart(cacheCtx, {
-                type: 'line',
-                data: {
-                    labels: [],
-                    datasets: [
-                        {
-                            label: 'Hit Ratio',
-                            data: [],
-                            borderColor: '#667eea',
-                            backgroundColor: 'rgba(102, 126, 234, 0.1)',
-                            tension: 0.4,
-                            yAxisID: 'y'
-                        },
-                        {
-                            label: 'Memory Usage (MB)',
-                            data: [],
-                            borderColor: '#764ba2',
-                            backgroundColor: 'rgba(118, 75, 162, 0.1)',
-                            tension: 0.4,
-                            yAxisID: 'y1'
-                        }
-                    ]
-                },
-                options: {
-                    responsive: true,
-                    maintainAspectRatio: false,
-                    scales: {
-                        y: {
-                            type: 'linear',
-                            display: true,
-                            position: 'left',
-                            min: 0,
-                            max: 1,
-                            title: {
-                                display: true,
-                                text: 'Hit Ratio'
-                            }
-                        },
-                        y1: {
-                            type: 'linear',
-                            display: true,
-                            position: 'right',
-                            grid: {
-                                drawOnChartArea: false,
-                            },
-                            title: {
-                                display: true,
-                                text: 'Memory (MB)'
-                            }
-                        }
-                    }
-                }
-            });
-        }
-
-        function updateMetrics(data) {
-            // Update cache metrics
-            document.getElementById('cacheHitRatio').textContent = (data.cache_hit_ratio * 100).toFixed(2) + '%';
-            document.getElementById('cacheHitRatio').className = 'metric-value ' +
-                (data.cache_hit_ratio >= 0.7 ? 'good' : data.cache_hit_ratio >= 0.5 ? 'warning' : 'critical');
-
-            document.getElementById('cacheSize').textContent = data.cache_size + ' / ' + data.cache_capacity;
-            document.getElementById('cacheMemory').textContent = data.cache_memory_usage_mb.toFixed(2) + ' MB';
-            document.getElementById('cacheTotalGets').textContent = data.cache_total_gets.toLocaleString();
-            document.getElementById('cacheEvictions').textContent = data.cache_evictions.toLocaleString();
-
-            // Update latency metrics
-            document.getElementById('latencyP50').textContent = data.latency_p50_ms.toFixed(2) + ' ms';
-            document.getElementById('latencyP95').textContent = data.latency_p95_ms.toFixed(2) + ' ms';
-            document.getElementById('latencyP95').className = 'metric-value ' +
-                (data.latency_p95_ms < 200 ? 'good' : data.latency_p95_ms < 500 ? 'warning' : 'critical');
-
-            document.getElementById('latencyP99').textContent = data.latency_p99_ms.toFixed(2) + ' ms';
-            document.getElementById('latencyP99').className = 'metric-value ' +
-                (data.latency_p99_ms < 500 ? 'good' : data.latency_p99_ms < 1000 ? 'warning' : 'critical');
-
-            document.getElementById('latencyMean').textContent = data.latency_mean_ms.toFixed(2) + ' ms';
-            document.getElementById('latencyMax').textContent = data.latency_max_ms.toFixed(2) + ' ms';
-
-            // Update throughput metrics
-            document.getElementById('throughputRPS').textContent = data.requests_per_second.toFixed(2);
-            document.getElementById('throughputBPS').textContent = (data.bytes_per_second / 1024).toFixed(2) + ' KB/s';
-            document.getElementById('errorRate').textContent = (data.error_rate * 100).toFixed(2) + '%';
-            document.getElementById('errorRate').className = 'metric-value ' +
-                (data.error_rate === 0 ? 'good' : data.error_rate < 0.05 ? 'warning' : 'critical');
-
-            document.getElementById('connReuse').textContent = (data.connection_reuse_rate * 100).toFixed(2) + '%';
-            document.getElementById('uptime').textContent = formatUptime(data.uptime_seconds);
-
-            // Update performance grade
-            document.getElementById('performanceGrade').textContent = data.performance_grade || '--';
-            document.getElementById('performanceScore').textContent = data.performance_score + ' / 100';
-
-            // Update timestamp
-            document.getElementById('lastUpdate').textContent = new Date().toLocaleTimeString();
-
-            // Update charts
-            updateCharts(data);
-        }
-
-        function updateCharts(data) {
-            const timestamp = new Date(data.timestamp).toLocaleTimeString();
-
-            // Update latency chart
-            latencyChart.data.labels.push(timestamp);
-            latencyChart.data.datasets[0].data.push(data.latency_p50_ms);
-            latencyChart.data.datasets[1].data.push(data.latency_p95_ms);
-            latencyChart.data.datasets[2].data.push(data.latency_p99_ms);
-
-            // Update cache chart
-            cacheChart.data.labels.push(timestamp);
-            cacheChart.data.datasets[0].data.push(data.cache_hit_ratio);
-            cacheChart.data.datasets[1].data.push(data.cache_memory_usage_mb);
-
-            // Limit data points
-            if (latencyChart.data.labels.length > maxDataPoints) {
-                latencyChart.data.labels.shift();
-                latencyChart.data.datasets.forEach(ds => ds.data.shift());
-                cacheChart.data.labels.shift();
-                cacheChart.data.datasets.forEach(ds => ds.data.shift());
-            }
-
-            latencyChart.update('none');
-            cacheChart.update('none');
-        }
-
-        function formatUptime(seconds) {
-            const hours = Math.floor(seconds / 3600);
-            const minutes = Math.floor((seconds % 3600) / 60);
-            const secs = Math.floor(seconds % 60);
-            return hours + 'h ' + minutes + 'm ' + secs + 's';
-        }
-
-        async function fetchMetrics() {
-            try {
-                const response = await fetch('/api/current');
-                if (response.ok) {
-                    const data = await response.json();
-                    updateMetrics(data);
-                }
-            } catch (error) {
-                console.error('Failed to fetch metrics:', error);
-            }
-        }
-
-        // Initialize
-        initCharts();
-        fetchMetrics();
-        setInterval(fetchMetrics, {{ .RefreshInterval }});
-    </script>
-</body>
-</html>
-`
+// handleAPIHeatmap returns the latency heatmap of the last benchmark result
+func (d *Dashboard) handleAPIHeatmap(w http.ResponseWriter, r *http.Request) {
+	heatmap := d.collector.GetLatencyHeatmap()
+	if heatmap == nil {
+		http.Error(w, "No latency heatmap available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(heatmap)
+}
+
+// handleCircuits lists every circuit breaker's state and metrics
+func (d *Dashboard) handleCircuits(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	circuits := d.circuits
+	d.mu.RUnlock()
+
+	if circuits == nil {
+		http.Error(w, "No circuit breakers attached", http.StatusServiceUnavailable)
+		return
+	}
+
+	circuits.HandleCircuits(w, r)
+}
+
+// handleConnections reports open, active and idle connections per host
+func (d *Dashboard) handleConnections(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	connections := d.connections
+	d.mu.RUnlock()
+
+	if connections == nil {
+		http.Error(w, "No connection tracker attached", http.StatusServiceUnavailable)
+		return
+	}
+
+	connections.HandleConnections(w, r)
+}
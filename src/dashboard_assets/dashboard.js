@@ -0,0 +1,498 @@
+let latencyChart, cacheChart;
+const maxDataPoints = 60;
+
+// cssVar reads a theme color, so charts follow the theme like the page does
+function cssVar(name) {
+    return getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+}
+
+// LineChart draws snapshot fields over time on a canvas, each dataset on the
+// left axis 'y' or the right axis 'y1'; an axis without min and max scales to
+// the data shown. Dataset colors name theme variables. Scrolling zooms around
+// the cursor, dragging pans and double-clicking shows everything again
+class LineChart {
+    constructor(canvas, datasets, axes) {
+        this.canvas = canvas;
+        this.datasets = datasets.map(ds => Object.assign({axis: 'y', data: []}, ds));
+        this.axes = axes;
+        this.times = [];
+        this.view = null; // [first, last] index shown while zoomed
+        this.plot = {left: 70, width: 1};
+
+        canvas.classList.add('zoomable');
+        canvas.addEventListener('wheel', event => {
+            event.preventDefault();
+            this.zoom(event.deltaY < 0 ? 0.8 : 1.25, (event.offsetX - this.plot.left) / this.plot.width);
+        });
+        canvas.addEventListener('mousedown', event => {
+            this.drag = {x: event.offsetX, view: this.visible()};
+        });
+        canvas.addEventListener('mousemove', event => {
+            if (this.drag) {
+                this.pan(this.drag, event.offsetX);
+            }
+        });
+        window.addEventListener('mouseup', () => this.drag = null);
+        canvas.addEventListener('dblclick', () => {
+            this.view = null;
+            this.draw();
+        });
+    }
+
+    // push appends a live snapshot, dropping the oldest past maxDataPoints
+    push(snapshot) {
+        this.times.push(new Date(snapshot.timestamp).getTime());
+        this.datasets.forEach(ds => ds.data.push(snapshot[ds.field]));
+        if (this.times.length > maxDataPoints) {
+            this.times.shift();
+            this.datasets.forEach(ds => ds.data.shift());
+            if (this.view) {
+                this.view = this.view[0] > 0 ? [this.view[0] - 1, this.view[1] - 1] : null;
+            }
+        }
+    }
+
+    // setData replaces the chart's data with snapshots
+    setData(snapshots) {
+        this.times = snapshots.map(s => new Date(s.timestamp).getTime());
+        this.datasets.forEach(ds => ds.data = snapshots.map(s => s[ds.field]));
+        this.view = null;
+    }
+
+    // visible returns the first and last index shown
+    visible() {
+        return this.view || [0, Math.max(this.times.length - 1, 0)];
+    }
+
+    zoom(factor, at) {
+        const [first, last] = this.visible();
+        const span = last - first;
+        const zoomed = Math.min(Math.max(Math.round(span * factor), 4), this.times.length - 1);
+        if (zoomed <= 0 || zoomed === span) {
+            return;
+        }
+        const start = first + Math.round((span - zoomed) * Math.min(Math.max(at, 0), 1));
+        this.view = zoomed >= this.times.length - 1 ? null : [start, start + zoomed];
+        this.draw();
+    }
+
+    pan(drag, x) {
+        const [first, last] = drag.view;
+        const span = last - first;
+        if (!this.view || span <= 0) {
+            return;
+        }
+        const shift = Math.round((drag.x - x) / this.plot.width * span);
+        const start = Math.min(Math.max(first + shift, 0), this.times.length - 1 - span);
+        this.view = [start, start + span];
+        this.draw();
+    }
+
+    range(axis, first, last) {
+        const options = this.axes[axis];
+        let min = options.min, max = options.max;
+        if (min === undefined || max === undefined) {
+            const values = this.datasets.filter(ds => ds.axis === axis).flatMap(ds => ds.data.slice(first, last + 1));
+            min = options.min !== undefined ? options.min : 0;
+            max = options.max !== undefined ? options.max : Math.max(min + 1e-9, ...values) * 1.1;
+        }
+        return {min, max};
+    }
+
+    label(time, span) {
+        const date = new Date(time);
+        return span > 86400000 ? date.toLocaleDateString() + ' ' + date.toLocaleTimeString([], {hour: '2-digit', minute: '2-digit'}) :
+            date.toLocaleTimeString();
+    }
+
+    draw() {
+        const canvas = this.canvas;
+        canvas.width = canvas.clientWidth;
+        canvas.height = canvas.clientHeight;
+        const ctx = canvas.getContext('2d');
+        ctx.clearRect(0, 0, canvas.width, canvas.height);
+
+        const left = 70, right = this.axes.y1 ? 70 : 20, top = 30, bottom = 30;
+        const width = canvas.width - left - right;
+        const height = canvas.height - top - bottom;
+        this.plot = {left, width};
+        const [first, last] = this.visible();
+        const points = Math.max(last - first, 1);
+        const x = i => left + ((i - first) / points) * width;
+
+        ctx.font = '11px sans-serif';
+        ctx.lineWidth = 1;
+        const ranges = {};
+        Object.keys(this.axes).forEach(axis => {
+            const range = ranges[axis] = this.range(axis, first, last);
+            const options = this.axes[axis];
+            const onLeft = axis === 'y';
+            ctx.fillStyle = cssVar('--text-muted');
+            ctx.textAlign = onLeft ? 'right' : 'left';
+            ctx.textBaseline = 'middle';
+            for (let step = 0; step <= 4; step++) {
+                const value = range.min + (range.max - range.min) * step / 4;
+                const y = top + height - height * step / 4;
+                ctx.fillText(value.toFixed(2), onLeft ? left - 6 : left + width + 6, y);
+                if (onLeft) {
+                    ctx.strokeStyle = cssVar('--grid-line');
+                    ctx.beginPath();
+                    ctx.moveTo(left, y);
+                    ctx.lineTo(left + width, y);
+                    ctx.stroke();
+                }
+            }
+            ctx.textAlign = onLeft ? 'left' : 'right';
+            ctx.textBaseline = 'bottom';
+            ctx.fillText(options.title, onLeft ? 0 : canvas.width, top - 12);
+        });
+
+        if (this.times.length > 0) {
+            ctx.textAlign = 'center';
+            ctx.textBaseline = 'top';
+            const span = this.times[last] - this.times[first];
+            const labelStep = Math.max(1, Math.ceil((last - first + 1) / 8));
+            for (let i = first; i <= last; i += labelStep) {
+                ctx.fillText(this.label(this.times[i], span), x(i), top + height + 8);
+            }
+        }
+
+        let legend = left;
+        ctx.lineWidth = 2;
+        this.datasets.forEach(ds => {
+            const range = ranges[ds.axis];
+            const y = v => top + height - ((v - range.min) / (range.max - range.min)) * height;
+            ctx.strokeStyle = cssVar(ds.color);
+            ctx.beginPath();
+            for (let i = first; i <= last && i < ds.data.length; i++) {
+                i === first ? ctx.moveTo(x(i), y(ds.data[i])) : ctx.lineTo(x(i), y(ds.data[i]));
+            }
+            ctx.stroke();
+
+            ctx.fillStyle = cssVar(ds.color);
+            ctx.fillRect(legend, 4, 12, 12);
+            ctx.fillStyle = cssVar('--text');
+            ctx.textAlign = 'left';
+            ctx.textBaseline = 'top';
+            ctx.fillText(ds.label, legend + 16, 4);
+            legend += ctx.measureText(ds.label).width + 40;
+        });
+    }
+
+    // exportCSV downloads the data shown, zoomed or not, as name.csv
+    exportCSV(name) {
+        const [first, last] = this.visible();
+        const rows = [['timestamp'].concat(this.datasets.map(ds => ds.field)).join(',')];
+        for (let i = first; i <= last && i < this.times.length; i++) {
+            rows.push([new Date(this.times[i]).toISOString()].concat(this.datasets.map(ds => ds.data[i])).join(','));
+        }
+        const link = document.createElement('a');
+        link.href = URL.createObjectURL(new Blob([rows.join('\n') + '\n'], {type: 'text/csv'}));
+        link.download = name + '.csv';
+        link.click();
+        URL.revokeObjectURL(link.href);
+    }
+}
+
+function initCharts() {
+    latencyChart = new LineChart(document.getElementById('latencyChart'), [
+        {label: 'P50', field: 'latency_p50_ms', color: '--good'},
+        {label: 'P95', field: 'latency_p95_ms', color: '--warning'},
+        {label: 'P99', field: 'latency_p99_ms', color: '--critical'}
+    ], {y: {title: 'Latency (ms)', min: 0}});
+
+    cacheChart = new LineChart(document.getElementById('cacheChart'), [
+        {label: 'Hit Ratio', field: 'cache_hit_ratio', color: '--accent'},
+        {label: 'Memory Usage (MB)', field: 'cache_memory_usage_mb', color: '--accent-secondary', axis: 'y1'}
+    ], {y: {title: 'Hit Ratio', min: 0, max: 1}, y1: {title: 'Memory (MB)', min: 0}});
+
+    document.querySelectorAll('.export').forEach(button => button.addEventListener('click', () => {
+        const chart = button.dataset.chart === 'latency' ? latencyChart : cacheChart;
+        chart.exportCSV(button.dataset.chart + '-' + (historyMode ? document.getElementById('historyRange').value : 'live'));
+    }));
+}
+
+// In history mode the charts show stored snapshots over the chosen range
+// instead of following the live ones
+let historyMode = false;
+
+async function fetchHistory() {
+    const range = document.getElementById('historyRange').value;
+    const info = document.getElementById('historyInfo');
+    try {
+        const response = await fetch('/api/history?duration=' + range + '&points=500');
+        if (!response.ok) {
+            info.textContent = 'Failed to load history: ' + await response.text();
+            return;
+        }
+        const snapshots = await response.json();
+        latencyChart.setData(snapshots);
+        cacheChart.setData(snapshots);
+        latencyChart.draw();
+        cacheChart.draw();
+        info.textContent = snapshots.length + ' snapshots; scroll to zoom, drag to pan, double-click to reset';
+    } catch (error) {
+        console.error('Failed to fetch history:', error);
+    }
+}
+
+function setHistoryMode(enabled) {
+    historyMode = enabled;
+    document.getElementById('modeLive').classList.toggle('active', !enabled);
+    document.getElementById('modeHistory').classList.toggle('active', enabled);
+    document.getElementById('historyRange').disabled = !enabled;
+    if (enabled) {
+        fetchHistory();
+        return;
+    }
+    latencyChart.setData([]);
+    cacheChart.setData([]);
+    latencyChart.draw();
+    cacheChart.draw();
+    document.getElementById('historyInfo').textContent = 'Scroll to zoom, drag to pan, double-click to reset';
+}
+
+function updateMetrics(data) {
+    // Update cache metrics
+    document.getElementById('cacheHitRatio').textContent = (data.cache_hit_ratio * 100).toFixed(2) + '%';
+    document.getElementById('cacheHitRatio').className = 'metric-value ' +
+        (data.cache_hit_ratio >= 0.7 ? 'good' : data.cache_hit_ratio >= 0.5 ? 'warning' : 'critical');
+
+    document.getElementById('cacheSize').textContent = data.cache_size + ' / ' + data.cache_capacity;
+    document.getElementById('cacheMemory').textContent = data.cache_memory_usage_mb.toFixed(2) + ' MB';
+    document.getElementById('cacheTotalGets').textContent = data.cache_total_gets.toLocaleString();
+    document.getElementById('cacheEvictions').textContent = data.cache_evictions.toLocaleString();
+
+    // Update latency metrics
+    document.getElementById('latencyP50').textContent = data.latency_p50_ms.toFixed(2) + ' ms';
+    document.getElementById('latencyP95').textContent = data.latency_p95_ms.toFixed(2) + ' ms';
+    document.getElementById('latencyP95').className = 'metric-value ' +
+        (data.latency_p95_ms < 200 ? 'good' : data.latency_p95_ms < 500 ? 'warning' : 'critical');
+
+    document.getElementById('latencyP99').textContent = data.latency_p99_ms.toFixed(2) + ' ms';
+    document.getElementById('latencyP99').className = 'metric-value ' +
+        (data.latency_p99_ms < 500 ? 'good' : data.latency_p99_ms < 1000 ? 'warning' : 'critical');
+
+    document.getElementById('latencyMean').textContent = data.latency_mean_ms.toFixed(2) + ' ms';
+    document.getElementById('latencyMax').textContent = data.latency_max_ms.toFixed(2) + ' ms';
+
+    // Update latency phases
+    document.getElementById('phaseHandshake').textContent = data.dns_p95_ms.toFixed(2) + ' / ' +
+        data.connect_p95_ms.toFixed(2) + ' / ' + data.tls_p95_ms.toFixed(2) + ' ms';
+    document.getElementById('phaseTTFB').textContent = data.ttfb_p95_ms.toFixed(2) + ' ms';
+    document.getElementById('phaseServer').textContent = data.server_p95_ms.toFixed(2) + ' ms';
+    document.getElementById('phaseDownload').textContent = data.download_p95_ms.toFixed(2) + ' ms';
+
+    // Update upstream attribution; without Server-Timing there is none
+    updateUpstream(data);
+
+    // Update throughput metrics
+    document.getElementById('throughputRPS').textContent = data.requests_per_second.toFixed(2);
+    document.getElementById('throughputBPS').textContent = (data.bytes_per_second / 1024).toFixed(2) + ' KB/s';
+    document.getElementById('errorRate').textContent = (data.error_rate * 100).toFixed(2) + '%';
+    document.getElementById('errorRate').className = 'metric-value ' +
+        (data.error_rate === 0 ? 'good' : data.error_rate < 0.05 ? 'warning' : 'critical');
+
+    document.getElementById('connReuse').textContent = (data.connection_reuse_rate * 100).toFixed(2) + '%';
+    document.getElementById('uptime').textContent = formatUptime(data.uptime_seconds);
+
+    // Update performance grade
+    document.getElementById('performanceGrade').textContent = data.performance_grade || '--';
+    document.getElementById('performanceScore').textContent = data.performance_score + ' / 100';
+
+    // Update timestamp
+    document.getElementById('lastUpdate').textContent = new Date().toLocaleTimeString();
+
+    // Update charts
+    updateCharts(data);
+}
+
+function updateUpstream(data) {
+    const phases = document.getElementById('serverTimingPhases');
+    phases.textContent = '';
+    if (!data.upstream_verdict) {
+        document.getElementById('upstreamTime').textContent = '--';
+        document.getElementById('networkTime').textContent = '--';
+        document.getElementById('upstreamShare').textContent = 'No Server-Timing';
+        document.getElementById('upstreamShare').className = 'metric-value';
+        return;
+    }
+
+    document.getElementById('upstreamTime').textContent = data.upstream_p50_ms.toFixed(2) + ' / ' +
+        data.upstream_p95_ms.toFixed(2) + ' ms';
+    document.getElementById('networkTime').textContent = data.network_p50_ms.toFixed(2) + ' / ' +
+        data.network_p95_ms.toFixed(2) + ' ms';
+    const verdicts = {upstream: 'API is slow', network: 'network is slow', mixed: 'mixed'};
+    document.getElementById('upstreamShare').textContent = (data.upstream_share * 100).toFixed(0) + '% (' +
+        verdicts[data.upstream_verdict] + ')';
+    document.getElementById('upstreamShare').className = 'metric-value' +
+        (data.upstream_verdict === 'mixed' ? '' : ' warning');
+
+    (data.server_timing || []).forEach(phase => {
+        const row = document.createElement('div');
+        row.className = 'metric';
+        const label = document.createElement('span');
+        label.className = 'metric-label';
+        label.textContent = phase.name + ' P50 / P95';
+        const value = document.createElement('span');
+        value.className = 'metric-value';
+        value.textContent = phase.p50_ms.toFixed(2) + ' / ' + phase.p95_ms.toFixed(2) + ' ms';
+        row.appendChild(label);
+        row.appendChild(value);
+        phases.appendChild(row);
+    });
+}
+
+function updateCharts(data) {
+    if (historyMode) {
+        return;
+    }
+    latencyChart.push(data);
+    cacheChart.push(data);
+    latencyChart.draw();
+    cacheChart.draw();
+}
+
+function drawHeatmap(heatmap) {
+    const canvas = document.getElementById('latencyHeatmap');
+    canvas.width = canvas.clientWidth;
+    canvas.height = canvas.clientHeight;
+    const ctx = canvas.getContext('2d');
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+
+    const left = 70, bottom = 30, top = 10;
+    const rows = heatmap.latency_bounds_ms.length + 1;
+    const columns = heatmap.counts.length;
+    const cellWidth = (canvas.width - left) / columns;
+    const cellHeight = (canvas.height - bottom - top) / rows;
+
+    // Darker cells hold more requests; failed requests show as a red strip
+    heatmap.counts.forEach((column, x) => {
+        column.forEach((count, y) => {
+            if (count === 0) {
+                return;
+            }
+            const intensity = Math.sqrt(count / heatmap.max);
+            ctx.globalAlpha = 0.15 + 0.85 * intensity;
+            ctx.fillStyle = cssVar('--accent');
+            ctx.fillRect(left + x * cellWidth, top + (rows - 1 - y) * cellHeight, Math.ceil(cellWidth), Math.ceil(cellHeight));
+        });
+        ctx.globalAlpha = 1;
+        if (heatmap.errors[x] > 0) {
+            ctx.fillStyle = cssVar('--critical');
+            ctx.fillRect(left + x * cellWidth, canvas.height - bottom, Math.ceil(cellWidth), 4);
+        }
+    });
+
+    ctx.fillStyle = cssVar('--text-muted');
+    ctx.font = '11px sans-serif';
+    ctx.textAlign = 'right';
+    ctx.textBaseline = 'middle';
+    for (let y = 0; y < rows; y++) {
+        const label = y < rows - 1 ? '≤' + heatmap.latency_bounds_ms[y] + ' ms' :
+            '>' + heatmap.latency_bounds_ms[rows - 2] + ' ms';
+        ctx.fillText(label, left - 6, top + (rows - 1 - y + 0.5) * cellHeight);
+    }
+
+    ctx.textAlign = 'center';
+    ctx.textBaseline = 'top';
+    const start = new Date(heatmap.start).getTime();
+    const widthMs = heatmap.bucket_width / 1e6;
+    const step = Math.max(1, Math.ceil(columns / 8));
+    for (let x = 0; x < columns; x += step) {
+        const label = new Date(start + x * widthMs).toLocaleTimeString();
+        ctx.fillText(label, left + (x + 0.5) * cellWidth, canvas.height - bottom + 8);
+    }
+}
+
+let lastHeatmap;
+
+async function fetchHeatmap() {
+    try {
+        const response = await fetch('/api/heatmap');
+        if (response.ok) {
+            lastHeatmap = await response.json();
+            drawHeatmap(lastHeatmap);
+        }
+    } catch (error) {
+        console.error('Failed to fetch latency heatmap:', error);
+    }
+}
+
+function formatUptime(seconds) {
+    const hours = Math.floor(seconds / 3600);
+    const minutes = Math.floor((seconds % 3600) / 60);
+    const secs = Math.floor(seconds % 60);
+    return hours + 'h ' + minutes + 'm ' + secs + 's';
+}
+
+async function fetchMetrics() {
+    try {
+        const response = await fetch('/api/current');
+        if (response.ok) {
+            const data = await response.json();
+            updateMetrics(data);
+        }
+    } catch (error) {
+        console.error('Failed to fetch metrics:', error);
+    }
+}
+
+function updateConnections(pool) {
+    document.getElementById('poolOpen').textContent = pool.open + ' (' + pool.active + ' / ' + pool.idle + ')';
+    document.getElementById('poolReuse').textContent = pool.dialed.toLocaleString() + ' / ' + pool.reuses.toLocaleString();
+    document.getElementById('poolReuseRatio').textContent = (pool.reuse_ratio * 100).toFixed(2) + '%';
+    document.getElementById('poolReuseRatio').className = 'metric-value ' +
+        (pool.reuse_ratio >= 0.8 ? 'good' : pool.reuse_ratio >= 0.5 ? 'warning' : 'critical');
+    document.getElementById('poolHosts').textContent = pool.hosts.map(h => h.host + ': ' + h.open).join(', ') || '--';
+}
+
+async function fetchConnections() {
+    try {
+        const response = await fetch('/metrics/connections');
+        if (response.ok) {
+            updateConnections(await response.json());
+        }
+    } catch (error) {
+        console.error('Failed to fetch connection pool:', error);
+    }
+}
+
+// The theme cycles through auto (following the system), light and dark; a
+// choice made here is remembered over the server's default
+const themes = ['auto', 'light', 'dark'];
+
+function applyTheme(theme) {
+    document.documentElement.dataset.theme = theme;
+    document.getElementById('themeToggle').textContent = 'Theme: ' + theme;
+    latencyChart.draw();
+    cacheChart.draw();
+    if (lastHeatmap) {
+        drawHeatmap(lastHeatmap);
+    }
+}
+
+function toggleTheme() {
+    const current = themes.indexOf(document.documentElement.dataset.theme);
+    const theme = themes[(current + 1) % themes.length];
+    localStorage.setItem('dashboardTheme', theme);
+    applyTheme(theme);
+}
+
+// Initialize
+const refreshInterval = Number(document.body.dataset.refreshInterval);
+initCharts();
+if (themes.includes(localStorage.getItem('dashboardTheme'))) {
+    applyTheme(localStorage.getItem('dashboardTheme'));
+}
+document.getElementById('themeToggle').addEventListener('click', toggleTheme);
+document.getElementById('modeLive').addEventListener('click', () => setHistoryMode(false));
+document.getElementById('modeHistory').addEventListener('click', () => setHistoryMode(true));
+document.getElementById('historyRange').addEventListener('change', fetchHistory);
+window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', () => applyTheme(document.documentElement.dataset.theme));
+fetchMetrics();
+fetchConnections();
+fetchHeatmap();
+setInterval(fetchMetrics, refreshInterval);
+setInterval(fetchConnections, refreshInterval);
+setInterval(fetchHeatmap, refreshInterval);
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getDashboard requests path from a dashboard with options
+func getDashboard(t *testing.T, options DashboardOptions, path string) (int, string) {
+	t.Helper()
+	d := &Dashboard{refreshInterval: 2 * time.Second, options: options, assets: options.assets()}
+	recorder := httptest.NewRecorder()
+	d.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return recorder.Code, string(body)
+}
+
+// TestDashboardEmbeddedAssets tests that the page and its assets are served
+// from the binary and reference nothing outside the dashboard
+func TestDashboardEmbeddedAssets(t *testing.T) {
+	code, page := getDashboard(t, DashboardOptions{}, "/")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	for _, want := range []string{`data-theme="auto"`, `data-refresh-interval="2000"`, "<title>API Latency Optimizer - Monitoring Dashboard</title>", `href="/assets/dashboard.css"`, `src="/assets/dashboard.js"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+
+	for _, asset := range []string{"dashboard.css", "dashboard.js"} {
+		if code, body := getDashboard(t, DashboardOptions{}, "/assets/"+asset); code != http.StatusOK || body == "" {
+			t.Errorf("Expected %s to be served, got status %d", asset, code)
+		}
+	}
+
+	fs.WalkDir(dashboardFiles, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, _ := dashboardFiles.ReadFile(path)
+		if strings.Contains(string(data), "https://") || strings.Contains(string(data), "http://") {
+			t.Errorf("Expected %s to load nothing from outside the dashboard", path)
+		}
+		return nil
+	})
+}
+
+// TestDashboardBranding tests the theme, title, logo and accent options
+func TestDashboardBranding(t *testing.T) {
+	options := DashboardOptions{Theme: DashboardThemeDark, Title: "Acme <API>", Logo: "/assets/logo.svg", Accent: "#0f766e"}
+	_, page := getDashboard(t, options, "/")
+	for _, want := range []string{`data-theme="dark"`, "Acme &lt;API&gt;", `<img class="logo" src="/assets/logo.svg"`, "--accent: #0f766e"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+}
+
+// TestDashboardAssetsOverride tests that an assets directory replaces
+// embedded files and adds its own, leaving the rest embedded
+func TestDashboardAssetsOverride(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dashboard.css"), []byte("body { background: black; }"), 0644)
+	os.WriteFile(filepath.Join(dir, "logo.svg"), []byte("<svg></svg>"), 0644)
+	options := DashboardOptions{AssetsDir: dir}
+
+	if _, body := getDashboard(t, options, "/assets/dashboard.css"); body != "body { background: black; }" {
+		t.Errorf("Expected the overriding stylesheet, got %q", body)
+	}
+	if _, body := getDashboard(t, options, "/assets/logo.svg"); body != "<svg></svg>" {
+		t.Errorf("Expected the added logo, got %q", body)
+	}
+	if _, body := getDashboard(t, options, "/assets/dashboard.js"); !strings.Contains(body, "class LineChart") {
+		t.Errorf("Expected the embedded script, got %q", body)
+	}
+
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>{{ .Title }}</h1>"), 0644)
+	if _, page := getDashboard(t, options, "/"); page != "<h1>API Latency Optimizer</h1>" {
+		t.Errorf("Expected the overriding page, got %q", page)
+	}
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("{{ .Title"), 0644)
+	if code, _ := getDashboard(t, options, "/"); code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a broken template, got %d", code)
+	}
+}
+
+// TestDashboardOptionsValidate tests rejected dashboard options
+func TestDashboardOptionsValidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.html")
+	os.WriteFile(file, nil, 0644)
+
+	tests := []struct {
+		name    string
+		options DashboardOptions
+		want    string
+	}{
+		{"valid", DashboardOptions{Theme: DashboardThemeLight, Accent: "#fff", AssetsDir: filepath.Dir(file)}, ""},
+		{"theme", DashboardOptions{Theme: "solarized"}, `unknown dashboard theme "solarized"`},
+		{"accent", DashboardOptions{Accent: "red; background: url(x)"}, "must be a hex color"},
+		{"missing assets", DashboardOptions{AssetsDir: filepath.Join(filepath.Dir(file), "missing")}, "invalid dashboard assets"},
+		{"assets file", DashboardOptions{AssetsDir: file}, "is not a directory"},
+	}
+
+	for _, tt := range tests {
+		err := tt.options.Validate()
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: Expected no error, got %v", tt.name, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
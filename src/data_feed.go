@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DataFeedConfig substitutes rows of a data file into requests, so a
+// benchmark spreads over many resources instead of hammering one. A
+// {{column}} placeholder in the target URL, a custom header or the body is
+// replaced by the row's value; values in the URL are escaped. Each worker
+// takes rows from its own share of the file, so concurrent workers never
+// send the same row unless there are fewer rows than workers, and wraps
+// around once its share is used up
+type DataFeedConfig struct {
+	File string `yaml:"file"` // .csv with a header row, or .jsonl with one object per line
+
+	// Shuffle the rows once when loading; Seed makes the order
+	// reproducible, zero seeds from the clock
+	Shuffle bool  `yaml:"shuffle"`
+	Seed    int64 `yaml:"seed"`
+}
+
+// DataFeedStats describes how much of a data file a run used
+type DataFeedStats struct {
+	File     string `json:"file"`
+	Rows     int    `json:"rows"`
+	RowsUsed int    `json:"rows_used"` // Distinct rows sent at least once
+}
+
+// placeholderPattern matches {{column}}, allowing spaces inside the braces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// DataFeed hands out data file rows to workers
+type DataFeed struct {
+	file    string
+	columns map[string]bool
+	rows    []map[string]string
+	workers int
+
+	mu        sync.Mutex
+	picks     []int // Rows taken by each worker so far
+	used      []bool
+	usedCount int
+}
+
+// LoadDataFeed reads the data file in config and shares its rows among
+// workers
+func LoadDataFeed(config *DataFeedConfig, workers int) (*DataFeed, error) {
+	file, err := os.Open(config.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer file.Close()
+
+	var rows []map[string]string
+	switch strings.ToLower(filepath.Ext(config.File)) {
+	case ".csv":
+		rows, err = readCSVRows(file)
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONLRows(file)
+	default:
+		return nil, fmt.Errorf("data file %s must be .csv or .jsonl", config.File)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data file %s: %w", config.File, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("data file %s has no rows", config.File)
+	}
+
+	if config.Shuffle {
+		seed := config.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	}
+
+	columns := make(map[string]bool)
+	for _, row := range rows {
+		for column := range row {
+			columns[column] = true
+		}
+	}
+	workers = max(workers, 1)
+	return &DataFeed{
+		file:    config.File,
+		columns: columns,
+		rows:    rows,
+		workers: workers,
+		picks:   make([]int, workers),
+		used:    make([]bool, len(rows)),
+	}, nil
+}
+
+// readCSVRows reads CSV records keyed by the header row
+func readCSVRows(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSONLRows reads one JSON object per line; values that are not
+// strings are substituted as JSON
+func readJSONLRows(r io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := make(map[string]string, len(object))
+		for column, raw := range object {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				row[column] = s
+			} else {
+				row[column] = string(raw)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// Validate checks that every placeholder in templates names a column
+func (f *DataFeed) Validate(templates ...string) error {
+	var missing []string
+	for _, template := range templates {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+			if !f.columns[match[1]] {
+				missing = append(missing, match[1])
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("data file %s has no column %s", f.file, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Next returns the next row of worker's share. With at least as many rows
+// as workers, worker w takes rows w, w+workers, w+2*workers and so on
+func (f *DataFeed) Next(worker int) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	worker %= f.workers
+	pick := f.picks[worker]
+	f.picks[worker]++
+
+	var index int
+	if len(f.rows) >= f.workers {
+		share := (len(f.rows) - worker + f.workers - 1) / f.workers
+		index = worker + (pick%share)*f.workers
+	} else {
+		index = (worker + pick*f.workers) % len(f.rows)
+	}
+	if !f.used[index] {
+		f.used[index] = true
+		f.usedCount++
+	}
+	return f.rows[index]
+}
+
+// Stats reports how many rows were sent so far
+func (f *DataFeed) Stats() *DataFeedStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &DataFeedStats{File: f.file, Rows: len(f.rows), RowsUsed: f.usedCount}
+}
+
+// expandPlaceholders replaces {{column}} in s with row's values, passed
+// through escape if it is not nil
+func expandPlaceholders(s string, row map[string]string, escape func(string) string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		value := row[placeholderPattern.FindStringSubmatch(match)[1]]
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+}
+
+// expandURL substitutes row into a URL template, escaping values as path
+// segments before the query and as query values after it
+func expandURL(target string, row map[string]string) string {
+	path, query, hasQuery := strings.Cut(target, "?")
+	expanded := expandPlaceholders(path, row, url.PathEscape)
+	if hasQuery {
+		expanded += "?" + expandPlaceholders(query, row, url.QueryEscape)
+	}
+	return expanded
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeDataFile writes content to a data file named name
+func writeDataFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+	return path
+}
+
+// TestDataFeedPartition tests that each worker takes rows from its own share
+func TestDataFeedPartition(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		workers int
+		picks   int
+		want    [][]string // Rows taken by each worker
+	}{
+		{"shares", 7, 3, 4, [][]string{{"0", "3", "6", "0"}, {"1", "4", "1", "4"}, {"2", "5", "2", "5"}}},
+		{"fewer rows than workers", 2, 3, 2, [][]string{{"0", "1"}, {"1", "0"}, {"0", "1"}}},
+	}
+
+	for _, tt := range tests {
+		content := "id\n"
+		for i := 0; i < tt.rows; i++ {
+			content += strconv.Itoa(i) + "\n"
+		}
+		feed, err := LoadDataFeed(&DataFeedConfig{File: writeDataFile(t, "ids.csv", content)}, tt.workers)
+		if err != nil {
+			t.Fatalf("%s: Failed to load data file: %v", tt.name, err)
+		}
+		got := make([][]string, tt.workers)
+		for pick := 0; pick < tt.picks; pick++ {
+			for worker := 0; worker < tt.workers; worker++ {
+				got[worker] = append(got[worker], feed.Next(worker)["id"])
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.want, got)
+		}
+		if stats := feed.Stats(); stats.RowsUsed != tt.rows {
+			t.Errorf("%s: Expected all %d rows used, got %+v", tt.name, tt.rows, stats)
+		}
+	}
+}
+
+// TestBenchmarkerDataFeed tests that rows fill the URL, headers and body,
+// escaped in the URL, with distinct rows per worker
+func TestBenchmarkerDataFeed(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		seen = append(seen, r.URL.EscapedPath()+" "+r.URL.Query().Get("name")+" "+r.Header.Get("X-User")+" "+string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	data := writeDataFile(t, "users.jsonl", `{"id": "a/1", "name": "Ann Lee"}
+{"id": 2, "name": "Bob"}
+
+{"id": 3, "name": "Cy & Di"}
+{"id": 4, "name": "Dee"}
+`)
+	b := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL + "/users/{{id}}?name={{ name }}",
+		TotalRequests: 4,
+		Concurrency:   2,
+		Method:        "POST",
+		Body:          []byte(`{"user": {{id}}}`),
+		CustomHeaders: map[string]string{"X-User": "{{name}}"},
+		Data:          &DataFeedConfig{File: data},
+	})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	sort.Strings(seen)
+	want := []string{
+		`/users/2 Bob Bob {"user": 2}`,
+		`/users/3 Cy & Di Cy & Di {"user": 3}`,
+		`/users/4 Dee Dee {"user": 4}`,
+		`/users/a%2F1 Ann Lee Ann Lee {"user": a/1}`,
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected requests %q, got %q", want, seen)
+	}
+	if result.Data == nil || result.Data.Rows != 4 || result.Data.RowsUsed != 4 {
+		t.Errorf("Expected all 4 rows used, got %+v", result.Data)
+	}
+}
+
+// TestLoadDataFeedInvalid tests rejected data files and templates
+func TestLoadDataFeedInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"extension", "ids.txt", "id\n1\n", "must be .csv or .jsonl"},
+		{"empty csv", "ids.csv", "id\n", "has no rows"},
+		{"ragged csv", "ids.csv", "id,name\n1\n", "wrong number of fields"},
+		{"bad jsonl", "ids.jsonl", "{\"id\": 1}\n[1]\n", "line 2"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadDataFeed(&DataFeedConfig{File: writeDataFile(t, tt.file, tt.content)}, 1)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	b := NewBenchmarker(BenchmarkConfig{
+		TargetURL: "http://example.com/{{id}}/{{missing}}",
+		Data:      &DataFeedConfig{File: writeDataFile(t, "ids.csv", "id\n1\n")},
+	})
+	if _, err := b.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "has no column missing") {
+		t.Errorf("Expected an unknown column error, got %v", err)
+	}
+}
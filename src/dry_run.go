@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// defaultAssumedLatency is the request latency a plan assumes when neither
+// the run's rate nor PlanOptions say how fast requests go
+const defaultAssumedLatency = 100 * time.Millisecond
+
+// planTokenSamples caps the workload samples drawn to estimate token counts
+const planTokenSamples = 1000
+
+// PlanOptions are the assumptions a dry run estimates with
+type PlanOptions struct {
+	// Latency of every request when a run sets no rate; zero means 100ms
+	AssumedLatency time.Duration
+
+	// Dollars per million prompt and output tokens; without them a plan
+	// counts tokens but not their cost
+	InputPrice  float64
+	OutputPrice float64
+}
+
+// RunPlan is what one run would send, warmup included, with every target,
+// connection variant or path expanded
+type RunPlan struct {
+	Name       string        `json:"name"`
+	Variants   []string      `json:"variants,omitempty"` // Targets, connection variants or paths run interleaved
+	Iterations int           `json:"iterations"`
+	Warmup     int           `json:"warmup_iterations"`
+	Requests   int           `json:"requests"`
+	Duration   time.Duration `json:"duration"`
+	Pacing     string        `json:"pacing"` // What the duration was estimated from
+
+	// Tokens of a generated workload; output tokens are an upper bound as
+	// requests may stop before max_tokens
+	PromptTokens int64   `json:"prompt_tokens,omitempty"`
+	OutputTokens int64   `json:"output_tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+}
+
+// SuitePlan is what a suite would send
+type SuitePlan struct {
+	Name         string        `json:"name"`
+	Runs         []RunPlan     `json:"runs"`
+	Requests     int           `json:"requests"`
+	Duration     time.Duration `json:"duration"`
+	PromptTokens int64         `json:"prompt_tokens,omitempty"`
+	OutputTokens int64         `json:"output_tokens,omitempty"`
+	Cost         float64       `json:"cost,omitempty"`
+}
+
+// PlanSuite validates every run of suite, loading its workload, script, data
+// file and plugins, and estimates what running it would take without
+// sending any request
+func PlanSuite(suite *BenchmarkSuite, options PlanOptions) (*SuitePlan, error) {
+	plan := &SuitePlan{Name: suite.Name}
+	for i := range suite.Runs {
+		run, err := planRun(&suite.Runs[i], options)
+		if err != nil {
+			return nil, fmt.Errorf("run %s: %w", suite.Runs[i].Name, err)
+		}
+		plan.add(run)
+	}
+	return plan, nil
+}
+
+// add appends run to the suite's runs and totals
+func (p *SuitePlan) add(run *RunPlan) {
+	p.Runs = append(p.Runs, *run)
+	p.Requests += run.Requests
+	p.Duration += run.Duration
+	p.PromptTokens += run.PromptTokens
+	p.OutputTokens += run.OutputTokens
+	p.Cost += run.Cost
+}
+
+// planRun validates run and estimates it
+func planRun(run *BenchmarkRun, options PlanOptions) (*RunPlan, error) {
+	plan := &RunPlan{Name: run.Name, Iterations: run.Iterations, Warmup: run.WarmupIterations}
+	switch {
+	case len(run.Targets) > 1:
+		plan.Variants = run.Targets
+	case len(run.ConnectionVariants) > 0:
+		for _, variant := range run.ConnectionVariants {
+			plan.Variants = append(plan.Variants, variant.Name)
+		}
+	case run.ColdPath:
+		plan.Variants = []string{PathOptimized, PathCold}
+	}
+
+	configs := []BenchmarkConfig{run.Config}
+	if len(run.Targets) > 1 {
+		configs = configs[:0]
+		for _, target := range run.Targets {
+			config := run.Config
+			config.TargetURL = target
+			configs = append(configs, config)
+		}
+	}
+	var b *Benchmarker
+	for _, config := range configs {
+		b = NewBenchmarker(config)
+		if err := b.prepare(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each measured iteration after the first, or round of variants, is
+	// preceded by a 2s pause
+	variants := max(len(plan.Variants), 1)
+	iterations := (run.Iterations + run.WarmupIterations) * variants
+	perIteration := b.config.TotalRequests
+	plan.Requests = iterations * perIteration
+
+	var iteration time.Duration
+	iteration, plan.Pacing = estimateIteration(b.config, options)
+	plan.Duration = time.Duration(iterations) * iteration
+	if run.Iterations > 1 {
+		plan.Duration += time.Duration(run.Iterations-1) * 2 * time.Second
+	}
+
+	if b.workload != nil {
+		prompt, output := workloadTokens(b.workload, perIteration)
+		plan.PromptTokens = prompt * int64(iterations)
+		plan.OutputTokens = output * int64(iterations)
+		plan.Cost = tokenCost(plan.PromptTokens, plan.OutputTokens, options)
+	}
+	return plan, nil
+}
+
+// estimateIteration returns how long one iteration of config takes: the
+// slower of its rate and its concurrency at the assumed latency
+func estimateIteration(config BenchmarkConfig, options PlanOptions) (time.Duration, string) {
+	latency := options.AssumedLatency
+	if latency <= 0 {
+		latency = defaultAssumedLatency
+	}
+	waves := math.Ceil(float64(config.TotalRequests) / float64(config.Concurrency))
+	duration := time.Duration(waves * float64(latency))
+	pacing := fmt.Sprintf("%d concurrent at an assumed %v per request", config.Concurrency, latency)
+
+	rate := config.TargetRPS
+	if config.RateLimit != nil && config.RateLimit.Global.Rate > 0 {
+		rate = config.RateLimit.Global.Rate
+	}
+	if rate > 0 {
+		if paced := time.Duration(float64(config.TotalRequests) / rate * float64(time.Second)); paced > duration {
+			return paced, fmt.Sprintf("paced at %.2f req/s", rate)
+		}
+	}
+	return duration, pacing
+}
+
+// workloadTokens estimates the prompt tokens and max_tokens of requests
+// bodies drawn from workload, sampling at most planTokenSamples
+func workloadTokens(workload *WorkloadGenerator, requests int) (prompt, output int64) {
+	samples := min(requests, planTokenSamples)
+	if samples <= 0 {
+		return 0, 0
+	}
+	for i := 0; i < samples; i++ {
+		sample := workload.Sample(i)
+		prompt += int64(sample.PromptTokens)
+		output += int64(sample.MaxTokens)
+	}
+	scale := float64(requests) / float64(samples)
+	return int64(math.Round(float64(prompt) * scale)), int64(math.Round(float64(output) * scale))
+}
+
+// tokenCost prices token counts at options' per-million-token prices
+func tokenCost(prompt, output int64, options PlanOptions) float64 {
+	return float64(prompt)/1e6*options.InputPrice + float64(output)/1e6*options.OutputPrice
+}
+
+// planSoak estimates a soak test of config
+func planSoak(config SoakConfig) (*RunPlan, error) {
+	if err := NewBenchmarker(config.Benchmark).prepare(); err != nil {
+		return nil, err
+	}
+	plan := &RunPlan{
+		Name:       "soak",
+		Iterations: 1,
+		Requests:   int(config.RPS * config.Duration.Seconds()),
+		Duration:   config.Duration,
+		Pacing:     fmt.Sprintf("paced at %.2f req/s", config.RPS),
+	}
+	return plan, nil
+}
+
+// planRPSCeiling bounds a throughput ceiling search of config; how many
+// requests it sends depends on where the search stops
+func planRPSCeiling(config RPSCeilingConfig) (*RunPlan, error) {
+	if err := NewBenchmarker(config.Benchmark).prepare(); err != nil {
+		return nil, err
+	}
+	steps := config.MaxSteps
+	if steps <= 0 {
+		steps = DefaultRPSCeilingConfig().MaxSteps
+	}
+	plan := &RunPlan{
+		Name:       "rps_ceiling",
+		Iterations: steps,
+		Duration:   time.Duration(steps) * config.StepDuration,
+		Pacing:     fmt.Sprintf("up to %d steps of %v from %.2f req/s", steps, config.StepDuration, config.StartRPS),
+	}
+	return plan, nil
+}
+
+// printPlan prints the plans of suites and their total
+func printPlan(plans []*SuitePlan, options PlanOptions) {
+	var total SuitePlan
+	for _, suite := range plans {
+		fmt.Printf("\n=== Plan: %s ===\n", suite.Name)
+		for _, run := range suite.Runs {
+			printRunPlan(&run, options)
+		}
+		total.Requests += suite.Requests
+		total.Duration += suite.Duration
+		total.PromptTokens += suite.PromptTokens
+		total.OutputTokens += suite.OutputTokens
+		total.Cost += suite.Cost
+	}
+
+	fmt.Printf("\n--- Total ---\n")
+	fmt.Printf("Requests: %d | Estimated duration: %v\n", total.Requests, total.Duration.Round(time.Second))
+	printPlanTokens("", total.PromptTokens, total.OutputTokens, total.Cost, options)
+	fmt.Printf("\nDry run: the configuration is valid and no requests were sent\n")
+}
+
+// printRunPlan prints one run's plan
+func printRunPlan(run *RunPlan, options PlanOptions) {
+	fmt.Printf("\nRun %s: %d iterations", run.Name, run.Iterations)
+	if run.Warmup > 0 {
+		fmt.Printf(" + %d warmup", run.Warmup)
+	}
+	if len(run.Variants) > 0 {
+		fmt.Printf(" x %d variants", len(run.Variants))
+	}
+	fmt.Println()
+	for _, variant := range run.Variants {
+		fmt.Printf("  - %s\n", variant)
+	}
+	requests := "depends on the search"
+	if run.Requests > 0 {
+		requests = fmt.Sprintf("%d", run.Requests)
+	}
+	fmt.Printf("  Requests: %s | Estimated duration: %v (%s)\n", requests, run.Duration.Round(time.Second), run.Pacing)
+	printPlanTokens("  ", run.PromptTokens, run.OutputTokens, run.Cost, options)
+}
+
+// printPlanTokens prints token counts and their cost, if any
+func printPlanTokens(indent string, prompt, output int64, cost float64, options PlanOptions) {
+	if prompt == 0 && output == 0 {
+		return
+	}
+	fmt.Printf("%sTokens: ~%d prompt, up to %d output", indent, prompt, output)
+	if options.InputPrice > 0 || options.OutputPrice > 0 {
+		fmt.Printf(" | Estimated cost: up to $%.2f", cost)
+	}
+	fmt.Println()
+}
+
+// PlanOrchestration validates an orchestration and plans its suites in the
+// order they would run
+func PlanOrchestration(path string, options PlanOptions) ([]*SuitePlan, error) {
+	orchestration, err := LoadOrchestration(path)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := orchestration.order()
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]*SuitePlan, 0, len(ordered))
+	for _, orchestrated := range ordered {
+		suite, err := LoadSuiteConfig(orchestrated.Config)
+		if err != nil {
+			return nil, fmt.Errorf("suite %s: %w", orchestrated.Name, err)
+		}
+		plan, err := PlanSuite(suite, options)
+		if err != nil {
+			return nil, fmt.Errorf("suite %s: %w", orchestrated.Name, err)
+		}
+		plan.Name = orchestrated.Name
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
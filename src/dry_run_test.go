@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPlanSuite tests that a plan expands targets and prices the workload
+// without sending requests
+func TestPlanSuite(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	suite := &BenchmarkSuite{
+		Name: "plan",
+		Runs: []BenchmarkRun{
+			{
+				Name: "ab",
+				Config: BenchmarkConfig{
+					TargetURL:     server.URL,
+					TotalRequests: 40,
+					Concurrency:   4,
+					Workload:      DefaultWorkloadConfig(writeCorpus(t, 100)),
+				},
+				Iterations:       3,
+				WarmupIterations: 1,
+				Targets:          []string{server.URL, server.URL + "/v2"},
+			},
+			{
+				Name:       "paced",
+				Config:     BenchmarkConfig{TargetURL: server.URL, TotalRequests: 20, Concurrency: 10, TargetRPS: 10},
+				Iterations: 1,
+			},
+		},
+	}
+
+	plan, err := PlanSuite(suite, PlanOptions{AssumedLatency: 50 * time.Millisecond, InputPrice: 3, OutputPrice: 15})
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected no requests to be sent, got %d", requests.Load())
+	}
+
+	ab := plan.Runs[0]
+	if len(ab.Variants) != 2 || ab.Requests != 320 {
+		t.Errorf("Expected 320 requests over 2 targets, got %d over %v", ab.Requests, ab.Variants)
+	}
+	// 8 iterations of 10 waves at 50ms, and 2 pauses of 2s
+	if ab.Duration != 8*time.Second {
+		t.Errorf("Expected 8s, got %v", ab.Duration)
+	}
+	if ab.PromptTokens != 32000 || ab.OutputTokens != 32000 || math.Abs(ab.Cost-0.576) > 1e-9 {
+		t.Errorf("Expected 32000 tokens each way costing $0.576, got %d, %d and $%v", ab.PromptTokens, ab.OutputTokens, ab.Cost)
+	}
+
+	paced := plan.Runs[1]
+	if paced.Requests != 20 || paced.Duration != 2*time.Second || paced.Pacing != "paced at 10.00 req/s" {
+		t.Errorf("Expected 20 requests paced over 2s, got %+v", paced)
+	}
+	if plan.Requests != 340 || plan.Duration != 10*time.Second {
+		t.Errorf("Expected 340 requests over 10s in total, got %d over %v", plan.Requests, plan.Duration)
+	}
+
+	suite.Runs[1].Config.Redirects = &RedirectPolicy{MaxRedirects: -1}
+	if _, err := PlanSuite(suite, PlanOptions{}); err == nil {
+		t.Error("Expected an invalid run to fail the plan")
+	}
+}
+
+// TestParseTokenPrice tests the -token-price flag
+func TestParseTokenPrice(t *testing.T) {
+	tests := []struct {
+		value         string
+		input, output float64
+		wantErr       bool
+	}{
+		{"", 0, 0, false},
+		{"3,15", 3, 15, false},
+		{" 0.25 , 1.25 ", 0.25, 1.25, false},
+		{"3", 0, 0, true},
+		{"3,x", 0, 0, true},
+		{"-1,2", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		input, output, err := parseTokenPrice(tt.value)
+		if (err != nil) != tt.wantErr || input != tt.input || output != tt.output {
+			t.Errorf("Expected %v, %v (error %v) for %q, got %v, %v (%v)", tt.input, tt.output, tt.wantErr, tt.value, input, output, err)
+		}
+	}
+}
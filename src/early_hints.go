@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Server push is not measured: Go's HTTP/2 client advertises
+// SETTINGS_ENABLE_PUSH=0, so servers never push to it. 103 Early Hints are
+// the replacement browsers use and are measured per request
+
+// EarlyHintsStats summarizes the 103 Early Hints received during a run
+type EarlyHintsStats struct {
+	Requests int     `json:"requests"` // Successful requests that received at least one 103
+	Rate     float64 `json:"rate"`     // Fraction of successful requests
+
+	// Link targets hinted, and how many the final response confirmed by
+	// repeating them in its own Link header
+	Links          int `json:"links"`
+	ConfirmedLinks int `json:"confirmed_links"`
+
+	// Time from sending the request to the first 103, and from the 103 to the
+	// final response headers: the head start a client gets for preloading
+	HintTime LatencyStats `json:"hint_time"`
+	LeadTime LatencyStats `json:"lead_time"`
+}
+
+// earlyHints collects the 103 responses of one request from httptrace's
+// Got1xxResponse, which runs on the transport's goroutine before Do returns
+type earlyHints struct {
+	received time.Time
+	links    []string
+}
+
+// got1xx records an informational response, keeping only 103 Early Hints
+func (h *earlyHints) got1xx(code int, header textproto.MIMEHeader) error {
+	if code != http.StatusEarlyHints {
+		return nil
+	}
+	if h.received.IsZero() {
+		h.received = time.Now()
+	}
+	h.links = append(h.links, linkTargets(header.Values("Link"))...)
+	return nil
+}
+
+// record fills metric's early hint fields once the final response headers
+// arrived at final
+func (h *earlyHints) record(metric *LatencyMetrics, start, final time.Time, resp *http.Response) {
+	if h.received.IsZero() {
+		return
+	}
+	metric.EarlyHint = h.received.Sub(start)
+	metric.EarlyHintLead = final.Sub(h.received)
+	metric.EarlyHintLinks = len(h.links)
+
+	confirmed := make(map[string]bool)
+	for _, target := range linkTargets(resp.Header.Values("Link")) {
+		confirmed[target] = true
+	}
+	for _, target := range h.links {
+		if confirmed[target] {
+			metric.EarlyHintConfirmed++
+		}
+	}
+}
+
+// linkTargets extracts the <URI> of every link in Link header values
+func linkTargets(values []string) []string {
+	var targets []string
+	for _, value := range values {
+		for {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			targets = append(targets, value[start+1:start+end])
+			value = value[start+end+1:]
+		}
+	}
+	return targets
+}
+
+// calculateEarlyHintsStats summarizes the early hints in metrics, or returns
+// nil if no successful request received one
+func calculateEarlyHintsStats(metrics []LatencyMetrics) *EarlyHintsStats {
+	var stats EarlyHintsStats
+	var successful int
+	var hintTimes, leadTimes []float64
+	for _, m := range metrics {
+		if m.Error != "" {
+			continue
+		}
+		successful++
+		if m.EarlyHint <= 0 {
+			continue
+		}
+		stats.Requests++
+		stats.Links += m.EarlyHintLinks
+		stats.ConfirmedLinks += m.EarlyHintConfirmed
+		hintTimes = append(hintTimes, float64(m.EarlyHint.Microseconds())/1000.0)
+		leadTimes = append(leadTimes, float64(m.EarlyHintLead.Microseconds())/1000.0)
+	}
+	if stats.Requests == 0 {
+		return nil
+	}
+
+	stats.Rate = float64(stats.Requests) / float64(successful)
+	stats.HintTime = CalculateStats(hintTimes)
+	stats.LeadTime = CalculateStats(leadTimes)
+	return &stats
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestLinkTargets tests extracting URIs from Link header values
+func TestLinkTargets(t *testing.T) {
+	targets := linkTargets([]string{
+		`</style.css>; rel=preload; as=style, </app.js>; rel=preload; as=script`,
+		`<https://cdn.example.com/font.woff2>; rel=preload; as=font; crossorigin`,
+		`malformed <unterminated`,
+	})
+	expected := []string{"/style.css", "/app.js", "https://cdn.example.com/font.woff2"}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Expected %v, got %v", expected, targets)
+	}
+}
+
+// TestBenchmarkerEarlyHints tests that 103 responses are counted, confirmed
+// links matched, and server time taken from the final response
+func TestBenchmarkerEarlyHints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hints") == "" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+		w.Header().Add("Link", "</app.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusEarlyHints)
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Del("Link")
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:         server.URL + "/?hints=1",
+		TotalRequests:     4,
+		Concurrency:       2,
+		KeepAlive:         true,
+		IncludeRawMetrics: true,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	hints := result.EarlyHints
+	if hints == nil || hints.Requests != 4 || hints.Rate != 1 {
+		t.Fatalf("Expected early hints on every request, got %+v", hints)
+	}
+	if hints.Links != 8 || hints.ConfirmedLinks != 4 {
+		t.Errorf("Expected 8 hinted and 4 confirmed links, got %d and %d", hints.Links, hints.ConfirmedLinks)
+	}
+	if hints.LeadTime.Min < 15 {
+		t.Errorf("Expected a lead time of about 20 ms, got %.2f ms", hints.LeadTime.Min)
+	}
+	for _, m := range result.RawMetrics {
+		if m.TimeToFirstByte < 15*time.Millisecond {
+			t.Errorf("TTFB %v should be taken from the final response, not the hint", m.TimeToFirstByte)
+		}
+	}
+
+	result, err = NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 2}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.EarlyHints != nil {
+		t.Errorf("Expected no early hint stats, got %+v", result.EarlyHints)
+	}
+}
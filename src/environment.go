@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvironmentFingerprint describes the machine a benchmark ran on. Results
+// measured under different fingerprints may differ for reasons unrelated to
+// the code, so comparisons flag them
+type EnvironmentFingerprint struct {
+	Hostname  string `json:"hostname,omitempty"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Kernel    string `json:"kernel,omitempty"`
+	CPUModel  string `json:"cpu_model,omitempty"`
+	CPUCount  int    `json:"cpu_count"`
+	GoVersion string `json:"go_version"`
+
+	// Container limits from the process's cgroup; zero when unlimited
+	CgroupCPULimit    float64 `json:"cgroup_cpu_limit,omitempty"` // Cores
+	CgroupMemoryLimit int64   `json:"cgroup_memory_limit_bytes,omitempty"`
+
+	// MTU of the interface the target is reached through
+	NetworkMTU int `json:"network_mtu,omitempty"`
+}
+
+// hostEnvironment is the part of the fingerprint that does not depend on the
+// target, collected once
+var hostEnvironment = sync.OnceValue(func() EnvironmentFingerprint {
+	env := EnvironmentFingerprint{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUCount:  runtime.NumCPU(),
+		GoVersion: runtime.Version(),
+		Kernel:    kernelRelease(),
+		CPUModel:  cpuModel(),
+	}
+	env.Hostname, _ = os.Hostname()
+	env.CgroupCPULimit, env.CgroupMemoryLimit = cgroupLimits()
+	return env
+})
+
+// targetMTUs caches routeMTU by target URL
+var targetMTUs sync.Map
+
+// CurrentEnvironment fingerprints this machine for a benchmark of targetURL;
+// an empty targetURL, e.g. for a Unix socket, leaves NetworkMTU unset
+func CurrentEnvironment(targetURL string) *EnvironmentFingerprint {
+	env := hostEnvironment()
+	if targetURL != "" {
+		mtu, ok := targetMTUs.Load(targetURL)
+		if !ok {
+			mtu, _ = targetMTUs.LoadOrStore(targetURL, routeMTU(targetURL))
+		}
+		env.NetworkMTU = mtu.(int)
+	}
+	return &env
+}
+
+// kernelRelease returns the running kernel's release
+func kernelRelease() string {
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	if out, err := exec.Command("uname", "-r").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+// cpuModel returns the CPU's marketing name
+func cpuModel() string {
+	if file, err := os.Open("/proc/cpuinfo"); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			// "Model" is the name on arm64 boards that have no "model name"
+			if ok && (strings.TrimSpace(key) == "model name" || strings.TrimSpace(key) == "Model") {
+				return strings.TrimSpace(value)
+			}
+		}
+		return ""
+	}
+	if out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+// cgroupLimits reads the CPU quota, in cores, and memory limit of the
+// process's cgroup, trying cgroup v2 before v1
+func cgroupLimits() (cpu float64, memory int64) {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		// "max 100000" when unlimited, else "QUOTA PERIOD"
+		if fields := strings.Fields(string(data)); len(fields) == 2 && fields[0] != "max" {
+			quota, _ := strconv.ParseFloat(fields[0], 64)
+			period, _ := strconv.ParseFloat(fields[1], 64)
+			if period > 0 {
+				cpu = quota / period
+			}
+		}
+		if data, err := os.ReadFile("/sys/fs/cgroup/memory.max"); err == nil {
+			memory, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		}
+		return cpu, memory
+	}
+
+	quota := readCgroupInt("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period := readCgroupInt("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if quota > 0 && period > 0 {
+		cpu = float64(quota) / float64(period)
+	}
+	// v1 reports no limit as a huge page-aligned number
+	if limit := readCgroupInt("/sys/fs/cgroup/memory/memory.limit_in_bytes"); limit > 0 && limit < 1<<62 {
+		memory = limit
+	}
+	return cpu, memory
+}
+
+// readCgroupInt reads an integer cgroup file, returning 0 on error
+func readCgroupInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+// routeMTU returns the MTU of the interface the kernel routes targetURL's
+// host through. Connecting a UDP socket picks the route without sending
+func routeMTU(targetURL string) int {
+	parsed, err := url.Parse(targetURL)
+	if err != nil || parsed.Hostname() == "" || parsed.Scheme == "unix" {
+		return 0
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "443"
+		if parsed.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(parsed.Hostname(), port), time.Second)
+	if err != nil {
+		return 0
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return 0
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return iface.MTU
+			}
+		}
+	}
+	return 0
+}
+
+// String summarizes the fingerprint for reports, e.g.
+// "bench-01: linux/amd64 6.8.0, 8 CPUs (AMD EPYC 7B13), go1.24.1"
+func (e *EnvironmentFingerprint) String() string {
+	if e == nil {
+		return "unknown"
+	}
+	s := fmt.Sprintf("%s: %s/%s", e.Hostname, e.OS, e.Arch)
+	if e.Kernel != "" {
+		s += " " + e.Kernel
+	}
+	s += fmt.Sprintf(", %d CPUs", e.CPUCount)
+	if e.CPUModel != "" {
+		s += fmt.Sprintf(" (%s)", e.CPUModel)
+	}
+	if e.CgroupCPULimit > 0 || e.CgroupMemoryLimit > 0 {
+		s += fmt.Sprintf(", limited to %s", e.limits())
+	}
+	return s + ", " + e.GoVersion
+}
+
+// limits describes the cgroup limits
+func (e *EnvironmentFingerprint) limits() string {
+	var parts []string
+	if e.CgroupCPULimit > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f CPUs", e.CgroupCPULimit))
+	}
+	if e.CgroupMemoryLimit > 0 {
+		parts = append(parts, formatBytes(e.CgroupMemoryLimit))
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Differences lists the fields in which other differs from e, e.g.
+// "CPU count: 8 -> 16". Either side being nil yields none
+func (e *EnvironmentFingerprint) Differences(other *EnvironmentFingerprint) []string {
+	if e == nil || other == nil {
+		return nil
+	}
+
+	var diffs []string
+	compare := func(field string, a, b any) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: %v -> %v", field, a, b))
+		}
+	}
+	compare("Host", e.Hostname, other.Hostname)
+	compare("OS", e.OS+"/"+e.Arch, other.OS+"/"+other.Arch)
+	compare("Kernel", e.Kernel, other.Kernel)
+	compare("CPU model", e.CPUModel, other.CPUModel)
+	compare("CPU count", e.CPUCount, other.CPUCount)
+	compare("Go version", e.GoVersion, other.GoVersion)
+	compare("Container limits", e.limits(), other.limits())
+	compare("Network MTU", e.NetworkMTU, other.NetworkMTU)
+	return diffs
+}
+
+// environmentDifferences compares the environments of the first results of
+// two runs; iterations of one run share a machine
+func environmentDifferences(baseline, current []*BenchmarkResult) []string {
+	if len(baseline) == 0 || len(current) == 0 {
+		return nil
+	}
+	return baseline[0].Environment.Differences(current[0].Environment)
+}
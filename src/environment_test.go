@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestCurrentEnvironment tests the fingerprint of the test machine
+func TestCurrentEnvironment(t *testing.T) {
+	env := CurrentEnvironment("http://127.0.0.1:8080/")
+	if env.OS != runtime.GOOS || env.CPUCount != runtime.NumCPU() || env.GoVersion != runtime.Version() {
+		t.Errorf("Expected the runtime's OS, CPU count and Go version, got %+v", env)
+	}
+	// Loopback always has an MTU
+	if env.NetworkMTU <= 0 {
+		t.Errorf("Expected the loopback MTU, got %d", env.NetworkMTU)
+	}
+	if unix := CurrentEnvironment(""); unix.NetworkMTU != 0 {
+		t.Errorf("Expected no MTU without a network target, got %d", unix.NetworkMTU)
+	}
+}
+
+// TestEnvironmentDifferences tests that comparisons flag other machines
+func TestEnvironmentDifferences(t *testing.T) {
+	base := &EnvironmentFingerprint{Hostname: "bench-01", OS: "linux", Arch: "amd64", CPUCount: 8, GoVersion: "go1.24.1", NetworkMTU: 1500}
+	same := *base
+	if diffs := base.Differences(&same); len(diffs) != 0 {
+		t.Errorf("Expected identical fingerprints to match, got %v", diffs)
+	}
+
+	other := *base
+	other.CPUCount = 4
+	other.CgroupCPULimit = 2
+	diffs := base.Differences(&other)
+	want := []string{"CPU count: 8 -> 4", "Container limits: unlimited -> 2.00 CPUs"}
+	if strings.Join(diffs, "; ") != strings.Join(want, "; ") {
+		t.Errorf("Expected %v, got %v", want, diffs)
+	}
+	if diffs := base.Differences(nil); diffs != nil {
+		t.Errorf("Expected no differences against a missing fingerprint, got %v", diffs)
+	}
+
+	// The comparison report warns about the change
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.json")
+	baseline := `{"name": "old", "runs": [{"name": "api", "results": [{"requests_per_second": 100,
+		"latency_stats": {"p95_ms": 50}, "environment": {"hostname": "bench-01", "os": "linux", "arch": "amd64",
+		"cpu_count": 8, "go_version": "go1.24.1", "network_mtu": 1500}}]}]}`
+	if err := os.WriteFile(baselinePath, []byte(baseline), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runner := &BenchmarkRunner{resultDir: dir, suite: &BenchmarkSuite{
+		Name: "new",
+		Runs: []BenchmarkRun{{
+			Name:    "api",
+			Results: []*BenchmarkResult{{RequestsPerSecond: 100, LatencyStats: LatencyStats{P95: 50}, Environment: &other}},
+		}},
+	}}
+	if err := runner.CompareWithBaseline(baselinePath); err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "COMPARISON.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "Environment differs") || !strings.Contains(string(report), "- CPU count: 8 -> 4") {
+		t.Errorf("Expected an environment warning in the report:\n%s", report)
+	}
+}
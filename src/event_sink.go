@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Types of streamed benchmark events
+const (
+	EventRequest   = "request"
+	EventIteration = "iteration"
+)
+
+const (
+	// DefaultEventBuffer is how many events may wait for the sink before new
+	// ones are dropped
+	DefaultEventBuffer = 10000
+
+	eventBatchSize      = 500
+	eventFlushInterval  = 250 * time.Millisecond
+	eventPublishTimeout = 10 * time.Second
+)
+
+// BenchmarkEvent is one live event of a suite: a completed request, or the
+// summary of a completed iteration
+type BenchmarkEvent struct {
+	Type      string    `json:"type"`
+	Suite     string    `json:"suite"`
+	Run       string    `json:"run"`
+	RunID     string    `json:"run_id"`
+	Target    string    `json:"target,omitempty"` // A/B target, connection variant or path
+	Iteration int       `json:"iteration"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Request *LatencyMetrics   `json:"request,omitempty"`
+	Result  *IterationSummary `json:"result,omitempty"`
+}
+
+// IterationSummary is the aggregate of an iteration carried by its event
+type IterationSummary struct {
+	TotalRequests     int          `json:"total_requests"`
+	SuccessfulReqs    int          `json:"successful_requests"`
+	FailedReqs        int          `json:"failed_requests"`
+	RequestsPerSecond float64      `json:"requests_per_second"`
+	DurationMs        float64      `json:"duration_ms"`
+	Latency           LatencyStats `json:"latency"`
+	Partial           bool         `json:"partial,omitempty"`
+}
+
+// summarizeIteration extracts the event summary of result
+func summarizeIteration(result *BenchmarkResult) *IterationSummary {
+	return &IterationSummary{
+		TotalRequests:     result.TotalRequests,
+		SuccessfulReqs:    result.SuccessfulReqs,
+		FailedReqs:        result.FailedReqs,
+		RequestsPerSecond: result.RequestsPerSecond,
+		DurationMs:        float64(result.Duration) / float64(time.Millisecond),
+		Latency:           result.LatencyStats,
+		Partial:           result.Partial,
+	}
+}
+
+// EventSink delivers benchmark events to an external pipeline
+type EventSink interface {
+	// Publish delivers a batch of events in order
+	Publish(ctx context.Context, events []BenchmarkEvent) error
+
+	// Close releases the sink's connection
+	Close() error
+}
+
+// NewEventSink opens the sink described by spec:
+//
+//	nats://[user:pass@]host[:4222]/subject     NATS core publish
+//	kafka+http[s]://[user:pass@]host/topic     Kafka via a Confluent REST Proxy
+func NewEventSink(ctx context.Context, spec string) (EventSink, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event sink %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "nats":
+		return NewNATSSink(ctx, u)
+	case "kafka+http", "kafka+https":
+		return NewKafkaRESTSink(u)
+	default:
+		return nil, fmt.Errorf("unsupported event sink %q: use nats://host:port/subject or kafka+http://rest-proxy/topic", spec)
+	}
+}
+
+// EventSinkStats counts what became of the events handed to a publisher
+type EventSinkStats struct {
+	Published int64  `json:"published"`
+	Dropped   int64  `json:"dropped"` // Buffer full
+	Failed    int64  `json:"failed"`  // Rejected by or lost on the way to the sink
+	LastError string `json:"last_error,omitempty"`
+}
+
+// EventPublisher batches events to a sink on its own goroutine, so a slow
+// sink never holds up the workers measuring requests. Events that arrive
+// while the buffer is full are dropped and counted
+type EventPublisher struct {
+	sink   EventSink
+	events chan BenchmarkEvent
+	done   chan struct{}
+
+	published atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+
+	errMu   sync.Mutex
+	lastErr error
+}
+
+// NewEventPublisher starts publishing to sink with room for buffer waiting events
+func NewEventPublisher(sink EventSink, buffer int) *EventPublisher {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	p := &EventPublisher{
+		sink:   sink,
+		events: make(chan BenchmarkEvent, buffer),
+		done:   make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// Publish queues event without blocking
+func (p *EventPublisher) Publish(event BenchmarkEvent) {
+	select {
+	case p.events <- event:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Close delivers the queued events, closes the sink and returns the counts.
+// Publish must not be called afterwards
+func (p *EventPublisher) Close() EventSinkStats {
+	close(p.events)
+	<-p.done
+	if err := p.sink.Close(); err != nil {
+		p.recordError(err)
+	}
+	return p.Stats()
+}
+
+// Stats returns the counts so far
+func (p *EventPublisher) Stats() EventSinkStats {
+	stats := EventSinkStats{
+		Published: p.published.Load(),
+		Dropped:   p.dropped.Load(),
+		Failed:    p.failed.Load(),
+	}
+	p.errMu.Lock()
+	if p.lastErr != nil {
+		stats.LastError = p.lastErr.Error()
+	}
+	p.errMu.Unlock()
+	return stats
+}
+
+// loop sends full batches as they fill and partial ones every flush interval
+func (p *EventPublisher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]BenchmarkEvent, 0, eventBatchSize)
+	for {
+		select {
+		case event, ok := <-p.events:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= eventBatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush publishes batch, counting it as failed if the sink returns an error
+func (p *EventPublisher) flush(batch []BenchmarkEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+	defer cancel()
+
+	if err := p.sink.Publish(ctx, batch); err != nil {
+		p.failed.Add(int64(len(batch)))
+		p.recordError(err)
+		return
+	}
+	p.published.Add(int64(len(batch)))
+}
+
+func (p *EventPublisher) recordError(err error) {
+	p.errMu.Lock()
+	p.lastErr = err
+	p.errMu.Unlock()
+}
+
+// NATSSink publishes each event as a message on one subject, speaking the
+// NATS client protocol directly. TLS is not supported
+type NATSSink struct {
+	conn       net.Conn
+	subject    string
+	maxPayload int
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	// The reader goroutine answers server PINGs and reports PONGs (nil) and
+	// -ERR messages here until the connection closes
+	replies chan error
+	closed  chan struct{}
+	readErr error
+}
+
+// natsInfo is the part of the server's INFO message the sink needs
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// NewNATSSink connects to the server in u and publishes to the subject in
+// its path. Credentials in u are sent as user and password, or as a token
+// when there is no password
+func NewNATSSink(ctx context.Context, u *url.URL) (*NATSSink, error) {
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("NATS event sink needs a subject without whitespace, e.g. nats://localhost:4222/apilo.events")
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	s := &NATSSink{
+		conn:    conn,
+		subject: subject,
+		writer:  bufio.NewWriter(conn),
+		replies: make(chan error, 16),
+		closed:  make(chan struct{}),
+	}
+	reader := bufio.NewReader(conn)
+	if err := s.handshake(reader, u.User); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go s.read(reader)
+	return s, nil
+}
+
+// handshake reads the server's INFO, sends CONNECT and waits for the PONG
+// answering a PING, so bad credentials fail here rather than on first publish
+func (s *NATSSink) handshake(reader *bufio.Reader, user *url.Userinfo) error {
+	s.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer s.conn.SetDeadline(time.Time{})
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read NATS server INFO: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected NATS greeting %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fmt.Errorf("invalid NATS server INFO: %w", err)
+	}
+	if info.TLSRequired {
+		return fmt.Errorf("NATS server requires TLS, which the event sink does not support")
+	}
+	s.maxPayload = info.MaxPayload
+
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "lang": "go", "name": "api-latency-optimizer"}
+	if user != nil {
+		if password, ok := user.Password(); ok {
+			connect["user"], connect["pass"] = user.Username(), password
+		} else {
+			connect["auth_token"] = user.Username()
+		}
+	}
+	options, _ := json.Marshal(connect)
+	fmt.Fprintf(s.writer, "CONNECT %s\r\nPING\r\n", options)
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to send NATS CONNECT: %w", err)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("NATS handshake failed: %w", err)
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server rejected the connection: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// read handles server messages until the connection closes
+func (s *NATSSink) read(reader *bufio.Reader) {
+	defer close(s.closed)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			s.readErr = err
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			s.writeMu.Lock()
+			s.writer.WriteString("PONG\r\n")
+			s.writer.Flush()
+			s.writeMu.Unlock()
+		case line == "PONG":
+			s.replies <- nil
+		case strings.HasPrefix(line, "-ERR"):
+			s.replies <- errors.New(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+// Publish sends every event followed by a PING, and waits for its PONG so
+// that errors the server reports for the batch are returned
+func (s *NATSSink) Publish(ctx context.Context, events []BenchmarkEvent) error {
+	s.writeMu.Lock()
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			s.writeMu.Unlock()
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		if s.maxPayload > 0 && len(payload) > s.maxPayload {
+			s.writeMu.Unlock()
+			return fmt.Errorf("event of %d bytes exceeds the NATS max_payload of %d", len(payload), s.maxPayload)
+		}
+		fmt.Fprintf(s.writer, "PUB %s %d\r\n", s.subject, len(payload))
+		s.writer.Write(payload)
+		s.writer.WriteString("\r\n")
+	}
+	s.writer.WriteString("PING\r\n")
+	err := s.writer.Flush()
+	s.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	var serverErr error
+	for {
+		select {
+		case reply := <-s.replies:
+			if reply == nil {
+				if serverErr != nil {
+					return fmt.Errorf("NATS server error: %w", serverErr)
+				}
+				return nil
+			}
+			if serverErr == nil {
+				serverErr = reply
+			}
+		case <-s.closed:
+			return fmt.Errorf("NATS connection closed: %v", s.readErr)
+		case <-ctx.Done():
+			return fmt.Errorf("no acknowledgement from NATS: %w", ctx.Err())
+		}
+	}
+}
+
+// Close closes the connection; published messages were flushed by Publish
+func (s *NATSSink) Close() error {
+	return s.conn.Close()
+}
+
+// KafkaRESTSink produces events to a Kafka topic through a Confluent REST
+// Proxy (v2 API), keyed by run ID so each run's events stay in order on one
+// partition
+type KafkaRESTSink struct {
+	client   *http.Client
+	endpoint string
+	user     *url.Userinfo
+}
+
+// kafkaRecord and kafkaProduceResponse follow the REST Proxy's v2 JSON format
+type kafkaRecord struct {
+	Key   string         `json:"key"`
+	Value BenchmarkEvent `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// NewKafkaRESTSink produces to the topic named by the last element of u's
+// path, through the REST Proxy at the rest of u
+func NewKafkaRESTSink(u *url.URL) (*KafkaRESTSink, error) {
+	path := strings.TrimRight(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	topic := path[slash+1:]
+	if topic == "" {
+		return nil, fmt.Errorf("Kafka event sink needs a topic, e.g. kafka+http://localhost:8082/apilo-events")
+	}
+
+	endpoint := *u
+	endpoint.Scheme = strings.TrimPrefix(u.Scheme, "kafka+")
+	endpoint.User = nil
+	endpoint.Path = path[:slash] + "/topics/" + url.PathEscape(topic)
+	return &KafkaRESTSink{
+		client:   &http.Client{Timeout: eventPublishTimeout},
+		endpoint: endpoint.String(),
+		user:     u.User,
+	}, nil
+}
+
+// Publish produces events as one request, failing if the proxy rejects any
+func (s *KafkaRESTSink) Publish(ctx context.Context, events []BenchmarkEvent) error {
+	records := make([]kafkaRecord, len(events))
+	for i, event := range events {
+		records[i] = kafkaRecord{Key: event.RunID, Value: event}
+	}
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if s.user != nil {
+		password, _ := s.user.Password()
+		req.SetBasicAuth(s.user.Username(), password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kafka REST Proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Kafka REST Proxy returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	var produced kafkaProduceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("invalid Kafka REST Proxy response: %w", err)
+	}
+	var rejected int
+	var lastErr string
+	for _, offset := range produced.Offsets {
+		if offset.ErrorCode != nil {
+			rejected++
+			lastErr = offset.Error
+		}
+	}
+	if rejected > 0 {
+		return fmt.Errorf("Kafka rejected %d of %d records: %s", rejected, len(events), lastErr)
+	}
+	return nil
+}
+
+// Close releases idle connections to the proxy
+func (s *KafkaRESTSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// openEvents starts streaming the suite's events to its EventSink, if any
+func (r *BenchmarkRunner) openEvents(ctx context.Context) error {
+	if r.suite.EventSink == "" {
+		return nil
+	}
+	sink, err := NewEventSink(ctx, r.suite.EventSink)
+	if err != nil {
+		return fmt.Errorf("failed to open event sink: %w", err)
+	}
+	r.events = NewEventPublisher(sink, DefaultEventBuffer)
+	return nil
+}
+
+// closeEvents delivers the remaining events and reports what was lost
+func (r *BenchmarkRunner) closeEvents() {
+	if r.events == nil {
+		return
+	}
+	stats := r.events.Close()
+	r.events = nil
+	fmt.Printf("Event sink: %d published, %d dropped, %d failed\n", stats.Published, stats.Dropped, stats.Failed)
+	if stats.LastError != "" {
+		fmt.Printf("WARNING: Event sink error: %s\n", stats.LastError)
+	}
+}
+
+// streamRequests has benchmarker publish an event per completed request of
+// the given iteration of run
+func (r *BenchmarkRunner) streamRequests(benchmarker *Benchmarker, run *BenchmarkRun, iteration int, target string) {
+	if r.events == nil {
+		return
+	}
+	events := r.events
+	benchmarker.SetRequestHandler(func(metric LatencyMetrics) {
+		events.Publish(BenchmarkEvent{
+			Type:      EventRequest,
+			Suite:     r.suite.ID,
+			Run:       run.Name,
+			RunID:     run.ID,
+			Target:    target,
+			Iteration: iteration,
+			Timestamp: metric.Timestamp,
+			Request:   &metric,
+		})
+	})
+}
+
+// publishIteration publishes the summary of a completed iteration
+func (r *BenchmarkRunner) publishIteration(run *BenchmarkRun, iteration int, target string, result *BenchmarkResult) {
+	if r.events == nil {
+		return
+	}
+	r.events.Publish(BenchmarkEvent{
+		Type:      EventIteration,
+		Suite:     r.suite.ID,
+		Run:       run.Name,
+		RunID:     run.ID,
+		Target:    target,
+		Iteration: iteration,
+		Timestamp: time.Now(),
+		Result:    summarizeIteration(result),
+	})
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeNATSServer accepts one client, answers PINGs and records what it publishes
+type fakeNATSServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	connect  string
+	subjects []string
+	payloads [][]byte
+}
+
+func newFakeNATSServer(t *testing.T) *fakeNATSServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fakeNATSServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeNATSServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "CONNECT":
+			s.mu.Lock()
+			s.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			s.mu.Unlock()
+		case fields[0] == "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case fields[0] == "PUB" && len(fields) == 3:
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.subjects = append(s.subjects, fields[1])
+			s.payloads = append(s.payloads, payload[:size])
+			s.mu.Unlock()
+		}
+	}
+}
+
+// TestEventSinkNATS tests that a suite streams its measured requests and
+// iterations, but not warmup, over NATS
+func TestEventSinkNATS(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	nats := newFakeNATSServer(t)
+
+	suite := &BenchmarkSuite{
+		Name:      "event_sink_test",
+		OutputDir: t.TempDir(),
+		EventSink: fmt.Sprintf("nats://alice:secret@%s/apilo.events", nats.listener.Addr()),
+		Runs: []BenchmarkRun{{
+			Name:             "streamed",
+			Config:           BenchmarkConfig{TargetURL: target.URL, TotalRequests: 5, Concurrency: 2},
+			Iterations:       2,
+			WarmupIterations: 1,
+		}},
+	}
+	if err := NewBenchmarkRunner(suite).Run(context.Background()); err != nil {
+		t.Fatalf("Suite failed: %v", err)
+	}
+
+	nats.mu.Lock()
+	defer nats.mu.Unlock()
+	if !strings.Contains(nats.connect, `"user":"alice"`) || !strings.Contains(nats.connect, `"pass":"secret"`) {
+		t.Errorf("Expected credentials in CONNECT, got %s", nats.connect)
+	}
+
+	counts := map[string]int{}
+	for i, payload := range nats.payloads {
+		var event BenchmarkEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatalf("Invalid event %q: %v", payload, err)
+		}
+		if nats.subjects[i] != "apilo.events" || event.RunID != suite.Runs[0].ID || event.Suite != suite.ID {
+			t.Errorf("Unexpected event on %s: %+v", nats.subjects[i], event)
+		}
+		if event.Iteration < 1 || event.Iteration > 2 {
+			t.Errorf("Expected iteration 1 or 2, got %d", event.Iteration)
+		}
+		switch event.Type {
+		case EventRequest:
+			if event.Request == nil || event.Request.StatusCode != http.StatusOK {
+				t.Errorf("Expected a successful request, got %+v", event.Request)
+			}
+		case EventIteration:
+			if event.Result == nil || event.Result.SuccessfulReqs != 5 {
+				t.Errorf("Expected 5 successful requests in the summary, got %+v", event.Result)
+			}
+		}
+		counts[event.Type]++
+	}
+	if counts[EventRequest] != 10 || counts[EventIteration] != 2 {
+		t.Errorf("Expected 10 request and 2 iteration events, got %v", counts)
+	}
+}
+
+// TestKafkaRESTSink tests records sent to the REST Proxy and rejected records
+func TestKafkaRESTSink(t *testing.T) {
+	var rejected bool
+	var received []kafkaRecord
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if r.URL.Path != "/kafka/topics/apilo-events" || user != "bob" || password != "pw" ||
+			r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body struct{ Records []kafkaRecord }
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body.Records...)
+		if rejected {
+			fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":1},{"error_code":40403,"error":"record too large"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":1},{"partition":0,"offset":2}]}`)
+	}))
+	defer proxy.Close()
+
+	spec := strings.Replace(proxy.URL, "http://", "kafka+http://bob:pw@", 1) + "/kafka/apilo-events"
+	sink, err := NewEventSink(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	defer sink.Close()
+
+	events := []BenchmarkEvent{{Type: EventRequest, RunID: "run-1"}, {Type: EventIteration, RunID: "run-1"}}
+	if err := sink.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(received) != 2 || received[0].Key != "run-1" || received[1].Value.Type != EventIteration {
+		t.Errorf("Expected both events keyed by run, got %+v", received)
+	}
+
+	rejected = true
+	if err := sink.Publish(context.Background(), events); err == nil || !strings.Contains(err.Error(), "rejected 1 of 2") {
+		t.Errorf("Expected the rejected record reported, got %v", err)
+	}
+}
+
+// blockingSink holds every Publish until released
+type blockingSink struct {
+	entered  chan struct{}
+	release  chan struct{}
+	received int
+}
+
+func (s *blockingSink) Publish(ctx context.Context, events []BenchmarkEvent) error {
+	s.entered <- struct{}{}
+	<-s.release
+	s.received += len(events)
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+// TestEventPublisherDrops tests that a stalled sink drops events instead of
+// blocking the publisher
+func TestEventPublisherDrops(t *testing.T) {
+	sink := &blockingSink{entered: make(chan struct{}, 4), release: make(chan struct{})}
+	publisher := NewEventPublisher(sink, 1)
+
+	publisher.Publish(BenchmarkEvent{Type: EventRequest})
+	<-sink.entered // The first batch is now stuck in the sink
+	for i := 0; i < 3; i++ {
+		publisher.Publish(BenchmarkEvent{Type: EventRequest})
+	}
+	close(sink.release)
+
+	stats := publisher.Close()
+	if stats.Published != 2 || stats.Dropped != 2 || sink.received != 2 {
+		t.Errorf("Expected 2 published and 2 dropped, got %+v", stats)
+	}
+}
+
+// TestNewEventSinkInvalid tests the rejected sink specs
+func TestNewEventSinkInvalid(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"mqtt://broker/topic", "unsupported event sink"},
+		{"nats://localhost:4222", "needs a subject"},
+		{"kafka+http://proxy:8082", "needs a topic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := NewEventSink(context.Background(), tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
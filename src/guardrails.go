@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+// ErrClientSaturated is returned when a guardrail stopped an iteration
+// because the load generator itself ran out of CPU or memory
+var ErrClientSaturated = errors.New("load generator saturated")
+
+// Guardrail actions for GuardrailConfig.Action
+const (
+	GuardrailThrottle = "throttle" // Halve the active workers, aborting once one remains
+	GuardrailAbort    = "abort"    // Stop the iteration
+)
+
+// GuardrailConfig protects results from a saturated load generator: once
+// this process's CPU use stays above MaxCPU, latencies include time spent
+// waiting to be scheduled. Exceeding MaxMemory always aborts
+type GuardrailConfig struct {
+	Action    string        `yaml:"action"`
+	MaxCPU    float64       `yaml:"max_cpu"`    // Fraction of the cores available to the process
+	MaxMemory int64         `yaml:"max_memory"` // Bytes; zero means 90% of the cgroup limit, if any
+	Interval  time.Duration `yaml:"interval"`   // Between samples
+	Sustain   int           `yaml:"sustain"`    // Consecutive saturated samples before acting
+}
+
+// DefaultGuardrailConfig throttles after 1.5 seconds above 90% CPU
+func DefaultGuardrailConfig() *GuardrailConfig {
+	return &GuardrailConfig{
+		Action:   GuardrailThrottle,
+		MaxCPU:   0.9,
+		Interval: 500 * time.Millisecond,
+		Sustain:  3,
+	}
+}
+
+// ParseGuardrails parses the -guardrail flag: throttle, abort, or off for no
+// guardrails
+func ParseGuardrails(action string) (*GuardrailConfig, error) {
+	switch action {
+	case "off":
+		return nil, nil
+	case GuardrailThrottle, GuardrailAbort:
+		config := DefaultGuardrailConfig()
+		config.Action = action
+		return config, nil
+	}
+	return nil, fmt.Errorf("unknown action %q, want throttle, abort or off", action)
+}
+
+// GuardrailStats records the load generator's own resource use during an
+// iteration and what the guardrail did about it
+type GuardrailStats struct {
+	PeakCPU    float64 `json:"peak_cpu"` // Fraction of the available cores
+	PeakMemory int64   `json:"peak_memory_bytes"`
+	Cores      float64 `json:"cores"`
+
+	// Saturated is set once CPU stayed above MaxCPU for Sustain samples;
+	// latencies measured while saturated are inflated
+	Saturated   bool                   `json:"saturated"`
+	Reductions  []ConcurrencyReduction `json:"reductions,omitempty"`
+	Concurrency int                    `json:"final_concurrency"`
+
+	Aborted bool   `json:"aborted,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ConcurrencyReduction is one throttling step
+type ConcurrencyReduction struct {
+	At   time.Duration `json:"at"` // Since measurement started
+	From int           `json:"from"`
+	To   int           `json:"to"`
+	CPU  float64       `json:"cpu"`
+}
+
+// startGuardrails samples this process's CPU and memory until the returned
+// func is called, which returns what was observed. Throttling lowers
+// b.activeWorkers; aborting calls cancel
+func (b *Benchmarker) startGuardrails(startTime time.Time, cancel context.CancelFunc) func() *GuardrailStats {
+	if b.config.Guardrails == nil {
+		return func() *GuardrailStats { return nil }
+	}
+	config := *b.config.Guardrails
+	defaults := DefaultGuardrailConfig()
+	if config.Action == "" {
+		config.Action = defaults.Action
+	}
+	if config.MaxCPU <= 0 {
+		config.MaxCPU = defaults.MaxCPU
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Sustain <= 0 {
+		config.Sustain = defaults.Sustain
+	}
+	env := hostEnvironment()
+	if config.MaxMemory <= 0 && env.CgroupMemoryLimit > 0 {
+		config.MaxMemory = env.CgroupMemoryLimit / 10 * 9
+	}
+
+	stats := &GuardrailStats{Cores: float64(runtime.GOMAXPROCS(0))}
+	if env.CgroupCPULimit > 0 && env.CgroupCPULimit < stats.Cores {
+		stats.Cores = env.CgroupCPULimit
+	}
+
+	abort := func(reason string) {
+		stats.Aborted = true
+		stats.Reason = reason
+		cancel()
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		lastCPU, cpuOK := processCPUTime()
+		lastSample := time.Now()
+		saturated := 0
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			memory := processMemory()
+			stats.PeakMemory = max(stats.PeakMemory, memory)
+			if config.MaxMemory > 0 && memory > config.MaxMemory {
+				abort(fmt.Sprintf("memory use %s exceeded the %s limit", formatBytes(memory), formatBytes(config.MaxMemory)))
+				return
+			}
+
+			now := time.Now()
+			cpuTime, ok := processCPUTime()
+			if ok && cpuOK {
+				cpu := (cpuTime - lastCPU).Seconds() / now.Sub(lastSample).Seconds() / stats.Cores
+				stats.PeakCPU = max(stats.PeakCPU, cpu)
+				if cpu > config.MaxCPU {
+					saturated++
+				} else {
+					saturated = 0
+				}
+
+				if saturated >= config.Sustain {
+					stats.Saturated = true
+					saturated = 0
+					active := int(b.activeWorkers.Load())
+					if config.Action == GuardrailThrottle && active > 1 {
+						reduced := active / 2
+						b.activeWorkers.Store(int64(reduced))
+						stats.Reductions = append(stats.Reductions, ConcurrencyReduction{At: now.Sub(startTime), From: active, To: reduced, CPU: cpu})
+					} else {
+						abort(fmt.Sprintf("CPU use stayed at %.0f%% of %.1f cores with %d worker(s)", cpu*100, stats.Cores, active))
+						return
+					}
+				}
+			}
+			lastCPU, cpuOK, lastSample = cpuTime, ok, now
+		}
+	}()
+
+	return func() *GuardrailStats {
+		close(done)
+		<-finished
+		stats.Concurrency = int(b.activeWorkers.Load())
+		return stats
+	}
+}
+
+// processMemory returns the memory the Go runtime holds from the OS, less
+// what it has released back
+func processMemory() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// interruptedBy explains why result is partial: a guardrail or spend guard
+// abort, or the cancellation of ctx
+func interruptedBy(ctx context.Context, result *BenchmarkResult) error {
+	if guard := result.Guardrails; guard != nil && guard.Aborted {
+		return fmt.Errorf("%w: %s", ErrClientSaturated, guard.Reason)
+	}
+	if spend := result.Spend; spend != nil && spend.Exceeded && spend.Action == SpendAbort {
+		return fmt.Errorf("%w: $%.2f budget", ErrSpendLimit, spend.Budget)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// processCPUTime is unavailable here, so only memory is guarded
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// guardedBenchmark runs 200 requests at concurrency 8 under guardrails
+func guardedBenchmark(t *testing.T, guardrails *GuardrailConfig) *BenchmarkResult {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 200,
+		Concurrency:   8,
+		KeepAlive:     true,
+		Guardrails:    guardrails,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	return result
+}
+
+// TestGuardrailThrottle tests that a saturated CPU halves concurrency down to
+// one worker before aborting, without losing requests
+func TestGuardrailThrottle(t *testing.T) {
+	// Any CPU use counts as saturated
+	result := guardedBenchmark(t, &GuardrailConfig{Action: GuardrailThrottle, MaxCPU: 1e-9, Interval: 10 * time.Millisecond, Sustain: 1})
+
+	guard := result.Guardrails
+	if guard == nil || !guard.Saturated || len(guard.Reductions) != 3 {
+		t.Fatalf("Expected three concurrency reductions, got %+v", guard)
+	}
+	for i, want := range []int{4, 2, 1} {
+		if guard.Reductions[i].To != want {
+			t.Errorf("Expected reduction %d to %d workers, got %+v", i+1, want, guard.Reductions[i])
+		}
+	}
+	if !guard.Aborted || guard.Concurrency != 1 || !strings.Contains(guard.Reason, "with 1 worker(s)") {
+		t.Errorf("Expected an abort at one worker, got %+v", guard)
+	}
+	if !result.Partial || result.SuccessfulReqs == 0 {
+		t.Errorf("Expected a partial result, got %+v", result)
+	}
+}
+
+// TestGuardrailAbort tests that exceeding the memory limit stops the
+// iteration with a clear reason
+func TestGuardrailAbort(t *testing.T) {
+	result := guardedBenchmark(t, &GuardrailConfig{Action: GuardrailThrottle, MaxMemory: 1, Interval: 10 * time.Millisecond})
+
+	guard := result.Guardrails
+	if guard == nil || !guard.Aborted || !strings.Contains(guard.Reason, "memory use") {
+		t.Fatalf("Expected a memory abort, got %+v", guard)
+	}
+	if !result.Partial || result.CanceledReqs == 0 {
+		t.Errorf("Expected a partial result with canceled requests, got %+v", result)
+	}
+	if err := interruptedBy(context.Background(), result); !errors.Is(err, ErrClientSaturated) {
+		t.Errorf("Expected ErrClientSaturated, got %v", err)
+	}
+}
+
+// TestParseGuardrails tests the -guardrail flag
+func TestParseGuardrails(t *testing.T) {
+	if config, err := ParseGuardrails("abort"); err != nil || config.Action != GuardrailAbort || config.MaxCPU != 0.9 {
+		t.Errorf("Expected default abort guardrails, got %+v, %v", config, err)
+	}
+	if config, err := ParseGuardrails("off"); err != nil || config != nil {
+		t.Errorf("Expected no guardrails, got %+v, %v", config, err)
+	}
+	if _, err := ParseGuardrails("pause"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
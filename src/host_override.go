@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HostOverride sends requests with a Host header and TLS server name other
+// than the target URL's, to benchmark an origin behind a CDN or load balancer
+// directly or to exercise host-based routing rules
+type HostOverride struct {
+	Host string `yaml:"host"` // Host header, as HOST[:PORT]; also the SNI unless SNI is set
+	SNI  string `yaml:"sni"`  // TLS server name, which certificates are verified against
+
+	// Send a Host header and SNI that name different hosts; most CDNs answer
+	// such requests with 421 Misdirected Request
+	AllowMismatch bool `yaml:"allow_mismatch"`
+}
+
+// Validate checks o against the target URL and any server name already set
+// in the TLS config
+func (o *HostOverride) Validate(targetURL string, tlsConfig *ClientTLSConfig) error {
+	if o.Host == "" && o.SNI == "" {
+		return fmt.Errorf("host override sets neither a Host header nor an SNI")
+	}
+	if o.Host != "" {
+		if u, err := url.Parse("//" + o.Host); err != nil || u.Host != o.Host || u.Path != "" || u.User != nil {
+			return fmt.Errorf("invalid Host header %q: use HOST or HOST:PORT", o.Host)
+		}
+	}
+	if o.SNI != "" {
+		if strings.ContainsAny(o.SNI, ":/ ") {
+			return fmt.Errorf("invalid SNI %q: use a host name without port", o.SNI)
+		}
+		if net.ParseIP(o.SNI) != nil {
+			return fmt.Errorf("invalid SNI %q: TLS server names cannot be IP addresses", o.SNI)
+		}
+		if strings.HasPrefix(targetURL, "http://") {
+			return fmt.Errorf("SNI %q needs an https:// target", o.SNI)
+		}
+	}
+
+	if o.Host != "" && o.SNI != "" && !o.AllowMismatch && !strings.EqualFold(hostName(o.Host), o.SNI) {
+		return fmt.Errorf("the Host header %q and SNI %q name different hosts; allow the mismatch to send them anyway", o.Host, o.SNI)
+	}
+	if tlsConfig != nil && tlsConfig.ServerName != "" && o.ServerName() != "" && !strings.EqualFold(tlsConfig.ServerName, o.ServerName()) {
+		return fmt.Errorf("TLS server name %q conflicts with the host override's %q", tlsConfig.ServerName, o.ServerName())
+	}
+	return nil
+}
+
+// ServerName returns the TLS server name to send: the SNI, or the Host
+// header's host when only that is set
+func (o *HostOverride) ServerName() string {
+	if o.SNI != "" {
+		return o.SNI
+	}
+	if net.ParseIP(hostName(o.Host)) != nil {
+		return ""
+	}
+	return hostName(o.Host)
+}
+
+// apply sets req's Host header from o, if any
+func (o *HostOverride) apply(req *http.Request) {
+	if o != nil && o.Host != "" {
+		req.Host = o.Host
+	}
+}
+
+// hostName strips any port from a HOST[:PORT] Host header
+func hostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return strings.Trim(host, "[]")
+}
+
+// checkHostHeader rejects a Host entry in custom headers, which Go's client
+// ignores in favor of the URL's host
+func checkHostHeader(headers map[string]string) error {
+	for key := range headers {
+		if strings.EqualFold(key, "Host") {
+			return fmt.Errorf("a Host custom header is not sent; use a host override instead")
+		}
+	}
+	return nil
+}
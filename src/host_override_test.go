@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestHostOverrideValidate tests that malformed and mismatched overrides are rejected
+func TestHostOverrideValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		override HostOverride
+		target   string
+		tls      *ClientTLSConfig
+		errText  string
+	}{
+		{"host only", HostOverride{Host: "www.example.com"}, "https://203.0.113.10", nil, ""},
+		{"host with port", HostOverride{Host: "www.example.com:8443"}, "https://203.0.113.10", nil, ""},
+		{"host and matching sni", HostOverride{Host: "www.example.com:8443", SNI: "WWW.example.com"}, "https://203.0.113.10", nil, ""},
+		{"mismatch allowed", HostOverride{Host: "a.example.com", SNI: "b.example.com", AllowMismatch: true}, "https://203.0.113.10", nil, ""},
+		{"empty", HostOverride{}, "https://203.0.113.10", nil, "neither"},
+		{"host with path", HostOverride{Host: "www.example.com/api"}, "https://203.0.113.10", nil, "invalid Host header"},
+		{"host with scheme", HostOverride{Host: "https://www.example.com"}, "https://203.0.113.10", nil, "invalid Host header"},
+		{"sni with port", HostOverride{SNI: "www.example.com:443"}, "https://203.0.113.10", nil, "invalid SNI"},
+		{"sni ip", HostOverride{SNI: "203.0.113.10"}, "https://203.0.113.10", nil, "IP addresses"},
+		{"sni over http", HostOverride{SNI: "www.example.com"}, "http://203.0.113.10", nil, "https://"},
+		{"mismatch", HostOverride{Host: "a.example.com", SNI: "b.example.com"}, "https://203.0.113.10", nil, "different hosts"},
+		{"tls server name conflict", HostOverride{Host: "a.example.com"}, "https://203.0.113.10", &ClientTLSConfig{ServerName: "b.example.com"}, "conflicts"},
+	}
+
+	for _, tt := range tests {
+		err := tt.override.Validate(tt.target, tt.tls)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%s: Expected no error, got %v", tt.name, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.name, tt.errText, err)
+		}
+	}
+}
+
+// TestHostOverrideServerName tests that the SNI follows the Host header unless set
+func TestHostOverrideServerName(t *testing.T) {
+	tests := []struct {
+		override HostOverride
+		expected string
+	}{
+		{HostOverride{Host: "www.example.com:8443"}, "www.example.com"},
+		{HostOverride{Host: "www.example.com", SNI: "origin.example.com"}, "origin.example.com"},
+		{HostOverride{Host: "203.0.113.10"}, ""},
+		{HostOverride{Host: "[2001:db8::1]:443"}, ""},
+	}
+
+	for _, tt := range tests {
+		if name := tt.override.ServerName(); name != tt.expected {
+			t.Errorf("Expected server name %q for %+v, got %q", tt.expected, tt.override, name)
+		}
+	}
+}
+
+// TestBenchmarkerHostOverride tests that the Host header and SNI reach the server
+func TestBenchmarkerHostOverride(t *testing.T) {
+	var mu sync.Mutex
+	var hosts, serverNames []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			serverNames = append(serverNames, hello.ServerName)
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 3,
+		Concurrency:   1,
+		KeepAlive:     true,
+		TLS:           &ClientTLSConfig{InsecureSkipVerify: true},
+		HostOverride:  &HostOverride{Host: "www.example.com"},
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.SuccessfulReqs != 3 {
+		t.Fatalf("Expected 3 successful requests, got %d", result.SuccessfulReqs)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, host := range hosts {
+		if host != "www.example.com" {
+			t.Errorf("Expected Host www.example.com, got %q", host)
+		}
+	}
+	if len(serverNames) == 0 || serverNames[0] != "www.example.com" {
+		t.Errorf("Expected SNI www.example.com, got %v", serverNames)
+	}
+}
+
+// TestBenchmarkerCustomHostHeader tests that a Host custom header is rejected
+// rather than silently dropped
+func TestBenchmarkerCustomHostHeader(t *testing.T) {
+	_, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     "http://127.0.0.1:1",
+		TotalRequests: 1,
+		Concurrency:   1,
+		CustomHeaders: map[string]string{"host": "www.example.com"},
+	}).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "host override") {
+		t.Errorf("Expected a host override error, got %v", err)
+	}
+}
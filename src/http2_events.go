@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP/2 connection and stream events seen as request errors
+const (
+	HTTP2EventGoAway         = "goaway"          // The server sent GOAWAY and closed the connection
+	HTTP2EventStreamReset    = "stream_reset"    // The server reset the request's stream with RST_STREAM
+	HTTP2EventConnectionLost = "connection_lost" // The connection died, e.g. an unanswered health check ping
+)
+
+// maxRecentHTTP2Events bounds the events kept for correlating latency spikes
+const maxRecentHTTP2Events = 100
+
+// HTTP2Event is one GOAWAY, stream reset or lost connection
+type HTTP2Event struct {
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host"`
+	Type    string    `json:"type"`
+	Code    string    `json:"code,omitempty"` // HTTP/2 error code, e.g. REFUSED_STREAM
+	Retried bool      `json:"retried"`
+}
+
+// HTTP2EventStats counts the HTTP/2 events an HTTP2Client has seen
+type HTTP2EventStats struct {
+	GoAways         int64            `json:"goaways"`
+	StreamResets    int64            `json:"stream_resets"`
+	ConnectionsLost int64            `json:"connections_lost"`
+	Codes           map[string]int64 `json:"codes,omitempty"` // Events by error code
+
+	// Requests sent again after an event, and how many of those succeeded
+	Retries          int64 `json:"retries"`
+	RetriesSucceeded int64 `json:"retries_succeeded"`
+
+	Recent []HTTP2Event `json:"recent,omitempty"` // Oldest first
+}
+
+// HTTP2EventTracker records the HTTP/2 events behind request errors
+type HTTP2EventTracker struct {
+	mu     sync.Mutex
+	stats  HTTP2EventStats
+	recent []HTTP2Event
+}
+
+// NewHTTP2EventTracker creates an empty tracker
+func NewHTTP2EventTracker() *HTTP2EventTracker {
+	return &HTTP2EventTracker{stats: HTTP2EventStats{Codes: make(map[string]int64)}}
+}
+
+// parseHTTP2Event returns the event behind err, if any. Go's HTTP/2 error
+// types are unexported, so events are recognized by their messages
+func parseHTTP2Event(err error) (event HTTP2Event, ok bool) {
+	if err == nil {
+		return event, false
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "server sent GOAWAY"), strings.Contains(message, "graceful shutdown GOAWAY"):
+		event.Type = HTTP2EventGoAway
+		// http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=""
+		if _, code, found := strings.Cut(message, "ErrCode="); found {
+			event.Code, _, _ = strings.Cut(code, ",")
+		}
+	case strings.Contains(message, "stream error:") && strings.Contains(message, "received from peer"):
+		event.Type = HTTP2EventStreamReset
+		// stream error: stream ID 3; REFUSED_STREAM; received from peer
+		if parts := strings.Split(message, "; "); len(parts) >= 3 {
+			event.Code = parts[1]
+		}
+	case strings.Contains(message, "http2: client connection lost"):
+		event.Type = HTTP2EventConnectionLost
+	default:
+		return event, false
+	}
+	return event, true
+}
+
+// Record counts the HTTP/2 event behind err, if any, for a request to host
+func (t *HTTP2EventTracker) Record(host string, err error) (HTTP2Event, bool) {
+	event, ok := parseHTTP2Event(err)
+	if !ok {
+		return event, false
+	}
+	event.Time = time.Now()
+	event.Host = host
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch event.Type {
+	case HTTP2EventGoAway:
+		t.stats.GoAways++
+	case HTTP2EventStreamReset:
+		t.stats.StreamResets++
+	case HTTP2EventConnectionLost:
+		t.stats.ConnectionsLost++
+	}
+	if event.Code != "" {
+		t.stats.Codes[event.Code]++
+	}
+	if len(t.recent) == maxRecentHTTP2Events {
+		t.recent = t.recent[1:]
+	}
+	t.recent = append(t.recent, event)
+	return event, true
+}
+
+// RecordRetry counts a request sent again after an event
+func (t *HTTP2EventTracker) RecordRetry(succeeded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Retries++
+	if succeeded {
+		t.stats.RetriesSucceeded++
+	}
+	if len(t.recent) > 0 {
+		t.recent[len(t.recent)-1].Retried = true
+	}
+}
+
+// Stats returns a snapshot of the counters, or nil if no event occurred
+func (t *HTTP2EventTracker) Stats() *HTTP2EventStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.recent) == 0 {
+		return nil
+	}
+	stats := t.stats
+	stats.Codes = make(map[string]int64, len(t.stats.Codes))
+	for code, count := range t.stats.Codes {
+		stats.Codes[code] = count
+	}
+	stats.Recent = append([]HTTP2Event(nil), t.recent...)
+	return &stats
+}
+
+// canRetryHTTP2 reports whether req can be sent again after event. A stream
+// the server refused was never processed, so any request may be retried;
+// otherwise the server may have acted on it, so only idempotent methods are.
+// Bodies must be replayable through GetBody
+func canRetryHTTP2(req *http.Request, event HTTP2Event) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if event.Code == "REFUSED_STREAM" {
+		return true
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// rewindRequest returns a copy of req with a fresh body to send again
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
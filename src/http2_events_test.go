@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// TestParseHTTP2Event tests recognizing Go's HTTP/2 error messages
+func TestParseHTTP2Event(t *testing.T) {
+	tests := []struct {
+		message   string
+		eventType string
+		code      string
+		errorType string
+	}{
+		{`http2: server sent GOAWAY and closed the connection; LastStreamID=5, ErrCode=ENHANCE_YOUR_CALM, debug=""`, HTTP2EventGoAway, "ENHANCE_YOUR_CALM", ErrorTypeHTTP2GoAway},
+		{"http2: Transport received Server's graceful shutdown GOAWAY", HTTP2EventGoAway, "", ErrorTypeHTTP2GoAway},
+		{"stream error: stream ID 3; REFUSED_STREAM; received from peer", HTTP2EventStreamReset, "REFUSED_STREAM", ErrorTypeHTTP2Reset},
+		{"http2: client connection lost", HTTP2EventConnectionLost, "", ErrorTypeConnection},
+		{"stream error: stream ID 3; PROTOCOL_ERROR", "", "", ErrorTypeOther},
+		{"unexpected EOF", "", "", ErrorTypeOther},
+	}
+
+	for _, tt := range tests {
+		err := errors.New(tt.message)
+		event, ok := parseHTTP2Event(err)
+		if ok != (tt.eventType != "") || event.Type != tt.eventType || event.Code != tt.code {
+			t.Errorf("%q: expected event %q code %q, got %q code %q (%v)", tt.message, tt.eventType, tt.code, event.Type, event.Code, ok)
+		}
+		if errorType := ClassifyRequestError(err); errorType != tt.errorType {
+			t.Errorf("%q: expected error type %q, got %q", tt.message, tt.errorType, errorType)
+		}
+	}
+}
+
+// TestCanRetryHTTP2 tests which requests are retried after an event
+func TestCanRetryHTTP2(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	post, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("body"))
+	stream, _ := http.NewRequest(http.MethodPut, "http://example.com", io.NopCloser(strings.NewReader("body")))
+
+	reset := HTTP2Event{Type: HTTP2EventStreamReset, Code: "INTERNAL_ERROR"}
+	refused := HTTP2Event{Type: HTTP2EventStreamReset, Code: "REFUSED_STREAM"}
+	tests := []struct {
+		name  string
+		req   *http.Request
+		event HTTP2Event
+		retry bool
+	}{
+		{"idempotent", get, reset, true},
+		{"non-idempotent", post, reset, false},
+		{"refused non-idempotent", post, refused, true},
+		{"unreplayable body", stream, refused, false},
+	}
+
+	for _, tt := range tests {
+		if retry := canRetryHTTP2(tt.req, tt.event); retry != tt.retry {
+			t.Errorf("%s: expected retry %v, got %v", tt.name, tt.retry, retry)
+		}
+	}
+}
+
+// resettingServer is an h2c server that resets the first stream with
+// INTERNAL_ERROR and answers later ones with 200
+type resettingServer struct {
+	listener net.Listener
+	streams  int
+}
+
+func (s *resettingServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return
+	}
+	framer := http2.NewFramer(conn, conn)
+	framer.WriteSettings()
+
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch frame := frame.(type) {
+		case *http2.SettingsFrame:
+			if !frame.IsAck() {
+				framer.WriteSettingsAck()
+			}
+		case *http2.HeadersFrame:
+			s.streams++
+			if s.streams == 1 {
+				framer.WriteRSTStream(frame.StreamID, http2.ErrCodeInternal)
+				continue
+			}
+			var block bytes.Buffer
+			hpack.NewEncoder(&block).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+			framer.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      frame.StreamID,
+				BlockFragment: block.Bytes(),
+				EndHeaders:    true,
+				EndStream:     true,
+			})
+		}
+	}
+}
+
+// TestHTTP2ClientStreamResetRetry tests that a reset GET is retried and the
+// reset counted
+func TestHTTP2ClientStreamResetRetry(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	server := &resettingServer{listener: listener}
+	go server.serve()
+
+	client, err := NewHTTP2Client(&HTTP2ClientConfig{H2C: H2CPriorKnowledge})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+"/", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	stats := client.HTTP2Events()
+	if stats == nil {
+		t.Fatal("Expected HTTP/2 events to be recorded")
+	}
+	if stats.StreamResets != 1 || stats.Codes["INTERNAL_ERROR"] != 1 {
+		t.Errorf("Expected 1 INTERNAL_ERROR reset, got %d (%v)", stats.StreamResets, stats.Codes)
+	}
+	if stats.Retries != 1 || stats.RetriesSucceeded != 1 {
+		t.Errorf("Expected 1 successful retry, got %d of %d", stats.RetriesSucceeded, stats.Retries)
+	}
+	if len(stats.Recent) != 1 || !stats.Recent[0].Retried || stats.Recent[0].Host != listener.Addr().String() {
+		t.Errorf("Expected the retried reset in recent events, got %+v", stats.Recent)
+	}
+
+	// Without retries the reset fails the request
+	noRetry, _ := NewHTTP2Client(&HTTP2ClientConfig{H2C: H2CPriorKnowledge, MaxStreamRetries: -1})
+	listener2, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer listener2.Close()
+	go (&resettingServer{listener: listener2}).serve()
+	req, _ = http.NewRequest(http.MethodGet, "http://"+listener2.Addr().String()+"/", nil)
+	if resp, err := noRetry.Do(req); err == nil {
+		resp.Body.Close()
+		t.Error("Expected the reset to fail the request without retries")
+	} else if ClassifyRequestError(err) != ErrorTypeHTTP2Reset {
+		t.Errorf("Expected a stream reset error, got %v", err)
+	}
+}
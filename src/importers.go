@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result formats of other load generators that can be imported as baselines
+const (
+	ImportFormatK6     = "k6"     // k6 --summary-export or handleSummary JSON
+	ImportFormatVegeta = "vegeta" // vegeta report -type=json
+	ImportFormatWrk2   = "wrk2"   // wrk2 (or wrk) text output, ideally with --latency
+)
+
+// ImportBenchmarkResult converts another tool's summary into a
+// BenchmarkResult, detecting its format. Only summary statistics are
+// available, so StdDev and some percentiles may be zero
+func ImportBenchmarkResult(data []byte) (*BenchmarkResult, string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &probe); err != nil {
+			return nil, "", fmt.Errorf("failed to parse JSON results: %w", err)
+		}
+		switch {
+		case probe["metrics"] != nil:
+			result, err := importK6(trimmed)
+			return result, ImportFormatK6, err
+		case probe["latencies"] != nil:
+			result, err := importVegeta(trimmed)
+			return result, ImportFormatVegeta, err
+		}
+		return nil, "", fmt.Errorf("unrecognized JSON results: expected a k6 summary or vegeta report")
+	}
+	if bytes.Contains(data, []byte("Requests/sec:")) {
+		result, err := importWrk(string(data))
+		return result, ImportFormatWrk2, err
+	}
+	return nil, "", fmt.Errorf("unrecognized results: expected apilo, k6 or vegeta JSON, or wrk2 output")
+}
+
+// LoadBaseline reads a suite saved by apilo, or imports another tool's
+// results as a suite with a single run named after the file
+func LoadBaseline(path string) (*BenchmarkSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var suite BenchmarkSuite
+	if err := json.Unmarshal(data, &suite); err == nil && len(suite.Runs) > 0 {
+		return &suite, nil
+	}
+
+	result, format, err := ImportBenchmarkResult(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &BenchmarkSuite{
+		Name:        fmt.Sprintf("%s (%s)", name, format),
+		Description: fmt.Sprintf("Imported from %s", path),
+		Runs: []BenchmarkRun{{
+			Name:       name,
+			Iterations: 1,
+			Results:    []*BenchmarkResult{result},
+		}},
+	}, nil
+}
+
+// k6Metric holds a metric's summary values. --summary-export writes them at
+// the top level and handleSummary under "values"
+type k6Metric map[string]json.RawMessage
+
+// value returns the named summary value, e.g. "avg" or "p(95)"
+func (m k6Metric) value(name string) float64 {
+	source := map[string]json.RawMessage(m)
+	if nested, ok := m["values"]; ok {
+		var values map[string]json.RawMessage
+		if json.Unmarshal(nested, &values) == nil {
+			source = values
+		}
+	}
+	var value float64
+	json.Unmarshal(source[name], &value)
+	return value
+}
+
+// importK6 converts a k6 end-of-test summary. Durations are milliseconds.
+// k6 counts 4xx and 5xx responses in http_req_failed by default, so failures
+// may include responses apilo would count as successful
+func importK6(data []byte) (*BenchmarkResult, error) {
+	var summary struct {
+		Metrics map[string]k6Metric `json:"metrics"`
+		State   struct {
+			TestRunDurationMs float64 `json:"testRunDurationMs"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse k6 summary: %w", err)
+	}
+	duration, ok := summary.Metrics["http_req_duration"]
+	if !ok {
+		return nil, fmt.Errorf("k6 summary has no http_req_duration metric")
+	}
+
+	reqs := summary.Metrics["http_reqs"]
+	total := int(reqs.value("count"))
+	failed := 0
+	if rate, ok := summary.Metrics["http_req_failed"]; ok {
+		// A rate metric's "passes" are its non-zero samples, here failures
+		failed = int(rate.value("passes"))
+		if failed == 0 && rate.value("value") > 0 {
+			failed = int(rate.value("value")*float64(total) + 0.5)
+		}
+	}
+
+	result := &BenchmarkResult{
+		TotalRequests:  total,
+		Concurrency:    int(summary.Metrics["vus_max"].value("value")),
+		SuccessfulReqs: total - failed,
+		FailedReqs:     failed,
+		TotalBytes:     int64(summary.Metrics["data_received"].value("count")),
+		LatencyStats:   k6Stats(duration, total-failed),
+	}
+	if failed > 0 {
+		result.ErrorTypes = map[string]int{ErrorTypeOther: failed}
+	}
+
+	// k6 splits each request into the same phases apilo measures
+	phases := map[string]*LatencyStats{
+		"http_req_connecting":      &result.ConnectionStats,
+		"http_req_tls_handshaking": &result.TLSStats,
+		"http_req_waiting":         &result.ServerStats,
+		"http_req_receiving":       &result.DownloadStats,
+	}
+	for name, stats := range phases {
+		if metric, ok := summary.Metrics[name]; ok {
+			*stats = k6Stats(metric, total-failed)
+		}
+	}
+
+	seconds := summary.State.TestRunDurationMs / 1000
+	if seconds <= 0 && reqs.value("rate") > 0 {
+		seconds = float64(total) / reqs.value("rate")
+	}
+	setImportedThroughput(result, time.Duration(seconds*float64(time.Second)))
+	return result, nil
+}
+
+// k6Stats converts a k6 trend metric
+func k6Stats(metric k6Metric, samples int) LatencyStats {
+	return LatencyStats{
+		Min:     metric.value("min"),
+		Max:     metric.value("max"),
+		Mean:    metric.value("avg"),
+		Median:  metric.value("med"),
+		P50:     metric.value("med"),
+		P95:     metric.value("p(95)"),
+		P99:     metric.value("p(99)"),
+		Samples: samples,
+	}
+}
+
+// importVegeta converts a vegeta JSON report. Durations are nanoseconds; a
+// status code of 0 marks requests that failed without a response
+func importVegeta(data []byte) (*BenchmarkResult, error) {
+	var report struct {
+		Latencies struct {
+			Mean float64 `json:"mean"`
+			P50  float64 `json:"50th"`
+			P95  float64 `json:"95th"`
+			P99  float64 `json:"99th"`
+			Max  float64 `json:"max"`
+			Min  float64 `json:"min"`
+		} `json:"latencies"`
+		BytesIn struct {
+			Total int64 `json:"total"`
+		} `json:"bytes_in"`
+		Earliest    time.Time      `json:"earliest"`
+		Duration    time.Duration  `json:"duration"`
+		Wait        time.Duration  `json:"wait"`
+		Requests    int            `json:"requests"`
+		StatusCodes map[string]int `json:"status_codes"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse vegeta report: %w", err)
+	}
+
+	failed := report.StatusCodes["0"]
+	ms := func(ns float64) float64 { return ns / float64(time.Millisecond) }
+	result := &BenchmarkResult{
+		TotalRequests:  report.Requests,
+		StartTime:      report.Earliest,
+		SuccessfulReqs: report.Requests - failed,
+		FailedReqs:     failed,
+		TotalBytes:     report.BytesIn.Total,
+		LatencyStats: LatencyStats{
+			Min:     ms(report.Latencies.Min),
+			Max:     ms(report.Latencies.Max),
+			Mean:    ms(report.Latencies.Mean),
+			Median:  ms(report.Latencies.P50),
+			P50:     ms(report.Latencies.P50),
+			P95:     ms(report.Latencies.P95),
+			P99:     ms(report.Latencies.P99),
+			Samples: report.Requests,
+		},
+	}
+	if failed > 0 {
+		result.ErrorTypes = map[string]int{ErrorTypeOther: failed}
+	}
+	setImportedThroughput(result, report.Duration+report.Wait)
+	return result, nil
+}
+
+var (
+	wrkTargetPattern     = regexp.MustCompile(`Running .* test @ (\S+)`)
+	wrkConnsPattern      = regexp.MustCompile(`(\d+) threads and (\d+) connections`)
+	wrkLatencyPattern    = regexp.MustCompile(`(?m)^\s*Latency\s+(\S+)\s+(\S+)\s+(\S+)\s+\S+%`)
+	wrkPercentilePattern = regexp.MustCompile(`(?m)^\s*(\d+(?:\.\d+)?)%\s+(\S+)\s*$`)
+	wrkTotalPattern      = regexp.MustCompile(`(\d+) requests in (\S+), (\S+) read`)
+	wrkSocketPattern     = regexp.MustCompile(`Socket errors: connect (\d+), read (\d+), write (\d+), timeout (\d+)`)
+)
+
+// importWrk converts wrk2 or wrk text output. Percentiles other than the
+// median are only printed with --latency
+func importWrk(output string) (*BenchmarkResult, error) {
+	total := wrkTotalPattern.FindStringSubmatch(output)
+	if total == nil {
+		return nil, fmt.Errorf("wrk output has no \"N requests in T\" summary line")
+	}
+	requests, _ := strconv.Atoi(total[1])
+	duration, err := parseWrkDuration(total[2])
+	if err != nil {
+		return nil, err
+	}
+	totalBytes, err := parseWrkBytes(total[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// Socket errors are the only failures; non-2xx responses still completed
+	failed := 0
+	if socket := wrkSocketPattern.FindStringSubmatch(output); socket != nil {
+		for _, count := range socket[1:] {
+			n, _ := strconv.Atoi(count)
+			failed += n
+		}
+	}
+	failed = min(failed, requests)
+
+	result := &BenchmarkResult{
+		TotalRequests:  requests,
+		SuccessfulReqs: requests - failed,
+		FailedReqs:     failed,
+		TotalBytes:     totalBytes,
+	}
+	if target := wrkTargetPattern.FindStringSubmatch(output); target != nil {
+		result.TargetURL = target[1]
+	}
+	if conns := wrkConnsPattern.FindStringSubmatch(output); conns != nil {
+		result.Concurrency, _ = strconv.Atoi(conns[2])
+	}
+	if failed > 0 {
+		result.ErrorTypes = map[string]int{ErrorTypeOther: failed}
+	}
+
+	stats := LatencyStats{Samples: requests - failed}
+	if latency := wrkLatencyPattern.FindStringSubmatch(output); latency != nil {
+		values := make([]float64, 3)
+		for i, value := range latency[1:] {
+			if values[i], err = parseWrkMillis(value); err != nil {
+				return nil, err
+			}
+		}
+		stats.Mean, stats.StdDev, stats.Max = values[0], values[1], values[2]
+	}
+	for _, match := range wrkPercentilePattern.FindAllStringSubmatch(output, -1) {
+		percentile, _ := strconv.ParseFloat(match[1], 64)
+		value, err := parseWrkMillis(match[2])
+		if err != nil {
+			return nil, err
+		}
+		switch percentile {
+		case 50:
+			stats.P50, stats.Median = value, value
+		case 95:
+			stats.P95 = value
+		case 99:
+			stats.P99 = value
+		case 100:
+			stats.Max = value
+		}
+	}
+	result.LatencyStats = stats
+
+	setImportedThroughput(result, duration)
+	return result, nil
+}
+
+// parseWrkMillis parses a wrk time such as 431.24us or 1.08ms as milliseconds
+func parseWrkMillis(value string) (float64, error) {
+	d, err := parseWrkDuration(value)
+	return float64(d) / float64(time.Millisecond), err
+}
+
+// parseWrkDuration parses a wrk time, which uses us, ms, s, m and h units
+func parseWrkDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wrk time %q: %w", value, err)
+	}
+	return d, nil
+}
+
+// parseWrkBytes parses a wrk size such as 21.45MB, which uses 1024-based units
+func parseWrkBytes(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid wrk size %q: %w", value, err)
+			}
+			return int64(n * unit.scale), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid wrk size %q", value)
+}
+
+// setImportedThroughput fills duration-derived fields the way
+// calculateResults does
+func setImportedThroughput(result *BenchmarkResult, duration time.Duration) {
+	result.Duration = duration
+	if !result.StartTime.IsZero() {
+		result.EndTime = result.StartTime.Add(duration)
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		result.RequestsPerSecond = float64(result.SuccessfulReqs) / seconds
+		result.BytesPerSecond = float64(result.TotalBytes) / seconds
+	}
+	if result.TotalRequests > 0 {
+		result.SuccessRate = float64(result.SuccessfulReqs) / float64(result.TotalRequests)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const k6Summary = `{
+  "metrics": {
+    "http_req_duration": {"avg": 120.5, "min": 80.1, "med": 110.2, "max": 900.3, "p(90)": 180, "p(95)": 210.7},
+    "http_req_waiting": {"avg": 100, "min": 70, "med": 95, "max": 850, "p(90)": 150, "p(95)": 190},
+    "http_reqs": {"count": 1000, "rate": 100},
+    "http_req_failed": {"passes": 10, "fails": 990, "value": 0.01},
+    "data_received": {"count": 2048000, "rate": 204800},
+    "vus_max": {"value": 20, "min": 20, "max": 20}
+  },
+  "state": {"testRunDurationMs": 10000}
+}`
+
+const k6HandleSummary = `{
+  "metrics": {
+    "http_req_duration": {"type": "trend", "contains": "time", "values": {"avg": 50, "min": 10, "med": 45, "max": 300, "p(95)": 90, "p(99)": 150}},
+    "http_reqs": {"type": "counter", "values": {"count": 500, "rate": 50}}
+  }
+}`
+
+const vegetaReport = `{
+  "latencies": {"total": 500000000000, "mean": 50000000, "50th": 45000000, "90th": 80000000, "95th": 90000000, "99th": 150000000, "max": 300000000, "min": 10000000},
+  "bytes_in": {"total": 1024000, "mean": 1024},
+  "bytes_out": {"total": 0, "mean": 0},
+  "earliest": "2024-05-01T10:00:00Z",
+  "latest": "2024-05-01T10:00:09.99Z",
+  "end": "2024-05-01T10:00:10Z",
+  "duration": 9990000000,
+  "wait": 10000000,
+  "requests": 1000,
+  "rate": 100.1,
+  "throughput": 98,
+  "success": 0.98,
+  "status_codes": {"0": 5, "200": 980, "503": 15},
+  "errors": ["Get \"http://localhost\": dial tcp: connection refused"]
+}`
+
+const wrk2Output = `Running 30s test @ http://localhost:8080/api
+  2 threads and 100 connections
+  Thread calibration: mean lat.: 1.234ms, rate sampling interval: 10ms
+  Thread Stats   Avg      Stdev     Max   +/- Stdev
+    Latency     1.08ms  431.24us   9.87ms   70.12%
+    Req/Sec     1.05k   120.45     1.50k    75.00%
+  Latency Distribution (HdrHistogram - Recorded Latency)
+ 50.000%    1.02ms
+ 75.000%    1.31ms
+ 90.000%    1.62ms
+ 99.000%    2.45ms
+ 99.900%    5.12ms
+100.000%    9.87ms
+
+  Detailed Percentile spectrum:
+       Value   Percentile   TotalCount 1/(1-Percentile)
+
+       0.180     0.000000            1         1.00
+#[Mean    =        1.080, StdDeviation   =        0.431]
+  59987 requests in 30.00s, 21.45MB read
+  Socket errors: connect 0, read 3, write 0, timeout 2
+Requests/sec:   1999.57
+Transfer/sec:    732.15KB
+`
+
+const wrkOutput = `Running 10s test @ http://localhost:8080
+  4 threads and 64 connections
+  Thread Stats   Avg      Stdev     Max   +/- Stdev
+    Latency     2.50ms    1.10ms  40.00ms   90.00%
+    Req/Sec     6.50k   500.00     7.20k    80.00%
+  Latency Distribution
+     50%    2.30ms
+     75%    2.90ms
+     90%    3.60ms
+     99%    6.80ms
+  260000 requests in 10.00s, 30.00MB read
+Requests/sec:  26000.00
+Transfer/sec:      3.00MB
+`
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("%s: expected %.3f, got %.3f", name, want, got)
+	}
+}
+
+// TestImportK6 tests both the --summary-export and handleSummary layouts
+func TestImportK6(t *testing.T) {
+	result, format, err := ImportBenchmarkResult([]byte(k6Summary))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if format != ImportFormatK6 {
+		t.Errorf("Expected format k6, got %s", format)
+	}
+	if result.TotalRequests != 1000 || result.FailedReqs != 10 || result.SuccessfulReqs != 990 {
+		t.Errorf("Expected 1000 requests with 10 failed, got %d with %d failed", result.TotalRequests, result.FailedReqs)
+	}
+	if result.Duration != 10*time.Second || result.Concurrency != 20 {
+		t.Errorf("Expected 10s with 20 VUs, got %v with %d", result.Duration, result.Concurrency)
+	}
+	assertClose(t, "RPS", result.RequestsPerSecond, 99)
+	assertClose(t, "P95", result.LatencyStats.P95, 210.7)
+	assertClose(t, "P50", result.LatencyStats.P50, 110.2)
+	assertClose(t, "server P95", result.ServerStats.P95, 190)
+
+	result, _, err = ImportBenchmarkResult([]byte(k6HandleSummary))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	assertClose(t, "P99", result.LatencyStats.P99, 150)
+	if result.Duration != 10*time.Second {
+		t.Errorf("Expected duration derived from the request rate, got %v", result.Duration)
+	}
+}
+
+// TestImportVegeta tests that only requests without a response count as failed
+func TestImportVegeta(t *testing.T) {
+	result, format, err := ImportBenchmarkResult([]byte(vegetaReport))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if format != ImportFormatVegeta {
+		t.Errorf("Expected format vegeta, got %s", format)
+	}
+	if result.FailedReqs != 5 || result.SuccessfulReqs != 995 {
+		t.Errorf("Expected 5 failed and 995 successful, got %d and %d", result.FailedReqs, result.SuccessfulReqs)
+	}
+	if result.Duration != 10*time.Second {
+		t.Errorf("Expected duration including wait of 10s, got %v", result.Duration)
+	}
+	assertClose(t, "P95", result.LatencyStats.P95, 90)
+	assertClose(t, "Mean", result.LatencyStats.Mean, 50)
+	assertClose(t, "RPS", result.RequestsPerSecond, 99.5)
+}
+
+// TestImportWrk tests wrk2 and wrk latency output
+func TestImportWrk(t *testing.T) {
+	result, format, err := ImportBenchmarkResult([]byte(wrk2Output))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if format != ImportFormatWrk2 {
+		t.Errorf("Expected format wrk2, got %s", format)
+	}
+	if result.TargetURL != "http://localhost:8080/api" || result.Concurrency != 100 {
+		t.Errorf("Expected target and 100 connections, got %s and %d", result.TargetURL, result.Concurrency)
+	}
+	if result.TotalRequests != 59987 || result.FailedReqs != 5 {
+		t.Errorf("Expected 59987 requests with 5 socket errors, got %d with %d", result.TotalRequests, result.FailedReqs)
+	}
+	if result.TotalBytes != 22491955 {
+		t.Errorf("Expected 21.45MB read, got %d bytes", result.TotalBytes)
+	}
+	assertClose(t, "Mean", result.LatencyStats.Mean, 1.08)
+	assertClose(t, "StdDev", result.LatencyStats.StdDev, 0.43124)
+	assertClose(t, "P50", result.LatencyStats.P50, 1.02)
+	assertClose(t, "P99", result.LatencyStats.P99, 2.45)
+	assertClose(t, "Max", result.LatencyStats.Max, 9.87)
+	// No 95th percentile is printed, so it is left unset rather than guessed
+	assertClose(t, "P95", result.LatencyStats.P95, 0)
+
+	result, _, err = ImportBenchmarkResult([]byte(wrkOutput))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	assertClose(t, "wrk P99", result.LatencyStats.P99, 6.8)
+	assertClose(t, "wrk RPS", result.RequestsPerSecond, 26000)
+}
+
+// TestCompareWithImportedBaseline tests that an imported baseline is
+// compared against runs with other names
+func TestCompareWithImportedBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "k6-baseline.json")
+	if err := os.WriteFile(baselinePath, []byte(k6Summary), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &BenchmarkRunner{resultDir: dir, suite: &BenchmarkSuite{
+		Name: "current",
+		Runs: []BenchmarkRun{{
+			Name:    "optimized",
+			Results: []*BenchmarkResult{{RequestsPerSecond: 198, LatencyStats: LatencyStats{P95: 105.35}}},
+		}},
+	}}
+	if err := runner.CompareWithBaseline(baselinePath); err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+
+	report, err := os.ReadFile(filepath.Join(dir, "COMPARISON.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"k6-baseline (k6)", "## optimized", "| Requests/sec | 99.00 | 198.00 | 100.0% |", "-50.0%"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("Expected report to contain %q:\n%s", want, report)
+		}
+	}
+
+	if _, _, err := ImportBenchmarkResult([]byte(`{"foo": 1}`)); err == nil {
+		t.Error("Expected unrecognized JSON to be rejected")
+	}
+}
+
+// FuzzImportBenchmarkResult tests that malformed k6, vegeta and wrk output
+// is rejected rather than panicking, and that any accepted result is usable
+func FuzzImportBenchmarkResult(f *testing.F) {
+	for _, seed := range []string{k6Summary, k6HandleSummary, vegetaReport, wrk2Output, wrkOutput, `{"metrics":{}}`, `{"latencies":null}`, "Requests/sec:"} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, format, err := ImportBenchmarkResult(data)
+		if err != nil {
+			return
+		}
+		if result == nil || format == "" {
+			t.Fatalf("Expected a result and format without an error, got %v and %q", result, format)
+		}
+	})
+}
@@ -53,6 +53,11 @@ type IntegratedConfig struct {
 	HealthCheckEnabled  bool          `yaml:"health_check_enabled"`
 	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
 
+	// apilo daemon whose hottest WarmupTopN URLs are prefetched on Start,
+	// e.g. http://localhost:9876; empty skips it
+	WarmupAnalyticsURL string `yaml:"warmup_analytics_url"`
+	WarmupTopN         int    `yaml:"warmup_top_n"`
+
 	// Performance targets
 	TargetLatency      time.Duration `yaml:"target_latency"`
 	MinCacheHitRatio   float64       `yaml:"min_cache_hit_ratio"`
@@ -260,6 +265,18 @@ func (io *IntegratedOptimizer) performWarmup() error {
 		return fmt.Errorf("client not initialized")
 	}
 
+	// Set warmup timeout
+	ctx, cancel := context.WithTimeout(io.ctx, io.config.WarmupTimeout)
+	defer cancel()
+
+	if io.config.WarmupAnalyticsURL != "" {
+		warmed, err := io.client.WarmupFromAnalytics(ctx, io.config.WarmupAnalyticsURL, io.config.WarmupTopN)
+		if err != nil {
+			return fmt.Errorf("historical warmup failed: %w", err)
+		}
+		log.Printf("Prefetched %d hot URLs from %s", warmed, io.config.WarmupAnalyticsURL)
+	}
+
 	warmupURLs := io.config.WarmupURLs
 	if len(warmupURLs) == 0 {
 		// Use default warmup URLs if none configured
@@ -272,10 +289,6 @@ func (io *IntegratedOptimizer) performWarmup() error {
 
 	log.Printf("Starting cache warmup with %d URLs...", len(warmupURLs))
 
-	// Set warmup timeout
-	ctx, cancel := context.WithTimeout(io.ctx, io.config.WarmupTimeout)
-	defer cancel()
-
 	// Create a channel to track warmup completion
 	done := make(chan error, 1)
 
@@ -0,0 +1,461 @@
+//go:build integration
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// The integration suite runs against containerized upstreams and needs a
+// Docker daemon; run it with go test -tags=integration ./src. Without one
+// every test is skipped
+
+// nginxConf serves a small JSON document over HTTP/1.1 on port 80 and over
+// HTTP/2 with TLS on port 443
+const nginxConf = `server {
+    listen 80;
+    listen 443 ssl;
+    http2 on;
+    ssl_certificate     /etc/nginx/conf.d/server.pem;
+    ssl_certificate_key /etc/nginx/conf.d/server-key.pem;
+
+    location / {
+        default_type application/json;
+        add_header Cache-Control "max-age=300";
+        return 200 '{"path":"$uri","items":[1,2,3,4,5,6,7,8]}';
+    }
+}
+`
+
+// integrationStack is the running upstreams of one test
+type integrationStack struct {
+	pool    *dockertest.Pool
+	network *dockertest.Network
+	pki     *testPKI
+	nginx   *dockertest.Resource
+}
+
+// newIntegrationStack connects to Docker and starts nginx on a private
+// network, skipping the test when Docker is unavailable. Everything started
+// is removed when the test ends
+func newIntegrationStack(t *testing.T) *integrationStack {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Docker is not available: %v", err)
+	}
+	if err := pool.Client.Ping(); err != nil {
+		t.Skipf("Docker is not available: %v", err)
+	}
+	pool.MaxWait = 2 * time.Minute
+
+	network, err := pool.CreateNetwork(fmt.Sprintf("apilo-integration-%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatalf("Failed to create network: %v", err)
+	}
+	t.Cleanup(func() { network.Close() })
+
+	stack := &integrationStack{pool: pool, network: network, pki: newTestPKI(t)}
+	stack.pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	if err := os.WriteFile(filepath.Join(stack.pki.dir, "default.conf"), []byte(nginxConf), 0644); err != nil {
+		t.Fatalf("Failed to write nginx config: %v", err)
+	}
+	stack.nginx = stack.run(t, &dockertest.RunOptions{
+		Repository:   "nginx",
+		Tag:          "1.27-alpine",
+		Mounts:       []string{stack.pki.dir + ":/etc/nginx/conf.d:ro"},
+		ExposedPorts: []string{"80/tcp", "443/tcp"},
+	})
+	stack.waitHTTP(t, "http://"+stack.nginx.GetHostPort("80/tcp")+"/", http.DefaultClient)
+	return stack
+}
+
+// run starts a container on the stack's network and removes it when the
+// test ends
+func (s *integrationStack) run(t *testing.T, options *dockertest.RunOptions) *dockertest.Resource {
+	options.Networks = []*dockertest.Network{s.network}
+	resource, err := s.pool.RunWithOptions(options, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		t.Fatalf("Failed to start %s:%s: %v", options.Repository, options.Tag, err)
+	}
+	resource.Expire(300)
+	t.Cleanup(func() { s.pool.Purge(resource) })
+	return resource
+}
+
+// waitHTTP retries a GET of url until it succeeds
+func (s *integrationStack) waitHTTP(t *testing.T, url string, client *http.Client) {
+	err := s.pool.Retry(func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s never became ready: %v", url, err)
+	}
+}
+
+// toxiproxy starts toxiproxy with one proxy, "upstream", in front of nginx's
+// port 80, and returns its API and the proxy's addresses
+func (s *integrationStack) toxiproxy(t *testing.T) (api, upstream string) {
+	resource := s.run(t, &dockertest.RunOptions{
+		Repository:   "ghcr.io/shopify/toxiproxy",
+		Tag:          "2.9.0",
+		ExposedPorts: []string{"8474/tcp", "8666/tcp"},
+	})
+	api = "http://" + resource.GetHostPort("8474/tcp")
+	s.waitHTTP(t, api+"/version", http.DefaultClient)
+
+	toxiproxyCall(t, http.MethodPost, api+"/proxies", map[string]any{
+		"name":     "upstream",
+		"listen":   "0.0.0.0:8666",
+		"upstream": net.JoinHostPort(s.nginx.GetIPInNetwork(s.network), "80"),
+		"enabled":  true,
+	})
+	upstream = "http://" + resource.GetHostPort("8666/tcp")
+	s.waitHTTP(t, upstream+"/", http.DefaultClient)
+	return api, upstream
+}
+
+// toxiproxyCall sends one request to the toxiproxy API, failing the test
+// unless it succeeds
+func toxiproxyCall(t *testing.T, method, url string, body any) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, url, reader)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("toxiproxy %s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		t.Fatalf("toxiproxy %s %s returned %d: %s", method, url, resp.StatusCode, message)
+	}
+}
+
+// TestIntegrationNginxHTTP2 tests that the benchmarker and the optimized
+// client negotiate HTTP/2 with a real server and reuse its connection
+func TestIntegrationNginxHTTP2(t *testing.T) {
+	stack := newIntegrationStack(t)
+	target := "https://" + stack.nginx.GetHostPort("443/tcp") + "/items"
+	tlsConfig := &ClientTLSConfig{CAFile: stack.pki.caFile}
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     target,
+		Method:        "GET",
+		TotalRequests: 50,
+		Concurrency:   5,
+		Timeout:       10 * time.Second,
+		KeepAlive:     true,
+		TLS:           tlsConfig,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.SuccessfulReqs != 50 {
+		t.Errorf("Expected 50 successful requests, got %d", result.SuccessfulReqs)
+	}
+	if result.Protocols == nil || len(result.Protocols.Hosts) != 1 || result.Protocols.Hosts[0].Requests["HTTP/2.0"] != 50 {
+		t.Errorf("Expected every response over HTTP/2, got %+v", result.Protocols)
+	}
+
+	config := DefaultOptimizedClientConfig()
+	config.MonitoringConfig.Enabled = false
+	config.CacheConfig.WarmupEnabled = false
+	config.TLS = tlsConfig
+	client, err := NewOptimizedClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Stop()
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		resp, err := client.Do(&OptimizedRequest{Request: req})
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("Request %d: expected HTTP/2, got %s", i, resp.Proto)
+		}
+		if i > 0 && !resp.ConnectionReused {
+			t.Errorf("Request %d: expected the connection to be reused", i)
+		}
+	}
+}
+
+// TestIntegrationToxiproxyFaults tests latency injection, the circuit
+// breaker opening on an unreachable upstream, and failing over to a healthy
+// one, through toxiproxy in front of nginx
+func TestIntegrationToxiproxyFaults(t *testing.T) {
+	stack := newIntegrationStack(t)
+	api, proxied := stack.toxiproxy(t)
+	direct := "http://" + stack.nginx.GetHostPort("80/tcp")
+
+	// 200ms of added latency shows in every percentile
+	toxiproxyCall(t, http.MethodPost, api+"/proxies/upstream/toxics", map[string]any{
+		"name":       "latency",
+		"type":       "latency",
+		"stream":     "downstream",
+		"attributes": map[string]any{"latency": 200},
+	})
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     proxied + "/slow",
+		Method:        "GET",
+		TotalRequests: 10,
+		Concurrency:   2,
+		Timeout:       10 * time.Second,
+		KeepAlive:     true,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.SuccessfulReqs != 10 || result.LatencyStats.P50 < 200 {
+		t.Errorf("Expected 10 requests at 200ms or more, got %d at P50 %.1fms", result.SuccessfulReqs, result.LatencyStats.P50)
+	}
+	toxiproxyCall(t, http.MethodDelete, api+"/proxies/upstream/toxics/latency", nil)
+
+	// With the proxy down every request fails until the breaker opens
+	toxiproxyCall(t, http.MethodPost, api+"/proxies/upstream", map[string]any{"enabled": false})
+	breaker := DefaultCircuitBreakerConfig()
+	breaker.FailureThreshold, breaker.MinimumRequests = 3, 3
+	config := DefaultOptimizedClientConfig()
+	config.MonitoringConfig.Enabled = false
+	config.CacheConfig.Enabled = false
+	config.CircuitBreaker = breaker
+	config.MaxRetries = 0
+	config.RequestTimeout = 2 * time.Second
+	client, err := NewOptimizedClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Stop()
+
+	var lastErr error
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, proxied+"/down", nil)
+		if _, lastErr = client.Do(&OptimizedRequest{Request: req}); lastErr == nil {
+			t.Fatalf("Request %d: expected the disabled proxy to fail", i)
+		}
+	}
+	if !errors.Is(lastErr, ErrCircuitOpen) {
+		t.Errorf("Expected the breaker to open, got %v", lastErr)
+	}
+
+	// Failing over sends traffic to nginx directly while the proxy is down
+	primaryConfig := DefaultCircuitBreakerConfig()
+	primaryConfig.FailureThreshold, primaryConfig.MinimumRequests = 2, 2
+	failoverConfig := DefaultFailoverConfig()
+	failoverConfig.AutoRecovery, failoverConfig.EnableFallback = false, false
+	failoverConfig.RetryDelay = 0
+	failover := NewFailoverManager(NewCircuitBreaker(primaryConfig), []*CircuitBreaker{NewCircuitBreaker(nil)}, failoverConfig)
+	endpoints := []string{proxied, direct}
+	var served [2]atomic.Int64
+
+	for i := 0; i < 10; i++ {
+		_, err := failover.Execute(func() (interface{}, error) {
+			endpoint := atomic.LoadInt32(&failover.currentService)
+			resp, err := http.Get(endpoints[endpoint] + "/failover")
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+			served[endpoint].Add(1)
+			return resp.StatusCode, nil
+		})
+		if err != nil && i >= 2 {
+			t.Errorf("Request %d: expected failover to serve it, got %v", i, err)
+		}
+	}
+	if served[0].Load() != 0 || served[1].Load() < 8 {
+		t.Errorf("Expected the backup to serve at least 8 requests, got %d primary and %d backup", served[0].Load(), served[1].Load())
+	}
+}
+
+// redisConn is a minimal RESP client, enough to use redis as a remote
+// cache tier
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialRedis connects to redis at addr
+func dialRedis(addr string) (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &redisConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// do sends a command and returns its reply; a nil bulk reply is nil
+func (r *redisConn) do(args ...string) ([]byte, error) {
+	var command bytes.Buffer
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	r.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := r.conn.Write(command.Bytes()); err != nil {
+		return nil, err
+	}
+
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	}
+	return nil, fmt.Errorf("unsupported reply %q", line)
+}
+
+// TestIntegrationRedisCacheTier tests a response fetched from nginx by one
+// process reaching another through redis: a miss in the second process's
+// in-memory tier is filled from the shared tier and promoted, for every
+// cache codec
+func TestIntegrationRedisCacheTier(t *testing.T) {
+	stack := newIntegrationStack(t)
+	redis := stack.run(t, &dockertest.RunOptions{Repository: "redis", Tag: "7-alpine"})
+	addr := redis.GetHostPort("6379/tcp")
+	if err := stack.pool.Retry(func() error {
+		conn, err := dialRedis(addr)
+		if err != nil {
+			return err
+		}
+		defer conn.conn.Close()
+		_, err = conn.do("PING")
+		return err
+	}); err != nil {
+		t.Fatalf("redis never became ready: %v", err)
+	}
+
+	target := "http://" + stack.nginx.GetHostPort("80/tcp") + "/users"
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", target, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, name := range []string{"binary", "json", "binary+gzip", "json+gzip"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := CacheCodecByName(name)
+			if err != nil {
+				t.Fatalf("CacheCodecByName failed: %v", err)
+			}
+			key := "GET:" + target + ":" + name
+			now := time.Now()
+			entry := &CacheEntry{
+				Key:        key,
+				Value:      body,
+				StatusCode: resp.StatusCode,
+				Headers:    map[string]string{"Content-Type": resp.Header.Get("Content-Type")},
+				Size:       int64(len(body)),
+				CreatedAt:  now,
+				TTL:        5 * time.Minute,
+				ExpiresAt:  now.Add(5 * time.Minute),
+			}
+
+			// The first process fills both tiers
+			writer, err := dialRedis(addr)
+			if err != nil {
+				t.Fatalf("Failed to connect to redis: %v", err)
+			}
+			defer writer.conn.Close()
+			NewShardedCache(4, 100, 10).Put(key, entry)
+			data, err := codec.Encode(entry)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if _, err := writer.do("SET", key, string(data), "PX", strconv.FormatInt(entry.TTL.Milliseconds(), 10)); err != nil {
+				t.Fatalf("SET failed: %v", err)
+			}
+
+			// The second process misses in memory and fills from redis
+			reader, err := dialRedis(addr)
+			if err != nil {
+				t.Fatalf("Failed to connect to redis: %v", err)
+			}
+			defer reader.conn.Close()
+			local := NewShardedCache(4, 100, 10)
+			if _, ok := local.Get(key); ok {
+				t.Fatal("Expected a miss in a new in-memory tier")
+			}
+			shared, err := reader.do("GET", key)
+			if err != nil || shared == nil {
+				t.Fatalf("Expected the entry in redis, got %v", err)
+			}
+			decoded, err := codec.Decode(shared)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			local.Put(key, decoded)
+
+			promoted, ok := local.Get(key)
+			if !ok {
+				t.Fatal("Expected the entry promoted to the in-memory tier")
+			}
+			req, _ := http.NewRequest(http.MethodGet, target, nil)
+			cached, _ := io.ReadAll(promoted.Response(req).Body)
+			if !bytes.Equal(cached, body) || !promoted.ExpiresAt.Equal(entry.ExpiresAt) {
+				t.Errorf("Expected %s expiring at %v, got %s expiring at %v", body, entry.ExpiresAt, cached, promoted.ExpiresAt)
+			}
+			if ttl, err := reader.do("PTTL", key); err != nil || string(ttl) == "-1" {
+				t.Errorf("Expected redis to expire the entry, got TTL %s (%v)", ttl, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestStartWarmsFromAnalytics tests that Start prefetches the hottest URLs a
+// daemon's /analytics reports, so the first requests for them hit the cache
+func TestStartWarmsFromAnalytics(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	analytics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/analytics" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"top_urls": []map[string]interface{}{
+				{"url": upstream.URL + "/cold", "total_requests": 2},
+				{"url": upstream.URL + "/hottest", "total_requests": 90},
+				{"url": upstream.URL + "/hot", "total_requests": 40},
+			},
+		})
+	}))
+	defer analytics.Close()
+
+	config := DefaultIntegratedConfig()
+	config.ClientConfig.MonitoringConfig.Enabled = false
+	config.HealthCheckEnabled = false
+	config.WarmupURLs = []string{upstream.URL + "/static"}
+	config.WarmupAnalyticsURL = analytics.URL
+	config.WarmupTopN = 2
+
+	optimizer, err := NewIntegratedOptimizer(config)
+	if err != nil {
+		t.Fatalf("NewIntegratedOptimizer failed: %v", err)
+	}
+	if err := optimizer.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer optimizer.Stop()
+
+	mu.Lock()
+	if fetched["/hottest"] != 1 || fetched["/hot"] != 1 {
+		t.Errorf("Expected the top 2 URLs prefetched once each, got %v", fetched)
+	}
+	if fetched["/cold"] != 0 {
+		t.Errorf("Expected URLs past the top 2 left alone, got %d fetches", fetched["/cold"])
+	}
+	mu.Unlock()
+
+	req, _ := http.NewRequest("GET", upstream.URL+"/hottest", nil)
+	resp, err := optimizer.client.Do(&OptimizedRequest{Request: req, UseCache: true})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if !resp.CacheHit {
+		t.Error("Expected the prefetched URL to be served from the cache")
+	}
+}
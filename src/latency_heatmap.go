@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// heatmapLatencyBoundsMs are the upper bounds of the heatmap's latency
+// buckets, log-spaced so fast and slow modes both stay visible. Latencies
+// above the last bound fall in an overflow bucket
+var heatmapLatencyBoundsMs = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// heatmapTimeBuckets is how many time buckets a run is split into
+const heatmapTimeBuckets = 60
+
+// heatmapMinBucketWidth keeps short runs from being split finer than the
+// clock resolution makes meaningful
+const heatmapMinBucketWidth = 100 * time.Millisecond
+
+// LatencyHeatmap counts requests by when they were sent and how long they
+// took. Unlike a percentile line it shows bimodal latency and periodic spikes
+type LatencyHeatmap struct {
+	Start       time.Time     `json:"start"`
+	BucketWidth time.Duration `json:"bucket_width"`
+
+	// Upper bounds of the latency buckets; each row of Counts has one more
+	// column for latencies above the last bound
+	LatencyBoundsMs []float64 `json:"latency_bounds_ms"`
+
+	Counts [][]int `json:"counts"` // Successful requests per time bucket and latency bucket
+	Errors []int   `json:"errors"` // Failed requests per time bucket
+	Max    int     `json:"max"`    // Largest count, for scaling colors
+}
+
+// BuildLatencyHeatmap buckets metrics by send time and latency, or returns
+// nil if there are none
+func BuildLatencyHeatmap(metrics []LatencyMetrics) *LatencyHeatmap {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	start, end := metrics[0].Timestamp, metrics[0].Timestamp
+	for _, m := range metrics[1:] {
+		if m.Timestamp.Before(start) {
+			start = m.Timestamp
+		}
+		if m.Timestamp.After(end) {
+			end = m.Timestamp
+		}
+	}
+
+	// Round the width up so the last request lands in the last bucket
+	width := max(end.Sub(start)/heatmapTimeBuckets+1, heatmapMinBucketWidth)
+	buckets := int(end.Sub(start)/width) + 1
+
+	heatmap := &LatencyHeatmap{
+		Start:           start,
+		BucketWidth:     width,
+		LatencyBoundsMs: heatmapLatencyBoundsMs,
+		Counts:          make([][]int, buckets),
+		Errors:          make([]int, buckets),
+	}
+	for i := range heatmap.Counts {
+		heatmap.Counts[i] = make([]int, len(heatmapLatencyBoundsMs)+1)
+	}
+
+	for _, m := range metrics {
+		column := int(m.Timestamp.Sub(start) / width)
+		if m.Error != "" {
+			heatmap.Errors[column]++
+			continue
+		}
+		latency := float64(m.TotalLatency.Microseconds()) / 1000.0
+		row := sort.SearchFloat64s(heatmapLatencyBoundsMs, latency)
+		heatmap.Counts[column][row]++
+		heatmap.Max = max(heatmap.Max, heatmap.Counts[column][row])
+	}
+	return heatmap
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestBuildLatencyHeatmap tests bucketing by send time and latency
+func TestBuildLatencyHeatmap(t *testing.T) {
+	if heatmap := BuildLatencyHeatmap(nil); heatmap != nil {
+		t.Errorf("Expected no heatmap without metrics, got %+v", heatmap)
+	}
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	request := func(offset, latency time.Duration) LatencyMetrics {
+		return LatencyMetrics{Timestamp: start.Add(offset), TotalLatency: latency}
+	}
+	failed := request(59*time.Second, 0)
+	failed.Error = "request failed"
+
+	// A bimodal minute: fast requests throughout, slow ones in the first second
+	metrics := []LatencyMetrics{
+		request(0, 3*time.Millisecond),
+		request(100*time.Millisecond, 3*time.Millisecond),
+		request(200*time.Millisecond, 400*time.Millisecond),
+		request(30*time.Second, time.Millisecond),
+		request(60*time.Second, 20*time.Second),
+		failed,
+	}
+	heatmap := BuildLatencyHeatmap(metrics)
+
+	if !heatmap.Start.Equal(start) {
+		t.Errorf("Expected start %v, got %v", start, heatmap.Start)
+	}
+	if len(heatmap.Counts) > heatmapTimeBuckets || len(heatmap.Counts) != len(heatmap.Errors) {
+		t.Fatalf("Expected at most %d time buckets, got %d counts and %d errors", heatmapTimeBuckets, len(heatmap.Counts), len(heatmap.Errors))
+	}
+	bucket := func(offset time.Duration) int { return int(offset / heatmap.BucketWidth) }
+
+	first := heatmap.Counts[0]
+	if first[2] != 2 || first[8] != 1 {
+		t.Errorf("Expected 2 requests of 2-5ms and 1 of 200-500ms in the first bucket, got %v", first)
+	}
+	if heatmap.Counts[bucket(30*time.Second)][0] != 1 {
+		t.Errorf("Expected a 1ms request in the <=1ms bucket, got %v", heatmap.Counts[bucket(30*time.Second)])
+	}
+	last := heatmap.Counts[len(heatmap.Counts)-1]
+	if last[len(heatmapLatencyBoundsMs)] != 1 {
+		t.Errorf("Expected the 20s request in the overflow bucket, got %v", last)
+	}
+	if heatmap.Errors[bucket(59*time.Second)] != 1 {
+		t.Errorf("Expected the failed request counted as an error, got %v", heatmap.Errors)
+	}
+	if heatmap.Max != 2 {
+		t.Errorf("Expected max count 2, got %d", heatmap.Max)
+	}
+}
+
+// TestBuildLatencyHeatmapShortRun tests that buckets are never narrower than the minimum
+func TestBuildLatencyHeatmapShortRun(t *testing.T) {
+	start := time.Now()
+	heatmap := BuildLatencyHeatmap([]LatencyMetrics{
+		{Timestamp: start, TotalLatency: time.Millisecond},
+		{Timestamp: start.Add(10 * time.Millisecond), TotalLatency: time.Millisecond},
+	})
+	if heatmap.BucketWidth != heatmapMinBucketWidth || len(heatmap.Counts) != 1 {
+		t.Errorf("Expected one %v bucket, got %d of %v", heatmapMinBucketWidth, len(heatmap.Counts), heatmap.BucketWidth)
+	}
+}
+
+// TestHeatmapEndpoint tests that the dashboard serves the last result's heatmap
+func TestHeatmapEndpoint(t *testing.T) {
+	collector := NewMetricsCollector(10)
+	dashboard := &Dashboard{collector: collector}
+
+	recorder := httptest.NewRecorder()
+	dashboard.handleAPIHeatmap(recorder, httptest.NewRequest(http.MethodGet, "/api/heatmap", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before any result, got %d", recorder.Code)
+	}
+
+	collector.UpdateBenchmarkResult(&BenchmarkResult{
+		Heatmap: BuildLatencyHeatmap([]LatencyMetrics{{Timestamp: time.Now(), TotalLatency: 5 * time.Millisecond}}),
+	})
+	recorder = httptest.NewRecorder()
+	dashboard.handleAPIHeatmap(recorder, httptest.NewRequest(http.MethodGet, "/api/heatmap", nil))
+
+	var heatmap LatencyHeatmap
+	if err := json.NewDecoder(recorder.Body).Decode(&heatmap); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(heatmap.Counts) != 1 || heatmap.Counts[0][2] != 1 || len(heatmap.LatencyBoundsMs) != len(heatmapLatencyBoundsMs) {
+		t.Errorf("Unexpected heatmap: %+v", heatmap)
+	}
+}
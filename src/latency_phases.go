@@ -0,0 +1,97 @@
+package main
+
+import "time"
+
+// Request phases reported alongside total latency, in the order they happen.
+// Server time runs from the end of the handshake to the first response byte;
+// download time from the first to the last byte
+const (
+	PhaseDNS      = "dns"
+	PhaseConnect  = "connect"
+	PhaseTLS      = "tls"
+	PhaseServer   = "server"
+	PhaseDownload = "download"
+)
+
+// LatencyPhases lists every phase in request order
+var LatencyPhases = []string{PhaseDNS, PhaseConnect, PhaseTLS, PhaseServer, PhaseDownload}
+
+var phaseLabels = map[string]string{
+	PhaseDNS:      "DNS Lookup",
+	PhaseConnect:  "TCP Connect",
+	PhaseTLS:      "TLS Handshake",
+	PhaseServer:   "Server Processing",
+	PhaseDownload: "Content Download",
+}
+
+// PhaseLabel returns the display name of phase
+func PhaseLabel(phase string) string {
+	if label, ok := phaseLabels[phase]; ok {
+		return label
+	}
+	return phase
+}
+
+// Phase returns how long the request spent in phase. It reports false when the
+// request skipped the phase, e.g. DNS and connect on a reused connection
+func (m LatencyMetrics) Phase(phase string) (time.Duration, bool) {
+	switch phase {
+	case PhaseDNS:
+		return m.DNSLookup, m.DNSLookup > 0
+	case PhaseConnect:
+		return m.TCPConnection, m.TCPConnection > 0
+	case PhaseTLS:
+		return m.TLSHandshake, m.TLSHandshake > 0
+	case PhaseServer:
+		return m.ServerProcessing, m.TimeToFirstByte > 0
+	case PhaseDownload:
+		return m.ContentTransfer, m.TimeToFirstByte > 0
+	}
+	return 0, false
+}
+
+// PhaseStats returns the aggregated statistics of phase
+func (r *BenchmarkResult) PhaseStats(phase string) LatencyStats {
+	switch phase {
+	case PhaseDNS:
+		return r.DNSStats
+	case PhaseConnect:
+		return r.ConnectionStats
+	case PhaseTLS:
+		return r.TLSStats
+	case PhaseServer:
+		return r.ServerStats
+	case PhaseDownload:
+		return r.DownloadStats
+	}
+	return LatencyStats{}
+}
+
+// phaseLatencies groups the per-request durations of every phase, in
+// milliseconds, skipping failed requests and phases a request did not go through
+func phaseLatencies(metrics []LatencyMetrics) map[string][]float64 {
+	latencies := make(map[string][]float64, len(LatencyPhases))
+	for _, m := range metrics {
+		if m.Error != "" {
+			continue
+		}
+		for _, phase := range LatencyPhases {
+			if d, ok := m.Phase(phase); ok {
+				latencies[phase] = append(latencies[phase], float64(d.Microseconds())/1000.0)
+			}
+		}
+	}
+	return latencies
+}
+
+// meanPhaseP95 averages the P95 of phase across results
+func meanPhaseP95(results []*BenchmarkResult, phase string) float64 {
+	if len(results) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, result := range results {
+		sum += result.PhaseStats(phase).P95
+	}
+	return sum / float64(len(results))
+}
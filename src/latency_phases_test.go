@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestLatencyPhaseBreakdown tests that server time and download time are
+// aggregated separately, so a slow body is not mistaken for a slow server
+func TestLatencyPhaseBreakdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+
+		time.Sleep(40 * time.Millisecond)
+		w.Write([]byte("second chunk"))
+	}))
+	defer server.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 4, Concurrency: 1, KeepAlive: true})
+	result, err := benchmarker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	serverTime, downloadTime := result.PhaseStats(PhaseServer), result.PhaseStats(PhaseDownload)
+	if serverTime.Samples != 4 || downloadTime.Samples != 4 {
+		t.Fatalf("Expected 4 samples per phase, got %d and %d", serverTime.Samples, downloadTime.Samples)
+	}
+	if serverTime.P50 < 20 {
+		t.Errorf("Expected at least 20 ms of server time, got %.2f ms", serverTime.P50)
+	}
+	if downloadTime.P50 < 40 || downloadTime.P50 <= serverTime.P50 {
+		t.Errorf("Expected download time above 40 ms and server time, got %.2f ms vs %.2f ms",
+			downloadTime.P50, serverTime.P50)
+	}
+
+	// Keep-alive dials once, so only the first request has a connect phase
+	if connect := result.PhaseStats(PhaseConnect); connect.Samples != 1 {
+		t.Errorf("Expected 1 connect sample, got %d", connect.Samples)
+	}
+	if tls := result.PhaseStats(PhaseTLS); tls.Samples != 0 {
+		t.Errorf("Plain HTTP should have no TLS samples, got %d", tls.Samples)
+	}
+}
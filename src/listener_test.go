@@ -0,0 +1,104 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// TestListenHostAndReusePort tests binding one interface and sharing a port
+func TestListenHostAndReusePort(t *testing.T) {
+	options := ListenOptions{Host: "127.0.0.1", ReusePort: true}
+	first, err := listen("test", options, 0)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer first.Close()
+
+	addr := first.Addr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected to bind 127.0.0.1, got %v", addr.IP)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	second, err := listen("test", options, addr.Port)
+	if err != nil {
+		t.Fatalf("Expected SO_REUSEPORT to share port %d, got %v", addr.Port, err)
+	}
+	second.Close()
+
+	if _, err := listen("test", ListenOptions{Host: "127.0.0.1"}, addr.Port); err == nil {
+		t.Error("Expected binding a used port without SO_REUSEPORT to fail")
+	}
+}
+
+// TestActivatedSockets tests turning passed descriptors into listeners and
+// matching them to servers by name and port
+func TestActivatedSockets(t *testing.T) {
+	named, _ := net.Listen("tcp", "127.0.0.1:0")
+	unnamed, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer named.Close()
+	defer unnamed.Close()
+
+	// Consecutive raw descriptors, as systemd passes them; activatedSockets
+	// takes ownership of them
+	descriptor := func(listener net.Listener) int {
+		file, _ := listener.(*net.TCPListener).File()
+		defer file.Close()
+		fd, _ := syscall.Dup(int(file.Fd()))
+		return fd
+	}
+	first := descriptor(named)
+	if second := descriptor(unnamed); second != first+1 {
+		syscall.Close(first)
+		syscall.Close(second)
+		t.Skip("Descriptors were not allocated consecutively")
+	}
+
+	pid := strconv.Itoa(os.Getpid())
+	if sockets, err := activatedSockets("1", "2", "", first); err != nil || sockets != nil {
+		t.Errorf("Expected another process's sockets to be ignored, got %v, %v", sockets, err)
+	}
+	if _, err := activatedSockets(pid, "two", "", first); err == nil {
+		t.Error("Expected an invalid LISTEN_FDS to be rejected")
+	}
+
+	sockets, err := activatedSockets(pid, "2", "dashboard:unknown", first)
+	if err != nil {
+		t.Fatalf("Failed to read activated sockets: %v", err)
+	}
+	if len(sockets) != 2 || sockets[0].name != "dashboard" || sockets[1].name != "" {
+		t.Fatalf("Expected a named and an unnamed socket, got %+v", sockets)
+	}
+
+	activation.mu.Lock()
+	activation.sockets = sockets
+	activation.mu.Unlock()
+	defer func() {
+		activation.mu.Lock()
+		activation.sockets = nil
+		activation.mu.Unlock()
+	}()
+
+	unnamedPort := unnamed.Addr().(*net.TCPAddr).Port
+	if listener := takeActivatedSocket("prometheus", unnamedPort); listener == nil || listener.Addr().String() != unnamed.Addr().String() {
+		t.Errorf("Expected the unnamed socket on port %d, got %v", unnamedPort, listener)
+	} else {
+		listener.Close()
+	}
+	if listener := takeActivatedSocket("dashboard", 8080); listener == nil || listener.Addr().String() != named.Addr().String() {
+		t.Errorf("Expected the socket named dashboard, got %v", listener)
+	} else {
+		listener.Close()
+	}
+	if listener := takeActivatedSocket("dashboard", 8080); listener != nil {
+		t.Errorf("Expected each socket to be taken once, got %v", listener.Addr())
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConcurrencyAnalysis applies Little's Law, L = λW, to a run: the workers a
+// request rate needs is the rate times how long each request holds a worker.
+// It tells whether the configured concurrency could reach the target rate at
+// the latency measured
+type ConcurrencyAnalysis struct {
+	TargetRPS     float64 `json:"target_rps,omitempty"` // Requested rate; 0 if none was set
+	AchievedRPS   float64 `json:"achieved_rps"`
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+	Configured    int     `json:"configured_concurrency"`
+
+	// Requests in flight on average, and as a share of Configured
+	InFlight    float64 `json:"in_flight"`
+	Utilization float64 `json:"utilization"`
+
+	// Highest rate Configured workers can reach at the mean latency
+	MaxRPS float64 `json:"max_rps"`
+
+	// Workers TargetRPS needs at the mean latency, and at the P95 latency
+	// as headroom for slow requests
+	Required    float64 `json:"required_concurrency,omitempty"`
+	Recommended int     `json:"recommended_concurrency,omitempty"`
+
+	Achievable bool   `json:"achievable"`
+	Warning    string `json:"warning,omitempty"`
+}
+
+// targetRate returns the request rate config asks for: TargetRPS, or else
+// the global rate limit
+func targetRate(config BenchmarkConfig) float64 {
+	if config.TargetRPS > 0 {
+		return config.TargetRPS
+	}
+	if config.RateLimit != nil && config.RateLimit.Global.Rate > 0 {
+		return config.RateLimit.Global.Rate
+	}
+	return 0
+}
+
+// calculateConcurrencyAnalysis checks configured workers against target
+// using the latency of result's successful requests, or returns nil if none
+// succeeded
+func calculateConcurrencyAnalysis(target float64, configured int, result *BenchmarkResult) *ConcurrencyAnalysis {
+	latency := result.LatencyStats
+	if latency.Samples == 0 || latency.Mean <= 0 || configured <= 0 {
+		return nil
+	}
+
+	seconds := latency.Mean / 1000
+	analysis := &ConcurrencyAnalysis{
+		TargetRPS:     target,
+		AchievedRPS:   result.RequestsPerSecond,
+		MeanLatencyMs: latency.Mean,
+		Configured:    configured,
+		InFlight:      result.RequestsPerSecond * seconds,
+		MaxRPS:        float64(configured) / seconds,
+		Achievable:    true,
+	}
+	analysis.Utilization = analysis.InFlight / float64(configured)
+	if target <= 0 {
+		return analysis
+	}
+
+	analysis.Required = target * seconds
+	analysis.Recommended = max(int(math.Ceil(target*math.Max(latency.P95, latency.Mean)/1000)), 1)
+	if analysis.Required > float64(configured) {
+		analysis.Achievable = false
+		analysis.Warning = fmt.Sprintf(
+			"%d workers cannot reach %.2f req/s at %.2f ms per request: at most %.2f req/s; set concurrency to at least %d",
+			configured, target, latency.Mean, analysis.MaxRPS, analysis.Recommended)
+	}
+	return analysis
+}
+
+// printConcurrencyAnalysis writes the Little's Law check of a result
+func printConcurrencyAnalysis(a *ConcurrencyAnalysis) {
+	fmt.Printf("\n--- Concurrency (Little's Law) ---\n")
+	fmt.Printf("In flight: %.2f of %d workers (%.0f%%) at %.2f ms mean latency\n",
+		a.InFlight, a.Configured, a.Utilization*100, a.MeanLatencyMs)
+	fmt.Printf("Max rate at this latency: %.2f req/s\n", a.MaxRPS)
+	if a.TargetRPS > 0 {
+		fmt.Printf("Target %.2f req/s needs %.2f workers (%d with P95 headroom)\n", a.TargetRPS, a.Required, a.Recommended)
+	}
+	if a.Warning != "" {
+		fmt.Printf("WARNING: %s\n", a.Warning)
+	}
+}
+
+// concurrencySection renders the Little's Law check of a run's last
+// iteration as markdown, or returns "" if there is none
+func concurrencySection(results []*BenchmarkResult) string {
+	var analysis *ConcurrencyAnalysis
+	for _, result := range results {
+		if result.LittlesLaw != nil {
+			analysis = result.LittlesLaw
+		}
+	}
+	if analysis == nil {
+		return ""
+	}
+
+	section := "### Concurrency (Little's Law)\n\n"
+	section += "| Figure | Value |\n"
+	section += "|--------|-------|\n"
+	section += fmt.Sprintf("| Configured workers | %d |\n", analysis.Configured)
+	section += fmt.Sprintf("| Mean in flight | %.2f (%.0f%%) |\n", analysis.InFlight, analysis.Utilization*100)
+	section += fmt.Sprintf("| Max rate at %.2f ms | %.2f req/s |\n", analysis.MeanLatencyMs, analysis.MaxRPS)
+	if analysis.TargetRPS > 0 {
+		section += fmt.Sprintf("| Target rate | %.2f req/s |\n", analysis.TargetRPS)
+		section += fmt.Sprintf("| Required workers | %.2f |\n", analysis.Required)
+		section += fmt.Sprintf("| Recommended workers (P95) | %d |\n", analysis.Recommended)
+	}
+	section += "\n"
+	if analysis.Warning != "" {
+		section += fmt.Sprintf("**Warning:** %s.\n\n", analysis.Warning)
+	}
+	return section
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCalculateConcurrencyAnalysis tests required concurrency against the configured workers
+func TestCalculateConcurrencyAnalysis(t *testing.T) {
+	result := &BenchmarkResult{
+		RequestsPerSecond: 40,
+		LatencyStats:      LatencyStats{Samples: 100, Mean: 200, P95: 400},
+	}
+
+	tests := []struct {
+		name        string
+		target      float64
+		configured  int
+		achievable  bool
+		required    float64
+		recommended int
+	}{
+		{"no target", 0, 10, true, 0, 0},
+		{"enough workers", 40, 10, true, 8, 16},
+		{"too few workers", 100, 10, false, 20, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := calculateConcurrencyAnalysis(tt.target, tt.configured, result)
+			if analysis.Achievable != tt.achievable || (analysis.Warning == "") != tt.achievable {
+				t.Errorf("Expected achievable=%v, got %v (%q)", tt.achievable, analysis.Achievable, analysis.Warning)
+			}
+			if math.Abs(analysis.Required-tt.required) > 1e-9 || analysis.Recommended != tt.recommended {
+				t.Errorf("Expected %.2f required and %d recommended, got %.2f and %d",
+					tt.required, tt.recommended, analysis.Required, analysis.Recommended)
+			}
+			// 40 req/s at 200 ms keeps 8 of 10 workers busy, capping out at 50 req/s
+			if math.Abs(analysis.InFlight-8) > 1e-9 || math.Abs(analysis.Utilization-0.8) > 1e-9 || math.Abs(analysis.MaxRPS-50) > 1e-9 {
+				t.Errorf("Expected 8 in flight (80%%) and a 50 req/s maximum, got %+v", analysis)
+			}
+		})
+	}
+
+	if analysis := calculateConcurrencyAnalysis(10, 10, &BenchmarkResult{}); analysis != nil {
+		t.Errorf("Expected no analysis without successful requests, got %+v", analysis)
+	}
+}
+
+// TestTargetRPSWarning tests that a run paced to an unreachable rate is flagged
+func TestTargetRPSWarning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// One worker at 50 ms per request tops out near 20 req/s
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 5,
+		Concurrency:   1,
+		Timeout:       time.Second,
+		TargetRPS:     100,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	analysis := result.LittlesLaw
+	if analysis == nil || analysis.TargetRPS != 100 || analysis.Achievable {
+		t.Fatalf("Expected an unreachable 100 req/s target, got %+v", analysis)
+	}
+	if analysis.Recommended < 5 || !strings.Contains(analysis.Warning, "set concurrency to at least") {
+		t.Errorf("Expected a recommendation of at least 5 workers, got %d (%q)", analysis.Recommended, analysis.Warning)
+	}
+	if section := concurrencySection([]*BenchmarkResult{result}); !strings.Contains(section, "**Warning:**") {
+		t.Errorf("Expected the warning in the report, got %q", section)
+	}
+}
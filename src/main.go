@@ -4,8 +4,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -38,18 +41,111 @@ func main() {
 		warmup          = flag.Int("warmup", 1, "Number of warmup iterations")
 		timeout         = flag.Duration("timeout", 30*time.Second, "Request timeout")
 		keepalive       = flag.Bool("keepalive", true, "Enable HTTP keep-alive")
+		guardrail       = flag.String("guardrail", GuardrailThrottle, "When the load generator saturates its own CPU: throttle (halve concurrency), abort, or off")
+		autoMemLimit    = flag.Bool("auto-memlimit", false, "In a container without GOMEMLIMIT, set the Go memory limit to 90% of the container's")
+		prime           = flag.Bool("prime", true, "Resolve DNS and open pooled connections before each measured iteration; disable to measure cold starts")
 		outputDir       = flag.String("output", "./benchmarks/results", "Output directory for results")
 		rawMetrics      = flag.Bool("raw", false, "Include raw metrics in output")
-		compareBaseline = flag.String("compare", "", "Path to baseline results for comparison")
+		compareBaseline = flag.String("compare", "", "Path to baseline results for comparison (apilo suite JSON, k6 summary, vegeta report or wrk2 output)")
+		orchestrate     = flag.String("orchestrate", "", "Path to an orchestration YAML running several suites in dependency order")
+		resume          = flag.String("resume", "", "Resume an interrupted suite from its checkpoint.json")
+		flushInterval   = flag.Duration("flush-interval", DefaultFlushInterval, "How often interim results are printed and checkpointed")
+		restart         = flag.Bool("restart", false, "Start fresh instead of resuming an interrupted attempt of the same suite")
+		ciSummary       = flag.Bool("ci-summary", false, "Write a Markdown and OpenMetrics summary for CI, appended to $GITHUB_STEP_SUMMARY when set")
+		dryRun          = flag.Bool("dry-run", false, "Validate the configuration and print the planned requests, duration and token cost without sending traffic")
+		assumeLatency   = flag.Duration("assume-latency", defaultAssumedLatency, "Request latency -dry-run assumes when estimating the duration of unpaced runs")
+		tokenPrice      = flag.String("token-price", "", "Dollars per million prompt and output tokens for -dry-run and -max-spend, as INPUT,OUTPUT, e.g. 3,15")
+		maxSpend        = flag.Float64("max-spend", 0, "Dollar budget for a run against a paid LLM API, priced with -token-price; requests that could exceed it are not sent (0 = no limit)")
+		spendAction     = flag.String("spend-action", SpendAbort, "When -max-spend would be exceeded: abort, or mock to send the remaining requests to -mock-url")
+		mockURL         = flag.String("mock-url", "", "Mock of the API that -spend-action mock sends requests to, e.g. http://localhost:8080")
+		budget          = flag.String("budget", "", "Comma-separated performance budgets for the CI summary, e.g. p95=250ms,error_rate=1%,rps=50")
+		targets         = flag.String("targets", "", "Comma-separated candidate URLs to A/B test against -url with the same workload")
+		connExperiment  = flag.Bool("connection-experiment", false, "Compare keep-alive on/off and idle pool settings, and recommend one")
+		coldPath        = flag.Bool("cold-path", false, "Compare the optimized path with a new connection, uncached DNS lookup and full TLS handshake per request")
+		unixSocket      = flag.String("unix-socket", "", "Connect to this Unix domain socket instead of the -url host (or use -url unix://SOCKET:/PATH)")
+		h2c             = flag.String("h2c", "", "Cleartext HTTP/2 for http:// targets: prior_knowledge or upgrade")
+		forceProtocol   = flag.String("force-protocol", "", "Speak only this protocol instead of negotiating: h1, h2, h2c or h3")
+		maxConnAge      = flag.Duration("max-conn-age", 0, "Reconnect once a connection is this old, e.g. to rebalance across load-balanced backends (0 = never)")
+		noFollow        = flag.Bool("no-follow", false, "Do not follow redirects; the first 3xx response ends each request")
+		maxRedirects    = flag.Int("max-redirects", 0, "Redirect hops followed before the last 3xx response ends the request (0 = 10, then fail)")
+		failOnRedirect  = flag.Bool("fail-on-redirect", false, "Count a request ending on a 3xx response, with -no-follow or -max-redirects, as failed")
+		honorRetryAfter = flag.Bool("honor-retry-after", false, "On 429s, pause every worker for Retry-After and retry, reporting throttle time and the effective RPS the rate limits allow")
+		throttleRetries = flag.Int("max-throttle-retries", 0, "Retries of a throttled request before it counts as failed, with -honor-retry-after (0 = 5)")
+		throttleMaxWait = flag.Duration("max-throttle-wait", 0, "Longest pause for one Retry-After, with -honor-retry-after (0 = 60s)")
+		seed            = flag.Int64("seed", 0, "Seed for all randomness: prompt sampling, data file shuffling and script Math.random; each iteration derives its own (0 = random)")
+		dataFile        = flag.String("data", "", "CSV or JSONL file whose rows fill {{column}} placeholders in -url, headers and the body, distinct rows per worker")
+		script          = flag.String("script", "", "JavaScript file defining request(ctx) to compute each request's URL, headers, body and cache key")
+		plugins         = flag.String("plugin", "", "Comma-separated Go plugins (.so) supplying custom response checks and metrics")
+		captureHeaders  = flag.String("capture-headers", "", "Comma-separated response headers to record per request, e.g. X-Request-Id,CF-Cache-Status")
+		hostHeader      = flag.String("host-header", "", "Send this Host header instead of the -url host, e.g. to reach an origin behind a CDN directly; also used as the SNI")
+		sni             = flag.String("sni", "", "Send this TLS server name (SNI) and verify the certificate against it")
+		allowMismatch   = flag.Bool("allow-host-mismatch", false, "Allow -host-header and -sni to name different hosts")
+		maxConnRequests = flag.Int("max-conn-requests", 0, "Reconnect once a connection has served this many requests (0 = unlimited)")
+		soakDuration    = flag.Duration("duration", 0, "Soak mode: hold -rps of constant load this long, e.g. 24h, and check for leaks and latency drift")
+		soakRPS         = flag.Float64("rps", 10, "Requests per second in soak mode")
+		soakWindow      = flag.Duration("soak-window", time.Minute, "How often soak mode samples latency and resource use")
+		soakTargetPID   = flag.Int("target-pid", 0, "Soak mode: also sample the memory, threads and file descriptors of this local target process")
+		targetRPS       = flag.Float64("target-rps", 0, "Request rate to hold; warns when -concurrency cannot reach it at the measured latency (0 = as fast as possible)")
+		findMaxRPS      = flag.Bool("find-max-rps", false, "Search for the highest request rate the target sustains within -slo")
+		slo             = flag.String("slo", "p95=500ms,error_rate=1%", "Comma-separated budgets every -find-max-rps step must meet")
+		startRPS        = flag.Float64("start-rps", 10, "First rate tried by -find-max-rps")
+		maxRPS          = flag.Float64("max-rps", 0, "Highest rate tried by -find-max-rps (0 = no limit)")
+		stepDuration    = flag.Duration("step-duration", 10*time.Second, "How long -find-max-rps holds each rate")
+		eventSink       = flag.String("event-sink", "", "Stream per-request and per-iteration events to nats://host:4222/subject or kafka+http://rest-proxy:8082/topic")
+		webhook         = flag.String("webhook", "", "Comma-separated URLs to POST a JSON payload to when a run completes or breaches -budget")
+		webhookOn       = flag.String("webhook-on", "", "Comma-separated webhook events: run_complete, slo_breach (default: both)")
+		webhookTemplate = flag.String("webhook-template", "", "Go template file rendering the webhook body, e.g. a chat message; must produce JSON")
+		webhookSecret   = flag.String("webhook-secret", "", "Sign webhook bodies with HMAC-SHA256 in the X-Apilo-Signature header")
+		resultURL       = flag.String("result-url", "", "Base URL the output directory is published at, for result links in webhook payloads")
 		quiet           = flag.Bool("quiet", false, "Suppress progress output")
 		showVersion     = flag.Bool("version", false, "Show version and exit")
 
+		// LLM workload flags
+		corpus       = flag.String("corpus", "", "JSONL prompt corpus to generate LLM request bodies from")
+		model        = flag.String("model", "", "Model to request when using -corpus")
+		apiFormat    = flag.String("api-format", WorkloadFormatAnthropic, "Request body format for -corpus: anthropic or openai")
+		promptDist   = flag.String("prompt-dist", LengthDistributionCorpus, "Prompt length distribution: corpus, uniform or normal")
+		promptTokens = flag.String("prompt-tokens", "", "Prompt length bounds in tokens, as MIN-MAX")
+		maxTokens    = flag.String("max-tokens", "", "max_tokens per request, as N or MIN-MAX")
+
+		// TLS trust and mutual TLS flags
+		clientCert     = flag.String("cert", "", "PEM client certificate for mutual TLS")
+		clientKey      = flag.String("key", "", "PEM private key for -cert")
+		caBundle       = flag.String("cacert", "", "Comma-separated PEM CA bundles trusted in addition to the system roots")
+		pins           = flag.String("pin", "", "Comma-separated SPKI pins as HOST=sha256/BASE64; HOST may be *.domain or *")
+		insecure       = flag.Bool("insecure", false, "DANGEROUS: skip TLS certificate verification (pins still apply)")
+		pkcs12File     = flag.String("pkcs12", "", "PKCS#12 client certificate bundle, instead of -cert and -key")
+		pkcs12Password = flag.String("pkcs12-password", "", "Password for -pkcs12; may reference an environment variable as $NAME")
+
+		// Cache microbenchmark flags
+		benchCache          = flag.Bool("bench-cache", false, "Run microbenchmarks of the cache implementations instead of a network benchmark")
+		benchCacheEntries   = flag.Int("bench-cache-entries", 10000, "Distinct keys in the cache microbenchmarks")
+		benchCacheValueSize = flag.Int("bench-cache-value-size", 1024, "Bytes per cached value in the cache microbenchmarks")
+		benchCacheOps       = flag.Int("bench-cache-ops", 200000, "Operations per cache throughput measurement")
+
+		// Self-test flags
+		selfTest               = flag.Bool("selftest", false, "Compare a plain client with the optimized client against an embedded mock server, exiting 1 unless overhead and improvement are within bands")
+		selfTestMaxOverhead    = flag.Duration("selftest-max-overhead", DefaultSelfTestConfig().MaxOverhead, "Most the optimized client may add to a cache miss at P50")
+		selfTestMinImprovement = flag.Float64("selftest-min-improvement", DefaultSelfTestConfig().MinImprovement*100, "Least P50 latency improvement over the plain client, in percent")
+
 		// Monitoring flags
 		enableMonitoring = flag.Bool("monitor", false, "Enable real-time monitoring dashboard")
 		dashboardPort    = flag.Int("dashboard-port", 8080, "Dashboard HTTP port")
+		dashboardAssets  = flag.String("dashboard-assets", "", "Directory whose files replace the dashboard's index.html, dashboard.css or dashboard.js, and are served under /assets/")
+		dashboardTheme   = flag.String("dashboard-theme", DashboardThemeAuto, "Dashboard theme: auto, light or dark")
+		dashboardTitle   = flag.String("dashboard-title", DefaultDashboardTitle, "Title shown in the dashboard header")
+		dashboardLogo    = flag.String("dashboard-logo", "", "Image URL shown before the dashboard title, e.g. /assets/logo.svg from -dashboard-assets")
+		dashboardAccent  = flag.String("dashboard-accent", "", "Hex accent color of the dashboard, e.g. #0f766e")
 		prometheusPort   = flag.Int("prometheus-port", 9090, "Prometheus exporter port")
+		listenHost       = flag.String("listen-host", "", "Interface the dashboard and Prometheus exporter bind, e.g. 127.0.0.1 (default all interfaces); systemd-activated sockets named dashboard or prometheus are used instead when passed")
+		reusePort        = flag.Bool("reuse-port", false, "Bind the dashboard and Prometheus exporter with SO_REUSEPORT so several processes can share their ports")
 		enableAlerts     = flag.Bool("alerts", false, "Enable performance alerting")
 		monitoringConfig = flag.String("monitoring-config", "", "Path to monitoring configuration file")
+		retention        = flag.Duration("retention", 24*time.Hour, "How long monitoring snapshots are kept")
+		maxSnapshots     = flag.Int("max-snapshots", 1000, "Most monitoring snapshots kept")
+		downsampleAfter  = flag.Duration("downsample-after", time.Hour, "Age after which snapshots are downsampled (0 = never)")
+		historyDir       = flag.String("history-dir", "", "Directory persisting monitoring snapshots for the dashboard's history mode")
+		historyRetention = flag.Duration("history-retention", 30*24*time.Hour, "How long snapshots are kept in -history-dir (0 = forever)")
 	)
 
 	flag.Parse()
@@ -69,6 +165,15 @@ func main() {
 		fmt.Println()
 	}
 
+	if *benchCache {
+		config := DefaultCacheBenchConfig()
+		config.Entries = *benchCacheEntries
+		config.ValueSize = *benchCacheValueSize
+		config.Operations = *benchCacheOps
+		printCacheBench(config, RunCacheBench(config))
+		return
+	}
+
 	// Set up context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -82,12 +187,37 @@ func main() {
 		cancel()
 	}()
 
+	if *selfTest {
+		config := DefaultSelfTestConfig()
+		config.MaxOverhead = *selfTestMaxOverhead
+		config.MinImprovement = *selfTestMinImprovement / 100
+		result, err := RunSelfTest(ctx, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Self-test failed: %v\n", err)
+			os.Exit(1)
+		}
+		printSelfTest(config, result)
+		if !result.Passed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	var err error
 
 	// Initialize monitoring if enabled
 	var monitoringSystem *MonitoringSystem
-	if *enableMonitoring {
-		monitoringSystem, err = initializeMonitoring(*monitoringConfig, *dashboardPort, *prometheusPort, *enableAlerts, *quiet)
+	if *enableMonitoring && !*dryRun {
+		dashboard := DashboardOptions{
+			AssetsDir: *dashboardAssets,
+			Theme:     *dashboardTheme,
+			Title:     *dashboardTitle,
+			Logo:      *dashboardLogo,
+			Accent:    *dashboardAccent,
+		}
+		monitoringSystem, err = initializeMonitoring(ctx, *monitoringConfig, *dashboardPort, *prometheusPort, *enableAlerts, *quiet,
+			RetentionPolicy{MaxSnapshots: *maxSnapshots, MaxAge: *retention, DownsampleAfter: *downsampleAfter}, dashboard, *historyDir, *historyRetention,
+			ListenOptions{Host: *listenHost, ReusePort: *reusePort})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to initialize monitoring: %v\n", err)
 			os.Exit(1)
@@ -95,8 +225,114 @@ func main() {
 		defer monitoringSystem.Stop()
 	}
 
+	// Build the LLM workload, if any
+	var workload *WorkloadConfig
+	if *corpus != "" {
+		workload, err = buildWorkloadConfig(*corpus, *model, *apiFormat, *promptDist, *promptTokens, *maxTokens)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid workload: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var tlsConfig *ClientTLSConfig
+	if *clientCert != "" || *clientKey != "" || *caBundle != "" || *pkcs12File != "" || *pins != "" || *insecure {
+		tlsConfig = &ClientTLSConfig{
+			ClientCertConfig: ClientCertConfig{
+				CertFile:       *clientCert,
+				KeyFile:        *clientKey,
+				PKCS12File:     *pkcs12File,
+				PKCS12Password: *pkcs12Password,
+			},
+			CAFiles:            splitList(*caBundle),
+			InsecureSkipVerify: *insecure,
+		}
+		if tlsConfig.Pins, err = parsePins(*pins); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -pin: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var scriptConfig *RequestScriptConfig
+	if *script != "" {
+		scriptConfig = &RequestScriptConfig{File: *script}
+	}
+	var redirects *RedirectPolicy
+	if *noFollow || *maxRedirects != 0 || *failOnRedirect {
+		redirects = &RedirectPolicy{NoFollow: *noFollow, MaxRedirects: *maxRedirects}
+		if *failOnRedirect {
+			redirects.Final = RedirectFailure
+		}
+	}
+	var throttle *ThrottlePolicy
+	if *honorRetryAfter {
+		throttle = &ThrottlePolicy{MaxRetries: *throttleRetries, MaxWait: *throttleMaxWait}
+	}
+	var dataConfig *DataFeedConfig
+	if *dataFile != "" {
+		dataConfig = &DataFeedConfig{File: *dataFile}
+	}
+
+	var hostOverride *HostOverride
+	if *hostHeader != "" || *sni != "" {
+		hostOverride = &HostOverride{Host: *hostHeader, SNI: *sni, AllowMismatch: *allowMismatch}
+	}
+
+	budgets, err := ParseBudgets(*budget)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -budget: %v\n", err)
+		os.Exit(1)
+	}
+	var ceiling *RPSCeilingConfig
+	if *findMaxRPS {
+		ceiling = &RPSCeilingConfig{StartRPS: *startRPS, MaxRPS: *maxRPS, StepDuration: *stepDuration}
+		if ceiling.SLO, err = ParseBudgets(*slo); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -slo: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	webhooks, err := ParseWebhooks(*webhook, *webhookOn, *webhookTemplate, *webhookSecret, *resultURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -webhook: %v\n", err)
+		os.Exit(1)
+	}
+	guardrails, err := ParseGuardrails(*guardrail)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -guardrail: %v\n", err)
+		os.Exit(1)
+	}
+	if *autoMemLimit {
+		if limit := ApplyContainerMemoryLimit(); limit > 0 && !*quiet {
+			fmt.Printf("Go memory limit set to %s (90%% of the container's)\n", formatBytes(limit))
+		}
+	}
+
+	inputPrice, outputPrice, err := parseTokenPrice(*tokenPrice)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -token-price: %v\n", err)
+		os.Exit(1)
+	}
+	var plan *PlanOptions
+	if *dryRun {
+		plan = &PlanOptions{AssumedLatency: *assumeLatency, InputPrice: inputPrice, OutputPrice: outputPrice}
+	}
+	var spendGuard *SpendGuardConfig
+	if *maxSpend > 0 {
+		spendGuard = &SpendGuardConfig{MaxSpend: *maxSpend, InputPrice: inputPrice, OutputPrice: outputPrice, Action: *spendAction, MockURL: *mockURL}
+		if err := spendGuard.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -max-spend: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Run benchmark based on configuration
-	if *configFile != "" {
+	if plan != nil && (*orchestrate != "" || *configFile != "" || *resume != "") {
+		err = planFromConfig(*orchestrate, *configFile, *resume, *plan)
+	} else if *orchestrate != "" {
+		err = runOrchestration(ctx, *orchestrate)
+	} else if *resume != "" {
+		err = resumeBenchmark(ctx, *resume, *quiet)
+	} else if *configFile != "" {
 		err = runFromConfig(ctx, *configFile, *compareBaseline, *quiet, monitoringSystem)
 	} else {
 		err = runQuickBenchmark(ctx, quickBenchmarkParams{
@@ -107,19 +343,53 @@ func main() {
 			warmup:          *warmup,
 			timeout:         *timeout,
 			keepalive:       *keepalive,
+			prime:           *prime,
+			guardrails:      guardrails,
 			outputDir:       *outputDir,
 			includeRaw:      *rawMetrics,
 			compareBaseline: *compareBaseline,
+			targets:         *targets,
+			connExperiment:  *connExperiment,
+			coldPath:        *coldPath,
+			flushInterval:   *flushInterval,
+			restart:         *restart,
+			ciSummary:       *ciSummary,
+			budgets:         budgets,
+			workload:        workload,
+			tls:             tlsConfig,
+			unixSocket:      *unixSocket,
+			h2c:             *h2c,
+			forceProtocol:   *forceProtocol,
+			hostOverride:    hostOverride,
+			captureHeaders:  splitList(*captureHeaders),
+			plugins:         splitList(*plugins),
+			script:          scriptConfig,
+			data:            dataConfig,
+			redirects:       redirects,
+			throttle:        throttle,
+			seed:            *seed,
+			rotation:        &ConnectionRotation{MaxAge: *maxConnAge, MaxRequests: *maxConnRequests},
+			soak:            &SoakConfig{Duration: *soakDuration, RPS: *soakRPS, Window: *soakWindow, TargetPID: *soakTargetPID},
+			ceiling:         ceiling,
+			targetRPS:       *targetRPS,
+			eventSink:       *eventSink,
+			webhooks:        webhooks,
 			quiet:           *quiet,
+			plan:            plan,
+			spendGuard:      spendGuard,
 		}, monitoringSystem)
 	}
 
 	if err != nil {
+		// os.Exit skips deferred calls, so shut monitoring down first
+		if monitoringSystem != nil {
+			monitoringSystem.Stop()
+		}
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 
-	if !*quiet {
+	if !*quiet && plan == nil {
 		fmt.Println("\n✓ Benchmark completed successfully")
 	}
 }
@@ -133,21 +403,58 @@ type quickBenchmarkParams struct {
 	warmup          int
 	timeout         time.Duration
 	keepalive       bool
+	prime           bool
+	guardrails      *GuardrailConfig
 	outputDir       string
 	includeRaw      bool
 	compareBaseline string
+	targets         string
+	connExperiment  bool
+	coldPath        bool
+	flushInterval   time.Duration
+	restart         bool
+	ciSummary       bool
+	budgets         []PerformanceBudget
+	workload        *WorkloadConfig
+	tls             *ClientTLSConfig
+	unixSocket      string
+	h2c             string
+	forceProtocol   string
+	hostOverride    *HostOverride
+	captureHeaders  []string
+	plugins         []string
+	script          *RequestScriptConfig
+	data            *DataFeedConfig
+	redirects       *RedirectPolicy
+	throttle        *ThrottlePolicy
+	seed            int64
+	rotation        *ConnectionRotation
+	soak            *SoakConfig
+	ceiling         *RPSCeilingConfig
+	targetRPS       float64
+	eventSink       string
+	webhooks        []WebhookConfig
 	quiet           bool
+	plan            *PlanOptions // Set for a dry run
+	spendGuard      *SpendGuardConfig
 }
 
 // initializeMonitoring sets up and starts the monitoring system
-func initializeMonitoring(configPath string, dashboardPort, prometheusPort int, enableAlerts, quiet bool) (*MonitoringSystem, error) {
+func initializeMonitoring(ctx context.Context, configPath string, dashboardPort, prometheusPort int, enableAlerts, quiet bool, retention RetentionPolicy, dashboard DashboardOptions, historyDir string, historyRetention time.Duration, listen ListenOptions) (*MonitoringSystem, error) {
 	// Create monitoring configuration
 	config := DefaultMonitoringConfig()
 
 	// Override with CLI flags
 	config.DashboardPort = dashboardPort
+	config.Dashboard = dashboard
+	config.HistoryDir = historyDir
+	config.HistoryRetention = historyRetention
 	config.PrometheusPort = prometheusPort
+	config.Listen = listen
 	config.AlertingEnabled = enableAlerts
+	config.RetentionPeriod = retention.MaxAge
+	config.MaxSnapshots = retention.MaxSnapshots
+	config.DownsampleAfter = retention.DownsampleAfter
 
 	// Load from config file if provided
 	if configPath != "" {
@@ -159,15 +466,19 @@ func initializeMonitoring(configPath string, dashboardPort, prometheusPort int,
 
 	// Create and start monitoring system
 	monitoring := NewMonitoringSystem(config)
-	if err := monitoring.Start(); err != nil {
+	if err := monitoring.StartContext(ctx); err != nil {
 		return nil, fmt.Errorf("failed to start monitoring: %w", err)
 	}
 
 	if !quiet {
 		fmt.Println("\n✓ Monitoring system started successfully")
-		fmt.Printf("  Dashboard: http://localhost:%d\n", config.DashboardPort)
+		host := "localhost"
+		if listen.Host != "" {
+			host = listen.Host
+		}
+		fmt.Printf("  Dashboard: http://%s\n", net.JoinHostPort(host, strconv.Itoa(config.DashboardPort)))
 		if config.PrometheusEnabled {
-			fmt.Printf("  Prometheus: http://localhost:%d%s\n", config.PrometheusPort, config.PrometheusPath)
+			fmt.Printf("  Prometheus: http://%s%s\n", net.JoinHostPort(host, strconv.Itoa(config.PrometheusPort)), config.PrometheusPath)
 		}
 		fmt.Println()
 	}
@@ -177,28 +488,57 @@ func initializeMonitoring(configPath string, dashboardPort, prometheusPort int,
 
 // runQuickBenchmark runs a simple benchmark without a config file
 func runQuickBenchmark(ctx context.Context, params quickBenchmarkParams, monitoring *MonitoringSystem) error {
-	if !params.quiet {
+	soak := params.soak != nil && params.soak.Duration > 0
+	if !params.quiet && !soak && params.ceiling == nil && params.plan == nil {
 		fmt.Printf("Running benchmark against: %s\n", params.url)
-		fmt.Printf("Configuration: %d requests, %d concurrent, %d iterations\n\n",
+		fmt.Printf("Configuration: %d requests, %d concurrent, %d iterations\n",
 			params.requests, params.concurrency, params.iterations)
+		if params.targetRPS > 0 {
+			fmt.Printf("Target rate: %.2f req/s\n", params.targetRPS)
+		}
+		fmt.Println()
 	}
 
 	// Create benchmark suite
 	suite := &BenchmarkSuite{
-		Name:        "quick_benchmark",
-		Description: fmt.Sprintf("Quick benchmark of %s", params.url),
-		OutputDir:   params.outputDir,
+		Name:          "quick_benchmark",
+		Description:   fmt.Sprintf("Quick benchmark of %s", params.url),
+		OutputDir:     params.outputDir,
+		FlushInterval: params.flushInterval,
+		Restart:       params.restart,
+		CISummary:     params.ciSummary,
+		Budgets:       params.budgets,
+		EventSink:     params.eventSink,
+		Webhooks:      params.webhooks,
 		Runs: []BenchmarkRun{
 			{
 				Name: "benchmark",
 				Config: BenchmarkConfig{
-					TargetURL:         params.url,
-					TotalRequests:     params.requests,
-					Concurrency:       params.concurrency,
-					Timeout:           params.timeout,
-					KeepAlive:         params.keepalive,
-					Method:            "GET",
-					IncludeRawMetrics: params.includeRaw,
+					TargetURL:          params.url,
+					TotalRequests:      params.requests,
+					Concurrency:        params.concurrency,
+					Timeout:            params.timeout,
+					KeepAlive:          params.keepalive,
+					PrimeConnections:   params.prime,
+					Guardrails:         params.guardrails,
+					IncludeRawMetrics:  params.includeRaw,
+					Workload:           params.workload,
+					CustomHeaders:      workloadHeaders(params.workload),
+					TLS:                params.tls,
+					UnixSocket:         params.unixSocket,
+					H2C:                params.h2c,
+					ForceProtocol:      params.forceProtocol,
+					HostOverride:       params.hostOverride,
+					CaptureHeaders:     params.captureHeaders,
+					Plugins:            params.plugins,
+					Script:             params.script,
+					Data:               params.data,
+					Redirects:          params.redirects,
+					Throttle:           params.throttle,
+					SpendGuard:         params.spendGuard,
+					Seed:               params.seed,
+					ConnectionRotation: params.rotation,
+					TargetRPS:          params.targetRPS,
 				},
 				Iterations:       params.iterations,
 				WarmupIterations: params.warmup,
@@ -207,6 +547,58 @@ func runQuickBenchmark(ctx context.Context, params quickBenchmarkParams, monitor
 		},
 	}
 
+	if (soak || params.ceiling != nil) && (params.eventSink != "" || len(params.webhooks) > 0) {
+		return fmt.Errorf("-event-sink and -webhook cannot be combined with -duration or -find-max-rps")
+	}
+	if soak {
+		if params.targets != "" || params.connExperiment || params.coldPath {
+			return fmt.Errorf("-duration cannot be combined with -targets, -connection-experiment or -cold-path")
+		}
+		return runSoak(ctx, suite.Runs[0].Config, params)
+	}
+	if params.ceiling != nil {
+		if soak || params.targets != "" || params.connExperiment || params.coldPath {
+			return fmt.Errorf("-find-max-rps cannot be combined with -duration, -targets, -connection-experiment or -cold-path")
+		}
+		return runRPSCeiling(ctx, suite.Runs[0].Config, params)
+	}
+
+	// A/B mode runs the baseline URL and every candidate interleaved
+	if params.targets != "" {
+		run := &suite.Runs[0]
+		run.Targets = []string{normalizeURL(params.url)}
+		for _, target := range strings.Split(params.targets, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				run.Targets = append(run.Targets, normalizeURL(target))
+			}
+		}
+	}
+
+	// The connection experiment runs the workload under each keep-alive setting
+	if params.connExperiment {
+		if params.targets != "" {
+			return fmt.Errorf("-connection-experiment cannot be combined with -targets")
+		}
+		suite.Runs[0].ConnectionVariants = DefaultConnectionVariants(params.concurrency)
+	}
+
+	// Cold path mode runs the workload warm and fully cold, interleaved
+	if params.coldPath {
+		if params.targets != "" || params.connExperiment {
+			return fmt.Errorf("-cold-path cannot be combined with -targets or -connection-experiment")
+		}
+		suite.Runs[0].ColdPath = true
+	}
+
+	if params.plan != nil {
+		plan, err := PlanSuite(suite, *params.plan)
+		if err != nil {
+			return err
+		}
+		printPlan([]*SuitePlan{plan}, *params.plan)
+		return nil
+	}
+
 	// Run benchmark
 	runner := NewBenchmarkRunner(suite)
 
@@ -254,6 +646,274 @@ func runQuickBenchmark(ctx context.Context, params quickBenchmarkParams, monitor
 	return nil
 }
 
+// runSoak runs a soak test of the quick benchmark's target and saves its samples
+func runSoak(ctx context.Context, benchmark BenchmarkConfig, params quickBenchmarkParams) error {
+	config := *params.soak
+	config.Benchmark = benchmark
+	config.Benchmark.TargetURL = normalizeURL(benchmark.TargetURL)
+	config.Thresholds = DefaultSoakConfig().Thresholds
+	if params.plan != nil {
+		run, err := planSoak(config)
+		if err != nil {
+			return err
+		}
+		plan := &SuitePlan{Name: "soak"}
+		plan.add(run)
+		printPlan([]*SuitePlan{plan}, *params.plan)
+		return nil
+	}
+
+	if !params.quiet {
+		fmt.Printf("Soak testing %s at %.1f req/s for %v, sampling every %v\n\n",
+			config.Benchmark.TargetURL, config.RPS, config.Duration, config.Window)
+	}
+	progress := func(s SoakSample) {
+		if !params.quiet {
+			fmt.Printf("[%v] %d requests, %d errors, P95 %.2f ms, heap %s, %d goroutines\n",
+				s.At.Round(time.Second), s.Requests, s.Errors, s.LatencyP95, formatBytes(s.HeapBytes), s.Goroutines)
+		}
+	}
+
+	result, err := RunSoak(ctx, config, progress)
+	if err != nil {
+		return err
+	}
+	printSoakResult(result)
+
+	path, err := SaveSoakResult(params.outputDir, result)
+	if err != nil {
+		return fmt.Errorf("failed to save soak result: %w", err)
+	}
+	if !params.quiet {
+		fmt.Printf("\nSoak result saved to: %s (samples in .csv)\n", path)
+	}
+	if !result.Stable {
+		return fmt.Errorf("soak test found the target or optimizer unstable")
+	}
+	return nil
+}
+
+// runRPSCeiling searches for the quick benchmark target's throughput ceiling
+// and saves the evidence
+func runRPSCeiling(ctx context.Context, benchmark BenchmarkConfig, params quickBenchmarkParams) error {
+	config := *params.ceiling
+	config.Benchmark = benchmark
+	config.Benchmark.TargetURL = normalizeURL(benchmark.TargetURL)
+	if params.plan != nil {
+		run, err := planRPSCeiling(config)
+		if err != nil {
+			return err
+		}
+		plan := &SuitePlan{Name: "rps_ceiling"}
+		plan.add(run)
+		printPlan([]*SuitePlan{plan}, *params.plan)
+		return nil
+	}
+
+	if !params.quiet {
+		fmt.Printf("Searching for the throughput ceiling of %s from %.1f req/s, %v per step\n\n",
+			config.Benchmark.TargetURL, config.StartRPS, config.StepDuration)
+	}
+	progress := func(step RPSStep) {
+		if !params.quiet {
+			verdict := "ok"
+			if !step.Passed {
+				verdict = step.Reason
+			}
+			fmt.Printf("%.2f req/s: achieved %.2f, P95 %.2f ms, %d errors: %s\n",
+				step.TargetRPS, step.AchievedRPS, step.Latency.P95, step.Errors, verdict)
+		}
+	}
+
+	result, err := FindRPSCeiling(ctx, config, progress)
+	if err != nil {
+		return err
+	}
+	printRPSCeiling(result)
+
+	path, err := SaveRPSCeiling(params.outputDir, result)
+	if err != nil {
+		return fmt.Errorf("failed to save ceiling result: %w", err)
+	}
+	if !params.quiet {
+		fmt.Printf("\nCeiling search saved to: %s\n", path)
+	}
+	return nil
+}
+
+// buildWorkloadConfig turns the workload flags into a WorkloadConfig
+func buildWorkloadConfig(corpus, model, format, distribution, promptTokens, maxTokens string) (*WorkloadConfig, error) {
+	config := DefaultWorkloadConfig(corpus)
+	config.Format = format
+	config.LengthDistribution = distribution
+	if model != "" {
+		config.Model = model
+	}
+
+	var err error
+	if promptTokens != "" {
+		if config.MinPromptTokens, config.MaxPromptTokens, err = parseIntRange(promptTokens); err != nil {
+			return nil, fmt.Errorf("invalid -prompt-tokens: %w", err)
+		}
+	}
+	if maxTokens != "" {
+		if config.MinMaxTokens, config.MaxMaxTokens, err = parseIntRange(maxTokens); err != nil {
+			return nil, fmt.Errorf("invalid -max-tokens: %w", err)
+		}
+	}
+
+	// Fail before any request is sent
+	if _, err := NewWorkloadGenerator(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parsePins parses -pin entries into ClientTLSConfig.Pins
+func parsePins(value string) (map[string][]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	pins := make(map[string][]string)
+	for _, entry := range splitList(value) {
+		host, pin, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(host) == "" || strings.TrimSpace(pin) == "" {
+			return nil, fmt.Errorf("%q is not HOST=PIN", entry)
+		}
+		host = strings.TrimSpace(host)
+		pins[host] = append(pins[host], strings.TrimSpace(pin))
+	}
+	return pins, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseIntRange parses "N" or "MIN-MAX"
+func parseIntRange(value string) (int, int, error) {
+	first, second, isRange := strings.Cut(value, "-")
+	lo, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(second))
+	if err != nil {
+		return 0, 0, err
+	}
+	return lo, hi, nil
+}
+
+// workloadHeaders returns the auth and version headers the workload's API
+// expects, taking keys from the usual environment variables
+func workloadHeaders(workload *WorkloadConfig) map[string]string {
+	if workload == nil {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	switch workload.Format {
+	case WorkloadFormatOpenAI:
+		if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+			headers["Authorization"] = "Bearer " + key
+		}
+	default:
+		headers["anthropic-version"] = "2023-06-01"
+		if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
+			headers["x-api-key"] = key
+		}
+	}
+	return headers
+}
+
+// resumeBenchmark continues a suite from the checkpoint of an interrupted run
+func resumeBenchmark(ctx context.Context, checkpointPath string, quiet bool) error {
+	runner, err := ResumeBenchmarkRunner(checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Printf("Resuming benchmark from checkpoint: %s\n", checkpointPath)
+	}
+	return runner.Run(ctx)
+}
+
+// runOrchestration runs the suites of an orchestration file, failing unless
+// every suite passed
+func runOrchestration(ctx context.Context, path string) error {
+	orchestration, err := LoadOrchestration(path)
+	if err != nil {
+		return err
+	}
+	result, err := NewOrchestrator(orchestration).Run(ctx)
+	if err != nil {
+		return err
+	}
+	if !result.Passed {
+		return fmt.Errorf("orchestration %s failed", result.Name)
+	}
+	return nil
+}
+
+// planFromConfig prints the dry run plan of an orchestration or suite file
+func planFromConfig(orchestrationPath, configPath, resumePath string, options PlanOptions) error {
+	if resumePath != "" {
+		return fmt.Errorf("-dry-run cannot be combined with -resume")
+	}
+
+	var plans []*SuitePlan
+	if orchestrationPath != "" {
+		var err error
+		if plans, err = PlanOrchestration(orchestrationPath, options); err != nil {
+			return err
+		}
+	} else {
+		suite, err := LoadSuiteConfig(configPath)
+		if err != nil {
+			return err
+		}
+		plan, err := PlanSuite(suite, options)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+	printPlan(plans, options)
+	return nil
+}
+
+// parseTokenPrice parses -token-price as INPUT,OUTPUT dollars per million
+// tokens; empty means unpriced
+func parseTokenPrice(value string) (float64, float64, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	input, output, ok := strings.Cut(value, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected INPUT,OUTPUT, got %q", value)
+	}
+	in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+	if err != nil || in < 0 {
+		return 0, 0, fmt.Errorf("invalid input price %q", input)
+	}
+	out, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil || out < 0 {
+		return 0, 0, fmt.Errorf("invalid output price %q", output)
+	}
+	return in, out, nil
+}
+
 // runFromConfig runs benchmarks from a YAML configuration file
 func runFromConfig(ctx context.Context, configPath, baselinePath string, quiet bool, monitoring *MonitoringSystem) error {
 	if !quiet {
@@ -18,20 +18,19 @@ type MemoryBoundedCache struct {
 	items map[string]*CacheElement
 	lru   *list.List
 
-	// Memory management
+	// Memory management; counters are atomic so stats can read them without mu
 	maxMemoryBytes int64
-	currentMemory  int64
-	itemCount      int64
+	currentMemory  atomic.Int64
+	itemCount      atomic.Int64
 
 	// GC optimization
-	gcThreshold int64 // Memory threshold to trigger GC
-	gcRunning   int32 // Atomic flag for GC in progress
-	lastGCRun   time.Time
+	gcThreshold int64        // Memory threshold to trigger GC
+	gcRunning   int32        // Atomic flag for GC in progress
+	lastGCRun   atomic.Int64 // Unix nanoseconds of the last GC run
 	gcInterval  time.Duration
 
 	// Memory pressure management
-	memoryPressure float64 // 0.0 to 1.0, indicates memory pressure
-	evictionRate   float64 // Dynamic eviction rate based on pressure
+	memoryPressure float64 // 0.0 to 1.0, indicates memory pressure; guarded by mu
 
 	// Monitoring and metrics
 	metrics       *EnhancedCacheMetrics
@@ -66,32 +65,43 @@ type MemoryBoundedConfig struct {
 	PressureThreshold    float64       `yaml:"pressure_threshold"`
 }
 
-// EnhancedCacheMetrics tracks comprehensive cache metrics
+// EnhancedCacheMetrics tracks comprehensive cache metrics. Counters are
+// atomic; the remaining fields are guarded by mutex
 type EnhancedCacheMetrics struct {
 	// Memory metrics
-	currentMemoryBytes  int64
-	peakMemoryBytes     int64
-	memoryPressureValue float64
-	gcRunCount          int64
-	evictionCount       int64
+	currentMemoryBytes atomic.Int64
+	peakMemoryBytes    atomic.Int64
+	gcRunCount         atomic.Int64
+	evictionCount      atomic.Int64
 
 	// Performance metrics
-	hitCount    int64
-	missCount   int64
-	setCount    int64
-	deleteCount int64
+	hitCount    atomic.Int64
+	missCount   atomic.Int64
+	setCount    atomic.Int64
+	deleteCount atomic.Int64
 
 	// GC metrics
-	gcDuration       time.Duration
-	lastGCTime       time.Time
-	memoryFreedBytes int64
+	memoryFreedBytes atomic.Int64
 
 	// Access patterns
-	avgAccessCount   float64
-	hotKeyCount      int64
-	coldKeyEvictions int64
+	hotKeyCount      atomic.Int64
+	coldKeyEvictions atomic.Int64
 
-	mutex sync.RWMutex
+	mutex               sync.RWMutex
+	memoryPressureValue float64
+	gcDuration          time.Duration
+	lastGCTime          time.Time
+	avgAccessCount      float64
+}
+
+// recordPeakMemory raises the peak memory to bytes if it is a new high
+func (m *EnhancedCacheMetrics) recordPeakMemory(bytes int64) {
+	for {
+		peak := m.peakMemoryBytes.Load()
+		if bytes <= peak || m.peakMemoryBytes.CompareAndSwap(peak, bytes) {
+			return
+		}
+	}
 }
 
 // MemoryTracker provides advanced memory usage tracking
@@ -162,14 +172,14 @@ func (mbc *MemoryBoundedCache) Get(key string) (interface{}, bool) {
 
 	element, exists := mbc.items[key]
 	if !exists {
-		atomic.AddInt64(&mbc.metrics.missCount, 1)
+		mbc.metrics.missCount.Add(1)
 		return nil, false
 	}
 
 	// Check expiration
 	if time.Now().After(element.expiresAt) {
 		mbc.removeElementUnsafe(element)
-		atomic.AddInt64(&mbc.metrics.missCount, 1)
+		mbc.metrics.missCount.Add(1)
 		return nil, false
 	}
 
@@ -180,7 +190,7 @@ func (mbc *MemoryBoundedCache) Get(key string) (interface{}, bool) {
 	// Move to front (LRU)
 	mbc.lru.MoveToFront(element.listElement)
 
-	atomic.AddInt64(&mbc.metrics.hitCount, 1)
+	mbc.metrics.hitCount.Add(1)
 	return element.value, true
 }
 
@@ -223,9 +233,9 @@ func (mbc *MemoryBoundedCache) Set(key string, value interface{}, ttl time.Durat
 	mbc.items[key] = element
 
 	// Update memory tracking
-	atomic.AddInt64(&mbc.currentMemory, memorySize)
-	atomic.AddInt64(&mbc.itemCount, 1)
-	atomic.AddInt64(&mbc.metrics.setCount, 1)
+	mbc.currentMemory.Add(memorySize)
+	mbc.itemCount.Add(1)
+	mbc.metrics.setCount.Add(1)
 
 	// Update memory pressure
 	mbc.updateMemoryPressure()
@@ -235,7 +245,7 @@ func (mbc *MemoryBoundedCache) Set(key string, value interface{}, ttl time.Durat
 
 // ensureMemorySpaceUnsafe ensures sufficient memory space by evicting items
 func (mbc *MemoryBoundedCache) ensureMemorySpaceUnsafe(requiredMemory int64) {
-	neededMemory := mbc.currentMemory + requiredMemory - mbc.maxMemoryBytes
+	neededMemory := mbc.currentMemory.Load() + requiredMemory - mbc.maxMemoryBytes
 
 	if neededMemory <= 0 {
 		return
@@ -263,8 +273,8 @@ func (mbc *MemoryBoundedCache) ensureMemorySpaceUnsafe(requiredMemory int64) {
 		evicted++
 	}
 
-	atomic.AddInt64(&mbc.metrics.evictionCount, int64(evicted))
-	atomic.AddInt64(&mbc.metrics.memoryFreedBytes, freedMemory)
+	mbc.metrics.evictionCount.Add(int64(evicted))
+	mbc.metrics.memoryFreedBytes.Add(freedMemory)
 }
 
 // removeElementUnsafe removes an element from cache (must hold lock)
@@ -274,8 +284,8 @@ func (mbc *MemoryBoundedCache) removeElementUnsafe(element *CacheElement) {
 	}
 	delete(mbc.items, element.key)
 
-	atomic.AddInt64(&mbc.currentMemory, -element.memorySize)
-	atomic.AddInt64(&mbc.itemCount, -1)
+	mbc.currentMemory.Add(-element.memorySize)
+	mbc.itemCount.Add(-1)
 }
 
 // updateMemoryPressure calculates current memory pressure (0.0 to 1.0)
@@ -285,7 +295,7 @@ func (mbc *MemoryBoundedCache) updateMemoryPressure() {
 		return
 	}
 
-	pressure := float64(mbc.currentMemory) / float64(mbc.maxMemoryBytes)
+	pressure := float64(mbc.currentMemory.Load()) / float64(mbc.maxMemoryBytes)
 
 	// Apply exponential curve for pressure sensitivity
 	if pressure > 0.8 {
@@ -312,7 +322,7 @@ func (mbc *MemoryBoundedCache) memoryManagementLoop() {
 // performMemoryCheck performs periodic memory health checks
 func (mbc *MemoryBoundedCache) performMemoryCheck() {
 	mbc.mu.RLock()
-	currentMemory := mbc.currentMemory
+	currentMemory := mbc.currentMemory.Load()
 	pressure := mbc.memoryPressure
 	mbc.mu.RUnlock()
 
@@ -323,12 +333,10 @@ func (mbc *MemoryBoundedCache) performMemoryCheck() {
 	}
 
 	// Update peak memory tracking
-	if currentMemory > mbc.metrics.peakMemoryBytes {
-		atomic.StoreInt64(&mbc.metrics.peakMemoryBytes, currentMemory)
-	}
+	mbc.metrics.recordPeakMemory(currentMemory)
 
 	// Check for potential memory leaks
-	if pressure > 0.95 && time.Since(mbc.lastGCRun) > mbc.gcInterval {
+	if pressure > 0.95 && time.Since(time.Unix(0, mbc.lastGCRun.Load())) > mbc.gcInterval {
 		mbc.triggerGC()
 	}
 }
@@ -358,12 +366,12 @@ func (mbc *MemoryBoundedCache) performEmergencyCleanup() {
 			if element.accessCount < coldThreshold {
 				freedMemory += element.memorySize
 				mbc.removeElementUnsafe(element)
-				atomic.AddInt64(&mbc.metrics.coldKeyEvictions, 1)
+				mbc.metrics.coldKeyEvictions.Add(1)
 			}
 		}
 	}
 
-	atomic.AddInt64(&mbc.metrics.memoryFreedBytes, freedMemory)
+	mbc.metrics.memoryFreedBytes.Add(freedMemory)
 }
 
 // calculateColdThreshold calculates threshold for identifying cold keys
@@ -404,7 +412,7 @@ func (mbc *MemoryBoundedCache) shouldRunGC() bool {
 	}
 
 	// Run GC if memory usage exceeds threshold
-	return mbc.currentMemory > mbc.gcThreshold
+	return mbc.currentMemory.Load() > mbc.gcThreshold
 }
 
 // triggerGC triggers garbage collection with optimization
@@ -430,14 +438,14 @@ func (mbc *MemoryBoundedCache) triggerGC() {
 	freedBytes := int64(memBefore.Alloc - memAfter.Alloc)
 
 	// Update metrics
-	atomic.AddInt64(&mbc.metrics.gcRunCount, 1)
+	mbc.metrics.gcRunCount.Add(1)
 	mbc.metrics.mutex.Lock()
 	mbc.metrics.gcDuration = duration
 	mbc.metrics.lastGCTime = time.Now()
 	mbc.metrics.mutex.Unlock()
-	atomic.AddInt64(&mbc.metrics.memoryFreedBytes, freedBytes)
+	mbc.metrics.memoryFreedBytes.Add(freedBytes)
 
-	mbc.lastGCRun = time.Now()
+	mbc.lastGCRun.Store(time.Now().UnixNano())
 	atomic.StoreInt32(&mbc.gcRunning, 0)
 }
 
@@ -473,8 +481,8 @@ func (mbc *MemoryBoundedCache) calculateMemorySize(key string, value interface{}
 func (mbc *MemoryBoundedCache) recordMemorySample() {
 	sample := MemorySample{
 		timestamp:   time.Now(),
-		memoryBytes: mbc.currentMemory,
-		itemCount:   mbc.itemCount,
+		memoryBytes: mbc.currentMemory.Load(),
+		itemCount:   mbc.itemCount.Load(),
 		gcRunning:   atomic.LoadInt32(&mbc.gcRunning) == 1,
 	}
 
@@ -486,15 +494,16 @@ func (mbc *MemoryBoundedCache) GetMemoryStats() MemoryStats {
 	mbc.mu.RLock()
 	defer mbc.mu.RUnlock()
 
+	currentMemory := mbc.currentMemory.Load()
 	return MemoryStats{
-		CurrentMemoryBytes: mbc.currentMemory,
+		CurrentMemoryBytes: currentMemory,
 		MaxMemoryBytes:     mbc.maxMemoryBytes,
 		MemoryPressure:     mbc.memoryPressure,
-		ItemCount:          mbc.itemCount,
-		MemoryUtilization:  float64(mbc.currentMemory) / float64(mbc.maxMemoryBytes),
-		GCRunCount:         atomic.LoadInt64(&mbc.metrics.gcRunCount),
-		EvictionCount:      atomic.LoadInt64(&mbc.metrics.evictionCount),
-		MemoryFreedBytes:   atomic.LoadInt64(&mbc.metrics.memoryFreedBytes),
+		ItemCount:          mbc.itemCount.Load(),
+		MemoryUtilization:  float64(currentMemory) / float64(mbc.maxMemoryBytes),
+		GCRunCount:         mbc.metrics.gcRunCount.Load(),
+		EvictionCount:      mbc.metrics.evictionCount.Load(),
+		MemoryFreedBytes:   mbc.metrics.memoryFreedBytes.Load(),
 		HitRatio:           mbc.calculateHitRatio(),
 		Trend:              mbc.memoryTracker.GetTrend(),
 	}
@@ -516,8 +525,8 @@ type MemoryStats struct {
 
 // calculateHitRatio calculates cache hit ratio
 func (mbc *MemoryBoundedCache) calculateHitRatio() float64 {
-	hits := atomic.LoadInt64(&mbc.metrics.hitCount)
-	misses := atomic.LoadInt64(&mbc.metrics.missCount)
+	hits := mbc.metrics.hitCount.Load()
+	misses := mbc.metrics.missCount.Load()
 	total := hits + misses
 
 	if total == 0 {
@@ -533,6 +533,71 @@ func TestMemoryBoundedCacheMemoryTracker(t *testing.T) {
 	}
 }
 
+// TestMemoryBoundedCacheConcurrentStats tests that stats and the background
+// tracker read memory counters safely while many goroutines write; run with -race
+func TestMemoryBoundedCacheConcurrentStats(t *testing.T) {
+	config := DefaultMemoryBoundedConfig()
+	config.EnableMemoryTracker = true
+	config.EnableGCOptimization = false
+	config.MemoryCheckInterval = time.Millisecond
+
+	cache := NewMemoryBoundedCache(config)
+
+	const workers, ops = 32, 300
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("race_key_%d_%d", w, i%20)
+				if err := cache.Set(key, "value", time.Minute); err != nil {
+					t.Errorf("Failed to set %s: %v", key, err)
+					return
+				}
+				cache.Get(key)
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				if stats := cache.GetMemoryStats(); stats.CurrentMemoryBytes < 0 || stats.ItemCount < 0 {
+					t.Errorf("Expected non-negative memory and items, got %+v", stats)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.GetMemoryStats()
+	if stats.ItemCount != workers*20 {
+		t.Errorf("Expected %d items, got %d", workers*20, stats.ItemCount)
+	}
+	if hits := cache.metrics.hitCount.Load(); hits != workers*ops {
+		t.Errorf("Expected %d hits, got %d", workers*ops, hits)
+	}
+}
+
+// TestEnhancedCacheMetricsPeakMemory tests that concurrent peaks keep the maximum
+func TestEnhancedCacheMetricsPeakMemory(t *testing.T) {
+	metrics := &EnhancedCacheMetrics{}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(bytes int64) {
+			defer wg.Done()
+			metrics.recordPeakMemory(bytes)
+		}(int64(i * 1024))
+	}
+	wg.Wait()
+
+	if peak := metrics.peakMemoryBytes.Load(); peak != 100*1024 {
+		t.Errorf("Expected peak of %d, got %d", 100*1024, peak)
+	}
+}
+
 // Example usage function to demonstrate the API
 func ExampleMemoryBoundedCache() {
 	// Create configuration
@@ -37,6 +37,25 @@ type MonitoringSnapshot struct {
 	TTFBP95 float64 `json:"ttfb_p95_ms"`
 	TTFBP99 float64 `json:"ttfb_p99_ms"`
 
+	// Phase breakdown, separating server time from transfer time
+	DNSP95      float64 `json:"dns_p95_ms"`
+	ConnectP95  float64 `json:"connect_p95_ms"`
+	TLSP95      float64 `json:"tls_p95_ms"`
+	ServerP50   float64 `json:"server_p50_ms"`
+	ServerP95   float64 `json:"server_p95_ms"`
+	DownloadP50 float64 `json:"download_p50_ms"`
+	DownloadP95 float64 `json:"download_p95_ms"`
+
+	// Time to first byte the upstream reported in Server-Timing against the
+	// rest, and the reported phases themselves
+	UpstreamP50     float64             `json:"upstream_p50_ms"`
+	UpstreamP95     float64             `json:"upstream_p95_ms"`
+	NetworkP50      float64             `json:"network_p50_ms"`
+	NetworkP95      float64             `json:"network_p95_ms"`
+	UpstreamShare   float64             `json:"upstream_share"`
+	UpstreamVerdict string              `json:"upstream_verdict,omitempty"`
+	ServerTiming    []ServerTimingPhase `json:"server_timing,omitempty"`
+
 	// Throughput metrics
 	RequestsPerSecond float64 `json:"requests_per_second"`
 	BytesPerSecond    float64 `json:"bytes_per_second"`
@@ -56,6 +75,16 @@ type MonitoringSnapshot struct {
 	// Performance grade
 	PerformanceGrade string `json:"performance_grade"`
 	PerformanceScore int    `json:"performance_score"`
+
+	// Raw snapshots merged into this one by downsampling (0 = raw)
+	Downsampled int `json:"downsampled,omitempty"`
+}
+
+// ServerTimingPhase is one Server-Timing metric of the last run
+type ServerTimingPhase struct {
+	Name string  `json:"name"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
 }
 
 // MetricsCollector aggregates metrics from all system components
@@ -67,6 +96,8 @@ type MetricsCollector struct {
 	// Historical data
 	snapshots    []MonitoringSnapshot
 	maxSnapshots int
+	retention    RetentionPolicy
+	store        *SnapshotStore // Persists captured snapshots, if attached
 
 	// Current metrics
 	currentSnapshot     *MonitoringSnapshot
@@ -78,6 +109,8 @@ type MetricsCollector struct {
 	// Timing
 	collectionStart time.Time
 	lastCollection  time.Time
+
+	trendConfig TrendConfig
 }
 
 // NewMetricsCollector creates a new metrics collector
@@ -86,14 +119,26 @@ func NewMetricsCollector(maxSnapshots int) *MetricsCollector {
 		maxSnapshots = 1000
 	}
 
+	retention := DefaultRetentionPolicy()
+	retention.MaxSnapshots = maxSnapshots
+
 	return &MetricsCollector{
 		snapshots:       make([]MonitoringSnapshot, 0, maxSnapshots),
 		maxSnapshots:    maxSnapshots,
+		retention:       retention,
 		collectionStart: time.Now(),
 		lastCollection:  time.Now(),
+		trendConfig:     DefaultTrendConfig(),
 	}
 }
 
+// SetTrendConfig tunes trend analysis and anomaly scoring
+func (mc *MetricsCollector) SetTrendConfig(config TrendConfig) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.trendConfig = config
+}
+
 // AttachBenchmarker attaches a benchmarker for monitoring
 func (mc *MetricsCollector) AttachBenchmarker(b *Benchmarker) {
 	mc.mu.Lock()
@@ -156,6 +201,30 @@ func (mc *MetricsCollector) Collect() {
 		snapshot.TTFBP95 = result.TTFBStats.P95
 		snapshot.TTFBP99 = result.TTFBStats.P99
 
+		snapshot.DNSP95 = result.DNSStats.P95
+		snapshot.ConnectP95 = result.ConnectionStats.P95
+		snapshot.TLSP95 = result.TLSStats.P95
+		snapshot.ServerP50 = result.ServerStats.P50
+		snapshot.ServerP95 = result.ServerStats.P95
+		snapshot.DownloadP50 = result.DownloadStats.P50
+		snapshot.DownloadP95 = result.DownloadStats.P95
+
+		if upstream := result.Upstream; upstream != nil {
+			snapshot.UpstreamP50 = upstream.Upstream.P50
+			snapshot.UpstreamP95 = upstream.Upstream.P95
+			snapshot.NetworkP50 = upstream.Network.P50
+			snapshot.NetworkP95 = upstream.Network.P95
+			snapshot.UpstreamShare = upstream.UpstreamShare
+			snapshot.UpstreamVerdict = upstream.Verdict
+		}
+		for _, timing := range result.ServerTiming {
+			snapshot.ServerTiming = append(snapshot.ServerTiming, ServerTimingPhase{
+				Name: timing.Name,
+				P50:  timing.Duration.P50,
+				P95:  timing.Duration.P95,
+			})
+		}
+
 		snapshot.RequestsPerSecond = result.RequestsPerSecond
 		snapshot.BytesPerSecond = result.BytesPerSecond
 
@@ -201,6 +270,12 @@ func (mc *MetricsCollector) CaptureSnapshot() {
 	if len(mc.snapshots) > mc.maxSnapshots {
 		mc.snapshots = mc.snapshots[1:]
 	}
+
+	if mc.store != nil {
+		if err := mc.store.Append(*mc.currentSnapshot); err != nil {
+			fmt.Printf("Failed to store snapshot: %v\n", err)
+		}
+	}
 }
 
 // GetSnapshot returns the current monitoring snapshot
@@ -217,6 +292,18 @@ func (mc *MetricsCollector) GetSnapshot() *MonitoringSnapshot {
 	return &snapshot
 }
 
+// GetLatencyHeatmap returns the heatmap of the last benchmark result, or nil
+// if there is none
+func (mc *MetricsCollector) GetLatencyHeatmap() *LatencyHeatmap {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.lastBenchmarkResult == nil {
+		return nil
+	}
+	return mc.lastBenchmarkResult.Heatmap
+}
+
 // GetSnapshots returns all historical snapshots
 func (mc *MetricsCollector) GetSnapshots() []MonitoringSnapshot {
 	mc.mu.RLock()
@@ -258,22 +345,7 @@ func (mc *MetricsCollector) GetSnapshotsInRange(start, end time.Time) []Monitori
 
 // CleanupOldSnapshots removes snapshots older than the retention period
 func (mc *MetricsCollector) CleanupOldSnapshots(retentionPeriod time.Duration) int {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
-	cutoff := time.Now().Add(-retentionPeriod)
-	newSnapshots := make([]MonitoringSnapshot, 0, len(mc.snapshots))
-
-	for _, snapshot := range mc.snapshots {
-		if snapshot.Timestamp.After(cutoff) {
-			newSnapshots = append(newSnapshots, snapshot)
-		}
-	}
-
-	removed := len(mc.snapshots) - len(newSnapshots)
-	mc.snapshots = newSnapshots
-
-	return removed
+	return mc.PurgeSnapshots(time.Now().Add(-retentionPeriod))
 }
 
 // GetMetricsSummary returns a summary of key metrics
@@ -302,6 +374,15 @@ func (mc *MetricsCollector) GetMetricsSummary() map[string]interface{} {
 			"p99_ms":  snapshot.LatencyP99,
 			"mean_ms": snapshot.LatencyMean,
 		},
+		"phases": map[string]interface{}{
+			"dns_p95_ms":      snapshot.DNSP95,
+			"connect_p95_ms":  snapshot.ConnectP95,
+			"tls_p95_ms":      snapshot.TLSP95,
+			"server_p50_ms":   snapshot.ServerP50,
+			"server_p95_ms":   snapshot.ServerP95,
+			"download_p50_ms": snapshot.DownloadP50,
+			"download_p95_ms": snapshot.DownloadP95,
+		},
 		"throughput": map[string]interface{}{
 			"requests_per_second": snapshot.RequestsPerSecond,
 			"bytes_per_second":    snapshot.BytesPerSecond,
@@ -318,59 +399,57 @@ func (mc *MetricsCollector) GetMetricsSummary() map[string]interface{} {
 	}
 }
 
-// GetTrendAnalysis analyzes trends in the collected metrics
-func (mc *MetricsCollector) GetTrendAnalysis(duration time.Duration) map[string]interface{} {
+// AnalyzeTrends computes the trend of every TrendMetrics metric over the
+// snapshots captured in the last duration, scoring the latest values for
+// anomalies. It returns nil with fewer than two snapshots
+func (mc *MetricsCollector) AnalyzeTrends(duration time.Duration) map[string]*MetricTrend {
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
 
 	since := time.Now().Add(-duration)
-	relevantSnapshots := make([]MonitoringSnapshot, 0)
-
-	for _, snapshot := range mc.snapshots {
+	first := len(mc.snapshots)
+	for i, snapshot := range mc.snapshots {
 		if snapshot.Timestamp.After(since) {
-			relevantSnapshots = append(relevantSnapshots, snapshot)
+			first = i
+			break
 		}
 	}
+	if len(mc.snapshots)-first < 2 {
+		return nil
+	}
 
-	if len(relevantSnapshots) < 2 {
+	trends := make(map[string]*MetricTrend, len(TrendMetrics))
+	for _, metric := range TrendMetrics {
+		history := seriesOf(mc.snapshots, metric)
+		trends[metric] = AnalyzeSeries(metric, history[first:], history, mc.trendConfig)
+	}
+	return trends
+}
+
+// GetTrendAnalysis analyzes trends in the collected metrics
+func (mc *MetricsCollector) GetTrendAnalysis(duration time.Duration) map[string]interface{} {
+	trends := mc.AnalyzeTrends(duration)
+	if trends == nil {
 		return map[string]interface{}{
 			"status": "insufficient data for trend analysis",
 		}
 	}
 
-	// Calculate trends
-	first := relevantSnapshots[0]
-	last := relevantSnapshots[len(relevantSnapshots)-1]
-
-	return map[string]interface{}{
+	analysis := map[string]interface{}{
 		"period":  duration.String(),
-		"samples": len(relevantSnapshots),
-		"cache_hit_ratio": map[string]interface{}{
-			"start":  first.CacheHitRatio,
-			"end":    last.CacheHitRatio,
-			"change": last.CacheHitRatio - first.CacheHitRatio,
-		},
-		"latency_p95": map[string]interface{}{
-			"start":  first.LatencyP95,
-			"end":    last.LatencyP95,
-			"change": last.LatencyP95 - first.LatencyP95,
-		},
-		"latency_p99": map[string]interface{}{
-			"start":  first.LatencyP99,
-			"end":    last.LatencyP99,
-			"change": last.LatencyP99 - first.LatencyP99,
-		},
-		"throughput": map[string]interface{}{
-			"start":  first.RequestsPerSecond,
-			"end":    last.RequestsPerSecond,
-			"change": last.RequestsPerSecond - first.RequestsPerSecond,
-		},
-		"error_rate": map[string]interface{}{
-			"start":  first.ErrorRate,
-			"end":    last.ErrorRate,
-			"change": last.ErrorRate - first.ErrorRate,
-		},
+		"samples": trends[TrendMetrics[0]].Samples,
 	}
+
+	anomalous := make([]string, 0)
+	for _, metric := range TrendMetrics {
+		analysis[metric] = trends[metric]
+		if trends[metric].Anomaly {
+			anomalous = append(anomalous, metric)
+		}
+	}
+	analysis["anomalous_metrics"] = anomalous
+
+	return analysis
 }
 
 // SaveReport saves a comprehensive metrics report to a file
@@ -490,6 +569,12 @@ func (s *MonitoringSnapshot) PrintSummary() {
 	fmt.Printf("P95: %.2f ms\n", s.TTFBP95)
 	fmt.Printf("P99: %.2f ms\n", s.TTFBP99)
 
+	// Phase breakdown
+	fmt.Printf("\n--- Latency Phases (P95) ---\n")
+	fmt.Printf("DNS: %.2f ms | Connect: %.2f ms | TLS: %.2f ms\n", s.DNSP95, s.ConnectP95, s.TLSP95)
+	fmt.Printf("Server: %.2f ms (P50 %.2f) | Download: %.2f ms (P50 %.2f)\n",
+		s.ServerP95, s.ServerP50, s.DownloadP95, s.DownloadP50)
+
 	// Throughput metrics
 	fmt.Printf("\n--- Throughput ---\n")
 	fmt.Printf("Requests/sec: %.2f\n", s.RequestsPerSecond)
@@ -21,6 +21,7 @@ type MonitoringConfig struct {
 	DashboardEnabled bool
 	DashboardPort    int
 	DashboardRefresh time.Duration
+	Dashboard        DashboardOptions // Assets, theme and branding
 
 	// Alerting settings
 	AlertingEnabled    bool
@@ -28,21 +29,35 @@ type MonitoringConfig struct {
 	AlertCheckInterval time.Duration
 	AlertRules         []AlertRule
 
+	// Trend analysis and anomaly scoring; zero uses DefaultTrendConfig
+	Trends TrendConfig
+
 	// Prometheus settings
 	PrometheusEnabled bool
 	PrometheusPort    int
 	PrometheusPath    string
 
+	// Interface and socket options of the dashboard and Prometheus servers
+	Listen ListenOptions
+
 	// Storage settings
-	RetentionPeriod time.Duration
-	MaxSnapshots    int
-	OutputPath      string
+	RetentionPeriod    time.Duration
+	MaxSnapshots       int
+	DownsampleAfter    time.Duration // Age after which snapshots are downsampled (0 = never)
+	DownsampleInterval time.Duration // Resolution of downsampled snapshots
+	OutputPath         string
+
+	// Directory persisting every snapshot for the dashboard's history
+	// (empty = memory only), and how long it is kept there (0 = forever)
+	HistoryDir       string
+	HistoryRetention time.Duration
 }
 
 // MonitoringSystem orchestrates all monitoring components
 type MonitoringSystem struct {
 	config       MonitoringConfig
 	collector    *MetricsCollector
+	store        *SnapshotStore
 	dashboard    *Dashboard
 	alertManager *AlertManager
 	promExporter *PrometheusExporter
@@ -109,20 +124,33 @@ func NewMonitoringSystem(config MonitoringConfig) *MonitoringSystem {
 		startTime:    time.Now(),
 		stopChannels: make([]chan struct{}, 0),
 	}
+	if config.Trends.MovingAverageWindow > 0 {
+		ms.collector.SetTrendConfig(config.Trends)
+	}
+	ms.collector.SetRetentionPolicy(RetentionPolicy{
+		MaxSnapshots:       config.MaxSnapshots,
+		MaxAge:             config.RetentionPeriod,
+		DownsampleAfter:    config.DownsampleAfter,
+		DownsampleInterval: config.DownsampleInterval,
+	})
 
 	// Initialize dashboard if enabled
 	if config.DashboardEnabled {
 		ms.dashboard = NewDashboard(config.DashboardPort, config.DashboardRefresh)
+		ms.dashboard.SetOptions(config.Dashboard)
+		ms.dashboard.SetListenOptions(config.Listen)
 	}
 
 	// Initialize alert manager if enabled
 	if config.AlertingEnabled {
 		ms.alertManager = NewAlertManager(config.AlertRules)
+		ms.alertManager.AttachCollector(ms.collector)
 	}
 
 	// Initialize Prometheus exporter if enabled
 	if config.PrometheusEnabled {
 		ms.promExporter = NewPrometheusExporter(config.PrometheusPort, config.PrometheusPath)
+		ms.promExporter.SetListenOptions(config.Listen)
 	}
 
 	return ms
@@ -151,6 +179,13 @@ func (ms *MonitoringSystem) AttachCache(c *LRUCache) {
 
 // Start initializes and starts all monitoring components
 func (ms *MonitoringSystem) Start() error {
+	return ms.StartContext(context.Background())
+}
+
+// StartContext starts all monitoring components with background loops bound
+// to ctx, so cancelling it stops collection promptly; Stop is still needed to
+// shut down the HTTP servers
+func (ms *MonitoringSystem) StartContext(ctx context.Context) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
@@ -158,10 +193,24 @@ func (ms *MonitoringSystem) Start() error {
 		return fmt.Errorf("monitoring system already running")
 	}
 
+	ms.cancel()
+	ms.ctx, ms.cancel = context.WithCancel(ctx)
+
 	fmt.Printf("\n=== Starting Monitoring System ===\n")
 	fmt.Printf("Metrics Interval: %v\n", ms.config.MetricsInterval)
 	fmt.Printf("Snapshot Interval: %v\n", ms.config.SnapshotInterval)
 
+	// Open the snapshot history before capturing into it
+	if ms.config.HistoryDir != "" && ms.store == nil {
+		store, err := OpenSnapshotStore(ms.config.HistoryDir, ms.config.HistoryRetention)
+		if err != nil {
+			return err
+		}
+		ms.store = store
+		ms.collector.AttachStore(store)
+		fmt.Printf("Snapshot History: %s\n", ms.config.HistoryDir)
+	}
+
 	// Start metrics collection
 	stopMetrics := ms.startMetricsCollection()
 	ms.stopChannels = append(ms.stopChannels, stopMetrics)
@@ -181,7 +230,7 @@ func (ms *MonitoringSystem) Start() error {
 	// Start alert manager if enabled
 	if ms.config.AlertingEnabled && ms.alertManager != nil {
 		fmt.Printf("Alert Manager: Enabled (%d rules)\n", len(ms.config.AlertRules))
-		stopAlerts := ms.alertManager.Start(ms.config.AlertCheckInterval)
+		stopAlerts := ms.alertManager.StartContext(ms.ctx, ms.config.AlertCheckInterval)
 		ms.stopChannels = append(ms.stopChannels, stopAlerts)
 	}
 
@@ -236,6 +285,10 @@ func (ms *MonitoringSystem) Stop() error {
 	// Wait for all goroutines
 	ms.wg.Wait()
 
+	if ms.store != nil {
+		ms.store.Close()
+	}
+
 	ms.running = false
 	fmt.Printf("Monitoring System: Stopped\n")
 
@@ -282,6 +335,7 @@ func (ms *MonitoringSystem) Uptime() time.Duration {
 // startMetricsCollection starts periodic metrics collection
 func (ms *MonitoringSystem) startMetricsCollection() chan struct{} {
 	stopChan := make(chan struct{})
+	ctx := ms.ctx
 
 	ms.wg.Add(1)
 	go func() {
@@ -295,7 +349,7 @@ func (ms *MonitoringSystem) startMetricsCollection() chan struct{} {
 				ms.collector.Collect()
 			case <-stopChan:
 				return
-			case <-ms.ctx.Done():
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -307,6 +361,7 @@ func (ms *MonitoringSystem) startMetricsCollection() chan struct{} {
 // startSnapshotCapture starts periodic snapshot capture
 func (ms *MonitoringSystem) startSnapshotCapture() chan struct{} {
 	stopChan := make(chan struct{})
+	ctx := ms.ctx
 
 	ms.wg.Add(1)
 	go func() {
@@ -320,7 +375,7 @@ func (ms *MonitoringSystem) startSnapshotCapture() chan struct{} {
 				ms.collector.CaptureSnapshot()
 			case <-stopChan:
 				return
-			case <-ms.ctx.Done():
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -332,6 +387,7 @@ func (ms *MonitoringSystem) startSnapshotCapture() chan struct{} {
 // startCleanupRoutine starts periodic cleanup of old data
 func (ms *MonitoringSystem) startCleanupRoutine() chan struct{} {
 	stopChan := make(chan struct{})
+	ctx := ms.ctx
 
 	ms.wg.Add(1)
 	go func() {
@@ -342,10 +398,13 @@ func (ms *MonitoringSystem) startCleanupRoutine() chan struct{} {
 		for {
 			select {
 			case <-ticker.C:
-				ms.collector.CleanupOldSnapshots(ms.config.RetentionPeriod)
+				ms.collector.ApplyRetention()
+				if ms.store != nil {
+					ms.store.Prune(time.Now())
+				}
 			case <-stopChan:
 				return
-			case <-ms.ctx.Done():
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -408,6 +467,8 @@ func DefaultMonitoringConfig() MonitoringConfig {
 		PrometheusPath:     "/metrics",
 		RetentionPeriod:    24 * time.Hour,
 		MaxSnapshots:       1000,
+		DownsampleAfter:    time.Hour,
+		DownsampleInterval: 5 * time.Minute,
 		OutputPath:         "./monitoring",
 		AlertRules: []AlertRule{
 			{
@@ -438,6 +499,15 @@ func DefaultMonitoringConfig() MonitoringConfig {
 				Threshold:   0.05,
 				Severity:    AlertSeverityCritical,
 			},
+			{
+				Name:        "latency_anomaly",
+				Description: "Alert when P95 latency deviates from its baseline",
+				Type:        AlertTypeAnomaly,
+				Metric:      TrendMetricLatencyP95,
+				Threshold:   3.0, // z-score
+				Severity:    AlertSeverityWarning,
+			},
 		},
+		Trends: DefaultTrendConfig(),
 	}
 }
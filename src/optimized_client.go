@@ -3,12 +3,11 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/http/httptrace"
 	"sync"
 	"time"
 )
@@ -18,8 +17,11 @@ type OptimizedClient struct {
 	// Core components
 	http2Client      *HTTP2Client
 	cache            *Cache
+	cacheCodec       CacheCodec
+	vary             *VaryIndex
 	monitor          *Monitor
 	metricsCollector *MetricsCollector
+	breakers         *CircuitBreakerRegistry
 
 	// Configuration
 	config *OptimizedClientConfig
@@ -36,6 +38,7 @@ type OptimizedClient struct {
 	cacheMisses     int64
 	connectionReuse int64
 	errors          int64
+	errorTypes      map[string]int64
 }
 
 // OptimizedClientConfig holds configuration for the unified client
@@ -46,7 +49,7 @@ type OptimizedClientConfig struct {
 		IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`
 		TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
 		DisableCompression    bool          `yaml:"disable_compression"`
-		EnablePush            bool          `yaml:"enable_push"`
+		EnablePush            bool          `yaml:"enable_push"` // Deprecated: no effect; see HTTP2ClientConfig.EnableHTTP2Push
 	} `yaml:"http2"`
 
 	// Cache Configuration
@@ -56,6 +59,10 @@ type OptimizedClientConfig struct {
 		DefaultTTL    time.Duration `yaml:"default_ttl"`
 		PolicyType    string        `yaml:"policy_type"`
 		WarmupEnabled bool          `yaml:"warmup_enabled"`
+		Codec         string        `yaml:"codec"` // See CacheCodecByName
+
+		// Request headers responses may vary on and still be cached
+		VaryHeaders []string `yaml:"vary_headers"`
 	} `yaml:"cache"`
 
 	// Monitoring Configuration
@@ -66,6 +73,20 @@ type OptimizedClientConfig struct {
 		PrometheusEnabled bool `yaml:"prometheus_enabled"`
 	} `yaml:"monitoring"`
 
+	// Circuit breaking: one breaker per upstream host
+	CircuitBreakerEnabled bool                  `yaml:"circuit_breaker_enabled"`
+	CircuitBreaker        *CircuitBreakerConfig `yaml:"circuit_breaker"`
+
+	// Optional CA bundle and client certificates for mutual TLS
+	TLS *ClientTLSConfig `yaml:"tls"`
+
+	// Optional Unix domain socket and cleartext HTTP/2 mode; see HTTP2ClientConfig
+	UnixSocket string `yaml:"unix_socket"`
+	H2C        string `yaml:"h2c"`
+
+	// Optional periodic reconnects; see ConnectionRotation
+	ConnectionRotation *ConnectionRotation `yaml:"connection_rotation"`
+
 	// Integration Configuration
 	MaxRetries     int           `yaml:"max_retries"`
 	RetryBackoff   time.Duration `yaml:"retry_backoff"`
@@ -87,7 +108,7 @@ func DefaultOptimizedClientConfig() *OptimizedClientConfig {
 			IdleConnTimeout:       90 * time.Second,
 			TLSHandshakeTimeout:   10 * time.Second,
 			DisableCompression:    false,
-			EnablePush:            true,
+			EnablePush:            false,
 		},
 		CacheConfig: struct {
 			Enabled       bool          `yaml:"enabled"`
@@ -95,12 +116,16 @@ func DefaultOptimizedClientConfig() *OptimizedClientConfig {
 			DefaultTTL    time.Duration `yaml:"default_ttl"`
 			PolicyType    string        `yaml:"policy_type"`
 			WarmupEnabled bool          `yaml:"warmup_enabled"`
+			Codec         string        `yaml:"codec"`
+			VaryHeaders   []string      `yaml:"vary_headers"`
 		}{
 			Enabled:       true,
 			Capacity:      10000,
 			DefaultTTL:    5 * time.Minute,
 			PolicyType:    "adaptive",
 			WarmupEnabled: true,
+			Codec:         CacheCodecBinary,
+			VaryHeaders:   DefaultVaryHeaders,
 		},
 		MonitoringConfig: struct {
 			Enabled           bool `yaml:"enabled"`
@@ -113,10 +138,12 @@ func DefaultOptimizedClientConfig() *OptimizedClientConfig {
 			AlertsEnabled:     false,
 			PrometheusEnabled: false,
 		},
-		MaxRetries:     3,
-		RetryBackoff:   100 * time.Millisecond,
-		RequestTimeout: 30 * time.Second,
-		EnableMetrics:  true,
+		CircuitBreakerEnabled: false, // Opt in; an open breaker hides the upstream's own error
+		CircuitBreaker:        DefaultCircuitBreakerConfig(),
+		MaxRetries:            3,
+		RetryBackoff:          100 * time.Millisecond,
+		RequestTimeout:        30 * time.Second,
+		EnableMetrics:         true,
 	}
 }
 
@@ -127,7 +154,8 @@ func NewOptimizedClient(config *OptimizedClientConfig) (*OptimizedClient, error)
 	}
 
 	client := &OptimizedClient{
-		config: config,
+		config:     config,
+		errorTypes: make(map[string]int64),
 	}
 
 	// Initialize HTTP/2 client
@@ -137,6 +165,10 @@ func NewOptimizedClient(config *OptimizedClientConfig) (*OptimizedClient, error)
 		TLSHandshakeTimeout:   config.HTTP2Config.TLSHandshakeTimeout,
 		DisableCompression:    config.HTTP2Config.DisableCompression,
 		EnableHTTP2Push:       config.HTTP2Config.EnablePush,
+		TLS:                   config.TLS,
+		UnixSocket:            config.UnixSocket,
+		H2C:                   config.H2C,
+		Rotation:              config.ConnectionRotation,
 	}
 
 	var err error
@@ -154,6 +186,10 @@ func NewOptimizedClient(config *OptimizedClientConfig) (*OptimizedClient, error)
 		}
 
 		client.cache = NewCache(cacheConfig)
+		if client.cacheCodec, err = CacheCodecByName(config.CacheConfig.Codec); err != nil {
+			return nil, err
+		}
+		client.vary = NewVaryIndex(config.CacheConfig.VaryHeaders...)
 
 		// Initialize cache warmup if enabled
 		if config.CacheConfig.WarmupEnabled {
@@ -181,10 +217,34 @@ func NewOptimizedClient(config *OptimizedClientConfig) (*OptimizedClient, error)
 		client.metricsCollector = NewMetricsCollector(1000) // Default max snapshots
 	}
 
+	// Initialize per-host circuit breakers if enabled
+	if config.CircuitBreakerEnabled {
+		client.breakers = NewCircuitBreakerRegistry(config.CircuitBreaker)
+	}
+
 	client.initialized = true
 	return client, nil
 }
 
+// ConnectionStats returns the live state of the HTTP/2 connection pool
+func (c *OptimizedClient) ConnectionStats() ConnectionPoolStats {
+	return c.http2Client.ConnectionTracker().Stats()
+}
+
+// SetCircuitBreakerRegistry shares a breaker registry with other clients
+func (c *OptimizedClient) SetCircuitBreakerRegistry(registry *CircuitBreakerRegistry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breakers = registry
+}
+
+// CircuitBreakers returns the client's breaker registry, or nil if disabled
+func (c *OptimizedClient) CircuitBreakers() *CircuitBreakerRegistry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.breakers
+}
+
 // OptimizedRequest represents a request with optimization context
 type OptimizedRequest struct {
 	*http.Request
@@ -208,6 +268,11 @@ type OptimizedResponse struct {
 	TTFBLatency       time.Duration
 	ProcessingLatency time.Duration
 	Metadata          map[string]interface{}
+
+	// TotalLatency split into time queued for a pooled connection and the
+	// remaining service time
+	QueueLatency   time.Duration
+	ServiceLatency time.Duration
 }
 
 // Do executes an HTTP request using all available optimizations
@@ -244,6 +309,7 @@ func (c *OptimizedClient) Do(req *OptimizedRequest) (*OptimizedResponse, error)
 		if cached := c.tryCache(req, response); cached != nil {
 			response.CacheHit = true
 			response.TotalLatency = time.Since(start)
+			response.ServiceLatency = response.TotalLatency
 
 			c.mu.Lock()
 			c.cacheHits++
@@ -268,13 +334,9 @@ func (c *OptimizedClient) Do(req *OptimizedRequest) (*OptimizedResponse, error)
 	if err != nil {
 		c.mu.Lock()
 		c.errors++
+		c.errorTypes[ClassifyRequestError(err)]++
 		c.mu.Unlock()
 
-		// Retry logic
-		if c.shouldRetry(err, 0) {
-			return c.retryRequest(req, response, 1)
-		}
-
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 
@@ -286,6 +348,8 @@ func (c *OptimizedClient) Do(req *OptimizedRequest) (*OptimizedResponse, error)
 	response.TTFBLatency = timing.TTFBLatency
 	response.ProcessingLatency = timing.ProcessingLatency
 	response.TotalLatency = time.Since(start)
+	response.QueueLatency = timing.QueueLatency
+	response.ServiceLatency = response.TotalLatency - timing.QueueLatency
 	response.ConnectionReused = timing.ConnectionReused
 
 	if timing.ConnectionReused {
@@ -323,12 +387,38 @@ func (c *OptimizedClient) Do(req *OptimizedRequest) (*OptimizedResponse, error)
 // 	ConnectionReused  bool
 // }
 
+// errUpstreamServerError marks 5xx responses as failures for the circuit breaker
+var errUpstreamServerError = errors.New("upstream server error")
+
 // executeHTTP2Request performs the actual HTTP/2 request with detailed timing
 func (c *OptimizedClient) executeHTTP2Request(req *OptimizedRequest) (*http.Response, *HTTP2RequestTiming, error) {
 	timing := &HTTP2RequestTiming{}
 
-	// Use the HTTP/2 client's Do method which provides detailed timing
-	response, err := c.http2Client.Do(req.Request)
+	var wait connWait
+	req.Request = req.Request.WithContext(httptrace.WithClientTrace(req.Context(), wait.trace()))
+	defer func() { timing.QueueLatency = wait.Queue() }()
+
+	// Use the HTTP/2 client's Do method which provides detailed timing,
+	// routed through the host's circuit breaker when one is configured. The
+	// retries happen inside, so the breaker sees one outcome per request
+	var response *http.Response
+	var err error
+	if breakers := c.CircuitBreakers(); breakers != nil {
+		_, err = breakers.ForRequest(req.Request).ExecuteWithContext(req.Context(), func() (interface{}, error) {
+			resp, doErr := c.doWithRetries(req.Request)
+			response = resp
+			if doErr == nil && resp.StatusCode >= 500 {
+				return resp, errUpstreamServerError
+			}
+			return resp, doErr
+		})
+		// Server errors count against the breaker but are still returned to the caller
+		if errors.Is(err, errUpstreamServerError) {
+			err = nil
+		}
+	} else {
+		response, err = c.doWithRetries(req.Request)
+	}
 	if err != nil {
 		return nil, timing, err
 	}
@@ -352,23 +442,33 @@ func (c *OptimizedClient) tryCache(req *OptimizedRequest, response *OptimizedRes
 	if key == "" {
 		key = generateCacheKey(req.Request)
 	}
+	key = c.vary.Key(key, req.Request)
 
 	cached, age, found := c.cache.GetWithAge(key)
 	if !found {
 		return nil
 	}
 
-	// Parse cached response
-	cachedResp, ok := cached.(*http.Response)
+	// Cached values are encoded, so the cache can keep them out of process
+	data, ok := cached.([]byte)
 	if !ok {
+		c.cache.Delete(key)
+		return nil
+	}
+	entry, err := c.cacheCodec.Decode(data)
+	if err != nil {
 		// Cache corruption, remove entry
 		c.cache.Delete(key)
 		return nil
 	}
+	// A generated key can collide; the entry then belongs to another request
+	if req.CacheKey == "" && entry.Check != 0 && entry.Check != defaultCacheKeyBuilder.Check(req.Request) {
+		return nil
+	}
 
 	// Create optimized response from cache
 	optimized := &OptimizedResponse{
-		Response: cachedResp,
+		Response: entry.Response(req.Request),
 		CacheHit: true,
 		CacheAge: age,
 		Metadata: response.Metadata,
@@ -384,45 +484,30 @@ func (c *OptimizedClient) cacheResponse(req *OptimizedRequest, resp *http.Respon
 		key = generateCacheKey(req.Request)
 	}
 
+	// Each variant of a response with Vary gets its own key
+	key, ok := c.vary.Learn(key, req.Request, resp)
+	if !ok {
+		return
+	}
+
 	ttl := req.CacheTTL
 	if ttl == 0 {
 		ttl = c.config.CacheConfig.DefaultTTL
 	}
 
-	// Clone response for caching (to avoid body consumption issues)
-	clonedResp := c.cloneResponse(resp)
-	c.cache.SetWithTTL(key, clonedResp, ttl)
-}
-
-// cloneResponse creates a copy of an HTTP response for caching
-func (c *OptimizedClient) cloneResponse(resp *http.Response) *http.Response {
-	cloned := &http.Response{
-		Status:           resp.Status,
-		StatusCode:       resp.StatusCode,
-		Proto:            resp.Proto,
-		ProtoMajor:       resp.ProtoMajor,
-		ProtoMinor:       resp.ProtoMinor,
-		Header:           resp.Header.Clone(),
-		ContentLength:    resp.ContentLength,
-		TransferEncoding: resp.TransferEncoding,
-		Close:            resp.Close,
-		Uncompressed:     resp.Uncompressed,
-		Trailer:          resp.Trailer,
-		Request:          resp.Request,
-		TLS:              resp.TLS,
-	}
-
-	// Clone body if present
-	if resp.Body != nil {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err == nil {
-			resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-			cloned.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		}
+	// The entry reads the body and leaves resp readable
+	entry, err := NewCacheEntryFromResponse(key, resp, ttl)
+	if err != nil {
+		return
 	}
-
-	return cloned
+	if req.CacheKey == "" {
+		entry.Check = defaultCacheKeyBuilder.Check(req.Request)
+	}
+	data, err := c.cacheCodec.Encode(entry)
+	if err != nil {
+		return
+	}
+	c.cache.SetWithTTL(key, data, ttl)
 }
 
 // isCacheable determines if a response should be cached
@@ -452,23 +537,47 @@ func (c *OptimizedClient) shouldRetry(err error, attempt int) bool {
 		return false
 	}
 
-	// Retry on timeout and connection errors
-	switch err.(type) {
-	case *tls.CertificateVerificationError:
-		return false // Don't retry certificate errors
+	// An open breaker fails fast; retrying would only add load to the upstream
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrHalfOpenLimitExceeded) {
+		return false
+	}
+
+	// Retry on timeout and connection errors, but not on handshake or
+	// certificate failures, which fail the same way every time
+	switch ClassifyRequestError(err) {
+	case ErrorTypeTLSCertificate, ErrorTypeTLSHandshake:
+		return false
 	default:
-		return true // Retry other errors
+		return true
 	}
 }
 
-// retryRequest performs request retry with exponential backoff
-func (c *OptimizedClient) retryRequest(req *OptimizedRequest, response *OptimizedResponse, attempt int) (*OptimizedResponse, error) {
-	// Exponential backoff
-	backoff := time.Duration(attempt) * c.config.RetryBackoff
-	time.Sleep(backoff)
+// doWithRetries sends req, retrying failures up to MaxRetries times with a
+// growing backoff. The last error is returned as is
+func (c *OptimizedClient) doWithRetries(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.http2Client.Do(req)
+		if err == nil || !c.shouldRetry(err, attempt) {
+			return resp, err
+		}
+
+		// A consumed body can only be resent if it can be rewound
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
 
-	// Retry the request
-	return c.Do(req)
+		backoff := time.Duration(attempt+1) * c.config.RetryBackoff
+		if sleepErr := sleepContext(req.Context(), backoff); sleepErr != nil {
+			return resp, err
+		}
+	}
 }
 
 // recordRequest records metrics for a completed request
@@ -514,7 +623,7 @@ func (c *OptimizedClient) recordCacheHit(req *OptimizedRequest, resp *OptimizedR
 
 // generateCacheKey creates a cache key from an HTTP request
 func generateCacheKey(req *http.Request) string {
-	return fmt.Sprintf("%s:%s", req.Method, req.URL.String())
+	return defaultCacheKeyBuilder.Key(req, nil)
 }
 
 // WarmupCache performs cache warming with common URLs
@@ -558,6 +667,40 @@ func (c *OptimizedClient) WarmupCache(urls []string) error {
 	return nil
 }
 
+// WarmupFromAnalytics prefetches the topN URLs an apilo daemon's /analytics
+// ranks hottest, and returns how many were fetched. They go through Do so
+// they are cached under the keys later requests look up
+func (c *OptimizedClient) WarmupFromAnalytics(ctx context.Context, analyticsURL string, topN int) (int, error) {
+	if c.cache == nil {
+		return 0, fmt.Errorf("caching not enabled")
+	}
+
+	// A dry run only ranks the URLs, leaving the fetching to the client
+	historical := NewHistoricalWarmup(&DaemonAnalyticsSource{BaseURL: analyticsURL}, topN, 0)
+	historical.SetDryRun(true)
+	historical.SetDefaultTTL(c.config.CacheConfig.DefaultTTL)
+	if err := historical.Warmup(ctx, nil); err != nil {
+		return 0, err
+	}
+
+	warmed := 0
+	var firstErr error
+	for _, target := range historical.Planned() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+		if err == nil {
+			var resp *OptimizedResponse
+			if resp, err = c.Do(&OptimizedRequest{Request: req, CacheTTL: target.TTL, UseCache: true}); err == nil {
+				resp.Body.Close()
+				warmed++
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("prefetch %s: %w", target.URL, err)
+		}
+	}
+	return warmed, firstErr
+}
+
 // GetStats returns current client performance statistics
 func (c *OptimizedClient) GetStats() *OptimizedClientStats {
 	c.mu.RLock()
@@ -569,10 +712,18 @@ func (c *OptimizedClient) GetStats() *OptimizedClientStats {
 		CacheMisses:     c.cacheMisses,
 		ConnectionReuse: c.connectionReuse,
 		Errors:          c.errors,
+		ErrorTypes:      make(map[string]int64, len(c.errorTypes)),
 		Initialized:     c.initialized,
 		WarmedUp:        c.warmedUp,
 	}
 
+	for errorType, count := range c.errorTypes {
+		stats.ErrorTypes[errorType] = count
+	}
+	if c.http2Client != nil {
+		stats.HTTP2Events = c.http2Client.HTTP2Events()
+	}
+
 	if c.requestCount > 0 {
 		stats.AverageLatency = c.totalLatency / time.Duration(c.requestCount)
 		stats.CacheHitRatio = float64(c.cacheHits) / float64(c.cacheHits+c.cacheMisses)
@@ -585,17 +736,21 @@ func (c *OptimizedClient) GetStats() *OptimizedClientStats {
 
 // OptimizedClientStats contains performance statistics
 type OptimizedClientStats struct {
-	TotalRequests        int64         `json:"total_requests"`
-	CacheHits            int64         `json:"cache_hits"`
-	CacheMisses          int64         `json:"cache_misses"`
-	ConnectionReuse      int64         `json:"connection_reuse"`
-	Errors               int64         `json:"errors"`
-	AverageLatency       time.Duration `json:"average_latency"`
-	CacheHitRatio        float64       `json:"cache_hit_ratio"`
-	ConnectionReuseRatio float64       `json:"connection_reuse_ratio"`
-	ErrorRate            float64       `json:"error_rate"`
-	Initialized          bool          `json:"initialized"`
-	WarmedUp             bool          `json:"warmed_up"`
+	TotalRequests        int64            `json:"total_requests"`
+	CacheHits            int64            `json:"cache_hits"`
+	CacheMisses          int64            `json:"cache_misses"`
+	ConnectionReuse      int64            `json:"connection_reuse"`
+	Errors               int64            `json:"errors"`
+	ErrorTypes           map[string]int64 `json:"error_types,omitempty"` // Errors by ClassifyRequestError class
+	AverageLatency       time.Duration    `json:"average_latency"`
+	CacheHitRatio        float64          `json:"cache_hit_ratio"`
+	ConnectionReuseRatio float64          `json:"connection_reuse_ratio"`
+	ErrorRate            float64          `json:"error_rate"`
+	Initialized          bool             `json:"initialized"`
+	WarmedUp             bool             `json:"warmed_up"`
+
+	// GOAWAYs and stream resets from upstreams, nil if there were none
+	HTTP2Events *HTTP2EventStats `json:"http2_events,omitempty"`
 }
 
 // Stop gracefully shuts down the optimized client
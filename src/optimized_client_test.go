@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCachedClient returns a client without monitoring holding a cached
+// response to req
+func newCachedClient(tb testing.TB, req *OptimizedRequest) *OptimizedClient {
+	tb.Helper()
+	config := DefaultOptimizedClientConfig()
+	config.MonitoringConfig.Enabled = false
+	client, err := NewOptimizedClient(config)
+	if err != nil {
+		tb.Fatalf("Failed to create client: %v", err)
+	}
+	tb.Cleanup(func() { client.Stop() })
+
+	client.cacheResponse(req, &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"id":1}`)),
+	})
+	return client
+}
+
+// TestTryCacheKeyCollision tests that an entry stored for another request
+// under the same generated key is not served
+func TestTryCacheKeyCollision(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "https://api.example.com/users?id=1", nil)
+	req := &OptimizedRequest{Request: httpReq, UseCache: true}
+	client := newCachedClient(t, req)
+
+	if hit := client.tryCache(req, &OptimizedResponse{}); hit == nil {
+		t.Fatal("Expected the cached response for the same request")
+	}
+
+	// Rewrite the entry as if a different request had produced it
+	key := generateCacheKey(httpReq)
+	cached, _, _ := client.cache.GetWithAge(key)
+	entry, err := client.cacheCodec.Decode(cached.([]byte))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	other, _ := http.NewRequest("GET", "https://api.example.com/users?id=2", nil)
+	entry.Check = defaultCacheKeyBuilder.Check(other)
+	data, _ := client.cacheCodec.Encode(entry)
+	client.cache.SetWithTTL(key, data, time.Minute)
+
+	if hit := client.tryCache(req, &OptimizedResponse{}); hit != nil {
+		t.Error("Expected no hit for an entry whose check belongs to another request")
+	}
+}
+
+// BenchmarkTryCacheHit benchmarks serving a cached response, including the
+// key collision check
+func BenchmarkTryCacheHit(b *testing.B) {
+	httpReq, _ := http.NewRequest("GET", "https://api.example.com/v1/items?page=2&sort=name", nil)
+	req := &OptimizedRequest{Request: httpReq, UseCache: true}
+	client := newCachedClient(b, req)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if client.tryCache(req, &OptimizedResponse{}) == nil {
+			b.Fatal("Expected a cache hit")
+		}
+	}
+}
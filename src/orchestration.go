@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"api-latency-optimizer/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Outcomes of an orchestrated suite
+const (
+	SuitePassed  = "passed"
+	SuiteFailed  = "failed"
+	SuiteSkipped = "skipped"
+)
+
+// OrchestrationConfig declares several suites to run in dependency order,
+// e.g. a smoke suite gating a heavy one
+type OrchestrationConfig struct {
+	Name      string `yaml:"name" json:"name"`
+	OutputDir string `yaml:"output_dir" json:"output_dir"`
+
+	// Baseline every suite is compared against unless it names its own
+	Baseline string `yaml:"baseline" json:"baseline,omitempty"`
+
+	Suites []OrchestratedSuite `yaml:"suites" json:"suites"`
+}
+
+// OrchestratedSuite is a suite file and when it runs. A suite runs only if
+// every suite it depends on passed; it passes if every run produced results
+// within Budgets
+type OrchestratedSuite struct {
+	Name      string   `yaml:"name" json:"name"`
+	Config    string   `yaml:"config" json:"config"` // Suite YAML, relative to the orchestration file
+	DependsOn []string `yaml:"depends_on" json:"depends_on,omitempty"`
+	Budgets   string   `yaml:"budgets" json:"budgets,omitempty"` // As for -budget
+	Baseline  string   `yaml:"baseline" json:"baseline,omitempty"`
+
+	// A failure skips every suite not yet run, not just dependents
+	AbortOnFailure bool `yaml:"abort_on_failure" json:"abort_on_failure,omitempty"`
+
+	budgets []PerformanceBudget
+}
+
+// SuiteOutcome is what became of one orchestrated suite
+type SuiteOutcome struct {
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	Reason    string         `json:"reason,omitempty"` // Why it failed or was skipped
+	SuiteID   string         `json:"suite_id,omitempty"`
+	ResultDir string         `json:"result_dir,omitempty"`
+	Baseline  string         `json:"baseline,omitempty"`
+	Duration  time.Duration  `json:"duration"`
+	Runs      []RunOutcome   `json:"runs,omitempty"`
+	Budgets   []BudgetResult `json:"budgets,omitempty"`
+}
+
+// RunOutcome holds a run's figures for the aggregated report
+type RunOutcome struct {
+	Name      string  `json:"name"`
+	RPS       float64 `json:"rps"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	ErrorRate float64 `json:"error_rate"` // Percentage of requests
+	Requests  int     `json:"requests"`
+}
+
+// OrchestrationResult is the outcome of every suite, in the order they ran
+type OrchestrationResult struct {
+	Name      string         `json:"name"`
+	StartedAt time.Time      `json:"started_at"`
+	Duration  time.Duration  `json:"duration"`
+	Passed    bool           `json:"passed"`
+	Suites    []SuiteOutcome `json:"suites"`
+	Metadata  *RunMetadata   `json:"metadata,omitempty"`
+}
+
+// LoadOrchestration reads and validates an orchestration file. Suite and
+// baseline paths are resolved relative to it
+func LoadOrchestration(path string) (*OrchestrationConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orchestration: %w", err)
+	}
+	var orchestration OrchestrationConfig
+	if err := yaml.Unmarshal(data, &orchestration); err != nil {
+		return nil, fmt.Errorf("failed to parse orchestration: %w", err)
+	}
+	if orchestration.Name == "" {
+		orchestration.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if orchestration.OutputDir == "" {
+		orchestration.OutputDir = "./benchmarks/results"
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	orchestration.Baseline = resolve(orchestration.Baseline)
+	for i := range orchestration.Suites {
+		suite := &orchestration.Suites[i]
+		suite.Config = resolve(suite.Config)
+		suite.Baseline = resolve(suite.Baseline)
+	}
+
+	if err := orchestration.validate(); err != nil {
+		return nil, err
+	}
+	return &orchestration, nil
+}
+
+// validate checks names, suite files, budgets and dependencies
+func (o *OrchestrationConfig) validate() error {
+	if len(o.Suites) == 0 {
+		return fmt.Errorf("orchestration %s declares no suites", o.Name)
+	}
+	names := make(map[string]bool, len(o.Suites))
+	for i := range o.Suites {
+		suite := &o.Suites[i]
+		if suite.Name == "" {
+			return fmt.Errorf("suite %d has no name", i+1)
+		}
+		if names[suite.Name] {
+			return fmt.Errorf("duplicate suite %q", suite.Name)
+		}
+		names[suite.Name] = true
+		if suite.Config == "" {
+			return fmt.Errorf("suite %q has no config", suite.Name)
+		}
+		budgets, err := ParseBudgets(suite.Budgets)
+		if err != nil {
+			return fmt.Errorf("suite %q: %w", suite.Name, err)
+		}
+		suite.budgets = budgets
+	}
+	for _, suite := range o.Suites {
+		for _, dependency := range suite.DependsOn {
+			if !names[dependency] {
+				return fmt.Errorf("suite %q depends on unknown suite %q", suite.Name, dependency)
+			}
+		}
+	}
+	_, err := o.order()
+	return err
+}
+
+// order returns the suites with every suite after its dependencies,
+// otherwise keeping the order they are declared in
+func (o *OrchestrationConfig) order() ([]*OrchestratedSuite, error) {
+	done := make(map[string]bool, len(o.Suites))
+	ordered := make([]*OrchestratedSuite, 0, len(o.Suites))
+	for len(ordered) < len(o.Suites) {
+		progressed := false
+		for i := range o.Suites {
+			suite := &o.Suites[i]
+			if done[suite.Name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range suite.DependsOn {
+				ready = ready && done[dependency]
+			}
+			if ready {
+				done[suite.Name] = true
+				ordered = append(ordered, suite)
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, suite := range o.Suites {
+				if !done[suite.Name] {
+					cycle = append(cycle, suite.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among suites %s", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// LoadSuiteConfig reads a suite YAML file, as in config/benchmark_config.yaml
+func LoadSuiteConfig(path string) (*BenchmarkSuite, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+
+	suite := &BenchmarkSuite{
+		Name:               cfg.Name,
+		Description:        cfg.Description,
+		OutputDir:          cfg.OutputDir,
+		ComparisonBaseline: cfg.ComparisonBaseline,
+	}
+	for _, run := range cfg.Runs {
+		loadPattern := LoadPattern(run.LoadPattern)
+		if loadPattern == "" {
+			loadPattern = LoadPatternConstant
+		}
+		var script *RequestScriptConfig
+		if s := run.Config.Script; s != nil {
+			script = &RequestScriptConfig{File: s.File, Source: s.Source, Vars: s.Vars, Timeout: s.Timeout.Duration}
+			if script.File != "" && !filepath.IsAbs(script.File) {
+				script.File = filepath.Join(filepath.Dir(path), script.File)
+			}
+		}
+		var data *DataFeedConfig
+		if d := run.Config.Data; d != nil {
+			data = &DataFeedConfig{File: d.File, Shuffle: d.Shuffle, Seed: d.Seed}
+			if !filepath.IsAbs(data.File) {
+				data.File = filepath.Join(filepath.Dir(path), data.File)
+			}
+		}
+		suite.Runs = append(suite.Runs, BenchmarkRun{
+			Name: run.Name,
+			Config: BenchmarkConfig{
+				TargetURL:     run.Config.TargetURL,
+				TotalRequests: run.Config.TotalRequests,
+				Concurrency:   run.Config.Concurrency,
+				Timeout:       run.Config.Timeout.Duration,
+				KeepAlive:     run.Config.KeepAlive,
+				Method:        run.Config.Method,
+				CustomHeaders: run.Config.CustomHeaders,
+				Body:          []byte(run.Config.Body),
+				Script:        script,
+				Data:          data,
+				Seed:          run.Config.Seed,
+			},
+			Iterations:       run.Iterations,
+			WarmupIterations: run.WarmupIterations,
+			LoadPattern:      loadPattern,
+		})
+	}
+	return suite, nil
+}
+
+// Orchestrator runs the suites of an orchestration and reports on all of them
+type Orchestrator struct {
+	config    *OrchestrationConfig
+	resultDir string
+}
+
+// NewOrchestrator creates an orchestrator writing under a new timestamped
+// directory of the orchestration's output directory
+func NewOrchestrator(orchestration *OrchestrationConfig) *Orchestrator {
+	resultDir := uniqueDir(filepath.Join(orchestration.OutputDir, fmt.Sprintf("%s_%s",
+		idSlug(orchestration.Name), time.Now().Format("20060102_150405"))))
+	return &Orchestrator{config: orchestration, resultDir: resultDir}
+}
+
+// Run runs every suite whose dependencies passed, in dependency order, and
+// writes the aggregated report. Cancelling ctx skips the suites not yet run
+func (o *Orchestrator) Run(ctx context.Context) (*OrchestrationResult, error) {
+	ordered, err := o.config.order()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(o.resultDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create result directory: %w", err)
+	}
+
+	result := &OrchestrationResult{
+		Name:      o.config.Name,
+		StartedAt: time.Now(),
+		Passed:    true,
+		Metadata:  CurrentRunMetadata(),
+	}
+	fmt.Printf("\n=== Starting Orchestration: %s (%d suites) ===\n", o.config.Name, len(ordered))
+	fmt.Printf("Output Directory: %s\n", o.resultDir)
+
+	statuses := make(map[string]string, len(ordered))
+	var abortedBy string
+	for _, suite := range ordered {
+		var outcome SuiteOutcome
+		switch skip := o.skipReason(ctx, suite, statuses, abortedBy); {
+		case skip != "":
+			outcome = SuiteOutcome{Name: suite.Name, Status: SuiteSkipped, Reason: skip}
+			fmt.Printf("\n>>> Suite %s skipped: %s\n", suite.Name, skip)
+		default:
+			fmt.Printf("\n>>> Suite %s (%s)\n", suite.Name, suite.Config)
+			outcome = o.runSuite(ctx, suite)
+		}
+
+		statuses[suite.Name] = outcome.Status
+		if outcome.Status != SuitePassed {
+			result.Passed = false
+		}
+		if outcome.Status == SuiteFailed {
+			fmt.Printf("<<< Suite %s failed: %s\n", suite.Name, outcome.Reason)
+			if suite.AbortOnFailure && abortedBy == "" {
+				abortedBy = suite.Name
+			}
+		} else if outcome.Status == SuitePassed {
+			fmt.Printf("<<< Suite %s passed\n", suite.Name)
+		}
+		result.Suites = append(result.Suites, outcome)
+	}
+	result.Duration = time.Since(result.StartedAt)
+
+	if err := o.saveResult(result); err != nil {
+		fmt.Printf("WARNING: Failed to save orchestration results: %v\n", err)
+	}
+	reportPath := filepath.Join(o.resultDir, "ORCHESTRATION_REPORT.md")
+	if err := os.WriteFile(reportPath, []byte(orchestrationReport(result)), 0644); err != nil {
+		fmt.Printf("WARNING: Failed to write orchestration report: %v\n", err)
+	}
+	printOrchestrationResult(result)
+	fmt.Printf("Report: %s\n", reportPath)
+
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("orchestration interrupted: %w", ctx.Err())
+	}
+	return result, nil
+}
+
+// skipReason says why suite must not run, or returns "" if it may
+func (o *Orchestrator) skipReason(ctx context.Context, suite *OrchestratedSuite, statuses map[string]string, abortedBy string) string {
+	if ctx.Err() != nil {
+		return "orchestration interrupted"
+	}
+	if abortedBy != "" {
+		return fmt.Sprintf("aborted after %s failed", abortedBy)
+	}
+	for _, dependency := range suite.DependsOn {
+		if statuses[dependency] != SuitePassed {
+			return fmt.Sprintf("dependency %s %s", dependency, statuses[dependency])
+		}
+	}
+	return ""
+}
+
+// runSuite loads, runs and judges one suite, comparing it with its baseline
+func (o *Orchestrator) runSuite(ctx context.Context, orchestrated *OrchestratedSuite) SuiteOutcome {
+	outcome := SuiteOutcome{Name: orchestrated.Name, Status: SuiteFailed}
+	started := time.Now()
+	defer func() { outcome.Duration = time.Since(started) }()
+
+	suite, err := LoadSuiteConfig(orchestrated.Config)
+	if err != nil {
+		outcome.Reason = err.Error()
+		return outcome
+	}
+	suite.OutputDir = o.resultDir
+	suite.Budgets = orchestrated.budgets
+
+	runner := NewBenchmarkRunner(suite)
+	outcome.SuiteID = suite.ID
+	outcome.ResultDir = runner.resultDir
+	runErr := runner.Run(ctx)
+
+	for i := range suite.Runs {
+		run := &suite.Runs[i]
+		summary := summarizeRun(run)
+		outcome.Runs = append(outcome.Runs, RunOutcome{
+			Name:      run.Name,
+			RPS:       summary.RPS,
+			P50Ms:     summary.P50,
+			P95Ms:     summary.P95,
+			P99Ms:     summary.P99,
+			ErrorRate: summary.ErrorRate,
+			Requests:  summary.Requests,
+		})
+	}
+	outcome.Budgets = EvaluateBudgets(suite)
+
+	if baseline := orchestrated.Baseline; baseline != "" || o.config.Baseline != "" {
+		if baseline == "" {
+			baseline = o.config.Baseline
+		}
+		outcome.Baseline = baseline
+		if err := runner.CompareWithBaseline(baseline); err != nil {
+			fmt.Printf("WARNING: Comparison with %s failed: %v\n", baseline, err)
+		}
+	}
+
+	var reasons []string
+	if runErr != nil {
+		reasons = append(reasons, runErr.Error())
+	}
+	for _, run := range suite.Runs {
+		if !runFinished(&run) {
+			reasons = append(reasons, fmt.Sprintf("run %s did not complete", run.Name))
+		}
+	}
+	for _, budget := range outcome.Budgets {
+		if !budget.Passed {
+			reasons = append(reasons, fmt.Sprintf("%s: %s (got %s)", budget.Run,
+				budgetLabel(budget.Budget), formatBudgetValue(budget.Budget.Metric, budget.Actual)))
+		}
+	}
+	if len(reasons) > 0 {
+		outcome.Reason = strings.Join(reasons, "; ")
+		return outcome
+	}
+	outcome.Status = SuitePassed
+	return outcome
+}
+
+// saveResult writes orchestration_results.json
+func (o *Orchestrator) saveResult(result *OrchestrationResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(o.resultDir, "orchestration_results.json"), data, 0644)
+}
+
+// printOrchestrationResult prints one line per suite
+func printOrchestrationResult(result *OrchestrationResult) {
+	fmt.Printf("\n=== Orchestration %s: ", result.Name)
+	if result.Passed {
+		fmt.Printf("PASSED ===\n")
+	} else {
+		fmt.Printf("FAILED ===\n")
+	}
+	for _, suite := range result.Suites {
+		fmt.Printf("  %-8s %-24s %v", strings.ToUpper(suite.Status), suite.Name, suite.Duration.Round(time.Second))
+		if suite.Reason != "" {
+			fmt.Printf("  %s", suite.Reason)
+		}
+		fmt.Println()
+	}
+}
+
+// orchestrationReport renders the aggregated report across suites as markdown
+func orchestrationReport(result *OrchestrationResult) string {
+	status := "✅ Passed"
+	if !result.Passed {
+		status = "❌ Failed"
+	}
+	report := fmt.Sprintf("# Orchestration Report: %s\n\n", result.Name)
+	report += fmt.Sprintf("**Status:** %s  \n", status)
+	report += fmt.Sprintf("**Started:** %s  \n", result.StartedAt.Format(time.RFC3339))
+	report += fmt.Sprintf("**Duration:** %v\n", result.Duration.Round(time.Second))
+	if result.Metadata != nil {
+		report += fmt.Sprintf("**Code:** %s\n", result.Metadata)
+	}
+	report += "\n## Suites\n\n"
+	report += "| Suite | Status | Duration | Budgets | Notes |\n"
+	report += "|-------|--------|----------|---------|-------|\n"
+	for _, suite := range result.Suites {
+		passed := 0
+		for _, budget := range suite.Budgets {
+			if budget.Passed {
+				passed++
+			}
+		}
+		budgets := "-"
+		if len(suite.Budgets) > 0 {
+			budgets = fmt.Sprintf("%d/%d", passed, len(suite.Budgets))
+		}
+		report += fmt.Sprintf("| %s | %s | %v | %s | %s |\n", suite.Name, suite.Status,
+			suite.Duration.Round(time.Second), budgets, strings.ReplaceAll(suite.Reason, "|", "\\|"))
+	}
+
+	report += "\n## Runs\n\n"
+	report += "| Suite | Run | Requests | RPS | P50 (ms) | P95 (ms) | P99 (ms) | Error Rate |\n"
+	report += "|-------|-----|----------|-----|----------|----------|----------|------------|\n"
+	for _, suite := range result.Suites {
+		for _, run := range suite.Runs {
+			report += fmt.Sprintf("| %s | %s | %d | %.2f | %.2f | %.2f | %.2f | %.2f%% |\n",
+				suite.Name, run.Name, run.Requests, run.RPS, run.P50Ms, run.P95Ms, run.P99Ms, run.ErrorRate)
+		}
+	}
+
+	report += "\n## Results\n\n"
+	for _, suite := range result.Suites {
+		if suite.ResultDir == "" {
+			continue
+		}
+		report += fmt.Sprintf("- **%s:** `%s`", suite.Name, suite.ResultDir)
+		if suite.Baseline != "" {
+			report += fmt.Sprintf(", compared with `%s` in COMPARISON.md", suite.Baseline)
+		}
+		report += "\n"
+	}
+	return report
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeOrchestration writes a suite file per name, each with one small run
+// against target, and the orchestration itself
+func writeOrchestration(t *testing.T, target string, suites []string, orchestration string) string {
+	dir := t.TempDir()
+	for _, name := range suites {
+		suite := fmt.Sprintf(`name: %s
+runs:
+  - name: api
+    config:
+      target_url: %q
+      total_requests: 5
+      concurrency: 1
+      timeout: 5s
+    iterations: 1
+`, name, target)
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(suite), 0644); err != nil {
+			t.Fatalf("Failed to write suite: %v", err)
+		}
+	}
+	path := filepath.Join(dir, "orchestration.yaml")
+	orchestration = strings.ReplaceAll(orchestration, "OUTPUT", filepath.Join(dir, "results"))
+	if err := os.WriteFile(path, []byte(orchestration), 0644); err != nil {
+		t.Fatalf("Failed to write orchestration: %v", err)
+	}
+	return path
+}
+
+// TestOrchestratorDependencies tests that a failed suite skips its dependents
+// while independent suites still run and are compared with the shared baseline
+func TestOrchestratorDependencies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := writeOrchestration(t, server.URL, []string{"smoke", "heavy", "other"}, `
+name: release
+output_dir: OUTPUT
+baseline: baseline.json
+suites:
+  - name: heavy
+    config: heavy.yaml
+    depends_on: [smoke]
+  - name: smoke
+    config: smoke.yaml
+    budgets: p95=0.000001ms
+  - name: other
+    config: other.yaml
+    budgets: error_rate=1%
+`)
+	baseline, _ := json.Marshal(BenchmarkSuite{Name: "baseline", Runs: []BenchmarkRun{{
+		Name:    "api",
+		Results: []*BenchmarkResult{{TotalRequests: 5, SuccessfulReqs: 5, LatencyStats: LatencyStats{P95: 1}}},
+	}}})
+	os.WriteFile(filepath.Join(filepath.Dir(path), "baseline.json"), baseline, 0644)
+
+	orchestration, err := LoadOrchestration(path)
+	if err != nil {
+		t.Fatalf("Failed to load orchestration: %v", err)
+	}
+	orchestrator := NewOrchestrator(orchestration)
+	result, err := orchestrator.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Orchestration failed to run: %v", err)
+	}
+
+	if result.Passed || len(result.Suites) != 3 {
+		t.Fatalf("Expected a failed orchestration of 3 suites, got %+v", result)
+	}
+	// heavy is declared first but waits for smoke
+	want := []struct{ name, status, reason string }{
+		{"smoke", SuiteFailed, "api: P95 <= 0.00 ms"},
+		{"heavy", SuiteSkipped, "dependency smoke failed"},
+		{"other", SuitePassed, ""},
+	}
+	for i, w := range want {
+		got := result.Suites[i]
+		if got.Name != w.name || got.Status != w.status || !strings.Contains(got.Reason, w.reason) {
+			t.Errorf("Suite %d: expected %s %s (%q), got %s %s (%q)", i, w.name, w.status, w.reason, got.Name, got.Status, got.Reason)
+		}
+	}
+
+	other := result.Suites[2]
+	if len(other.Runs) != 1 || other.Runs[0].Requests != 5 || len(other.Budgets) != 1 {
+		t.Errorf("Expected the run and budget of other, got %+v", other)
+	}
+	if _, err := os.Stat(filepath.Join(other.ResultDir, "COMPARISON.md")); err != nil {
+		t.Errorf("Expected a comparison with the shared baseline: %v", err)
+	}
+	if !strings.HasPrefix(other.ResultDir, orchestrator.resultDir) {
+		t.Errorf("Expected suite results under %s, got %s", orchestrator.resultDir, other.ResultDir)
+	}
+
+	report, err := os.ReadFile(filepath.Join(orchestrator.resultDir, "ORCHESTRATION_REPORT.md"))
+	if err != nil {
+		t.Fatalf("Expected an aggregated report: %v", err)
+	}
+	for _, row := range []string{"| smoke | failed |", "| heavy | skipped |", "| other | passed |", "| other | api | 5 |"} {
+		if !strings.Contains(string(report), row) {
+			t.Errorf("Expected %q in the report:\n%s", row, report)
+		}
+	}
+}
+
+// TestOrchestratorAbortOnFailure tests that an aborting failure skips
+// every remaining suite, dependent or not
+func TestOrchestratorAbortOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := writeOrchestration(t, server.URL, []string{"smoke", "other"}, `
+output_dir: OUTPUT
+suites:
+  - name: smoke
+    config: smoke.yaml
+    budgets: p95=0.000001ms
+    abort_on_failure: true
+  - name: other
+    config: other.yaml
+`)
+	orchestration, err := LoadOrchestration(path)
+	if err != nil {
+		t.Fatalf("Failed to load orchestration: %v", err)
+	}
+	result, err := NewOrchestrator(orchestration).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Orchestration failed to run: %v", err)
+	}
+	if result.Name != "orchestration" || result.Suites[1].Status != SuiteSkipped || result.Suites[1].Reason != "aborted after smoke failed" {
+		t.Errorf("Expected other skipped after smoke aborted, got %+v", result.Suites)
+	}
+}
+
+// TestLoadOrchestrationInvalid tests rejected orchestration files
+func TestLoadOrchestrationInvalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		orchestration string
+		want          string
+	}{
+		{"no suites", "name: empty\n", "declares no suites"},
+		{"duplicate", "suites:\n  - {name: a, config: a.yaml}\n  - {name: a, config: b.yaml}\n", `duplicate suite "a"`},
+		{"unknown dependency", "suites:\n  - {name: a, config: a.yaml, depends_on: [b]}\n", `unknown suite "b"`},
+		{"cycle", "suites:\n  - {name: a, config: a.yaml, depends_on: [b]}\n  - {name: b, config: b.yaml, depends_on: [a]}\n  - {name: c, config: c.yaml}\n", "dependency cycle among suites a, b"},
+		{"bad budget", "suites:\n  - {name: a, config: a.yaml, budgets: p95}\n", "expected METRIC=LIMIT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "orchestration.yaml")
+			os.WriteFile(path, []byte(tt.orchestration), 0644)
+			_, err := LoadOrchestration(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// fuzzConfigSeeds returns the repo's example configs as fuzz seeds
+func fuzzConfigSeeds(f *testing.F) {
+	paths, _ := filepath.Glob("../config/*.yaml")
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			f.Add(data)
+		}
+	}
+	f.Add([]byte("name: a\nruns:\n  - name: r\n    config: {target_url: x, timeout: 1x}\n"))
+	f.Add([]byte("suites:\n  - {name: a, config: a.yaml, depends_on: [a], budgets: p95=}\n"))
+}
+
+// FuzzLoadSuiteConfig tests that malformed suite YAML is rejected rather
+// than panicking
+func FuzzLoadSuiteConfig(f *testing.F) {
+	fuzzConfigSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "suite.yaml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		suite, err := LoadSuiteConfig(path)
+		if err == nil && len(suite.Runs) == 0 {
+			t.Error("Expected a valid suite to have runs")
+		}
+	})
+}
+
+// FuzzLoadOrchestration tests that malformed orchestration YAML, including
+// dependency cycles, is rejected rather than panicking or hanging
+func FuzzLoadOrchestration(f *testing.F) {
+	fuzzConfigSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "orchestration.yaml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		orchestration, err := LoadOrchestration(path)
+		if err != nil {
+			return
+		}
+		if ordered, err := orchestration.order(); err != nil || len(ordered) != len(orchestration.Suites) {
+			t.Errorf("Expected a valid orchestration to order all %d suites, got %d (%v)", len(orchestration.Suites), len(ordered), err)
+		}
+	})
+}
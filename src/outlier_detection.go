@@ -0,0 +1,413 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OutlierDetectionConfig configures passive outlier ejection across endpoints
+type OutlierDetectionConfig struct {
+	WindowSize         int           `yaml:"window_size"`          // Recent calls kept per endpoint
+	MinRequests        int           `yaml:"min_requests"`         // Calls needed before an endpoint is judged
+	EvaluationInterval time.Duration `yaml:"evaluation_interval"`  // How often peers are compared
+	ErrorRateDeviation float64       `yaml:"error_rate_deviation"` // Eject when error rate exceeds the peer median by this much
+	LatencyFactor      float64       `yaml:"latency_factor"`       // Eject when P99 exceeds the peer median P99 by this factor
+	BaseEjectionTime   time.Duration `yaml:"base_ejection_time"`   // Cooldown, multiplied by the number of ejections
+	MaxEjectionTime    time.Duration `yaml:"max_ejection_time"`
+	EjectionDecay      time.Duration `yaml:"ejection_decay"`       // Healthy time that forgives one past ejection; zero never forgives
+	RampUpDuration     time.Duration `yaml:"ramp_up_duration"`     // Time to go from 0 to full traffic after a cooldown
+	MaxEjectionPercent float64       `yaml:"max_ejection_percent"` // Never eject more than this fraction of endpoints
+}
+
+// DefaultOutlierDetectionConfig returns conservative outlier detection defaults
+func DefaultOutlierDetectionConfig() *OutlierDetectionConfig {
+	return &OutlierDetectionConfig{
+		WindowSize:         100,
+		MinRequests:        20,
+		EvaluationInterval: 10 * time.Second,
+		ErrorRateDeviation: 0.3,
+		LatencyFactor:      3.0,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    5 * time.Minute,
+		EjectionDecay:      5 * time.Minute,
+		RampUpDuration:     time.Minute,
+		MaxEjectionPercent: 0.5,
+	}
+}
+
+// OutlierEvent describes an endpoint being ejected
+type OutlierEvent struct {
+	Endpoint      int           `json:"endpoint"`
+	Reason        string        `json:"reason"`
+	ErrorRate     float64       `json:"error_rate"`
+	PeerErrorRate float64       `json:"peer_error_rate"`
+	P99           time.Duration `json:"p99"`
+	PeerP99       time.Duration `json:"peer_p99"`
+	Ejections     int           `json:"ejections"`
+	EjectedUntil  time.Time     `json:"ejected_until"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+// OutlierStatus is a point-in-time view of one endpoint
+type OutlierStatus struct {
+	Endpoint     int           `json:"endpoint"`
+	Ejected      bool          `json:"ejected"`
+	Weight       float64       `json:"weight"`
+	Ejections    int           `json:"ejections"`
+	EjectedUntil time.Time     `json:"ejected_until,omitempty"`
+	Calls        int           `json:"calls"`
+	ErrorRate    float64       `json:"error_rate"`
+	P99          time.Duration `json:"p99"`
+}
+
+// endpointOutcomes keeps a ring of recent outcomes for one endpoint
+type endpointOutcomes struct {
+	outcomes []callOutcome
+	next     int
+	filled   bool
+
+	ejections    int
+	ejectedUntil time.Time
+	healthySince time.Time // When the endpoint was last forgiven an ejection, if after ejectedUntil
+}
+
+// OutlierDetector compares endpoints against their peers and temporarily
+// ejects the ones whose error rate or tail latency stands out
+type OutlierDetector struct {
+	config    *OutlierDetectionConfig
+	endpoints []*endpointOutcomes
+	lastEval  time.Time
+	now       func() time.Time
+
+	onEject      func(event OutlierEvent)
+	alertManager *AlertManager
+
+	mu sync.Mutex
+}
+
+// NewOutlierDetector creates a detector for count endpoints
+func NewOutlierDetector(count int, config *OutlierDetectionConfig) *OutlierDetector {
+	if config == nil {
+		config = DefaultOutlierDetectionConfig()
+	}
+	if config.WindowSize <= 0 {
+		config.WindowSize = 100
+	}
+
+	endpoints := make([]*endpointOutcomes, count)
+	for i := range endpoints {
+		endpoints[i] = &endpointOutcomes{outcomes: make([]callOutcome, config.WindowSize)}
+	}
+
+	return &OutlierDetector{
+		config:    config,
+		endpoints: endpoints,
+		now:       time.Now,
+	}
+}
+
+// SetOnEject registers a callback fired for each ejection
+func (od *OutlierDetector) SetOnEject(callback func(event OutlierEvent)) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	od.onEject = callback
+}
+
+// AttachAlertManager raises an outlier_ejection alert whenever an endpoint is ejected
+func (od *OutlierDetector) AttachAlertManager(am *AlertManager) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	od.alertManager = am
+}
+
+// Record adds the outcome of a call to endpoint and re-evaluates peers when due
+func (od *OutlierDetector) Record(endpoint int, latency time.Duration, err error) {
+	od.mu.Lock()
+
+	if endpoint < 0 || endpoint >= len(od.endpoints) {
+		od.mu.Unlock()
+		return
+	}
+
+	e := od.endpoints[endpoint]
+	e.outcomes[e.next] = callOutcome{failed: err != nil, latency: latency}
+	e.next = (e.next + 1) % len(e.outcomes)
+	if e.next == 0 {
+		e.filled = true
+	}
+
+	var events []OutlierEvent
+	now := od.now()
+	if now.Sub(od.lastEval) >= od.config.EvaluationInterval {
+		od.lastEval = now
+		events = od.evaluate(now)
+	}
+	onEject, am := od.onEject, od.alertManager
+	od.mu.Unlock()
+
+	for _, event := range events {
+		od.report(event, onEject, am)
+	}
+}
+
+// Evaluate compares all endpoints immediately and returns any new ejections
+func (od *OutlierDetector) Evaluate() []OutlierEvent {
+	od.mu.Lock()
+	now := od.now()
+	od.lastEval = now
+	events := od.evaluate(now)
+	onEject, am := od.onEject, od.alertManager
+	od.mu.Unlock()
+
+	for _, event := range events {
+		od.report(event, onEject, am)
+	}
+	return events
+}
+
+// evaluate ejects endpoints that deviate from the peer median; callers must hold od.mu
+func (od *OutlierDetector) evaluate(now time.Time) []OutlierEvent {
+	type sample struct {
+		index     int
+		errorRate float64
+		p99       time.Duration
+	}
+
+	var samples []sample
+	ejected := 0
+	for i, e := range od.endpoints {
+		if now.Before(e.ejectedUntil) {
+			ejected++
+			continue
+		}
+		e.decay(now, od.config.EjectionDecay)
+		if e.count() < od.config.MinRequests {
+			continue
+		}
+		samples = append(samples, sample{index: i, errorRate: e.errorRate(), p99: e.p99()})
+	}
+
+	maxEjected := int(float64(len(od.endpoints)) * od.config.MaxEjectionPercent)
+
+	var events []OutlierEvent
+	for _, s := range samples {
+		if ejected >= maxEjected {
+			break
+		}
+
+		// Compare against the median of every other eligible endpoint
+		peerErrors := make([]float64, 0, len(samples)-1)
+		peerP99s := make([]float64, 0, len(samples)-1)
+		for _, peer := range samples {
+			if peer.index != s.index {
+				peerErrors = append(peerErrors, peer.errorRate)
+				peerP99s = append(peerP99s, float64(peer.p99))
+			}
+		}
+		if len(peerErrors) == 0 {
+			break
+		}
+
+		peerErrorRate := median(peerErrors)
+		peerP99 := time.Duration(median(peerP99s))
+
+		reason := ""
+		switch {
+		case od.config.ErrorRateDeviation > 0 && s.errorRate-peerErrorRate > od.config.ErrorRateDeviation:
+			reason = "error_rate"
+		case od.config.LatencyFactor > 0 && peerP99 > 0 && float64(s.p99) > float64(peerP99)*od.config.LatencyFactor:
+			reason = "latency"
+		default:
+			continue
+		}
+
+		e := od.endpoints[s.index]
+		e.ejections++
+		cooldown := od.config.BaseEjectionTime * time.Duration(e.ejections)
+		if od.config.MaxEjectionTime > 0 && cooldown > od.config.MaxEjectionTime {
+			cooldown = od.config.MaxEjectionTime
+		}
+		e.ejectedUntil = now.Add(cooldown)
+		e.reset()
+		ejected++
+
+		events = append(events, OutlierEvent{
+			Endpoint:      s.index,
+			Reason:        reason,
+			ErrorRate:     s.errorRate,
+			PeerErrorRate: peerErrorRate,
+			P99:           s.p99,
+			PeerP99:       peerP99,
+			Ejections:     e.ejections,
+			EjectedUntil:  e.ejectedUntil,
+			Timestamp:     now,
+		})
+	}
+
+	return events
+}
+
+// report logs an ejection and forwards it to the callback and alert manager
+func (od *OutlierDetector) report(event OutlierEvent, onEject func(OutlierEvent), am *AlertManager) {
+	log.Printf("Outlier detection: ejecting endpoint %d (%s: error rate %.2f vs %.2f, p99 %v vs %v) until %s",
+		event.Endpoint, event.Reason, event.ErrorRate, event.PeerErrorRate,
+		event.P99, event.PeerP99, event.EjectedUntil.Format(time.RFC3339))
+
+	if onEject != nil {
+		onEject(event)
+	}
+
+	if am != nil {
+		am.RaiseAlert(AlertRule{
+			Name:        "outlier_ejection",
+			Description: "Endpoint ejected as outlier",
+			Type:        AlertTypeOutlierEjection,
+			Threshold:   0,
+			Comparator:  "gt",
+			Severity:    AlertSeverityWarning,
+		}, float64(event.Endpoint))
+	}
+}
+
+// Weight returns the share of traffic endpoint should receive: 0 while
+// ejected, ramping linearly back to 1 after the cooldown
+func (od *OutlierDetector) Weight(endpoint int) float64 {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	if endpoint < 0 || endpoint >= len(od.endpoints) {
+		return 1
+	}
+	return od.weight(od.endpoints[endpoint], od.now())
+}
+
+// weight computes an endpoint's admission weight; callers must hold od.mu
+func (od *OutlierDetector) weight(e *endpointOutcomes, now time.Time) float64 {
+	if e.ejectedUntil.IsZero() {
+		return 1
+	}
+	if now.Before(e.ejectedUntil) {
+		return 0
+	}
+
+	elapsed := now.Sub(e.ejectedUntil)
+	if od.config.RampUpDuration <= 0 || elapsed >= od.config.RampUpDuration {
+		return 1
+	}
+	return float64(elapsed) / float64(od.config.RampUpDuration)
+}
+
+// Admit reports whether a call should be sent to endpoint, sampling its weight
+func (od *OutlierDetector) Admit(endpoint int) bool {
+	weight := od.Weight(endpoint)
+	if weight >= 1 {
+		return true
+	}
+	return rand.Float64() < weight
+}
+
+// Statuses returns the ejection state of every endpoint
+func (od *OutlierDetector) Statuses() []OutlierStatus {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	now := od.now()
+	statuses := make([]OutlierStatus, len(od.endpoints))
+	for i, e := range od.endpoints {
+		statuses[i] = OutlierStatus{
+			Endpoint:  i,
+			Ejected:   now.Before(e.ejectedUntil),
+			Weight:    od.weight(e, now),
+			Ejections: e.ejections,
+			Calls:     e.count(),
+			ErrorRate: e.errorRate(),
+			P99:       e.p99(),
+		}
+		if statuses[i].Ejected {
+			statuses[i].EjectedUntil = e.ejectedUntil
+		}
+	}
+	return statuses
+}
+
+// count returns the number of outcomes held
+func (e *endpointOutcomes) count() int {
+	if e.filled {
+		return len(e.outcomes)
+	}
+	return e.next
+}
+
+// errorRate returns the fraction of failed calls held
+func (e *endpointOutcomes) errorRate() float64 {
+	n := e.count()
+	if n == 0 {
+		return 0
+	}
+
+	failures := 0
+	for _, outcome := range e.outcomes[:n] {
+		if outcome.failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(n)
+}
+
+// p99 returns the 99th percentile latency of the calls held
+func (e *endpointOutcomes) p99() time.Duration {
+	n := e.count()
+	if n == 0 {
+		return 0
+	}
+
+	latencies := make([]time.Duration, n)
+	for i, outcome := range e.outcomes[:n] {
+		latencies[i] = outcome.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	return latencies[(n*99)/100]
+}
+
+// decay forgives one ejection for each full period the endpoint has stayed
+// healthy since its cooldown ended, so the cooldown of a later ejection no
+// longer grows with ones long past
+func (e *endpointOutcomes) decay(now time.Time, period time.Duration) {
+	if period <= 0 || e.ejections == 0 {
+		return
+	}
+
+	since := e.ejectedUntil
+	if e.healthySince.After(since) {
+		since = e.healthySince
+	}
+	periods := int(now.Sub(since) / period)
+	if periods <= 0 {
+		return
+	}
+
+	e.ejections = max(e.ejections-periods, 0)
+	e.healthySince = since.Add(time.Duration(periods) * period)
+}
+
+// reset clears the outcomes so a re-admitted endpoint is judged afresh
+func (e *endpointOutcomes) reset() {
+	e.next = 0
+	e.filled = false
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PrimeStats describes the setup done before an iteration's measurement
+// started; see BenchmarkConfig.PrimeConnections
+type PrimeStats struct {
+	DNS         float64 `json:"dns_ms"`      // Resolving the target host
+	Addresses   int     `json:"addresses"`   // Addresses the host resolved to
+	Connections int     `json:"connections"` // New connections left in the pool
+	Duration    float64 `json:"duration_ms"` // The whole phase
+	Errors      int     `json:"errors,omitempty"`
+	Error       string  `json:"error,omitempty"` // The first error, if any
+}
+
+// primeConnections resolves the target and opens up to Concurrency pooled
+// connections, completing their TCP and TLS handshakes, so measurement starts
+// with a warm pool. Each connection is opened by a HEAD request whose
+// response is discarded; HTTP/2 targets share one connection
+func (b *Benchmarker) primeConnections(ctx context.Context) *PrimeStats {
+	stats := &PrimeStats{}
+	start := time.Now()
+	defer func() { stats.Duration = msSince(start) }()
+
+	var mu sync.Mutex
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stats.Errors++
+		if stats.Error == "" {
+			stats.Error = err.Error()
+		}
+	}
+
+	// Go has no DNS cache of its own, so this mainly warms the system
+	// resolver's; the pooled connections below avoid lookups altogether
+	if parsed, err := url.Parse(b.requestURL); err == nil && b.config.UnixSocket == "" {
+		if host := parsed.Hostname(); host != "" && net.ParseIP(host) == nil {
+			dnsStart := time.Now()
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			stats.DNS = msSince(dnsStart)
+			stats.Addresses = len(addrs)
+			if err != nil {
+				fail(err)
+				return stats
+			}
+		}
+	}
+
+	// Connections are only pooled with keep-alive on
+	if !b.config.KeepAlive {
+		return stats
+	}
+
+	var opened atomic.Int64
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				opened.Add(1)
+			}
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodHead, b.requestURL, nil)
+			if err != nil {
+				fail(err)
+				return
+			}
+			for key, value := range b.config.CustomHeaders {
+				req.Header.Set(key, value)
+			}
+			b.config.HostOverride.apply(req)
+			resp, err := b.client.Do(req)
+			if err != nil {
+				fail(err)
+				return
+			}
+			// Draining lets the connection return to the pool
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	stats.Connections = int(opened.Load())
+	return stats
+}
+
+// msSince returns the milliseconds elapsed since start
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000.0
+}
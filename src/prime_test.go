@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPrimeConnections tests that priming opens the pool before measurement,
+// so no measured request pays for a TLS handshake
+func TestPrimeConnections(t *testing.T) {
+	var opened atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Holding each request keeps concurrent primers on separate connections
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	measure := func(prime bool) *BenchmarkResult {
+		opened.Store(0)
+		result, err := NewBenchmarker(BenchmarkConfig{
+			TargetURL:         server.URL,
+			TotalRequests:     20,
+			Concurrency:       4,
+			KeepAlive:         true,
+			IncludeRawMetrics: true,
+			PrimeConnections:  prime,
+			TLS:               &ClientTLSConfig{InsecureSkipVerify: true},
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Benchmark failed: %v", err)
+		}
+		return result
+	}
+	handshakes := func(result *BenchmarkResult) (n int) {
+		for _, metric := range result.RawMetrics {
+			if metric.TLSHandshake > 0 {
+				n++
+			}
+		}
+		return n
+	}
+
+	primed := measure(true)
+	if primed.Prime == nil || primed.Prime.Connections != 4 || primed.Prime.Errors != 0 {
+		t.Fatalf("Expected 4 primed connections, got %+v", primed.Prime)
+	}
+	if n := handshakes(primed); n != 0 {
+		t.Errorf("Expected no measured handshakes after priming, got %d", n)
+	}
+	if n := opened.Load(); n != 4 {
+		t.Errorf("Expected the server to see only the 4 primed connections, got %d", n)
+	}
+	// The IP literal needs no lookup
+	if primed.Prime.DNS != 0 {
+		t.Errorf("Expected no DNS lookup for %s, got %.2f ms", server.URL, primed.Prime.DNS)
+	}
+
+	cold := measure(false)
+	if cold.Prime != nil {
+		t.Errorf("Expected no prime stats when disabled, got %+v", cold.Prime)
+	}
+	if n := handshakes(cold); n == 0 {
+		t.Error("Expected measured handshakes without priming")
+	}
+}
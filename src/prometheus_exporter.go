@@ -12,6 +12,7 @@ import (
 // PrometheusExporter exports metrics in Prometheus format
 type PrometheusExporter struct {
 	port      int
+	listen    ListenOptions
 	path      string
 	collector *MetricsCollector
 
@@ -28,6 +29,14 @@ func NewPrometheusExporter(port int, path string) *PrometheusExporter {
 	}
 }
 
+// SetListenOptions sets the interface and socket options the exporter binds
+// with when it starts
+func (pe *PrometheusExporter) SetListenOptions(options ListenOptions) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.listen = options
+}
+
 // Start starts the Prometheus HTTP server
 func (pe *PrometheusExporter) Start(collector *MetricsCollector) error {
 	pe.mu.Lock()
@@ -43,13 +52,17 @@ func (pe *PrometheusExporter) Start(collector *MetricsCollector) error {
 	mux.HandleFunc(pe.path, pe.handleMetrics)
 	mux.HandleFunc("/", pe.handleIndex)
 
+	listener, err := listen("prometheus", pe.listen, pe.port)
+	if err != nil {
+		return err
+	}
 	pe.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", pe.port),
+		Addr:    listener.Addr().String(),
 		Handler: mux,
 	}
 
 	go func() {
-		if err := pe.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := pe.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			fmt.Printf("Prometheus exporter error: %v\n", err)
 		}
 	}()
@@ -153,6 +166,22 @@ func (pe *PrometheusExporter) handleMetrics(w http.ResponseWriter, r *http.Reque
 	pe.writeMetric(&sb, "ttfb_p99_milliseconds", "P99 time to first byte in milliseconds", "gauge",
 		snapshot.TTFBP99, nil)
 
+	// Latency phase metrics
+	pe.writeMetric(&sb, "dns_p95_milliseconds", "P95 DNS lookup time in milliseconds", "gauge",
+		snapshot.DNSP95, nil)
+	pe.writeMetric(&sb, "connect_p95_milliseconds", "P95 TCP connect time in milliseconds", "gauge",
+		snapshot.ConnectP95, nil)
+	pe.writeMetric(&sb, "tls_p95_milliseconds", "P95 TLS handshake time in milliseconds", "gauge",
+		snapshot.TLSP95, nil)
+	pe.writeMetric(&sb, "server_p50_milliseconds", "P50 server processing time in milliseconds", "gauge",
+		snapshot.ServerP50, nil)
+	pe.writeMetric(&sb, "server_p95_milliseconds", "P95 server processing time in milliseconds", "gauge",
+		snapshot.ServerP95, nil)
+	pe.writeMetric(&sb, "download_p50_milliseconds", "P50 content download time in milliseconds", "gauge",
+		snapshot.DownloadP50, nil)
+	pe.writeMetric(&sb, "download_p95_milliseconds", "P95 content download time in milliseconds", "gauge",
+		snapshot.DownloadP95, nil)
+
 	// Throughput metrics
 	pe.writeMetric(&sb, "requests_per_second", "Requests per second", "gauge",
 		snapshot.RequestsPerSecond, nil)
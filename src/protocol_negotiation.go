@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Protocols a benchmark can be forced to speak instead of negotiating
+const (
+	ProtocolH1  = "h1"  // HTTP/1.1 only, over TLS or cleartext
+	ProtocolH2  = "h2"  // HTTP/2 over TLS only; servers without it fail
+	ProtocolH2C = "h2c" // Cleartext HTTP/2 with prior knowledge
+	ProtocolH3  = "h3"  // HTTP/3 over QUIC
+)
+
+// applyForcedProtocol restricts transport to protocol and returns the h2c
+// mode to use with it. Without a forced protocol, HTTP/2 is offered over TLS
+// and HTTP/1.1 used where the server declines it
+func applyForcedProtocol(transport *http.Transport, protocol, h2c string) (string, error) {
+	protocols := new(http.Protocols)
+	switch protocol {
+	case "":
+		transport.ForceAttemptHTTP2 = true
+		return h2c, nil
+	case ProtocolH1:
+		protocols.SetHTTP1(true)
+	case ProtocolH2:
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		if h2c == H2CUpgrade {
+			return "", fmt.Errorf("forced protocol h2c uses prior knowledge and cannot be combined with h2c mode %q", h2c)
+		}
+		return H2CPriorKnowledge, nil
+	case ProtocolH3:
+		return "", fmt.Errorf("forced protocol h3 needs a QUIC transport, which this build does not include")
+	default:
+		return "", fmt.Errorf("unknown forced protocol %q: use %s, %s, %s or %s", protocol, ProtocolH1, ProtocolH2, ProtocolH2C, ProtocolH3)
+	}
+	if h2c != "" {
+		return "", fmt.Errorf("forced protocol %s cannot be combined with h2c mode %q", protocol, h2c)
+	}
+	transport.Protocols = protocols
+	return "", nil
+}
+
+// ProtocolStats summarizes the HTTP versions responses arrived over and how
+// often an offer of HTTP/2 fell back to HTTP/1.1
+type ProtocolStats struct {
+	Forced string              `json:"forced,omitempty"` // The forced protocol, if any
+	Hosts  []HostProtocolStats `json:"hosts"`
+
+	// Successful requests that offered HTTP/2, and those of them answered
+	// over HTTP/1.1
+	Offered      int     `json:"offered"`
+	Fallbacks    int     `json:"fallbacks"`
+	FallbackRate float64 `json:"fallback_rate"`
+
+	// Median latency of fallback requests minus that of requests answered
+	// over HTTP/2; zero unless both occurred
+	FallbackCostMs float64 `json:"fallback_cost_ms,omitempty"`
+}
+
+// HostProtocolStats counts one host's responses by protocol
+type HostProtocolStats struct {
+	Host      string             `json:"host"`
+	Requests  map[string]int     `json:"requests"` // By response protocol, e.g. HTTP/2.0
+	MedianMs  map[string]float64 `json:"median_ms"`
+	Offered   int                `json:"offered"`
+	Fallbacks int                `json:"fallbacks"`
+}
+
+// offersHTTP2 reports whether requests to u offer HTTP/2 and may fall back
+func (b *Benchmarker) offersHTTP2(u *url.URL) bool {
+	if b.config.ForceProtocol != "" {
+		return false
+	}
+	return u.Scheme == "https" || b.config.H2C == H2CUpgrade
+}
+
+// recordProtocol fills metric's protocol fields from resp
+func (b *Benchmarker) recordProtocol(metric *LatencyMetrics, resp *http.Response) {
+	metric.Protocol = resp.Proto
+	metric.Host = resp.Request.URL.Host
+	metric.HTTP2Offered = b.offersHTTP2(resp.Request.URL)
+}
+
+// calculateProtocolStats summarizes the protocols of successful requests in
+// metrics, or returns nil if none recorded one
+func calculateProtocolStats(metrics []LatencyMetrics) *ProtocolStats {
+	hosts := make(map[string]*HostProtocolStats)
+	latencies := make(map[string]map[string][]float64)
+	var fallback, http2 []float64
+	stats := &ProtocolStats{}
+
+	for _, m := range metrics {
+		if m.Error != "" || m.Protocol == "" {
+			continue
+		}
+		host, ok := hosts[m.Host]
+		if !ok {
+			host = &HostProtocolStats{Host: m.Host, Requests: make(map[string]int), MedianMs: make(map[string]float64)}
+			hosts[m.Host] = host
+			latencies[m.Host] = make(map[string][]float64)
+		}
+		latency := float64(m.TotalLatency.Microseconds()) / 1000.0
+		host.Requests[m.Protocol]++
+		latencies[m.Host][m.Protocol] = append(latencies[m.Host][m.Protocol], latency)
+
+		if !m.HTTP2Offered {
+			continue
+		}
+		host.Offered++
+		stats.Offered++
+		if m.Protocol == "HTTP/2.0" {
+			http2 = append(http2, latency)
+		} else {
+			host.Fallbacks++
+			stats.Fallbacks++
+			fallback = append(fallback, latency)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	for name, host := range hosts {
+		for protocol, values := range latencies[name] {
+			host.MedianMs[protocol] = CalculateStats(values).Median
+		}
+		stats.Hosts = append(stats.Hosts, *host)
+	}
+	sort.Slice(stats.Hosts, func(i, j int) bool {
+		return stats.Hosts[i].Host < stats.Hosts[j].Host
+	})
+
+	if stats.Offered > 0 {
+		stats.FallbackRate = float64(stats.Fallbacks) / float64(stats.Offered)
+	}
+	if len(fallback) > 0 && len(http2) > 0 {
+		stats.FallbackCostMs = CalculateStats(fallback).Median - CalculateStats(http2).Median
+	}
+	return stats
+}
+
+// printProtocolStats writes the protocol breakdown of a result
+func printProtocolStats(stats *ProtocolStats) {
+	fmt.Printf("\n--- Protocols ---\n")
+	if stats.Forced != "" {
+		fmt.Printf("Forced: %s\n", stats.Forced)
+	}
+	for _, host := range stats.Hosts {
+		fmt.Printf("%s:", host.Host)
+		for _, protocol := range sortedProtocols(host.Requests) {
+			fmt.Printf(" %s %d (median %.2f ms)", protocol, host.Requests[protocol], host.MedianMs[protocol])
+		}
+		fmt.Println()
+	}
+	if stats.Offered > 0 {
+		fmt.Printf("HTTP/2 fallback: %d of %d requests (%.1f%%)\n", stats.Fallbacks, stats.Offered, stats.FallbackRate*100)
+	}
+	if stats.FallbackCostMs != 0 {
+		fmt.Printf("Fallback cost: %+.2f ms median latency over HTTP/2\n", stats.FallbackCostMs)
+	}
+}
+
+// protocolSection renders the protocols of a run's iterations as markdown,
+// or returns "" if none recorded one
+func protocolSection(results []*BenchmarkResult) string {
+	type hostTotals struct {
+		requests           map[string]int
+		offered, fallbacks int
+	}
+	hosts := make(map[string]*hostTotals)
+	var offered, fallbacks, costed int
+	var cost float64
+	forced := ""
+	for _, result := range results {
+		stats := result.Protocols
+		if stats == nil {
+			continue
+		}
+		forced = stats.Forced
+		offered += stats.Offered
+		fallbacks += stats.Fallbacks
+		if stats.FallbackCostMs != 0 {
+			cost += stats.FallbackCostMs
+			costed++
+		}
+		for _, host := range stats.Hosts {
+			totals, ok := hosts[host.Host]
+			if !ok {
+				totals = &hostTotals{requests: make(map[string]int)}
+				hosts[host.Host] = totals
+			}
+			for protocol, count := range host.Requests {
+				totals.requests[protocol] += count
+			}
+			totals.offered += host.Offered
+			totals.fallbacks += host.Fallbacks
+		}
+	}
+	if len(hosts) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	section := "### Protocol Negotiation\n\n"
+	if forced != "" {
+		section += fmt.Sprintf("Protocol forced to **%s**.\n\n", forced)
+	}
+	section += "| Host | Protocols | HTTP/2 Offered | Fallbacks | Fallback Rate |\n"
+	section += "|------|-----------|----------------|-----------|---------------|\n"
+	for _, name := range names {
+		totals := hosts[name]
+		protocols := ""
+		for _, protocol := range sortedProtocols(totals.requests) {
+			if protocols != "" {
+				protocols += ", "
+			}
+			protocols += fmt.Sprintf("%s: %d", protocol, totals.requests[protocol])
+		}
+		rate := 0.0
+		if totals.offered > 0 {
+			rate = float64(totals.fallbacks) / float64(totals.offered)
+		}
+		section += fmt.Sprintf("| %s | %s | %d | %d | %.1f%% |\n", name, protocols, totals.offered, totals.fallbacks, rate*100)
+	}
+	section += "\n"
+
+	if offered > 0 && fallbacks > 0 {
+		section += fmt.Sprintf("⚠️ HTTP/2 negotiation fell back to HTTP/1.1 for %d of %d requests (%.1f%%).", fallbacks, offered, float64(fallbacks)/float64(offered)*100)
+		if costed > 0 {
+			section += fmt.Sprintf(" Fallback requests took %+.2f ms median latency compared with HTTP/2.", cost/float64(costed))
+		}
+		section += "\n\n"
+	}
+	return section
+}
+
+// sortedProtocols returns the protocols counted in requests in order
+func sortedProtocols(requests map[string]int) []string {
+	protocols := make([]string, 0, len(requests))
+	for protocol := range requests {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newProtocolServer starts a TLS server that offers HTTP/2 when http2 is set
+func newProtocolServer(http2 bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = http2
+	server.StartTLS()
+	return server
+}
+
+// TestBenchmarkerProtocolFallback tests that HTTP/2 is offered over TLS and
+// servers declining it are reported as fallbacks
+func TestBenchmarkerProtocolFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		force     string
+		protocol  string
+		offered   int
+		fallbacks int
+	}{
+		{"negotiated h2", true, "", "HTTP/2.0", 5, 0},
+		{"fallback to h1", false, "", "HTTP/1.1", 5, 5},
+		{"forced h1", true, ProtocolH1, "HTTP/1.1", 0, 0},
+		{"forced h2", true, ProtocolH2, "HTTP/2.0", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newProtocolServer(tt.http2)
+			defer server.Close()
+
+			result, err := NewBenchmarker(BenchmarkConfig{
+				TargetURL:     server.URL,
+				TotalRequests: 5,
+				Concurrency:   1,
+				KeepAlive:     true,
+				TLS:           &ClientTLSConfig{InsecureSkipVerify: true},
+				ForceProtocol: tt.force,
+			}).Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			stats := result.Protocols
+			if stats == nil || len(stats.Hosts) != 1 {
+				t.Fatalf("Expected protocol stats for one host, got %+v", stats)
+			}
+			if n := stats.Hosts[0].Requests[tt.protocol]; n != 5 {
+				t.Errorf("Expected 5 %s responses, got %v", tt.protocol, stats.Hosts[0].Requests)
+			}
+			if stats.Offered != tt.offered || stats.Fallbacks != tt.fallbacks {
+				t.Errorf("Expected %d offered and %d fallbacks, got %d and %d", tt.offered, tt.fallbacks, stats.Offered, stats.Fallbacks)
+			}
+			if stats.Forced != tt.force {
+				t.Errorf("Expected forced protocol %q, got %q", tt.force, stats.Forced)
+			}
+		})
+	}
+}
+
+// TestBenchmarkerForcedH2Unsupported tests that forcing HTTP/2 against a
+// server without it fails instead of falling back
+func TestBenchmarkerForcedH2Unsupported(t *testing.T) {
+	server := newProtocolServer(false)
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 2,
+		TLS:           &ClientTLSConfig{InsecureSkipVerify: true},
+		ForceProtocol: ProtocolH2,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.SuccessfulReqs != 0 {
+		t.Errorf("Expected every request to fail, got %d successful", result.SuccessfulReqs)
+	}
+}
+
+// TestApplyForcedProtocolErrors tests invalid forced protocols
+func TestApplyForcedProtocolErrors(t *testing.T) {
+	tests := []struct {
+		protocol string
+		h2c      string
+		err      string
+	}{
+		{ProtocolH3, "", "QUIC"},
+		{"spdy", "", "unknown forced protocol"},
+		{ProtocolH2C, H2CUpgrade, "prior knowledge"},
+		{ProtocolH1, H2CPriorKnowledge, "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		_, err := applyForcedProtocol(&http.Transport{}, tt.protocol, tt.h2c)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s with h2c %q: expected an error containing %q, got %v", tt.protocol, tt.h2c, tt.err, err)
+		}
+	}
+
+	if h2c, err := applyForcedProtocol(&http.Transport{}, ProtocolH2C, ""); err != nil || h2c != H2CPriorKnowledge {
+		t.Errorf("Expected h2c to use prior knowledge, got %q, %v", h2c, err)
+	}
+}
+
+// TestProtocolSection tests the report section across iterations
+func TestProtocolSection(t *testing.T) {
+	results := []*BenchmarkResult{
+		{Protocols: &ProtocolStats{
+			Hosts:          []HostProtocolStats{{Host: "api.example.com", Requests: map[string]int{"HTTP/1.1": 3, "HTTP/2.0": 7}, Offered: 10, Fallbacks: 3}},
+			Offered:        10,
+			Fallbacks:      3,
+			FallbackCostMs: 12.5,
+		}},
+		{},
+	}
+
+	section := protocolSection(results)
+	for _, want := range []string{"| api.example.com | HTTP/1.1: 3, HTTP/2.0: 7 | 10 | 3 | 30.0% |", "3 of 10 requests", "+12.50 ms"} {
+		if !strings.Contains(section, want) {
+			t.Errorf("Expected the section to contain %q, got:\n%s", want, section)
+		}
+	}
+
+	if section := protocolSection([]*BenchmarkResult{{}}); section != "" {
+		t.Errorf("Expected no section without protocol stats, got %q", section)
+	}
+}
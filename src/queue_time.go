@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// connWait measures how long a request queued for a pooled connection, e.g.
+// behind MaxConnsPerHost or a busy HTTP/2 connection. Time spent dialing a
+// new connection is service time and is excluded
+type connWait struct {
+	getConn, gotConn   time.Time
+	dialStart, dialEnd time.Time
+	mu                 sync.Mutex
+}
+
+// trace returns hooks recording the connection wait; merge them into the
+// request's context with httptrace.WithClientTrace
+func (w *connWait) trace() *httptrace.ClientTrace {
+	dialing := func() {
+		w.mu.Lock()
+		if w.dialStart.IsZero() {
+			w.dialStart = time.Now()
+		}
+		w.mu.Unlock()
+	}
+	dialed := func() {
+		w.mu.Lock()
+		w.dialEnd = time.Now()
+		w.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			w.mu.Lock()
+			w.getConn = time.Now()
+			w.mu.Unlock()
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			w.mu.Lock()
+			w.gotConn = time.Now()
+			w.mu.Unlock()
+		},
+		DNSStart:         func(httptrace.DNSStartInfo) { dialing() },
+		ConnectStart:     func(string, string) { dialing() },
+		ConnectDone:      func(string, string, error) { dialed() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) { dialed() },
+	}
+}
+
+// Queue returns the time between asking for a connection and getting one,
+// less any dial in between
+func (w *connWait) Queue() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.getConn.IsZero() || w.gotConn.IsZero() {
+		return 0
+	}
+	wait := w.gotConn.Sub(w.getConn)
+	if !w.dialStart.IsZero() && w.dialEnd.After(w.dialStart) {
+		wait -= w.dialEnd.Sub(w.dialStart)
+	}
+	return max(wait, 0)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+// TestBenchmarkerQueueTime tests that rate limiter waits are reported as
+// queue time rather than latency
+func TestBenchmarkerQueueTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := DefaultRateLimiterConfig()
+	config.Global = TokenBucketConfig{Rate: 20, Burst: 1}
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 4,
+		Concurrency:   1,
+		KeepAlive:     true,
+		RateLimit:     config,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.QueueStats.Samples != 4 {
+		t.Fatalf("Expected 4 queue time samples, got %d", result.QueueStats.Samples)
+	}
+	if result.QueueStats.Max < 30 {
+		t.Errorf("Expected waits of about 50 ms for tokens, got max %.2f ms", result.QueueStats.Max)
+	}
+	if result.LatencyStats.Max >= 30 {
+		t.Errorf("Latency %.2f ms should exclude the rate limiter wait", result.LatencyStats.Max)
+	}
+}
+
+// TestConnWaitExcludesDial tests that dialing a new connection is not
+// counted as queueing
+func TestConnWaitExcludesDial(t *testing.T) {
+	var wait connWait
+	trace := wait.trace()
+	trace.GetConn("example.com:443")
+	trace.ConnectStart("tcp", "127.0.0.1:443")
+	time.Sleep(20 * time.Millisecond)
+	trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+	trace.GotConn(httptrace.GotConnInfo{})
+
+	if queue := wait.Queue(); queue >= 10*time.Millisecond {
+		t.Errorf("Expected dial time excluded from queue, got %v", queue)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestRateLimiterBuckets tests that per-host buckets are independent and reject when empty
+func TestRateLimiterBuckets(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Mode = RateLimitReject
+	config.PerHost = TokenBucketConfig{Rate: 1, Burst: 2}
+	limiter := NewRateLimiter(config)
+
+	reqA, _ := http.NewRequest("GET", "http://a.example.com/", nil)
+	reqB, _ := http.NewRequest("GET", "http://b.example.com/", nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(ctx, reqA); err != nil {
+			t.Fatalf("Request %d within burst was limited: %v", i+1, err)
+		}
+	}
+	if err := limiter.Wait(ctx, reqA); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited once the burst is spent, got %v", err)
+	}
+	if err := limiter.Wait(ctx, reqB); err != nil {
+		t.Errorf("Other hosts should have their own bucket, got %v", err)
+	}
+
+	levels := limiter.Levels()
+	if len(levels) != 2 || levels[0].Key != "a.example.com" || levels[0].Tokens >= 1 {
+		t.Errorf("Unexpected bucket levels: %+v", levels)
+	}
+}
+
+// TestRateLimiterWait tests that wait mode blocks until a token is refilled
+func TestRateLimiterWait(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Global = TokenBucketConfig{Rate: 50, Burst: 1}
+	limiter := NewRateLimiter(config)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background(), req); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected waits for refill at 50/s, took only %v", elapsed)
+	}
+}
+
+// TestRateLimiterWaitCanceled tests that a canceled caller gets its context error, not ErrRateLimited
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	config := DefaultRateLimiterConfig()
+	config.Global = TokenBucketConfig{Rate: 0.1, Burst: 1}
+	limiter := NewRateLimiter(config)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	if err := limiter.Wait(context.Background(), req); err != nil {
+		t.Fatalf("First request within burst was limited: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := limiter.Wait(ctx, req)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	config.MaxWait = 10 * time.Millisecond
+	limiter = NewRateLimiter(config)
+	limiter.Wait(context.Background(), req)
+	if err := limiter.Wait(context.Background(), req); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited once MaxWait passes, got %v", err)
+	}
+}
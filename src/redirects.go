@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxRedirects is how many hops are followed without a policy, as
+// net/http does
+const DefaultMaxRedirects = 10
+
+// What a 3xx response that ends a request counts as
+const (
+	RedirectSuccess = "success"
+	RedirectFailure = "failure"
+)
+
+// RedirectPolicy controls how requests follow redirects. Without one, up
+// to DefaultMaxRedirects hops are followed and a longer chain fails
+type RedirectPolicy struct {
+	// Return the first 3xx response instead of following it
+	NoFollow bool `yaml:"no_follow"`
+
+	// Hops followed before the last 3xx response is returned; zero means
+	// DefaultMaxRedirects
+	MaxRedirects int `yaml:"max_redirects"`
+
+	// What a 3xx response ending a request, not followed or past
+	// MaxRedirects, counts as: RedirectSuccess (default) or RedirectFailure
+	Final string `yaml:"final"`
+}
+
+// Validate checks the policy's values
+func (p *RedirectPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxRedirects < 0 {
+		return fmt.Errorf("max_redirects must not be negative")
+	}
+	switch p.Final {
+	case "", RedirectSuccess, RedirectFailure:
+		return nil
+	}
+	return fmt.Errorf("unknown redirect final %q, expected %s or %s", p.Final, RedirectSuccess, RedirectFailure)
+}
+
+// limit returns how many hops to follow
+func (p *RedirectPolicy) limit() int {
+	if p == nil || p.MaxRedirects == 0 {
+		return DefaultMaxRedirects
+	}
+	return p.MaxRedirects
+}
+
+// RedirectHop is one followed redirect: the response that redirected and
+// the time from sending its request to receiving it
+type RedirectHop struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status_code"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// redirectTrace collects the hops of one request; CheckRedirect finds it in
+// the request context
+type redirectTrace struct {
+	hopStart time.Time
+	hops     []RedirectHop
+}
+
+type redirectTraceKey struct{}
+
+// withRedirectTrace returns ctx carrying trace
+func withRedirectTrace(ctx context.Context, trace *redirectTrace) context.Context {
+	return context.WithValue(ctx, redirectTraceKey{}, trace)
+}
+
+// errTooManyRedirects fails a chain longer than the limit without a policy
+var errTooManyRedirects = errors.New("too many redirects")
+
+// checkRedirect is the client's CheckRedirect: it applies the redirect
+// policy and times the hop about to be followed. With a policy, a redirect
+// that is not followed becomes the final response
+func (b *Benchmarker) checkRedirect(req *http.Request, via []*http.Request) error {
+	policy := b.config.Redirects
+	if policy != nil && policy.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	if limit := policy.limit(); len(via) > limit {
+		if policy != nil {
+			return http.ErrUseLastResponse
+		}
+		return fmt.Errorf("stopped after %d redirects: %w", limit, errTooManyRedirects)
+	}
+
+	if trace, ok := req.Context().Value(redirectTraceKey{}).(*redirectTrace); ok {
+		now := time.Now()
+		// req.Response is the redirect that via's last request received
+		hop := RedirectHop{URL: via[len(via)-1].URL.String(), Duration: now.Sub(trace.hopStart)}
+		if req.Response != nil {
+			hop.StatusCode = req.Response.StatusCode
+		}
+		trace.hops = append(trace.hops, hop)
+		trace.hopStart = now
+	}
+	return nil
+}
+
+// recordRedirects fills metric's redirect fields from trace and the final
+// response, failing a final 3xx if the policy says so
+func (b *Benchmarker) recordRedirects(metric *LatencyMetrics, trace *redirectTrace, resp *http.Response) {
+	metric.Redirects = trace.hops
+	for _, hop := range trace.hops {
+		metric.RedirectTime += hop.Duration
+	}
+
+	policy := b.config.Redirects
+	if isRedirect(resp.StatusCode) && resp.Header.Get("Location") != "" && policy != nil && policy.Final == RedirectFailure && metric.Error == "" {
+		metric.Error = fmt.Sprintf("redirect not followed: %d to %s", resp.StatusCode, resp.Header.Get("Location"))
+		metric.ErrorType = ErrorTypeRedirect
+	}
+}
+
+// isRedirect reports whether status is a redirect net/http may follow
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// RedirectStats summarizes the redirect chains of a run's requests
+type RedirectStats struct {
+	Requests int     `json:"requests"` // Requests that followed at least one redirect
+	Rate     float64 `json:"rate"`
+	MeanHops float64 `json:"mean_hops"`
+	MaxHops  int     `json:"max_hops"`
+
+	// Time spent in redirect hops per redirected request, and its share of
+	// those requests' total latency
+	Time      LatencyStats `json:"time"`
+	TimeShare float64      `json:"time_share"`
+
+	// Requests ending on a 3xx response, failed if the policy's Final says so
+	Unfollowed int `json:"unfollowed"`
+}
+
+// calculateRedirectStats summarizes the redirects in metrics, or returns nil
+// if no request followed or ended on one
+func calculateRedirectStats(metrics []LatencyMetrics) *RedirectStats {
+	stats := &RedirectStats{}
+	var times []float64
+	var hops int
+	var redirectTime, totalTime time.Duration
+	for _, m := range metrics {
+		if m.ErrorType == ErrorTypeRedirect || (m.Error == "" && isRedirect(m.StatusCode)) {
+			stats.Unfollowed++
+		}
+		if m.Error != "" || len(m.Redirects) == 0 {
+			continue
+		}
+		stats.Requests++
+		hops += len(m.Redirects)
+		stats.MaxHops = max(stats.MaxHops, len(m.Redirects))
+		times = append(times, float64(m.RedirectTime.Microseconds())/1000.0)
+		redirectTime += m.RedirectTime
+		totalTime += m.TotalLatency
+	}
+	if stats.Requests == 0 && stats.Unfollowed == 0 {
+		return nil
+	}
+
+	if len(metrics) > 0 {
+		stats.Rate = float64(stats.Requests) / float64(len(metrics))
+	}
+	if stats.Requests > 0 {
+		stats.MeanHops = float64(hops) / float64(stats.Requests)
+		stats.Time = CalculateStats(times)
+	}
+	if totalTime > 0 {
+		stats.TimeShare = float64(redirectTime) / float64(totalTime)
+	}
+	return stats
+}
+
+// printRedirectStats writes the redirect summary of a result
+func printRedirectStats(stats *RedirectStats) {
+	fmt.Printf("\n--- Redirects ---\n")
+	if stats.Requests > 0 {
+		fmt.Printf("Redirected: %d requests (%.1f%%), %.1f hops on average, at most %d\n",
+			stats.Requests, stats.Rate*100, stats.MeanHops, stats.MaxHops)
+		fmt.Printf("Redirect time P50: %.2f ms | P95: %.2f ms (%.1f%% of redirected requests' latency)\n",
+			stats.Time.P50, stats.Time.P95, stats.TimeShare*100)
+	}
+	if stats.Unfollowed > 0 {
+		fmt.Printf("Ended on a redirect: %d requests\n", stats.Unfollowed)
+	}
+}
+
+// redirectSection renders the redirects of a run's iterations as markdown,
+// or returns "" if there were none
+func redirectSection(results []*BenchmarkResult) string {
+	var requests, unfollowed, maxHops int
+	var p50, share []float64
+	for _, result := range results {
+		stats := result.Redirects
+		if stats == nil {
+			continue
+		}
+		requests += stats.Requests
+		unfollowed += stats.Unfollowed
+		maxHops = max(maxHops, stats.MaxHops)
+		if stats.Requests > 0 {
+			p50 = append(p50, stats.Time.P50)
+			share = append(share, stats.TimeShare*100)
+		}
+	}
+	if requests == 0 && unfollowed == 0 {
+		return ""
+	}
+
+	section := "### Redirects\n\n"
+	if requests > 0 {
+		section += fmt.Sprintf("- **Redirected requests:** %d (up to %d hops)\n", requests, maxHops)
+		section += fmt.Sprintf("- **Redirect time P50:** %.2f ms, %.1f%% of their latency (mean over iterations)\n",
+			CalculateStats(p50).Mean, CalculateStats(share).Mean)
+	}
+	if unfollowed > 0 {
+		section += fmt.Sprintf("- **Ended on a redirect:** %d requests\n", unfollowed)
+	}
+	return section + "\n"
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirectServer redirects /a to /b to /c, and /loop to itself
+func redirectServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			time.Sleep(5 * time.Millisecond)
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+		}
+	}))
+}
+
+// TestBenchmarkerRedirectChain tests that followed hops are timed per request
+func TestBenchmarkerRedirectChain(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	b := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL + "/a", TotalRequests: 3, Concurrency: 1, IncludeRawMetrics: true})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	metric := result.RawMetrics[0]
+	if metric.StatusCode != http.StatusOK || len(metric.Redirects) != 2 {
+		t.Fatalf("Expected a 200 after 2 hops, got %d after %+v", metric.StatusCode, metric.Redirects)
+	}
+	first, second := metric.Redirects[0], metric.Redirects[1]
+	if !strings.HasSuffix(first.URL, "/a") || first.StatusCode != http.StatusFound || !strings.HasSuffix(second.URL, "/b") || second.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected hops /a 302 and /b 301, got %+v", metric.Redirects)
+	}
+	if first.Duration < 5*time.Millisecond || metric.RedirectTime != first.Duration+second.Duration || metric.RedirectTime > metric.TotalLatency {
+		t.Errorf("Expected hop times within the total latency, got %+v of %v", metric.Redirects, metric.TotalLatency)
+	}
+
+	stats := result.Redirects
+	if stats == nil || stats.Requests != 3 || stats.Rate != 1 || stats.MaxHops != 2 || stats.MeanHops != 2 || stats.Unfollowed != 0 {
+		t.Errorf("Expected 3 requests redirected twice, got %+v", stats)
+	}
+	if stats != nil && (stats.Time.P50 < 5 || stats.TimeShare <= 0 || stats.TimeShare > 1) {
+		t.Errorf("Expected redirect time of at least 5 ms, got %+v", stats)
+	}
+	if section := redirectSection([]*BenchmarkResult{result}); !strings.Contains(section, "**Redirected requests:** 3 (up to 2 hops)") {
+		t.Errorf("Expected the redirects in the report section, got:\n%s", section)
+	}
+}
+
+// TestBenchmarkerRedirectPolicy tests following limits and how a final 3xx counts
+func TestBenchmarkerRedirectPolicy(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		policy    *RedirectPolicy
+		status    int
+		hops      int
+		errorType string
+	}{
+		{"no follow", "/a", &RedirectPolicy{NoFollow: true}, http.StatusFound, 0, ""},
+		{"limit", "/a", &RedirectPolicy{MaxRedirects: 1}, http.StatusMovedPermanently, 1, ""},
+		{"limit as failure", "/a", &RedirectPolicy{MaxRedirects: 1, Final: RedirectFailure}, http.StatusMovedPermanently, 1, ErrorTypeRedirect},
+		{"within limit", "/a", &RedirectPolicy{MaxRedirects: 2, Final: RedirectFailure}, http.StatusOK, 2, ""},
+		{"loop without policy", "/loop", nil, 0, 0, ErrorTypeRedirect},
+		{"loop with policy", "/loop", &RedirectPolicy{MaxRedirects: 3}, http.StatusTemporaryRedirect, 3, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL + tt.path, TotalRequests: 1, Concurrency: 1, IncludeRawMetrics: true, Redirects: tt.policy})
+			result, err := b.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Benchmark failed: %v", err)
+			}
+			metric := result.RawMetrics[0]
+			if metric.StatusCode != tt.status || len(metric.Redirects) != tt.hops || metric.ErrorType != tt.errorType {
+				t.Errorf("Expected status %d after %d hops with error %q, got %d after %d with %q (%s)",
+					tt.status, tt.hops, tt.errorType, metric.StatusCode, len(metric.Redirects), metric.ErrorType, metric.Error)
+			}
+			if tt.status >= 300 && tt.status < 400 && (result.Redirects == nil || result.Redirects.Unfollowed != 1) {
+				t.Errorf("Expected the final redirect counted, got %+v", result.Redirects)
+			}
+		})
+	}
+
+	b := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, Redirects: &RedirectPolicy{Final: "maybe"}})
+	if _, err := b.Run(context.Background()); err == nil || !strings.Contains(err.Error(), `unknown redirect final "maybe"`) {
+		t.Errorf("Expected an invalid policy error, got %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultScriptTimeout bounds one call of a request script
+const DefaultScriptTimeout = time.Second
+
+// RequestScriptConfig is JavaScript computing each request, for workloads
+// the fixed Body, CustomHeaders and Workload cannot express. The script
+// defines
+//
+//	function request(ctx) {
+//	  return {
+//	    url: "/users/" + (ctx.id % 100),        // Resolved against the target
+//	    query: {page: ctx.id % 5},
+//	    headers: {"X-Request-Id": "bench-" + ctx.id},
+//	    body: {user: ctx.id % 100},             // Objects are sent as JSON
+//	    cacheKey: "user-" + (ctx.id % 100),
+//	  };
+//	}
+//
+// where every field is optional and ctx holds the request's id, method and
+// url, vars, and its row when the run has a data file. Calls are serialized,
+// so globals may hold state across requests. CacheKey names the cache entry
+// the request is served from by the optimized client; keys are recorded per
+// request and summarized, see CacheKeyStats
+type RequestScriptConfig struct {
+	File   string            `yaml:"file"`   // Script file
+	Source string            `yaml:"source"` // Inline script, instead of File
+	Vars   map[string]string `yaml:"vars"`   // Passed as ctx.vars
+
+	// Calls running longer fail their request; zero means DefaultScriptTimeout
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Seed makes Math.random reproducible, seeding each call's draws from it
+	// and the request's id; zero leaves it random
+	Seed int64 `yaml:"seed"`
+}
+
+// ScriptedRequest is what a request script computed for one request
+type ScriptedRequest struct {
+	Method   string
+	URL      string // Empty to keep the target
+	Headers  map[string]string
+	Body     []byte
+	HasBody  bool
+	JSONBody bool // Body was an object, encoded as JSON
+	CacheKey string
+}
+
+// RequestScript runs a compiled request script
+type RequestScript struct {
+	mu      sync.Mutex
+	vm      *goja.Runtime
+	request goja.Callable
+	vars    map[string]string
+	timeout time.Duration
+	seed    int64
+}
+
+// NewRequestScript compiles and runs the script in config, which must
+// define request(ctx)
+func NewRequestScript(config *RequestScriptConfig) (*RequestScript, error) {
+	name, source := "script", config.Source
+	if config.File != "" {
+		if source != "" {
+			return nil, fmt.Errorf("script sets both file and source")
+		}
+		data, err := os.ReadFile(config.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script: %w", err)
+		}
+		name, source = config.File, string(data)
+	}
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("script is empty")
+	}
+
+	program, err := goja.Compile(name, source, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script: %w", err)
+	}
+	vm := goja.New()
+	if _, err := vm.RunProgram(program); err != nil {
+		return nil, fmt.Errorf("script failed: %w", err)
+	}
+	request, ok := goja.AssertFunction(vm.Get("request"))
+	if !ok {
+		return nil, fmt.Errorf("script %s does not define function request(ctx)", name)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+	return &RequestScript{vm: vm, request: request, vars: config.Vars, timeout: timeout, seed: config.Seed}, nil
+}
+
+// Next calls request(ctx) for the request with the given id, method and url,
+// and its data row if any
+func (s *RequestScript) Next(id int, method, target string, row map[string]string) (*ScriptedRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := s.vm.NewObject()
+	ctx.Set("id", id)
+	ctx.Set("method", method)
+	ctx.Set("url", target)
+	ctx.Set("vars", s.vars)
+	if row != nil {
+		ctx.Set("row", row)
+	}
+	if s.seed != 0 {
+		s.vm.SetRandSource(newSeededRand(requestSeed(s.seed, id)).Float64)
+	}
+
+	// A runaway script is interrupted; an interrupt landing after the call
+	// returned is cleared so it does not fail the next one
+	fired := make(chan struct{})
+	timer := time.AfterFunc(s.timeout, func() {
+		s.vm.Interrupt(fmt.Sprintf("timed out after %v", s.timeout))
+		close(fired)
+	})
+	value, err := s.request(goja.Undefined(), ctx)
+	if !timer.Stop() {
+		<-fired
+		s.vm.ClearInterrupt()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scripted := &ScriptedRequest{Method: method}
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return scripted, nil
+	}
+	fields, ok := value.Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("request(ctx) returned %s, expected an object", value.ExportType())
+	}
+	return scripted, scripted.apply(fields, target)
+}
+
+// apply reads the fields a script returned
+func (r *ScriptedRequest) apply(fields map[string]interface{}, target string) error {
+	if method, ok := fields["method"]; ok {
+		r.Method = strings.ToUpper(fmt.Sprint(method))
+	}
+
+	if ref, ok := fields["url"]; ok || fields["query"] != nil {
+		base, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		resolved := base
+		if ok {
+			if resolved, err = base.Parse(fmt.Sprint(ref)); err != nil {
+				return fmt.Errorf("invalid url: %w", err)
+			}
+		}
+		if query, ok := fields["query"].(map[string]interface{}); ok {
+			values := resolved.Query()
+			for key, value := range query {
+				values.Set(key, fmt.Sprint(value))
+			}
+			resolved.RawQuery = values.Encode()
+		} else if fields["query"] != nil {
+			return fmt.Errorf("query must be an object")
+		}
+		r.URL = resolved.String()
+	}
+
+	if headers, ok := fields["headers"].(map[string]interface{}); ok {
+		r.Headers = make(map[string]string, len(headers))
+		for name, value := range headers {
+			r.Headers[name] = fmt.Sprint(value)
+		}
+	} else if fields["headers"] != nil {
+		return fmt.Errorf("headers must be an object")
+	}
+
+	if body, ok := fields["body"]; ok && body != nil {
+		r.HasBody = true
+		switch body := body.(type) {
+		case string:
+			r.Body = []byte(body)
+		case goja.ArrayBuffer:
+			r.Body = body.Bytes()
+		default:
+			data, err := json.Marshal(body)
+			if err != nil {
+				return fmt.Errorf("failed to encode body: %w", err)
+			}
+			r.Body = data
+			r.JSONBody = true
+		}
+	}
+
+	if key, ok := fields["cacheKey"]; ok && key != nil {
+		r.CacheKey = fmt.Sprint(key)
+	}
+	return nil
+}
+
+// applyHeaders sets the scripted headers on req, over any configured ones
+func (r *ScriptedRequest) applyHeaders(req *http.Request) {
+	if r.JSONBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.Header.Set(name, r.Headers[name])
+	}
+}
+
+// CacheKeyStats summarizes the cache keys scripted requests carried. Reuse
+// is the share of requests whose key an earlier request already used: the
+// hit ratio a cache in front of the target would reach at best
+type CacheKeyStats struct {
+	Requests int     `json:"requests"`
+	Distinct int     `json:"distinct"`
+	Reuse    float64 `json:"reuse"`
+}
+
+// calculateCacheKeyStats summarizes the cache keys of successful requests,
+// or returns nil if none carried one
+func calculateCacheKeyStats(metrics []LatencyMetrics) *CacheKeyStats {
+	seen := make(map[string]bool)
+	stats := &CacheKeyStats{}
+	for _, m := range metrics {
+		if m.Error != "" || m.CacheKey == "" {
+			continue
+		}
+		stats.Requests++
+		seen[m.CacheKey] = true
+	}
+	if stats.Requests == 0 {
+		return nil
+	}
+	stats.Distinct = len(seen)
+	stats.Reuse = float64(stats.Requests-stats.Distinct) / float64(stats.Requests)
+	return stats
+}
+
+// cacheKeySection renders the cache keys of a run's iterations as markdown,
+// or returns "" if no request carried one
+func cacheKeySection(results []*BenchmarkResult) string {
+	var reuse []float64
+	requests, distinct := 0, 0
+	for _, result := range results {
+		if keys := result.CacheKeys; keys != nil {
+			requests += keys.Requests
+			distinct = max(distinct, keys.Distinct)
+			reuse = append(reuse, keys.Reuse*100)
+		}
+	}
+	if len(reuse) == 0 {
+		return ""
+	}
+
+	section := "### Cache Keys\n\n"
+	section += "Keys assigned by the request script; reuse is the best hit ratio a cache in front of the target could reach.\n\n"
+	section += fmt.Sprintf("- **Requests with a key:** %d\n", requests)
+	section += fmt.Sprintf("- **Distinct keys:** %d (most in one iteration)\n", distinct)
+	section += fmt.Sprintf("- **Reuse:** %.1f%% (mean over iterations)\n\n", CalculateStats(reuse).Mean)
+	return section
+}
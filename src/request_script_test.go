@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestBenchmarkerRequestScript tests that a script computes each request's
+// method, URL, headers, body and cache key, keeping state across requests
+func TestBenchmarkerRequestScript(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		seen = append(seen, strings.Join([]string{r.Method, r.URL.RequestURI(), r.Header.Get("X-Seq"), r.Header.Get("Content-Type"), string(body)}, " "))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	b := NewBenchmarker(BenchmarkConfig{
+		TargetURL:         server.URL + "/api",
+		TotalRequests:     4,
+		Concurrency:       1,
+		IncludeRawMetrics: true,
+		Script: &RequestScriptConfig{
+			Vars: map[string]string{"tenant": "acme"},
+			Source: `
+var seq = 0;
+function request(ctx) {
+  seq++;
+  return {
+    method: "post",
+    url: "items/" + (ctx.id % 2),
+    query: {tenant: ctx.vars.tenant},
+    headers: {"X-Seq": seq},
+    body: {id: ctx.id},
+    cacheKey: "item-" + (ctx.id % 2),
+  };
+}`,
+		},
+	})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.FailedReqs != 0 {
+		t.Fatalf("Expected no failures, got %v", result.RawMetrics)
+	}
+
+	want := []string{
+		`POST /items/0?tenant=acme 1 application/json {"id":0}`,
+		`POST /items/1?tenant=acme 2 application/json {"id":1}`,
+		`POST /items/0?tenant=acme 3 application/json {"id":2}`,
+		`POST /items/1?tenant=acme 4 application/json {"id":3}`,
+	}
+	for i, w := range want {
+		if i >= len(seen) || seen[i] != w {
+			t.Errorf("Request %d: expected %q, got %q", i, w, seen)
+		}
+	}
+	if keys := result.CacheKeys; keys == nil || keys.Requests != 4 || keys.Distinct != 2 || keys.Reuse != 0.5 {
+		t.Errorf("Expected 2 distinct keys reused half the time, got %+v", keys)
+	}
+	if result.RawMetrics[0].CacheKey != "item-0" {
+		t.Errorf("Expected the cache key recorded per request, got %q", result.RawMetrics[0].CacheKey)
+	}
+	if section := cacheKeySection([]*BenchmarkResult{result}); !strings.Contains(section, "**Reuse:** 50.0%") {
+		t.Errorf("Expected the reuse in the report section, got:\n%s", section)
+	}
+}
+
+// TestRequestScriptFailures tests that script errors fail their request and
+// that a runaway script is interrupted without failing the next call
+func TestRequestScriptFailures(t *testing.T) {
+	script, err := NewRequestScript(&RequestScriptConfig{
+		Timeout: 50 * time.Millisecond,
+		Source: `function request(ctx) {
+  if (ctx.id == 1) throw new Error("bad id");
+  if (ctx.id == 2) for (;;) {}
+  if (ctx.id == 3) return 42;
+}`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile script: %v", err)
+	}
+
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{1, "bad id"},
+		{2, "timed out after 50ms"},
+		{3, "expected an object"},
+	}
+	for _, tt := range tests {
+		if _, err := script.Next(tt.id, "GET", "http://example.com", nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Request %d: expected error containing %q, got %v", tt.id, tt.want, err)
+		}
+	}
+	scripted, err := script.Next(0, "GET", "http://example.com", nil)
+	if err != nil || scripted.Method != "GET" || scripted.URL != "" {
+		t.Errorf("Expected the request unchanged after a timeout, got %+v, %v", scripted, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	b := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 2, Concurrency: 1})
+	b.SetRequestScript(script)
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.SuccessfulReqs != 1 || result.ErrorTypes[ErrorTypeScript] != 1 {
+		t.Errorf("Expected one scripted failure, got %d successes and %v", result.SuccessfulReqs, result.ErrorTypes)
+	}
+}
+
+// TestNewRequestScriptInvalid tests rejected scripts
+func TestNewRequestScriptInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.js")
+	os.WriteFile(path, []byte("function other() {}"), 0644)
+
+	tests := []struct {
+		name   string
+		config RequestScriptConfig
+		want   string
+	}{
+		{"empty", RequestScriptConfig{}, "script is empty"},
+		{"both", RequestScriptConfig{File: path, Source: "x"}, "both file and source"},
+		{"missing file", RequestScriptConfig{File: path + ".missing"}, "failed to read script"},
+		{"syntax", RequestScriptConfig{Source: "function request( {"}, "failed to compile script"},
+		{"throws", RequestScriptConfig{Source: "throw new Error('boom')"}, "boom"},
+		{"no request", RequestScriptConfig{File: path}, "does not define function request(ctx)"},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRequestScript(&tt.config); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	b := NewBenchmarker(BenchmarkConfig{TargetURL: "http://example.com", Script: &RequestScriptConfig{Source: "x"}, Workload: &WorkloadConfig{}})
+	if _, err := b.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot be combined with a workload") {
+		t.Errorf("Expected a script and workload conflict, got %v", err)
+	}
+}
+
+// TestLoadSuiteConfigScript tests that a suite's script file is resolved
+// relative to the suite
+func TestLoadSuiteConfigScript(t *testing.T) {
+	dir := t.TempDir()
+	suite := `name: scripted
+runs:
+  - name: api
+    config:
+      target_url: http://example.com
+      total_requests: 5
+      concurrency: 1
+      script:
+        file: scripts/users.js
+        vars: {tenant: acme}
+        timeout: 200ms
+    iterations: 1
+`
+	path := filepath.Join(dir, "suite.yaml")
+	os.WriteFile(path, []byte(suite), 0644)
+
+	loaded, err := LoadSuiteConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load suite: %v", err)
+	}
+	script := loaded.Runs[0].Config.Script
+	if script == nil || script.File != filepath.Join(dir, "scripts", "users.js") || script.Vars["tenant"] != "acme" || script.Timeout != 200*time.Millisecond {
+		t.Errorf("Expected the script resolved next to the suite, got %+v", script)
+	}
+}
+
+// TestOptimizedRequestScriptCacheKey tests that the optimized client caches
+// scripted requests under the script's cache key, so requests for different
+// URLs sharing a key are served from one entry
+func TestOptimizedRequestScriptCacheKey(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	clientConfig := DefaultOptimizedClientConfig()
+	clientConfig.MonitoringConfig.Enabled = false
+	client, err := NewOptimizedClient(clientConfig)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Stop()
+
+	engine, err := NewIntegratedBenchmarkEngine(&IntegratedBenchmarkConfig{
+		BenchmarkConfig: &BenchmarkConfig{
+			Script: &RequestScriptConfig{Source: `
+function request(ctx) {
+  return {url: "items/" + ctx.id, cacheKey: "shared"};
+}`},
+		},
+		UseOptimizations: true,
+		EnableCaching:    true,
+		OptimizedClient:  client,
+	})
+	if err != nil {
+		t.Fatalf("NewIntegratedBenchmarkEngine failed: %v", err)
+	}
+
+	results := make(chan *LatencyMetrics, 2)
+	errs := make(chan error, 2)
+	for id := 0; id < 2; id++ {
+		engine.executeOptimizedRequest(context.Background(), client, server.URL+"/", id, results, errs)
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if len(fetched) != 1 || fetched[0] != "/items/0" {
+		t.Errorf("Expected only /items/0 fetched, got %v", fetched)
+	}
+	for i := 0; i < 2; i++ {
+		if metric := <-results; metric.CacheKey != "shared" {
+			t.Errorf("Expected cache key shared recorded, got %q", metric.CacheKey)
+		}
+	}
+	if stats := client.GetStats(); stats.CacheHits != 1 {
+		t.Errorf("Expected 1 cache hit, got %d", stats.CacheHits)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	"api-latency-optimizer/checks"
+)
+
+// maxCheckExamples is how many failure reasons are kept per check
+const maxCheckExamples = 3
+
+// CheckStats counts the responses a plugin check passed and failed, with
+// the first few failure reasons
+type CheckStats struct {
+	Name     string   `json:"name"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// CustomMetric is the value a plugin metric computed over a run's responses
+type CustomMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// LoadPlugins opens the Go plugins at paths; see package checks for how to
+// build one
+func LoadPlugins(paths []string) ([]checks.Plugin, error) {
+	plugins := make([]checks.Plugin, 0, len(paths))
+	for _, path := range paths {
+		if strings.EqualFold(filepath.Ext(path), ".wasm") {
+			return nil, fmt.Errorf("plugin %s: WASM modules are not supported, build it as a Go plugin", path)
+		}
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+		}
+		symbol, err := p.Lookup("Plugin")
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		exported, ok := symbol.(*checks.Plugin)
+		if !ok || *exported == nil {
+			return nil, fmt.Errorf("plugin %s: Plugin must be a non-nil checks.Plugin variable, got %T", path, symbol)
+		}
+		plugins = append(plugins, *exported)
+	}
+	return plugins, nil
+}
+
+// responseChecks applies plugin checks and metrics to the responses of one Run
+type responseChecks struct {
+	checks  []checks.Check
+	metrics []checks.Metric
+
+	mu    sync.Mutex // Guards stats and serializes metric calls
+	stats []CheckStats
+}
+
+// newResponseChecks collects the checks and fresh metrics of plugins, or
+// returns nil if there are none
+func newResponseChecks(plugins []checks.Plugin) (*responseChecks, error) {
+	rc := &responseChecks{}
+	names := make(map[string]bool)
+	for _, p := range plugins {
+		for _, check := range p.Checks() {
+			if names["check "+check.Name()] {
+				return nil, fmt.Errorf("duplicate check %q", check.Name())
+			}
+			names["check "+check.Name()] = true
+			rc.checks = append(rc.checks, check)
+			rc.stats = append(rc.stats, CheckStats{Name: check.Name()})
+		}
+		for _, metric := range p.Metrics() {
+			if names["metric "+metric.Name()] {
+				return nil, fmt.Errorf("duplicate metric %q", metric.Name())
+			}
+			names["metric "+metric.Name()] = true
+			rc.metrics = append(rc.metrics, metric)
+		}
+	}
+	if len(rc.checks) == 0 && len(rc.metrics) == 0 {
+		return nil, nil
+	}
+	return rc, nil
+}
+
+// apply passes a completed response to every metric and check. The first
+// failed check fails metric; the others still run so each is counted
+func (rc *responseChecks) apply(metric *LatencyMetrics, req *http.Request, resp *http.Response, body []byte) {
+	response := &checks.Response{
+		Method:          req.Method,
+		URL:             req.URL.String(),
+		StatusCode:      resp.StatusCode,
+		Protocol:        metric.Protocol,
+		Header:          resp.Header,
+		Body:            body,
+		Latency:         metric.TotalLatency,
+		TimeToFirstByte: metric.TimeToFirstByte,
+	}
+
+	rc.mu.Lock()
+	for _, m := range rc.metrics {
+		observeMetric(m, response)
+	}
+	rc.mu.Unlock()
+
+	for i, check := range rc.checks {
+		err := runCheck(check, response)
+
+		rc.mu.Lock()
+		stats := &rc.stats[i]
+		if err == nil {
+			stats.Passed++
+		} else {
+			stats.Failed++
+			if len(stats.Examples) < maxCheckExamples {
+				stats.Examples = append(stats.Examples, err.Error())
+			}
+		}
+		rc.mu.Unlock()
+
+		if err != nil && metric.Error == "" {
+			metric.Error = fmt.Sprintf("check %s failed: %v", check.Name(), err)
+			metric.ErrorType = ErrorTypeCheck
+		}
+	}
+}
+
+// runCheck runs check, reporting a panic in plugin code as a failure
+func runCheck(check checks.Check, resp *checks.Response) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return check.Check(resp)
+}
+
+// observeMetric passes resp to metric, ignoring a panic in plugin code
+func observeMetric(metric checks.Metric, resp *checks.Response) {
+	defer func() { recover() }()
+	metric.Observe(resp)
+}
+
+// results returns the check counts and metric values so far
+func (rc *responseChecks) results() ([]CheckStats, []CustomMetric) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	var stats []CheckStats
+	for _, s := range rc.stats {
+		s.Examples = append([]string(nil), s.Examples...)
+		stats = append(stats, s)
+	}
+	var values []CustomMetric
+	for _, m := range rc.metrics {
+		values = append(values, CustomMetric{Name: m.Name(), Value: m.Value()})
+	}
+	return stats, values
+}
+
+// printCheckStats writes a result's plugin checks and metrics
+func printCheckStats(r *BenchmarkResult) {
+	if len(r.Checks) > 0 {
+		fmt.Printf("\n--- Response Checks ---\n")
+		for _, check := range r.Checks {
+			fmt.Printf("%-24s passed: %d | failed: %d\n", check.Name, check.Passed, check.Failed)
+			for _, example := range check.Examples {
+				fmt.Printf("  %s\n", example)
+			}
+		}
+	}
+	if len(r.CustomMetrics) > 0 {
+		fmt.Printf("\n--- Custom Metrics ---\n")
+		for _, metric := range r.CustomMetrics {
+			fmt.Printf("%-24s %.4f\n", metric.Name, metric.Value)
+		}
+	}
+}
+
+// checkSection renders the plugin checks summed over a run's iterations and
+// the metrics averaged over them as markdown, or returns "" if there are none
+func checkSection(results []*BenchmarkResult) string {
+	var checkNames, metricNames []string
+	passed := make(map[string]int)
+	failed := make(map[string]int)
+	values := make(map[string][]float64)
+	for _, result := range results {
+		for _, check := range result.Checks {
+			if _, ok := passed[check.Name]; !ok {
+				checkNames = append(checkNames, check.Name)
+			}
+			passed[check.Name] += check.Passed
+			failed[check.Name] += check.Failed
+		}
+		for _, metric := range result.CustomMetrics {
+			if _, ok := values[metric.Name]; !ok {
+				metricNames = append(metricNames, metric.Name)
+			}
+			values[metric.Name] = append(values[metric.Name], metric.Value)
+		}
+	}
+	if len(checkNames) == 0 && len(metricNames) == 0 {
+		return ""
+	}
+
+	section := ""
+	if len(checkNames) > 0 {
+		section += "### Response Checks\n\n"
+		section += "| Check | Passed | Failed |\n"
+		section += "|-------|--------|--------|\n"
+		for _, name := range checkNames {
+			section += fmt.Sprintf("| %s | %d | %d |\n", name, passed[name], failed[name])
+		}
+		section += "\n"
+	}
+	if len(metricNames) > 0 {
+		sort.Strings(metricNames)
+		section += "### Custom Metrics\n\n"
+		section += "| Metric | Mean | Min | Max |\n"
+		section += "|--------|------|-----|-----|\n"
+		for _, name := range metricNames {
+			stats := CalculateStats(values[name])
+			section += fmt.Sprintf("| %s | %.4f | %.4f | %.4f |\n", name, stats.Mean, stats.Min, stats.Max)
+		}
+		section += "\n"
+	}
+	return section
+}
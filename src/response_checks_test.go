@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"api-latency-optimizer/checks"
+)
+
+// checkFunc is a check defined inline in a test
+type checkFunc struct {
+	name  string
+	check func(*checks.Response) error
+}
+
+func (c checkFunc) Name() string                      { return c.name }
+func (c checkFunc) Check(resp *checks.Response) error { return c.check(resp) }
+
+// fallbackShare is a metric counting the share of fallback responses
+type fallbackShare struct {
+	fallbacks, total int
+}
+
+func (m *fallbackShare) Name() string { return "fallback_share" }
+func (m *fallbackShare) Observe(resp *checks.Response) {
+	m.total++
+	if resp.Header.Get("X-Fallback") != "" {
+		m.fallbacks++
+	}
+}
+func (m *fallbackShare) Value() float64 { return float64(m.fallbacks) / float64(m.total) }
+
+// testPlugin supplies checks and metrics compiled into the test
+type testPlugin struct {
+	checks []checks.Check
+}
+
+func (p testPlugin) Checks() []checks.Check   { return p.checks }
+func (p testPlugin) Metrics() []checks.Metric { return []checks.Metric{&fallbackShare{}} }
+
+// TestBenchmarkerResponseChecks tests that failed plugin checks fail their
+// requests and that plugin metrics are computed per run
+func TestBenchmarkerResponseChecks(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every fourth response is a fallback missing its id
+		if requests.Add(1)%4 == 0 {
+			w.Header().Set("X-Fallback", "1")
+			w.Write([]byte(`{"status":"degraded"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"abc","status":"ok"}`))
+	}))
+	defer server.Close()
+
+	hasID := checkFunc{"has_id", func(resp *checks.Response) error {
+		var body struct{ ID string }
+		if err := json.Unmarshal(resp.Body, &body); err != nil {
+			return err
+		}
+		if body.ID == "" {
+			return errors.New("response has no id")
+		}
+		return nil
+	}}
+	panics := checkFunc{"panics", func(resp *checks.Response) error {
+		if resp.Header.Get("X-Fallback") != "" {
+			panic("unexpected fallback")
+		}
+		return nil
+	}}
+
+	b := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 8, Concurrency: 1, IncludeRawMetrics: true})
+	b.SetPlugins([]checks.Plugin{testPlugin{checks: []checks.Check{hasID, panics}}})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if result.SuccessfulReqs != 6 || result.FailedReqs != 2 || result.ErrorTypes[ErrorTypeCheck] != 2 {
+		t.Errorf("Expected 6 passing and 2 check failures, got %d and %v", result.SuccessfulReqs, result.ErrorTypes)
+	}
+	for _, m := range result.RawMetrics {
+		if m.Error != "" && m.Error != "check has_id failed: response has no id" {
+			t.Errorf("Expected the first failed check as the error, got %q", m.Error)
+		}
+	}
+
+	if len(result.Checks) != 2 {
+		t.Fatalf("Expected stats for 2 checks, got %+v", result.Checks)
+	}
+	for _, check := range result.Checks {
+		if check.Passed != 6 || check.Failed != 2 || len(check.Examples) != 2 {
+			t.Errorf("Expected %s to pass 6 and fail 2, got %+v", check.Name, check)
+		}
+	}
+	if got := result.Checks[1].Examples[0]; got != "panic: unexpected fallback" {
+		t.Errorf("Expected a panic reported as a failure, got %q", got)
+	}
+	if len(result.CustomMetrics) != 1 || result.CustomMetrics[0].Value != 0.25 {
+		t.Errorf("Expected a fallback share of 0.25, got %+v", result.CustomMetrics)
+	}
+
+	// A second run starts its metrics afresh
+	b = NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 3, Concurrency: 1})
+	b.SetPlugins([]checks.Plugin{testPlugin{}})
+	requests.Store(0)
+	result, err = b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.FailedReqs != 0 || result.CustomMetrics[0].Value != 0 {
+		t.Errorf("Expected no failures and no fallbacks, got %d and %+v", result.FailedReqs, result.CustomMetrics)
+	}
+
+	section := checkSection([]*BenchmarkResult{{Checks: []CheckStats{{Name: "has_id", Passed: 6, Failed: 2}}}})
+	if !strings.Contains(section, "| has_id | 6 | 2 |") {
+		t.Errorf("Expected the check in the report section, got:\n%s", section)
+	}
+}
+
+// TestNewResponseChecksDuplicate tests that two plugins may not supply
+// checks of the same name
+func TestNewResponseChecksDuplicate(t *testing.T) {
+	check := checkFunc{"has_id", func(*checks.Response) error { return nil }}
+	plugin := testPlugin{checks: []checks.Check{check}}
+	if _, err := newResponseChecks([]checks.Plugin{plugin, plugin}); err == nil || !strings.Contains(err.Error(), `duplicate check "has_id"`) {
+		t.Errorf("Expected a duplicate check error, got %v", err)
+	}
+}
+
+// TestLoadPluginsInvalid tests rejected plugin paths
+func TestLoadPluginsInvalid(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"checks.wasm", "WASM modules are not supported"},
+		{filepath.Join(t.TempDir(), "missing.so"), "failed to open plugin"},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadPlugins([]string{tt.path}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.path, tt.want, err)
+		}
+	}
+}
+
+// TestLoadPluginsBuilt tests loading a plugin built with -buildmode=plugin
+func TestLoadPluginsBuilt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping plugin build in short mode")
+	}
+
+	// Built inside the module so it shares the host's checks package
+	so := filepath.Join(t.TempDir(), "checkplugin.so")
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", so, "./testdata/checkplugin")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("Go plugins cannot be built here: %v\n%s", err, out)
+	}
+
+	plugins, err := LoadPlugins([]string{so})
+	if err != nil {
+		t.Skipf("Built plugin cannot be loaded into the test binary: %v", err)
+	}
+	if len(plugins) != 1 || len(plugins[0].Checks()) != 1 || plugins[0].Checks()[0].Name() != "non_empty" {
+		t.Errorf("Expected the non_empty check, got %+v", plugins)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCapturedHeaderValues is how many distinct values of a captured header
+// are counted; headers with more, like request IDs, report only the count
+const maxCapturedHeaderValues = 20
+
+// ServerTimingMetric is one metric of a Server-Timing response header
+type ServerTimingMetric struct {
+	Name        string  `json:"name"`
+	DurationMs  float64 `json:"dur_ms,omitempty"`
+	Description string  `json:"desc,omitempty"`
+}
+
+// ServerTimingStats summarizes one Server-Timing metric across a run: the
+// upstream's own account of a phase, next to the client-observed timings
+type ServerTimingStats struct {
+	Name        string       `json:"name"`
+	Description string       `json:"desc,omitempty"`
+	Requests    int          `json:"requests"`
+	Duration    LatencyStats `json:"duration"`
+}
+
+// CapturedHeaderStats summarizes the values of one captured response header
+type CapturedHeaderStats struct {
+	Name     string `json:"name"`
+	Present  int    `json:"present"`  // Successful responses carrying the header
+	Distinct int    `json:"distinct"` // Distinct values seen
+
+	// Responses and median latency per value, unless there were more than
+	// maxCapturedHeaderValues distinct values
+	Values   map[string]int     `json:"values,omitempty"`
+	MedianMs map[string]float64 `json:"median_ms,omitempty"`
+}
+
+// captureResponseHeaders records the configured headers and the Server-Timing
+// metrics of resp. Trailers count too, so call it after reading the body
+func (b *Benchmarker) captureResponseHeaders(metric *LatencyMetrics, resp *http.Response) {
+	for _, name := range b.config.CaptureHeaders {
+		value := resp.Header.Get(name)
+		if value == "" {
+			value = resp.Trailer.Get(name)
+		}
+		if value == "" {
+			continue
+		}
+		if metric.Headers == nil {
+			metric.Headers = make(map[string]string, len(b.config.CaptureHeaders))
+		}
+		metric.Headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	timing := append(resp.Header.Values("Server-Timing"), resp.Trailer.Values("Server-Timing")...)
+	metric.ServerTiming = parseServerTiming(timing)
+}
+
+// parseServerTiming parses Server-Timing header values, e.g.
+// `db;dur=53, cache;desc="Cache Read";dur=23.2`. Malformed metrics are skipped
+func parseServerTiming(values []string) []ServerTimingMetric {
+	var metrics []ServerTimingMetric
+	for _, value := range values {
+		for _, entry := range splitUnquoted(value, ',') {
+			params := splitUnquoted(entry, ';')
+			name := strings.TrimSpace(params[0])
+			if name == "" || strings.ContainsAny(name, " \t\"=") {
+				continue
+			}
+			metric := ServerTimingMetric{Name: name}
+			for _, param := range params[1:] {
+				key, val, _ := strings.Cut(param, "=")
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "dur":
+					if duration, err := strconv.ParseFloat(val, 64); err == nil && duration >= 0 {
+						metric.DurationMs = duration
+					}
+				case "desc":
+					metric.Description = val
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+// splitUnquoted splits s at sep, except inside double-quoted strings
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// calculateServerTimingStats summarizes the Server-Timing metrics of
+// successful requests by name, or returns nil if none reported any
+func calculateServerTimingStats(metrics []LatencyMetrics) []ServerTimingStats {
+	byName := make(map[string]*ServerTimingStats)
+	durations := make(map[string][]float64)
+	for _, m := range metrics {
+		if m.Error != "" {
+			continue
+		}
+		for _, timing := range m.ServerTiming {
+			stats, ok := byName[timing.Name]
+			if !ok {
+				stats = &ServerTimingStats{Name: timing.Name}
+				byName[timing.Name] = stats
+			}
+			if stats.Description == "" {
+				stats.Description = timing.Description
+			}
+			stats.Requests++
+			durations[timing.Name] = append(durations[timing.Name], timing.DurationMs)
+		}
+	}
+	if len(byName) == 0 {
+		return nil
+	}
+
+	result := make([]ServerTimingStats, 0, len(byName))
+	for name, stats := range byName {
+		stats.Duration = CalculateStats(durations[name])
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// calculateCapturedHeaderStats summarizes the captured headers of successful
+// requests, or returns nil if none were configured
+func calculateCapturedHeaderStats(names []string, metrics []LatencyMetrics) []CapturedHeaderStats {
+	if len(names) == 0 {
+		return nil
+	}
+
+	result := make([]CapturedHeaderStats, 0, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		stats := CapturedHeaderStats{Name: name, Values: make(map[string]int)}
+		latencies := make(map[string][]float64)
+		for _, m := range metrics {
+			value, ok := m.Headers[name]
+			if m.Error != "" || !ok {
+				continue
+			}
+			stats.Present++
+			stats.Values[value]++
+			latencies[value] = append(latencies[value], float64(m.TotalLatency.Microseconds())/1000.0)
+		}
+
+		stats.Distinct = len(stats.Values)
+		if stats.Distinct > maxCapturedHeaderValues || stats.Distinct == 0 {
+			stats.Values = nil
+		} else {
+			stats.MedianMs = make(map[string]float64, len(latencies))
+			for value, values := range latencies {
+				stats.MedianMs[value] = CalculateStats(values).Median
+			}
+		}
+		result = append(result, stats)
+	}
+	return result
+}
+
+// printResponseHeaderStats writes the captured headers and Server-Timing
+// metrics of a result, next to the client-observed server processing time
+func printResponseHeaderStats(r *BenchmarkResult) {
+	if len(r.CapturedHeaders) > 0 {
+		fmt.Printf("\n--- Captured Headers ---\n")
+		for _, header := range r.CapturedHeaders {
+			fmt.Printf("%s: present in %d responses, %d distinct values\n", header.Name, header.Present, header.Distinct)
+			for _, value := range sortedHeaderValues(header.Values) {
+				fmt.Printf("  %-24s %6d (median %.2f ms)\n", value, header.Values[value], header.MedianMs[value])
+			}
+		}
+	}
+
+	if len(r.ServerTiming) > 0 {
+		fmt.Printf("\n--- Server-Timing ---\n")
+		for _, timing := range r.ServerTiming {
+			fmt.Printf("%-20s P50: %.2f ms | P95: %.2f ms | P99: %.2f ms (%d responses)\n",
+				timing.Name, timing.Duration.P50, timing.Duration.P95, timing.Duration.P99, timing.Requests)
+		}
+		fmt.Printf("%-20s P50: %.2f ms | P95: %.2f ms | P99: %.2f ms (client-observed)\n",
+			"server processing", r.ServerStats.P50, r.ServerStats.P95, r.ServerStats.P99)
+		if r.Upstream != nil {
+			fmt.Printf("%s\n", r.Upstream.describe())
+		}
+	}
+}
+
+// responseHeaderSection renders the captured headers and Server-Timing
+// metrics of a run's iterations as markdown, or returns "" if there are none
+func responseHeaderSection(results []*BenchmarkResult) string {
+	headers := make(map[string]map[string]int)
+	var headerNames []string
+	timing := make(map[string][]float64)
+	var serverP50 []float64
+	var upstream *UpstreamAttribution
+	for _, result := range results {
+		for _, header := range result.CapturedHeaders {
+			if _, ok := headers[header.Name]; !ok {
+				headers[header.Name] = make(map[string]int)
+				headerNames = append(headerNames, header.Name)
+			}
+			for value, count := range header.Values {
+				headers[header.Name][value] += count
+			}
+		}
+		for _, stats := range result.ServerTiming {
+			timing[stats.Name] = append(timing[stats.Name], stats.Duration.P50)
+		}
+		if len(result.ServerTiming) > 0 {
+			serverP50 = append(serverP50, result.ServerStats.P50)
+		}
+		if result.Upstream != nil {
+			upstream = result.Upstream
+		}
+	}
+	if len(headerNames) == 0 && len(timing) == 0 {
+		return ""
+	}
+
+	section := ""
+	if len(headerNames) > 0 {
+		section += "### Captured Response Headers\n\n"
+		section += "| Header | Values |\n"
+		section += "|--------|--------|\n"
+		for _, name := range headerNames {
+			values := ""
+			for _, value := range sortedHeaderValues(headers[name]) {
+				if values != "" {
+					values += ", "
+				}
+				values += fmt.Sprintf("%s: %d", value, headers[name][value])
+			}
+			if values == "" {
+				values = "too many distinct values to list"
+			}
+			section += fmt.Sprintf("| %s | %s |\n", name, values)
+		}
+		section += "\n"
+	}
+
+	if len(timing) > 0 {
+		names := make([]string, 0, len(timing))
+		for name := range timing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		section += "### Server-Timing\n\n"
+		section += "Durations reported by the upstream, averaged over iterations, next to the server processing time the client observed.\n\n"
+		section += "| Metric | P50 |\n"
+		section += "|--------|-----|\n"
+		for _, name := range names {
+			section += fmt.Sprintf("| %s | %.2f ms |\n", name, CalculateStats(timing[name]).Mean)
+		}
+		section += fmt.Sprintf("| *client-observed server processing* | %.2f ms |\n\n", CalculateStats(serverP50).Mean)
+		if upstream != nil {
+			section += fmt.Sprintf("%s (last iteration).\n\n", upstream.describe())
+		}
+	}
+	return section
+}
+
+// sortedHeaderValues returns the values in counts, most frequent first
+func sortedHeaderValues(counts map[string]int) []string {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
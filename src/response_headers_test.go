@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// TestParseServerTiming tests parsing of Server-Timing header values
+func TestParseServerTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []ServerTimingMetric
+	}{
+		{"empty", nil, nil},
+		{"single", []string{"db;dur=53"}, []ServerTimingMetric{{Name: "db", DurationMs: 53}}},
+		{
+			"several with descriptions",
+			[]string{`cache;desc="Cache Read";dur=23.2, db;dur=53, miss`},
+			[]ServerTimingMetric{
+				{Name: "cache", DurationMs: 23.2, Description: "Cache Read"},
+				{Name: "db", DurationMs: 53},
+				{Name: "miss"},
+			},
+		},
+		{
+			"quoted separators",
+			[]string{`app;desc="a, b; c";dur=1.5`},
+			[]ServerTimingMetric{{Name: "app", DurationMs: 1.5, Description: "a, b; c"}},
+		},
+		{
+			"several header lines",
+			[]string{"edge;dur=2", "origin;DUR=40"},
+			[]ServerTimingMetric{{Name: "edge", DurationMs: 2}, {Name: "origin", DurationMs: 40}},
+		},
+		{
+			"malformed entries skipped",
+			[]string{`;dur=5, db;dur=abc, "quoted";dur=1, total;dur=-1`},
+			[]ServerTimingMetric{{Name: "db"}, {Name: "total"}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := parseServerTiming(tt.values)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: Expected %+v, got %+v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// TestBenchmarkerCapturesResponseHeaders tests that configured headers and
+// Server-Timing metrics are recorded per request and summarized
+func TestBenchmarkerCapturesResponseHeaders(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("X-Request-Id", fmt.Sprintf("req-%d", n))
+		if n%2 == 0 {
+			w.Header().Set("CF-Cache-Status", "HIT")
+		} else {
+			w.Header().Set("CF-Cache-Status", "MISS")
+		}
+		w.Header().Set("Server-Timing", `db;dur=12.5, app;desc="Render";dur=3`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:         server.URL,
+		TotalRequests:     4,
+		Concurrency:       1,
+		KeepAlive:         true,
+		IncludeRawMetrics: true,
+		CaptureHeaders:    []string{"x-request-id", "cf-cache-status", "X-Missing"},
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, m := range result.RawMetrics {
+		if m.Headers["X-Request-Id"] == "" || m.Headers["Cf-Cache-Status"] == "" {
+			t.Errorf("Expected captured headers on every request, got %v", m.Headers)
+		}
+		if len(m.ServerTiming) != 2 {
+			t.Errorf("Expected 2 Server-Timing metrics, got %+v", m.ServerTiming)
+		}
+	}
+
+	if len(result.CapturedHeaders) != 3 {
+		t.Fatalf("Expected stats for 3 headers, got %+v", result.CapturedHeaders)
+	}
+	cache := result.CapturedHeaders[1]
+	if cache.Present != 4 || cache.Values["HIT"] != 2 || cache.Values["MISS"] != 2 {
+		t.Errorf("Expected 2 hits and 2 misses, got %+v", cache)
+	}
+	if missing := result.CapturedHeaders[2]; missing.Present != 0 || missing.Values != nil {
+		t.Errorf("Expected an absent header to have no values, got %+v", missing)
+	}
+
+	if len(result.ServerTiming) != 2 {
+		t.Fatalf("Expected 2 Server-Timing metrics, got %+v", result.ServerTiming)
+	}
+	app, db := result.ServerTiming[0], result.ServerTiming[1]
+	if app.Name != "app" || app.Description != "Render" || app.Requests != 4 || app.Duration.P50 != 3 {
+		t.Errorf("Unexpected app metric: %+v", app)
+	}
+	if db.Name != "db" || db.Duration.Mean != 12.5 {
+		t.Errorf("Unexpected db metric: %+v", db)
+	}
+}
+
+// TestCapturedHeaderStatsManyValues tests that unique values are only counted
+func TestCapturedHeaderStatsManyValues(t *testing.T) {
+	var metrics []LatencyMetrics
+	for i := 0; i < maxCapturedHeaderValues+1; i++ {
+		metrics = append(metrics, LatencyMetrics{Headers: map[string]string{"X-Request-Id": fmt.Sprintf("id-%d", i)}})
+	}
+
+	stats := calculateCapturedHeaderStats([]string{"X-Request-Id"}, metrics)
+	if len(stats) != 1 || stats[0].Distinct != maxCapturedHeaderValues+1 || stats[0].Values != nil {
+		t.Errorf("Expected only a distinct count, got %+v", stats)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ResponseSizeBuckets are the lower bounds of the response size histogram
+// buckets; the last bucket is unbounded
+var ResponseSizeBuckets = []int64{0, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// SizeStats provides statistical analysis of payload sizes in bytes
+type SizeStats struct {
+	Min     int64   `json:"min_bytes"`
+	Max     int64   `json:"max_bytes"`
+	Mean    float64 `json:"mean_bytes"`
+	P50     float64 `json:"p50_bytes"`
+	P90     float64 `json:"p90_bytes"`
+	P95     float64 `json:"p95_bytes"`
+	P99     float64 `json:"p99_bytes"`
+	Total   int64   `json:"total_bytes"`
+	Samples int     `json:"samples"`
+}
+
+// SizeBucket counts responses of at least MinBytes and below MaxBytes; a zero
+// MaxBytes means no upper bound
+type SizeBucket struct {
+	MinBytes int64 `json:"min_bytes"`
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	Count    int   `json:"count"`
+}
+
+// Label formats the bucket's range for reports
+func (b SizeBucket) Label() string {
+	if b.MaxBytes == 0 {
+		return fmt.Sprintf(">= %s", formatBytes(b.MinBytes))
+	}
+	return fmt.Sprintf("%s - %s", formatBytes(b.MinBytes), formatBytes(b.MaxBytes))
+}
+
+// CalculateSizeStats computes the distribution of sizes
+func CalculateSizeStats(sizes []int64) SizeStats {
+	stats := SizeStats{
+		Samples: len(sizes),
+	}
+
+	if len(sizes) == 0 {
+		return stats
+	}
+
+	sorted := make([]float64, len(sizes))
+	for i, size := range sizes {
+		sorted[i] = float64(size)
+		stats.Total += size
+	}
+	sort.Float64s(sorted)
+
+	stats.Min = int64(sorted[0])
+	stats.Max = int64(sorted[len(sorted)-1])
+	stats.Mean = float64(stats.Total) / float64(len(sizes))
+	stats.P50 = percentile(sorted, 50)
+	stats.P90 = percentile(sorted, 90)
+	stats.P95 = percentile(sorted, 95)
+	stats.P99 = percentile(sorted, 99)
+
+	return stats
+}
+
+// SizeHistogram counts sizes into ResponseSizeBuckets
+func SizeHistogram(sizes []int64) []SizeBucket {
+	buckets := make([]SizeBucket, len(ResponseSizeBuckets))
+	for i, lower := range ResponseSizeBuckets {
+		buckets[i].MinBytes = lower
+		if i+1 < len(ResponseSizeBuckets) {
+			buckets[i].MaxBytes = ResponseSizeBuckets[i+1]
+		}
+	}
+
+	for _, size := range sizes {
+		i := sort.Search(len(ResponseSizeBuckets), func(i int) bool { return ResponseSizeBuckets[i] > size }) - 1
+		if i < 0 {
+			i = 0
+		}
+		buckets[i].Count++
+	}
+
+	return buckets
+}
+
+// mergeSizeHistograms adds up histograms of several results, e.g. every
+// iteration of a run
+func mergeSizeHistograms(results []*BenchmarkResult) []SizeBucket {
+	var merged []SizeBucket
+	for _, result := range results {
+		if len(result.ResponseSizeHistogram) == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = make([]SizeBucket, len(result.ResponseSizeHistogram))
+			copy(merged, result.ResponseSizeHistogram)
+			for i := range merged {
+				merged[i].Count = 0
+			}
+		}
+		for i := range merged {
+			if i < len(result.ResponseSizeHistogram) {
+				merged[i].Count += result.ResponseSizeHistogram[i].Count
+			}
+		}
+	}
+	return merged
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestSizeHistogram tests bucket boundaries and the unbounded last bucket
+func TestSizeHistogram(t *testing.T) {
+	histogram := SizeHistogram([]int64{0, 1023, 1024, 5000, 10 << 20})
+	if len(histogram) != len(ResponseSizeBuckets) {
+		t.Fatalf("Expected %d buckets, got %d", len(ResponseSizeBuckets), len(histogram))
+	}
+
+	counts := map[int64]int{}
+	for _, bucket := range histogram {
+		counts[bucket.MinBytes] = bucket.Count
+	}
+	if counts[0] != 2 || counts[1<<10] != 1 || counts[4<<10] != 1 || counts[4<<20] != 1 {
+		t.Errorf("Unexpected bucket counts: %+v", histogram)
+	}
+	if last := histogram[len(histogram)-1]; last.MaxBytes != 0 {
+		t.Errorf("Last bucket should be unbounded, got %+v", last)
+	}
+
+	stats := CalculateSizeStats([]int64{100, 200, 300, 400})
+	if stats.Min != 100 || stats.Max != 400 || stats.Mean != 250 || stats.Total != 1000 || stats.Samples != 4 {
+		t.Errorf("Unexpected size stats: %+v", stats)
+	}
+}
+
+// TestBenchmarkerBandwidth tests that results report bytes transferred,
+// goodput and the size distribution of responses
+func TestBenchmarkerBandwidth(t *testing.T) {
+	var served int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every fourth response is a 2 KB error page
+		if atomic.AddInt64(&served, 1)%4 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(strings.Repeat("e", 2048)))
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 512)))
+	}))
+	defer server.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{TargetURL: server.URL, TotalRequests: 20, Concurrency: 1, KeepAlive: true})
+	result, err := benchmarker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if want := int64(15*512 + 5*2048); result.TotalBytes != want {
+		t.Errorf("Expected %d bytes transferred, got %d", want, result.TotalBytes)
+	}
+	if result.GoodputMBps <= 0 {
+		t.Error("Expected a positive goodput")
+	}
+	if goodput := result.GoodputMBps * (1 << 20); goodput >= result.BytesPerSecond {
+		t.Errorf("Goodput %.0f B/s should exclude error responses counted in %.0f B/s", goodput, result.BytesPerSecond)
+	}
+
+	sizes := result.ResponseSizeStats
+	if sizes.Samples != 20 || sizes.Min != 512 || sizes.Max != 2048 || sizes.P50 != 512 {
+		t.Errorf("Unexpected response size stats: %+v", sizes)
+	}
+	if histogram := result.ResponseSizeHistogram; histogram[0].Count != 15 || histogram[1].Count != 5 {
+		t.Errorf("Unexpected histogram: %+v", histogram)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCeilingConcurrency caps the workers of one search step
+const maxCeilingConcurrency = 2000
+
+// RPSCeilingConfig searches for the highest request rate the target sustains
+// within an SLO
+type RPSCeilingConfig struct {
+	Benchmark BenchmarkConfig `yaml:"benchmark"` // Target and transport; rate and request count are set per step
+
+	// Budgets every step must meet, e.g. p95=250ms,error_rate=1%; an rps
+	// budget is ignored since the search sets the rate
+	SLO []PerformanceBudget `yaml:"slo"`
+
+	StartRPS     float64       `yaml:"start_rps"`
+	MaxRPS       float64       `yaml:"max_rps"` // 0 = no limit
+	StepDuration time.Duration `yaml:"step_duration"`
+
+	// The search stops once the highest passing and lowest failing rates are
+	// within this fraction of each other, or after MaxSteps steps
+	Precision float64 `yaml:"precision"`
+	MaxSteps  int     `yaml:"max_steps"`
+
+	// Fraction of the offered rate a step must achieve; below it the target
+	// is not keeping up even if the requests it serves are fast
+	MinAchieved float64 `yaml:"min_achieved"`
+}
+
+// DefaultRPSCeilingConfig starts at 10 req/s with 10 second steps and stops
+// within 5% of the ceiling
+func DefaultRPSCeilingConfig() RPSCeilingConfig {
+	return RPSCeilingConfig{
+		SLO: []PerformanceBudget{
+			{Metric: BudgetMetricP95, Limit: 500},
+			{Metric: BudgetMetricErrorRate, Limit: 1},
+		},
+		StartRPS:     10,
+		StepDuration: 10 * time.Second,
+		Precision:    0.05,
+		MaxSteps:     20,
+		MinAchieved:  0.9,
+	}
+}
+
+// RPSStep is one rate tried by the search and the evidence for its verdict
+type RPSStep struct {
+	TargetRPS   float64        `json:"target_rps"`
+	AchievedRPS float64        `json:"achieved_rps"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	Latency     LatencyStats   `json:"latency"`
+	Budgets     []BudgetResult `json:"budgets"`
+	Passed      bool           `json:"passed"`
+	Reason      string         `json:"reason,omitempty"` // Why a step failed
+}
+
+// RPSCeilingResult is the outcome of a ceiling search
+type RPSCeilingResult struct {
+	Target    string              `json:"target"`
+	SLO       []PerformanceBudget `json:"slo"`
+	StartTime time.Time           `json:"start_time"`
+	Steps     []RPSStep           `json:"steps"` // In the order they ran
+
+	// Highest rate that met the SLO, or 0 if even StartRPS failed
+	MaxSustainableRPS float64 `json:"max_sustainable_rps"`
+
+	// Capped is set when MaxRPS passed, so the real ceiling is higher
+	Capped bool `json:"capped,omitempty"`
+
+	// Step after which latency rises fastest relative to throughput, among
+	// the steps in rate order; nil with fewer than three steps
+	Knee *RPSStep `json:"knee,omitempty"`
+}
+
+// FindRPSCeiling doubles the rate from config.StartRPS until a step breaks
+// the SLO, then bisects between the highest passing and lowest failing rate
+func FindRPSCeiling(ctx context.Context, config RPSCeilingConfig, progress func(RPSStep)) (*RPSCeilingResult, error) {
+	defaults := DefaultRPSCeilingConfig()
+	if len(config.SLO) == 0 {
+		config.SLO = defaults.SLO
+	}
+	if config.StartRPS <= 0 {
+		config.StartRPS = defaults.StartRPS
+	}
+	if config.StepDuration <= 0 {
+		config.StepDuration = defaults.StepDuration
+	}
+	if config.Precision <= 0 {
+		config.Precision = defaults.Precision
+	}
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = defaults.MaxSteps
+	}
+	if config.MinAchieved <= 0 {
+		config.MinAchieved = defaults.MinAchieved
+	}
+	if config.MaxRPS > 0 && config.StartRPS > config.MaxRPS {
+		return nil, fmt.Errorf("start rate %.2f req/s exceeds the maximum %.2f req/s", config.StartRPS, config.MaxRPS)
+	}
+
+	// One spend guard, so its budget caps the whole search
+	var spend *SpendGuard
+	if config.Benchmark.SpendGuard != nil {
+		spend = NewSpendGuard(config.Benchmark.SpendGuard)
+	}
+
+	result := &RPSCeilingResult{Target: config.Benchmark.TargetURL, SLO: config.SLO, StartTime: time.Now()}
+	passing, failing := 0.0, 0.0
+	for rate := config.StartRPS; len(result.Steps) < config.MaxSteps && ctx.Err() == nil; {
+		step, err := runRPSStep(ctx, config, rate, spend)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		result.Steps = append(result.Steps, step)
+		if progress != nil {
+			progress(step)
+		}
+
+		if step.Passed {
+			passing = rate
+		} else {
+			failing = rate
+		}
+		if failing == 0 {
+			if config.MaxRPS > 0 && rate >= config.MaxRPS {
+				result.Capped = true
+				break
+			}
+			rate *= 2
+			if config.MaxRPS > 0 {
+				rate = min(rate, config.MaxRPS)
+			}
+			continue
+		}
+		// Below one request per second there is no meaningful ceiling left
+		if failing-passing <= config.Precision*failing || failing < 1 {
+			break
+		}
+		rate = (passing + failing) / 2
+	}
+
+	result.MaxSustainableRPS = passing
+	result.Knee = findKnee(result.Steps)
+	return result, nil
+}
+
+// runRPSStep offers rate for one step and checks the outcome against the SLO
+func runRPSStep(ctx context.Context, config RPSCeilingConfig, rate float64, spend *SpendGuard) (RPSStep, error) {
+	benchmark := config.Benchmark
+	benchmark.TotalRequests = max(int(math.Ceil(rate*config.StepDuration.Seconds())), 1)
+	benchmark.RateLimit = DefaultRateLimiterConfig()
+	benchmark.RateLimit.Global = TokenBucketConfig{Rate: rate, Burst: 1}
+	benchmark.TargetRPS = 0
+	benchmark.IncludeRawMetrics = false
+
+	// Enough workers to keep the rate with up to a second of latency each;
+	// the rate limiter keeps the extra ones idle
+	benchmark.Concurrency = min(max(benchmark.Concurrency, int(math.Ceil(rate))), maxCeilingConcurrency)
+
+	b := NewBenchmarker(benchmark)
+	if spend != nil {
+		b.SetSpendGuard(spend)
+	}
+	benchResult, err := b.Run(ctx)
+	if err != nil {
+		return RPSStep{}, err
+	}
+	if stats := benchResult.Spend; stats != nil && stats.Exceeded && stats.Action == SpendAbort {
+		return RPSStep{}, fmt.Errorf("%w at %.2f req/s: $%.2f budget", ErrSpendLimit, rate, stats.Budget)
+	}
+	return evaluateRPSStep(rate, benchResult, config.SLO, config.MinAchieved), nil
+}
+
+// evaluateRPSStep judges one step's result against the SLO and the share of
+// the offered rate it achieved
+func evaluateRPSStep(rate float64, result *BenchmarkResult, slo []PerformanceBudget, minAchieved float64) RPSStep {
+	step := RPSStep{
+		TargetRPS:   rate,
+		AchievedRPS: result.RequestsPerSecond,
+		Requests:    result.TotalRequests,
+		Errors:      result.FailedReqs,
+		Latency:     result.LatencyStats,
+		Passed:      true,
+	}
+
+	summary := summarizeRun(&BenchmarkRun{Results: []*BenchmarkResult{result}})
+	var reasons []string
+	for _, budget := range slo {
+		if budget.Metric == BudgetMetricRPS {
+			continue
+		}
+		actual := summary.value(budget.Metric)
+		passed := actual <= budget.Limit
+		step.Budgets = append(step.Budgets, BudgetResult{Budget: budget, Actual: actual, Passed: passed})
+		if !passed {
+			reasons = append(reasons, fmt.Sprintf("%s (got %s)", budgetLabel(budget), formatBudgetValue(budget.Metric, actual)))
+		}
+	}
+	if step.AchievedRPS < rate*minAchieved {
+		reasons = append(reasons, fmt.Sprintf("achieved %.2f of %.2f req/s", step.AchievedRPS, rate))
+	}
+	if len(reasons) > 0 {
+		step.Passed = false
+		step.Reason = strings.Join(reasons, "; ")
+	}
+	return step
+}
+
+// findKnee returns the step at the knee of the throughput-latency curve:
+// with both axes scaled to [0, 1], the point furthest below the chord from
+// the lowest to the highest rate, where latency starts climbing
+func findKnee(steps []RPSStep) *RPSStep {
+	if len(steps) < 3 {
+		return nil
+	}
+	ordered := append([]RPSStep(nil), steps...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].TargetRPS < ordered[j].TargetRPS })
+
+	first, last := ordered[0], ordered[len(ordered)-1]
+	xSpan := last.AchievedRPS - first.AchievedRPS
+	lo, hi := first.Latency.P95, first.Latency.P95
+	for _, step := range ordered {
+		lo, hi = math.Min(lo, step.Latency.P95), math.Max(hi, step.Latency.P95)
+	}
+	if xSpan <= 0 || hi <= lo {
+		return nil
+	}
+
+	knee, best := -1, 0.0
+	for i, step := range ordered {
+		x := (step.AchievedRPS - first.AchievedRPS) / xSpan
+		y := (step.Latency.P95 - lo) / (hi - lo)
+		if gap := x - y; gap > best {
+			knee, best = i, gap
+		}
+	}
+	if knee < 0 {
+		return nil
+	}
+	return &ordered[knee]
+}
+
+// printRPSCeiling writes every step with its evidence, then the ceiling
+func printRPSCeiling(result *RPSCeilingResult) {
+	fmt.Printf("\n--- Throughput Ceiling ---\n")
+	fmt.Printf("%10s %10s %10s %10s %10s %8s  %s\n", "Target", "Achieved", "P50 ms", "P95 ms", "P99 ms", "Errors", "Verdict")
+	for _, step := range result.Steps {
+		verdict := "ok"
+		if !step.Passed {
+			verdict = "FAIL: " + step.Reason
+		}
+		fmt.Printf("%10.2f %10.2f %10.2f %10.2f %10.2f %8d  %s\n", step.TargetRPS, step.AchievedRPS,
+			step.Latency.P50, step.Latency.P95, step.Latency.P99, step.Errors, verdict)
+	}
+
+	fmt.Println()
+	switch {
+	case len(result.Steps) == 0:
+		fmt.Printf("Max sustainable rate: unknown; stopped before the first step finished\n")
+	case result.MaxSustainableRPS == 0:
+		fmt.Printf("Max sustainable rate: none; even %.2f req/s broke the SLO\n", result.Steps[0].TargetRPS)
+	case result.Capped:
+		fmt.Printf("Max sustainable rate: at least %.2f req/s (the -max-rps cap)\n", result.MaxSustainableRPS)
+	default:
+		fmt.Printf("Max sustainable rate: %.2f req/s\n", result.MaxSustainableRPS)
+	}
+	if result.Knee != nil {
+		fmt.Printf("Knee: latency starts climbing after %.2f req/s (P95 %.2f ms)\n", result.Knee.AchievedRPS, result.Knee.Latency.P95)
+	}
+}
+
+// SaveRPSCeiling writes result as JSON to dir, returning its path
+func SaveRPSCeiling(dir string, result *RPSCeilingResult) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ceiling result: %w", err)
+	}
+	path := filepath.Join(dir, "ceiling_"+result.StartTime.Format("20060102_150405")+".json")
+	return path, os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFindRPSCeiling tests the search against a server that handles one
+// request at a time, so its ceiling is about 1 / service time
+func TestFindRPSCeiling(t *testing.T) {
+	var serial sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serial.Lock()
+		time.Sleep(20 * time.Millisecond)
+		serial.Unlock()
+	}))
+	defer server.Close()
+
+	config := RPSCeilingConfig{
+		Benchmark:    BenchmarkConfig{TargetURL: server.URL, Timeout: 5 * time.Second, KeepAlive: true},
+		SLO:          []PerformanceBudget{{Metric: BudgetMetricP95, Limit: 100}},
+		StartRPS:     10,
+		StepDuration: 400 * time.Millisecond,
+		Precision:    0.25,
+	}
+	var progressed int
+	result, err := FindRPSCeiling(context.Background(), config, func(RPSStep) { progressed++ })
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if progressed != len(result.Steps) || len(result.Steps) < 3 {
+		t.Fatalf("Expected at least 3 reported steps, got %d steps and %d progress calls", len(result.Steps), progressed)
+	}
+	if result.MaxSustainableRPS < 10 || result.MaxSustainableRPS >= 80 {
+		t.Errorf("Expected a ceiling between 10 and 80 req/s for a 50 req/s server, got %.2f", result.MaxSustainableRPS)
+	}
+	for _, step := range result.Steps {
+		if step.Requests == 0 || len(step.Budgets) != 1 {
+			t.Errorf("Expected evidence for every step, got %+v", step)
+		}
+		if !step.Passed && step.Reason == "" {
+			t.Errorf("Expected a reason for the failed %.2f req/s step", step.TargetRPS)
+		}
+		if step.Passed && step.TargetRPS > result.MaxSustainableRPS {
+			t.Errorf("Step at %.2f req/s passed above the reported ceiling %.2f", step.TargetRPS, result.MaxSustainableRPS)
+		}
+	}
+}
+
+// TestFindRPSCeilingCapped tests that a search passing at -max-rps stops there
+func TestFindRPSCeilingCapped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	result, err := FindRPSCeiling(context.Background(), RPSCeilingConfig{
+		Benchmark:    BenchmarkConfig{TargetURL: server.URL, Timeout: time.Second},
+		StartRPS:     20,
+		MaxRPS:       30,
+		StepDuration: 200 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !result.Capped || result.MaxSustainableRPS != 30 || len(result.Steps) != 2 {
+		t.Errorf("Expected steps at 20 and 30 req/s and a capped ceiling, got %+v", result)
+	}
+}
+
+// TestEvaluateRPSStep tests the SLO and achieved-rate checks of a step
+func TestEvaluateRPSStep(t *testing.T) {
+	slo := []PerformanceBudget{
+		{Metric: BudgetMetricP95, Limit: 100},
+		{Metric: BudgetMetricErrorRate, Limit: 1},
+		{Metric: BudgetMetricRPS, Limit: 1000},
+	}
+	tests := []struct {
+		name   string
+		result BenchmarkResult
+		reason string
+	}{
+		{"within SLO", BenchmarkResult{RequestsPerSecond: 99, TotalRequests: 100, LatencyStats: LatencyStats{P95: 50}}, ""},
+		{"slow", BenchmarkResult{RequestsPerSecond: 99, TotalRequests: 100, LatencyStats: LatencyStats{P95: 150}}, "P95 <= 100.00 ms (got 150.00 ms)"},
+		{"errors", BenchmarkResult{RequestsPerSecond: 95, TotalRequests: 100, FailedReqs: 5}, "Error rate <= 1.00% (got 5.00%)"},
+		{"falling behind", BenchmarkResult{RequestsPerSecond: 60, TotalRequests: 100}, "achieved 60.00 of 100.00 req/s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := evaluateRPSStep(100, &tt.result, slo, 0.9)
+			if step.Passed != (tt.reason == "") || !strings.Contains(step.Reason, tt.reason) {
+				t.Errorf("Expected reason %q, got passed=%v reason %q", tt.reason, step.Passed, step.Reason)
+			}
+			if len(step.Budgets) != 2 {
+				t.Errorf("Expected the rps budget skipped, got %+v", step.Budgets)
+			}
+		})
+	}
+}
+
+// TestFindKnee tests that the knee is where latency turns upward
+func TestFindKnee(t *testing.T) {
+	step := func(rps, p95 float64) RPSStep {
+		return RPSStep{TargetRPS: rps, AchievedRPS: rps, Latency: LatencyStats{P95: p95}}
+	}
+	steps := []RPSStep{step(400, 400), step(100, 10), step(200, 12), step(300, 20), step(350, 120)}
+	knee := findKnee(steps)
+	if knee == nil || knee.TargetRPS != 300 {
+		t.Errorf("Expected the knee at 300 req/s, got %+v", knee)
+	}
+	if knee := findKnee(steps[:2]); knee != nil {
+		t.Errorf("Expected no knee from two steps, got %+v", knee)
+	}
+}
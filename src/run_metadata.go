@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// RunMetadata identifies the code and tool a benchmark ran with, so a
+// regression can be bisected to the change that caused it. Git fields
+// describe the checkout of the working directory and are empty outside one
+type RunMetadata struct {
+	GitCommit string `json:"git_commit,omitempty"`
+	GitBranch string `json:"git_branch,omitempty"` // Empty on a detached HEAD
+	GitDirty  bool   `json:"git_dirty,omitempty"`  // Tracked files had uncommitted changes
+
+	// The build variables of this binary
+	ToolVersion   string `json:"tool_version"`
+	ToolCommit    string `json:"tool_commit,omitempty"`
+	ToolBuildTime string `json:"tool_build_time,omitempty"`
+}
+
+// CurrentRunMetadata returns the metadata of this process, collected once
+var CurrentRunMetadata = sync.OnceValue(collectRunMetadata)
+
+// collectRunMetadata reads the build variables and asks git about the
+// working directory
+func collectRunMetadata() *RunMetadata {
+	metadata := &RunMetadata{
+		ToolVersion:   Version,
+		ToolCommit:    knownBuildVar(Commit),
+		ToolBuildTime: knownBuildVar(BuildTime),
+	}
+
+	metadata.GitCommit = gitOutput("rev-parse", "HEAD")
+	if metadata.GitCommit == "" {
+		return metadata
+	}
+	if branch := gitOutput("rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+		metadata.GitBranch = branch
+	}
+	metadata.GitDirty = gitOutput("status", "--porcelain", "--untracked-files=no") != ""
+	return metadata
+}
+
+// gitOutput runs git with args and returns its trimmed output, or "" on error
+func gitOutput(args ...string) string {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// knownBuildVar returns value unless it is the "unknown" default of a
+// variable not injected via -ldflags
+func knownBuildVar(value string) string {
+	if value == "unknown" {
+		return ""
+	}
+	return value
+}
+
+// String describes the metadata for reports, e.g.
+// "3f2a9c1d (main, dirty) with api-optimizer v2.0.0 (a1b2c3d)"
+func (m *RunMetadata) String() string {
+	if m == nil {
+		return "unknown"
+	}
+
+	code := "not a git checkout"
+	if m.GitCommit != "" {
+		code = shortCommit(m.GitCommit)
+		var details []string
+		if m.GitBranch != "" {
+			details = append(details, m.GitBranch)
+		}
+		if m.GitDirty {
+			details = append(details, "dirty")
+		}
+		if len(details) > 0 {
+			code += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+		}
+	}
+
+	tool := "v" + m.ToolVersion
+	if m.ToolCommit != "" {
+		tool += fmt.Sprintf(" (%s)", m.ToolCommit)
+	}
+	return fmt.Sprintf("%s with api-optimizer %s", code, tool)
+}
+
+// shortCommit abbreviates a git commit for reports
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
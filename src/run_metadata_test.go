@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestRunMetadataString tests the report description of run metadata
+func TestRunMetadataString(t *testing.T) {
+	tests := []struct {
+		metadata *RunMetadata
+		want     string
+	}{
+		{&RunMetadata{GitCommit: "3f2a9c1d0b7e55", GitBranch: "main", GitDirty: true, ToolVersion: "2.0.0", ToolCommit: "a1b2c3d"},
+			"3f2a9c1d (main, dirty) with api-optimizer v2.0.0 (a1b2c3d)"},
+		{&RunMetadata{GitCommit: "3f2a9c1d0b7e55", ToolVersion: "2.0.0"},
+			"3f2a9c1d with api-optimizer v2.0.0"},
+		{&RunMetadata{ToolVersion: "1.0.0"}, "not a git checkout with api-optimizer v1.0.0"},
+		{nil, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.metadata.String(); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+// TestCollectRunMetadata tests that the test's own checkout is recorded
+func TestCollectRunMetadata(t *testing.T) {
+	if exec.Command("git", "rev-parse", "HEAD").Run() != nil {
+		t.Skip("not running in a git checkout")
+	}
+
+	metadata := collectRunMetadata()
+	if len(metadata.GitCommit) != 40 {
+		t.Errorf("Expected a full commit hash, got %q", metadata.GitCommit)
+	}
+	if metadata.ToolVersion != Version || metadata.ToolCommit != "" {
+		t.Errorf("Expected version %s without an injected commit, got %+v", Version, metadata)
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -27,32 +29,96 @@ type BenchmarkSuite struct {
 	Runs               []BenchmarkRun `json:"runs"`
 	OutputDir          string         `json:"output_dir"`
 	ComparisonBaseline string         `json:"comparison_baseline,omitempty"`
+	Interrupted        bool           `json:"interrupted,omitempty"` // Cancelled before every run finished
+
+	// How often interim aggregates are printed and checkpointed during an
+	// iteration; zero uses DefaultFlushInterval
+	FlushInterval time.Duration `json:"flush_interval,omitempty"`
+
+	// Stable ID derived from the suite definition; see AssignIDs
+	ID string `json:"id,omitempty"`
+
+	// Restart ignores interrupted attempts of this suite instead of resuming them
+	Restart bool `json:"-"`
+
+	// EventSink streams request and iteration events as they happen; see
+	// NewEventSink. Left out of results since it may carry credentials
+	EventSink string `json:"-"`
+
+	// Webhooks fired as each run completes; left out of results since they
+	// may carry secrets
+	Webhooks []WebhookConfig `json:"-"`
+
+	// Code and tool version the suite ran with
+	Metadata *RunMetadata `json:"metadata,omitempty"`
+
+	// CISummary writes CI_SUMMARY.md and benchmark.om for CI job summaries,
+	// checking every run against Budgets
+	CISummary bool                `json:"ci_summary,omitempty"`
+	Budgets   []PerformanceBudget `json:"budgets,omitempty"`
 }
 
 // BenchmarkRun represents a single benchmark configuration
 type BenchmarkRun struct {
+	ID               string             `json:"id,omitempty"` // Stable ID keying the run's result file
 	Name             string             `json:"name"`
 	Config           BenchmarkConfig    `json:"config"`
 	Iterations       int                `json:"iterations"`
 	WarmupIterations int                `json:"warmup_iterations"`
 	LoadPattern      LoadPattern        `json:"load_pattern"`
 	Results          []*BenchmarkResult `json:"results,omitempty"`
+
+	// A/B mode: with two or more targets the same workload runs interleaved
+	// against each, and every target is compared with the first one
+	Targets           []string                      `json:"targets,omitempty"`
+	SignificanceLevel float64                       `json:"significance_level,omitempty"`
+	TargetResults     map[string][]*BenchmarkResult `json:"target_results,omitempty"`
+	Comparison        *ABComparison                 `json:"comparison,omitempty"`
+
+	// Connection experiment: with variants set the same workload runs
+	// interleaved under each keep-alive and idle pool setting, e.g.
+	// DefaultConnectionVariants
+	ConnectionVariants   []ConnectionVariant           `json:"connection_variants,omitempty"`
+	VariantResults       map[string][]*BenchmarkResult `json:"variant_results,omitempty"`
+	ConnectionExperiment *ConnectionExperiment         `json:"connection_experiment,omitempty"`
+
+	// Cold path mode: the same workload runs interleaved on the optimized
+	// path and with a new connection, DNS lookup and TLS handshake per request
+	ColdPath           bool                          `json:"cold_path,omitempty"`
+	PathResults        map[string][]*BenchmarkResult `json:"path_results,omitempty"`
+	ColdPathComparison *ColdPathComparison           `json:"cold_path_comparison,omitempty"`
 }
 
 // BenchmarkRunner orchestrates benchmark execution with multiple iterations
 type BenchmarkRunner struct {
-	suite     *BenchmarkSuite
-	resultDir string
+	suite        *BenchmarkSuite
+	resultDir    string
+	checkpointMu sync.Mutex
+	events       *EventPublisher // Set while Run streams to the suite's EventSink
 }
 
-// NewBenchmarkRunner creates a new runner for the given suite
+// NewBenchmarkRunner creates a new runner for the given suite. If an earlier
+// attempt of the same suite was interrupted, the runner picks up its results
+// and result directory so completed runs and iterations are skipped
 func NewBenchmarkRunner(suite *BenchmarkSuite) *BenchmarkRunner {
 	if suite.OutputDir == "" {
 		suite.OutputDir = "./benchmarks/results"
 	}
+	suite.AssignIDs()
+
+	if !suite.Restart {
+		if checkpoint, found := findResumableCheckpoint(suite.OutputDir, suite.ID); found {
+			suite.restoreResults(checkpoint.Suite)
+			fmt.Printf("Resuming interrupted suite %s from %s\n", suite.ID, checkpoint.ResultDir)
+			return &BenchmarkRunner{
+				suite:     suite,
+				resultDir: checkpoint.ResultDir,
+			}
+		}
+	}
 
-	resultDir := filepath.Join(suite.OutputDir, fmt.Sprintf("%s_%s",
-		suite.Name, time.Now().Format("20060102_150405")))
+	resultDir := uniqueDir(filepath.Join(suite.OutputDir, fmt.Sprintf("%s_%s",
+		suite.ID, time.Now().Format("20060102_150405"))))
 
 	return &BenchmarkRunner{
 		suite:     suite,
@@ -60,33 +126,71 @@ func NewBenchmarkRunner(suite *BenchmarkSuite) *BenchmarkRunner {
 	}
 }
 
-// Run executes all benchmark runs in the suite
+// Run executes all benchmark runs in the suite. When ctx is cancelled the
+// remaining runs are skipped and everything completed so far is still saved
 func (r *BenchmarkRunner) Run(ctx context.Context) error {
 	// Create output directory
 	if err := os.MkdirAll(r.resultDir, 0755); err != nil {
 		return fmt.Errorf("failed to create result directory: %w", err)
 	}
 
+	if r.suite.Metadata == nil {
+		r.suite.Metadata = CurrentRunMetadata()
+	}
+
+	if err := r.openEvents(ctx); err != nil {
+		return err
+	}
+	defer r.closeEvents()
+
 	fmt.Printf("\n=== Starting Benchmark Suite: %s ===\n", r.suite.Name)
 	fmt.Printf("Description: %s\n", r.suite.Description)
+	fmt.Printf("Suite ID: %s\n", r.suite.ID)
 	fmt.Printf("Output Directory: %s\n\n", r.resultDir)
 
 	// Execute each benchmark run
 	for i := range r.suite.Runs {
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted: skipping %d remaining run(s)\n", len(r.suite.Runs)-i)
+			break
+		}
+
 		run := &r.suite.Runs[i]
+		if runFinished(run) {
+			fmt.Printf("\n--- Benchmark Run: %s (%s) already complete, skipping ---\n", run.Name, run.ID)
+			continue
+		}
 
-		fmt.Printf("\n--- Benchmark Run: %s ---\n", run.Name)
+		fmt.Printf("\n--- Benchmark Run: %s (%s) ---\n", run.Name, run.ID)
 
-		if err := r.executeRun(ctx, run); err != nil {
+		if err := r.executeRun(ctx, i, run); err != nil {
 			fmt.Printf("ERROR: Run failed: %v\n", err)
-			continue
+			// An interrupted or aborted run still saves the iterations it
+			// completed
+			if (ctx.Err() == nil && !errors.Is(err, ErrClientSaturated)) || len(run.Results) == 0 {
+				continue
+			}
 		}
 
 		// Save individual run results
-		runFile := filepath.Join(r.resultDir, fmt.Sprintf("%s.json", run.Name))
+		runFile := filepath.Join(r.resultDir, fmt.Sprintf("%s.json", run.ID))
 		if err := r.saveRunResults(run, runFile); err != nil {
 			fmt.Printf("WARNING: Failed to save run results: %v\n", err)
 		}
+		if runFinished(run) {
+			r.notifyWebhooks(run, runFile)
+		}
+	}
+
+	r.suite.Interrupted = ctx.Err() != nil
+
+	// The checkpoint stays resumable unless every run finished
+	runIndex := len(r.suite.Runs)
+	if r.suite.Interrupted {
+		runIndex = r.firstUnfinishedRun()
+	}
+	if err := r.writeCheckpoint(runIndex, nil, !r.suite.Interrupted); err != nil {
+		fmt.Printf("WARNING: Failed to write checkpoint: %v\n", err)
 	}
 
 	// Save complete suite results
@@ -97,6 +201,15 @@ func (r *BenchmarkRunner) Run(ctx context.Context) error {
 
 	// Generate summary report
 	r.generateSummaryReport()
+	if r.suite.CISummary || len(r.suite.Budgets) > 0 {
+		r.generateCISummary()
+	}
+
+	if r.suite.Interrupted {
+		fmt.Printf("\n=== Benchmark Suite Interrupted ===\n")
+		fmt.Printf("Partial results saved to: %s\n", r.resultDir)
+		return fmt.Errorf("benchmark suite interrupted: %w", ctx.Err())
+	}
 
 	fmt.Printf("\n=== Benchmark Suite Complete ===\n")
 	fmt.Printf("Results saved to: %s\n", r.resultDir)
@@ -104,53 +217,192 @@ func (r *BenchmarkRunner) Run(ctx context.Context) error {
 	return nil
 }
 
-// executeRun runs a single benchmark configuration with iterations
-func (r *BenchmarkRunner) executeRun(ctx context.Context, run *BenchmarkRun) error {
+// firstUnfinishedRun returns the index of the first run with iterations left
+func (r *BenchmarkRunner) firstUnfinishedRun() int {
+	for i := range r.suite.Runs {
+		if !runFinished(&r.suite.Runs[i]) {
+			return i
+		}
+	}
+	return len(r.suite.Runs)
+}
+
+// executeRun runs a single benchmark configuration with iterations. Results
+// already present, e.g. from a checkpoint, count as completed iterations
+func (r *BenchmarkRunner) executeRun(ctx context.Context, runIndex int, run *BenchmarkRun) error {
+	if len(run.Targets) > 1 {
+		return r.executeABRun(ctx, runIndex, run)
+	}
+	if len(run.ConnectionVariants) > 0 {
+		return r.executeConnectionRun(ctx, runIndex, run)
+	}
+	if run.ColdPath {
+		return r.executeColdPathRun(ctx, runIndex, run)
+	}
+
+	// One limiter spans warmup and all iterations so limits hold across them
+	var limiter *RateLimiter
+	if run.Config.RateLimit != nil {
+		limiter = NewRateLimiter(run.Config.RateLimit)
+	}
+	// One spend guard, so its budget caps warmup and every iteration together
+	var spend *SpendGuard
+	if run.Config.SpendGuard != nil {
+		spend = NewSpendGuard(run.Config.SpendGuard)
+	}
+	// Likewise one auth provider, so tokens are reused rather than refetched
+	var auth AuthProvider
+	if run.Config.Auth != nil {
+		provider, err := NewAuthProvider(run.Config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to configure auth: %w", err)
+		}
+		auth = provider
+	}
+	// Every iteration is seeded differently, but the same way on every run
+	newBenchmarker := func(iteration int) *Benchmarker {
+		config := run.Config
+		config.Seed = IterationSeed(run.Config.Seed, iteration)
+		benchmarker := NewBenchmarker(config)
+		if limiter != nil {
+			benchmarker.SetRateLimiter(limiter)
+		}
+		if auth != nil {
+			benchmarker.SetAuthProvider(auth)
+		}
+		if spend != nil {
+			benchmarker.SetSpendGuard(spend)
+		}
+		r.watchProgress(benchmarker, runIndex)
+		return benchmarker
+	}
+
 	// Warmup phase
 	if run.WarmupIterations > 0 {
 		fmt.Printf("Warmup: Running %d iterations...\n", run.WarmupIterations)
+		var warmup *BenchmarkResult
 		for i := 0; i < run.WarmupIterations; i++ {
-			benchmarker := NewBenchmarker(run.Config)
-			_, err := benchmarker.Run(ctx)
+			benchmarker := newBenchmarker(-(i + 1))
+			result, err := benchmarker.Run(ctx)
+			if ctx.Err() != nil {
+				return fmt.Errorf("warmup interrupted: %w", ctx.Err())
+			}
 			if err != nil {
 				fmt.Printf("Warmup iteration %d failed: %v\n", i+1, err)
+			} else {
+				warmup = result
 			}
 		}
+		// Warn before the measured iterations when the target rate is out of reach
+		if warmup != nil && warmup.LittlesLaw != nil && !warmup.LittlesLaw.Achievable {
+			fmt.Printf("WARNING: %s\n", warmup.LittlesLaw.Warning)
+		}
 		fmt.Printf("Warmup complete\n\n")
 	}
 
 	// Main benchmark iterations
-	run.Results = make([]*BenchmarkResult, 0, run.Iterations)
+	if len(run.Results) > 0 {
+		fmt.Printf("Resuming after %d completed iteration(s)\n", len(run.Results))
+	} else {
+		run.Results = make([]*BenchmarkResult, 0, run.Iterations)
+	}
+
+	// Aggregate whatever completed, even if the run is interrupted
+	defer r.calculateAggregateStats(run)
 
-	for i := 0; i < run.Iterations; i++ {
+	runFile := filepath.Join(r.resultDir, fmt.Sprintf("%s.json", run.ID))
+	for i := len(run.Results); i < run.Iterations; i++ {
 		fmt.Printf("Iteration %d/%d...\n", i+1, run.Iterations)
 
-		benchmarker := NewBenchmarker(run.Config)
+		benchmarker := newBenchmarker(i + 1)
+		r.streamRequests(benchmarker, run, i+1, "")
 		result, err := benchmarker.Run(ctx)
 
 		if err != nil {
 			return fmt.Errorf("iteration %d failed: %w", i+1, err)
 		}
+		if result.Partial {
+			if completed := result.SuccessfulReqs + result.FailedReqs; completed > 0 {
+				run.Results = append(run.Results, result)
+				r.publishIteration(run, i+1, "", result)
+				fmt.Printf("  Interrupted after %d requests\n", completed)
+			}
+			return fmt.Errorf("iteration %d interrupted: %w", i+1, interruptedBy(ctx, result))
+		}
 
 		run.Results = append(run.Results, result)
+		r.publishIteration(run, i+1, "", result)
 
 		// Print iteration summary
 		fmt.Printf("  Successful: %d | Failed: %d | RPS: %.2f | P95: %.2f ms\n",
 			result.SuccessfulReqs, result.FailedReqs,
 			result.RequestsPerSecond, result.LatencyStats.P95)
+		if result.Workload != nil {
+			fmt.Printf("  Prompt tokens: avg %.0f (%d-%d) | Avg max_tokens: %.0f\n",
+				result.Workload.AvgPromptTokens, result.Workload.MinPromptTokens,
+				result.Workload.MaxPromptTokens, result.Workload.AvgMaxTokens)
+		}
+		if analysis := result.LittlesLaw; analysis != nil && !analysis.Achievable {
+			fmt.Printf("  WARNING: %s\n", analysis.Warning)
+		}
+		if fairness := result.WorkerFairness; fairness != nil && fairness.Skewed {
+			fmt.Printf("  WARNING: worker %d's P50 is %.2fx the median worker's (fairness index %.3f); the client may be the bottleneck\n",
+				fairness.SlowestWorker, fairness.Skew, fairness.Index)
+		}
+		if guard := result.Guardrails; guard != nil {
+			for _, reduction := range guard.Reductions {
+				fmt.Printf("  WARNING: load generator CPU at %.0f%%; concurrency reduced from %d to %d after %v\n",
+					reduction.CPU*100, reduction.From, reduction.To, reduction.At.Round(time.Millisecond))
+			}
+		}
+		if result.Prime != nil {
+			fmt.Printf("  Primed: %d connection(s), DNS %.2f ms, %.2f ms unmeasured",
+				result.Prime.Connections, result.Prime.DNS, result.Prime.Duration)
+			if result.Prime.Errors > 0 {
+				fmt.Printf(" (%d error(s): %s)", result.Prime.Errors, result.Prime.Error)
+			}
+			fmt.Println()
+		}
+
+		// Flush every completed iteration so a crash loses at most one
+		if err := r.saveRunResults(run, runFile); err != nil {
+			fmt.Printf("WARNING: Failed to save run results: %v\n", err)
+		}
+		if err := r.writeCheckpoint(runIndex, nil, false); err != nil {
+			fmt.Printf("WARNING: Failed to write checkpoint: %v\n", err)
+		}
 
 		// Small delay between iterations to avoid overwhelming the target
 		if i < run.Iterations-1 {
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return fmt.Errorf("interrupted after iteration %d: %w", i+1, err)
+			}
 		}
 	}
 
-	// Calculate aggregate statistics
-	r.calculateAggregateStats(run)
+	if limiter != nil {
+		for _, level := range limiter.Levels() {
+			fmt.Printf("  Rate limit %s %s: %.1f/%.0f tokens (%.2f/s)\n",
+				level.Scope, level.Key, level.Tokens, level.Capacity, level.Rate)
+		}
+	}
 
 	return nil
 }
 
+// sleepContext pauses for d, returning early with ctx's error if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // calculateAggregateStats computes statistics across multiple iterations
 func (r *BenchmarkRunner) calculateAggregateStats(run *BenchmarkRun) {
 	if len(run.Results) == 0 {
@@ -190,6 +442,9 @@ func (r *BenchmarkRunner) calculateAggregateStats(run *BenchmarkRun) {
 	fmt.Printf("Avg P50 Latency: %.2f ms\n", totalP50/count)
 	fmt.Printf("Avg P95 Latency: %.2f ms (min: %.2f, max: %.2f)\n", totalP95/count, minP95, maxP95)
 	fmt.Printf("Avg P99 Latency: %.2f ms\n", totalP99/count)
+	for _, recommendation := range uniqueRecommendations(run.Results) {
+		fmt.Printf("Load generator: recommend %s=%s (%s)\n", recommendation.Setting, recommendation.Value, recommendation.Reason)
+	}
 }
 
 // saveRunResults saves results for a single benchmark run
@@ -217,6 +472,13 @@ func (r *BenchmarkRunner) generateSummaryReport() {
 	report := fmt.Sprintf("# Benchmark Suite Summary: %s\n\n", r.suite.Name)
 	report += fmt.Sprintf("**Description:** %s\n\n", r.suite.Description)
 	report += fmt.Sprintf("**Run Date:** %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	report += fmt.Sprintf("**Code:** %s\n\n", r.suite.Metadata)
+	if env := r.firstEnvironment(); env != nil {
+		report += fmt.Sprintf("**Environment:** %s\n\n", env)
+	}
+	if r.suite.Interrupted {
+		report += "**Status:** Interrupted, results are partial\n\n"
+	}
 	report += "---\n\n"
 
 	for _, run := range r.suite.Runs {
@@ -228,16 +490,61 @@ func (r *BenchmarkRunner) generateSummaryReport() {
 		report += fmt.Sprintf("- **Target:** %s\n", run.Config.TargetURL)
 		report += fmt.Sprintf("- **Requests:** %d\n", run.Config.TotalRequests)
 		report += fmt.Sprintf("- **Concurrency:** %d\n", run.Config.Concurrency)
-		report += fmt.Sprintf("- **Iterations:** %d\n\n", run.Iterations)
+		report += fmt.Sprintf("- **Iterations:** %d\n", run.Iterations)
+		if workload := run.Config.Workload; workload != nil {
+			report += fmt.Sprintf("- **Workload:** %s (%s, %s prompt lengths)\n",
+				workload.CorpusPath, workload.Format, workload.LengthDistribution)
+		}
+		if data := run.Config.Data; data != nil {
+			report += fmt.Sprintf("- **Data:** %s\n", data.File)
+		}
+		report += "\n"
 
 		// Calculate averages
-		var avgRPS, avgP50, avgP95, avgP99, avgTTFB float64
+		var avgRPS, avgP50, avgP95, avgP99, avgTTFB, avgServer, avgDownload float64
+		var avgPromptTokens, avgMaxTokens float64
+		var avgGoodput, avgSizeP50, avgSizeP95 float64
+		var totalBytes int64
+		var hinted, avgHintRate, avgHintLead float64
+		var queued, avgQueueP50, avgQueueP95 float64
+		var fair, avgFairness float64
+		var skewed, saturated int
 		for _, result := range run.Results {
+			if result.Guardrails != nil && result.Guardrails.Saturated {
+				saturated++
+			}
+			if result.WorkerFairness != nil {
+				fair++
+				avgFairness += result.WorkerFairness.Index
+				if result.WorkerFairness.Skewed {
+					skewed++
+				}
+			}
+			if result.QueueStats.Samples > 0 {
+				queued++
+				avgQueueP50 += result.QueueStats.P50
+				avgQueueP95 += result.QueueStats.P95
+			}
+			if result.EarlyHints != nil {
+				hinted++
+				avgHintRate += result.EarlyHints.Rate
+				avgHintLead += result.EarlyHints.LeadTime.P50
+			}
+			avgGoodput += result.GoodputMBps
+			avgSizeP50 += result.ResponseSizeStats.P50
+			avgSizeP95 += result.ResponseSizeStats.P95
+			totalBytes += result.TotalBytes
 			avgRPS += result.RequestsPerSecond
 			avgP50 += result.LatencyStats.P50
 			avgP95 += result.LatencyStats.P95
 			avgP99 += result.LatencyStats.P99
 			avgTTFB += result.TTFBStats.P95
+			avgServer += result.ServerStats.P95
+			avgDownload += result.DownloadStats.P95
+			if result.Workload != nil {
+				avgPromptTokens += result.Workload.AvgPromptTokens
+				avgMaxTokens += result.Workload.AvgMaxTokens
+			}
 		}
 		count := float64(len(run.Results))
 
@@ -248,7 +555,77 @@ func (r *BenchmarkRunner) generateSummaryReport() {
 		report += fmt.Sprintf("| Avg P50 Latency | %.2f ms |\n", avgP50/count)
 		report += fmt.Sprintf("| Avg P95 Latency | %.2f ms |\n", avgP95/count)
 		report += fmt.Sprintf("| Avg P99 Latency | %.2f ms |\n", avgP99/count)
-		report += fmt.Sprintf("| Avg P95 TTFB | %.2f ms |\n\n", avgTTFB/count)
+		report += fmt.Sprintf("| Avg P95 TTFB | %.2f ms |\n", avgTTFB/count)
+		report += fmt.Sprintf("| Avg P95 Server Processing | %.2f ms |\n", avgServer/count)
+		report += fmt.Sprintf("| Avg P95 Content Download | %.2f ms |\n", avgDownload/count)
+		if queued > 0 {
+			report += fmt.Sprintf("| Avg P50 Queue Time | %.2f ms |\n", avgQueueP50/queued)
+			report += fmt.Sprintf("| Avg P95 Queue Time | %.2f ms |\n", avgQueueP95/queued)
+		}
+		report += fmt.Sprintf("| Avg Goodput | %.3f MB/s |\n", avgGoodput/count)
+		report += fmt.Sprintf("| Avg P50 Response Size | %s |\n", formatBytes(int64(avgSizeP50/count)))
+		report += fmt.Sprintf("| Avg P95 Response Size | %s |\n", formatBytes(int64(avgSizeP95/count)))
+		report += fmt.Sprintf("| Total Transferred | %s |\n", formatBytes(totalBytes))
+		if avgPromptTokens > 0 {
+			report += fmt.Sprintf("| Avg Prompt Tokens | %.0f |\n", avgPromptTokens/count)
+			report += fmt.Sprintf("| Avg max_tokens | %.0f |\n", avgMaxTokens/count)
+		}
+		if hinted > 0 {
+			report += fmt.Sprintf("| Avg 103 Early Hints Rate | %.1f%% |\n", avgHintRate/count*100)
+			report += fmt.Sprintf("| Avg P50 Early Hint Lead | %.2f ms |\n", avgHintLead/hinted)
+		}
+		if fair > 0 {
+			report += fmt.Sprintf("| Avg Worker Fairness Index | %.3f |\n", avgFairness/fair)
+		}
+		report += "\n"
+		if skewed > 0 {
+			report += fmt.Sprintf("⚠️ Latency was skewed across workers in %d of %d iterations; the client machine may have been the bottleneck.\n\n",
+				skewed, len(run.Results))
+		}
+		if saturated > 0 {
+			report += fmt.Sprintf("⚠️ The load generator saturated its CPU in %d of %d iterations; latencies may be inflated.\n\n",
+				saturated, len(run.Results))
+		}
+
+		if histogram := mergeSizeHistograms(run.Results); histogram != nil {
+			report += "### Response Sizes\n\n"
+			report += "| Size | Responses |\n"
+			report += "|------|-----------|\n"
+			for _, bucket := range histogram {
+				if bucket.Count > 0 {
+					report += fmt.Sprintf("| %s | %d |\n", bucket.Label(), bucket.Count)
+				}
+			}
+			report += "\n"
+		}
+
+		if recommendations := uniqueRecommendations(run.Results); len(recommendations) > 0 {
+			report += "### Load Generator Tuning\n\n"
+			report += "| Setting | Value | Reason |\n"
+			report += "|---------|-------|--------|\n"
+			for _, recommendation := range recommendations {
+				report += fmt.Sprintf("| %s | %s | %s |\n", recommendation.Setting, recommendation.Value, recommendation.Reason)
+			}
+			report += "\n"
+		}
+
+		report += protocolSection(run.Results)
+		report += rotationSection(run.Results)
+		report += redirectSection(run.Results)
+		report += responseHeaderSection(run.Results)
+		report += checkSection(run.Results)
+		report += cacheKeySection(run.Results)
+		report += concurrencySection(run.Results)
+
+		if run.Comparison != nil {
+			report += abComparisonSection(run.Comparison)
+		}
+		if run.ConnectionExperiment != nil {
+			report += connectionExperimentSection(run.ConnectionExperiment)
+		}
+		if run.ColdPathComparison != nil {
+			report += coldPathSection(run.ColdPathComparison)
+		}
 	}
 
 	os.WriteFile(reportPath, []byte(report), 0644)
@@ -257,28 +634,30 @@ func (r *BenchmarkRunner) generateSummaryReport() {
 
 // CompareWithBaseline compares current results with a baseline benchmark
 func (r *BenchmarkRunner) CompareWithBaseline(baselinePath string) error {
-	// Load baseline data
-	baselineData, err := os.ReadFile(baselinePath)
+	// Load baseline data, saved by apilo or imported from k6, vegeta or wrk2
+	baseline, err := LoadBaseline(baselinePath)
 	if err != nil {
-		return fmt.Errorf("failed to read baseline: %w", err)
-	}
-
-	var baseline BenchmarkSuite
-	if err := json.Unmarshal(baselineData, &baseline); err != nil {
-		return fmt.Errorf("failed to parse baseline: %w", err)
+		return err
 	}
 
 	// Generate comparison report
 	reportPath := filepath.Join(r.resultDir, "COMPARISON.md")
 	report := "# Benchmark Comparison Report\n\n"
-	report += fmt.Sprintf("**Current:** %s\n", r.suite.Name)
-	report += fmt.Sprintf("**Baseline:** %s\n\n", baseline.Name)
+	report += fmt.Sprintf("**Current:** %s at %s\n", r.suite.Name, r.suite.Metadata)
+	report += fmt.Sprintf("**Baseline:** %s", baseline.Name)
+	if baseline.Metadata != nil {
+		report += fmt.Sprintf(" at %s", baseline.Metadata)
+	}
+	report += "\n\n"
 	report += "---\n\n"
 
 	// Compare matching runs
+	// A baseline with a single run, such as an imported one, is compared
+	// against every run when no names match
+	matchAny := len(baseline.Runs) == 1 && !r.hasRun(baseline.Runs[0].Name)
 	for _, currentRun := range r.suite.Runs {
 		for _, baselineRun := range baseline.Runs {
-			if currentRun.Name != baselineRun.Name {
+			if currentRun.Name != baselineRun.Name && !matchAny {
 				continue
 			}
 
@@ -292,6 +671,28 @@ func (r *BenchmarkRunner) CompareWithBaseline(baselinePath string) error {
 	return nil
 }
 
+// firstEnvironment returns the environment of the suite's first result
+func (r *BenchmarkRunner) firstEnvironment() *EnvironmentFingerprint {
+	for _, run := range r.suite.Runs {
+		for _, result := range run.Results {
+			if result.Environment != nil {
+				return result.Environment
+			}
+		}
+	}
+	return nil
+}
+
+// hasRun reports whether the current suite has a run called name
+func (r *BenchmarkRunner) hasRun(name string) bool {
+	for _, run := range r.suite.Runs {
+		if run.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // generateComparisonSection creates a comparison for two benchmark runs
 func (r *BenchmarkRunner) generateComparisonSection(current, baseline *BenchmarkRun) string {
 	if len(current.Results) == 0 || len(baseline.Results) == 0 {
@@ -325,7 +726,31 @@ func (r *BenchmarkRunner) generateComparisonSection(current, baseline *Benchmark
 	section += "| Metric | Baseline | Current | Change |\n"
 	section += "|--------|----------|---------|--------|\n"
 	section += fmt.Sprintf("| Requests/sec | %.2f | %.2f | %.1f%% |\n", baseRPS, currRPS, rpsChange)
-	section += fmt.Sprintf("| P95 Latency | %.2f ms | %.2f ms | %.1f%% |\n\n", baseP95, currP95, p95Change)
+	section += fmt.Sprintf("| P95 Latency | %.2f ms | %.2f ms | %.1f%% |\n", baseP95, currP95, p95Change)
+
+	// Phase breakdown shows whether a change came from the server or the network
+	for _, phase := range LatencyPhases {
+		basePhase := meanPhaseP95(baseline.Results, phase)
+		currPhase := meanPhaseP95(current.Results, phase)
+		if basePhase == 0 && currPhase == 0 {
+			continue
+		}
+		change := "n/a"
+		if basePhase > 0 {
+			change = fmt.Sprintf("%.1f%%", (currPhase-basePhase)/basePhase*100)
+		}
+		section += fmt.Sprintf("| P95 %s | %.2f ms | %.2f ms | %s |\n", PhaseLabel(phase), basePhase, currPhase, change)
+	}
+	section += "\n"
+
+	// Differences in hardware or limits can explain a change on their own
+	if diffs := environmentDifferences(baseline.Results, current.Results); len(diffs) > 0 {
+		section += "⚠️ **Environment differs from the baseline; this comparison may not be valid:**\n\n"
+		for _, diff := range diffs {
+			section += fmt.Sprintf("- %s\n", diff)
+		}
+		section += "\n"
+	}
 
 	if rpsChange > 5 {
 		section += "✅ **Improvement:** Throughput increased significantly\n\n"
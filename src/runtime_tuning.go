@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+)
+
+// Runtime metrics read around each measured iteration
+const (
+	metricGCPauses      = "/sched/pauses/total/gc:seconds"
+	metricSchedLatency  = "/sched/latencies:seconds"
+	metricGCCycles      = "/gc/cycles/total:gc-cycles"
+	metricGCCPU         = "/cpu/classes/gc/total:cpu-seconds"
+	metricTotalCPU      = "/cpu/classes/total:cpu-seconds"
+	metricIdleCPU       = "/cpu/classes/idle:cpu-seconds"
+	metricGOGC          = "/gc/gogc:percent"
+	metricGOMEMLIMIT    = "/gc/gomemlimit:bytes"
+	metricHeapLiveBytes = "/gc/heap/live:bytes"
+)
+
+// RuntimeProfile describes how the load generator's Go runtime behaved during
+// an iteration: time lost to garbage collection and to goroutines waiting for
+// a thread both end up in the measured latencies
+type RuntimeProfile struct {
+	GCCycles      uint64  `json:"gc_cycles"`
+	GCCPUFraction float64 `json:"gc_cpu_fraction"` // Of the CPU time the process used
+
+	// Stop-the-world GC pauses
+	GCPauseTotal float64 `json:"gc_pause_total_ms"`
+	GCPauseP99   float64 `json:"gc_pause_p99_ms"`
+	GCPauseMax   float64 `json:"gc_pause_max_ms"`
+
+	// Time runnable goroutines waited to be scheduled
+	SchedLatencyP50 float64 `json:"sched_latency_p50_ms"`
+	SchedLatencyP99 float64 `json:"sched_latency_p99_ms"`
+
+	// Settings in effect; GOMEMLIMIT is zero when unlimited
+	GOGC       int   `json:"gogc"`
+	GOMEMLIMIT int64 `json:"gomemlimit_bytes,omitempty"`
+	GOMAXPROCS int   `json:"gomaxprocs"`
+	LiveHeap   int64 `json:"live_heap_bytes"`
+
+	Recommendations []RuntimeRecommendation `json:"recommendations,omitempty"`
+}
+
+// RuntimeRecommendation is a suggested environment variable for the next run
+type RuntimeRecommendation struct {
+	Setting string `json:"setting"` // GOGC, GOMEMLIMIT or GOMAXPROCS
+	Value   string `json:"value"`
+	Reason  string `json:"reason"`
+}
+
+// runtimeSnapshot holds the cumulative runtime metrics at one instant
+type runtimeSnapshot []metrics.Sample
+
+// takeRuntimeSnapshot reads the runtime metrics profiled
+func takeRuntimeSnapshot() runtimeSnapshot {
+	names := []string{metricGCPauses, metricSchedLatency, metricGCCycles, metricGCCPU,
+		metricTotalCPU, metricIdleCPU, metricGOGC, metricGOMEMLIMIT, metricHeapLiveBytes}
+	samples := make([]metrics.Sample, len(names))
+	for i, name := range names {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	return samples
+}
+
+// value returns the named sample, or a zero value if it is unsupported
+func (s runtimeSnapshot) value(name string) metrics.Value {
+	for _, sample := range s {
+		if sample.Name == name {
+			return sample.Value
+		}
+	}
+	return metrics.Value{}
+}
+
+// uint64, float64 and histogram return the named sample's value, or zero
+// if it has another kind
+func (s runtimeSnapshot) uint64(name string) uint64 {
+	if v := s.value(name); v.Kind() == metrics.KindUint64 {
+		return v.Uint64()
+	}
+	return 0
+}
+
+func (s runtimeSnapshot) float64(name string) float64 {
+	if v := s.value(name); v.Kind() == metrics.KindFloat64 {
+		return v.Float64()
+	}
+	return 0
+}
+
+func (s runtimeSnapshot) histogram(name string) *metrics.Float64Histogram {
+	if v := s.value(name); v.Kind() == metrics.KindFloat64Histogram {
+		return v.Float64Histogram()
+	}
+	return nil
+}
+
+// profileRuntime describes the runtime between two snapshots and recommends
+// settings, weighing pauses and scheduling delay against the measured P50
+// latency in milliseconds
+func profileRuntime(before, after runtimeSnapshot, latencyP50 float64) *RuntimeProfile {
+	profile := &RuntimeProfile{
+		GCCycles:   after.uint64(metricGCCycles) - before.uint64(metricGCCycles),
+		GOGC:       int(after.uint64(metricGOGC)),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		LiveHeap:   int64(after.uint64(metricHeapLiveBytes)),
+	}
+	if limit := after.uint64(metricGOMEMLIMIT); limit < math.MaxInt64 {
+		profile.GOMEMLIMIT = int64(limit)
+	}
+
+	// Idle time is the CPU time the process did not use
+	used := (after.float64(metricTotalCPU) - after.float64(metricIdleCPU)) - (before.float64(metricTotalCPU) - before.float64(metricIdleCPU))
+	if used > 0 {
+		profile.GCCPUFraction = (after.float64(metricGCCPU) - before.float64(metricGCCPU)) / used
+	}
+
+	if pauses := histogramDelta(before.histogram(metricGCPauses), after.histogram(metricGCPauses)); pauses != nil {
+		profile.GCPauseTotal = pauses.sum() * 1000
+		profile.GCPauseP99 = pauses.quantile(0.99) * 1000
+		profile.GCPauseMax = pauses.quantile(1) * 1000
+	}
+	if sched := histogramDelta(before.histogram(metricSchedLatency), after.histogram(metricSchedLatency)); sched != nil {
+		profile.SchedLatencyP50 = sched.quantile(0.5) * 1000
+		profile.SchedLatencyP99 = sched.quantile(0.99) * 1000
+	}
+
+	profile.Recommendations = recommendRuntimeSettings(profile, hostEnvironment(), latencyP50)
+	return profile
+}
+
+// recommendRuntimeSettings applies rules of thumb to a profile
+func recommendRuntimeSettings(profile *RuntimeProfile, env EnvironmentFingerprint, latencyP50 float64) []RuntimeRecommendation {
+	var recommendations []RuntimeRecommendation
+
+	// GC costing over a tenth of the CPU, or pausing for a noticeable part of
+	// a typical request, trades memory for latency poorly
+	gcHeavy := profile.GCCPUFraction > 0.10
+	pausesHurt := latencyP50 > 0 && profile.GCPauseP99 > latencyP50*0.05
+	if profile.GOGC > 0 && (gcHeavy || pausesHurt) {
+		reason := fmt.Sprintf("GC used %.0f%% of the CPU over %d cycles", profile.GCCPUFraction*100, profile.GCCycles)
+		if pausesHurt {
+			reason = fmt.Sprintf("GC pauses reached %.2f ms (P99), %.0f%% of the P50 latency", profile.GCPauseP99, profile.GCPauseP99/latencyP50*100)
+		}
+		recommendations = append(recommendations, RuntimeRecommendation{
+			Setting: "GOGC",
+			Value:   fmt.Sprint(profile.GOGC * 2),
+			Reason:  reason + "; collecting less often trades memory for latency",
+		})
+	}
+
+	// Without a soft limit the runtime ignores the container's hard one
+	if env.CgroupMemoryLimit > 0 && profile.GOMEMLIMIT == 0 {
+		recommendations = append(recommendations, RuntimeRecommendation{
+			Setting: "GOMEMLIMIT",
+			Value:   fmt.Sprintf("%dMiB", env.CgroupMemoryLimit/10*9>>20),
+			Reason:  fmt.Sprintf("the container is limited to %s, so the GC should work harder before the OOM killer does", formatBytes(env.CgroupMemoryLimit)),
+		})
+	}
+
+	// Before Go 1.25, GOMAXPROCS ignored CPU quotas, so threads beyond the
+	// quota get throttled mid-request
+	if quota := int(math.Ceil(env.CgroupCPULimit)); quota > 0 && profile.GOMAXPROCS > quota {
+		recommendations = append(recommendations, RuntimeRecommendation{
+			Setting: "GOMAXPROCS",
+			Value:   fmt.Sprint(quota),
+			Reason:  fmt.Sprintf("%d threads share a %.2f CPU quota, so the kernel throttles them", profile.GOMAXPROCS, env.CgroupCPULimit),
+		})
+	} else if latencyP50 > 0 && profile.SchedLatencyP99 > latencyP50*0.05 && profile.GOMAXPROCS < env.CPUCount {
+		recommendations = append(recommendations, RuntimeRecommendation{
+			Setting: "GOMAXPROCS",
+			Value:   fmt.Sprint(env.CPUCount),
+			Reason:  fmt.Sprintf("goroutines waited %.2f ms (P99) for one of %d threads while %d CPUs are available", profile.SchedLatencyP99, profile.GOMAXPROCS, env.CPUCount),
+		})
+	}
+	return recommendations
+}
+
+// ApplyContainerMemoryLimit sets the Go memory limit to 90% of the cgroup
+// memory limit unless GOMEMLIMIT is set or there is no limit. It returns
+// the limit applied, or zero
+func ApplyContainerMemoryLimit() int64 {
+	if os.Getenv("GOMEMLIMIT") != "" {
+		return 0
+	}
+	limit := hostEnvironment().CgroupMemoryLimit
+	if limit <= 0 {
+		return 0
+	}
+	limit = limit / 10 * 9
+	debug.SetMemoryLimit(limit)
+	return limit
+}
+
+// histogramCounts is the difference of two cumulative runtime histograms
+type histogramCounts struct {
+	counts  []uint64
+	buckets []float64
+}
+
+// histogramDelta returns after minus before, or nil if nothing was recorded
+func histogramDelta(before, after *metrics.Float64Histogram) *histogramCounts {
+	if before == nil || after == nil || len(before.Counts) != len(after.Counts) {
+		return nil
+	}
+	delta := &histogramCounts{counts: make([]uint64, len(after.Counts)), buckets: after.Buckets}
+	var total uint64
+	for i := range after.Counts {
+		delta.counts[i] = after.Counts[i] - before.Counts[i]
+		total += delta.counts[i]
+	}
+	if total == 0 {
+		return nil
+	}
+	return delta
+}
+
+// quantile returns the upper bound of the bucket holding quantile q, or its
+// lower bound for the unbounded last bucket
+func (h *histogramCounts) quantile(q float64) float64 {
+	var total uint64
+	for _, count := range h.counts {
+		total += count
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if count > 0 && seen >= rank {
+			return h.bound(i)
+		}
+	}
+	return 0
+}
+
+// sum estimates the total of all samples from their buckets' bounds
+func (h *histogramCounts) sum() float64 {
+	var sum float64
+	for i, count := range h.counts {
+		sum += float64(count) * h.bound(i)
+	}
+	return sum
+}
+
+// bound returns bucket i's finite upper bound, falling back to its lower one
+func (h *histogramCounts) bound(i int) float64 {
+	if upper := h.buckets[i+1]; !math.IsInf(upper, 1) {
+		return upper
+	}
+	return h.buckets[i]
+}
+
+// uniqueRecommendations merges the recommendations of several results, keeping
+// the last for each setting, in setting order
+func uniqueRecommendations(results []*BenchmarkResult) []RuntimeRecommendation {
+	bySetting := make(map[string]RuntimeRecommendation)
+	for _, result := range results {
+		if result.Runtime == nil {
+			continue
+		}
+		for _, recommendation := range result.Runtime.Recommendations {
+			bySetting[recommendation.Setting] = recommendation
+		}
+	}
+	recommendations := make([]RuntimeRecommendation, 0, len(bySetting))
+	for _, recommendation := range bySetting {
+		recommendations = append(recommendations, recommendation)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Setting < recommendations[j].Setting
+	})
+	return recommendations
+}
+
+// formatRuntimeProfile summarizes a profile in one line
+func formatRuntimeProfile(profile *RuntimeProfile) string {
+	return fmt.Sprintf("%d GC cycles using %.1f%% CPU, pauses %.2f ms total (P99 %.3f ms), scheduling delay P99 %.3f ms",
+		profile.GCCycles, profile.GCCPUFraction*100, profile.GCPauseTotal, profile.GCPauseP99, profile.SchedLatencyP99)
+}
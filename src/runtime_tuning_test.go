@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+)
+
+// TestRecommendRuntimeSettings tests the tuning rules
+func TestRecommendRuntimeSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  RuntimeProfile
+		env      EnvironmentFingerprint
+		p50      float64
+		settings map[string]string
+	}{
+		{"healthy", RuntimeProfile{GOGC: 100, GCCPUFraction: 0.02, GCPauseP99: 0.1, GOMAXPROCS: 8},
+			EnvironmentFingerprint{CPUCount: 8}, 50, map[string]string{}},
+		{"GC heavy", RuntimeProfile{GOGC: 100, GCCPUFraction: 0.25, GOMAXPROCS: 8},
+			EnvironmentFingerprint{CPUCount: 8}, 50, map[string]string{"GOGC": "200"}},
+		{"long pauses", RuntimeProfile{GOGC: 100, GCPauseP99: 1, GOMAXPROCS: 8},
+			EnvironmentFingerprint{CPUCount: 8}, 10, map[string]string{"GOGC": "200"}},
+		{"container", RuntimeProfile{GOGC: 100, GOMAXPROCS: 16},
+			EnvironmentFingerprint{CPUCount: 16, CgroupCPULimit: 1.5, CgroupMemoryLimit: 1 << 30}, 50,
+			map[string]string{"GOMEMLIMIT": "921MiB", "GOMAXPROCS": "2"}},
+		{"memory limit set", RuntimeProfile{GOGC: 100, GOMEMLIMIT: 900 << 20, GOMAXPROCS: 2},
+			EnvironmentFingerprint{CPUCount: 2, CgroupMemoryLimit: 1 << 30}, 50, map[string]string{}},
+		{"scheduler delay", RuntimeProfile{GOGC: 100, SchedLatencyP99: 2, GOMAXPROCS: 2},
+			EnvironmentFingerprint{CPUCount: 8}, 10, map[string]string{"GOMAXPROCS": "8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, recommendation := range recommendRuntimeSettings(&tt.profile, tt.env, tt.p50) {
+				got[recommendation.Setting] = recommendation.Value
+				if recommendation.Reason == "" {
+					t.Errorf("Expected a reason for %s", recommendation.Setting)
+				}
+			}
+			if len(got) != len(tt.settings) {
+				t.Fatalf("Expected %v, got %v", tt.settings, got)
+			}
+			for setting, value := range tt.settings {
+				if got[setting] != value {
+					t.Errorf("Expected %s=%s, got %q", setting, value, got[setting])
+				}
+			}
+		})
+	}
+}
+
+// TestProfileRuntime tests that GC activity between snapshots is measured
+func TestProfileRuntime(t *testing.T) {
+	before := takeRuntimeSnapshot()
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+	profile := profileRuntime(before, takeRuntimeSnapshot(), 10)
+
+	if profile.GCCycles < 3 {
+		t.Errorf("Expected at least 3 GC cycles, got %d", profile.GCCycles)
+	}
+	if profile.GCPauseMax <= 0 || profile.GCPauseP99 > profile.GCPauseMax || profile.GCPauseTotal < profile.GCPauseMax {
+		t.Errorf("Expected consistent pause stats, got %+v", profile)
+	}
+	if profile.GOMAXPROCS != runtime.GOMAXPROCS(0) {
+		t.Errorf("Expected GOMAXPROCS %d, got %d", runtime.GOMAXPROCS(0), profile.GOMAXPROCS)
+	}
+	if !strings.Contains(formatRuntimeProfile(profile), "GC cycles") {
+		t.Errorf("Unexpected summary %q", formatRuntimeProfile(profile))
+	}
+}
+
+// TestHistogramQuantile tests quantiles of a runtime histogram delta
+func TestHistogramQuantile(t *testing.T) {
+	before := &metrics.Float64Histogram{Counts: []uint64{0, 0, 0}, Buckets: []float64{0, 1, 2, math.Inf(1)}}
+	after := &metrics.Float64Histogram{Counts: []uint64{98, 1, 1}, Buckets: before.Buckets}
+	delta := histogramDelta(before, after)
+	if got := delta.quantile(0.5); got != 1 {
+		t.Errorf("Expected P50 in the first bucket, got %v", got)
+	}
+	if got := delta.quantile(0.99); got != 2 {
+		t.Errorf("Expected P99 in the second bucket, got %v", got)
+	}
+	// The unbounded bucket reports its lower bound
+	if got := delta.quantile(1); got != 2 {
+		t.Errorf("Expected the max bounded at 2, got %v", got)
+	}
+	if histogramDelta(after, after) != nil {
+		t.Error("Expected no delta between equal snapshots")
+	}
+}
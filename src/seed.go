@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// A run's seed controls every random choice its requests make: prompts
+// sampled from a workload, the data file shuffle and Math.random in request
+// scripts. Random draws for a request are seeded from the run's seed and
+// the request's ID rather than taken in turn from one generator, so a
+// request gets the same body whichever worker happens to send it, and two
+// runs with the same seed send the same requests; with one worker, in the
+// same order. Each iteration derives its own seed, see IterationSeed
+
+// IterationSeed returns the seed of an iteration, numbered from 1 with
+// warmup iterations negative, of a run seeded with seed. Iterations differ
+// from each other, but an iteration replays exactly given the run's seed;
+// the zero seed, meaning seeded from the clock, stays zero
+func IterationSeed(seed int64, iteration int) int64 {
+	if seed == 0 {
+		return 0
+	}
+	derived := int64(mix64(uint64(seed) ^ mix64(uint64(iteration))))
+	if derived == 0 {
+		derived = 1
+	}
+	return derived
+}
+
+// requestSeed returns the seed of the random draws for one request
+func requestSeed(seed int64, requestID int) int64 {
+	return int64(mix64(uint64(seed) + mix64(uint64(requestID))))
+}
+
+// newSeededRand returns a generator seeded with seed. Unlike
+// rand.NewSource it is cheap to create, so one can be made per request
+func newSeededRand(seed int64) *rand.Rand {
+	source := splitMixSource(seed)
+	return rand.New(&source)
+}
+
+// splitMixSource is a splitmix64 rand.Source64
+type splitMixSource uint64
+
+func (s *splitMixSource) Seed(seed int64) { *s = splitMixSource(seed) }
+func (s *splitMixSource) Int63() int64    { return int64(s.Uint64() >> 1) }
+
+func (s *splitMixSource) Uint64() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	return mix64(uint64(*s))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestIterationSeed tests that iterations get distinct, reproducible seeds
+func TestIterationSeed(t *testing.T) {
+	if seed := IterationSeed(0, 1); seed != 0 {
+		t.Errorf("Expected the zero seed to stay zero, got %d", seed)
+	}
+
+	seen := make(map[int64]int)
+	for _, iteration := range []int{-2, -1, 1, 2, 3} {
+		seed := IterationSeed(42, iteration)
+		if seed == 0 || seed != IterationSeed(42, iteration) {
+			t.Errorf("Expected a stable non-zero seed for iteration %d, got %d", iteration, seed)
+		}
+		if previous, ok := seen[seed]; ok {
+			t.Errorf("Expected iterations %d and %d to differ, both got %d", previous, iteration, seed)
+		}
+		seen[seed] = iteration
+	}
+	if IterationSeed(42, 1) == IterationSeed(43, 1) {
+		t.Error("Expected different run seeds to give different iteration seeds")
+	}
+}
+
+// TestWorkloadSampleSeeded tests that a seeded request's body depends only on
+// the seed and its ID
+func TestWorkloadSampleSeeded(t *testing.T) {
+	config := DefaultWorkloadConfig(writeCorpus(t, 10, 50, 100, 200, 400))
+	config.LengthDistribution = LengthDistributionUniform
+	config.MinMaxTokens, config.MaxMaxTokens = 1, 1000
+	config.Seed = 7
+
+	first, err := NewWorkloadGenerator(config)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	second, err := NewWorkloadGenerator(config)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	// The second generator samples the same IDs in reverse
+	const requests = 50
+	samples := make([]*WorkloadSample, requests)
+	for id := range requests {
+		samples[id] = first.Sample(id)
+	}
+	distinct := make(map[int]bool)
+	for id := requests - 1; id >= 0; id-- {
+		sample := second.Sample(id)
+		if string(sample.Body) != string(samples[id].Body) {
+			t.Fatalf("Expected request %d to get the same body, got %s and %s", id, samples[id].Body, sample.Body)
+		}
+		distinct[sample.MaxTokens] = true
+	}
+	if len(distinct) < requests/2 {
+		t.Errorf("Expected requests to get different draws, got %d distinct max_tokens", len(distinct))
+	}
+}
+
+// TestBenchmarkerSeed tests that runs with the same seed send the same
+// requests, whichever worker sends each
+func TestBenchmarkerSeed(t *testing.T) {
+	var mu sync.Mutex
+	var draws map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		draws[r.Header.Get("X-Id")] = r.Header.Get("X-Draw")
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	run := func(seed int64) map[string]string {
+		draws = make(map[string]string)
+		b := NewBenchmarker(BenchmarkConfig{
+			TargetURL:     server.URL,
+			TotalRequests: 40,
+			Concurrency:   4,
+			Script:        &RequestScriptConfig{Source: `function request(ctx) { return {headers: {"X-Id": "" + ctx.id, "X-Draw": "" + Math.random()}}; }`},
+			Seed:          seed,
+		})
+		result, err := b.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Benchmark failed: %v", err)
+		}
+		if result.Seed != seed || result.SuccessfulReqs != 40 {
+			t.Fatalf("Expected 40 requests seeded with %d, got %d seeded with %d", seed, result.SuccessfulReqs, result.Seed)
+		}
+		return draws
+	}
+
+	first, second, other := run(5), run(5), run(6)
+	for id, draw := range first {
+		if second[id] != draw {
+			t.Errorf("Expected request %s to draw %s again, got %s", id, draw, second[id])
+		}
+		if other[id] == draw {
+			t.Errorf("Expected request %s to draw differently under another seed, got %s", id, draw)
+		}
+	}
+}
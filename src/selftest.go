@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// SelfTestConfig sizes the self-test's standardized workload and the bands
+// the optimizer must land in to pass
+type SelfTestConfig struct {
+	Requests    int           // Sent by each client
+	Concurrency int           // Requests in flight per client
+	Keys        int           // Distinct URLs; repeats are the optimized client's cache hits
+	Latency     time.Duration // The mock server's time per response
+
+	// Most the optimized client may add to a cache miss at P50, and least it
+	// must cut the P50 latency by, as a fraction of the baseline's; negative
+	// allows it to be that much slower
+	MaxOverhead    time.Duration
+	MinImprovement float64
+}
+
+// DefaultSelfTestConfig returns 500 requests over 50 URLs at 10 concurrent
+// against a 20ms mock, allowing 2ms of overhead. The optimized client's
+// Cache is still a stub that stores nothing, so every request is a miss and
+// the default improvement band only requires it to be no more than 5%
+// slower at P50; raise it once responses are cached
+func DefaultSelfTestConfig() SelfTestConfig {
+	return SelfTestConfig{
+		Requests:       500,
+		Concurrency:    10,
+		Keys:           50,
+		Latency:        20 * time.Millisecond,
+		MaxOverhead:    2 * time.Millisecond,
+		MinImprovement: -0.05,
+	}
+}
+
+// SelfTestCheck is one band of the self-test
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Limit  string `json:"limit"`
+	Passed bool   `json:"passed"`
+}
+
+// SelfTestResult compares the baseline client with the optimized client on
+// the same workload
+type SelfTestResult struct {
+	Baseline       LatencyStats    `json:"baseline"`
+	Optimized      LatencyStats    `json:"optimized"`
+	OptimizedMiss  LatencyStats    `json:"optimized_miss"` // Requests the optimized client sent upstream
+	Overhead       float64         `json:"overhead_ms"`    // Optimized miss P50 minus baseline P50
+	Improvement    float64         `json:"improvement"`    // Fraction of the baseline P50 saved
+	CacheHitRatio  float64         `json:"cache_hit_ratio"`
+	BaselineErrors int             `json:"baseline_errors"`
+	Errors         int             `json:"errors"`
+	Checks         []SelfTestCheck `json:"checks"`
+	Passed         bool            `json:"passed"`
+}
+
+// selfTestSample is one request of a self-test client
+type selfTestSample struct {
+	latency  time.Duration
+	cacheHit bool
+	err      error
+}
+
+// RunSelfTest starts an embedded mock server, sends the same workload
+// through a plain HTTP client and through OptimizedClient, and checks the
+// optimizer's overhead and improvement against config's bands
+func RunSelfTest(ctx context.Context, config SelfTestConfig) (*SelfTestResult, error) {
+	if config.Requests <= 0 || config.Concurrency <= 0 || config.Keys <= 0 {
+		return nil, fmt.Errorf("self-test requests, concurrency and keys must be positive")
+	}
+
+	mock := newSelfTestServer(config.Latency)
+	defer mock.Close()
+
+	baselineClient := &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	defer baselineClient.CloseIdleConnections()
+	baseline := runSelfTestClient(ctx, config, mock.URL, func(req *http.Request) (bool, error) {
+		resp, err := baselineClient.Do(req)
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(io.Discard, resp.Body)
+		return false, err
+	})
+
+	clientConfig := DefaultOptimizedClientConfig()
+	clientConfig.MonitoringConfig.Enabled = false
+	clientConfig.CacheConfig.WarmupEnabled = false
+	clientConfig.MaxRetries = 0
+	client, err := NewOptimizedClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create optimized client: %w", err)
+	}
+	defer client.Stop()
+	optimized := runSelfTestClient(ctx, config, mock.URL, func(req *http.Request) (bool, error) {
+		resp, err := client.Do(&OptimizedRequest{Request: req, UseCache: true})
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(io.Discard, resp.Body)
+		return resp.CacheHit, err
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return evaluateSelfTest(config, baseline, optimized), nil
+}
+
+// newSelfTestServer returns the embedded mock API: every response takes
+// latency and may be cached
+func newSelfTestServer(latency time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=300")
+		fmt.Fprintf(w, `{"path":%q,"items":[1,2,3,4,5,6,7,8]}`, r.URL.Path)
+	}))
+}
+
+// runSelfTestClient sends the self-test workload through do, request i
+// going to key i mod Keys, and returns each request's outcome
+func runSelfTestClient(ctx context.Context, config SelfTestConfig, baseURL string, do func(*http.Request) (bool, error)) []selfTestSample {
+	samples := make([]selfTestSample, config.Requests)
+	queue := make(chan int, config.Requests)
+	for i := range config.Requests {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for range config.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					samples[i].err = ctx.Err()
+					continue
+				}
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/items/%d", baseURL, i%config.Keys), nil)
+				if err != nil {
+					samples[i].err = err
+					continue
+				}
+				start := time.Now()
+				samples[i].cacheHit, samples[i].err = do(req)
+				samples[i].latency = time.Since(start)
+			}
+		}()
+	}
+	wg.Wait()
+	return samples
+}
+
+// evaluateSelfTest compares the two clients' samples and checks the bands
+func evaluateSelfTest(config SelfTestConfig, baseline, optimized []selfTestSample) *SelfTestResult {
+	result := &SelfTestResult{}
+
+	var baselineLatencies, optimizedLatencies, missLatencies []float64
+	for _, sample := range baseline {
+		if sample.err != nil {
+			result.BaselineErrors++
+			continue
+		}
+		baselineLatencies = append(baselineLatencies, float64(sample.latency.Microseconds())/1000.0)
+	}
+	hits := 0
+	for _, sample := range optimized {
+		if sample.err != nil {
+			result.Errors++
+			continue
+		}
+		latency := float64(sample.latency.Microseconds()) / 1000.0
+		optimizedLatencies = append(optimizedLatencies, latency)
+		if sample.cacheHit {
+			hits++
+		} else {
+			missLatencies = append(missLatencies, latency)
+		}
+	}
+
+	result.Baseline = CalculateStats(baselineLatencies)
+	result.Optimized = CalculateStats(optimizedLatencies)
+	result.OptimizedMiss = CalculateStats(missLatencies)
+	if len(optimizedLatencies) > 0 {
+		result.CacheHitRatio = float64(hits) / float64(len(optimizedLatencies))
+	}
+	result.Overhead = result.OptimizedMiss.P50 - result.Baseline.P50
+	if result.Baseline.P50 > 0 {
+		result.Improvement = 1 - result.Optimized.P50/result.Baseline.P50
+	}
+
+	maxOverhead := float64(config.MaxOverhead.Microseconds()) / 1000.0
+	result.Checks = []SelfTestCheck{
+		{
+			Name:   "errors",
+			Value:  fmt.Sprintf("%d baseline, %d optimized", result.BaselineErrors, result.Errors),
+			Limit:  "0",
+			Passed: result.BaselineErrors == 0 && result.Errors == 0,
+		},
+		{
+			Name:   "overhead",
+			Value:  fmt.Sprintf("%.2f ms", result.Overhead),
+			Limit:  fmt.Sprintf("<= %v", config.MaxOverhead),
+			Passed: result.OptimizedMiss.Samples > 0 && result.Overhead <= maxOverhead,
+		},
+		{
+			Name:   "improvement",
+			Value:  fmt.Sprintf("%.1f%%", result.Improvement*100),
+			Limit:  fmt.Sprintf(">= %.1f%%", config.MinImprovement*100),
+			Passed: result.Improvement >= config.MinImprovement,
+		},
+	}
+	result.Passed = true
+	for _, check := range result.Checks {
+		result.Passed = result.Passed && check.Passed
+	}
+	return result
+}
+
+// printSelfTest prints the comparison and each band's verdict
+func printSelfTest(config SelfTestConfig, result *SelfTestResult) {
+	fmt.Printf("\n--- Self-Test (%d requests over %d URLs, %d concurrent, %v mock latency) ---\n",
+		config.Requests, config.Keys, config.Concurrency, config.Latency)
+	fmt.Printf("%-16s %10s %10s %10s\n", "CLIENT", "P50", "P95", "P99")
+	fmt.Printf("%-16s %8.2fms %8.2fms %8.2fms\n", "baseline", result.Baseline.P50, result.Baseline.P95, result.Baseline.P99)
+	fmt.Printf("%-16s %8.2fms %8.2fms %8.2fms\n", "optimized", result.Optimized.P50, result.Optimized.P95, result.Optimized.P99)
+	fmt.Printf("%-16s %8.2fms %8.2fms %8.2fms\n", "optimized miss", result.OptimizedMiss.P50, result.OptimizedMiss.P95, result.OptimizedMiss.P99)
+	fmt.Printf("Cache hit ratio: %.1f%%\n\n", result.CacheHitRatio*100)
+
+	for _, check := range result.Checks {
+		verdict := "PASS"
+		if !check.Passed {
+			verdict = "FAIL"
+		}
+		fmt.Printf("%-4s %-12s %s (limit %s)\n", verdict, check.Name, check.Value, check.Limit)
+	}
+	if result.Passed {
+		fmt.Printf("\nSelf-test passed\n")
+	} else {
+		fmt.Printf("\nSelf-test FAILED\n")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRunSelfTest tests running both clients against the mock server. The
+// bands are wide so a loaded test machine cannot fail them
+func TestRunSelfTest(t *testing.T) {
+	config := DefaultSelfTestConfig()
+	config.Requests, config.Keys, config.Concurrency = 60, 6, 6
+	config.Latency = 10 * time.Millisecond
+	config.MaxOverhead, config.MinImprovement = 50*time.Millisecond, -5
+
+	result, err := RunSelfTest(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Self-test failed to run: %v", err)
+	}
+	if result.Baseline.Samples != 60 || result.Optimized.Samples != 60 {
+		t.Errorf("Expected 60 samples per client, got %d and %d", result.Baseline.Samples, result.Optimized.Samples)
+	}
+	if !result.Passed {
+		t.Errorf("Expected the self-test to pass, got %+v", result.Checks)
+	}
+
+	config.Keys = 0
+	if _, err := RunSelfTest(context.Background(), config); err == nil {
+		t.Error("Expected zero keys to be rejected")
+	}
+}
+
+// TestEvaluateSelfTest tests the overhead, improvement and error bands
+func TestEvaluateSelfTest(t *testing.T) {
+	samples := func(latency time.Duration, hits int, failed int) []selfTestSample {
+		var samples []selfTestSample
+		for i := 0; i < 10; i++ {
+			sample := selfTestSample{latency: latency, cacheHit: i < hits}
+			if sample.cacheHit {
+				sample.latency = time.Millisecond
+			}
+			if i >= 10-failed {
+				sample.err = errors.New("connection refused")
+			}
+			samples = append(samples, sample)
+		}
+		return samples
+	}
+	config := SelfTestConfig{MaxOverhead: 2 * time.Millisecond, MinImprovement: 0.5}
+
+	tests := []struct {
+		name      string
+		optimized []selfTestSample
+		failed    []string
+	}{
+		{"cached", samples(21*time.Millisecond, 8, 0), nil},
+		{"slow misses", samples(25*time.Millisecond, 8, 0), []string{"overhead"}},
+		{"no hits", samples(21*time.Millisecond, 0, 0), []string{"improvement"}},
+		{"errors", samples(21*time.Millisecond, 8, 1), []string{"errors"}},
+	}
+
+	for _, tt := range tests {
+		result := evaluateSelfTest(config, samples(20*time.Millisecond, 0, 0), tt.optimized)
+		var failed []string
+		for _, check := range result.Checks {
+			if !check.Passed {
+				failed = append(failed, check.Name)
+			}
+		}
+		if len(failed) != len(tt.failed) || (len(failed) > 0 && failed[0] != tt.failed[0]) || result.Passed != (len(tt.failed) == 0) {
+			t.Errorf("%s: expected %v to fail, got %v", tt.name, tt.failed, failed)
+		}
+	}
+}
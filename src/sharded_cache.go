@@ -0,0 +1,76 @@
+package main
+
+import (
+	"hash/fnv"
+)
+
+// ShardedCache spreads entries over independent LRU caches by key hash, so
+// concurrent requests for different keys rarely wait on the same lock.
+// Capacity and memory are split evenly, and each shard evicts on its own
+type ShardedCache struct {
+	shards []*LRUCache
+}
+
+// NewShardedCache creates a cache of shards LRU caches sharing capacity
+// entries and maxMemoryMB
+func NewShardedCache(shards, capacity int, maxMemoryMB int64) *ShardedCache {
+	if shards <= 0 {
+		shards = 16
+	}
+	if capacity <= 0 {
+		capacity = 1000 // Default capacity, as for NewLRUCache
+	}
+
+	cache := &ShardedCache{shards: make([]*LRUCache, shards)}
+	for i := range cache.shards {
+		shard := NewLRUCache((capacity+shards-1)/shards, 0)
+		shard.maxMemory = maxMemoryMB * 1024 * 1024 / int64(shards)
+		cache.shards[i] = shard
+	}
+	return cache
+}
+
+// shard returns the cache holding key
+func (c *ShardedCache) shard(key string) *LRUCache {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return c.shards[hasher.Sum32()%uint32(len(c.shards))]
+}
+
+// Get retrieves a value from the cache
+func (c *ShardedCache) Get(key string) (*CacheEntry, bool) {
+	return c.shard(key).Get(key)
+}
+
+// Put adds or updates a value in the cache
+func (c *ShardedCache) Put(key string, entry *CacheEntry) error {
+	return c.shard(key).Put(key, entry)
+}
+
+// Delete removes an entry from the cache
+func (c *ShardedCache) Delete(key string) bool {
+	return c.shard(key).Delete(key)
+}
+
+// Size returns the current number of entries in all shards
+func (c *ShardedCache) Size() int {
+	size := 0
+	for _, shard := range c.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+// MemoryUsage returns current memory usage of all shards in bytes
+func (c *ShardedCache) MemoryUsage() int64 {
+	var usage int64
+	for _, shard := range c.shards {
+		usage += shard.MemoryUsage()
+	}
+	return usage
+}
+
+// Shards returns the number of shards
+func (c *ShardedCache) Shards() int {
+	return len(c.shards)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestShardedCacheBasic tests that entries round-trip through their shards
+// and sizes add up across shards
+func TestShardedCacheBasic(t *testing.T) {
+	cache := NewShardedCache(4, 100, 10)
+	if cache.Shards() != 4 {
+		t.Errorf("Expected 4 shards, got %d", cache.Shards())
+	}
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		cache.Put(key, benchEntry(key, make([]byte, 100)))
+	}
+	if cache.Size() != 20 {
+		t.Errorf("Expected 20 entries, got %d", cache.Size())
+	}
+	if cache.MemoryUsage() != 2000 {
+		t.Errorf("Expected 2000 bytes, got %d", cache.MemoryUsage())
+	}
+
+	entry, found := cache.Get("key-7")
+	if !found || entry.Key != "key-7" {
+		t.Errorf("Expected to find key-7, got %v, %v", entry, found)
+	}
+	if !cache.Delete("key-7") {
+		t.Error("Expected key-7 to be deleted")
+	}
+	if _, found := cache.Get("key-7"); found {
+		t.Error("Expected key-7 to be gone after Delete")
+	}
+}
+
+// TestShardedCacheEviction tests that a full shard evicts its own entries
+func TestShardedCacheEviction(t *testing.T) {
+	cache := NewShardedCache(2, 10, 10)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		cache.Put(key, benchEntry(key, []byte("value")))
+	}
+	if cache.Size() > 10 {
+		t.Errorf("Expected at most 10 entries, got %d", cache.Size())
+	}
+}
+
+// TestShardedCacheConcurrent tests concurrent use across shards
+func TestShardedCacheConcurrent(t *testing.T) {
+	cache := NewShardedCache(8, 1000, 10)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key-%d-%d", g, i)
+				cache.Put(key, benchEntry(key, []byte("value")))
+				cache.Get(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Size() != 800 {
+		t.Errorf("Expected 800 entries, got %d", cache.Size())
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// RetentionPolicy bounds how many snapshots a MetricsCollector keeps and for
+// how long. Snapshots older than DownsampleAfter are merged into one per
+// DownsampleInterval so long-running monitors keep coarse history cheaply
+type RetentionPolicy struct {
+	MaxSnapshots       int           // Most snapshots kept; the oldest go first
+	MaxAge             time.Duration // Snapshots older than this are dropped (0 = keep)
+	DownsampleAfter    time.Duration // Age after which snapshots are downsampled (0 = never)
+	DownsampleInterval time.Duration // Width of each downsampled bucket
+}
+
+// DefaultRetentionPolicy keeps a day of history, downsampled to 5 minute
+// resolution after the first hour
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		MaxSnapshots:       1000,
+		MaxAge:             24 * time.Hour,
+		DownsampleAfter:    time.Hour,
+		DownsampleInterval: 5 * time.Minute,
+	}
+}
+
+// RetentionResult reports what a retention pass removed
+type RetentionResult struct {
+	Expired     int `json:"expired"`     // Older than MaxAge
+	Downsampled int `json:"downsampled"` // Merged into coarser snapshots
+	Trimmed     int `json:"trimmed"`     // Over MaxSnapshots
+	Remaining   int `json:"remaining"`
+}
+
+// SetRetentionPolicy replaces the retention policy and applies it immediately
+func (mc *MetricsCollector) SetRetentionPolicy(policy RetentionPolicy) RetentionResult {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if policy.MaxSnapshots <= 0 {
+		policy.MaxSnapshots = mc.retention.MaxSnapshots
+	}
+	mc.retention = policy
+	mc.maxSnapshots = policy.MaxSnapshots
+	return mc.applyRetention(time.Now())
+}
+
+// RetentionPolicy returns the current retention policy
+func (mc *MetricsCollector) RetentionPolicy() RetentionPolicy {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.retention
+}
+
+// ApplyRetention expires, downsamples and trims stored snapshots
+func (mc *MetricsCollector) ApplyRetention() RetentionResult {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.applyRetention(time.Now())
+}
+
+// PurgeSnapshots removes every snapshot taken before cutoff, or all of them
+// when cutoff is zero, and returns how many were removed
+func (mc *MetricsCollector) PurgeSnapshots(cutoff time.Time) int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if cutoff.IsZero() {
+		removed := len(mc.snapshots)
+		mc.snapshots = make([]MonitoringSnapshot, 0, mc.maxSnapshots)
+		return removed
+	}
+
+	kept := mc.snapshots[:0]
+	for _, snapshot := range mc.snapshots {
+		if snapshot.Timestamp.After(cutoff) {
+			kept = append(kept, snapshot)
+		}
+	}
+	removed := len(mc.snapshots) - len(kept)
+	mc.snapshots = kept
+	return removed
+}
+
+// applyRetention enforces the retention policy as of now. Callers hold mc.mu
+func (mc *MetricsCollector) applyRetention(now time.Time) RetentionResult {
+	var result RetentionResult
+	policy := mc.retention
+
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		kept := mc.snapshots[:0]
+		for _, snapshot := range mc.snapshots {
+			if snapshot.Timestamp.After(cutoff) {
+				kept = append(kept, snapshot)
+			}
+		}
+		result.Expired = len(mc.snapshots) - len(kept)
+		mc.snapshots = kept
+	}
+
+	if policy.DownsampleAfter > 0 && policy.DownsampleInterval > 0 {
+		before := len(mc.snapshots)
+		mc.snapshots = downsampleSnapshots(mc.snapshots, now.Add(-policy.DownsampleAfter), policy.DownsampleInterval)
+		result.Downsampled = before - len(mc.snapshots)
+	}
+
+	if policy.MaxSnapshots > 0 && len(mc.snapshots) > policy.MaxSnapshots {
+		result.Trimmed = len(mc.snapshots) - policy.MaxSnapshots
+		mc.snapshots = append(mc.snapshots[:0], mc.snapshots[result.Trimmed:]...)
+	}
+
+	result.Remaining = len(mc.snapshots)
+	return result
+}
+
+// downsampleSnapshots merges snapshots taken before cutoff into one per
+// interval-aligned bucket, leaving newer snapshots untouched. Snapshots must
+// be in time order
+func downsampleSnapshots(snapshots []MonitoringSnapshot, cutoff time.Time, interval time.Duration) []MonitoringSnapshot {
+	result := make([]MonitoringSnapshot, 0, len(snapshots))
+	var bucket []MonitoringSnapshot
+	var bucketStart time.Time
+
+	flush := func() {
+		if len(bucket) > 0 {
+			result = append(result, mergeSnapshots(bucket))
+			bucket = bucket[:0]
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		if !snapshot.Timestamp.Before(cutoff) {
+			flush()
+			result = append(result, snapshot)
+			continue
+		}
+
+		start := snapshot.Timestamp.Truncate(interval)
+		if len(bucket) > 0 && !start.Equal(bucketStart) {
+			flush()
+		}
+		bucketStart = start
+		bucket = append(bucket, snapshot)
+	}
+	flush()
+
+	return result
+}
+
+// mergeSnapshots combines a bucket of snapshots into one. Rates, ratios and
+// percentiles are averaged, weighted by how many raw snapshots each already
+// represents; counters and gauges such as cache size come from the newest
+func mergeSnapshots(bucket []MonitoringSnapshot) MonitoringSnapshot {
+	if len(bucket) == 1 {
+		return bucket[0]
+	}
+
+	merged := bucket[len(bucket)-1]
+	var weight float64
+	averaged := map[*float64]float64{}
+	fields := func(s *MonitoringSnapshot) []*float64 {
+		return []*float64{
+			&s.CacheHitRatio, &s.CacheMissRatio,
+			&s.LatencyP50, &s.LatencyP95, &s.LatencyP99, &s.LatencyMean,
+			&s.TTFBP50, &s.TTFBP95, &s.TTFBP99,
+			&s.DNSP95, &s.ConnectP95, &s.TLSP95,
+			&s.ServerP50, &s.ServerP95, &s.DownloadP50, &s.DownloadP95,
+			&s.UpstreamP50, &s.UpstreamP95, &s.NetworkP50, &s.NetworkP95, &s.UpstreamShare,
+			&s.RequestsPerSecond, &s.BytesPerSecond,
+			&s.ErrorRate, &s.ConnectionReuseRate,
+		}
+	}
+
+	target := fields(&merged)
+	merged.LatencyMin = math.Inf(1)
+	merged.LatencyMax = 0
+	scoreSum := 0.0
+	for i := range bucket {
+		w := float64(max(bucket[i].Downsampled, 1))
+		weight += w
+		for j, field := range fields(&bucket[i]) {
+			averaged[target[j]] += *field * w
+		}
+		merged.LatencyMin = math.Min(merged.LatencyMin, bucket[i].LatencyMin)
+		merged.LatencyMax = math.Max(merged.LatencyMax, bucket[i].LatencyMax)
+		scoreSum += float64(bucket[i].PerformanceScore) * w
+	}
+	for field, sum := range averaged {
+		*field = sum / weight
+	}
+
+	merged.PerformanceScore = int(math.Round(scoreSum / weight))
+	merged.Downsampled = int(weight)
+	return merged
+}
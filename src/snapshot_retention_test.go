@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSnapshotRetention tests expiry, downsampling and the count cap
+func TestSnapshotRetention(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Three hours of one snapshot a minute, the oldest hour already expired.
+	// Offsetting by 30s keeps every snapshot clear of bucket and cutoff edges
+	for i := 180; i > 0; i-- {
+		collector.snapshots = append(collector.snapshots, MonitoringSnapshot{
+			Timestamp:  now.Add(-time.Duration(i)*time.Minute + 30*time.Second),
+			LatencyP95: float64(i % 2 * 100),
+			LatencyMin: float64(i),
+			LatencyMax: float64(i),
+		})
+	}
+
+	collector.retention = RetentionPolicy{
+		MaxSnapshots:       1000,
+		MaxAge:             2 * time.Hour,
+		DownsampleAfter:    time.Hour,
+		DownsampleInterval: 10 * time.Minute,
+	}
+	result := collector.applyRetention(now)
+	if result.Expired != 60 {
+		t.Errorf("Expected 60 expired snapshots, got %d", result.Expired)
+	}
+
+	// The second hour collapses to six buckets; the last hour stays raw
+	if result.Remaining != 6+60 {
+		t.Fatalf("Expected 66 snapshots after downsampling, got %+v", result)
+	}
+	snapshots := collector.GetSnapshots()
+	first := snapshots[0]
+	if first.Downsampled != 10 || first.LatencyP95 != 50 {
+		t.Errorf("Expected 10 snapshots averaged to P95 50, got %d at %.1f", first.Downsampled, first.LatencyP95)
+	}
+	if first.LatencyMin != 111 || first.LatencyMax != 120 {
+		t.Errorf("Expected min/max 111/120 across the bucket, got %.0f/%.0f", first.LatencyMin, first.LatencyMax)
+	}
+	if raw := snapshots[len(snapshots)-1]; raw.Downsampled != 0 {
+		t.Errorf("Recent snapshots should stay raw, got %+v", raw)
+	}
+
+	// A second pass leaves downsampled buckets alone
+	if again := collector.applyRetention(now); again.Downsampled != 0 || again.Remaining != 66 {
+		t.Errorf("Retention should be idempotent, got %+v", again)
+	}
+
+	collector.SetRetentionPolicy(RetentionPolicy{MaxSnapshots: 20})
+	if got := len(collector.GetSnapshots()); got != 20 {
+		t.Errorf("Expected the count cap to keep 20 snapshots, got %d", got)
+	}
+}
+
+// TestPurgeSnapshotsEndpoint tests purging history through the dashboard API
+func TestPurgeSnapshotsEndpoint(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	now := time.Now()
+	for i := 10; i > 0; i-- {
+		collector.snapshots = append(collector.snapshots, MonitoringSnapshot{Timestamp: now.Add(-time.Duration(i) * time.Hour)})
+	}
+	dashboard := &Dashboard{collector: collector}
+
+	recorder := httptest.NewRecorder()
+	dashboard.handleAPIPurgeSnapshots(recorder, httptest.NewRequest(http.MethodGet, "/api/snapshots/purge", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be rejected, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	dashboard.handleAPIPurgeSnapshots(recorder, httptest.NewRequest(http.MethodPost, "/api/snapshots/purge?older_than=5h30m", nil))
+	var body map[string]int
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if body["removed"] != 5 || body["remaining"] != 5 {
+		t.Errorf("Expected 5 removed and 5 remaining, got %v", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	dashboard.handleAPIPurgeSnapshots(recorder, httptest.NewRequest(http.MethodDelete, "/api/snapshots/purge", nil))
+	if len(collector.GetSnapshots()) != 0 {
+		t.Error("Expected a purge without a cutoff to remove everything")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHistoryPoints is how many snapshots a history query returns at
+// most; longer ranges are downsampled to fit
+const DefaultHistoryPoints = 500
+
+// snapshotDayLayout names a store's day files, snapshots-<day>.jsonl
+const snapshotDayLayout = "2006-01-02"
+
+// SnapshotStore persists monitoring snapshots on disk, one JSON line each
+// in a file per UTC day, so history outlives the in-memory snapshots and the
+// process. Day files older than the store's max age are deleted by Prune
+type SnapshotStore struct {
+	dir    string
+	maxAge time.Duration // Zero keeps every day
+
+	mu   sync.Mutex
+	file *os.File
+	day  string
+}
+
+// OpenSnapshotStore opens or creates a store in dir
+func OpenSnapshotStore(dir string, maxAge time.Duration) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot store: %w", err)
+	}
+	return &SnapshotStore{dir: dir, maxAge: maxAge}, nil
+}
+
+// dayFile returns the path of day's file
+func (s *SnapshotStore) dayFile(day string) string {
+	return filepath.Join(s.dir, "snapshots-"+day+".jsonl")
+}
+
+// Append writes snapshot to the file of its day
+func (s *SnapshotStore) Append(snapshot MonitoringSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := snapshot.Timestamp.UTC().Format(snapshotDayLayout)
+	if s.file == nil || s.day != day {
+		if s.file != nil {
+			s.file.Close()
+		}
+		s.file, err = os.OpenFile(s.dayFile(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			s.file = nil
+			return fmt.Errorf("failed to open snapshot file: %w", err)
+		}
+		s.day = day
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Query returns the stored snapshots taken from start to end in time order,
+// downsampled to at most points of them if points is positive
+func (s *SnapshotStore) Query(start, end time.Time, points int) ([]MonitoringSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapshots []MonitoringSnapshot
+	for day := start.UTC().Truncate(24 * time.Hour); !day.After(end); day = day.Add(24 * time.Hour) {
+		found, err := readSnapshotFile(s.dayFile(day.Format(snapshotDayLayout)), start, end)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, found...)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	return downsampleHistory(snapshots, start, end, points), nil
+}
+
+// readSnapshotFile reads the snapshots from start to end in a day file,
+// skipping a torn last line; a missing file holds none
+func readSnapshotFile(path string, start, end time.Time) ([]MonitoringSnapshot, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	var snapshots []MonitoringSnapshot
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var snapshot MonitoringSnapshot
+		if json.Unmarshal(scanner.Bytes(), &snapshot) != nil {
+			continue
+		}
+		if snapshot.Timestamp.Before(start) || snapshot.Timestamp.After(end) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, scanner.Err()
+}
+
+// Prune deletes the day files entirely older than the store's max age as of
+// now, returning how many went
+func (s *SnapshotStore) Prune(now time.Time) (int, error) {
+	if s.maxAge <= 0 {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "snapshots-*.jsonl"))
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.Add(-s.maxAge)
+	removed := 0
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "snapshots-"), ".jsonl")
+		day, err := time.Parse(snapshotDayLayout, name)
+		if err != nil || !day.Add(24*time.Hour).Before(cutoff) {
+			continue
+		}
+		if name == s.day && s.file != nil {
+			s.file.Close()
+			s.file = nil
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Close closes the file being appended to
+func (s *SnapshotStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// downsampleHistory merges snapshots into even buckets over start to end so
+// at most points remain, or returns them as they are if they already fit
+func downsampleHistory(snapshots []MonitoringSnapshot, start, end time.Time, points int) []MonitoringSnapshot {
+	if points <= 0 || len(snapshots) <= points {
+		return snapshots
+	}
+	// Buckets are aligned to interval, so the range may touch one more
+	// bucket than it spans
+	buckets := time.Duration(max(points-1, 1))
+	interval := (end.Sub(start) + buckets - 1) / buckets
+	if interval <= 0 {
+		return snapshots
+	}
+	return downsampleSnapshots(snapshots, end.Add(time.Nanosecond), interval)
+}
+
+// AttachStore persists every captured snapshot to store and serves History
+// from it
+func (mc *MetricsCollector) AttachStore(store *SnapshotStore) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.store = store
+}
+
+// History returns the snapshots taken from start to end, downsampled to at
+// most points of them: from the attached store, or from memory without one
+func (mc *MetricsCollector) History(start, end time.Time, points int) ([]MonitoringSnapshot, error) {
+	mc.mu.RLock()
+	store := mc.store
+	mc.mu.RUnlock()
+
+	if store != nil {
+		return store.Query(start, end, points)
+	}
+	var snapshots []MonitoringSnapshot
+	for _, snapshot := range mc.GetSnapshots() {
+		if !snapshot.Timestamp.Before(start) && !snapshot.Timestamp.After(end) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return downsampleHistory(snapshots, start, end, points), nil
+}
+
+// historyColumns are the snapshot fields exported as CSV
+var historyColumns = []struct {
+	name  string
+	value func(*MonitoringSnapshot) float64
+}{
+	{"latency_p50_ms", func(s *MonitoringSnapshot) float64 { return s.LatencyP50 }},
+	{"latency_p95_ms", func(s *MonitoringSnapshot) float64 { return s.LatencyP95 }},
+	{"latency_p99_ms", func(s *MonitoringSnapshot) float64 { return s.LatencyP99 }},
+	{"latency_mean_ms", func(s *MonitoringSnapshot) float64 { return s.LatencyMean }},
+	{"latency_max_ms", func(s *MonitoringSnapshot) float64 { return s.LatencyMax }},
+	{"ttfb_p95_ms", func(s *MonitoringSnapshot) float64 { return s.TTFBP95 }},
+	{"requests_per_second", func(s *MonitoringSnapshot) float64 { return s.RequestsPerSecond }},
+	{"error_rate", func(s *MonitoringSnapshot) float64 { return s.ErrorRate }},
+	{"cache_hit_ratio", func(s *MonitoringSnapshot) float64 { return s.CacheHitRatio }},
+	{"cache_memory_usage_mb", func(s *MonitoringSnapshot) float64 { return s.CacheMemoryUsageMB }},
+	{"connection_reuse_rate", func(s *MonitoringSnapshot) float64 { return s.ConnectionReuseRate }},
+}
+
+// writeHistoryCSV writes snapshots as CSV, one row per snapshot
+func writeHistoryCSV(w io.Writer, snapshots []MonitoringSnapshot) error {
+	writer := csv.NewWriter(w)
+	header := []string{"timestamp"}
+	for _, column := range historyColumns {
+		header = append(header, column.name)
+	}
+	writer.Write(append(header, "downsampled"))
+
+	for i := range snapshots {
+		row := []string{snapshots[i].Timestamp.UTC().Format(time.RFC3339)}
+		for _, column := range historyColumns {
+			row = append(row, strconv.FormatFloat(column.value(&snapshots[i]), 'f', -1, 64))
+		}
+		writer.Write(append(row, strconv.Itoa(snapshots[i].Downsampled)))
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSnapshotStore tests persisting snapshots in day files, querying and
+// downsampling ranges across days, and pruning old days
+func TestSnapshotStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenSnapshotStore(dir, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	// Three days of one snapshot an hour
+	start := time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC)
+	for i := 0; i < 72; i++ {
+		if err := store.Append(MonitoringSnapshot{Timestamp: start.Add(time.Duration(i) * time.Hour), LatencyP95: float64(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	store.Close()
+	if days, _ := filepath.Glob(filepath.Join(dir, "snapshots-*.jsonl")); len(days) != 3 {
+		t.Fatalf("Expected 3 day files, got %v", days)
+	}
+
+	// A torn line from a crash mid-write is skipped
+	file, _ := os.OpenFile(filepath.Join(dir, "snapshots-2026-03-02.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"timestamp":"2026-03-02T23:59`)
+	file.Close()
+
+	// A reopened store reads what the first wrote
+	store, _ = OpenSnapshotStore(dir, 48*time.Hour)
+	snapshots, err := store.Query(start.Add(20*time.Hour), start.Add(30*time.Hour), 0)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(snapshots) != 11 || snapshots[0].LatencyP95 != 20 || snapshots[10].LatencyP95 != 30 {
+		t.Fatalf("Expected the 11 snapshots from hour 20 to 30, got %d", len(snapshots))
+	}
+
+	snapshots, _ = store.Query(start, start.Add(71*time.Hour), 10)
+	if len(snapshots) > 10 || len(snapshots) < 5 {
+		t.Errorf("Expected 72 snapshots downsampled to at most 10, got %d", len(snapshots))
+	}
+	merged := 0
+	for _, s := range snapshots {
+		merged += max(s.Downsampled, 1)
+	}
+	if merged != 72 {
+		t.Errorf("Expected the downsampled snapshots to cover all 72, got %d", merged)
+	}
+
+	removed, err := store.Prune(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+	if err != nil || removed != 1 {
+		t.Errorf("Expected the first day pruned, got %d (%v)", removed, err)
+	}
+	if snapshots, _ := store.Query(start, start.Add(71*time.Hour), 0); len(snapshots) != 48 {
+		t.Errorf("Expected 48 snapshots left, got %d", len(snapshots))
+	}
+}
+
+// TestHistoryEndpoint tests the dashboard's history API, from memory
+// without a store, as JSON and CSV
+func TestHistoryEndpoint(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		collector.snapshots = append(collector.snapshots, MonitoringSnapshot{
+			Timestamp:  end.Add(-time.Duration(60-i) * time.Minute),
+			LatencyP50: 10,
+			LatencyP95: 20,
+		})
+	}
+	dashboard := &Dashboard{collector: collector}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		dashboard.handleAPIHistory(recorder, httptest.NewRequest(http.MethodGet, "/api/history?"+query, nil))
+		return recorder
+	}
+
+	recorder := get("end=2026-03-01T12:00:00Z&duration=30m")
+	var snapshots []MonitoringSnapshot
+	json.NewDecoder(recorder.Body).Decode(&snapshots)
+	if recorder.Code != http.StatusOK || len(snapshots) != 30 {
+		t.Errorf("Expected the last 30 minutes' snapshots, got %d (status %d)", len(snapshots), recorder.Code)
+	}
+
+	recorder = get("start=2026-03-01T11:00:00Z&end=2026-03-01T12:00:00Z&points=6&format=csv")
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if recorder.Header().Get("Content-Type") != "text/csv" || len(lines) < 2 || len(lines) > 7 {
+		t.Fatalf("Expected a header and at most 6 CSV rows, got:\n%s", recorder.Body.String())
+	}
+	if !strings.HasPrefix(lines[0], "timestamp,latency_p50_ms,latency_p95_ms,") || !strings.HasPrefix(lines[1], "2026-03-01T11:") {
+		t.Errorf("Unexpected CSV:\n%s", recorder.Body.String())
+	}
+
+	for _, query := range []string{"duration=soon", "points=0", "start=yesterday", "start=2026-03-01T12:00:00Z&end=2026-03-01T11:00:00Z"} {
+		if recorder := get(query); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status 400, got %d", query, recorder.Code)
+		}
+	}
+}
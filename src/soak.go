@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SoakConfig runs constant moderate load for hours to expose slow leaks and
+// latency drift that short benchmarks never see
+type SoakConfig struct {
+	Benchmark BenchmarkConfig `yaml:"benchmark"` // Target and transport; TotalRequests is derived from RPS
+	Duration  time.Duration   `yaml:"duration"`
+	RPS       float64         `yaml:"rps"`
+
+	// Length of each measurement window; resources are sampled between
+	// windows, after a garbage collection, while no request is in flight
+	Window time.Duration `yaml:"window"`
+
+	// Optional local target process whose memory, threads and file
+	// descriptors are sampled too; Linux only
+	TargetPID int `yaml:"target_pid"`
+
+	Thresholds SoakThresholds `yaml:"thresholds"`
+}
+
+// SoakThresholds are the growth rates past which a soak is unstable
+type SoakThresholds struct {
+	MemoryPerHour     int64   `yaml:"memory_per_hour"`     // Bytes of live heap, or target RSS
+	GoroutinesPerHour float64 `yaml:"goroutines_per_hour"` // Also target threads
+	FDsPerHour        float64 `yaml:"fds_per_hour"`
+	LatencyDrift      float64 `yaml:"latency_drift"` // Fractional P95 change from the first to the last quarter
+}
+
+// DefaultSoakConfig samples every minute and flags 10MB of heap, 10
+// goroutines or 5 file descriptors gained per hour, or P95 drifting 20%
+func DefaultSoakConfig() SoakConfig {
+	return SoakConfig{
+		Duration: time.Hour,
+		RPS:      10,
+		Window:   time.Minute,
+		Thresholds: SoakThresholds{
+			MemoryPerHour:     10 * 1024 * 1024,
+			GoroutinesPerHour: 10,
+			FDsPerHour:        5,
+			LatencyDrift:      0.2,
+		},
+	}
+}
+
+// SoakSample is one window of a soak: the target's latency over the window
+// and resource use at its end. Unavailable counts are -1
+type SoakSample struct {
+	At         time.Duration `json:"at"` // Since the soak started
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	LatencyP50 float64       `json:"latency_p50_ms"`
+	LatencyP95 float64       `json:"latency_p95_ms"`
+	LatencyP99 float64       `json:"latency_p99_ms"`
+
+	HeapBytes  int64 `json:"heap_bytes"` // Live heap after GC
+	Goroutines int   `json:"goroutines"`
+	FDs        int   `json:"fds"`
+
+	TargetRSS     int64 `json:"target_rss_bytes,omitempty"`
+	TargetThreads int   `json:"target_threads,omitempty"`
+	TargetFDs     int   `json:"target_fds,omitempty"`
+}
+
+// SoakCheck is one stability criterion and how the soak fared
+type SoakCheck struct {
+	Name      string  `json:"name"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Unit      string  `json:"unit"`
+	Passed    bool    `json:"passed"`
+}
+
+// SoakResult is the outcome of a soak: every sample and the stability verdict
+type SoakResult struct {
+	Target    string        `json:"target"`
+	RPS       float64       `json:"rps"`
+	StartTime time.Time     `json:"start_time"`
+	Duration  time.Duration `json:"duration"`
+	Partial   bool          `json:"partial,omitempty"` // Stopped before Duration
+
+	Samples []SoakSample `json:"samples"`
+	Checks  []SoakCheck  `json:"checks"`
+	Stable  bool         `json:"stable"`
+}
+
+// RunSoak sends config.RPS requests per second for config.Duration, one
+// whole window at a time over a single connection pool, and judges stability
+// from how resources and latency trend across windows. Cancelling ctx ends the
+// soak early with the windows completed so far
+func RunSoak(ctx context.Context, config SoakConfig, progress func(SoakSample)) (*SoakResult, error) {
+	defaults := DefaultSoakConfig()
+	if config.RPS <= 0 {
+		config.RPS = defaults.RPS
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.Thresholds == (SoakThresholds{}) {
+		config.Thresholds = defaults.Thresholds
+	}
+	if config.Duration < config.Window {
+		return nil, fmt.Errorf("soak duration %v is shorter than one %v window", config.Duration, config.Window)
+	}
+
+	benchmark := config.Benchmark
+	benchmark.RateLimit = DefaultRateLimiterConfig()
+	benchmark.RateLimit.Global = TokenBucketConfig{Rate: config.RPS, Burst: 1}
+	benchmark.TargetRPS = 0
+	benchmark.IncludeRawMetrics = false
+	benchmark.Concurrency = max(benchmark.Concurrency, 1)
+	b := NewBenchmarker(benchmark)
+
+	result := &SoakResult{Target: benchmark.TargetURL, RPS: config.RPS, StartTime: time.Now()}
+	requests := max(int(math.Round(config.RPS*config.Window.Seconds())), 1)
+	overBudget := false
+	for window := 0; window < int(config.Duration/config.Window) && ctx.Err() == nil; window++ {
+
+		// Each window starts empty so the soak's own bookkeeping stays flat
+		b.metricsMux.Lock()
+		b.config.TotalRequests = requests
+		b.metrics = make([]LatencyMetrics, 0, requests)
+		b.metricsMux.Unlock()
+
+		windowResult, err := b.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if windowResult.Partial && windowResult.SuccessfulReqs+windowResult.FailedReqs == 0 {
+			break
+		}
+
+		sample := sampleSoak(time.Since(result.StartTime), config.TargetPID)
+		sample.Requests = windowResult.SuccessfulReqs + windowResult.FailedReqs
+		sample.Errors = windowResult.FailedReqs
+		sample.LatencyP50 = windowResult.LatencyStats.P50
+		sample.LatencyP95 = windowResult.LatencyStats.P95
+		sample.LatencyP99 = windowResult.LatencyStats.P99
+		result.Samples = append(result.Samples, sample)
+		if progress != nil {
+			progress(sample)
+		}
+		if spend := windowResult.Spend; spend != nil && spend.Exceeded && spend.Action == SpendAbort {
+			overBudget = true
+			break
+		}
+	}
+
+	result.Duration = time.Since(result.StartTime)
+	result.Partial = ctx.Err() != nil || overBudget
+	result.Checks = evaluateSoak(result.Samples, config.Thresholds, config.TargetPID > 0)
+	result.Stable = true
+	for _, check := range result.Checks {
+		result.Stable = result.Stable && check.Passed
+	}
+	return result, nil
+}
+
+// sampleSoak reads this process's and the target's resource use
+func sampleSoak(at time.Duration, targetPID int) SoakSample {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	sample := SoakSample{
+		At:         at,
+		HeapBytes:  int64(stats.HeapAlloc),
+		Goroutines: runtime.NumGoroutine(),
+		FDs:        countFDs("self"),
+	}
+	if targetPID > 0 {
+		pid := strconv.Itoa(targetPID)
+		sample.TargetFDs = countFDs(pid)
+		sample.TargetRSS, sample.TargetThreads = procStatus(pid)
+	}
+	return sample
+}
+
+// countFDs returns the open file descriptors of a process, or -1 where
+// /proc is unavailable; this process also falls back to /dev/fd
+func countFDs(pid string) int {
+	dirs := []string{filepath.Join("/proc", pid, "fd")}
+	if pid == "self" {
+		dirs = append(dirs, "/dev/fd")
+	}
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+// procStatus returns a process's resident memory and thread count from
+// /proc, or -1 for each where unavailable
+func procStatus(pid string) (rss int64, threads int) {
+	rss, threads = -1, -1
+	file, err := os.Open(filepath.Join("/proc", pid, "status"))
+	if err != nil {
+		return rss, threads
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		switch key {
+		case "VmRSS":
+			if kb, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				rss = kb * 1024
+			}
+		case "Threads":
+			if n, err := strconv.Atoi(fields[0]); err == nil {
+				threads = n
+			}
+		}
+	}
+	return rss, threads
+}
+
+// evaluateSoak fits a trend through each resource and compares the latency
+// of the first and last quarters. The first window warms pools and caches,
+// so resource trends start from the second
+func evaluateSoak(samples []SoakSample, thresholds SoakThresholds, target bool) []SoakCheck {
+	settled := samples
+	if len(settled) > 2 {
+		settled = settled[1:]
+	}
+	start := time.Now()
+	trend := func(value func(SoakSample) float64) float64 {
+		points := make([]trendPoint, 0, len(settled))
+		for _, s := range settled {
+			if v := value(s); v >= 0 {
+				points = append(points, trendPoint{at: start.Add(s.At), value: v})
+			}
+		}
+		return slopePerHour(points)
+	}
+	growth := func(name, unit string, threshold float64, value func(SoakSample) float64) SoakCheck {
+		slope := trend(value)
+		return SoakCheck{Name: name, Value: slope, Threshold: threshold, Unit: unit, Passed: slope <= threshold}
+	}
+
+	const mb = 1024 * 1024
+	memoryThreshold := float64(thresholds.MemoryPerHour) / mb
+	checks := []SoakCheck{
+		growth("heap", "MB/hour", memoryThreshold, func(s SoakSample) float64 { return float64(s.HeapBytes) / mb }),
+		growth("goroutines", "/hour", thresholds.GoroutinesPerHour, func(s SoakSample) float64 { return float64(s.Goroutines) }),
+	}
+	if len(samples) > 0 && samples[0].FDs >= 0 {
+		checks = append(checks, growth("fds", "/hour", thresholds.FDsPerHour, func(s SoakSample) float64 { return float64(s.FDs) }))
+	}
+	if target && len(samples) > 0 {
+		if samples[0].TargetRSS >= 0 {
+			checks = append(checks, growth("target_rss", "MB/hour", memoryThreshold, func(s SoakSample) float64 { return float64(s.TargetRSS) / mb }))
+		}
+		if samples[0].TargetThreads >= 0 {
+			checks = append(checks, growth("target_threads", "/hour", thresholds.GoroutinesPerHour, func(s SoakSample) float64 { return float64(s.TargetThreads) }))
+		}
+		if samples[0].TargetFDs >= 0 {
+			checks = append(checks, growth("target_fds", "/hour", thresholds.FDsPerHour, func(s SoakSample) float64 { return float64(s.TargetFDs) }))
+		}
+	}
+
+	// Drift compares quarters rather than a fitted line so one slow window
+	// at either end does not dominate
+	drift := 0.0
+	if quarter := max(len(samples)/4, 1); len(samples) >= 2 {
+		first, last := meanP95(samples[:quarter]), meanP95(samples[len(samples)-quarter:])
+		if first > 0 {
+			drift = (last - first) / first
+		}
+	}
+	checks = append(checks, SoakCheck{
+		Name:      "latency_drift",
+		Value:     drift * 100,
+		Threshold: thresholds.LatencyDrift * 100,
+		Unit:      "% P95",
+		Passed:    drift <= thresholds.LatencyDrift,
+	})
+	return checks
+}
+
+// meanP95 averages the P95 latency of samples
+func meanP95(samples []SoakSample) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s.LatencyP95
+	}
+	return sum / float64(len(samples))
+}
+
+// printSoakResult writes the verdict with a sparkline of every series
+func printSoakResult(result *SoakResult) {
+	fmt.Printf("\n--- Soak Test (%v at %.1f req/s) ---\n", result.Duration.Round(time.Second), result.RPS)
+	if result.Partial {
+		fmt.Printf("Stopped early after %d windows\n", len(result.Samples))
+	}
+
+	series := []struct {
+		name  string
+		value func(SoakSample) float64
+	}{
+		{"latency P95 (ms)", func(s SoakSample) float64 { return s.LatencyP95 }},
+		{"heap (MB)", func(s SoakSample) float64 { return float64(s.HeapBytes) / (1024 * 1024) }},
+		{"goroutines", func(s SoakSample) float64 { return float64(s.Goroutines) }},
+		{"fds", func(s SoakSample) float64 { return float64(s.FDs) }},
+		{"target RSS (MB)", func(s SoakSample) float64 { return float64(s.TargetRSS) / (1024 * 1024) }},
+		{"target fds", func(s SoakSample) float64 { return float64(s.TargetFDs) }},
+	}
+	for _, line := range series {
+		values := make([]float64, 0, len(result.Samples))
+		for _, s := range result.Samples {
+			if v := line.value(s); v > 0 {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		fmt.Printf("%-18s %s  %.2f -> %.2f\n", line.name, sparkline(values), values[0], values[len(values)-1])
+	}
+
+	fmt.Println()
+	for _, check := range result.Checks {
+		status := "ok"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("%-16s %+10.2f %-8s (limit %.2f) %s\n", check.Name, check.Value, check.Unit, check.Threshold, status)
+	}
+	if result.Stable {
+		fmt.Printf("Verdict: STABLE\n")
+	} else {
+		fmt.Printf("Verdict: UNSTABLE\n")
+	}
+}
+
+// SaveSoakResult writes result as JSON and its samples as CSV for plotting,
+// returning the JSON path
+func SaveSoakResult(dir string, result *SoakResult) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	base := filepath.Join(dir, "soak_"+result.StartTime.Format("20060102_150405"))
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal soak result: %w", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(base + ".csv")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"elapsed_s", "requests", "errors", "latency_p50_ms", "latency_p95_ms", "latency_p99_ms",
+		"heap_bytes", "goroutines", "fds", "target_rss_bytes", "target_threads", "target_fds"})
+	for _, s := range result.Samples {
+		w.Write([]string{
+			strconv.FormatFloat(s.At.Seconds(), 'f', 0, 64),
+			strconv.Itoa(s.Requests), strconv.Itoa(s.Errors),
+			strconv.FormatFloat(s.LatencyP50, 'f', 3, 64),
+			strconv.FormatFloat(s.LatencyP95, 'f', 3, 64),
+			strconv.FormatFloat(s.LatencyP99, 'f', 3, 64),
+			strconv.FormatInt(s.HeapBytes, 10), strconv.Itoa(s.Goroutines), strconv.Itoa(s.FDs),
+			strconv.FormatInt(s.TargetRSS, 10), strconv.Itoa(s.TargetThreads), strconv.Itoa(s.TargetFDs),
+		})
+	}
+	w.Flush()
+	return base + ".json", w.Error()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunSoak tests that a short soak samples every window at the requested rate
+func TestRunSoak(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := SoakConfig{
+		Benchmark: BenchmarkConfig{TargetURL: server.URL, Concurrency: 2, Timeout: time.Second, KeepAlive: true},
+		Duration:  600 * time.Millisecond,
+		RPS:       50,
+		Window:    200 * time.Millisecond,
+	}
+	var progressed int
+	result, err := RunSoak(context.Background(), config, func(SoakSample) { progressed++ })
+	if err != nil {
+		t.Fatalf("Soak failed: %v", err)
+	}
+
+	if len(result.Samples) < 2 || progressed != len(result.Samples) {
+		t.Fatalf("Expected a sample per window, got %d samples and %d progress calls", len(result.Samples), progressed)
+	}
+	for i, sample := range result.Samples {
+		if sample.Requests != 10 || sample.Errors != 0 {
+			t.Errorf("Sample %d: expected 10 successful requests, got %d with %d errors", i, sample.Requests, sample.Errors)
+		}
+		if sample.HeapBytes <= 0 || sample.Goroutines <= 0 {
+			t.Errorf("Sample %d: expected resource use, got %+v", i, sample)
+		}
+	}
+	if len(result.Checks) < 3 || result.Checks[len(result.Checks)-1].Name != "latency_drift" {
+		t.Errorf("Expected resource checks followed by latency drift, got %+v", result.Checks)
+	}
+
+	dir := t.TempDir()
+	path, err := SaveSoakResult(dir, result)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	file, err := os.Open(strings.TrimSuffix(path, ".json") + ".csv")
+	if err != nil {
+		t.Fatalf("Expected CSV next to %s: %v", path, err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(rows) != len(result.Samples)+1 {
+		t.Errorf("Expected a header and %d rows, got %d (%v)", len(result.Samples), len(rows), err)
+	}
+}
+
+// TestRunSoakTooShort tests that a soak must cover at least one window
+func TestRunSoakTooShort(t *testing.T) {
+	_, err := RunSoak(context.Background(), SoakConfig{Duration: time.Second, Window: time.Minute}, nil)
+	if err == nil {
+		t.Error("Expected an error for a soak shorter than its window")
+	}
+}
+
+// TestEvaluateSoak tests leak and drift detection on synthetic samples
+func TestEvaluateSoak(t *testing.T) {
+	thresholds := DefaultSoakConfig().Thresholds
+	samples := func(heapPerHour int64, goroutinesPerHour int, p95 func(hour int) float64) []SoakSample {
+		var result []SoakSample
+		for hour := 0; hour <= 8; hour++ {
+			result = append(result, SoakSample{
+				At:         time.Duration(hour) * time.Hour,
+				HeapBytes:  50*1024*1024 + int64(hour)*heapPerHour,
+				Goroutines: 20 + hour*goroutinesPerHour,
+				FDs:        -1,
+				LatencyP95: p95(hour),
+			})
+		}
+		return result
+	}
+	flat := func(int) float64 { return 100 }
+
+	tests := []struct {
+		name    string
+		samples []SoakSample
+		failing []string
+	}{
+		{"stable", samples(0, 0, flat), nil},
+		{"heap leak", samples(50*1024*1024, 0, flat), []string{"heap"}},
+		{"goroutine leak", samples(0, 100, flat), []string{"goroutines"}},
+		{"latency drift", samples(0, 0, func(hour int) float64 { return 100 + float64(hour)*10 }), []string{"latency_drift"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := evaluateSoak(tt.samples, thresholds, false)
+			var failing []string
+			for _, check := range checks {
+				if check.Name == "fds" {
+					t.Errorf("Expected no fd check when fds are unavailable")
+				}
+				if !check.Passed {
+					failing = append(failing, check.Name)
+				}
+			}
+			if strings.Join(failing, ",") != strings.Join(tt.failing, ",") {
+				t.Errorf("Expected failing checks %v, got %v (%+v)", tt.failing, failing, checks)
+			}
+		})
+	}
+}
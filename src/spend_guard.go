@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// ErrSpendLimit is returned when the spend guard stopped an iteration
+// because its next requests could have exceeded the dollar budget
+var ErrSpendLimit = errors.New("spend limit reached")
+
+// Spend guard actions for SpendGuardConfig.Action
+const (
+	SpendAbort = "abort" // Stop the run
+	SpendMock  = "mock"  // Send the remaining requests to MockURL
+)
+
+// SpendGuardConfig caps what a benchmark against a paid LLM API may cost.
+// Before a request is sent its worst case, the prompt's tokens plus
+// max_tokens of output, is reserved against MaxSpend, and once the response
+// reports its usage the reservation is replaced by the actual cost. A
+// request whose reservation would exceed the budget is not sent; the run
+// aborts, or with SpendMock that request and every later one goes to a mock
+// of the API instead, so no request in flight can take the bill past the
+// budget
+type SpendGuardConfig struct {
+	MaxSpend float64 `yaml:"max_spend"` // Dollars
+
+	// Dollars per million prompt and output tokens
+	InputPrice  float64 `yaml:"input_price"`
+	OutputPrice float64 `yaml:"output_price"`
+
+	Action  string `yaml:"action"`   // abort (default) or mock
+	MockURL string `yaml:"mock_url"` // Replaces the scheme and host of mocked requests
+}
+
+// Validate checks the budget, prices and action
+func (c *SpendGuardConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxSpend <= 0 {
+		return fmt.Errorf("max_spend must be positive")
+	}
+	if c.InputPrice < 0 || c.OutputPrice < 0 || c.InputPrice+c.OutputPrice == 0 {
+		return fmt.Errorf("token prices must not be negative, and one must be set")
+	}
+	switch c.Action {
+	case "", SpendAbort:
+	case SpendMock:
+		parsed, err := url.Parse(c.MockURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("action %s requires a mock_url such as http://localhost:8080, got %q", SpendMock, c.MockURL)
+		}
+	default:
+		return fmt.Errorf("unknown spend action %q, expected %s or %s", c.Action, SpendAbort, SpendMock)
+	}
+	return nil
+}
+
+// SpendStats is what the runs sharing a spend guard have spent so far
+type SpendStats struct {
+	Budget   float64 `json:"budget"`
+	Spent    float64 `json:"spent"`    // Actual cost of the answered requests, or their reservation when they reported no usage
+	Requests int     `json:"requests"` // Sent to the API
+	Mocked   int     `json:"mocked_requests,omitempty"`
+	Exceeded bool    `json:"exceeded"` // A request was held back
+	Action   string  `json:"action"`
+}
+
+// SpendGuard tracks spending against a SpendGuardConfig's budget. One guard
+// spans every iteration of a run, so the budget caps the whole run. It is
+// safe for concurrent use
+type SpendGuard struct {
+	config SpendGuardConfig
+
+	mu       sync.Mutex
+	spent    float64
+	reserved float64
+	requests int
+	mocked   int
+	exceeded bool
+}
+
+// NewSpendGuard returns a guard with nothing spent
+func NewSpendGuard(config *SpendGuardConfig) *SpendGuard {
+	guard := &SpendGuard{config: *config}
+	if guard.config.Action == "" {
+		guard.config.Action = SpendAbort
+	}
+	return guard
+}
+
+// cost prices token counts
+func (g *SpendGuard) cost(prompt, output int) float64 {
+	return float64(prompt)/1e6*g.config.InputPrice + float64(output)/1e6*g.config.OutputPrice
+}
+
+// Reserve reserves the worst-case cost of a request with the given prompt
+// tokens and max_tokens, returning it, or reports false when it could
+// exceed the budget. Once one request is held back, so is every later one
+func (g *SpendGuard) Reserve(prompt, maxTokens int) (float64, bool) {
+	cost := g.cost(prompt, maxTokens)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exceeded || g.spent+g.reserved+cost > g.config.MaxSpend {
+		g.exceeded = true
+		return 0, false
+	}
+	g.reserved += cost
+	g.requests++
+	return cost, true
+}
+
+// Settle replaces a reservation with what the request actually cost
+func (g *SpendGuard) Settle(reserved, cost float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.reserved -= reserved
+	g.spent += cost
+}
+
+// Mock records a request sent to the mock instead, returning its URL
+func (g *SpendGuard) Mock(target string) (string, error) {
+	mocked, err := mockURL(target, g.config.MockURL)
+	if err != nil {
+		return "", err
+	}
+	g.mu.Lock()
+	g.mocked++
+	g.mu.Unlock()
+	return mocked, nil
+}
+
+// Stats describes what was spent so far
+func (g *SpendGuard) Stats() *SpendStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return &SpendStats{
+		Budget:   g.config.MaxSpend,
+		Spent:    g.spent,
+		Requests: g.requests,
+		Mocked:   g.mocked,
+		Exceeded: g.exceeded,
+		Action:   g.config.Action,
+	}
+}
+
+// mockURL sends target to the scheme and host of mock, keeping its path and
+// query
+func mockURL(target, mock string) (string, error) {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	base, err := url.Parse(mock)
+	if err != nil {
+		return "", err
+	}
+	parsed.Scheme, parsed.Host = base.Scheme, base.Host
+	return parsed.String(), nil
+}
+
+// responseCost returns what a request that reserved reserved actually cost:
+// its usage, nothing when the API rejected it, or the reservation when the
+// response reports no usage
+func (g *SpendGuard) responseCost(reserved float64, status int, body []byte) float64 {
+	if input, output, ok := responseUsage(body); ok {
+		return g.cost(input, output)
+	}
+	if status >= 400 {
+		return 0
+	}
+	return reserved
+}
+
+// Token counts in a response's usage, in the Anthropic and OpenAI formats;
+// the last match counts, as streamed responses repeat them cumulatively
+var (
+	usageInputPattern  = regexp.MustCompile(`"(?:input_tokens|prompt_tokens)"\s*:\s*(\d+)`)
+	usageOutputPattern = regexp.MustCompile(`"(?:output_tokens|completion_tokens)"\s*:\s*(\d+)`)
+	maxTokensPattern   = regexp.MustCompile(`"max_(?:completion_)?tokens"\s*:\s*(\d+)`)
+)
+
+// responseUsage returns the token usage a response body reports, plain or
+// streamed; ok is false when it reports none
+func responseUsage(body []byte) (input, output int, ok bool) {
+	inputs := usageInputPattern.FindAllSubmatch(body, -1)
+	outputs := usageOutputPattern.FindAllSubmatch(body, -1)
+	if len(inputs) == 0 && len(outputs) == 0 {
+		return 0, 0, false
+	}
+	if len(inputs) > 0 {
+		input, _ = strconv.Atoi(string(inputs[len(inputs)-1][1]))
+	}
+	if len(outputs) > 0 {
+		output, _ = strconv.Atoi(string(outputs[len(outputs)-1][1]))
+	}
+	return input, output, true
+}
+
+// requestTokens estimates a request body's prompt tokens and reads its
+// max_tokens, DefaultMaxTokens when it sets none
+func requestTokens(body []byte) (prompt, maxTokens int) {
+	maxTokens = DefaultMaxTokens
+	if match := maxTokensPattern.FindSubmatch(body); match != nil {
+		maxTokens, _ = strconv.Atoi(string(match[1]))
+	}
+	return EstimateTokens(string(body)), maxTokens
+}
+
+// printSpendStats prints the spend guard's summary
+func printSpendStats(stats *SpendStats) {
+	fmt.Printf("\n--- Spend ---\n")
+	fmt.Printf("Spent: $%.4f of $%.2f over %d requests\n", stats.Spent, stats.Budget, stats.Requests)
+	if stats.Mocked > 0 {
+		fmt.Printf("WARNING: %d requests went to the mock after the budget ran out; their latencies are the mock's\n", stats.Mocked)
+	} else if stats.Exceeded {
+		fmt.Printf("ABORTED: the next requests could have exceeded the budget\n")
+	}
+}
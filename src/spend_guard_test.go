@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// TestSpendGuardReserve tests that reservations count against the budget
+// until they are settled at the actual cost
+func TestSpendGuardReserve(t *testing.T) {
+	guard := NewSpendGuard(&SpendGuardConfig{MaxSpend: 1, InputPrice: 1, OutputPrice: 2})
+
+	// 100k prompt and 200k output tokens reserve $0.50
+	first, ok := guard.Reserve(100000, 200000)
+	if !ok || math.Abs(first-0.5) > 1e-9 {
+		t.Fatalf("Expected a $0.50 reservation, got $%v (%v)", first, ok)
+	}
+	second, ok := guard.Reserve(100000, 200000)
+	if !ok {
+		t.Fatal("Expected a second reservation to fit the budget")
+	}
+	if _, ok := guard.Reserve(1, 0); ok {
+		t.Error("Expected a reservation past the budget to be held back")
+	}
+
+	// Settling below the reservation frees budget, but the guard stays tripped
+	guard.Settle(first, 0.1)
+	guard.Settle(second, 0.1)
+	if _, ok := guard.Reserve(1, 0); ok {
+		t.Error("Expected the guard to keep holding requests back once exceeded")
+	}
+
+	stats := guard.Stats()
+	if math.Abs(stats.Spent-0.2) > 1e-9 || stats.Requests != 2 || !stats.Exceeded || stats.Action != SpendAbort {
+		t.Errorf("Expected $0.20 over 2 requests, exceeded with abort, got %+v", stats)
+	}
+}
+
+// TestResponseUsage tests reading usage from plain and streamed responses
+func TestResponseUsage(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		input, output int
+		ok            bool
+	}{
+		{"anthropic", `{"usage":{"input_tokens":12,"output_tokens":34}}`, 12, 34, true},
+		{"openai", `{"usage": {"prompt_tokens": 5, "completion_tokens": 7, "total_tokens": 12}}`, 5, 7, true},
+		{"streamed", "data: {\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}\n\ndata: {\"usage\":{\"output_tokens\":40}}\n\n", 12, 40, true},
+		{"none", `{"ok":true}`, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		input, output, ok := responseUsage([]byte(tt.body))
+		if input != tt.input || output != tt.output || ok != tt.ok {
+			t.Errorf("%s: expected %d, %d (%v), got %d, %d (%v)", tt.name, tt.input, tt.output, tt.ok, input, output, ok)
+		}
+	}
+
+	if _, maxTokens := requestTokens([]byte(`{"prompt":"hi","max_tokens":64}`)); maxTokens != 64 {
+		t.Errorf("Expected max_tokens 64, got %d", maxTokens)
+	}
+	if _, maxTokens := requestTokens([]byte(`{"prompt":"hi"}`)); maxTokens != DefaultMaxTokens {
+		t.Errorf("Expected the default max_tokens, got %d", maxTokens)
+	}
+}
+
+// TestSpendGuardConfigValidate tests rejecting budgets, prices and actions
+func TestSpendGuardConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SpendGuardConfig
+		wantErr bool
+	}{
+		{"valid", SpendGuardConfig{MaxSpend: 10, InputPrice: 3, OutputPrice: 15}, false},
+		{"mock", SpendGuardConfig{MaxSpend: 10, OutputPrice: 15, Action: SpendMock, MockURL: "http://localhost:8080"}, false},
+		{"no budget", SpendGuardConfig{InputPrice: 3, OutputPrice: 15}, true},
+		{"no prices", SpendGuardConfig{MaxSpend: 10}, true},
+		{"negative price", SpendGuardConfig{MaxSpend: 10, InputPrice: -1, OutputPrice: 15}, true},
+		{"mock without URL", SpendGuardConfig{MaxSpend: 10, OutputPrice: 15, Action: SpendMock}, true},
+		{"unknown action", SpendGuardConfig{MaxSpend: 10, OutputPrice: 15, Action: "ignore"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+// TestBenchmarkerSpendGuard tests that a run stops, or moves to the mock,
+// before its budget is exceeded
+func TestBenchmarkerSpendGuard(t *testing.T) {
+	var paid, mocked atomic.Int64
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paid.Add(1)
+		w.Write([]byte(`{"usage":{"input_tokens":10,"output_tokens":50}}`))
+	}))
+	defer api.Close()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mocked.Add(1)
+	}))
+	defer mock.Close()
+
+	// Each request reserves about 110 tokens and costs 60, at $1 a million
+	config := BenchmarkConfig{
+		TargetURL:     api.URL,
+		Method:        "POST",
+		Body:          []byte(`{"prompt":"hello","max_tokens":100}`),
+		TotalRequests: 40,
+		Concurrency:   1,
+		SpendGuard:    &SpendGuardConfig{MaxSpend: 0.001, InputPrice: 1, OutputPrice: 1},
+	}
+
+	ctx := context.Background()
+	result, err := NewBenchmarker(config).Run(ctx)
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if paid.Load() != 15 || result.Spend.Requests != 15 {
+		t.Errorf("Expected 15 requests before the budget ran out, got %d sent and %d counted", paid.Load(), result.Spend.Requests)
+	}
+	if result.Spend.Spent > result.Spend.Budget || math.Abs(result.Spend.Spent-0.0009) > 1e-9 {
+		t.Errorf("Expected $0.0009 spent within the budget, got $%v", result.Spend.Spent)
+	}
+	if err := interruptedBy(ctx, result); !errors.Is(err, ErrSpendLimit) {
+		t.Errorf("Expected the run to stop on the spend limit, got %v", err)
+	}
+
+	paid.Store(0)
+	config.SpendGuard.Action = SpendMock
+	config.SpendGuard.MockURL = mock.URL
+	result, err = NewBenchmarker(config).Run(ctx)
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if paid.Load() != 15 || mocked.Load() != 25 || result.Spend.Mocked != 25 {
+		t.Errorf("Expected 15 paid and 25 mocked requests, got %d and %d (%d counted)", paid.Load(), mocked.Load(), result.Spend.Mocked)
+	}
+	if result.SuccessfulReqs != 40 {
+		t.Errorf("Expected every request to succeed, got %d", result.SuccessfulReqs)
+	}
+}
+
+// FuzzResponseUsage tests that any response body, plain JSON or a
+// server-sent event stream, yields usage without panicking
+func FuzzResponseUsage(f *testing.F) {
+	f.Add([]byte(`{"usage":{"input_tokens":12,"output_tokens":34}}`))
+	f.Add([]byte(`{"usage": {"prompt_tokens": 5, "completion_tokens": 7, "max_tokens": 64}}`))
+	f.Add([]byte("event: message_delta\ndata: {\"usage\":{\"output_tokens\":99999999999999999999999}}\n\n"))
+	f.Add([]byte(`{"max_completion_tokens":`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		input, output, ok := responseUsage(body)
+		if !ok && (input != 0 || output != 0) {
+			t.Errorf("Expected no usage when none is reported, got %d and %d", input, output)
+		}
+		if prompt, _ := requestTokens(body); prompt < 0 {
+			t.Errorf("Expected a non-negative prompt estimate, got %d", prompt)
+		}
+	})
+}
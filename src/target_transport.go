@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// H2C modes for cleartext HTTP/2 on http:// targets
+const (
+	H2CPriorKnowledge = "prior_knowledge" // Speak HTTP/2 from the first byte
+	H2CUpgrade        = "upgrade"         // Probe each host with Upgrade: h2c and use HTTP/2 where it switches
+)
+
+// unixSocketHost is the Host of requests sent over a Unix domain socket
+const unixSocketHost = "localhost"
+
+// h2cProbeSettings is an HTTP2-Settings header value: max concurrent
+// streams 100, push disabled
+const h2cProbeSettings = "AAMAAABkAAIAAAAA"
+
+// ParseUnixTarget splits a unix://SOCKET[:PATH] target into the socket path
+// and the http:// URL requested over it, e.g. unix:///run/app.sock:/v1/items
+func ParseUnixTarget(target string) (socket, requestURL string, ok bool) {
+	rest, found := strings.CutPrefix(target, "unix://")
+	if !found {
+		return "", "", false
+	}
+	socket, path, _ := strings.Cut(rest, ":")
+	if path == "" {
+		path = "/"
+	} else if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return socket, "http://" + unixSocketHost + path, socket != ""
+}
+
+// dialUnixSocket returns a dialer that connects every request to socket
+func dialUnixSocket(socket string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
+
+// enableH2C makes transport use HTTP/2 with prior knowledge for http:// URLs
+func enableH2C(transport *http.Transport) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport.Protocols = protocols
+}
+
+// newH2CRoundTripper applies the h2c mode to transport. wrap instruments each
+// underlying transport, e.g. ConnectionTracker.Wrap; the upgrade mode keeps an
+// HTTP/1.1 and an HTTP/2 transport side by side
+func newH2CRoundTripper(transport *http.Transport, mode string, wrap func(*http.Transport) http.RoundTripper) (http.RoundTripper, error) {
+	switch mode {
+	case "":
+		return wrap(transport), nil
+	case H2CPriorKnowledge:
+		enableH2C(transport)
+		return wrap(transport), nil
+	case H2CUpgrade:
+		// Clone and capture the dialer before wrap instruments it, so the
+		// probes stay out of connection metrics
+		h2c := transport.Clone()
+		enableH2C(h2c)
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+		}
+		return &h2cUpgradeTransport{
+			http1:  wrap(transport),
+			h2c:    wrap(h2c),
+			dial:   dial,
+			probes: make(map[string]*h2cProbe),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown h2c mode %q: use %s or %s", mode, H2CPriorKnowledge, H2CUpgrade)
+	}
+}
+
+// h2cProbe is the upgrade result for one host, shared by concurrent requests
+type h2cProbe struct {
+	done     chan struct{}
+	upgraded bool
+	err      error
+}
+
+// h2cUpgradeTransport sends each http:// host's requests over HTTP/2 once an
+// HTTP/1.1 Upgrade: h2c probe shows the server supports it. Go's client does
+// not continue a request on an upgraded connection, so the probe runs once
+// per host ahead of the real traffic and later connections use prior knowledge
+type h2cUpgradeTransport struct {
+	http1 http.RoundTripper
+	h2c   http.RoundTripper
+	dial  func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	probes map[string]*h2cProbe
+	mu     sync.Mutex
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *h2cUpgradeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return t.http1.RoundTrip(req)
+	}
+	if t.upgraded(req) {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.http1.RoundTrip(req)
+}
+
+// upgraded reports whether req's host accepted h2c, probing it on first use.
+// Failed probes are retried by the next request and fall back to HTTP/1.1
+func (t *h2cUpgradeTransport) upgraded(req *http.Request) bool {
+	host := canonicalAddr(req.URL)
+
+	t.mu.Lock()
+	probe, exists := t.probes[host]
+	if !exists {
+		probe = &h2cProbe{done: make(chan struct{})}
+		t.probes[host] = probe
+	}
+	t.mu.Unlock()
+
+	if exists {
+		select {
+		case <-probe.done:
+			return probe.upgraded
+		case <-req.Context().Done():
+			return false
+		}
+	}
+
+	probe.upgraded, probe.err = probeH2CUpgrade(req.Context(), t.dial, req, host)
+	if probe.err != nil {
+		t.mu.Lock()
+		delete(t.probes, host)
+		t.mu.Unlock()
+	}
+	close(probe.done)
+	return probe.upgraded
+}
+
+// probeH2CUpgrade sends an OPTIONS request asking to upgrade to h2c and
+// reports whether the server switched protocols
+func probeH2CUpgrade(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), req *http.Request, addr string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	probeURL := *req.URL
+	probe := &http.Request{
+		Method:     http.MethodOptions,
+		URL:        &probeURL,
+		Host:       req.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Connection":     {"Upgrade, HTTP2-Settings"},
+			"Upgrade":        {"h2c"},
+			"Http2-Settings": {h2cProbeSettings},
+		},
+	}
+	if err := probe.Write(conn); err != nil {
+		return false, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), probe)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusSwitchingProtocols && strings.EqualFold(resp.Header.Get("Upgrade"), "h2c"), nil
+}
+
+// canonicalAddr returns u's host with the scheme's default port
+func canonicalAddr(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// TestParseUnixTarget tests splitting unix:// targets into socket and URL
+func TestParseUnixTarget(t *testing.T) {
+	tests := []struct {
+		target, socket, url string
+		ok                  bool
+	}{
+		{"unix:///run/app.sock", "/run/app.sock", "http://localhost/", true},
+		{"unix:///run/app.sock:/v1/items?limit=5", "/run/app.sock", "http://localhost/v1/items?limit=5", true},
+		{"unix://app.sock:health", "app.sock", "http://localhost/health", true},
+		{"unix://", "", "http://localhost/", false},
+		{"http://localhost/", "", "", false},
+	}
+	for _, tt := range tests {
+		socket, url, ok := ParseUnixTarget(tt.target)
+		if socket != tt.socket || url != tt.url || ok != tt.ok {
+			t.Errorf("%s: got (%q, %q, %v)", tt.target, socket, url, ok)
+		}
+	}
+}
+
+// protoRecorder counts requests by HTTP major version
+type protoRecorder struct {
+	http1, http2, options atomic.Int64
+	lastPath              atomic.Value
+}
+
+func (p *protoRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Upgrade probes are counted apart; whether h2c serves one before the
+	// probe hangs up is a race
+	if r.Method == http.MethodOptions {
+		p.options.Add(1)
+		return
+	}
+	if r.ProtoMajor == 2 {
+		p.http2.Add(1)
+	} else {
+		p.http1.Add(1)
+	}
+	p.lastPath.Store(r.URL.RequestURI())
+	w.Write([]byte("ok"))
+}
+
+// TestBenchmarkerUnixSocket tests benchmarking over a Unix domain socket,
+// with and without h2c
+func TestBenchmarkerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	recorder := &protoRecorder{}
+	server := httptest.NewUnstartedServer(h2c.NewHandler(recorder, &http2.Server{}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	for _, mode := range []string{"", H2CPriorKnowledge} {
+		result, err := NewBenchmarker(BenchmarkConfig{
+			TargetURL:         "unix://" + socket + ":/v1/items?limit=5",
+			TotalRequests:     4,
+			Concurrency:       2,
+			KeepAlive:         true,
+			IncludeRawMetrics: true,
+			H2C:               mode,
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.SuccessfulReqs != 4 {
+			t.Fatalf("h2c %q: expected 4 successful requests, got %d (%v)", mode, result.SuccessfulReqs, result.ErrorTypes)
+		}
+		if result.TargetURL != "unix://"+socket+":/v1/items?limit=5" {
+			t.Errorf("Expected the unix target to be reported, got %s", result.TargetURL)
+		}
+
+		dialed := false
+		for _, m := range result.RawMetrics {
+			if m.TCPConnection > 0 {
+				dialed = true
+			}
+		}
+		if !dialed {
+			t.Errorf("h2c %q: expected socket connect timings", mode)
+		}
+	}
+
+	if recorder.lastPath.Load() != "/v1/items?limit=5" {
+		t.Errorf("Unexpected request path %v", recorder.lastPath.Load())
+	}
+	if recorder.http1.Load() != 4 || recorder.http2.Load() != 4 {
+		t.Errorf("Expected 4 HTTP/1.1 and 4 h2c requests, got %d and %d", recorder.http1.Load(), recorder.http2.Load())
+	}
+}
+
+// TestBenchmarkerH2CUpgrade tests that upgrade mode uses HTTP/2 only where the
+// server accepts it, probing each host once
+func TestBenchmarkerH2CUpgrade(t *testing.T) {
+	h2cRecorder := &protoRecorder{}
+	h2cServer := httptest.NewServer(h2c.NewHandler(h2cRecorder, &http2.Server{}))
+	defer h2cServer.Close()
+
+	http1Recorder := &protoRecorder{}
+	http1Server := httptest.NewServer(http1Recorder)
+	defer http1Server.Close()
+
+	for _, tt := range []struct {
+		server   *httptest.Server
+		recorder *protoRecorder
+		http2    bool
+	}{
+		{h2cServer, h2cRecorder, true},
+		{http1Server, http1Recorder, false},
+	} {
+		result, err := NewBenchmarker(BenchmarkConfig{
+			TargetURL:     tt.server.URL,
+			TotalRequests: 6,
+			Concurrency:   3,
+			KeepAlive:     true,
+			H2C:           H2CUpgrade,
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.SuccessfulReqs != 6 {
+			t.Fatalf("Expected 6 successful requests, got %d (%v)", result.SuccessfulReqs, result.ErrorTypes)
+		}
+
+		if tt.recorder.options.Load() > 1 {
+			t.Errorf("Expected a single upgrade probe, got %d", tt.recorder.options.Load())
+		}
+		if tt.http2 && tt.recorder.http2.Load() != 6 {
+			t.Errorf("Expected every request over h2c, got %d", tt.recorder.http2.Load())
+		}
+		if !tt.http2 && (tt.recorder.http2.Load() != 0 || tt.recorder.http1.Load() != 6) {
+			t.Errorf("Expected HTTP/1.1 fallback, got %d HTTP/1.1 and %d HTTP/2", tt.recorder.http1.Load(), tt.recorder.http2.Load())
+		}
+	}
+
+	if _, err := NewBenchmarker(BenchmarkConfig{TargetURL: h2cServer.URL, H2C: "always"}).Run(context.Background()); err == nil {
+		t.Error("Expected an error for an unknown h2c mode")
+	}
+}
+
+// TestHTTP2ClientKeepsDefaultTransport tests that the client keeps the default
+// proxy and dial settings while applying its pool config
+func TestHTTP2ClientKeepsDefaultTransport(t *testing.T) {
+	client, err := NewHTTP2Client(&HTTP2ClientConfig{MaxConnectionsPerHost: 7})
+	if err != nil {
+		t.Fatalf("NewHTTP2Client failed: %v", err)
+	}
+
+	tracking, ok := client.client.Transport.(*trackingRoundTripper)
+	if !ok {
+		t.Fatalf("Expected a tracking round tripper, got %T", client.client.Transport)
+	}
+	transport := tracking.base.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Error("Expected the default proxy function to be kept")
+	}
+	if transport.TLSHandshakeTimeout == 0 || transport.IdleConnTimeout == 0 {
+		t.Errorf("Expected default timeouts, got TLS %v and idle %v", transport.TLSHandshakeTimeout, transport.IdleConnTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != 7 {
+		t.Errorf("Expected 7 idle connections per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+}
@@ -0,0 +1,29 @@
+// Command checkplugin is a Go plugin supplying a response check, loaded by
+// TestLoadPluginsBuilt
+package main
+
+import (
+	"errors"
+
+	"api-latency-optimizer/checks"
+)
+
+// nonEmpty fails responses without a body
+type nonEmpty struct{}
+
+func (nonEmpty) Name() string { return "non_empty" }
+
+func (nonEmpty) Check(resp *checks.Response) error {
+	if len(resp.Body) == 0 {
+		return errors.New("empty body")
+	}
+	return nil
+}
+
+type plugin struct{}
+
+func (plugin) Checks() []checks.Check   { return []checks.Check{nonEmpty{}} }
+func (plugin) Metrics() []checks.Metric { return nil }
+
+// Plugin is looked up by the benchmark tool
+var Plugin checks.Plugin = plugin{}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottlePolicy makes a benchmark back off when the target rate limits it,
+// as a well-behaved client would, instead of counting every 429 as an error.
+// A 429, or a 503 with Retry-After, pauses every worker for the Retry-After
+// delay and the request is retried; the pause is recorded as throttle time,
+// apart from latency, and the run reports the rate the target's limits
+// actually allow
+type ThrottlePolicy struct {
+	// Retries of a throttled request before it counts as failed; zero means 5
+	MaxRetries int `yaml:"max_retries"`
+
+	// Pause after a response without Retry-After, doubled on each retry of
+	// the same request; zero means 1s
+	DefaultWait time.Duration `yaml:"default_wait"`
+
+	// Longest single pause, whatever Retry-After asks for; zero means 60s
+	MaxWait time.Duration `yaml:"max_wait"`
+}
+
+// Validate checks the policy's values
+func (p *ThrottlePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxRetries < 0 || p.DefaultWait < 0 || p.MaxWait < 0 {
+		return fmt.Errorf("throttle retries and waits must not be negative")
+	}
+	return nil
+}
+
+// retries returns how many times a throttled request is retried
+func (p *ThrottlePolicy) retries() int {
+	if p.MaxRetries == 0 {
+		return 5
+	}
+	return p.MaxRetries
+}
+
+// wait returns the pause after the attempt'th throttled response, from 1,
+// that asked for retryAfter
+func (p *ThrottlePolicy) wait(retryAfter time.Duration, attempt int) time.Duration {
+	wait := retryAfter
+	if wait <= 0 {
+		wait = p.DefaultWait
+		if wait == 0 {
+			wait = time.Second
+		}
+		wait <<= min(attempt-1, 10)
+	}
+	limit := p.MaxWait
+	if limit == 0 {
+		limit = time.Minute
+	}
+	return min(wait, limit)
+}
+
+// ThrottleStats describes how a run was throttled
+type ThrottleStats struct {
+	Responses int `json:"throttled_responses"` // 429s, and 503s with Retry-After
+	Requests  int `json:"throttled_requests"`  // Requests throttled at least once
+	Exhausted int `json:"exhausted"`           // Still throttled after every retry
+
+	// Per throttled request, time held back by pauses; not part of latency
+	ThrottleTime LatencyStats `json:"throttle_time"`
+
+	// Wall time every worker was paused
+	PausedTime time.Duration `json:"paused_time"`
+
+	// Successful requests per second over the whole run, pauses included:
+	// the rate the target's limits allow. UnthrottledRPS leaves out the
+	// paused time
+	EffectiveRPS   float64 `json:"effective_rps"`
+	UnthrottledRPS float64 `json:"unthrottled_rps"`
+
+	// Last RateLimit-Limit or X-RateLimit-Limit header the target sent
+	RateLimit string `json:"rate_limit,omitempty"`
+}
+
+// throttleGate pauses every worker of a run while the target asks clients to
+// back off
+type throttleGate struct {
+	policy *ThrottlePolicy
+
+	mu        sync.Mutex
+	until     time.Time
+	paused    time.Duration
+	rateLimit string
+}
+
+// newThrottleGate returns a gate applying policy
+func newThrottleGate(policy *ThrottlePolicy) *throttleGate {
+	return &throttleGate{policy: policy}
+}
+
+// Wait blocks until the gate is open or ctx is done, returning how long it
+// waited
+func (g *throttleGate) Wait(ctx context.Context) time.Duration {
+	start := time.Now()
+	for {
+		g.mu.Lock()
+		remaining := time.Until(g.until)
+		g.mu.Unlock()
+		if remaining <= 0 {
+			return time.Since(start)
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Since(start)
+		case <-timer.C:
+		}
+	}
+}
+
+// Hold closes the gate for wait from now, unless it already stays closed
+// longer, adding the extension to the paused time
+func (g *throttleGate) Hold(wait time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	until := now.Add(wait)
+	if !until.After(g.until) {
+		return
+	}
+	if g.until.After(now) {
+		g.paused += until.Sub(g.until)
+	} else {
+		g.paused += wait
+	}
+	g.until = until
+}
+
+// observe records the rate limit the target advertises in resp
+func (g *throttleGate) observe(resp *http.Response) {
+	limit := resp.Header.Get("RateLimit-Limit")
+	if limit == "" {
+		limit = resp.Header.Get("X-RateLimit-Limit")
+	}
+	if limit == "" {
+		return
+	}
+	g.mu.Lock()
+	g.rateLimit = limit
+	g.mu.Unlock()
+}
+
+// pausedTime returns the wall time the gate was closed
+func (g *throttleGate) pausedTime() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// isThrottleResponse reports whether a response asks the client to back off
+func isThrottleResponse(status int, retryAfter time.Duration) bool {
+	return status == http.StatusTooManyRequests || (status == http.StatusServiceUnavailable && retryAfter > 0)
+}
+
+// parseRetryAfter returns the delay a Retry-After header asks for, as
+// seconds or an HTTP date; zero when it is missing or unparsable
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// throttledRequest measures a request, pausing and retrying while the target
+// throttles it. The returned metric is the last attempt's, with the time
+// held back and the throttled attempts recorded
+func (b *Benchmarker) throttledRequest(ctx context.Context, workerID, requestID int) LatencyMetrics {
+	if b.throttle == nil {
+		return b.measureRequest(ctx, workerID, requestID)
+	}
+
+	var held time.Duration
+	for attempt := 0; ; attempt++ {
+		held += b.throttle.Wait(ctx)
+		metric := b.measureRequest(ctx, workerID, requestID)
+		metric.ThrottleTime = held
+		metric.Throttled = attempt
+		if metric.Error != "" || !isThrottleResponse(metric.StatusCode, metric.RetryAfter) {
+			return metric
+		}
+
+		metric.Throttled++
+		if attempt == b.throttle.policy.retries() {
+			metric.Error = fmt.Sprintf("throttled: status %d after %d retries", metric.StatusCode, attempt)
+			metric.ErrorType = ErrorTypeRateLimited
+			return metric
+		}
+		b.throttle.Hold(b.throttle.policy.wait(metric.RetryAfter, attempt+1))
+		if ctx.Err() != nil {
+			return metric
+		}
+	}
+}
+
+// calculateThrottleStats summarizes throttling, or returns nil without a
+// throttle policy
+func (b *Benchmarker) calculateThrottleStats(metrics []LatencyMetrics, result *BenchmarkResult) *ThrottleStats {
+	if b.throttle == nil {
+		return nil
+	}
+
+	stats := &ThrottleStats{PausedTime: b.throttle.pausedTime()}
+	var held []float64
+	for _, m := range metrics {
+		if m.Throttled == 0 {
+			continue
+		}
+		stats.Responses += m.Throttled
+		stats.Requests++
+		if m.Error != "" && isThrottleResponse(m.StatusCode, m.RetryAfter) {
+			stats.Exhausted++
+		}
+		held = append(held, float64(m.ThrottleTime.Microseconds())/1000.0)
+	}
+	stats.ThrottleTime = CalculateStats(held)
+
+	if seconds := result.Duration.Seconds(); seconds > 0 {
+		stats.EffectiveRPS = float64(result.SuccessfulReqs) / seconds
+		if unpaused := (result.Duration - stats.PausedTime).Seconds(); unpaused > 0 {
+			stats.UnthrottledRPS = float64(result.SuccessfulReqs) / unpaused
+		}
+	}
+
+	b.throttle.mu.Lock()
+	stats.RateLimit = b.throttle.rateLimit
+	b.throttle.mu.Unlock()
+	return stats
+}
+
+// printThrottleStats prints the throttling summary
+func printThrottleStats(stats *ThrottleStats) {
+	fmt.Printf("\n--- Throttling (Retry-After honored, excluded from latency) ---\n")
+	fmt.Printf("Throttled responses: %d across %d requests | Exhausted retries: %d\n", stats.Responses, stats.Requests, stats.Exhausted)
+	fmt.Printf("Paused: %v | Held back per request P50: %.2f ms, P95: %.2f ms\n",
+		stats.PausedTime.Round(time.Millisecond), stats.ThrottleTime.P50, stats.ThrottleTime.P95)
+	fmt.Printf("Effective RPS under rate limits: %.2f (%.2f while not paused)\n", stats.EffectiveRPS, stats.UnthrottledRPS)
+	if stats.RateLimit != "" {
+		fmt.Printf("Advertised rate limit: %s\n", stats.RateLimit)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestParseRetryAfter tests both Retry-After forms
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"0", 0},
+		{"-1", 0},
+		{now.Add(3 * time.Second).Format(http.TimeFormat), 3 * time.Second},
+		{now.Add(-time.Second).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("Expected %v for %q, got %v", tt.expected, tt.value, got)
+		}
+	}
+}
+
+// TestThrottlePolicyWait tests the pause for each throttled response
+func TestThrottlePolicyWait(t *testing.T) {
+	policy := &ThrottlePolicy{DefaultWait: 100 * time.Millisecond, MaxWait: time.Second}
+	tests := []struct {
+		retryAfter time.Duration
+		attempt    int
+		expected   time.Duration
+	}{
+		{300 * time.Millisecond, 1, 300 * time.Millisecond},
+		{5 * time.Second, 1, time.Second},
+		{0, 1, 100 * time.Millisecond},
+		{0, 3, 400 * time.Millisecond},
+		{0, 5, time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.wait(tt.retryAfter, tt.attempt); got != tt.expected {
+			t.Errorf("Expected %v for Retry-After %v on attempt %d, got %v", tt.expected, tt.retryAfter, tt.attempt, got)
+		}
+	}
+	if (&ThrottlePolicy{MaxRetries: -1}).Validate() == nil {
+		t.Error("Expected negative retries to be rejected")
+	}
+}
+
+// TestBenchmarkerHonorsRetryAfter tests that throttled requests are retried
+// after the pause, which is kept out of latency
+func TestBenchmarkerHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "2")
+		if calls.Add(1)%3 == 0 {
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	b := NewBenchmarker(BenchmarkConfig{
+		TargetURL:         server.URL,
+		TotalRequests:     6,
+		Concurrency:       1,
+		Throttle:          &ThrottlePolicy{},
+		IncludeRawMetrics: true,
+	})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if result.SuccessfulReqs != 6 || result.FailedReqs != 0 {
+		t.Fatalf("Expected 6 successful requests, got %d successful and %d failed", result.SuccessfulReqs, result.FailedReqs)
+	}
+	stats := result.Throttle
+	if stats == nil || stats.Responses != 2 || stats.Requests != 2 || stats.Exhausted != 0 || stats.RateLimit != "2" {
+		t.Fatalf("Expected 2 throttled requests under a limit of 2, got %+v", stats)
+	}
+	if stats.PausedTime < 100*time.Millisecond || stats.ThrottleTime.P50 < 50 {
+		t.Errorf("Expected 2 pauses of 50 ms, got %v paused and %+v held", stats.PausedTime, stats.ThrottleTime)
+	}
+	if stats.EffectiveRPS <= 0 || stats.EffectiveRPS > stats.UnthrottledRPS {
+		t.Errorf("Expected the effective RPS below the unthrottled RPS, got %.2f and %.2f", stats.EffectiveRPS, stats.UnthrottledRPS)
+	}
+	for _, m := range result.RawMetrics {
+		if m.Throttled > 0 && m.TotalLatency >= m.ThrottleTime {
+			t.Errorf("Expected throttle time outside the latency, got %v latency and %v held", m.TotalLatency, m.ThrottleTime)
+		}
+	}
+}
+
+// TestBenchmarkerThrottleExhausted tests that a request still throttled after
+// every retry fails as rate limited
+func TestBenchmarkerThrottleExhausted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	b := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 1,
+		Concurrency:   1,
+		Throttle:      &ThrottlePolicy{MaxRetries: 2, DefaultWait: 10 * time.Millisecond},
+	})
+	result, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if result.FailedReqs != 1 || result.ErrorTypes[ErrorTypeRateLimited] != 1 {
+		t.Errorf("Expected 1 rate limited failure, got %d failed with %v", result.FailedReqs, result.ErrorTypes)
+	}
+	if stats := result.Throttle; stats == nil || stats.Responses != 3 || stats.Exhausted != 1 || stats.PausedTime < 30*time.Millisecond {
+		t.Errorf("Expected 3 throttled responses after 30 ms of pauses, got %+v", stats)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Metrics covered by trend analysis, named as in MonitoringSnapshot JSON
+const (
+	TrendMetricLatencyP95    = "latency_p95"
+	TrendMetricLatencyP99    = "latency_p99"
+	TrendMetricErrorRate     = "error_rate"
+	TrendMetricThroughput    = "throughput"
+	TrendMetricCacheHitRatio = "cache_hit_ratio"
+)
+
+// TrendMetrics lists every metric trend analysis covers
+var TrendMetrics = []string{
+	TrendMetricLatencyP95,
+	TrendMetricLatencyP99,
+	TrendMetricErrorRate,
+	TrendMetricThroughput,
+	TrendMetricCacheHitRatio,
+}
+
+// Trend directions
+const (
+	TrendDirectionRising  = "rising"
+	TrendDirectionFalling = "falling"
+	TrendDirectionStable  = "stable"
+)
+
+// Sources of the baseline a value is scored against
+const (
+	BaselineSeasonal = "seasonal" // Same time slot in earlier periods
+	BaselineEWMA     = "ewma"     // Exponentially weighted recent history
+)
+
+// TrendConfig tunes trend analysis and anomaly scoring
+type TrendConfig struct {
+	MovingAverageWindow int     // Samples in the simple moving average
+	EWMAAlpha           float64 // Weight of the newest sample, 0-1
+	AnomalyThreshold    float64 // |z-score| above which a sample is anomalous
+	MinSamples          int     // Samples of history needed before scoring
+	StableChange        float64 // Fractional change over the window still considered stable
+
+	// Seasonality: samples in the same slot of earlier periods (e.g. the same
+	// hour on earlier days) form the baseline once enough of them exist
+	SeasonPeriod       time.Duration
+	SeasonSlots        int
+	MinSeasonalSamples int
+
+	MaxAnomalies int // Most recent anomalies kept per metric
+}
+
+// DefaultTrendConfig returns hourly seasonality over a daily period with a
+// 3-sigma anomaly threshold
+func DefaultTrendConfig() TrendConfig {
+	return TrendConfig{
+		MovingAverageWindow: 10,
+		EWMAAlpha:           0.3,
+		AnomalyThreshold:    3.0,
+		MinSamples:          5,
+		StableChange:        0.05,
+		SeasonPeriod:        24 * time.Hour,
+		SeasonSlots:         24,
+		MinSeasonalSamples:  3,
+		MaxAnomalies:        20,
+	}
+}
+
+// AnomalyPoint is a sample that deviated from its baseline
+type AnomalyPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Expected  float64   `json:"expected"`
+	ZScore    float64   `json:"z_score"`
+}
+
+// MetricTrend describes how one metric moved over the analysis window and how
+// unusual its latest value is
+type MetricTrend struct {
+	Metric  string  `json:"metric"`
+	Samples int     `json:"samples"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Change  float64 `json:"change"`
+
+	MovingAverage float64 `json:"moving_average"`
+	EWMA          float64 `json:"ewma"`
+	EWMStdDev     float64 `json:"ewm_stddev"`
+	SlopePerHour  float64 `json:"slope_per_hour"`
+	Direction     string  `json:"direction"`
+
+	// Scoring of the latest sample
+	Baseline       float64 `json:"baseline"`
+	BaselineSource string  `json:"baseline_source"`
+	ZScore         float64 `json:"z_score"`
+	Anomaly        bool    `json:"anomaly"`
+
+	Anomalies []AnomalyPoint `json:"anomalies,omitempty"`
+}
+
+// trendPoint is one sample of a metric
+type trendPoint struct {
+	at    time.Time
+	value float64
+}
+
+// snapshotMetric extracts a trend metric from a snapshot
+func snapshotMetric(snapshot *MonitoringSnapshot, metric string) (float64, bool) {
+	switch metric {
+	case TrendMetricLatencyP95:
+		return snapshot.LatencyP95, true
+	case TrendMetricLatencyP99:
+		return snapshot.LatencyP99, true
+	case TrendMetricErrorRate:
+		return snapshot.ErrorRate, true
+	case TrendMetricThroughput:
+		return snapshot.RequestsPerSecond, true
+	case TrendMetricCacheHitRatio:
+		return snapshot.CacheHitRatio, true
+	}
+	return 0, false
+}
+
+// seriesOf extracts metric from snapshots, oldest first
+func seriesOf(snapshots []MonitoringSnapshot, metric string) []trendPoint {
+	points := make([]trendPoint, 0, len(snapshots))
+	for i := range snapshots {
+		if value, ok := snapshotMetric(&snapshots[i], metric); ok {
+			points = append(points, trendPoint{snapshots[i].Timestamp, value})
+		}
+	}
+	return points
+}
+
+// AnalyzeSeries computes the trend of window, the most recent samples of a
+// metric. history holds every retained sample, including window, and supplies
+// the seasonal baseline
+func AnalyzeSeries(metric string, window, history []trendPoint, config TrendConfig) *MetricTrend {
+	trend := &MetricTrend{
+		Metric:         metric,
+		Samples:        len(window),
+		Direction:      TrendDirectionStable,
+		BaselineSource: BaselineEWMA,
+	}
+	if len(window) == 0 {
+		return trend
+	}
+
+	first, last := window[0], window[len(window)-1]
+	trend.Start = first.value
+	trend.End = last.value
+	trend.Change = last.value - first.value
+
+	// Simple moving average of the newest samples
+	n := config.MovingAverageWindow
+	if n <= 0 || n > len(window) {
+		n = len(window)
+	}
+	var sum float64
+	for _, p := range window[len(window)-n:] {
+		sum += p.value
+	}
+	trend.MovingAverage = sum / float64(n)
+
+	// EWMA z-score: score each sample against the mean and variance of the
+	// samples before it, then fold it in
+	var mean, variance float64
+	var z float64
+	for i, p := range window {
+		if i == 0 {
+			mean = p.value
+			continue
+		}
+
+		z = 0
+		if i >= config.MinSamples {
+			z = (p.value - mean) / stddevFloor(math.Sqrt(variance), mean)
+			if math.Abs(z) > config.AnomalyThreshold {
+				trend.Anomalies = append(trend.Anomalies, AnomalyPoint{
+					Timestamp: p.at, Value: p.value, Expected: mean, ZScore: z,
+				})
+			}
+		}
+		if i == len(window)-1 {
+			trend.Baseline = mean
+			trend.ZScore = z
+		}
+
+		diff := p.value - mean
+		increment := config.EWMAAlpha * diff
+		mean += increment
+		variance = (1 - config.EWMAAlpha) * (variance + diff*increment)
+	}
+	trend.EWMA = mean
+	trend.EWMStdDev = math.Sqrt(variance)
+	if config.MaxAnomalies > 0 && len(trend.Anomalies) > config.MaxAnomalies {
+		trend.Anomalies = trend.Anomalies[len(trend.Anomalies)-config.MaxAnomalies:]
+	}
+
+	// Prefer the seasonal baseline for the latest sample when history has it
+	if baseline, stddev, ok := seasonalBaseline(last, history, config); ok {
+		trend.BaselineSource = BaselineSeasonal
+		trend.Baseline = baseline
+		trend.ZScore = (last.value - baseline) / stddevFloor(stddev, baseline)
+	}
+	trend.Anomaly = math.Abs(trend.ZScore) > config.AnomalyThreshold
+
+	// Least-squares slope decides the direction
+	trend.SlopePerHour = slopePerHour(window)
+	span := last.at.Sub(first.at).Hours()
+	if scale := math.Abs(trend.MovingAverage); scale > 0 && span > 0 {
+		if relative := trend.SlopePerHour * span / scale; relative > config.StableChange {
+			trend.Direction = TrendDirectionRising
+		} else if relative < -config.StableChange {
+			trend.Direction = TrendDirectionFalling
+		}
+	}
+
+	return trend
+}
+
+// seasonalBaseline returns the mean and standard deviation of samples in the
+// same season slot as point from earlier periods
+func seasonalBaseline(point trendPoint, history []trendPoint, config TrendConfig) (float64, float64, bool) {
+	if config.SeasonPeriod <= 0 || config.SeasonSlots <= 0 {
+		return 0, 0, false
+	}
+
+	slotWidth := config.SeasonPeriod / time.Duration(config.SeasonSlots)
+	slot := seasonSlot(point.at, config.SeasonPeriod, slotWidth)
+	cutoff := point.at.Add(-config.SeasonPeriod + slotWidth)
+
+	var values []float64
+	for _, p := range history {
+		if p.at.Before(cutoff) && seasonSlot(p.at, config.SeasonPeriod, slotWidth) == slot {
+			values = append(values, p.value)
+		}
+	}
+	if len(values) < config.MinSeasonalSamples || len(values) == 0 {
+		return 0, 0, false
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values))), true
+}
+
+// stddevFloor keeps a perfectly flat history from making every later change
+// infinitely anomalous: deviations are measured against at least 1% of the
+// mean, or 0.001 for metrics that sit at zero such as error rate
+func stddevFloor(stddev, mean float64) float64 {
+	return math.Max(stddev, math.Max(math.Abs(mean)*0.01, 1e-3))
+}
+
+// seasonSlot returns which slot of the period t falls in
+func seasonSlot(t time.Time, period, slotWidth time.Duration) int {
+	return int(t.Sub(t.Truncate(period)) / slotWidth)
+}
+
+// slopePerHour fits a least-squares line through points
+func slopePerHour(points []trendPoint) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+
+	origin := points[0].at
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := p.at.Sub(origin).Hours()
+		sumX += x
+		sumY += p.value
+		sumXY += x * p.value
+		sumXX += x * x
+	}
+
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
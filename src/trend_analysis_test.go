@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// steadySeries returns n points a minute apart alternating around value
+func steadySeries(start time.Time, n int, value float64) []trendPoint {
+	points := make([]trendPoint, n)
+	for i := range points {
+		jitter := float64(i%3) - 1
+		points[i] = trendPoint{start.Add(time.Duration(i) * time.Minute), value + jitter}
+	}
+	return points
+}
+
+// TestAnalyzeSeriesAnomaly tests that a spike scores far from the EWMA baseline
+// while normal jitter does not
+func TestAnalyzeSeriesAnomaly(t *testing.T) {
+	config := DefaultTrendConfig()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steady := steadySeries(start, 30, 100)
+	trend := AnalyzeSeries(TrendMetricLatencyP95, steady, steady, config)
+	if trend.Anomaly || len(trend.Anomalies) != 0 {
+		t.Errorf("Jitter should not be anomalous: z=%.2f, %d anomalies", trend.ZScore, len(trend.Anomalies))
+	}
+	if trend.Direction != TrendDirectionStable || trend.BaselineSource != BaselineEWMA {
+		t.Errorf("Expected a stable EWMA trend, got %s from %s", trend.Direction, trend.BaselineSource)
+	}
+	if trend.MovingAverage < 99 || trend.MovingAverage > 101 {
+		t.Errorf("Unexpected moving average %.2f", trend.MovingAverage)
+	}
+
+	spiked := append(steadySeries(start, 30, 100), trendPoint{start.Add(30 * time.Minute), 400})
+	trend = AnalyzeSeries(TrendMetricLatencyP95, spiked, spiked, config)
+	if !trend.Anomaly || trend.ZScore < config.AnomalyThreshold {
+		t.Errorf("Expected the spike to be anomalous, got z=%.2f", trend.ZScore)
+	}
+	if len(trend.Anomalies) != 1 || trend.Anomalies[0].Value != 400 {
+		t.Errorf("Expected one recorded anomaly, got %+v", trend.Anomalies)
+	}
+
+	// A flat error rate of zero still flags a jump
+	var errors []trendPoint
+	for i := 0; i < 10; i++ {
+		errors = append(errors, trendPoint{start.Add(time.Duration(i) * time.Minute), 0})
+	}
+	errors = append(errors, trendPoint{start.Add(10 * time.Minute), 0.2})
+	if trend := AnalyzeSeries(TrendMetricErrorRate, errors, errors, config); !trend.Anomaly {
+		t.Errorf("Expected an error rate jump from zero to be anomalous, got z=%.2f", trend.ZScore)
+	}
+
+	var rising []trendPoint
+	for i := 0; i < 20; i++ {
+		rising = append(rising, trendPoint{start.Add(time.Duration(i) * time.Minute), 100 + float64(i)*5})
+	}
+	if trend := AnalyzeSeries(TrendMetricLatencyP95, rising, rising, config); trend.Direction != TrendDirectionRising || trend.SlopePerHour <= 0 {
+		t.Errorf("Expected a rising trend, got %s (slope %.2f/h)", trend.Direction, trend.SlopePerHour)
+	}
+}
+
+// TestAnalyzeSeriesSeasonal tests that a value normal for its time of day is
+// scored against earlier days rather than the recent past
+func TestAnalyzeSeriesSeasonal(t *testing.T) {
+	config := DefaultTrendConfig()
+	today := time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	// Every morning at 09:00 latency is three times higher
+	var history []trendPoint
+	for day := 3; day >= 1; day-- {
+		history = append(history, trendPoint{today.Add(-time.Duration(day)*24*time.Hour + 9*time.Hour), 300})
+		history = append(history, trendPoint{today.Add(-time.Duration(day)*24*time.Hour + 9*time.Hour + 10*time.Minute), 310})
+	}
+	window := steadySeries(today.Add(8*time.Hour), 59, 100)
+	window = append(window, trendPoint{today.Add(9*time.Hour + 5*time.Minute), 305})
+	history = append(history, window...)
+
+	trend := AnalyzeSeries(TrendMetricLatencyP95, window, history, config)
+	if trend.BaselineSource != BaselineSeasonal {
+		t.Fatalf("Expected a seasonal baseline, got %s", trend.BaselineSource)
+	}
+	if trend.Anomaly || trend.Baseline < 300 || trend.Baseline > 310 {
+		t.Errorf("The morning peak should match its baseline: baseline=%.2f z=%.2f", trend.Baseline, trend.ZScore)
+	}
+	if len(trend.Anomalies) == 0 {
+		t.Error("The EWMA scan should still record the jump against the recent past")
+	}
+}
+
+// TestAnomalyAlertRule tests that anomaly rules fire from the collector's trends
+func TestAnomalyAlertRule(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	now := time.Now()
+	for i := 0; i < 30; i++ {
+		collector.snapshots = append(collector.snapshots, MonitoringSnapshot{
+			Timestamp:  now.Add(time.Duration(i-30) * time.Minute),
+			LatencyP95: 100 + float64(i%3),
+		})
+	}
+	collector.snapshots = append(collector.snapshots, MonitoringSnapshot{Timestamp: now, LatencyP95: 500})
+
+	manager := NewAlertManager([]AlertRule{{
+		Name:      "latency_anomaly",
+		Type:      AlertTypeAnomaly,
+		Metric:    TrendMetricLatencyP95,
+		Threshold: 3,
+		Severity:  AlertSeverityWarning,
+	}})
+	manager.AttachCollector(collector)
+	manager.CheckAlerts()
+
+	alerts := manager.GetActiveAlerts()
+	if len(alerts) != 1 || alerts[0].Value < 3 {
+		t.Fatalf("Expected one anomaly alert, got %+v", alerts)
+	}
+
+	analysis := collector.GetTrendAnalysis(time.Hour)
+	if anomalous, ok := analysis["anomalous_metrics"].([]string); !ok || len(anomalous) != 1 || anomalous[0] != TrendMetricLatencyP95 {
+		t.Errorf("Expected latency_p95 to be reported anomalous, got %v", analysis["anomalous_metrics"])
+	}
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -22,6 +23,86 @@ type BenchmarkConfig struct {
 	CustomHeaders     map[string]string `yaml:"custom_headers"`
 	Method            string            `yaml:"method"`
 	Body              []byte            `yaml:"body"`
+
+	// Optional outbound limits to stay under provider rate limits
+	RateLimit *RateLimiterConfig `yaml:"rate_limit"`
+
+	// Optional request rate to hold; paces requests when RateLimit is unset,
+	// and is checked against Concurrency with Little's Law
+	TargetRPS float64 `yaml:"target_rps"`
+
+	// Optional generated LLM request bodies, replacing Body
+	Workload *WorkloadConfig `yaml:"workload"`
+
+	// Optional JavaScript computing each request's method, URL, headers,
+	// body and cache key; cannot be combined with Workload
+	Script *RequestScriptConfig `yaml:"script"`
+
+	// Optional data file whose rows are substituted into the URL, headers
+	// and body, and passed to Script
+	Data *DataFeedConfig `yaml:"data"`
+
+	// Optional seed for all of the above's randomness, standing in for
+	// their own seeds when those are zero; see IterationSeed
+	Seed int64 `yaml:"seed"`
+
+	// Optional CA bundle and client certificates for mutual TLS
+	TLS *ClientTLSConfig `yaml:"tls"`
+
+	// Optional credentials fetched and refreshed per request
+	Auth *AuthConfig `yaml:"auth"`
+
+	// Transport: dial a Unix domain socket instead of TCP (TargetURL may also
+	// be unix://SOCKET[:PATH]), and cleartext HTTP/2 as H2CPriorKnowledge or
+	// H2CUpgrade
+	UnixSocket string `yaml:"unix_socket"`
+	H2C        string `yaml:"h2c"`
+
+	// Speak only this protocol (ProtocolH1, ProtocolH2, ProtocolH2C or
+	// ProtocolH3) instead of offering HTTP/2 and falling back to HTTP/1.1
+	ForceProtocol string `yaml:"force_protocol"`
+
+	// Response headers recorded per request, e.g. X-Request-Id or
+	// CF-Cache-Status; Server-Timing is always parsed
+	CaptureHeaders []string `yaml:"capture_headers"`
+
+	// Optional Host header and TLS server name sent instead of the target's
+	HostOverride *HostOverride `yaml:"host_override"`
+
+	// Optional maximum connection age and requests per connection, forcing
+	// periodic reconnects
+	ConnectionRotation *ConnectionRotation `yaml:"connection_rotation"`
+
+	// Idle connection pool; zero keeps up to Concurrency idle connections
+	// per host for 90 seconds
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+
+	// Resolve the target and fill the connection pool, TLS handshakes
+	// included, before measuring; leave off to measure cold starts
+	PrimeConnections bool `yaml:"prime_connections"`
+
+	// Open a new connection for every request, with an uncached DNS lookup
+	// and a full TLS handshake, to measure the worst case; see applyColdPath
+	ColdPath bool `yaml:"cold_path"`
+
+	// How redirects are followed; nil follows up to DefaultMaxRedirects
+	Redirects *RedirectPolicy `yaml:"redirects"`
+
+	// Honor Retry-After on 429s, pausing and retrying instead of counting
+	// them as errors; nil records throttled responses like any other
+	Throttle *ThrottlePolicy `yaml:"throttle"`
+
+	// Optional dollar budget for paid LLM APIs, aborting or switching to a
+	// mock before it could be exceeded
+	SpendGuard *SpendGuardConfig `yaml:"spend_guard"`
+
+	// Optional self-protection when the load generator saturates
+	Guardrails *GuardrailConfig `yaml:"guardrails"`
+
+	// Go plugins supplying custom response checks and metrics; see package
+	// checks
+	Plugins []string `yaml:"plugins"`
 }
 
 // BenchmarkRunConfig holds runtime configuration for a benchmark run
@@ -163,8 +244,10 @@ type ThroughputStats struct {
 
 // HTTP2Client is a functional HTTP/2 client wrapper
 type HTTP2Client struct {
-	config *HTTP2ClientConfig
-	client *http.Client
+	config      *HTTP2ClientConfig
+	client      *http.Client
+	connections *ConnectionTracker
+	events      *HTTP2EventTracker
 	// functionalClient *FunctionalHTTP2Client // DISABLED - functional implementation not used
 }
 
@@ -174,7 +257,17 @@ type HTTP2ClientConfig struct {
 	IdleConnTimeout       time.Duration
 	TLSHandshakeTimeout   time.Duration
 	DisableCompression    bool
-	EnableHTTP2Push       bool
+	EnableHTTP2Push       bool             // Deprecated: no effect, Go's client refuses server push; see EarlyHintsStats
+	TLS                   *ClientTLSConfig // Optional CA bundle and client certificates
+	UnixSocket            string           // Dial every connection to this Unix domain socket
+	H2C                   string           // Cleartext HTTP/2 mode: H2CPriorKnowledge or H2CUpgrade
+
+	// Times a request is sent again after a GOAWAY, stream reset or lost
+	// connection, when that is safe; zero means once, negative never
+	MaxStreamRetries int
+
+	// Optional maximum connection age and requests per connection
+	Rotation *ConnectionRotation
 }
 
 // HTTP2RequestTiming contains HTTP/2 request timing
@@ -184,25 +277,97 @@ type HTTP2RequestTiming struct {
 	TLSLatency        time.Duration
 	TTFBLatency       time.Duration
 	ProcessingLatency time.Duration
+	QueueLatency      time.Duration // Waiting for a pooled connection
 	ConnectionReused  bool
 }
 
 // NewHTTP2Client creates a new HTTP/2 client
 func NewHTTP2Client(config *HTTP2ClientConfig) (*HTTP2Client, error) {
-	// Create a basic HTTP/2 client (functional implementation disabled)
+	if config == nil {
+		config = &HTTP2ClientConfig{}
+	}
+
+	// Start from the default transport so proxy settings and dial timeouts
+	// carry over, then override only what the config sets
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = config.MaxConnectionsPerHost
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	transport.DisableCompression = config.DisableCompression
+	transport.ForceAttemptHTTP2 = true
+
+	if config.UnixSocket != "" {
+		transport.DialContext = dialUnixSocket(config.UnixSocket)
+	}
+	if config.TLS != nil {
+		tlsConfig, err := NewClientTLSConfig(config.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS config: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	// Track dials and reuse so pool metrics reflect real connections
+	connections := NewConnectionTracker()
+	connections.SetRotation(config.Rotation)
+	roundTripper, err := newH2CRoundTripper(transport, config.H2C, connections.Wrap)
+	if err != nil {
+		return nil, err
+	}
+	if config.TLS != nil {
+		roundTripper = WithClientCertHost(roundTripper)
+	}
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: roundTripper,
+		Timeout:   30 * time.Second,
 	}
 
 	return &HTTP2Client{
-		config: config,
-		client: client,
+		config:      config,
+		client:      client,
+		connections: connections,
+		events:      NewHTTP2EventTracker(),
 	}, nil
 }
 
-// Do executes an HTTP request
+// ConnectionTracker returns the tracker observing this client's pool
+func (c *HTTP2Client) ConnectionTracker() *ConnectionTracker {
+	return c.connections
+}
+
+// HTTP2Events returns the GOAWAYs, stream resets and lost connections seen
+// so far, or nil if there were none
+func (c *HTTP2Client) HTTP2Events() *HTTP2EventStats {
+	return c.events.Stats()
+}
+
+// Do executes an HTTP request, sending it again after a GOAWAY, stream reset
+// or lost connection when canRetryHTTP2 allows
 func (c *HTTP2Client) Do(req *http.Request) (*http.Response, error) {
-	return c.client.Do(req)
+	retries := c.config.MaxStreamRetries
+	if retries == 0 {
+		retries = 1
+	}
+
+	resp, err := c.client.Do(req)
+	for attempt := 0; err != nil; attempt++ {
+		event, ok := c.events.Record(req.URL.Host, err)
+		if !ok || attempt >= retries || !canRetryHTTP2(req, event) {
+			break
+		}
+		retry, rewindErr := rewindRequest(req)
+		if rewindErr != nil {
+			break
+		}
+		req = retry
+		resp, err = c.client.Do(req)
+		c.events.RecordRetry(err == nil)
+	}
+	return resp, err
 }
 
 // GetLastRequestTiming returns timing for the last request (stub implementation)
@@ -225,10 +390,13 @@ func (c *HTTP2Client) Close() error {
 
 // Cache is a functional cache implementation with LRU and TTL
 type Cache struct {
-	config *CacheConfig
-	// functionalCache *FunctionalCache // DISABLED - functional implementation not used
+	config  *CacheConfig
+	entries *LRUCache
 }
 
+// cacheMemoryMB bounds the memory of the optimized client's cache
+const cacheMemoryMB = 100
+
 // CacheConfig holds cache configuration
 type CacheConfig struct {
 	Capacity   int
@@ -239,34 +407,57 @@ type CacheConfig struct {
 // WarmupConfig holds cache warmup configuration
 type WarmupConfig struct {
 	Enabled          bool     `yaml:"enabled" json:"enabled"`
-	Strategy         string   `yaml:"strategy" json:"strategy"` // "static", "predictive", "time_based", "adaptive"
+	Strategy         string   `yaml:"strategy" json:"strategy"` // "static", "predictive", "time_based", "adaptive", "historical"
 	Interval         string   `yaml:"interval" json:"interval"`
 	StaticURLs       []string `yaml:"static_urls" json:"static_urls"`
 	PredictionWindow string   `yaml:"prediction_window" json:"prediction_window"`
 	TopN             int      `yaml:"top_n" json:"top_n"`
+	AnalyticsURL     string   `yaml:"analytics_url" json:"analytics_url"` // Daemon address for "historical"
+	Concurrency      int      `yaml:"concurrency" json:"concurrency"`     // Max parallel prefetches
+	DryRun           bool     `yaml:"dry_run" json:"dry_run"`             // Plan warmup without fetching
 }
 
-// NewCache creates a new cache (stub implementation)
+// NewCache creates an LRU cache holding up to config.Capacity values
 func NewCache(config *CacheConfig) *Cache {
 	return &Cache{
-		config: config,
+		config:  config,
+		entries: NewLRUCache(config.Capacity, cacheMemoryMB),
 	}
 }
 
-// GetWithAge retrieves an item from cache with age (stub implementation)
+// GetWithAge returns a live value and how long ago it was stored
 func (c *Cache) GetWithAge(key string) (interface{}, time.Duration, bool) {
-	// Stub implementation - functional cache disabled
-	return nil, 0, false
+	entry, found := c.entries.Get(key)
+	if !found {
+		return nil, 0, false
+	}
+	return entry.Value, entry.Age(), true
 }
 
-// SetWithTTL sets an item in cache with TTL (stub implementation)
+// SetWithTTL stores value, an encoded cache entry, for ttl
 func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
-	// Stub implementation - functional cache disabled
-}
-
-// Delete removes an item from cache (stub implementation)
+	data, ok := value.([]byte)
+	if !ok {
+		return
+	}
+	if ttl <= 0 {
+		ttl = c.config.DefaultTTL
+	}
+	now := time.Now()
+	c.entries.Put(key, &CacheEntry{
+		Key:          key,
+		Value:        data,
+		Size:         int64(len(data)),
+		CreatedAt:    now,
+		LastAccessed: now,
+		TTL:          ttl,
+		ExpiresAt:    now.Add(ttl),
+	})
+}
+
+// Delete removes an item from cache
 func (c *Cache) Delete(key string) {
-	// Stub implementation - functional cache disabled
+	c.entries.Delete(key)
 }
 
 // InitializeWarmup initializes cache warmup
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verdicts of an UpstreamAttribution
+const (
+	AttributionUpstream = "upstream" // The API itself accounts for most of the wait
+	AttributionNetwork  = "network"  // The path to the API accounts for most of it
+	AttributionMixed    = "mixed"
+)
+
+// Upstream shares of the time to first byte beyond which one side is blamed
+const (
+	upstreamDominantShare = 0.6
+	networkDominantShare  = 0.4
+)
+
+// UpstreamAttribution splits the time to first byte of requests that sent
+// Server-Timing into what the upstream reported spending and the rest: the
+// network path, queueing and connection setup
+type UpstreamAttribution struct {
+	Requests      int          `json:"requests"`
+	Upstream      LatencyStats `json:"upstream"`
+	Network       LatencyStats `json:"network"`
+	UpstreamShare float64      `json:"upstream_share"` // Of the median time to first byte
+	Verdict       string       `json:"verdict"`        // AttributionUpstream, AttributionNetwork or AttributionMixed
+}
+
+// serverTimingTotal returns the upstream time a response reported: its
+// "total" metric if any, otherwise the sum of its durations
+func serverTimingTotal(timings []ServerTimingMetric) (float64, bool) {
+	var sum float64
+	for _, timing := range timings {
+		if strings.EqualFold(timing.Name, "total") && timing.DurationMs > 0 {
+			return timing.DurationMs, true
+		}
+		sum += timing.DurationMs
+	}
+	return sum, sum > 0
+}
+
+// calculateUpstreamAttribution attributes the time to first byte of
+// successful requests with Server-Timing durations, or returns nil if none
+// reported any
+func calculateUpstreamAttribution(metrics []LatencyMetrics) *UpstreamAttribution {
+	var upstream, network []float64
+	for _, m := range metrics {
+		if m.Error != "" || m.TimeToFirstByte <= 0 {
+			continue
+		}
+		total, ok := serverTimingTotal(m.ServerTiming)
+		if !ok {
+			continue
+		}
+		ttfb := float64(m.TimeToFirstByte.Microseconds()) / 1000.0
+		upstream = append(upstream, total)
+		network = append(network, max(ttfb-total, 0))
+	}
+	if len(upstream) == 0 {
+		return nil
+	}
+
+	attribution := &UpstreamAttribution{
+		Requests: len(upstream),
+		Upstream: CalculateStats(upstream),
+		Network:  CalculateStats(network),
+	}
+	if whole := attribution.Upstream.P50 + attribution.Network.P50; whole > 0 {
+		attribution.UpstreamShare = attribution.Upstream.P50 / whole
+	}
+	switch {
+	case attribution.UpstreamShare >= upstreamDominantShare:
+		attribution.Verdict = AttributionUpstream
+	case attribution.UpstreamShare <= networkDominantShare:
+		attribution.Verdict = AttributionNetwork
+	default:
+		attribution.Verdict = AttributionMixed
+	}
+	return attribution
+}
+
+// describe summarizes the attribution in one sentence
+func (a *UpstreamAttribution) describe() string {
+	var blame string
+	switch a.Verdict {
+	case AttributionUpstream:
+		blame = "the API is slow"
+	case AttributionNetwork:
+		blame = "the network path is slow"
+	default:
+		blame = "both the API and the network path contribute"
+	}
+	return fmt.Sprintf("Upstream reported %.0f%% of the median time to first byte (%.2f ms upstream, %.2f ms network): %s",
+		a.UpstreamShare*100, a.Upstream.P50, a.Network.P50, blame)
+}
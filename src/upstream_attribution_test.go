@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestServerTimingTotal tests that a total metric wins over the sum of phases
+func TestServerTimingTotal(t *testing.T) {
+	tests := []struct {
+		name     string
+		timings  []ServerTimingMetric
+		expected float64
+		ok       bool
+	}{
+		{"none", nil, 0, false},
+		{"no durations", []ServerTimingMetric{{Name: "miss"}}, 0, false},
+		{"sum of phases", []ServerTimingMetric{{Name: "db", DurationMs: 30}, {Name: "app", DurationMs: 12.5}}, 42.5, true},
+		{"total", []ServerTimingMetric{{Name: "db", DurationMs: 30}, {Name: "Total", DurationMs: 35}}, 35, true},
+	}
+
+	for _, tt := range tests {
+		total, ok := serverTimingTotal(tt.timings)
+		if total != tt.expected || ok != tt.ok {
+			t.Errorf("%s: Expected %.1f (%v), got %.1f (%v)", tt.name, tt.expected, tt.ok, total, ok)
+		}
+	}
+}
+
+// TestCalculateUpstreamAttribution tests the verdict from the upstream share of TTFB
+func TestCalculateUpstreamAttribution(t *testing.T) {
+	request := func(ttfb time.Duration, upstreamMs float64) LatencyMetrics {
+		return LatencyMetrics{
+			TimeToFirstByte: ttfb,
+			ServerTiming:    []ServerTimingMetric{{Name: "app", DurationMs: upstreamMs}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		metrics []LatencyMetrics
+		verdict string
+		share   float64
+	}{
+		{"slow api", []LatencyMetrics{request(100*time.Millisecond, 90), request(100*time.Millisecond, 80)}, AttributionUpstream, 0.85},
+		{"slow network", []LatencyMetrics{request(100*time.Millisecond, 10), request(100*time.Millisecond, 20)}, AttributionNetwork, 0.15},
+		{"mixed", []LatencyMetrics{request(100*time.Millisecond, 50)}, AttributionMixed, 0.5},
+		{"upstream over ttfb", []LatencyMetrics{request(10*time.Millisecond, 20)}, AttributionUpstream, 1},
+	}
+
+	for _, tt := range tests {
+		attribution := calculateUpstreamAttribution(tt.metrics)
+		if attribution == nil {
+			t.Fatalf("%s: Expected an attribution", tt.name)
+		}
+		if attribution.Verdict != tt.verdict {
+			t.Errorf("%s: Expected verdict %s, got %s", tt.name, tt.verdict, attribution.Verdict)
+		}
+		if diff := attribution.UpstreamShare - tt.share; diff > 0.001 || diff < -0.001 {
+			t.Errorf("%s: Expected upstream share %.2f, got %.2f", tt.name, tt.share, attribution.UpstreamShare)
+		}
+	}
+
+	failed := request(100*time.Millisecond, 90)
+	failed.Error = "request failed"
+	if attribution := calculateUpstreamAttribution([]LatencyMetrics{failed, {TimeToFirstByte: time.Millisecond}}); attribution != nil {
+		t.Errorf("Expected no attribution without successful Server-Timing, got %+v", attribution)
+	}
+}
+
+// TestMetricsCollectorUpstream tests that snapshots carry the attribution and phases
+func TestMetricsCollectorUpstream(t *testing.T) {
+	collector := NewMetricsCollector(10)
+	collector.UpdateBenchmarkResult(&BenchmarkResult{
+		Upstream:     &UpstreamAttribution{Upstream: LatencyStats{P50: 40, P95: 60}, Network: LatencyStats{P50: 10, P95: 15}, UpstreamShare: 0.8, Verdict: AttributionUpstream},
+		ServerTiming: []ServerTimingStats{{Name: "db", Duration: LatencyStats{P50: 30, P95: 45}}},
+	})
+	collector.Collect()
+
+	snapshot := collector.GetSnapshot()
+	if snapshot.UpstreamP50 != 40 || snapshot.NetworkP95 != 15 || snapshot.UpstreamVerdict != AttributionUpstream {
+		t.Errorf("Unexpected upstream attribution in snapshot: %+v", snapshot)
+	}
+	if len(snapshot.ServerTiming) != 1 || snapshot.ServerTiming[0].Name != "db" || snapshot.ServerTiming[0].P95 != 45 {
+		t.Errorf("Expected the db phase in the snapshot, got %+v", snapshot.ServerTiming)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultVaryHeaders are the request headers responses may vary on and still
+// be cached. Authorization is left out so per-user responses are not cached
+// unless explicitly allowed
+var DefaultVaryHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// maxVaryResources bounds the resources a VaryIndex remembers. Forgetting one
+// only costs a miss, since its variants are never stored under the base key
+const maxVaryResources = 10000
+
+// VaryIndex remembers which request headers each cached resource varies on,
+// so a lookup can find the variant matching the request before any response
+// is at hand. Only headers in the allowed list may participate; a response
+// varying on anything else, or on "*", is not cached
+type VaryIndex struct {
+	allowed   map[string]bool
+	resources map[string][]string // Base key to sorted canonical header names
+	mu        sync.RWMutex
+}
+
+// NewVaryIndex creates an index allowing responses to vary on headers
+func NewVaryIndex(headers ...string) *VaryIndex {
+	allowed := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		allowed[textproto.CanonicalMIMEHeaderKey(header)] = true
+	}
+	return &VaryIndex{
+		allowed:   allowed,
+		resources: make(map[string][]string),
+	}
+}
+
+// Key returns the key to look req up under, given its base key
+func (v *VaryIndex) Key(base string, req *http.Request) string {
+	v.mu.RLock()
+	vary := v.resources[base]
+	v.mu.RUnlock()
+	return VaryKey(base, req, vary)
+}
+
+// Learn records the headers resp varies on and returns the key to store it
+// under for req, or false if it must not be cached
+func (v *VaryIndex) Learn(base string, req *http.Request, resp *http.Response) (string, bool) {
+	vary, ok := v.parse(resp.Header.Values("Vary"))
+	if !ok {
+		return "", false
+	}
+
+	v.mu.Lock()
+	if len(vary) == 0 {
+		delete(v.resources, base)
+	} else {
+		if _, known := v.resources[base]; !known && len(v.resources) >= maxVaryResources {
+			for resource := range v.resources {
+				delete(v.resources, resource)
+				break
+			}
+		}
+		v.resources[base] = vary
+	}
+	v.mu.Unlock()
+
+	return VaryKey(base, req, vary), true
+}
+
+// parse returns the sorted canonical names in Vary header values, or false
+// if one of them is "*" or not allowed
+func (v *VaryIndex) parse(values []string) ([]string, bool) {
+	var vary []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			name = textproto.CanonicalMIMEHeaderKey(name)
+			if !v.allowed[name] {
+				return nil, false
+			}
+			vary = append(vary, name)
+		}
+	}
+	slices.Sort(vary)
+	return slices.Compact(vary), true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// varyRequest returns a GET for one resource with the given headers
+func varyRequest(headers map[string]string) *http.Request {
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/items", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+// varyResponse returns a response with the given Vary values
+func varyResponse(vary ...string) *http.Response {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	for _, value := range vary {
+		resp.Header.Add("Vary", value)
+	}
+	return resp
+}
+
+// TestVaryIndexKeys tests that variants get their own keys and lookups find
+// the variant matching the request
+func TestVaryIndexKeys(t *testing.T) {
+	index := NewVaryIndex(DefaultVaryHeaders...)
+	gzip := varyRequest(map[string]string{"Accept-Encoding": "gzip, br", "User-Agent": "a"})
+	base := generateCacheKey(gzip)
+
+	if key := index.Key(base, gzip); key != base {
+		t.Errorf("Expected the base key before any response, got %q", key)
+	}
+
+	stored, ok := index.Learn(base, gzip, varyResponse("accept-encoding"))
+	if !ok || stored == base {
+		t.Fatalf("Expected a variant key, got %q, %v", stored, ok)
+	}
+	if key := index.Key(base, gzip); key != stored {
+		t.Errorf("Expected the lookup to find the stored variant %q, got %q", stored, key)
+	}
+
+	for name, headers := range map[string]map[string]string{
+		"spacing":         {"Accept-Encoding": "gzip,br"},
+		"unvaried header": {"Accept-Encoding": "gzip, br", "User-Agent": "b"},
+	} {
+		if key := index.Key(base, varyRequest(headers)); key != stored {
+			t.Errorf("%s: expected the same variant, got %q", name, key)
+		}
+	}
+	for name, headers := range map[string]map[string]string{
+		"other encoding": {"Accept-Encoding": "identity"},
+		"no encoding":    {},
+	} {
+		if key := index.Key(base, varyRequest(headers)); key == stored || key == base {
+			t.Errorf("%s: expected a different variant, got %q", name, key)
+		}
+	}
+
+	// A response that stops varying is cached under the base key again
+	if key, ok := index.Learn(base, gzip, varyResponse()); !ok || key != base {
+		t.Errorf("Expected the base key without Vary, got %q, %v", key, ok)
+	}
+	if key := index.Key(base, gzip); key != base {
+		t.Errorf("Expected the base key after Vary was dropped, got %q", key)
+	}
+}
+
+// TestVaryIndexOrder tests that the order and repetition of Vary names does
+// not change the key
+func TestVaryIndexOrder(t *testing.T) {
+	req := varyRequest(map[string]string{"Accept": "application/json", "Accept-Language": "en"})
+	base := generateCacheKey(req)
+
+	first, _ := NewVaryIndex(DefaultVaryHeaders...).Learn(base, req, varyResponse("Accept, Accept-Language"))
+	second, _ := NewVaryIndex(DefaultVaryHeaders...).Learn(base, req, varyResponse("accept-language", "Accept, Accept"))
+	if first != second {
+		t.Errorf("Expected equal keys, got %q and %q", first, second)
+	}
+}
+
+// TestVaryIndexUncacheable tests that responses varying on headers outside
+// the allowed list are not cached
+func TestVaryIndexUncacheable(t *testing.T) {
+	req := varyRequest(map[string]string{"Authorization": "Bearer alice"})
+	base := generateCacheKey(req)
+
+	index := NewVaryIndex(DefaultVaryHeaders...)
+	for _, vary := range []string{"*", "Authorization", "Accept-Encoding, Cookie"} {
+		if _, ok := index.Learn(base, req, varyResponse(vary)); ok {
+			t.Errorf("Expected Vary: %s to be uncacheable", vary)
+		}
+	}
+
+	// Allowing Authorization keys responses per credential
+	index = NewVaryIndex("Authorization")
+	alice, ok := index.Learn(base, req, varyResponse("Authorization"))
+	if !ok {
+		t.Fatal("Expected Vary: Authorization to be cacheable once allowed")
+	}
+	bob := index.Key(base, varyRequest(map[string]string{"Authorization": "Bearer bob"}))
+	if bob == alice {
+		t.Error("Expected different credentials to get different keys")
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Events a webhook can fire on
+const (
+	WebhookRunComplete = "run_complete"
+	WebhookSLOBreach   = "slo_breach"
+)
+
+// Delivery attempts per webhook call, with a doubling delay between them
+const (
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+)
+
+// WebhookConfig posts a JSON payload to URL when a run completes or breaches
+// the suite's budgets
+type WebhookConfig struct {
+	URL string `json:"url"`
+
+	// Events to fire on; empty means all of them
+	Events []string `json:"events,omitempty"`
+
+	// Go text/template rendering the body from a WebhookPayload, e.g. a
+	// chat message; it must produce JSON. Empty posts the payload itself
+	Template string `json:"template,omitempty"`
+
+	// Signs the body with HMAC-SHA256 in X-Apilo-Signature when set
+	Secret string `json:"secret,omitempty"`
+
+	// Where the result directory is published, e.g. a CI artifact URL, so
+	// payloads can link to result files
+	ResultBaseURL string `json:"result_base_url,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout time.Duration     `json:"timeout,omitempty"`
+
+	tmpl *template.Template
+}
+
+// WebhookPayload is what a webhook reports about a run, and the data its
+// template is executed with
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	Suite     string    `json:"suite"`
+	SuiteID   string    `json:"suite_id"`
+	Run       string    `json:"run"`
+	RunID     string    `json:"run_id"`
+	Target    string    `json:"target"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Iterations int            `json:"iterations"`
+	Metrics    WebhookMetrics `json:"metrics"`
+
+	// Budgets checked against the run, and those it failed
+	Budgets  int             `json:"budgets"`
+	Breaches []WebhookBreach `json:"breaches,omitempty"`
+
+	ResultFile string `json:"result_file"`
+	ResultURL  string `json:"result_url,omitempty"`
+}
+
+// WebhookMetrics are a run's key figures, averaged over its iterations
+type WebhookMetrics struct {
+	RPS       float64 `json:"rps"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	TTFBP95Ms float64 `json:"ttfb_p95_ms"`
+	ErrorRate float64 `json:"error_rate"` // Percentage of requests
+	Requests  int     `json:"requests"`
+	Failed    int     `json:"failed"`
+}
+
+// WebhookBreach is a budget the run failed
+type WebhookBreach struct {
+	Metric      string  `json:"metric"`
+	Limit       float64 `json:"limit"`
+	Actual      float64 `json:"actual"`
+	Description string  `json:"description"` // e.g. "P95 <= 250.00 ms (got 312.40 ms)"
+}
+
+// webhookFuncs are available to webhook templates; json renders a value as
+// a JSON literal, so strings are quoted and escaped
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// ParseWebhooks builds a webhook per comma-separated URL in urls, firing on
+// the comma-separated events and rendering the template in templateFile, if any
+func ParseWebhooks(urls, events, templateFile, secret, resultBaseURL string) ([]WebhookConfig, error) {
+	var webhooks []WebhookConfig
+	var body string
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook template: %w", err)
+		}
+		body = string(data)
+	}
+	for _, target := range splitList(urls) {
+		webhook := WebhookConfig{
+			URL:           target,
+			Events:        splitList(events),
+			Template:      body,
+			Secret:        secret,
+			ResultBaseURL: resultBaseURL,
+		}
+		if err := webhook.validate(); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// validate checks the URL and events and parses the template
+func (w *WebhookConfig) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: expected http(s)://host/path", w.URL)
+	}
+	for _, event := range w.Events {
+		if event != WebhookRunComplete && event != WebhookSLOBreach {
+			return fmt.Errorf("invalid webhook event %q: expected %s or %s", event, WebhookRunComplete, WebhookSLOBreach)
+		}
+	}
+	if w.Template != "" && w.tmpl == nil {
+		tmpl, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(w.Template)
+		if err != nil {
+			return fmt.Errorf("invalid webhook template: %w", err)
+		}
+		w.tmpl = tmpl
+	}
+	return nil
+}
+
+// firesOn reports whether the webhook subscribes to event
+func (w *WebhookConfig) firesOn(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the request body for payload
+func (w *WebhookConfig) render(payload *WebhookPayload) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(payload)
+	}
+	var body bytes.Buffer
+	if err := w.tmpl.Execute(&body, payload); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON: %s", strings.TrimSpace(body.String()))
+	}
+	return body.Bytes(), nil
+}
+
+// deliver posts body, retrying network errors and 5xx or 429 responses
+func (w *WebhookConfig) deliver(body []byte) error {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var lastErr error
+	backoff := webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "api-latency-optimizer/"+Version)
+		for name, value := range w.Headers {
+			req.Header.Set(name, value)
+		}
+		if w.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(w.Secret))
+			mac.Write(body)
+			req.Header.Set("X-Apilo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return lastErr
+}
+
+// webhookPayload describes run, saved to resultFile, for event
+func (r *BenchmarkRunner) webhookPayload(event string, run *BenchmarkRun, resultFile string, budgets []BudgetResult) *WebhookPayload {
+	summary := summarizeRun(run)
+	payload := &WebhookPayload{
+		Event:      event,
+		Suite:      r.suite.Name,
+		SuiteID:    r.suite.ID,
+		Run:        run.Name,
+		RunID:      run.ID,
+		Target:     run.Config.TargetURL,
+		Timestamp:  time.Now(),
+		Iterations: len(run.Results),
+		Metrics: WebhookMetrics{
+			RPS:       summary.RPS,
+			P50Ms:     summary.P50,
+			P95Ms:     summary.P95,
+			P99Ms:     summary.P99,
+			TTFBP95Ms: summary.TTFBP95,
+			ErrorRate: summary.ErrorRate,
+			Requests:  summary.Requests,
+			Failed:    summary.Failed,
+		},
+		Budgets:    len(budgets),
+		ResultFile: resultFile,
+	}
+	for _, budget := range budgets {
+		if !budget.Passed {
+			payload.Breaches = append(payload.Breaches, WebhookBreach{
+				Metric:      budget.Budget.Metric,
+				Limit:       budget.Budget.Limit,
+				Actual:      budget.Actual,
+				Description: fmt.Sprintf("%s (got %s)", budgetLabel(budget.Budget), formatBudgetValue(budget.Budget.Metric, budget.Actual)),
+			})
+		}
+	}
+	return payload
+}
+
+// notifyWebhooks fires the suite's webhooks for a completed run: always
+// run_complete, and slo_breach if it failed any budget. Delivery failures
+// are reported but do not fail the run
+func (r *BenchmarkRunner) notifyWebhooks(run *BenchmarkRun, resultFile string) {
+	if len(r.suite.Webhooks) == 0 {
+		return
+	}
+	budgets := evaluateRunBudgets(r.suite.Budgets, run)
+	events := []string{WebhookRunComplete}
+	for _, budget := range budgets {
+		if !budget.Passed {
+			events = append(events, WebhookSLOBreach)
+			break
+		}
+	}
+
+	for i := range r.suite.Webhooks {
+		webhook := &r.suite.Webhooks[i]
+		if err := webhook.validate(); err != nil {
+			fmt.Printf("WARNING: Skipping webhook: %v\n", err)
+			continue
+		}
+		for _, event := range events {
+			if !webhook.firesOn(event) {
+				continue
+			}
+			payload := r.webhookPayload(event, run, resultFile, budgets)
+			if webhook.ResultBaseURL != "" {
+				payload.ResultURL = strings.TrimRight(webhook.ResultBaseURL, "/") + "/" +
+					path.Join(filepath.Base(r.resultDir), filepath.Base(resultFile))
+			}
+			body, err := webhook.render(payload)
+			if err == nil {
+				err = webhook.deliver(body)
+			}
+			if err != nil {
+				fmt.Printf("WARNING: Webhook %s for %s failed: %v\n", event, webhook.URL, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestWebhooksOnRunComplete tests the payloads fired for a run that breaches
+// its budget, with the default body and with a template
+func TestWebhooksOnRunComplete(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	var mu sync.Mutex
+	bodies := map[string][][]byte{}
+	var signatures []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], body)
+		if signature := r.Header.Get("X-Apilo-Signature"); signature != "" {
+			signatures = append(signatures, signature)
+		}
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	templateFile := filepath.Join(t.TempDir(), "chat.tmpl")
+	os.WriteFile(templateFile, []byte(`{"text": {{json (printf "%s breached %d budget(s): %s" .Run (len .Breaches) (index .Breaches 0).Description)}}}`), 0644)
+	webhooks, err := ParseWebhooks(receiver.URL+"/hook", "", "", "s3cret", "https://ci.example.com/artifacts/")
+	if err != nil {
+		t.Fatalf("Failed to parse webhooks: %v", err)
+	}
+	chat, err := ParseWebhooks(receiver.URL+"/chat", WebhookSLOBreach, templateFile, "", "")
+	if err != nil {
+		t.Fatalf("Failed to parse webhooks: %v", err)
+	}
+
+	suite := &BenchmarkSuite{
+		Name:      "webhook_test",
+		OutputDir: t.TempDir(),
+		Budgets:   []PerformanceBudget{{Metric: BudgetMetricP95, Limit: 0.000001}, {Metric: BudgetMetricErrorRate, Limit: 1}},
+		Webhooks:  append(webhooks, chat...),
+		Runs: []BenchmarkRun{{
+			Name:       "api",
+			Config:     BenchmarkConfig{TargetURL: target.URL, TotalRequests: 5, Concurrency: 1},
+			Iterations: 1,
+		}},
+	}
+	if err := NewBenchmarkRunner(suite).Run(context.Background()); err != nil {
+		t.Fatalf("Suite failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies["/hook"]) != 2 || len(bodies["/chat"]) != 1 {
+		t.Fatalf("Expected 2 default and 1 templated webhook, got %d and %d", len(bodies["/hook"]), len(bodies["/chat"]))
+	}
+
+	var events []string
+	for i, body := range bodies["/hook"] {
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("Invalid payload %s: %v", body, err)
+		}
+		events = append(events, payload.Event)
+		if payload.RunID != suite.Runs[0].ID || payload.Metrics.Requests != 5 || payload.Budgets != 2 || len(payload.Breaches) != 1 {
+			t.Errorf("Unexpected payload: %+v", payload)
+		}
+		if !strings.HasPrefix(payload.ResultURL, "https://ci.example.com/artifacts/webhook_test-") ||
+			!strings.HasSuffix(payload.ResultURL, "/"+suite.Runs[0].ID+".json") || filepath.Base(payload.ResultFile) != suite.Runs[0].ID+".json" {
+			t.Errorf("Unexpected result location %s, %s", payload.ResultFile, payload.ResultURL)
+		}
+
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if signatures[i] != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("Signature %s does not match the body", signatures[i])
+		}
+	}
+	if strings.Join(events, ",") != "run_complete,slo_breach" {
+		t.Errorf("Expected run_complete then slo_breach, got %v", events)
+	}
+
+	var message struct{ Text string }
+	if err := json.Unmarshal(bodies["/chat"][0], &message); err != nil || !strings.HasPrefix(message.Text, "api breached 1 budget(s): P95 <= 0.00 ms (got ") {
+		t.Errorf("Unexpected templated body %s (%v)", bodies["/chat"][0], err)
+	}
+}
+
+// TestParseWebhooksInvalid tests rejected webhook settings
+func TestParseWebhooksInvalid(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "bad.tmpl")
+	os.WriteFile(templateFile, []byte(`{"text": {{.Run}`), 0644)
+
+	tests := []struct {
+		name     string
+		url      string
+		events   string
+		template string
+		want     string
+	}{
+		{"not http", "ftp://example.com/hook", "", "", "invalid webhook URL"},
+		{"unknown event", "https://example.com/hook", "run_started", "", "invalid webhook event"},
+		{"bad template", "https://example.com/hook", "", templateFile, "invalid webhook template"},
+		{"missing template", "https://example.com/hook", "", "/nonexistent/webhook.tmpl", "failed to read webhook template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWebhooks(tt.url, tt.events, tt.template, "", "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestWebhookRenderInvalidJSON tests that a template producing invalid JSON is an error
+func TestWebhookRenderInvalidJSON(t *testing.T) {
+	webhook := WebhookConfig{URL: "https://example.com/hook", Template: `{"text": "{{.Run}}"}`}
+	if err := webhook.validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := webhook.render(&WebhookPayload{Run: `say "hi"`}); err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("Expected an invalid JSON error for an unescaped quote, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+)
+
+// FairnessSkewThreshold is the skew at which a run is flagged: the slowest
+// worker's median latency at 1.5 times that of the median worker
+const FairnessSkewThreshold = 1.5
+
+// minWorkerSamples is the fewest requests a worker needs to be compared
+const minWorkerSamples = 5
+
+// WorkerFairness summarizes how evenly latency was spread across a run's
+// concurrent workers. Workers share one target, so a worker consistently
+// slower than the rest points at the client, e.g. too few GOMAXPROCS or a
+// remote NUMA node, or at a connection pinned to a slower backend
+type WorkerFairness struct {
+	Workers []WorkerLatency `json:"workers"`
+
+	// Jain's fairness index of per-worker mean latency: 1 when every worker
+	// saw the same latency, falling towards 1/n as one dominates
+	Index float64 `json:"fairness_index"`
+
+	// The slowest worker's P50 over the median of the workers' P50s
+	Skew          float64 `json:"skew"`
+	SlowestWorker int     `json:"slowest_worker"`
+	Skewed        bool    `json:"skewed"` // Skew reached FairnessSkewThreshold
+
+	GOMAXPROCS int `json:"gomaxprocs"`
+}
+
+// WorkerLatency is the latency of the successful requests of one worker
+type WorkerLatency struct {
+	Worker   int     `json:"worker"`
+	Requests int     `json:"requests"`
+	Mean     float64 `json:"mean_ms"`
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+}
+
+// calculateWorkerFairness groups successful requests by worker. It returns
+// nil unless at least two workers served minWorkerSamples requests
+func calculateWorkerFairness(metrics []LatencyMetrics) *WorkerFairness {
+	latencies := make(map[int][]float64)
+	for _, m := range metrics {
+		if m.Error != "" {
+			continue
+		}
+		latencies[m.Worker] = append(latencies[m.Worker], float64(m.TotalLatency.Microseconds())/1000.0)
+	}
+
+	fairness := &WorkerFairness{GOMAXPROCS: runtime.GOMAXPROCS(0)}
+	for worker, values := range latencies {
+		if len(values) < minWorkerSamples {
+			continue
+		}
+		stats := CalculateStats(values)
+		fairness.Workers = append(fairness.Workers, WorkerLatency{
+			Worker:   worker,
+			Requests: len(values),
+			Mean:     stats.Mean,
+			P50:      stats.P50,
+			P95:      stats.P95,
+		})
+	}
+	if len(fairness.Workers) < 2 {
+		return nil
+	}
+	sort.Slice(fairness.Workers, func(i, j int) bool {
+		return fairness.Workers[i].Worker < fairness.Workers[j].Worker
+	})
+
+	var sum, sumSquares float64
+	medians := make([]float64, len(fairness.Workers))
+	slowest := fairness.Workers[0]
+	for i, w := range fairness.Workers {
+		sum += w.Mean
+		sumSquares += w.Mean * w.Mean
+		medians[i] = w.P50
+		if w.P50 > slowest.P50 {
+			slowest = w
+		}
+	}
+	if sumSquares > 0 {
+		fairness.Index = sum * sum / (float64(len(fairness.Workers)) * sumSquares)
+	}
+
+	fairness.SlowestWorker = slowest.Worker
+	if median := CalculateStats(medians).P50; median > 0 {
+		fairness.Skew = slowest.P50 / median
+	}
+	fairness.Skewed = fairness.Skew >= FairnessSkewThreshold
+	return fairness
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// workerMetrics returns n successful requests of worker taking latency
+func workerMetrics(worker, n int, latency time.Duration) []LatencyMetrics {
+	metrics := make([]LatencyMetrics, n)
+	for i := range metrics {
+		metrics[i] = LatencyMetrics{Worker: worker, TotalLatency: latency, StatusCode: http.StatusOK}
+	}
+	return metrics
+}
+
+// TestCalculateWorkerFairness tests the fairness index and skew detection
+func TestCalculateWorkerFairness(t *testing.T) {
+	var even []LatencyMetrics
+	for worker := 0; worker < 4; worker++ {
+		even = append(even, workerMetrics(worker, 10, 10*time.Millisecond)...)
+	}
+	fairness := calculateWorkerFairness(even)
+	if fairness == nil || len(fairness.Workers) != 4 {
+		t.Fatalf("Expected 4 workers, got %+v", fairness)
+	}
+	if math.Abs(fairness.Index-1) > 1e-9 || fairness.Skew != 1 || fairness.Skewed {
+		t.Errorf("Expected perfectly fair workers, got %+v", fairness)
+	}
+
+	// Worker 2 is three times slower than the rest
+	skewed := append(workerMetrics(0, 10, 10*time.Millisecond), workerMetrics(1, 10, 10*time.Millisecond)...)
+	skewed = append(skewed, workerMetrics(2, 10, 30*time.Millisecond)...)
+	skewed = append(skewed, workerMetrics(3, 10, 10*time.Millisecond)...)
+	// Failures and workers with too few samples are ignored
+	skewed = append(skewed, LatencyMetrics{Worker: 0, TotalLatency: time.Second, Error: "timeout"})
+	skewed = append(skewed, workerMetrics(4, minWorkerSamples-1, time.Second)...)
+	fairness = calculateWorkerFairness(skewed)
+	if fairness == nil || len(fairness.Workers) != 4 {
+		t.Fatalf("Expected 4 workers, got %+v", fairness)
+	}
+	if fairness.SlowestWorker != 2 || fairness.Skew != 3 || !fairness.Skewed {
+		t.Errorf("Expected worker 2 flagged at 3x, got %+v", fairness)
+	}
+	// (10+10+30+10)^2 / (4 * (100+100+900+100))
+	if want := 3600.0 / 4800; math.Abs(fairness.Index-want) > 1e-9 {
+		t.Errorf("Expected fairness index %.3f, got %.3f", want, fairness.Index)
+	}
+
+	if fairness := calculateWorkerFairness(workerMetrics(0, 20, time.Millisecond)); fairness != nil {
+		t.Errorf("Expected no fairness stats for a single worker, got %+v", fairness)
+	}
+}
+
+// TestBenchmarkerWorkerFairness tests that requests are attributed to workers
+func TestBenchmarkerWorkerFairness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer server.Close()
+
+	result, err := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 40,
+		Concurrency:   2,
+		KeepAlive:     true,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	fairness := result.WorkerFairness
+	if fairness == nil || len(fairness.Workers) != 2 {
+		t.Fatalf("Expected stats for 2 workers, got %+v", fairness)
+	}
+	if requests := fairness.Workers[0].Requests + fairness.Workers[1].Requests; requests != 40 {
+		t.Errorf("Expected all 40 requests attributed, got %d", requests)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request body formats produced by the workload generator
+const (
+	WorkloadFormatAnthropic = "anthropic" // Messages API, system prompt as a top-level field
+	WorkloadFormatOpenAI    = "openai"    // Chat completions, system prompt as a message
+)
+
+// Prompt length distributions
+const (
+	LengthDistributionCorpus  = "corpus"  // Sample prompts uniformly, keeping the corpus's own mix
+	LengthDistributionUniform = "uniform" // Prompt lengths spread evenly between the bounds
+	LengthDistributionNormal  = "normal"  // Prompt lengths clustered around a mean
+)
+
+// DefaultMaxTokens is used when neither the workload nor the prompt sets max_tokens
+const DefaultMaxTokens = 256
+
+// WorkloadConfig generates LLM request bodies from a prompt corpus instead of
+// sending a fixed body
+type WorkloadConfig struct {
+	CorpusPath string `yaml:"corpus_path"` // JSONL file, one CorpusPrompt per line
+	Format     string `yaml:"format"`
+	Model      string `yaml:"model"`
+
+	// Prompt lengths, in estimated tokens. Prompts outside the bounds are
+	// dropped; a zero MaxPromptTokens means no upper bound
+	LengthDistribution string  `yaml:"length_distribution"`
+	MinPromptTokens    int     `yaml:"min_prompt_tokens"`
+	MaxPromptTokens    int     `yaml:"max_prompt_tokens"`
+	MeanPromptTokens   float64 `yaml:"mean_prompt_tokens"`   // Normal distribution only
+	StdDevPromptTokens float64 `yaml:"stddev_prompt_tokens"` // Normal distribution only
+
+	// max_tokens is drawn uniformly from the range; when both are zero the
+	// prompt's own max_tokens or DefaultMaxTokens is used
+	MinMaxTokens int `yaml:"min_max_tokens"`
+	MaxMaxTokens int `yaml:"max_max_tokens"`
+
+	// Seed makes the requests reproducible, each request's body depending
+	// only on the seed and its ID; zero seeds from the clock
+	Seed int64 `yaml:"seed"`
+}
+
+// DefaultWorkloadConfig returns a workload that samples the corpus as-is
+func DefaultWorkloadConfig(corpusPath string) *WorkloadConfig {
+	return &WorkloadConfig{
+		CorpusPath:         corpusPath,
+		Format:             WorkloadFormatAnthropic,
+		Model:              "claude-sonnet-4-20250514",
+		LengthDistribution: LengthDistributionCorpus,
+	}
+}
+
+// CorpusPrompt is one line of a prompt corpus file
+type CorpusPrompt struct {
+	Prompt    string `json:"prompt"`
+	System    string `json:"system,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+
+	tokens int // Estimated prompt tokens
+}
+
+// WorkloadSample is a generated request body and the token counts behind it
+type WorkloadSample struct {
+	Body         []byte
+	PromptTokens int
+	MaxTokens    int
+}
+
+// WorkloadStats summarizes the token counts a benchmark actually sent
+type WorkloadStats struct {
+	Requests        int     `json:"requests"`
+	AvgPromptTokens float64 `json:"avg_prompt_tokens"`
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	MaxPromptTokens int     `json:"max_prompt_tokens"`
+	AvgMaxTokens    float64 `json:"avg_max_tokens"`
+}
+
+// WorkloadGenerator samples request bodies from a prompt corpus. It is safe
+// for concurrent use
+type WorkloadGenerator struct {
+	config  WorkloadConfig
+	prompts []CorpusPrompt // Sorted by estimated tokens
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewWorkloadGenerator loads the corpus and keeps the prompts within the
+// configured length bounds
+func NewWorkloadGenerator(config *WorkloadConfig) (*WorkloadGenerator, error) {
+	cfg := *config
+	if cfg.Format == "" {
+		cfg.Format = WorkloadFormatAnthropic
+	}
+	if cfg.LengthDistribution == "" {
+		cfg.LengthDistribution = LengthDistributionCorpus
+	}
+
+	switch cfg.Format {
+	case WorkloadFormatAnthropic, WorkloadFormatOpenAI:
+	default:
+		return nil, fmt.Errorf("unknown workload format %q", cfg.Format)
+	}
+	switch cfg.LengthDistribution {
+	case LengthDistributionCorpus, LengthDistributionUniform, LengthDistributionNormal:
+	default:
+		return nil, fmt.Errorf("unknown prompt length distribution %q", cfg.LengthDistribution)
+	}
+	if cfg.MaxPromptTokens > 0 && cfg.MaxPromptTokens < cfg.MinPromptTokens {
+		return nil, fmt.Errorf("max_prompt_tokens %d is below min_prompt_tokens %d", cfg.MaxPromptTokens, cfg.MinPromptTokens)
+	}
+	if cfg.MaxMaxTokens < cfg.MinMaxTokens {
+		return nil, fmt.Errorf("max_max_tokens %d is below min_max_tokens %d", cfg.MaxMaxTokens, cfg.MinMaxTokens)
+	}
+
+	corpus, err := LoadPromptCorpus(cfg.CorpusPath)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts := make([]CorpusPrompt, 0, len(corpus))
+	for _, prompt := range corpus {
+		if prompt.tokens < cfg.MinPromptTokens || (cfg.MaxPromptTokens > 0 && prompt.tokens > cfg.MaxPromptTokens) {
+			continue
+		}
+		prompts = append(prompts, prompt)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("no prompts in %s between %d and %d tokens", cfg.CorpusPath, cfg.MinPromptTokens, cfg.MaxPromptTokens)
+	}
+	sort.SliceStable(prompts, func(i, j int) bool { return prompts[i].tokens < prompts[j].tokens })
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &WorkloadGenerator{
+		config:  cfg,
+		prompts: prompts,
+		rng:     rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// LoadPromptCorpus reads a JSONL corpus, skipping blank lines
+func LoadPromptCorpus(path string) ([]CorpusPrompt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompt corpus: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []CorpusPrompt
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var prompt CorpusPrompt
+		if err := json.Unmarshal([]byte(text), &prompt); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt corpus line %d: %w", line, err)
+		}
+		if prompt.Prompt == "" {
+			return nil, fmt.Errorf("prompt corpus line %d has no prompt", line)
+		}
+		prompt.tokens = EstimateTokens(prompt.System) + EstimateTokens(prompt.Prompt)
+		prompts = append(prompts, prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompt corpus: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("prompt corpus %s is empty", path)
+	}
+	return prompts, nil
+}
+
+// EstimateTokens approximates the token count of text at four characters per
+// token, close enough to shape a workload without a tokenizer
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
+
+// Next returns the body for the next request
+func (g *WorkloadGenerator) Next() *WorkloadSample {
+	g.mu.Lock()
+	prompt := g.pickPrompt(g.rng)
+	maxTokens := g.pickMaxTokens(g.rng, prompt)
+	g.mu.Unlock()
+
+	return g.sample(prompt, maxTokens)
+}
+
+// Sample returns the body for the request with the given ID. With a seed
+// the body depends only on the seed and the ID, so it is the same whichever
+// worker sends the request; without one it is the next body
+func (g *WorkloadGenerator) Sample(requestID int) *WorkloadSample {
+	if g.config.Seed == 0 {
+		return g.Next()
+	}
+	rng := newSeededRand(requestSeed(g.config.Seed, requestID))
+	prompt := g.pickPrompt(rng)
+	return g.sample(prompt, g.pickMaxTokens(rng, prompt))
+}
+
+// sample builds the sample for prompt
+func (g *WorkloadGenerator) sample(prompt *CorpusPrompt, maxTokens int) *WorkloadSample {
+	return &WorkloadSample{
+		Body:         g.buildBody(prompt, maxTokens),
+		PromptTokens: prompt.tokens,
+		MaxTokens:    maxTokens,
+	}
+}
+
+// pickPrompt draws a prompt according to the length distribution
+func (g *WorkloadGenerator) pickPrompt(rng *rand.Rand) *CorpusPrompt {
+	shortest := g.prompts[0].tokens
+	longest := g.prompts[len(g.prompts)-1].tokens
+
+	var target float64
+	switch g.config.LengthDistribution {
+	case LengthDistributionUniform:
+		target = float64(shortest) + rng.Float64()*float64(longest-shortest)
+	case LengthDistributionNormal:
+		mean := g.config.MeanPromptTokens
+		if mean <= 0 {
+			mean = float64(shortest+longest) / 2
+		}
+		stddev := g.config.StdDevPromptTokens
+		if stddev <= 0 {
+			stddev = float64(longest-shortest) / 6
+		}
+		target = mean + rng.NormFloat64()*stddev
+	default:
+		return &g.prompts[rng.Intn(len(g.prompts))]
+	}
+
+	return &g.prompts[g.nearestPrompt(rng, int(math.Round(target)))]
+}
+
+// nearestPrompt returns the index of a prompt whose length is closest to
+// tokens, choosing randomly among prompts of that length
+func (g *WorkloadGenerator) nearestPrompt(rng *rand.Rand, tokens int) int {
+	i := sort.Search(len(g.prompts), func(i int) bool { return g.prompts[i].tokens >= tokens })
+	if i == len(g.prompts) || (i > 0 && tokens-g.prompts[i-1].tokens < g.prompts[i].tokens-tokens) {
+		i--
+	}
+
+	length := g.prompts[i].tokens
+	first := sort.Search(len(g.prompts), func(j int) bool { return g.prompts[j].tokens >= length })
+	last := sort.Search(len(g.prompts), func(j int) bool { return g.prompts[j].tokens > length })
+	return first + rng.Intn(last-first)
+}
+
+// pickMaxTokens draws max_tokens for a request
+func (g *WorkloadGenerator) pickMaxTokens(rng *rand.Rand, prompt *CorpusPrompt) int {
+	lo, hi := g.config.MinMaxTokens, g.config.MaxMaxTokens
+	switch {
+	case hi > 0:
+		if lo <= 0 {
+			lo = 1
+		}
+		return lo + rng.Intn(hi-lo+1)
+	case prompt.MaxTokens > 0:
+		return prompt.MaxTokens
+	}
+	return DefaultMaxTokens
+}
+
+// chatMessage is a message in either supported request format
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatRequest is the request body for either supported format
+type chatRequest struct {
+	Model     string        `json:"model,omitempty"`
+	MaxTokens int           `json:"max_tokens"`
+	System    string        `json:"system,omitempty"`
+	Messages  []chatMessage `json:"messages"`
+}
+
+// buildBody encodes prompt in the configured format
+func (g *WorkloadGenerator) buildBody(prompt *CorpusPrompt, maxTokens int) []byte {
+	request := chatRequest{
+		Model:     g.config.Model,
+		MaxTokens: maxTokens,
+	}
+
+	if g.config.Format == WorkloadFormatOpenAI {
+		if prompt.System != "" {
+			request.Messages = append(request.Messages, chatMessage{Role: "system", Content: prompt.System})
+		}
+	} else {
+		request.System = prompt.System
+	}
+	request.Messages = append(request.Messages, chatMessage{Role: "user", Content: prompt.Prompt})
+
+	// Only strings and ints, so encoding cannot fail
+	body, _ := json.Marshal(request)
+	return body
+}
+
+// calculateWorkloadStats summarizes the token counts of metrics, or returns
+// nil if no request carried a generated body
+func calculateWorkloadStats(metrics []LatencyMetrics) *WorkloadStats {
+	var stats WorkloadStats
+	var promptTotal, maxTotal int
+	for _, m := range metrics {
+		if m.PromptTokens == 0 {
+			continue
+		}
+		if stats.Requests == 0 || m.PromptTokens < stats.MinPromptTokens {
+			stats.MinPromptTokens = m.PromptTokens
+		}
+		if m.PromptTokens > stats.MaxPromptTokens {
+			stats.MaxPromptTokens = m.PromptTokens
+		}
+		stats.Requests++
+		promptTotal += m.PromptTokens
+		maxTotal += m.MaxTokens
+	}
+	if stats.Requests == 0 {
+		return nil
+	}
+
+	stats.AvgPromptTokens = float64(promptTotal) / float64(stats.Requests)
+	stats.AvgMaxTokens = float64(maxTotal) / float64(stats.Requests)
+	return &stats
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeCorpus writes prompts of the given lengths in estimated tokens
+func writeCorpus(t *testing.T, tokens ...int) string {
+	t.Helper()
+
+	var lines []string
+	for _, n := range tokens {
+		line, _ := json.Marshal(CorpusPrompt{Prompt: strings.Repeat("abcd", n), MaxTokens: n})
+		lines = append(lines, string(line))
+	}
+
+	path := filepath.Join(t.TempDir(), "corpus.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write corpus: %v", err)
+	}
+	return path
+}
+
+// TestWorkloadGenerator tests length filtering, max_tokens ranges and both body formats
+func TestWorkloadGenerator(t *testing.T) {
+	corpus := writeCorpus(t, 10, 50, 100, 200, 400)
+
+	config := DefaultWorkloadConfig(corpus)
+	config.LengthDistribution = LengthDistributionUniform
+	config.MinPromptTokens = 50
+	config.MaxPromptTokens = 200
+	config.MinMaxTokens = 64
+	config.MaxMaxTokens = 128
+	config.Seed = 1
+
+	generator, err := NewWorkloadGenerator(config)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		sample := generator.Next()
+		if sample.PromptTokens < 50 || sample.PromptTokens > 200 {
+			t.Fatalf("Prompt of %d tokens is outside the bounds", sample.PromptTokens)
+		}
+		if sample.MaxTokens < 64 || sample.MaxTokens > 128 {
+			t.Fatalf("max_tokens %d is outside the range", sample.MaxTokens)
+		}
+		seen[sample.PromptTokens] = true
+
+		var body chatRequest
+		if err := json.Unmarshal(sample.Body, &body); err != nil {
+			t.Fatalf("Body is not valid JSON: %v", err)
+		}
+		if body.Model != config.Model || body.MaxTokens != sample.MaxTokens || len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+			t.Fatalf("Unexpected body: %s", sample.Body)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all 3 in-bounds lengths to be sampled, got %v", seen)
+	}
+
+	// Without a range each prompt keeps its own max_tokens
+	config.MinMaxTokens, config.MaxMaxTokens = 0, 0
+	config.Format = WorkloadFormatOpenAI
+	generator, err = NewWorkloadGenerator(config)
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	if sample := generator.Next(); sample.MaxTokens != sample.PromptTokens {
+		t.Errorf("Expected the corpus max_tokens %d, got %d", sample.PromptTokens, sample.MaxTokens)
+	}
+
+	config.MinPromptTokens, config.MaxPromptTokens = 500, 1000
+	if _, err := NewWorkloadGenerator(config); err == nil {
+		t.Error("Expected an error when no prompt fits the bounds")
+	}
+	config.MinPromptTokens, config.MaxPromptTokens = 0, 0
+	config.LengthDistribution = "zipf"
+	if _, err := NewWorkloadGenerator(config); err == nil {
+		t.Error("Expected an error for an unknown distribution")
+	}
+}
+
+// TestBenchmarkerWorkload tests that a benchmark sends generated bodies and
+// reports the token counts it sent
+func TestBenchmarkerWorkload(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body chatRequest
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(data, &body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	benchmarker := NewBenchmarker(BenchmarkConfig{
+		TargetURL:     server.URL,
+		TotalRequests: 20,
+		Concurrency:   4,
+		Workload:      DefaultWorkloadConfig(writeCorpus(t, 20, 40)),
+	})
+
+	result, err := benchmarker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if result.SuccessfulReqs != 20 || len(bodies) != 20 {
+		t.Fatalf("Expected 20 accepted requests, got %d (%d bodies)", result.SuccessfulReqs, len(bodies))
+	}
+	if result.Workload == nil || result.Workload.Requests != 20 ||
+		result.Workload.MinPromptTokens < 20 || result.Workload.MaxPromptTokens > 40 {
+		t.Errorf("Unexpected workload stats: %+v", result.Workload)
+	}
+
+	missing := NewBenchmarker(BenchmarkConfig{
+		TargetURL: server.URL,
+		Workload:  DefaultWorkloadConfig(filepath.Join(t.TempDir(), "missing.jsonl")),
+	})
+	if _, err := missing.Run(context.Background()); err == nil {
+		t.Error("Expected an error for a missing corpus")
+	}
+}